@@ -0,0 +1,66 @@
+// Package accounts queries the runtime's accounts module with subcalls, which
+// unlike eth_getBalance exposes every denomination an account holds:
+//
+//	balances, _ := accounts.Balances(ctx, backend, addr)
+//	wei := accounts.NativeBalance(balances)
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/accounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/consensus"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+const methodBalances = "accounts.Balances"
+
+// Balances returns the amount of each denomination addr holds, with a query
+// subcall. Denominations the account holds none of are left out.
+//
+// The native denomination, ROSE, is types.NativeDenomination, whose amounts
+// are in runtime base units, i.e. wei as eth_getBalance reports them.
+func Balances(ctx context.Context, caller bind.ContractCaller, addr common.Address) (map[types.Denomination]*big.Int, error) {
+	res, err := precompiles.Subcall(ctx, caller, methodBalances, &accounts.BalancesQuery{Address: consensus.EthAddress(addr)})
+	if err != nil {
+		return nil, err
+	}
+	var body accounts.AccountBalances
+	if err = res.Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", methodBalances, err)
+	}
+	balances := make(map[types.Denomination]*big.Int, len(body.Balances))
+	for denomination, amount := range body.Balances {
+		balances[denomination] = amount.ToBigInt()
+	}
+	return balances, nil
+}
+
+// NativeBalance returns the native balance in balances, in wei. It is zero if
+// the account holds no ROSE.
+func NativeBalance(balances map[types.Denomination]*big.Int) *big.Int {
+	if wei, err := ToWei(types.NativeDenomination, balances[types.NativeDenomination]); err == nil {
+		return wei
+	}
+	return new(big.Int)
+}
+
+// ToWei returns amount of denomination in wei. Only the native denomination
+// is denominated in wei, others have their own decimals and can't be
+// converted.
+func ToWei(denomination types.Denomination, amount *big.Int) (*big.Int, error) {
+	if !denomination.IsNative() {
+		return nil, fmt.Errorf("%w: %s is not the native denomination", precompiles.ErrInvalidInput, denomination)
+	}
+	if amount == nil {
+		return nil, fmt.Errorf("%w: no amount", precompiles.ErrInvalidInput)
+	}
+	return new(big.Int).Set(amount), nil
+}
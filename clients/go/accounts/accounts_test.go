@@ -0,0 +1,117 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/accounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/client"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/consensus"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+type fakeCaller struct {
+	respond func(msg ethereum.CallMsg) ([]byte, error)
+}
+
+func (c *fakeCaller) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (c *fakeCaller) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	return c.respond(msg)
+}
+
+func TestBalances(t *testing.T) {
+	addr := common.HexToAddress("0x1234")
+	stringType, _ := abi.NewType("string", "", nil)
+	bytesType, _ := abi.NewType("bytes", "", nil)
+	uint64Type, _ := abi.NewType("uint64", "", nil)
+	var status uint64
+	var response []byte
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		values, err := abi.Arguments{{Type: stringType}, {Type: bytesType}}.Unpack(msg.Data)
+		if err != nil || values[0].(string) != "accounts.Balances" {
+			t.Fatalf("unexpected subcall %v: %v", values, err)
+		}
+		var body accounts.BalancesQuery
+		if err = cbor.Unmarshal(values[1].([]byte), &body); err != nil || !body.Address.Equal(consensus.EthAddress(addr)) {
+			t.Fatalf("unexpected query %+v: %v", body, err)
+		}
+		return abi.Arguments{{Type: uint64Type}, {Type: bytesType}}.Pack(status, response)
+	}}
+	ctx := context.Background()
+
+	wei, _ := new(big.Int).SetString("1234567890123456789012", 10)
+	var q quantity.Quantity
+	_ = q.FromBigInt(wei)
+	token := types.Denomination("TEST")
+	response = cbor.Marshal(accounts.AccountBalances{Balances: map[types.Denomination]types.Quantity{
+		types.NativeDenomination: q,
+		token:                    *quantity.NewFromUint64(7),
+	}})
+	balances, err := Balances(ctx, caller, addr)
+	if err != nil || len(balances) != 2 || balances[token].Int64() != 7 {
+		t.Fatalf("unexpected balances %v: %v", balances, err)
+	}
+	if NativeBalance(balances).Cmp(wei) != 0 {
+		t.Fatalf("unexpected native balance %v", NativeBalance(balances))
+	}
+	if _, err = ToWei(token, balances[token]); !errors.Is(err, precompiles.ErrInvalidInput) {
+		t.Fatalf("expected %s to not convert to wei, got %v", token, err)
+	}
+
+	// Empty accounts hold nothing.
+	response = cbor.Marshal(accounts.AccountBalances{})
+	if balances, err = Balances(ctx, caller, addr); err != nil || len(balances) != 0 || NativeBalance(balances).Sign() != 0 {
+		t.Fatalf("expected no balances, got %v: %v", balances, err)
+	}
+
+	status, response = 1, []byte("accounts")
+	if _, err = Balances(ctx, caller, addr); !errors.Is(err, sapphire.ErrCallFailed) {
+		t.Fatalf("expected a failed query to fail, got %v", err)
+	}
+	status, response = 0, []byte{0xff}
+	if _, err = Balances(ctx, caller, addr); err == nil {
+		t.Fatalf("expected a malformed response to fail")
+	}
+}
+
+func TestBalancesLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	signer := sapphire.NewPrivateKeySigner(key)
+	eth := localnet.Dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	b, err := client.WrapClient(eth, nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+
+	balances, err := Balances(ctx, b, signer.Address())
+	if err != nil {
+		t.Fatalf("Balances failed: %v", err)
+	}
+	// Nothing else spends from the account while the test runs.
+	wei, err := b.BalanceAt(ctx, signer.Address(), nil)
+	if err != nil {
+		t.Fatalf("BalanceAt failed: %v", err)
+	}
+	if NativeBalance(balances).Sign() == 0 || NativeBalance(balances).Cmp(wei) != 0 {
+		t.Fatalf("accounts module reports %v, eth_getBalance %v", NativeBalance(balances), wei)
+	}
+}
@@ -0,0 +1,261 @@
+package sapphire
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/deoxysii"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+// ArtifactSchemaVersion is the version of the JSON encoding of CallArtifact.
+// Fields are only ever added to a version; anything else bumps it.
+const ArtifactSchemaVersion = 1
+
+// ErrInvalidArtifact is returned by CallArtifact.Verify for artifacts whose
+// fields don't match each other, and when decoding malformed artifacts.
+var ErrInvalidArtifact = errors.New("invalid call artifact")
+
+// CallArtifact records how a confidential transaction was built, for audits.
+// Verify checks that its fields are consistent with each other.
+//
+// It holds the key of the session the calldata was encrypted in, so it
+// reveals the plaintext of every transaction of that session: store
+// artifacts like the plaintexts themselves.
+//
+// The JSON encoding is versioned, see ArtifactSchemaVersion:
+//
+//	{
+//	  "schemaVersion": 1,
+//	  "txHash": "0x…",
+//	  "chainId": "0x5afd",
+//	  "from": "0x…",
+//	  "to": "0x…",
+//	  "nonce": "0x7",
+//	  "plaintextHash": "0x…",
+//	  "envelope": "0x…",
+//	  "signature": "0x…",
+//	  "transaction": "0x…",
+//	  "cipher": {"format": 1, "epoch": "0x2a", "publicKey": "0x…", "runtimePublicKey": "0x…", "sessionKey": "0x…"}
+//	}
+//
+// Byte fields and quantities are 0x-prefixed hex, as in Ethereum JSON-RPC.
+// The to field is null for deployments; the keys and epoch are omitted for
+// plain calldata.
+type CallArtifact struct {
+	TxHash  common.Hash
+	ChainID *big.Int
+	// From is the sender, To the callee or nil for deployments.
+	From  common.Address
+	To    *common.Address
+	Nonce uint64
+	// PlaintextHash is the Keccak-256 hash of the plaintext calldata.
+	PlaintextHash common.Hash
+	// Envelope is the calldata as sent, a CBOR-encoded call envelope.
+	Envelope []byte
+	// Signature is the transaction's signature in RSV format, with V 0 or 1.
+	Signature []byte
+	// Transaction is the signed transaction, see types.Transaction.MarshalBinary.
+	Transaction []byte
+	Cipher      ArtifactCipher
+}
+
+// ArtifactCipher is how the calldata of a CallArtifact was encrypted.
+type ArtifactCipher struct {
+	Format sdkTypes.CallFormat
+	// Epoch is the epoch of the runtime key.
+	Epoch uint64
+	// PublicKey is the client's ephemeral key and RuntimePublicKey the
+	// runtime's, which SessionKey was derived from.
+	PublicKey        []byte
+	RuntimePublicKey []byte
+	SessionKey       []byte
+}
+
+type callArtifactJSON struct {
+	SchemaVersion int                `json:"schemaVersion"`
+	TxHash        common.Hash        `json:"txHash"`
+	ChainID       *hexutil.Big       `json:"chainId"`
+	From          common.Address     `json:"from"`
+	To            *common.Address    `json:"to"`
+	Nonce         hexutil.Uint64     `json:"nonce"`
+	PlaintextHash common.Hash        `json:"plaintextHash"`
+	Envelope      hexutil.Bytes      `json:"envelope"`
+	Signature     hexutil.Bytes      `json:"signature"`
+	Transaction   hexutil.Bytes      `json:"transaction"`
+	Cipher        artifactCipherJSON `json:"cipher"`
+}
+
+type artifactCipherJSON struct {
+	Format           sdkTypes.CallFormat `json:"format"`
+	Epoch            *hexutil.Uint64     `json:"epoch,omitempty"`
+	PublicKey        hexutil.Bytes       `json:"publicKey,omitempty"`
+	RuntimePublicKey hexutil.Bytes       `json:"runtimePublicKey,omitempty"`
+	SessionKey       hexutil.Bytes       `json:"sessionKey,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a CallArtifact) MarshalJSON() ([]byte, error) {
+	enc := callArtifactJSON{
+		SchemaVersion: ArtifactSchemaVersion,
+		TxHash:        a.TxHash,
+		ChainID:       (*hexutil.Big)(a.ChainID),
+		From:          a.From,
+		To:            a.To,
+		Nonce:         hexutil.Uint64(a.Nonce),
+		PlaintextHash: a.PlaintextHash,
+		Envelope:      a.Envelope,
+		Signature:     a.Signature,
+		Transaction:   a.Transaction,
+		Cipher: artifactCipherJSON{
+			Format:           a.Cipher.Format,
+			PublicKey:        a.Cipher.PublicKey,
+			RuntimePublicKey: a.Cipher.RuntimePublicKey,
+			SessionKey:       a.Cipher.SessionKey,
+		},
+	}
+	if a.Cipher.Format != sdkTypes.CallFormatPlain {
+		epoch := hexutil.Uint64(a.Cipher.Epoch)
+		enc.Cipher.Epoch = &epoch
+	}
+	return json.Marshal(enc)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Artifacts of other schema
+// versions are rejected.
+func (a *CallArtifact) UnmarshalJSON(data []byte) error {
+	var dec callArtifactJSON
+	if err := json.Unmarshal(data, &dec); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidArtifact, err)
+	}
+	if dec.SchemaVersion != ArtifactSchemaVersion {
+		return fmt.Errorf("%w: schema version %d, expected %d", ErrInvalidArtifact, dec.SchemaVersion, ArtifactSchemaVersion)
+	}
+	if dec.ChainID == nil {
+		return fmt.Errorf("%w: no chain ID", ErrInvalidArtifact)
+	}
+	*a = CallArtifact{
+		TxHash:        dec.TxHash,
+		ChainID:       dec.ChainID.ToInt(),
+		From:          dec.From,
+		To:            dec.To,
+		Nonce:         uint64(dec.Nonce),
+		PlaintextHash: dec.PlaintextHash,
+		Envelope:      dec.Envelope,
+		Signature:     dec.Signature,
+		Transaction:   dec.Transaction,
+		Cipher: ArtifactCipher{
+			Format:           dec.Cipher.Format,
+			PublicKey:        dec.Cipher.PublicKey,
+			RuntimePublicKey: dec.Cipher.RuntimePublicKey,
+			SessionKey:       dec.Cipher.SessionKey,
+		},
+	}
+	if dec.Cipher.Epoch != nil {
+		a.Cipher.Epoch = uint64(*dec.Cipher.Epoch)
+	}
+	return nil
+}
+
+// Verify checks that the artifact is internally consistent: that the
+// transaction has its hash, chain ID, callee, nonce, calldata and signature,
+// that the signature recovers the sender, and that the envelope decrypts
+// with the session key to calldata of the plaintext hash. It does not check
+// that the session key belongs to the public keys, which takes the secret of
+// one of them. Mismatches fail with ErrInvalidArtifact.
+func (a *CallArtifact) Verify() error {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(a.Transaction); err != nil {
+		return fmt.Errorf("%w: malformed transaction: %w", ErrInvalidArtifact, err)
+	}
+	switch {
+	case tx.Hash() != a.TxHash:
+		return fmt.Errorf("%w: transaction hash is %s, recorded %s", ErrInvalidArtifact, tx.Hash().Hex(), a.TxHash.Hex())
+	case a.ChainID == nil || tx.ChainId().Cmp(a.ChainID) != 0:
+		return fmt.Errorf("%w: transaction is for chain %s, recorded %v", ErrInvalidArtifact, tx.ChainId(), a.ChainID)
+	case !sameAddress(tx.To(), a.To):
+		return fmt.Errorf("%w: transaction callee differs", ErrInvalidArtifact)
+	case tx.Nonce() != a.Nonce:
+		return fmt.Errorf("%w: transaction nonce is %d, recorded %d", ErrInvalidArtifact, tx.Nonce(), a.Nonce)
+	case !bytes.Equal(tx.Data(), a.Envelope):
+		return fmt.Errorf("%w: transaction calldata differs from the envelope", ErrInvalidArtifact)
+	}
+
+	signer := types.LatestSignerForChainID(a.ChainID)
+	_, r, s := tx.RawSignatureValues()
+	if len(a.Signature) != crypto.SignatureLength || new(big.Int).SetBytes(a.Signature[:32]).Cmp(r) != 0 || new(big.Int).SetBytes(a.Signature[32:64]).Cmp(s) != 0 {
+		return fmt.Errorf("%w: signature differs from the transaction's", ErrInvalidArtifact)
+	}
+	pub, err := crypto.SigToPub(signer.Hash(tx).Bytes(), a.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: invalid signature: %w", ErrInvalidArtifact, err)
+	}
+	if sender := crypto.PubkeyToAddress(*pub); sender != a.From {
+		return fmt.Errorf("%w: signature recovers %s, recorded sender %s", ErrInvalidArtifact, sender.Hex(), a.From.Hex())
+	}
+
+	plaintext, err := a.Cipher.open(a.Envelope)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidArtifact, err)
+	}
+	if hash := crypto.Keccak256Hash(plaintext); hash != a.PlaintextHash {
+		return fmt.Errorf("%w: envelope decrypts to calldata of hash %s, recorded %s", ErrInvalidArtifact, hash.Hex(), a.PlaintextHash.Hex())
+	}
+	return nil
+}
+
+// open returns the plaintext calldata of envelope.
+func (c *ArtifactCipher) open(envelope []byte) ([]byte, error) {
+	limits := DefaultDecodeLimits
+	var call sdkTypes.Call
+	if err := limits.unmarshal(envelope, &call); err != nil {
+		return nil, fmt.Errorf("malformed envelope: %w", err)
+	}
+	if call.Format != c.Format {
+		return nil, fmt.Errorf("envelope has format %d, recorded %d", call.Format, c.Format)
+	}
+	switch call.Format {
+	case sdkTypes.CallFormatPlain:
+	case sdkTypes.CallFormatEncryptedX25519DeoxysII:
+		var body sdkTypes.CallEnvelopeX25519DeoxysII
+		if err := limits.unmarshal(call.Body, &body); err != nil {
+			return nil, fmt.Errorf("malformed envelope body: %w", err)
+		}
+		if body.Epoch != c.Epoch || !bytes.Equal(body.Pk[:], c.PublicKey) {
+			return nil, fmt.Errorf("envelope is for epoch %d and key %x, recorded %d and %x", body.Epoch, body.Pk[:], c.Epoch, c.PublicKey)
+		}
+		aead, err := deoxysii.New(c.SessionKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session key: %w", err)
+		}
+		decrypted, err := aead.Open(nil, body.Nonce[:], body.Data, []byte{})
+		if err != nil {
+			return nil, fmt.Errorf("envelope does not decrypt with the session key: %w", err)
+		}
+		call = sdkTypes.Call{}
+		if err = limits.unmarshal(decrypted, &call); err != nil {
+			return nil, fmt.Errorf("malformed decrypted call: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported call format %d", call.Format)
+	}
+	var plaintext []byte
+	if err := limits.unmarshal(call.Body, &plaintext); err != nil {
+		return nil, fmt.Errorf("malformed calldata: %w", err)
+	}
+	return plaintext, nil
+}
+
+func sameAddress(a, b *common.Address) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
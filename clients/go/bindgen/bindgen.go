@@ -0,0 +1,459 @@
+// Package bindgen generates wrappers around abigen bindings whose view methods
+// are signed queries and whose transactions are encrypted, so that call sites
+// can't forget to pass Sapphire-specific options.
+//
+// For a binding type Foo, Generate adds a SapphireFoo type to the bindings
+// package. It embeds *Foo and replaces each of its view and transaction
+// methods with one taking a context instead of bind.CallOpts or
+// bind.TransactOpts:
+//
+//	store, _ := NewSapphireStore(addr, backend, signer)
+//	value, _ := store.Get(ctx)                 // Signed query from signer.
+//	tx, _ := store.Set(ctx, big.NewInt(7))     // Encrypted, signed by signer.
+//
+// The wrappers take a client.WrappedBackend, see the client package.
+//
+// The bindings are read with go/types, abigen is not run again. Use it from a
+// go:generate directive next to the bindings:
+//
+//	//go:generate go run github.com/oasisprotocol/sapphire-paratime/clients/go/bindgen/cmd/sapphire-bindgen
+package bindgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultOutput is the file Generate writes to if Config.Output is empty.
+const DefaultOutput = "sapphire_bindings.go"
+
+const (
+	bindPath     = "github.com/ethereum/go-ethereum/accounts/abi/bind"
+	commonPath   = "github.com/ethereum/go-ethereum/common"
+	sapphirePath = "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	clientPath   = sapphirePath + "/client"
+)
+
+// Config configures Generate.
+type Config struct {
+	// Dir is the directory of the package holding the bindings, the current
+	// directory if empty.
+	Dir string
+	// Types are the binding types to wrap, as passed to abigen's --type. All
+	// bindings in the package are wrapped if empty.
+	Types []string
+	// Output is the file to write, relative to Dir. It defaults to
+	// DefaultOutput and is ignored when reading the bindings.
+	Output string
+}
+
+// Generate writes the wrappers for the bindings in cfg.Dir to cfg.Output. It
+// needs the go command to find the bindings' dependencies.
+func Generate(cfg Config) error {
+	src, err := Source(cfg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cfg.outputPath(), src, 0o644) //nolint:gosec
+}
+
+// Source returns the formatted source of the wrappers Generate would write.
+func Source(cfg Config) ([]byte, error) {
+	pkg, err := load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := findBindings(pkg, cfg.Types)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{imports: newImports(pkg.types)}
+	for _, b := range bindings {
+		if err = g.binding(b); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by sapphire-bindgen. DO NOT EDIT.\n\npackage %s\n\n", pkg.types.Name())
+	out.WriteString(g.imports.block())
+	out.Write(g.body.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("bindgen: failed to format output: %w", err)
+	}
+	return src, nil
+}
+
+func (cfg Config) outputPath() string {
+	output := cfg.Output
+	if output == "" {
+		output = DefaultOutput
+	}
+	if filepath.IsAbs(output) {
+		return output
+	}
+	return filepath.Join(cfg.Dir, output)
+}
+
+// loadedPackage is a type-checked bindings package.
+type loadedPackage struct {
+	types *types.Package
+	files []*ast.File
+}
+
+// listedPackage is the output of go list -json.
+type listedPackage struct {
+	ImportPath string
+	Dir        string
+	Export     string
+	GoFiles    []string
+	DepOnly    bool
+}
+
+// load type-checks the bindings package from source, against the export data
+// of its dependencies. An existing output file is skipped so that stale
+// wrappers can't get in the way.
+func load(cfg Config) (*loadedPackage, error) {
+	output, err := filepath.Abs(cfg.outputPath())
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("go", "list", "-e", "-export", "-deps", "-json=ImportPath,Dir,Export,GoFiles,DepOnly", ".")
+	cmd.Dir = cfg.Dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("bindgen: go list failed: %w: %s", err, stderr.String())
+	}
+
+	var target *listedPackage
+	exports := make(map[string]string)
+	for dec := json.NewDecoder(bytes.NewReader(out)); ; {
+		var p listedPackage
+		if err = dec.Decode(&p); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("bindgen: failed to decode go list output: %w", err)
+		}
+		exports[p.ImportPath] = p.Export
+		if !p.DepOnly {
+			target = &p
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("bindgen: no package found in %s", cfg.Dir)
+	}
+
+	fset := token.NewFileSet()
+	pkg := &loadedPackage{}
+	for _, name := range target.GoFiles {
+		path := filepath.Join(target.Dir, name)
+		if path == output {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("bindgen: %w", err)
+		}
+		pkg.files = append(pkg.files, f)
+	}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+			if exports[path] == "" {
+				return nil, fmt.Errorf("no export data for %s", path)
+			}
+			return os.Open(exports[path])
+		}),
+	}
+	if pkg.types, err = conf.Check(target.ImportPath, fset, pkg.files, nil); err != nil {
+		return nil, fmt.Errorf("bindgen: failed to type-check %s: %w", target.ImportPath, err)
+	}
+	return pkg, nil
+}
+
+// binding is an abigen binding type and the methods to wrap.
+type binding struct {
+	name     string
+	calls    []method
+	transact []method
+}
+
+type method struct {
+	fn *types.Func
+	// solidity is the "Solidity:" line of abigen's doc comment, if any.
+	solidity string
+}
+
+// findBindings returns the abigen bindings in pkg, those named in names if
+// set, sorted by name.
+func findBindings(pkg *loadedPackage, names []string) ([]binding, error) {
+	scope := pkg.types.Scope()
+	candidates := names
+	if len(candidates) == 0 {
+		for _, name := range scope.Names() {
+			if isBinding(scope, name) {
+				candidates = append(candidates, name)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("bindgen: no abigen bindings found in %s", pkg.types.Path())
+		}
+	}
+	sort.Strings(candidates)
+
+	docs := solidityDocs(pkg)
+	var bindings []binding
+	for _, name := range candidates {
+		if !isBinding(scope, name) {
+			return nil, fmt.Errorf("bindgen: %s is not an abigen binding", name)
+		}
+		b := binding{name: name}
+		for _, fn := range methods(scope, name+"Caller") {
+			if isOpts(fn, "CallOpts") {
+				b.calls = append(b.calls, method{fn, docs[fn.Pos()]})
+			}
+		}
+		for _, fn := range methods(scope, name+"Transactor") {
+			if isOpts(fn, "TransactOpts") {
+				b.transact = append(b.transact, method{fn, docs[fn.Pos()]})
+			}
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings, nil
+}
+
+// isBinding reports whether name is a binding type abigen generated, which
+// comes with Caller and Transactor types and a constructor.
+func isBinding(scope *types.Scope, name string) bool {
+	for _, typ := range []string{name, name + "Caller", name + "Transactor"} {
+		if _, ok := scope.Lookup(typ).(*types.TypeName); !ok {
+			return false
+		}
+	}
+	_, ok := scope.Lookup("New" + name).(*types.Func)
+	return ok
+}
+
+// methods returns the exported methods declared on *typ, in source order.
+func methods(scope *types.Scope, typ string) []*types.Func {
+	named, ok := scope.Lookup(typ).Type().(*types.Named)
+	if !ok {
+		return nil
+	}
+	var fns []*types.Func
+	for i := 0; i < named.NumMethods(); i++ {
+		if fn := named.Method(i); fn.Exported() {
+			fns = append(fns, fn)
+		}
+	}
+	sort.Slice(fns, func(i, j int) bool { return fns[i].Pos() < fns[j].Pos() })
+	return fns
+}
+
+// isOpts reports whether fn takes a *bind.<opts> as its first parameter.
+func isOpts(fn *types.Func, opts string) bool {
+	params := fn.Type().(*types.Signature).Params()
+	if params.Len() == 0 {
+		return false
+	}
+	ptr, ok := params.At(0).Type().(*types.Pointer)
+	if !ok {
+		return false
+	}
+	named, ok := ptr.Elem().(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == bindPath && named.Obj().Name() == opts
+}
+
+// solidityDocs maps method positions to the Solidity signature line of their
+// doc comments.
+func solidityDocs(pkg *loadedPackage) map[token.Pos]string {
+	docs := make(map[token.Pos]string)
+	for _, f := range pkg.files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Doc == nil {
+				continue
+			}
+			for _, line := range strings.Split(fn.Doc.Text(), "\n") {
+				if strings.HasPrefix(line, "Solidity:") {
+					docs[fn.Name.Pos()] = line
+				}
+			}
+		}
+	}
+	return docs
+}
+
+type generator struct {
+	imports *imports
+	body    bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+func (g *generator) binding(b binding) error {
+	wrapper := "Sapphire" + b.name
+	recv := "_" + b.name
+	for _, m := range append(append([]method{}, b.calls...), b.transact...) {
+		if m.fn.Name() == b.name {
+			return fmt.Errorf("bindgen: method %s.%s collides with the embedded binding", b.name, m.fn.Name())
+		}
+	}
+	commonPkg := g.imports.name(commonPath)
+	sapphirePkg := g.imports.name(sapphirePath)
+	clientPkg := g.imports.name(clientPath)
+
+	g.printf("// %s wraps %s so that its view methods are signed queries and its\n", wrapper, b.name)
+	g.printf("// transactions are encrypted and signed, on behalf of signer.\n")
+	g.printf("type %s struct {\n\t*%s\n\tbackend *%s.WrappedBackend\n\tsigner  %s.SignerWithAddress\n}\n\n", wrapper, b.name, clientPkg, sapphirePkg)
+
+	g.printf("// New%s binds %s at address through backend, querying and\n", wrapper, b.name)
+	g.printf("// transacting on behalf of signer.\n")
+	g.printf("func New%s(address %s.Address, backend *%s.WrappedBackend, signer %s.SignerWithAddress) (*%s, error) {\n", wrapper, commonPkg, clientPkg, sapphirePkg, wrapper)
+	g.printf("\tcontract, err := New%s(address, backend)\n\tif err != nil {\n\t\treturn nil, err\n\t}\n", b.name)
+	g.printf("\treturn &%s{%s: contract, backend: backend, signer: signer}, nil\n}\n\n", wrapper, b.name)
+
+	for _, m := range b.calls {
+		g.method(wrapper, recv, b.name, m, "as a signed query", fmt.Sprintf("%s.AuthenticatedCallOpts(ctx, %s.signer)", clientPkg, recv))
+	}
+	for _, m := range b.transact {
+		g.method(wrapper, recv, b.name, m, "as an encrypted transaction", recv+".transactOpts(ctx)")
+	}
+
+	if len(b.transact) > 0 {
+		g.printf("// transactOpts returns options signing with %s.signer, honoring ctx.\n", recv)
+		g.printf("func (%s *%s) transactOpts(ctx %s.Context) *%s.TransactOpts {\n", recv, wrapper, g.imports.name("context"), g.imports.name(bindPath))
+		g.printf("\topts := %s.backend.Transactor(%s.signer.Address())\n", recv, recv)
+		g.printf("\topts.Context = %s.ContextWithSigner(ctx, %s.signer)\n", sapphirePkg, recv)
+		g.printf("\treturn opts\n}\n\n")
+	}
+	return nil
+}
+
+// method writes a wrapper method replacing m's options by a context.
+func (g *generator) method(wrapper, recv, embedded string, m method, what, opts string) {
+	sig := m.fn.Type().(*types.Signature)
+	params := []string{"ctx " + g.imports.name("context") + ".Context"}
+	args := []string{opts}
+	for i := 1; i < sig.Params().Len(); i++ {
+		p := sig.Params().At(i)
+		name := p.Name()
+		if name == "" || name == "_" || name == "ctx" || name == recv {
+			name = fmt.Sprintf("arg%d", i-1)
+		}
+		params = append(params, name+" "+types.TypeString(p.Type(), g.imports.qualifier))
+		args = append(args, name)
+	}
+	var results []string
+	for i := 0; i < sig.Results().Len(); i++ {
+		results = append(results, types.TypeString(sig.Results().At(i).Type(), g.imports.qualifier))
+	}
+
+	name := m.fn.Name()
+	g.printf("// %s calls %s.%s %s.\n", name, embedded, name, what)
+	if m.solidity != "" {
+		g.printf("//\n// %s\n", m.solidity)
+	}
+	g.printf("func (%s *%s) %s(%s) (%s) {\n", recv, wrapper, name, strings.Join(params, ", "), strings.Join(results, ", "))
+	g.printf("\treturn %s.%s.%s(%s)\n}\n\n", recv, embedded, name, strings.Join(args, ", "))
+}
+
+// imports tracks the packages referenced by the generated code.
+type imports struct {
+	self  *types.Package
+	names map[string]string // By path.
+	taken map[string]bool
+}
+
+func newImports(self *types.Package) *imports {
+	taken := make(map[string]bool)
+	for _, name := range self.Scope().Names() {
+		taken[name] = true
+	}
+	return &imports{self: self, names: make(map[string]string), taken: taken}
+}
+
+// name returns the name to refer to the package at path by.
+func (im *imports) name(path string) string {
+	return im.add(path, defaultName(path))
+}
+
+func (im *imports) qualifier(pkg *types.Package) string {
+	if pkg == im.self {
+		return ""
+	}
+	return im.add(pkg.Path(), pkg.Name())
+}
+
+func (im *imports) add(path, name string) string {
+	if n, ok := im.names[path]; ok {
+		return n
+	}
+	n := name
+	for i := 2; im.taken[n]; i++ {
+		n = fmt.Sprintf("%s%d", name, i)
+	}
+	im.names[path], im.taken[n] = n, true
+	return n
+}
+
+func defaultName(path string) string {
+	if path == sapphirePath {
+		return "sapphire"
+	}
+	return lastElem(path)
+}
+
+func lastElem(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// block returns the import declaration, with the standard library first.
+func (im *imports) block() string {
+	var std, other []string
+	for path := range im.names {
+		if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	var b strings.Builder
+	b.WriteString("import (\n")
+	for i, group := range [][]string{std, other} {
+		if i > 0 && len(std) > 0 && len(other) > 0 {
+			b.WriteString("\n")
+		}
+		for _, path := range group {
+			if name := im.names[path]; name != lastElem(path) {
+				fmt.Fprintf(&b, "\t%s %q\n", name, path)
+			} else {
+				fmt.Fprintf(&b, "\t%q\n", path)
+			}
+		}
+	}
+	b.WriteString(")\n\n")
+	return b.String()
+}
@@ -0,0 +1,46 @@
+package bindgen
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/bindgen/testdata/store"
+)
+
+var update = flag.Bool("update", false, "update the golden output")
+
+// The golden output compiles against the bindings and the wrapped backend.
+var _ = fixture.NewSapphireStore
+
+func TestSource(t *testing.T) {
+	golden := filepath.Join("testdata", "store", DefaultOutput)
+	for _, types := range [][]string{nil, {"Store"}} {
+		src, err := Source(Config{Dir: filepath.Join("testdata", "store"), Types: types})
+		if err != nil {
+			t.Fatalf("Source failed: %v", err)
+		}
+		if *update {
+			if err = os.WriteFile(golden, src, 0o644); err != nil { //nolint:gosec
+				t.Fatalf("failed to update golden output: %v", err)
+			}
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("failed to read golden output: %v", err)
+		}
+		if !bytes.Equal(src, want) {
+			t.Fatalf("output for types %v differs from %s:\n%s", types, golden, src)
+		}
+	}
+}
+
+func TestSourceUnknownType(t *testing.T) {
+	_, err := Source(Config{Dir: filepath.Join("testdata", "store"), Types: []string{"StoreCaller"}})
+	if err == nil || !strings.Contains(err.Error(), "not an abigen binding") {
+		t.Fatalf("expected non-binding types to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+// Command sapphire-bindgen wraps the abigen bindings of the package in the
+// current directory, see the bindgen package.
+//
+// Usage:
+//
+//	sapphire-bindgen [-type Foo,Bar] [-out sapphire_bindings.go] [dir]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/bindgen"
+)
+
+func main() {
+	typeList := flag.String("type", "", "comma-separated binding types to wrap, all if empty")
+	out := flag.String("out", bindgen.DefaultOutput, "output file, relative to the package directory")
+	flag.Parse()
+
+	cfg := bindgen.Config{Dir: flag.Arg(0), Output: *out}
+	if *typeList != "" {
+		cfg.Types = strings.Split(*typeList, ",")
+	}
+	if err := bindgen.Generate(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
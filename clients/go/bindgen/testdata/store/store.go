@@ -0,0 +1,465 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package fixture
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+	_ = abi.ConvertType
+)
+
+// StoreMetaData contains all meta data concerning the Store contract.
+var StoreMetaData = &bind.MetaData{
+	ABI: "[{\"type\":\"constructor\",\"inputs\":[{\"name\":\"initial\",\"type\":\"uint256\"}],\"stateMutability\":\"nonpayable\"},{\"type\":\"function\",\"name\":\"get\",\"inputs\":[],\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\"},{\"type\":\"function\",\"name\":\"balanceOf\",\"inputs\":[{\"name\":\"owner\",\"type\":\"address\"}],\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\"},{\"type\":\"function\",\"name\":\"pair\",\"inputs\":[],\"outputs\":[{\"name\":\"a\",\"type\":\"uint256\"},{\"name\":\"b\",\"type\":\"bool\"}],\"stateMutability\":\"view\"},{\"type\":\"function\",\"name\":\"set\",\"inputs\":[{\"name\":\"value\",\"type\":\"uint256\"}],\"outputs\":[],\"stateMutability\":\"nonpayable\"},{\"type\":\"event\",\"name\":\"Set\",\"inputs\":[{\"name\":\"value\",\"type\":\"uint256\",\"indexed\":false}],\"anonymous\":false}]",
+	Bin: "0x6000",
+}
+
+// StoreABI is the input ABI used to generate the binding from.
+// Deprecated: Use StoreMetaData.ABI instead.
+var StoreABI = StoreMetaData.ABI
+
+// StoreBin is the compiled bytecode used for deploying new contracts.
+// Deprecated: Use StoreMetaData.Bin instead.
+var StoreBin = StoreMetaData.Bin
+
+// DeployStore deploys a new Ethereum contract, binding an instance of Store to it.
+func DeployStore(auth *bind.TransactOpts, backend bind.ContractBackend, initial *big.Int) (common.Address, *types.Transaction, *Store, error) {
+	parsed, err := StoreMetaData.GetAbi()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	if parsed == nil {
+		return common.Address{}, nil, nil, errors.New("GetABI returned nil")
+	}
+
+	address, tx, contract, err := bind.DeployContract(auth, *parsed, common.FromHex(StoreBin), backend, initial)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &Store{StoreCaller: StoreCaller{contract: contract}, StoreTransactor: StoreTransactor{contract: contract}, StoreFilterer: StoreFilterer{contract: contract}}, nil
+}
+
+// Store is an auto generated Go binding around an Ethereum contract.
+type Store struct {
+	StoreCaller     // Read-only binding to the contract
+	StoreTransactor // Write-only binding to the contract
+	StoreFilterer   // Log filterer for contract events
+}
+
+// StoreCaller is an auto generated read-only Go binding around an Ethereum contract.
+type StoreCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// StoreTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type StoreTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// StoreFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type StoreFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// StoreSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type StoreSession struct {
+	Contract     *Store            // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// StoreCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type StoreCallerSession struct {
+	Contract *StoreCaller  // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts // Call options to use throughout this session
+}
+
+// StoreTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type StoreTransactorSession struct {
+	Contract     *StoreTransactor  // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// StoreRaw is an auto generated low-level Go binding around an Ethereum contract.
+type StoreRaw struct {
+	Contract *Store // Generic contract binding to access the raw methods on
+}
+
+// StoreCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type StoreCallerRaw struct {
+	Contract *StoreCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// StoreTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type StoreTransactorRaw struct {
+	Contract *StoreTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewStore creates a new instance of Store, bound to a specific deployed contract.
+func NewStore(address common.Address, backend bind.ContractBackend) (*Store, error) {
+	contract, err := bindStore(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{StoreCaller: StoreCaller{contract: contract}, StoreTransactor: StoreTransactor{contract: contract}, StoreFilterer: StoreFilterer{contract: contract}}, nil
+}
+
+// NewStoreCaller creates a new read-only instance of Store, bound to a specific deployed contract.
+func NewStoreCaller(address common.Address, caller bind.ContractCaller) (*StoreCaller, error) {
+	contract, err := bindStore(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &StoreCaller{contract: contract}, nil
+}
+
+// NewStoreTransactor creates a new write-only instance of Store, bound to a specific deployed contract.
+func NewStoreTransactor(address common.Address, transactor bind.ContractTransactor) (*StoreTransactor, error) {
+	contract, err := bindStore(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &StoreTransactor{contract: contract}, nil
+}
+
+// NewStoreFilterer creates a new log filterer instance of Store, bound to a specific deployed contract.
+func NewStoreFilterer(address common.Address, filterer bind.ContractFilterer) (*StoreFilterer, error) {
+	contract, err := bindStore(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &StoreFilterer{contract: contract}, nil
+}
+
+// bindStore binds a generic wrapper to an already deployed contract.
+func bindStore(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := StoreMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, *parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Store *StoreRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _Store.Contract.StoreCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Store *StoreRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Store.Contract.StoreTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Store *StoreRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Store.Contract.StoreTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Store *StoreCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _Store.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Store *StoreTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Store.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Store *StoreTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Store.Contract.contract.Transact(opts, method, params...)
+}
+
+// BalanceOf is a free data retrieval call binding the contract method 0x70a08231.
+//
+// Solidity: function balanceOf(address owner) view returns(uint256)
+func (_Store *StoreCaller) BalanceOf(opts *bind.CallOpts, owner common.Address) (*big.Int, error) {
+	var out []interface{}
+	err := _Store.contract.Call(opts, &out, "balanceOf", owner)
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// BalanceOf is a free data retrieval call binding the contract method 0x70a08231.
+//
+// Solidity: function balanceOf(address owner) view returns(uint256)
+func (_Store *StoreSession) BalanceOf(owner common.Address) (*big.Int, error) {
+	return _Store.Contract.BalanceOf(&_Store.CallOpts, owner)
+}
+
+// BalanceOf is a free data retrieval call binding the contract method 0x70a08231.
+//
+// Solidity: function balanceOf(address owner) view returns(uint256)
+func (_Store *StoreCallerSession) BalanceOf(owner common.Address) (*big.Int, error) {
+	return _Store.Contract.BalanceOf(&_Store.CallOpts, owner)
+}
+
+// Get is a free data retrieval call binding the contract method 0x6d4ce63c.
+//
+// Solidity: function get() view returns(uint256)
+func (_Store *StoreCaller) Get(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	err := _Store.contract.Call(opts, &out, "get")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// Get is a free data retrieval call binding the contract method 0x6d4ce63c.
+//
+// Solidity: function get() view returns(uint256)
+func (_Store *StoreSession) Get() (*big.Int, error) {
+	return _Store.Contract.Get(&_Store.CallOpts)
+}
+
+// Get is a free data retrieval call binding the contract method 0x6d4ce63c.
+//
+// Solidity: function get() view returns(uint256)
+func (_Store *StoreCallerSession) Get() (*big.Int, error) {
+	return _Store.Contract.Get(&_Store.CallOpts)
+}
+
+// Pair is a free data retrieval call binding the contract method 0xa8aa1b31.
+//
+// Solidity: function pair() view returns(uint256 a, bool b)
+func (_Store *StoreCaller) Pair(opts *bind.CallOpts) (struct {
+	A *big.Int
+	B bool
+}, error) {
+	var out []interface{}
+	err := _Store.contract.Call(opts, &out, "pair")
+
+	outstruct := new(struct {
+		A *big.Int
+		B bool
+	})
+	if err != nil {
+		return *outstruct, err
+	}
+
+	outstruct.A = *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+	outstruct.B = *abi.ConvertType(out[1], new(bool)).(*bool)
+
+	return *outstruct, err
+
+}
+
+// Pair is a free data retrieval call binding the contract method 0xa8aa1b31.
+//
+// Solidity: function pair() view returns(uint256 a, bool b)
+func (_Store *StoreSession) Pair() (struct {
+	A *big.Int
+	B bool
+}, error) {
+	return _Store.Contract.Pair(&_Store.CallOpts)
+}
+
+// Pair is a free data retrieval call binding the contract method 0xa8aa1b31.
+//
+// Solidity: function pair() view returns(uint256 a, bool b)
+func (_Store *StoreCallerSession) Pair() (struct {
+	A *big.Int
+	B bool
+}, error) {
+	return _Store.Contract.Pair(&_Store.CallOpts)
+}
+
+// Set is a paid mutator transaction binding the contract method 0x60fe47b1.
+//
+// Solidity: function set(uint256 value) returns()
+func (_Store *StoreTransactor) Set(opts *bind.TransactOpts, value *big.Int) (*types.Transaction, error) {
+	return _Store.contract.Transact(opts, "set", value)
+}
+
+// Set is a paid mutator transaction binding the contract method 0x60fe47b1.
+//
+// Solidity: function set(uint256 value) returns()
+func (_Store *StoreSession) Set(value *big.Int) (*types.Transaction, error) {
+	return _Store.Contract.Set(&_Store.TransactOpts, value)
+}
+
+// Set is a paid mutator transaction binding the contract method 0x60fe47b1.
+//
+// Solidity: function set(uint256 value) returns()
+func (_Store *StoreTransactorSession) Set(value *big.Int) (*types.Transaction, error) {
+	return _Store.Contract.Set(&_Store.TransactOpts, value)
+}
+
+// StoreSetIterator is returned from FilterSet and is used to iterate over the raw logs and unpacked data for Set events raised by the Store contract.
+type StoreSetIterator struct {
+	Event *StoreSet // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *StoreSetIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(StoreSet)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(StoreSet)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *StoreSetIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *StoreSetIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// StoreSet represents a Set event raised by the Store contract.
+type StoreSet struct {
+	Value *big.Int
+	Raw   types.Log // Blockchain specific contextual infos
+}
+
+// FilterSet is a free log retrieval operation binding the contract event 0xdf7a95aebff315db1b7716215d602ab537373cdb769232aae6055c06e798425b.
+//
+// Solidity: event Set(uint256 value)
+func (_Store *StoreFilterer) FilterSet(opts *bind.FilterOpts) (*StoreSetIterator, error) {
+
+	logs, sub, err := _Store.contract.FilterLogs(opts, "Set")
+	if err != nil {
+		return nil, err
+	}
+	return &StoreSetIterator{contract: _Store.contract, event: "Set", logs: logs, sub: sub}, nil
+}
+
+// WatchSet is a free log subscription operation binding the contract event 0xdf7a95aebff315db1b7716215d602ab537373cdb769232aae6055c06e798425b.
+//
+// Solidity: event Set(uint256 value)
+func (_Store *StoreFilterer) WatchSet(opts *bind.WatchOpts, sink chan<- *StoreSet) (event.Subscription, error) {
+
+	logs, sub, err := _Store.contract.WatchLogs(opts, "Set")
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(StoreSet)
+				if err := _Store.contract.UnpackLog(event, "Set", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseSet is a log parse operation binding the contract event 0xdf7a95aebff315db1b7716215d602ab537373cdb769232aae6055c06e798425b.
+//
+// Solidity: event Set(uint256 value)
+func (_Store *StoreFilterer) ParseSet(log types.Log) (*StoreSet, error) {
+	event := new(StoreSet)
+	if err := _Store.contract.UnpackLog(event, "Set", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
@@ -0,0 +1,303 @@
+package sapphire
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+const (
+	DefaultGasPrice   = 100_000_000_000
+	DefaultGasLimit   = 30_000_000
+	DefaultBlockRange = 15
+)
+
+// CallOption configures a query signed by NewSignedCall. Later options take
+// precedence over earlier ones.
+type CallOption func(*signedCallBuilder)
+
+type signedCallBuilder struct {
+	cfg      signedCallConfig
+	caller   *common.Address
+	gasLimit uint64
+	gasPrice *big.Int
+	value    *big.Int
+	leash    *evm.Leash
+	// autoLeash builds the leash if leash is nil.
+	autoLeash func(ctx context.Context, caller common.Address) (*evm.Leash, error)
+}
+
+// WithCaller signs the query for caller, which the signer must hold the key
+// of. It is required unless the signer is a SignerWithAddress.
+func WithCaller(caller common.Address) CallOption {
+	return func(b *signedCallBuilder) {
+		b.caller = &caller
+	}
+}
+
+// WithGasLimit sets the query's gas limit, DefaultGasLimit by default.
+func WithGasLimit(gasLimit uint64) CallOption {
+	return func(b *signedCallBuilder) {
+		b.gasLimit = gasLimit
+	}
+}
+
+// WithGasPrice sets the query's gas price, DefaultGasPrice by default.
+func WithGasPrice(gasPrice *big.Int) CallOption {
+	return func(b *signedCallBuilder) {
+		b.gasPrice = gasPrice
+	}
+}
+
+// WithValue sets the value sent with the query, none by default.
+func WithValue(value *big.Int) CallOption {
+	return func(b *signedCallBuilder) {
+		b.value = value
+	}
+}
+
+// WithLeash binds the query to leash.
+func WithLeash(leash evm.Leash) CallOption {
+	return func(b *signedCallBuilder) {
+		b.leash = &leash
+		b.autoLeash = nil
+	}
+}
+
+// WithCallDomain signs the query in domain instead of DefaultSignedCallDomain.
+func WithCallDomain(domain SignedCallDomain) CallOption {
+	return func(b *signedCallBuilder) {
+		b.cfg.domain = domain
+	}
+}
+
+// WithLeashFunc builds the query's leash with leash for the caller when it
+// is signed, e.g. from the caller's nonce and the latest block.
+func WithLeashFunc(leash func(ctx context.Context, caller common.Address) (*evm.Leash, error)) CallOption {
+	return func(b *signedCallBuilder) {
+		b.leash = nil
+		b.autoLeash = leash
+	}
+}
+
+// WithMaxCallDataSize rejects queries with more than n bytes of calldata
+// instead of DefaultMaxSignedCallDataSize, which a zero n keeps.
+func WithMaxCallDataSize(n int) CallOption {
+	return func(b *signedCallBuilder) {
+		b.cfg.maxData = n
+	}
+}
+
+// WithoutValidation signs the query without checking it against what the
+// runtime accepts, for testing how the runtime handles malformed queries.
+func WithoutValidation() CallOption {
+	return func(b *signedCallBuilder) {
+		b.cfg.unchecked = true
+	}
+}
+
+// NewSignedCall signs a query of to with data for the chain chainID, which
+// must be positive. A nil to is a deployment. A leash must be given with WithLeash or WithLeashFunc,
+// e.g. client.WithAutoLeash.
+//
+// The returned pack is not encrypted yet, see PackSignedCall for sending
+// queries.
+func NewSignedCall(ctx context.Context, signer Signer, chainID *big.Int, to *common.Address, data []byte, opts ...CallOption) (*evm.SignedCallDataPack, error) {
+	b := signedCallBuilder{
+		cfg:      signedCallConfig{domain: DefaultSignedCallDomain},
+		gasLimit: DefaultGasLimit,
+		gasPrice: big.NewInt(DefaultGasPrice),
+	}
+	for _, opt := range opts {
+		opt(&b)
+	}
+
+	if b.caller == nil {
+		s, ok := signer.(SignerWithAddress)
+		if !ok {
+			return nil, fmt.Errorf("%w: no caller given", ErrNoSigner)
+		}
+		addr := s.Address()
+		b.caller = &addr
+	}
+	if err := b.cfg.validate(chainID, b.gasLimit, b.gasPrice, b.value, data); err != nil {
+		return nil, err
+	}
+	if b.leash == nil {
+		if b.autoLeash == nil {
+			return nil, fmt.Errorf("%w: no leash given", ErrInvalidSignedCall)
+		}
+		leash, err := b.autoLeash(ctx, *b.caller)
+		if err != nil {
+			return nil, err
+		}
+		b.leash = leash
+	}
+
+	digest, err := SignedCallDigest(b.cfg.domain.signableCall(chainID, *b.caller, to, b.gasLimit, b.gasPrice, b.value, data, *b.leash))
+	if err != nil {
+		return nil, err
+	}
+	signature, err := SignDigest(ctx, signer, digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign call: %w", err)
+	}
+	return SignedCallWithSignature(data, *b.leash, signature)
+}
+
+// SignedCallWithSignature assembles a signed query of data from a signature
+// made elsewhere, e.g. by a wallet, over the SignedCallDigest of the query's
+// SignableCall with the same leash. The signature is normalized with
+// NormalizeV.
+//
+// The returned pack is not encrypted yet, like those of NewSignedCall.
+func SignedCallWithSignature(data []byte, leash evm.Leash, signature []byte) (*evm.SignedCallDataPack, error) {
+	signature, err := NormalizeV(signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign call: %w", err)
+	}
+	return &evm.SignedCallDataPack{
+		Data:      sdkTypes.Call{Body: cbor.Marshal(data)},
+		Leash:     leash,
+		Signature: signature,
+	}, nil
+}
+
+// NormalizeV returns a copy of the 65-byte (R || S || V) signature with the
+// recovery ID V as 27 or 28, which the runtime verifies. Wallets and signers
+// return either that or 0 and 1; other values of V are rejected.
+func NormalizeV(signature []byte) ([]byte, error) {
+	if len(signature) != 65 {
+		return nil, fmt.Errorf("%w: invalid signature length %d", ErrSignatureRejected, len(signature))
+	}
+	normalized := append([]byte(nil), signature...)
+	switch v := normalized[64]; v {
+	case 0, 1:
+		normalized[64] += 27
+	case 27, 28:
+	default:
+		return nil, fmt.Errorf("%w: invalid signature recovery ID %d", ErrSignatureRejected, v)
+	}
+	return normalized, nil
+}
+
+// RecoverCaller returns the account that made signature over the digest of
+// the typed data of a signed query, as returned by SignableCall. The runtime
+// executes the query as from that account if it is the query's from address.
+func RecoverCaller(typedData apitypes.TypedData, signature []byte) (common.Address, error) {
+	signature, err := NormalizeV(signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	digest, err := SignedCallDigest(typedData)
+	if err != nil {
+		return common.Address{}, err
+	}
+	signature[64] -= 27
+	pub, err := crypto.SigToPub(digest[:], signature)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to recover signer: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// SignedCallHash identifies pack, e.g. to deduplicate retried submissions of
+// a query. It is the Keccak-256 hash of the pack's canonical CBOR encoding,
+// which is what is sent to the gateway, so it stays the same across versions
+// of this package and when the pack is decoded and encoded again.
+func SignedCallHash(pack *evm.SignedCallDataPack) common.Hash {
+	return crypto.Keccak256Hash(EncodeDataPack(pack))
+}
+
+// SignedCallsEqual reports whether a and b are the same signed query, i.e.
+// have the same SignedCallHash. Two nil packs are equal.
+func SignedCallsEqual(a, b *evm.SignedCallDataPack) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return SignedCallHash(a) == SignedCallHash(b)
+}
+
+// EncodeDataPack returns the canonical CBOR encoding of pack, which is the
+// calldata of the query sent to the gateway. DecodeDataPack reverses it.
+func EncodeDataPack(pack *evm.SignedCallDataPack) []byte {
+	return cbor.Marshal(pack)
+}
+
+// DecodeDataPack parses a signed query from its EncodeDataPack encoding, e.g.
+// one received by a relayer. The pack's data may be plain or encrypted; it is
+// not decrypted. Malformed packs are rejected with ErrMalformedEnvelope:
+// besides invalid CBOR and trailing data, these are packs missing fields,
+// with a signature or leash block hash of the wrong length, and packs not in
+// the canonical encoding, which would not hash to their SignedCallHash.
+func DecodeDataPack(data []byte) (*evm.SignedCallDataPack, error) {
+	pack, err := (DecodeLimits{}).DecodeSignedCall(data)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := scanCBOR(data, DefaultDecodeLimits.MaxNestingDepth); err != nil || n != len(data) {
+		return nil, fmt.Errorf("%w: trailing data after signed query", ErrMalformedEnvelope)
+	}
+	if len(pack.Signature) != 65 {
+		return nil, fmt.Errorf("%w: invalid signature length %d", ErrMalformedEnvelope, len(pack.Signature))
+	}
+	if v := pack.Signature[64]; v != 27 && v != 28 {
+		return nil, fmt.Errorf("%w: invalid signature recovery ID %d", ErrMalformedEnvelope, v)
+	}
+	if len(pack.Leash.BlockHash) != common.HashLength {
+		return nil, fmt.Errorf("%w: invalid leash block hash length %d", ErrMalformedEnvelope, len(pack.Leash.BlockHash))
+	}
+	// A missing body is encoded as CBOR null.
+	if len(pack.Data.Body) == 0 || bytes.Equal(pack.Data.Body, []byte{0xf6}) {
+		return nil, fmt.Errorf("%w: missing call body", ErrMalformedEnvelope)
+	}
+	if pack.Data.Method != "" || pack.Data.ReadOnly {
+		return nil, fmt.Errorf("%w: unexpected call method or flags", ErrMalformedEnvelope)
+	}
+
+	var body interface{}
+	switch pack.Data.Format {
+	case sdkTypes.CallFormatPlain:
+		body = new([]byte)
+	case sdkTypes.CallFormatEncryptedX25519DeoxysII:
+		body = new(sdkTypes.CallEnvelopeX25519DeoxysII)
+	default:
+		return nil, fmt.Errorf("%w: unsupported call format %d", ErrMalformedEnvelope, pack.Data.Format)
+	}
+	if err = (DecodeLimits{}).unmarshal(pack.Data.Body, body); err != nil {
+		return nil, fmt.Errorf("%w: call body: %w", ErrMalformedEnvelope, err)
+	}
+	// Re-encoding catches what decoding tolerates: non-canonical integers and
+	// map orders, and byte strings of the wrong length for the fixed-size
+	// public key and nonce of encrypted bodies.
+	if !bytes.Equal(cbor.Marshal(body), pack.Data.Body) {
+		return nil, fmt.Errorf("%w: call body not canonically encoded", ErrMalformedEnvelope)
+	}
+	if !bytes.Equal(EncodeDataPack(pack), data) {
+		return nil, fmt.Errorf("%w: signed query not canonically encoded", ErrMalformedEnvelope)
+	}
+	return pack, nil
+}
+
+// DecodeSignedCall decodes a CBOR-encoded signed query within the limits,
+// like DecodeDataPack but without requiring the canonical encoding, e.g. to
+// inspect queries made by other clients. Packs without a signature are
+// rejected, as they are call envelopes.
+func (l DecodeLimits) DecodeSignedCall(data []byte) (*evm.SignedCallDataPack, error) {
+	var pack evm.SignedCallDataPack
+	if err := l.unmarshal(data, &pack); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedEnvelope, err)
+	}
+	if len(pack.Signature) == 0 {
+		return nil, fmt.Errorf("%w: not a signed query", ErrMalformedEnvelope)
+	}
+	return &pack, nil
+}
@@ -0,0 +1,257 @@
+package sapphire
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+func TestSignedCallHash(t *testing.T) {
+	ctx := context.Background()
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	leash := evm.Leash{Nonce: 3, BlockNumber: 50, BlockHash: common.HexToHash("0x01").Bytes(), BlockRange: DefaultBlockRange}
+
+	pack, err := NewSignedCall(ctx, signer, big.NewInt(0x5afd), &to, []byte{1, 2, 3}, WithLeash(leash))
+	if err != nil {
+		t.Fatalf("NewSignedCall failed: %v", err)
+	}
+	// Pinned, so that a change of the encoding is noticed.
+	if h := SignedCallHash(pack); h != common.HexToHash("0x8960a34033404f413caddb2b6be9596d12dd45953b627a1e96d44cebb60cbd15") {
+		t.Fatalf("hash %x changed", h)
+	}
+
+	var decoded evm.SignedCallDataPack
+	if err = cbor.Unmarshal(cbor.Marshal(pack), &decoded); err != nil {
+		t.Fatalf("failed to decode pack: %v", err)
+	}
+	if SignedCallHash(&decoded) != SignedCallHash(pack) || !SignedCallsEqual(&decoded, pack) {
+		t.Fatalf("hash changed by a round trip")
+	}
+
+	// Signatures are deterministic, so only a different query differs.
+	again, _ := NewSignedCall(ctx, signer, big.NewInt(0x5afd), &to, []byte{1, 2, 3}, WithLeash(leash))
+	other, _ := NewSignedCall(ctx, signer, big.NewInt(0x5afd), &to, []byte{1, 2, 4}, WithLeash(leash))
+	if !SignedCallsEqual(pack, again) || SignedCallsEqual(pack, other) {
+		t.Fatalf("equality does not follow the query")
+	}
+	if !SignedCallsEqual(nil, nil) || SignedCallsEqual(pack, nil) {
+		t.Fatalf("unexpected equality of nil packs")
+	}
+}
+
+func TestDecodeDataPack(t *testing.T) {
+	ctx := context.Background()
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	leash := evm.Leash{Nonce: 3, BlockNumber: 50, BlockHash: common.HexToHash("0x01").Bytes(), BlockRange: DefaultBlockRange}
+	pack, err := NewSignedCall(ctx, NewPrivateKeySigner(key), big.NewInt(0x5afd), &to, []byte{1, 2, 3}, WithLeash(leash))
+	if err != nil {
+		t.Fatalf("NewSignedCall failed: %v", err)
+	}
+	pair := Curve25519KeyPair{}
+	cipher, err := NewX25519DeoxysIICipher(&pair, &pair.PublicKey, 1)
+	if err != nil {
+		t.Fatalf("could not init deoxysii cipher: %v", err)
+	}
+	encrypted := *pack
+	encrypted.Data = *cipher.EncryptEnvelope([]byte{1, 2, 3})
+
+	for _, p := range []*evm.SignedCallDataPack{pack, &encrypted} {
+		encoded := EncodeDataPack(p)
+		decoded, err := DecodeDataPack(encoded)
+		if err != nil {
+			t.Fatalf("failed to decode pack: %v", err)
+		}
+		if !reflect.DeepEqual(decoded, p) || !bytes.Equal(EncodeDataPack(decoded), encoded) {
+			t.Fatalf("pack %+v decoded to %+v", p, decoded)
+		}
+	}
+
+	// modified returns the encoding of a copy of pack changed by modify.
+	modified := func(modify func(p *evm.SignedCallDataPack)) []byte {
+		p := *pack
+		p.Signature = common.CopyBytes(pack.Signature)
+		modify(&p)
+		return EncodeDataPack(&p)
+	}
+	encryptedBody := func(nonceSize int) []byte {
+		return cbor.Marshal(map[string]interface{}{"pk": make([]byte, 32), "nonce": make([]byte, nonceSize), "epoch": 1, "data": []byte{1}})
+	}
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"invalid CBOR", []byte{0xff}},
+		{"trailing data", append(EncodeDataPack(pack), 0)},
+		{"call envelope", cbor.Marshal(cipher.EncryptEnvelope([]byte{1}))},
+		{"short signature", modified(func(p *evm.SignedCallDataPack) { p.Signature = p.Signature[:64] })},
+		{"unnormalized signature", modified(func(p *evm.SignedCallDataPack) { p.Signature[64] -= 27 })},
+		{"short block hash", modified(func(p *evm.SignedCallDataPack) { p.Leash.BlockHash = p.Leash.BlockHash[1:] })},
+		{"missing body", modified(func(p *evm.SignedCallDataPack) { p.Data.Body = nil })},
+		{"method", modified(func(p *evm.SignedCallDataPack) { p.Data.Method = "evm.Call" })},
+		{"unknown format", modified(func(p *evm.SignedCallDataPack) { p.Data.Format = 9 })},
+		{"plain body not bytes", modified(func(p *evm.SignedCallDataPack) { p.Data.Body = cbor.Marshal(uint64(1)) })},
+		{"encrypted body not an envelope", modified(func(p *evm.SignedCallDataPack) {
+			p.Data.Format, p.Data.Body = sdkTypes.CallFormatEncryptedX25519DeoxysII, cbor.Marshal([]byte{1})
+		})},
+		{"short nonce", modified(func(p *evm.SignedCallDataPack) {
+			p.Data.Format, p.Data.Body = sdkTypes.CallFormatEncryptedX25519DeoxysII, encryptedBody(14)
+		})},
+		{"long nonce", modified(func(p *evm.SignedCallDataPack) {
+			p.Data.Format, p.Data.Body = sdkTypes.CallFormatEncryptedX25519DeoxysII, encryptedBody(16)
+		})},
+		{"unknown field", cbor.Marshal(struct {
+			Data      sdkTypes.Call `json:"data"`
+			Leash     evm.Leash     `json:"leash"`
+			Signature []byte        `json:"signature"`
+			Extra     uint64        `json:"extra"`
+		}{pack.Data, pack.Leash, pack.Signature, 1})},
+	} {
+		if _, err := DecodeDataPack(tc.data); !errors.Is(err, ErrMalformedEnvelope) {
+			t.Fatalf("%s: expected ErrMalformedEnvelope, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestNormalizeV(t *testing.T) {
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	for i := 0; i < 32; i++ {
+		key, _ := crypto.GenerateKey()
+		from := crypto.PubkeyToAddress(key.PublicKey)
+		leash := evm.Leash{Nonce: uint64(i), BlockNumber: uint64(i), BlockHash: crypto.Keccak256([]byte{byte(i)}), BlockRange: DefaultBlockRange}
+		typedData := SignableCall(0x5afd, from, &to, DefaultGasLimit, big.NewInt(DefaultGasPrice), nil, []byte{byte(i)}, leash)
+		digest, err := SignedCallDigest(typedData)
+		if err != nil {
+			t.Fatalf("failed to hash query: %v", err)
+		}
+		low, err := crypto.Sign(digest[:], key)
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		high := append([]byte(nil), low...)
+		high[64] += 27
+
+		for _, sig := range [][]byte{low, high} {
+			caller, err := RecoverCaller(typedData, sig)
+			if err != nil || caller != from {
+				t.Fatalf("signature with V=%d recovered to %s: %v", sig[64], caller.Hex(), err)
+			}
+			normalized, err := NormalizeV(sig)
+			if err != nil || !bytes.Equal(normalized, high) {
+				t.Fatalf("V=%d normalized to %x: %v", sig[64], normalized, err)
+			}
+			pack, err := SignedCallWithSignature([]byte{byte(i)}, leash, sig)
+			if err != nil || !bytes.Equal(pack.Signature, high) {
+				t.Fatalf("pack from a signature with V=%d not normalized: %v", sig[64], err)
+			}
+		}
+		if low[64] > 1 {
+			t.Fatalf("crypto.Sign returned V=%d", low[64])
+		}
+	}
+
+	sig := make([]byte, 65)
+	for _, v := range []byte{2, 26, 29, 37} {
+		sig[64] = v
+		if _, err := NormalizeV(sig); err == nil {
+			t.Fatalf("expected V=%d to be rejected", v)
+		}
+	}
+	if _, err := NormalizeV(sig[:64]); err == nil {
+		t.Fatalf("expected a short signature to be rejected")
+	}
+}
+
+// FuzzDecodeDataPack fuzzes the strict decoding of signed queries: whatever
+// decodes encodes back to the same bytes.
+func FuzzDecodeDataPack(f *testing.F) {
+	pair := Curve25519KeyPair{}
+	cipher, err := NewX25519DeoxysIICipher(&pair, &pair.PublicKey, 1)
+	if err != nil {
+		f.Fatalf("could not init deoxysii cipher: %v", err)
+	}
+	signature := append(bytes.Repeat([]byte{1}, 64), 27)
+	for _, v := range loadSignedQueryVectors(f) {
+		pack, err := SignedCallWithSignature(v.Data, v.leash(), signature)
+		if err != nil {
+			f.Fatalf("%s: failed to assemble pack: %v", v.Name, err)
+		}
+		f.Add(EncodeDataPack(pack))
+		if envelope := cipher.EncryptEnvelope(v.Data); envelope != nil {
+			pack.Data = *envelope
+			f.Add(EncodeDataPack(pack))
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) > fuzzLimits.MaxEnvelopeSize {
+			return
+		}
+		pack, err := DecodeDataPack(data)
+		if err != nil {
+			if !errors.Is(err, ErrMalformedEnvelope) {
+				t.Fatalf("expected ErrMalformedEnvelope, got %v", err)
+			}
+			return
+		}
+		if encoded := EncodeDataPack(pack); !bytes.Equal(encoded, data) {
+			t.Fatalf("pack %+v encoded to %x, expected %x", pack, encoded, data)
+		}
+		if again, err := DecodeDataPack(data); err != nil || !reflect.DeepEqual(again, pack) {
+			t.Fatalf("expected %+v to decode again, got %+v, %v", pack, again, err)
+		}
+	})
+}
+
+// FuzzSignedCallDataPack fuzzes CBOR-encoded signed queries.
+func FuzzSignedCallDataPack(f *testing.F) {
+	pair := Curve25519KeyPair{}
+	cipher, err := NewX25519DeoxysIICipher(&pair, &pair.PublicKey, 1)
+	if err != nil {
+		f.Fatalf("could not init deoxysii cipher: %v", err)
+	}
+	signature := append(bytes.Repeat([]byte{1}, 64), 27)
+	for _, v := range loadSignedQueryVectors(f) {
+		pack, err := SignedCallWithSignature(v.Data, v.leash(), signature)
+		if err != nil {
+			f.Fatalf("%s: failed to assemble pack: %v", v.Name, err)
+		}
+		f.Add(cbor.Marshal(pack))
+		if envelope := cipher.EncryptEnvelope(v.Data); envelope != nil {
+			pack.Data = *envelope
+			f.Add(cbor.Marshal(pack))
+		}
+	}
+	f.Add(cbor.Marshal(cipher.EncryptEnvelope(TestData)))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) > fuzzLimits.MaxEnvelopeSize {
+			return
+		}
+		pack, err := fuzzLimits.DecodeSignedCall(data)
+		if err != nil {
+			if !errors.Is(err, ErrMalformedEnvelope) {
+				t.Fatalf("expected ErrMalformedEnvelope, got %v", err)
+			}
+			return
+		}
+		// Packs decode the same once encoded again.
+		encoded := cbor.Marshal(pack)
+		again, err := fuzzLimits.DecodeSignedCall(encoded)
+		if err != nil || !SignedCallsEqual(pack, again) {
+			t.Fatalf("expected %+v to decode again, got %+v, %v", pack, again, err)
+		}
+	})
+}
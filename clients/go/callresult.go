@@ -0,0 +1,86 @@
+package sapphire
+
+import (
+	"errors"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+// CallResultVariant is the variant of a runtime call result.
+type CallResultVariant string
+
+const (
+	// CallResultUndecodable is the variant of responses that are not call
+	// results, or that could not be decrypted.
+	CallResultUndecodable CallResultVariant = ""
+	CallResultOk          CallResultVariant = "ok"
+	CallResultFailed      CallResultVariant = "failed"
+	CallResultUnknown     CallResultVariant = "unknown"
+)
+
+// CipherInfo describes the cipher a call result was decrypted with. It
+// carries no key material.
+type CipherInfo struct {
+	// Format is the call format of the cipher.
+	Format types.CallFormat
+	// Epoch is the epoch of the runtime key the cipher encrypts to, zero for
+	// plain calls.
+	Epoch uint64
+}
+
+// cipherInfo describes c.
+func cipherInfo(c Cipher) CipherInfo {
+	info := CipherInfo{Format: c.CallFormat()}
+	switch c := c.(type) {
+	case X25519DeoxysIICipher:
+		info.Epoch = c.epoch
+	case *X25519DeoxysIICipher:
+		info.Epoch = c.epoch
+	}
+	return info
+}
+
+// CallResultError is returned for call results that report a failure or
+// cannot be decoded. It keeps the response as received next to how it was
+// interpreted, so that the two can be compared.
+type CallResultError struct {
+	// RawEnvelope is the CBOR-encoded call result as returned by the gateway.
+	RawEnvelope []byte
+	// Variant is the variant of the call result.
+	Variant CallResultVariant
+	// InnerVariant is the variant of the decrypted result of encrypted
+	// calls, CallResultUndecodable if there is none.
+	InnerVariant CallResultVariant
+	// ModuleError is the failure the runtime reported, nil if the result
+	// could not be decoded.
+	ModuleError *ModuleError
+	// RevertError is set if the failure is a revert.
+	RevertError *RevertError
+	// Cipher is the cipher the result was decrypted with.
+	Cipher CipherInfo
+	// Err is ModuleError, or an error matching ErrMalformedEnvelope.
+	Err error
+}
+
+func (e *CallResultError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CallResultError) Unwrap() error {
+	return e.Err
+}
+
+// callResultError returns a CallResultError for the result response that c
+// failed to decrypt with err.
+func callResultError(c Cipher, response []byte, variant, inner CallResultVariant, err error) *CallResultError {
+	e := &CallResultError{
+		RawEnvelope:  response,
+		Variant:      variant,
+		InnerVariant: inner,
+		Cipher:       cipherInfo(c),
+		Err:          err,
+	}
+	errors.As(err, &e.ModuleError)
+	errors.As(err, &e.RevertError)
+	return e
+}
@@ -0,0 +1,92 @@
+package sapphire
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CBOR nesting depths DecodeLimits.MaxNestingDepth may be set to.
+const (
+	minNestingDepth = 4
+	maxNestingDepth = 256
+)
+
+var (
+	// errCBORDepth is returned by scanCBOR for items nested too deeply.
+	errCBORDepth = errors.New("cbor: exceeded max nesting depth")
+	// errCBORMalformed is returned by scanCBOR for items that are not
+	// well-formed, or use indefinite lengths or tags, which Sapphire's
+	// encodings never do.
+	errCBORMalformed = errors.New("cbor: malformed item")
+)
+
+// scanCBOR checks that data starts with a well-formed CBOR item nested at
+// most maxDepth arrays and maps deep, without decoding it, and returns its
+// length. Length prefixes are checked against the remaining data before
+// anything is done with them, so hostile prefixes can't cause allocations.
+func scanCBOR(data []byte, maxDepth int) (int, error) {
+	return scanCBORItem(data, 0, 0, maxDepth)
+}
+
+func scanCBORItem(data []byte, off, depth, maxDepth int) (int, error) {
+	if off >= len(data) {
+		return 0, fmt.Errorf("%w: unexpected end of data", errCBORMalformed)
+	}
+	major, info := data[off]>>5, data[off]&0x1f
+	off++
+
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(data)-off < size {
+			return 0, fmt.Errorf("%w: unexpected end of data", errCBORMalformed)
+		}
+		for _, b := range data[off : off+size] {
+			arg = arg<<8 | uint64(b)
+		}
+		off += size
+	case info == 31:
+		return 0, fmt.Errorf("%w: indefinite length", errCBORMalformed)
+	default:
+		return 0, fmt.Errorf("%w: reserved additional information %d", errCBORMalformed, info)
+	}
+	remaining := uint64(len(data) - off)
+
+	switch major {
+	case 0, 1, 7:
+		// Integers, simple values and floats are just their header.
+		return off, nil
+	case 2, 3:
+		if arg > remaining {
+			return 0, fmt.Errorf("%w: %d byte string exceeds the data", errCBORMalformed, arg)
+		}
+		return off + int(arg), nil
+	case 4, 5:
+		if depth++; depth > maxDepth {
+			return 0, fmt.Errorf("%w of %d", errCBORDepth, maxDepth)
+		}
+		items := arg
+		if major == 5 {
+			if items > remaining {
+				return 0, fmt.Errorf("%w: %d entry map exceeds the data", errCBORMalformed, arg)
+			}
+			items *= 2
+		}
+		// Every item takes at least a byte.
+		if items > remaining {
+			return 0, fmt.Errorf("%w: %d item array exceeds the data", errCBORMalformed, arg)
+		}
+		for i := uint64(0); i < items; i++ {
+			var err error
+			if off, err = scanCBORItem(data, off, depth, maxDepth); err != nil {
+				return 0, err
+			}
+		}
+		return off, nil
+	default:
+		return 0, fmt.Errorf("%w: tags are not supported", errCBORMalformed)
+	}
+}
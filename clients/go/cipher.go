@@ -6,25 +6,57 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/ethclient"
 
 	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
 	"github.com/oasisprotocol/deoxysii"
 	"github.com/oasisprotocol/oasis-core/go/common/cbor"
 	mraeApi "github.com/oasisprotocol/oasis-core/go/common/crypto/mrae/api"
-	mrae "github.com/oasisprotocol/oasis-core/go/common/crypto/mrae/deoxysii"
 	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles/local"
 )
 
 type Kind uint64
 
 var (
 	ErrCallFailed       = errors.New("call failed in module")
-	ErrCallResultDecode = errors.New("could not decode call result")
+	ErrCallResultDecode = fmt.Errorf("%w: could not decode call result", ErrMalformedEnvelope)
 )
 
+// CallFailedError is returned when the runtime reports that a call failed.
+// It matches ErrCallFailed with errors.Is.
+type CallFailedError struct {
+	Module  string
+	Code    uint32
+	Message string
+}
+
+func newCallFailedError(failed *types.FailedCallResult) *CallFailedError {
+	return &CallFailedError{
+		Module:  failed.Module,
+		Code:    failed.Code,
+		Message: failed.Message,
+	}
+}
+
+func (e *CallFailedError) Error() string {
+	if len(e.Message) == 0 {
+		if description := e.Description(); description != "" {
+			return fmt.Sprintf("call failed in module %s with code %d: %s", e.Module, e.Code, description)
+		}
+		return fmt.Sprintf("call failed in module %s with code %d", e.Module, e.Code)
+	}
+	return e.Message
+}
+
+func (e *CallFailedError) Is(target error) bool {
+	return target == ErrCallFailed
+}
+
 type Cipher interface {
 	CallFormat() types.CallFormat
 	Encrypt(plaintext []byte) (ciphertext []byte, nonce []byte)
@@ -35,7 +67,9 @@ type Cipher interface {
 	DecryptCallResult(result []byte) ([]byte, error)
 }
 
-type PlainCipher struct{}
+type PlainCipher struct {
+	limits DecodeLimits
+}
 
 // NewPlainCipher creates a cipher instance without encryption support.
 func NewPlainCipher() PlainCipher {
@@ -56,33 +90,40 @@ func (c PlainCipher) Decrypt(_ []byte, ciphertext []byte) (plaintext []byte, err
 }
 
 func (c PlainCipher) DecryptCallResult(response []byte) ([]byte, error) {
+	res, variant, err := c.decodeCallResult(response)
+	if err != nil {
+		return nil, callResultError(c, response, variant, CallResultUndecodable, err)
+	}
+	return res, nil
+}
+
+func (c PlainCipher) decodeCallResult(response []byte) ([]byte, CallResultVariant, error) {
 	var callResult types.CallResult
-	if err := cbor.Unmarshal(response, &callResult); err != nil {
-		return nil, err
+	if err := c.limits.unmarshal(response, &callResult); err != nil {
+		return nil, CallResultUndecodable, fmt.Errorf("%w: %w", ErrMalformedEnvelope, err)
 	}
 
-	// TODO: actually decode and return failure
 	if callResult.Failed != nil {
-		return nil, ErrCallFailed
+		return nil, CallResultFailed, newCallFailedError(callResult.Failed)
 	}
 
 	if callResult.Unknown != nil {
 		var unknown []byte
-		if err := cbor.Unmarshal(callResult.Unknown, &unknown); err != nil {
-			return nil, fmt.Errorf("failed decoding callResult.Unknown: %w", err)
+		if err := c.limits.unmarshal(callResult.Unknown, &unknown); err != nil {
+			return nil, CallResultUnknown, fmt.Errorf("%w: decoding callResult.Unknown: %w", ErrMalformedEnvelope, err)
 		}
-		return unknown, nil
+		return unknown, CallResultUnknown, nil
 	}
 
 	if callResult.Ok != nil {
 		var ok []byte
-		if err := cbor.Unmarshal(callResult.Ok, &ok); err != nil {
-			return nil, fmt.Errorf("failed decoding callResult.Ok: %w", err)
+		if err := c.limits.unmarshal(callResult.Ok, &ok); err != nil {
+			return nil, CallResultOk, fmt.Errorf("%w: decoding callResult.Ok: %w", ErrMalformedEnvelope, err)
 		}
-		return ok, nil
+		return ok, CallResultOk, nil
 	}
 
-	return nil, ErrCallResultDecode
+	return nil, CallResultUndecodable, ErrCallResultDecode
 }
 
 func (c PlainCipher) DecryptEncoded(response []byte) ([]byte, error) {
@@ -107,9 +148,12 @@ func (c PlainCipher) EncryptEncode(plaintext []byte) []byte {
 
 // X25519DeoxysIICipher is the default cipher that does what it says on the tin.
 type X25519DeoxysIICipher struct {
-	cipher  cipher.AEAD
-	keypair *Curve25519KeyPair
-	epoch   uint64
+	cipher        cipher.AEAD
+	keypair       *Curve25519KeyPair
+	peerPublicKey x25519.PublicKey
+	epoch         uint64
+	rng           io.Reader // Nonce source, crypto/rand if nil.
+	limits        DecodeLimits
 }
 
 type Curve25519KeyPair struct {
@@ -131,27 +175,61 @@ func NewCurve25519KeyPair() (*Curve25519KeyPair, error) {
 
 // NewX25519DeoxysIICipher creates a new cipher instance with encryption support.
 func NewX25519DeoxysIICipher(keypair *Curve25519KeyPair, peerPublicKey *x25519.PublicKey, epoch uint64) (*X25519DeoxysIICipher, error) {
-	var sharedKey [deoxysii.KeySize]byte
-	mrae.Box.DeriveSymmetricKey(sharedKey[:], peerPublicKey, &keypair.SecretKey)
+	sharedKey := local.X25519Derive(*peerPublicKey, keypair.SecretKey)
 	cipher, err := deoxysii.New(sharedKey[:])
 	mraeApi.Bzero(sharedKey[:])
 	if err != nil {
 		return nil, err
 	}
 	return &X25519DeoxysIICipher{
-		cipher:  cipher,
-		keypair: keypair,
-		epoch:   epoch,
+		cipher:        cipher,
+		keypair:       keypair,
+		peerPublicKey: *peerPublicKey,
+		epoch:         epoch,
 	}, nil
 }
 
+// NewX25519DeoxysIICipherWithRand is NewX25519DeoxysIICipher drawing nonces
+// from rng instead of crypto/rand, for reproducible test vectors. Nonces must
+// never repeat under a key, so it must not encrypt anything secret.
+func NewX25519DeoxysIICipherWithRand(keypair *Curve25519KeyPair, peerPublicKey *x25519.PublicKey, epoch uint64, rng io.Reader) (*X25519DeoxysIICipher, error) {
+	c, err := NewX25519DeoxysIICipher(keypair, peerPublicKey, epoch)
+	if err != nil {
+		return nil, err
+	}
+	c.rng = rng
+	return c, nil
+}
+
 func (c X25519DeoxysIICipher) CallFormat() types.CallFormat {
 	return types.CallFormatEncryptedX25519DeoxysII
 }
 
+// Epoch returns the epoch of the runtime key the cipher encrypts to.
+func (c X25519DeoxysIICipher) Epoch() uint64 {
+	return c.epoch
+}
+
+// ArtifactCipher returns the keys of the cipher for the CallArtifact of a
+// call it encrypted. The session key decrypts every call of the cipher.
+func (c X25519DeoxysIICipher) ArtifactCipher() ArtifactCipher {
+	sessionKey := local.X25519Derive(c.peerPublicKey, c.keypair.SecretKey)
+	return ArtifactCipher{
+		Format:           c.CallFormat(),
+		Epoch:            c.epoch,
+		PublicKey:        common.CopyBytes(c.keypair.PublicKey[:]),
+		RuntimePublicKey: common.CopyBytes(c.peerPublicKey[:]),
+		SessionKey:       sessionKey[:],
+	}
+}
+
 func (c X25519DeoxysIICipher) Encrypt(plaintext []byte) (ciphertext []byte, nonce []byte) {
 	nonce = make([]byte, deoxysii.NonceSize)
-	if _, err := rand.Reader.Read(nonce); err != nil {
+	rng := c.rng
+	if rng == nil {
+		rng = rand.Reader
+	}
+	if _, err := io.ReadFull(rng, nonce); err != nil {
 		panic(fmt.Sprintf("crypto/rand is unavailable: %v", err))
 	}
 	res := c.cipher.Seal(ciphertext, nonce, plaintext, []byte{})
@@ -193,87 +271,77 @@ func (c X25519DeoxysIICipher) EncryptEncode(plaintext []byte) []byte {
 }
 
 func (c X25519DeoxysIICipher) DecryptCallResult(response []byte) ([]byte, error) {
+	res, variant, inner, err := c.decodeCallResult(response)
+	if err != nil {
+		return nil, callResultError(c, response, variant, inner, err)
+	}
+	return res, nil
+}
+
+func (c X25519DeoxysIICipher) decodeCallResult(response []byte) ([]byte, CallResultVariant, CallResultVariant, error) {
 	var callResult types.CallResult
-	if err := cbor.Unmarshal(response, &callResult); err != nil {
-		return nil, err
+	if err := c.limits.unmarshal(response, &callResult); err != nil {
+		return nil, CallResultUndecodable, CallResultUndecodable, fmt.Errorf("%w: %w", ErrMalformedEnvelope, err)
 	}
 
-	// TODO: actually decode and return failure
 	if callResult.Failed != nil {
-		return nil, ErrCallFailed
+		return nil, CallResultFailed, CallResultUndecodable, newCallFailedError(callResult.Failed)
 	}
 
 	var aeadEnvelope types.ResultEnvelopeX25519DeoxysII
+	variant := CallResultOk
 	if callResult.Ok != nil {
-		if err := cbor.Unmarshal(callResult.Ok, &aeadEnvelope); err != nil {
+		if err := c.limits.unmarshal(callResult.Ok, &aeadEnvelope); err != nil {
 			// If Ok is not CBOR, return raw value.
-			return callResult.Ok, nil
+			return callResult.Ok, variant, CallResultUndecodable, nil
 		}
 	} else if callResult.Unknown != nil {
-		if err := cbor.Unmarshal(callResult.Unknown, &aeadEnvelope); err != nil {
+		variant = CallResultUnknown
+		if err := c.limits.unmarshal(callResult.Unknown, &aeadEnvelope); err != nil {
 			// If Unknown is not CBOR, return raw value.
-			return callResult.Unknown, nil
+			return callResult.Unknown, variant, CallResultUndecodable, nil
 		}
 	} else {
-		return nil, ErrCallResultDecode
+		return nil, CallResultUndecodable, CallResultUndecodable, ErrCallResultDecode
 	}
 
 	decrypted, err := c.Decrypt(aeadEnvelope.Nonce[:], aeadEnvelope.Data)
 	if err != nil {
-		return nil, err
+		return nil, variant, CallResultUndecodable, fmt.Errorf("%w: decrypting: %w", ErrMalformedEnvelope, err)
 	}
 
 	var innerResult types.CallResult
-	if err = cbor.Unmarshal(decrypted, &innerResult); err != nil {
-		return nil, err
+	if err = c.limits.unmarshal(decrypted, &innerResult); err != nil {
+		return nil, variant, CallResultUndecodable, fmt.Errorf("%w: %w", ErrMalformedEnvelope, err)
 	}
 
 	if innerResult.Unknown != nil {
 		var unknown []byte
-		if err = cbor.Unmarshal(innerResult.Unknown, &unknown); err != nil {
-			return nil, fmt.Errorf("failed decoding innerResult.Unknown: %w", err)
+		if err = c.limits.unmarshal(innerResult.Unknown, &unknown); err != nil {
+			return nil, variant, CallResultUnknown, fmt.Errorf("%w: decoding innerResult.Unknown: %w", ErrMalformedEnvelope, err)
 		}
-		return unknown, nil
+		return unknown, variant, CallResultUnknown, nil
 	}
 
 	if innerResult.Ok != nil {
 		var ok []byte
-		if err = cbor.Unmarshal(innerResult.Ok, &ok); err != nil {
-			return nil, fmt.Errorf("failed decoding innerResult.Ok: %w", err)
+		if err = c.limits.unmarshal(innerResult.Ok, &ok); err != nil {
+			return nil, variant, CallResultOk, fmt.Errorf("%w: decoding innerResult.Ok: %w", ErrMalformedEnvelope, err)
 		}
-		return ok, nil
+		return ok, variant, CallResultOk, nil
 	}
 
 	if innerResult.Failed != nil {
-		msg := innerResult.Failed.Message
-		if len(msg) == 0 {
-			msg = fmt.Sprintf("call failed in module %s with code %d", innerResult.Failed.Module, innerResult.Failed.Code)
-		}
-		return nil, errors.New(msg)
+		return nil, variant, CallResultFailed, newCallFailedError(innerResult.Failed)
 	}
 
-	return nil, fmt.Errorf("unexpected inner call result: %x", callResult.Unknown)
+	return nil, variant, CallResultUndecodable, fmt.Errorf("%w: unexpected inner call result: %x", ErrMalformedEnvelope, callResult.Unknown)
 }
 
 func (c X25519DeoxysIICipher) DecryptEncoded(response []byte) ([]byte, error) {
 	return c.DecryptCallResult(response)
 }
 
-// GetRuntimePublicKey fetches the runtime calldata public key from the default Sapphire gateway.
-func GetRuntimePublicKey(c *ethclient.Client) (*x25519.PublicKey, uint64, error) {
-	var pubKey CallDataPublicKey
-
-	if err := c.Client().Call(&pubKey, "oasis_callDataPublicKey"); err != nil {
-		return nil, 0, fmt.Errorf("invalid response when fetching runtime calldata public key: %w", err)
-	}
-
-	if len(pubKey.PublicKey) != x25519.PublicKeySize {
-		return nil, 0, fmt.Errorf("invalid public key length")
-	}
-
-	return (*x25519.PublicKey)(pubKey.PublicKey), pubKey.Epoch, nil
-}
-
 type Request struct {
 	Version string      `json:"jsonrpc"`
 	Method  string      `json:"method"`
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// PackAndCall ABI-encodes a call to method, makes an encrypted call to the
+// contract and decodes the decrypted result.
+func (b *WrappedBackend) PackAndCall(ctx context.Context, contract common.Address, parsedABI abi.ABI, method string, args ...interface{}) ([]interface{}, error) {
+	return b.PackAndSignedCall(ctx, common.Address{}, contract, parsedABI, method, args...)
+}
+
+// PackAndSignedCall is like PackAndCall but makes a signed query on behalf
+// of from, so that the contract sees from as msg.sender.
+func (b *WrappedBackend) PackAndSignedCall(ctx context.Context, from common.Address, contract common.Address, parsedABI abi.ABI, method string, args ...interface{}) ([]interface{}, error) {
+	data, err := parsedABI.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: packing %s: %v", sapphire.ErrABI, method, err)
+	}
+	ctx = contextWithABIMethod(ctx, method)
+	res, err := b.CallContract(ctx, ethereum.CallMsg{From: from, To: &contract, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 && len(parsedABI.Methods[method].Outputs) > 0 {
+		// Like bind.BoundContract, tell an empty result apart from a missing contract.
+		code, err := b.CodeAt(ctx, contract, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(code) == 0 {
+			return nil, bind.ErrNoCode
+		}
+	}
+	out, err := parsedABI.Unpack(method, res)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unpacking %s result: %v", sapphire.ErrABI, method, err)
+	}
+	return out, nil
+}
+
+// AuthenticatedCallOpts returns CallOpts that make abigen view methods called
+// through a wrapped client signed queries from signer's address, signed by
+// signer even if the client has no signer for it.
+//
+// Setting From in CallOpts is enough when the client can sign for it; the
+// signer carried by ctx takes precedence over the client's keyring and
+// SignerFn. A query from an address no signer is available for fails with
+// ErrNoSigner rather than being sent unsigned.
+func AuthenticatedCallOpts(ctx context.Context, signer sapphire.SignerWithAddress) *bind.CallOpts {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &bind.CallOpts{
+		From:    signer.Address(),
+		Context: sapphire.ContextWithSigner(ctx, signer),
+	}
+}
+
+// PackAndTransact ABI-encodes a call to method and sends it as an encrypted
+// transaction from opts.From.
+//
+// The transaction is always signed by the wrapped client's signer for
+// opts.From; opts.Signer is ignored so that the calldata cannot be sent in
+// plain text by mistake. Other options are honored as by bind.BoundContract.
+func (b *WrappedBackend) PackAndTransact(opts *bind.TransactOpts, contract common.Address, parsedABI abi.ABI, method string, args ...interface{}) (*types.Transaction, error) {
+	data, err := parsedABI.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: packing %s: %v", sapphire.ErrABI, method, err)
+	}
+	o := *opts
+	o.Signer = b.bindSigner(opts)
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	o.Context = contextWithABIMethod(ctx, method)
+	return bind.NewBoundContract(contract, parsedABI, b, b, b).RawTransact(&o, data)
+}
+
+// DeployConfidential deploys a contract like bind.DeployContract, the
+// function abigen's Deploy methods call, with its initcode and constructor
+// arguments encrypted.
+//
+// If backend is a *WrappedBackend, the transaction is signed by its signer
+// for opts.From and opts.Signer is ignored, as by PackAndTransact; the gas
+// limit, unless set in opts, is estimated on the encrypted initcode. With other
+// backends opts.Signer must encrypt, e.g. one made by NewSapphireTransactor
+// with backend being the one it returns, and deployments it would sign in
+// plain text are refused.
+func DeployConfidential(opts *bind.TransactOpts, backend bind.ContractBackend, parsedABI abi.ABI, bytecode []byte, params ...interface{}) (common.Address, *types.Transaction, *bind.BoundContract, error) {
+	if _, err := parsedABI.Pack("", params...); err != nil {
+		return common.Address{}, nil, nil, fmt.Errorf("%w: packing constructor arguments: %v", sapphire.ErrABI, err)
+	}
+	o := *opts
+	if b, ok := backend.(*WrappedBackend); ok {
+		o.Signer = b.bindSigner(opts)
+	} else {
+		o.Signer = func(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			signed, err := opts.Signer(addr, tx)
+			if err != nil {
+				return nil, err
+			}
+			if txNeedsPacking(signed) {
+				return nil, fmt.Errorf("%w: deploying contract with unencrypted initcode, use a signer from NewSapphireTransactor", sapphire.ErrUnencryptedCalldata)
+			}
+			return signed, nil
+		}
+	}
+	return bind.DeployContract(&o, parsedABI, bytecode, backend, params...)
+}
+
+// bindSigner returns a bind.SignerFn that packs and signs transactions from
+// opts.From with the wrapped client's signer, honoring opts.Context.
+func (b *WrappedBackend) bindSigner(opts *bind.TransactOpts) bind.SignerFn {
+	return func(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if addr != opts.From {
+			return nil, bind.ErrNotAuthorized
+		}
+		ctx := opts.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		return b.signTx(ctx, addr, tx)
+	}
+}
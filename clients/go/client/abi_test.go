@@ -0,0 +1,376 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+const testContractABI = `[
+	{"type":"function","name":"pair","stateMutability":"view","inputs":[],"outputs":[{"name":"a","type":"uint256"},{"name":"b","type":"string"}]},
+	{"type":"function","name":"point","stateMutability":"view","inputs":[],"outputs":[{"name":"p","type":"tuple","components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}]}]},
+	{"type":"function","name":"list","stateMutability":"view","inputs":[{"name":"n","type":"uint256"}],"outputs":[{"name":"","type":"uint256[]"}]},
+	{"type":"function","name":"set","stateMutability":"nonpayable","inputs":[{"name":"v","type":"uint256"}],"outputs":[]}
+]`
+
+func TestPackAndCall(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(testContractABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	contract := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	type point struct {
+		X *big.Int `json:"x"`
+		Y *big.Int `json:"y"`
+	}
+	for _, tc := range []struct {
+		method string
+		args   []interface{}
+		out    []interface{}
+	}{
+		{"pair", nil, []interface{}{big.NewInt(42), "hello"}},
+		{"point", nil, []interface{}{point{big.NewInt(1), big.NewInt(2)}}},
+		{"list", []interface{}{big.NewInt(3)}, []interface{}{[]*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}}},
+	} {
+		encoded, err := parsed.Methods[tc.method].Outputs.Pack(tc.out...)
+		if err != nil {
+			t.Fatalf("%s: failed to encode result: %v", tc.method, err)
+		}
+		mock := newMockBackend()
+		mock.callResult = cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(encoded)})
+		b := newMockWrappedBackend(mock, nil)
+
+		out, err := b.PackAndCall(ctx, contract, parsed, tc.method, tc.args...)
+		if err != nil {
+			t.Fatalf("%s: PackAndCall failed: %v", tc.method, err)
+		}
+		if len(out) != len(tc.out) {
+			t.Fatalf("%s: expected %d results, got %d", tc.method, len(tc.out), len(out))
+		}
+		if tc.method == "point" {
+			got := reflect.ValueOf(out[0])
+			if got.Field(0).Interface().(*big.Int).Int64() != 1 || got.Field(1).Interface().(*big.Int).Int64() != 2 {
+				t.Fatalf("point: unexpected result %v", out[0])
+			}
+		} else if !reflect.DeepEqual(out, tc.out) {
+			t.Fatalf("%s: expected %v, got %v", tc.method, tc.out, out)
+		}
+
+		data, err := parsed.Pack(tc.method, tc.args...)
+		if err != nil {
+			t.Fatalf("%s: failed to pack call: %v", tc.method, err)
+		}
+		if sent := mock.receivedCalls()[0].Data; !reflect.DeepEqual(sent, sapphire.NewPlainCipher().EncryptEncode(data)) {
+			t.Fatalf("%s: call was not sent through the cipher", tc.method)
+		}
+	}
+}
+
+func TestPackAndCallErrors(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(testContractABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	contract := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	mock := newMockBackend()
+	mock.callResult = cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal([]byte{1, 2, 3})})
+	b := newMockWrappedBackend(mock, nil)
+	if _, err = b.PackAndCall(ctx, contract, parsed, "missing"); !errors.Is(err, sapphire.ErrABI) {
+		t.Fatalf("expected ErrABI for an unknown method, got %v", err)
+	}
+	if _, err = b.PackAndCall(ctx, contract, parsed, "list", "three"); !errors.Is(err, sapphire.ErrABI) {
+		t.Fatalf("expected ErrABI for invalid arguments, got %v", err)
+	}
+	if _, err = b.PackAndCall(ctx, contract, parsed, "pair"); !errors.Is(err, sapphire.ErrABI) {
+		t.Fatalf("expected ErrABI for a malformed result, got %v", err)
+	}
+
+	mock.callErr = errors.New("execution reverted")
+	if _, err = b.PackAndCall(ctx, contract, parsed, "pair"); err == nil || errors.Is(err, sapphire.ErrABI) {
+		t.Fatalf("expected a chain error, got %v", err)
+	}
+}
+
+func TestPackAndTransact(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(testContractABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	contract := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	mock := newMockBackend()
+	b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+
+	opts := b.Transactor(from)
+	opts.Signer = nil
+	tx, err := b.PackAndTransact(opts, contract, parsed, "set", big.NewInt(7))
+	if err != nil {
+		t.Fatalf("PackAndTransact failed: %v", err)
+	}
+	data, _ := parsed.Pack("set", big.NewInt(7))
+	sent := mock.sentTransactions()
+	if len(sent) != 1 || sent[0].Hash() != tx.Hash() {
+		t.Fatalf("expected the transaction to be sent")
+	}
+	if !reflect.DeepEqual(sent[0].Data(), sapphire.NewPlainCipher().EncryptEncode(data)) {
+		t.Fatalf("transaction calldata was not sent through the cipher")
+	}
+
+	if _, err = b.PackAndTransact(opts, contract, parsed, "set", "seven"); !errors.Is(err, sapphire.ErrABI) {
+		t.Fatalf("expected ErrABI for invalid arguments, got %v", err)
+	}
+}
+
+// ctorStorageCode stores its constructor argument in slot 0 and deploys
+// sloadCode, which returns it.
+var ctorStorageCode = common.FromHex("602060203803600039600051600055600b80601a6000396000f3" + "60005460005260206000f3")
+
+const ctorStorageABI = `[{"type":"constructor","inputs":[{"name":"value","type":"uint256"}],"stateMutability":"nonpayable"}]`
+
+func TestDeployConfidential(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(ctorStorageABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	ctx := context.Background()
+	secret := new(big.Int).SetBytes([]byte("confidential constructor arg"))
+
+	mock := newMockBackend()
+	b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+	b.cipher = newSeededCipher(t, 1)
+
+	opts := b.Transactor(from)
+	opts.Signer = nil
+	addr, tx, _, err := DeployConfidential(opts, b, parsed, ctorStorageCode, secret)
+	if err != nil {
+		t.Fatalf("DeployConfidential failed: %v", err)
+	}
+	if tx.To() != nil || addr != crypto.CreateAddress(from, tx.Nonce()) {
+		t.Fatalf("unexpected deployment of %s", addr.Hex())
+	}
+
+	var envelope sdkTypes.Call
+	if err = cbor.Unmarshal(tx.Data(), &envelope); err != nil || envelope.Format != sdkTypes.CallFormatEncryptedX25519DeoxysII {
+		t.Fatalf("initcode was not encrypted")
+	}
+	if bytes.Contains(tx.Data(), ctorStorageCode) || bytes.Contains(tx.Data(), secret.Bytes()) {
+		t.Fatalf("deployment leaks the initcode or constructor arguments")
+	}
+	estimate := mock.receivedCalls()[0]
+	if estimate.To != nil || bytes.Contains(estimate.Data, secret.Bytes()) {
+		t.Fatalf("gas was not estimated on the encrypted initcode")
+	}
+	if tx.Gas() != mock.gas {
+		t.Fatalf("unexpected gas limit %d", tx.Gas())
+	}
+
+	mock.mine(tx, types.ReceiptStatusSuccessful, 21_000)
+	deployed, err := bind.WaitDeployed(ctx, b, tx)
+	if err != nil || deployed != addr {
+		t.Fatalf("WaitDeployed failed: %v", err)
+	}
+
+	if _, _, _, err = DeployConfidential(opts, b, parsed, ctorStorageCode, "seven"); !errors.Is(err, sapphire.ErrABI) {
+		t.Fatalf("expected ErrABI for invalid arguments, got %v", err)
+	}
+}
+
+func TestDeployConfidentialPlainSigner(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(ctorStorageABI))
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	opts, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(0x5afd))
+	if err != nil {
+		t.Fatalf("failed to create transactor: %v", err)
+	}
+	mock := newMockBackend()
+	if _, _, _, err = DeployConfidential(opts, mock, parsed, ctorStorageCode, big.NewInt(7)); err == nil {
+		t.Fatalf("expected a plain text deployment to be refused")
+	}
+	if len(mock.sentTransactions()) != 0 {
+		t.Fatalf("plain text deployment was sent")
+	}
+}
+
+func TestDeployConfidentialLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	signer := sapphire.NewPrivateKeySigner(key)
+	client := localnet.Dial(t)
+	b, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(signer)))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	parsed, _ := abi.JSON(strings.NewReader(ctorStorageABI))
+	secret := new(big.Int).SetBytes([]byte("confidential constructor arg"))
+	opts := b.Transactor(signer.Address())
+	opts.Context = ctx
+	addr, tx, _, err := DeployConfidential(opts, b, parsed, ctorStorageCode, secret)
+	if err != nil {
+		t.Fatalf("DeployConfidential failed: %v", err)
+	}
+	if _, err = bind.WaitDeployed(ctx, b, tx); err != nil {
+		t.Fatalf("deployment failed: %v", err)
+	}
+
+	mined, _, err := client.TransactionByHash(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to fetch deployment: %v", err)
+	}
+	if bytes.Contains(mined.Data(), secret.Bytes()) {
+		t.Fatalf("constructor arguments were sent in plain text")
+	}
+	stored, err := b.CallContract(ctx, ethereum.CallMsg{To: &addr}, nil)
+	if err != nil {
+		t.Fatalf("failed to call deployed contract: %v", err)
+	}
+	if new(big.Int).SetBytes(stored).Cmp(secret) != 0 {
+		t.Fatalf("unexpected stored value %x", stored)
+	}
+}
+
+// ownerGuardedCode stores its deployer as owner and, when called by the
+// owner, returns 1. Calls from anyone else revert.
+var ownerGuardedCode = common.FromHex("33600055601780600f6000396000f3" + "6000543314600c57600080fd5b600160005260206000f3")
+
+const ownerGuardedABI = `[{"type":"function","name":"secret","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]}]`
+
+// countingSigner counts the signatures made by a signer.
+type countingSigner struct {
+	sapphire.SignerWithAddress
+	mu sync.Mutex
+	n  int
+}
+
+func (s *countingSigner) SignRSV(digest [32]byte) ([]byte, error) {
+	s.mu.Lock()
+	s.n++
+	s.mu.Unlock()
+	return s.SignerWithAddress.SignRSV(digest)
+}
+
+func (s *countingSigner) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.n
+}
+
+func TestAuthenticatedCallOpts(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(ownerGuardedABI))
+	encoded, _ := parsed.Methods["secret"].Outputs.Pack(big.NewInt(1))
+	contract := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	keyA, _ := crypto.GenerateKey()
+	keyB, _ := crypto.GenerateKey()
+	inKeyring := &countingSigner{SignerWithAddress: sapphire.NewPrivateKeySigner(keyA)}
+	inContext := &countingSigner{SignerWithAddress: sapphire.NewPrivateKeySigner(keyA)}
+	onlyInContext := &countingSigner{SignerWithAddress: sapphire.NewPrivateKeySigner(keyB)}
+	stranger := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	mock := newMockBackend()
+	mock.callResult = cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(encoded)})
+	b := newMockWrappedBackend(mock, nil, WithKeyring(sapphire.NewKeyring(inKeyring)))
+	contractBinding := bind.NewBoundContract(contract, parsed, b, b, b)
+	call := func(opts *bind.CallOpts) error {
+		var out []interface{}
+		return contractBinding.Call(opts, &out, "secret")
+	}
+
+	// From alone is enough for accounts in the keyring.
+	if err := call(&bind.CallOpts{From: inKeyring.Address()}); err != nil || inKeyring.count() != 1 {
+		t.Fatalf("expected the keyring to sign: %v", err)
+	}
+	// The context's signer takes precedence over the keyring.
+	if err := call(AuthenticatedCallOpts(ctx, inContext)); err != nil || inContext.count() != 1 || inKeyring.count() != 1 {
+		t.Fatalf("expected the context signer to sign: %v", err)
+	}
+	// And signs for accounts the client has no signer for.
+	if err := call(AuthenticatedCallOpts(ctx, onlyInContext)); err != nil || onlyInContext.count() != 1 {
+		t.Fatalf("expected the context signer to sign: %v", err)
+	}
+	// A context signer for another account is not used.
+	opts := AuthenticatedCallOpts(ctx, onlyInContext)
+	opts.From = inKeyring.Address()
+	if err := call(opts); err != nil || inKeyring.count() != 2 || onlyInContext.count() != 1 {
+		t.Fatalf("expected the keyring to sign: %v", err)
+	}
+	// Queries from accounts nobody can sign for are not sent unsigned.
+	calls := len(mock.receivedCalls())
+	opts.From = stranger
+	if err := call(opts); !errors.Is(err, sapphire.ErrNoSigner) {
+		t.Fatalf("expected ErrNoSigner, got %v", err)
+	}
+	if len(mock.receivedCalls()) != calls {
+		t.Fatalf("query without a signer was sent")
+	}
+}
+
+func TestAuthenticatedCallOptsLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	owner := sapphire.NewPrivateKeySigner(key)
+	client := localnet.Dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	deployer, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(owner)))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	parsed, _ := abi.JSON(strings.NewReader(ownerGuardedABI))
+	opts := deployer.Transactor(owner.Address())
+	opts.Context = ctx
+	_, tx, _, err := DeployConfidential(opts, deployer, parsed, ownerGuardedCode)
+	if err != nil {
+		t.Fatalf("DeployConfidential failed: %v", err)
+	}
+	addr, err := bind.WaitDeployed(ctx, deployer, tx)
+	if err != nil {
+		t.Fatalf("deployment failed: %v", err)
+	}
+
+	// A client without any signer, as the generated caller would use it.
+	b, err := WrapClient(client, nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	caller := bind.NewBoundContract(addr, parsed, b, b, b)
+	var out []interface{}
+	if err = caller.Call(AuthenticatedCallOpts(ctx, owner), &out, "secret"); err != nil {
+		t.Fatalf("owner call failed: %v", err)
+	}
+	if len(out) != 1 || out[0].(*big.Int).Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("unexpected result %v", out)
+	}
+	if err = caller.Call(&bind.CallOpts{Context: ctx}, &out, "secret"); err == nil {
+		t.Fatalf("expected an anonymous call to revert")
+	}
+}
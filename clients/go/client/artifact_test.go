@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/mockgateway"
+)
+
+// sendForArtifact sends a transaction with data through a client of gw and
+// returns the client and the transaction.
+func sendForArtifact(t *testing.T, gw *mockgateway.Gateway, data []byte) (*WrappedBackend, *types.Transaction) {
+	keyring := newTestKeyring(t, 1)
+	b, err := WrapClient(gw.Dial(t), nil, WithKeyring(keyring))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	from := keyring.Addresses()[0]
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	tx, err := b.Transactor(from).Signer(from, types.NewTransaction(0, to, big.NewInt(0), 100_000, big.NewInt(sapphire.DefaultGasPrice), data))
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if err = b.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	return b, tx
+}
+
+func TestExportArtifact(t *testing.T) {
+	gw := mockgateway.New(t)
+	b, tx := sendForArtifact(t, gw, []byte("secret"))
+
+	artifact, err := b.ExportArtifact(tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to export artifact: %v", err)
+	}
+	sent := gw.Calls("eth_sendRawTransaction")[0]
+	if artifact.From != sent.From || artifact.PlaintextHash != crypto.Keccak256Hash([]byte("secret")) || artifact.Cipher.Epoch != mockgateway.DefaultEpoch {
+		t.Fatalf("unexpected artifact %+v", artifact)
+	}
+	if runtimeKey := gw.PublicKey(); string(artifact.Cipher.RuntimePublicKey) != string(runtimeKey[:]) {
+		t.Fatalf("expected the runtime key %x, got %x", runtimeKey, artifact.Cipher.RuntimePublicKey)
+	}
+	if err = artifact.Verify(); err != nil {
+		t.Fatalf("artifact does not verify: %v", err)
+	}
+
+	// The JSON encoding is versioned and round trips.
+	encoded, err := json.Marshal(artifact)
+	if err != nil {
+		t.Fatalf("failed to encode artifact: %v", err)
+	}
+	if !strings.HasPrefix(string(encoded), `{"schemaVersion":1,"txHash":"`+tx.Hash().Hex()+`"`) {
+		t.Fatalf("unexpected encoding %s", encoded)
+	}
+	var decoded sapphire.CallArtifact
+	if err = json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("failed to decode artifact: %v", err)
+	}
+	if err = decoded.Verify(); err != nil {
+		t.Fatalf("decoded artifact does not verify: %v", err)
+	}
+	if reencoded, _ := json.Marshal(decoded); string(reencoded) != string(encoded) {
+		t.Fatalf("artifact changed in round trip:\n%s\n%s", encoded, reencoded)
+	}
+
+	for _, doc := range []string{
+		strings.Replace(string(encoded), `"schemaVersion":1`, `"schemaVersion":2`, 1),
+		strings.Replace(string(encoded), `"chainId":"0x5afd",`, "", 1),
+		`{"schemaVersion":1,"nonce":1}`,
+	} {
+		if err = json.Unmarshal([]byte(doc), &decoded); !errors.Is(err, sapphire.ErrInvalidArtifact) {
+			t.Fatalf("expected %s to be rejected, got %v", doc, err)
+		}
+	}
+
+	if _, err = b.ExportArtifact(common.Hash{1}); !errors.Is(err, ErrNoArtifact) {
+		t.Fatalf("expected no artifact of an unknown transaction, got %v", err)
+	}
+}
+
+func TestArtifactTamperDetection(t *testing.T) {
+	gw := mockgateway.New(t)
+	b, tx := sendForArtifact(t, gw, []byte("secret"))
+	original, err := b.ExportArtifact(tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to export artifact: %v", err)
+	}
+	_, other := sendForArtifact(t, gw, []byte("other"))
+	otherRaw, _ := other.MarshalBinary()
+
+	for name, tamper := range map[string]func(a *sapphire.CallArtifact){
+		"tx hash":        func(a *sapphire.CallArtifact) { a.TxHash[0] ^= 1 },
+		"chain ID":       func(a *sapphire.CallArtifact) { a.ChainID = big.NewInt(1) },
+		"sender":         func(a *sapphire.CallArtifact) { a.From[0] ^= 1 },
+		"callee":         func(a *sapphire.CallArtifact) { a.To = nil },
+		"nonce":          func(a *sapphire.CallArtifact) { a.Nonce++ },
+		"plaintext hash": func(a *sapphire.CallArtifact) { a.PlaintextHash = crypto.Keccak256Hash([]byte("public")) },
+		"envelope":       func(a *sapphire.CallArtifact) { a.Envelope[len(a.Envelope)-1] ^= 1 },
+		"signature":      func(a *sapphire.CallArtifact) { a.Signature[64] ^= 1 },
+		"transaction":    func(a *sapphire.CallArtifact) { a.Transaction = otherRaw },
+		"epoch":          func(a *sapphire.CallArtifact) { a.Cipher.Epoch++ },
+		"public key":     func(a *sapphire.CallArtifact) { a.Cipher.PublicKey[0] ^= 1 },
+		"session key":    func(a *sapphire.CallArtifact) { a.Cipher.SessionKey[0] ^= 1 },
+		"format":         func(a *sapphire.CallArtifact) { a.Cipher.Format = 0 },
+	} {
+		// Tamper with a deep copy.
+		encoded, _ := json.Marshal(original)
+		var a sapphire.CallArtifact
+		if err = json.Unmarshal(encoded, &a); err != nil {
+			t.Fatalf("failed to copy artifact: %v", err)
+		}
+		tamper(&a)
+		if err = a.Verify(); !errors.Is(err, sapphire.ErrInvalidArtifact) {
+			t.Fatalf("%s: expected tampering to be detected, got %v", name, err)
+		}
+	}
+}
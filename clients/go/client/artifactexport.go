@@ -0,0 +1,79 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// ErrNoArtifact is returned by ExportArtifact for transactions the wrapped
+// client has no record of.
+var ErrNoArtifact = errors.New("no artifact of transaction")
+
+// ExportArtifact returns the artifact of a transaction the wrapped client
+// encrypted and signed, see CallArtifact. Only the most recent transactions
+// are remembered, as for DiagnoseFailedTx; transactions without calldata,
+// which are not encrypted, have none.
+func (b *WrappedBackend) ExportArtifact(txHash common.Hash) (*sapphire.CallArtifact, error) {
+	entry, ok := b.plaintexts.signed(txHash)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoArtifact, txHash.Hex())
+	}
+	tx := entry.tx
+	from, err := types.Sender(types.LatestSignerForChainID(&b.chainID), tx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction signature: %w", err)
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	artifact := &sapphire.CallArtifact{
+		TxHash:        txHash,
+		ChainID:       new(big.Int).Set(&b.chainID),
+		From:          from,
+		To:            tx.To(),
+		Nonce:         tx.Nonce(),
+		PlaintextHash: crypto.Keccak256Hash(entry.plaintext),
+		Envelope:      tx.Data(),
+		Signature:     rsvSignature(tx),
+		Transaction:   raw,
+		Cipher:        sapphire.ArtifactCipher{Format: entry.cipher.CallFormat()},
+	}
+	switch c := entry.cipher.(type) {
+	case sapphire.PlainCipher:
+	case *sapphire.X25519DeoxysIICipher:
+		artifact.Cipher = c.ArtifactCipher()
+	default:
+		if artifact.Cipher.Format != sdkTypes.CallFormatPlain {
+			return nil, fmt.Errorf("%w: keys of cipher %T unknown", ErrNoArtifact, entry.cipher)
+		}
+	}
+	return artifact, nil
+}
+
+// rsvSignature returns the signature of tx in RSV format, with V 0 or 1.
+func rsvSignature(tx *types.Transaction) []byte {
+	v, r, s := tx.RawSignatureValues()
+	recID := new(big.Int).Set(v)
+	if tx.Type() == types.LegacyTxType {
+		if tx.Protected() {
+			recID.Sub(recID, new(big.Int).Lsh(tx.ChainId(), 1))
+			recID.Sub(recID, big.NewInt(35))
+		} else {
+			recID.Sub(recID, big.NewInt(27))
+		}
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:64])
+	sig[64] = byte(recID.Uint64())
+	return sig
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// ErrUnauthenticated is returned by PackAndAuthenticatedCall for calls that
+// can't be authenticated as asked, rather than making them unauthenticated.
+var ErrUnauthenticated = errors.New("call cannot be authenticated")
+
+// AuthMethod authenticates the view calls of PackAndAuthenticatedCall, see
+// WithSignedQuery, WithSiweToken and WithAuto.
+type AuthMethod interface {
+	// authenticate returns the context, sender and arguments of the call.
+	authenticate(ctx context.Context, contract common.Address, parsedABI abi.ABI, method string, args []interface{}) (context.Context, common.Address, []interface{}, error)
+}
+
+// AuthOption configures an AuthMethod.
+type AuthOption func(*authConfig)
+
+type authConfig struct {
+	tokenArgument string
+	tokens        sapphire.TokenSource
+	isSiweAuth    func(abi.ABI) bool
+}
+
+// WithTokenArgument sets the name of the argument methods take the SIWE
+// token in, SiweTokenArgument by default.
+func WithTokenArgument(name string) AuthOption {
+	return func(cfg *authConfig) {
+		cfg.tokenArgument = name
+	}
+}
+
+// WithTokenSource sets where WithAuto gets the SIWE tokens of SiweAuth
+// contracts from.
+func WithTokenSource(tokens sapphire.TokenSource) AuthOption {
+	return func(cfg *authConfig) {
+		cfg.tokens = tokens
+	}
+}
+
+// WithSiweAuthDetector overrides how WithAuto detects SiweAuth contracts,
+// IsSiweAuth by default.
+func WithSiweAuthDetector(isSiweAuth func(parsedABI abi.ABI) bool) AuthOption {
+	return func(cfg *authConfig) {
+		cfg.isSiweAuth = isSiweAuth
+	}
+}
+
+func newAuthConfig(opts []AuthOption) authConfig {
+	cfg := authConfig{tokenArgument: sapphire.SiweTokenArgument, isSiweAuth: IsSiweAuth}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// IsSiweAuth reports whether the contract with ABI parsedABI extends
+// SiweAuth, i.e. has its login and domain methods.
+func IsSiweAuth(parsedABI abi.ABI) bool {
+	login, ok := parsedABI.Methods["login"]
+	if !ok || login.Sig != "login(string,(bytes32,bytes32,uint256))" {
+		return false
+	}
+	domain, ok := parsedABI.Methods["domain"]
+	return ok && domain.Sig == "domain()"
+}
+
+// tokenIndex returns the index of the token argument of method, or -1 if
+// it takes none.
+func (cfg *authConfig) tokenIndex(parsedABI abi.ABI, method string) int {
+	for i, input := range parsedABI.Methods[method].Inputs {
+		if input.Name == cfg.tokenArgument && input.Type.T == abi.BytesTy {
+			return i
+		}
+	}
+	return -1
+}
+
+// withToken returns args with token inserted as argument i.
+func withToken(args []interface{}, i int, token []byte) []interface{} {
+	if i > len(args) {
+		// Let packing report the missing arguments.
+		return args
+	}
+	injected := make([]interface{}, 0, len(args)+1)
+	injected = append(injected, args[:i]...)
+	injected = append(injected, token)
+	return append(injected, args[i:]...)
+}
+
+type signedQueryAuth struct {
+	signer sapphire.SignerWithAddress
+}
+
+// WithSignedQuery authenticates calls as signed queries from signer's
+// address, signed by signer, so that the contract sees it as msg.sender.
+func WithSignedQuery(signer sapphire.SignerWithAddress) AuthMethod {
+	return &signedQueryAuth{signer: signer}
+}
+
+func (a *signedQueryAuth) authenticate(ctx context.Context, _ common.Address, _ abi.ABI, _ string, args []interface{}) (context.Context, common.Address, []interface{}, error) {
+	if a.signer == nil {
+		return nil, common.Address{}, nil, fmt.Errorf("%w: no signer", ErrUnauthenticated)
+	}
+	return sapphire.ContextWithSigner(ctx, a.signer), a.signer.Address(), args, nil
+}
+
+type siweTokenAuth struct {
+	token []byte
+	cfg   authConfig
+}
+
+// WithSiweToken authenticates calls to methods of SiweAuth contracts by
+// passing them token in their token argument, which is left out of the
+// arguments of the call. Calls to methods without a token argument fail.
+func WithSiweToken(token []byte, opts ...AuthOption) AuthMethod {
+	return &siweTokenAuth{token: token, cfg: newAuthConfig(opts)}
+}
+
+func (a *siweTokenAuth) authenticate(ctx context.Context, _ common.Address, parsedABI abi.ABI, method string, args []interface{}) (context.Context, common.Address, []interface{}, error) {
+	i := a.cfg.tokenIndex(parsedABI, method)
+	switch {
+	case i < 0:
+		return nil, common.Address{}, nil, fmt.Errorf("%w: %s takes no %s argument", ErrUnauthenticated, method, a.cfg.tokenArgument)
+	case len(a.token) == 0:
+		return nil, common.Address{}, nil, fmt.Errorf("%w: no SIWE token", ErrUnauthenticated)
+	}
+	return ctx, common.Address{}, withToken(args, i, a.token), nil
+}
+
+type autoAuth struct {
+	signer sapphire.SignerWithAddress
+	cfg    authConfig
+}
+
+// WithAuto authenticates calls to methods taking a token argument of
+// SiweAuth contracts with the SIWE token of signer's address, see
+// WithTokenSource, and other calls as signed queries by signer.
+//
+// Calls to methods taking a token argument of contracts not detected as
+// SiweAuth fail, as do calls needing a token when there is no token source.
+func WithAuto(signer sapphire.SignerWithAddress, opts ...AuthOption) AuthMethod {
+	return &autoAuth{signer: signer, cfg: newAuthConfig(opts)}
+}
+
+func (a *autoAuth) authenticate(ctx context.Context, contract common.Address, parsedABI abi.ABI, method string, args []interface{}) (context.Context, common.Address, []interface{}, error) {
+	if a.signer == nil {
+		return nil, common.Address{}, nil, fmt.Errorf("%w: no signer", ErrUnauthenticated)
+	}
+	i := a.cfg.tokenIndex(parsedABI, method)
+	if i < 0 {
+		return (&signedQueryAuth{signer: a.signer}).authenticate(ctx, contract, parsedABI, method, args)
+	}
+	switch {
+	case !a.cfg.isSiweAuth(parsedABI):
+		return nil, common.Address{}, nil, fmt.Errorf("%w: %s takes a %s argument, but the contract is not SiweAuth", ErrUnauthenticated, method, a.cfg.tokenArgument)
+	case a.cfg.tokens == nil:
+		return nil, common.Address{}, nil, fmt.Errorf("%w: no token source", ErrUnauthenticated)
+	}
+	token, err := a.cfg.tokens.Bearer(ctx, contract, a.signer.Address())
+	if err != nil {
+		return nil, common.Address{}, nil, fmt.Errorf("%w: failed to get SIWE token: %v", ErrUnauthenticated, err)
+	}
+	return (&siweTokenAuth{token: token, cfg: a.cfg}).authenticate(ctx, contract, parsedABI, method, args)
+}
+
+// PackAndAuthenticatedCall is like PackAndCall but authenticates the call
+// with auth. Calls that can't be authenticated as auth asks fail with
+// ErrUnauthenticated instead of being made unauthenticated.
+func (b *WrappedBackend) PackAndAuthenticatedCall(ctx context.Context, contract common.Address, auth AuthMethod, parsedABI abi.ABI, method string, args ...interface{}) ([]interface{}, error) {
+	if auth == nil {
+		return nil, fmt.Errorf("%w: no auth method", ErrUnauthenticated)
+	}
+	if _, ok := parsedABI.Methods[method]; !ok {
+		return nil, fmt.Errorf("%w: packing %s: method not found", sapphire.ErrABI, method)
+	}
+	ctx, from, args, err := auth.authenticate(ctx, contract, parsedABI, method, args)
+	if err != nil {
+		return nil, err
+	}
+	return b.PackAndSignedCall(ctx, from, contract, parsedABI, method, args...)
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// siweAuthABI is the ABI of a contract extending SiweAuth.
+const siweAuthABI = `[
+	{"type":"function","name":"login","stateMutability":"view","inputs":[{"name":"siweMsg","type":"string"},{"name":"sig","type":"tuple","components":[{"name":"r","type":"bytes32"},{"name":"s","type":"bytes32"},{"name":"v","type":"uint256"}]}],"outputs":[{"name":"","type":"bytes"}]},
+	{"type":"function","name":"domain","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"getSecret","stateMutability":"view","inputs":[{"name":"id","type":"uint256"},{"name":"bearer","type":"bytes"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"open","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+// tokenSource hands out a fixed token.
+type tokenSource struct {
+	token  []byte
+	users  []common.Address
+	failed bool
+}
+
+func (s *tokenSource) Bearer(_ context.Context, _, user common.Address) ([]byte, error) {
+	if s.failed {
+		return nil, errors.New("login failed")
+	}
+	s.users = append(s.users, user)
+	return s.token, nil
+}
+
+func TestPackAndAuthenticatedCall(t *testing.T) {
+	ctx := context.Background()
+	guarded, _ := abi.JSON(strings.NewReader(ownerGuardedABI))
+	siwe, _ := abi.JSON(strings.NewReader(siweAuthABI))
+	if IsSiweAuth(guarded) || !IsSiweAuth(siwe) {
+		t.Fatalf("expected only the SiweAuth contract to be detected")
+	}
+	contract := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	key, _ := crypto.GenerateKey()
+	signer := &countingSigner{SignerWithAddress: sapphire.NewPrivateKeySigner(key)}
+	encoded, _ := guarded.Methods["secret"].Outputs.Pack(big.NewInt(1))
+	mock := newMockBackend()
+	mock.callResult = cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(encoded)})
+	b := newMockWrappedBackend(mock, nil)
+	tokens := &tokenSource{token: []byte("token")}
+
+	// lastCall returns the sender and plain calldata of the last call.
+	lastCall := func() (common.Address, []byte) {
+		calls := mock.receivedCalls()
+		call := calls[len(calls)-1]
+		return call.From, call.Data
+	}
+	withToken := func(args ...interface{}) []byte {
+		data, _ := siwe.Pack("getSecret", args...)
+		return sapphire.NewPlainCipher().EncryptEncode(data)
+	}
+
+	for _, auth := range []AuthMethod{WithSignedQuery(signer), WithAuto(signer, WithTokenSource(tokens))} {
+		before := signer.count()
+		if _, err := b.PackAndAuthenticatedCall(ctx, contract, auth, guarded, "secret"); err != nil {
+			t.Fatalf("%T: PackAndAuthenticatedCall failed: %v", auth, err)
+		}
+		if from, _ := lastCall(); from != signer.Address() || signer.count() != before+1 {
+			t.Fatalf("%T: expected a query signed by %s, got one from %s", auth, signer.Address().Hex(), from.Hex())
+		}
+	}
+	if len(tokens.users) != 0 {
+		t.Fatalf("expected no token to be requested for signed queries")
+	}
+
+	for _, auth := range []AuthMethod{WithSiweToken([]byte("token")), WithAuto(signer, WithTokenSource(tokens))} {
+		before := signer.count()
+		if _, err := b.PackAndAuthenticatedCall(ctx, contract, auth, siwe, "getSecret", big.NewInt(7)); err != nil {
+			t.Fatalf("%T: PackAndAuthenticatedCall failed: %v", auth, err)
+		}
+		if from, data := lastCall(); from != (common.Address{}) || !bytes.Equal(data, withToken(big.NewInt(7), []byte("token"))) || signer.count() != before {
+			t.Fatalf("%T: expected the token to be passed in an unsigned call", auth)
+		}
+	}
+	if len(tokens.users) != 1 || tokens.users[0] != signer.Address() {
+		t.Fatalf("expected the signer's token to be requested, got %v", tokens.users)
+	}
+
+	// Methods of SiweAuth contracts without a token argument are signed
+	// queries.
+	if _, err := b.PackAndAuthenticatedCall(ctx, contract, WithAuto(signer, WithTokenSource(tokens)), siwe, "open"); err != nil {
+		t.Fatalf("PackAndAuthenticatedCall failed: %v", err)
+	}
+	if from, _ := lastCall(); from != signer.Address() {
+		t.Fatalf("expected a signed query, got one from %s", from.Hex())
+	}
+
+	// Calls that can't be authenticated are not made.
+	sent := len(mock.receivedCalls())
+	for name, tc := range map[string]struct {
+		auth      AuthMethod
+		parsedABI abi.ABI
+		method    string
+		args      []interface{}
+	}{
+		"no auth":             {nil, guarded, "secret", nil},
+		"no signer":           {WithSignedQuery(nil), guarded, "secret", nil},
+		"no token argument":   {WithSiweToken([]byte("token")), guarded, "secret", nil},
+		"empty token":         {WithSiweToken(nil), siwe, "getSecret", []interface{}{big.NewInt(7)}},
+		"other argument":      {WithSiweToken([]byte("token"), WithTokenArgument("token")), siwe, "getSecret", []interface{}{big.NewInt(7)}},
+		"no token source":     {WithAuto(signer), siwe, "getSecret", []interface{}{big.NewInt(7)}},
+		"failed token":        {WithAuto(signer, WithTokenSource(&tokenSource{failed: true})), siwe, "getSecret", []interface{}{big.NewInt(7)}},
+		"not SiweAuth":        {WithAuto(signer, WithTokenSource(tokens), WithSiweAuthDetector(func(abi.ABI) bool { return false })), siwe, "getSecret", []interface{}{big.NewInt(7)}},
+		"auto without signer": {WithAuto(nil, WithTokenSource(tokens)), guarded, "secret", nil},
+	} {
+		if _, err := b.PackAndAuthenticatedCall(ctx, contract, tc.auth, tc.parsedABI, tc.method, tc.args...); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("%s: expected ErrUnauthenticated, got %v", name, err)
+		}
+	}
+	if n := len(mock.receivedCalls()); n != sent {
+		t.Fatalf("expected no call to be made, got %d", n-sent)
+	}
+}
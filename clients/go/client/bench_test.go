@@ -0,0 +1,218 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/mockgateway"
+)
+
+// The overhead benchmarks compare a wrapped client with a raw ethclient
+// making the same requests to the in-process mock gateway, over loopback:
+//
+//	go test -run '^$' -bench Overhead -benchmem
+//
+// Besides ns/op they report the time per request spent in each stage:
+// keyfetch-ns/op fetching the runtime key, amortized over the run; leash-ns/op
+// fetching the head and nonce a signed query is bound to; rpc-ns/op waiting
+// for the eth_call or eth_sendRawTransaction itself; and client-ns/op, the
+// rest, i.e. signing, encryption, decryption and bookkeeping. allocs/op
+// includes the gateway's, which decrypts what the wrapped client sends.
+//
+// On loopback, an encrypted call takes about twice as long as a raw one,
+// nearly all of it spent by the gateway decrypting and encrypting; the client
+// adds under 10µs. A signed query takes about ten times as long as a raw
+// call: the two round trips fetching its leash, and EIP-712 hashing and a
+// secp256k1 signature, which take some 300µs and 3000 allocations. A
+// transaction takes under twice as long as a raw one, the client adding
+// encryption and nonce tracking to the signature a raw client makes anyway.
+// Against a remote gateway, the extra round trips of signed queries dominate.
+
+// stageTimer is an http.RoundTripper timing the JSON-RPC requests it sends by
+// method.
+type stageTimer struct {
+	next http.RoundTripper
+
+	mu    sync.Mutex
+	spent map[string]time.Duration
+	// setupKeyFetch is the time spent fetching the runtime key before the
+	// benchmark loop, e.g. when wrapping the client.
+	setupKeyFetch time.Duration
+}
+
+func (s *stageTimer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	var msg struct {
+		Method string `json:"method"`
+	}
+	_ = json.Unmarshal(body, &msg)
+
+	start := time.Now()
+	resp, err := s.next.RoundTrip(req)
+	if err == nil {
+		// Read the response here, so that it is timed as well.
+		var raw []byte
+		raw, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(raw))
+	}
+	s.mu.Lock()
+	s.spent[msg.Method] += time.Since(start)
+	s.mu.Unlock()
+	return resp, err
+}
+
+// start starts timing the benchmark loop. Only the time spent fetching the
+// runtime key so far is kept, to be amortized over the loop.
+func (s *stageTimer) start(b *testing.B) {
+	s.mu.Lock()
+	s.setupKeyFetch += s.spent["oasis_callDataPublicKey"]
+	s.spent = make(map[string]time.Duration)
+	s.mu.Unlock()
+	b.ReportAllocs()
+	b.ResetTimer()
+}
+
+// report reports the time spent per request in each stage, given the
+// methods of the request itself.
+func (s *stageTimer) report(b *testing.B, methods ...string) {
+	b.StopTimer()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	perOp := func(d time.Duration) float64 {
+		return float64(d.Nanoseconds()) / float64(b.N)
+	}
+	var rpcTime, total time.Duration
+	for _, method := range methods {
+		rpcTime += s.spent[method]
+	}
+	for _, spent := range s.spent {
+		total += spent
+	}
+	leash := s.spent["eth_getBlockByNumber"] + s.spent["eth_getTransactionCount"]
+	b.ReportMetric(perOp(s.setupKeyFetch+s.spent["oasis_callDataPublicKey"]), "keyfetch-ns/op")
+	b.ReportMetric(perOp(leash), "leash-ns/op")
+	b.ReportMetric(perOp(rpcTime), "rpc-ns/op")
+	b.ReportMetric(perOp(b.Elapsed()-total), "client-ns/op")
+}
+
+// dialBench connects to gw through a new stage timer.
+func dialBench(b *testing.B, gw *mockgateway.Gateway) (*ethclient.Client, *stageTimer) {
+	timer := &stageTimer{next: http.DefaultTransport, spent: make(map[string]time.Duration)}
+	c, err := rpc.DialOptions(context.Background(), gw.URL, rpc.WithHTTPClient(&http.Client{Transport: timer}))
+	if err != nil {
+		b.Fatalf("failed to dial mock gateway: %v", err)
+	}
+	client := ethclient.NewClient(c)
+	b.Cleanup(client.Close)
+	return client, timer
+}
+
+func BenchmarkOverheadCallContract(b *testing.B) {
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	data := make([]byte, 68) // A typical ABI call with two arguments.
+	key, _ := crypto.GenerateKey()
+	signer := sapphire.NewPrivateKeySigner(key)
+
+	for _, bc := range []struct {
+		name    string
+		wrapped bool
+		from    common.Address
+	}{
+		{name: "raw"},
+		{name: "wrapped", wrapped: true},
+		{name: "signed", wrapped: true, from: signer.Address()},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			gw := mockgateway.New(b)
+			gw.OnCall(echo)
+			client, timer := dialBench(b, gw)
+			var caller ethereum.ContractCaller = client
+			if bc.wrapped {
+				wrapped, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(signer)))
+				if err != nil {
+					b.Fatalf("failed to wrap client: %v", err)
+				}
+				caller = wrapped
+			}
+			msg := ethereum.CallMsg{From: bc.from, To: &to, Data: data}
+			ctx := context.Background()
+
+			timer.start(b)
+			for i := 0; i < b.N; i++ {
+				if _, err := caller.CallContract(ctx, msg, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+			timer.report(b, "eth_call")
+		})
+	}
+}
+
+func BenchmarkOverheadSendTransaction(b *testing.B) {
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	data := make([]byte, 68)
+	key, _ := crypto.GenerateKey()
+	signer := sapphire.NewPrivateKeySigner(key)
+	gasPrice := big.NewInt(mockgateway.DefaultGasPrice)
+
+	for _, bc := range []struct {
+		name    string
+		wrapped bool
+	}{
+		{name: "raw"},
+		{name: "wrapped", wrapped: true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			gw := mockgateway.New(b)
+			client, timer := dialBench(b, gw)
+			chainSigner := types.LatestSignerForChainID(gw.ChainID())
+			var (
+				transactor bind.ContractTransactor = client
+				sign                               = func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) {
+					return types.SignTx(tx, chainSigner, key)
+				}
+			)
+			if bc.wrapped {
+				wrapped, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(signer)))
+				if err != nil {
+					b.Fatalf("failed to wrap client: %v", err)
+				}
+				transactor, sign = wrapped, wrapped.Transactor(signer.Address()).Signer
+			}
+			ctx := context.Background()
+
+			timer.start(b)
+			for i := 0; i < b.N; i++ {
+				tx, err := sign(signer.Address(), types.NewTransaction(uint64(i), to, nil, 100_000, gasPrice, data))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err = transactor.SendTransaction(ctx, tx); err != nil {
+					b.Fatal(err)
+				}
+			}
+			timer.report(b, "eth_sendRawTransaction")
+		})
+	}
+}
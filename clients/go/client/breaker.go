@@ -0,0 +1,223 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the gateway while the
+// circuit breaker configured with WithCircuitBreaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open: gateway unavailable")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets all requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails all requests with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe requests through to
+	// find out whether the gateway recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerPolicy configures the circuit breaker around requests to the
+// gateway. Zero fields take their value from DefaultCircuitBreakerPolicy.
+//
+// Only transport failures, i.e. the errors IsTemporary reports, and requests
+// timing out on the configured Timeouts count as failures. Errors returned by
+// a working gateway, such as reverts, do not.
+type CircuitBreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failed requests, counting
+	// every retry, that opens the breaker.
+	FailureThreshold int
+	// OpenDuration is how long the breaker stays open before letting probes
+	// through.
+	OpenDuration time.Duration
+	// HalfOpenProbes is the number of probe requests let through at once
+	// while half-open. The breaker closes once as many probes succeeded, and
+	// opens again as soon as one fails.
+	HalfOpenProbes int
+	// OnStateChange, if set, is called on every state transition.
+	OnStateChange func(from, to CircuitState)
+}
+
+// DefaultCircuitBreakerPolicy opens the breaker after 5 consecutive failures
+// and probes the gateway again after 30 seconds.
+var DefaultCircuitBreakerPolicy = CircuitBreakerPolicy{
+	FailureThreshold: 5,
+	OpenDuration:     30 * time.Second,
+	HalfOpenProbes:   1,
+}
+
+func (p CircuitBreakerPolicy) withDefaults() CircuitBreakerPolicy {
+	if p.FailureThreshold == 0 {
+		p.FailureThreshold = DefaultCircuitBreakerPolicy.FailureThreshold
+	}
+	if p.OpenDuration == 0 {
+		p.OpenDuration = DefaultCircuitBreakerPolicy.OpenDuration
+	}
+	if p.HalfOpenProbes == 0 {
+		p.HalfOpenProbes = DefaultCircuitBreakerPolicy.HalfOpenProbes
+	}
+	return p
+}
+
+// circuitBreaker fails requests fast while the gateway is unavailable.
+type circuitBreaker struct {
+	policy CircuitBreakerPolicy
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	// generation counts the state transitions, so that requests allowed in
+	// an earlier state can be told apart.
+	generation uint64
+	// probes and successes count the probes in flight and succeeded in the
+	// half-open state.
+	probes    int
+	successes int
+}
+
+func newCircuitBreaker(policy CircuitBreakerPolicy) *circuitBreaker {
+	return &circuitBreaker{
+		policy: policy.withDefaults(),
+		now:    time.Now,
+	}
+}
+
+// allow reports whether a request may be sent, returning ErrCircuitOpen if
+// not. Every allowed request must be followed by a call to record with the
+// generation returned.
+func (cb *circuitBreaker) allow() (uint64, error) {
+	if cb == nil {
+		return 0, nil
+	}
+	cb.mu.Lock()
+	from := cb.advance()
+	err := error(nil)
+	switch cb.state {
+	case CircuitOpen:
+		err = ErrCircuitOpen
+	case CircuitHalfOpen:
+		if cb.probes >= cb.policy.HalfOpenProbes {
+			err = ErrCircuitOpen
+		} else {
+			cb.probes++
+		}
+	}
+	to, generation := cb.state, cb.generation
+	cb.mu.Unlock()
+	cb.notify(from, to)
+	return generation, err
+}
+
+// record records the outcome of a request allowed in generation. parent is
+// the caller's context, whose cancellation does not count against the
+// gateway. Outcomes of requests allowed before the last state transition are
+// ignored, e.g. so that a request sent while closed can't pass for a probe.
+func (cb *circuitBreaker) record(parent context.Context, generation uint64, err error) {
+	if cb == nil {
+		return
+	}
+	failed := IsTemporary(err) && !(errors.Is(err, context.DeadlineExceeded) && parent.Err() != nil)
+	neutral := !failed && err != nil && parent.Err() != nil
+
+	cb.mu.Lock()
+	if generation != cb.generation {
+		cb.mu.Unlock()
+		return
+	}
+	from := cb.state
+	switch cb.state {
+	case CircuitClosed:
+		switch {
+		case failed:
+			cb.failures++
+			if cb.failures >= cb.policy.FailureThreshold {
+				cb.open()
+			}
+		case !neutral:
+			cb.failures = 0
+		}
+	case CircuitHalfOpen:
+		cb.probes--
+		switch {
+		case failed:
+			cb.open()
+		case !neutral:
+			cb.successes++
+			if cb.successes >= cb.policy.HalfOpenProbes {
+				cb.state = CircuitClosed
+				cb.failures = 0
+				cb.generation++
+			}
+		}
+	}
+	to := cb.state
+	cb.mu.Unlock()
+	cb.notify(from, to)
+}
+
+// current returns the state of the breaker.
+func (cb *circuitBreaker) current() CircuitState {
+	if cb == nil {
+		return CircuitClosed
+	}
+	cb.mu.Lock()
+	from := cb.advance()
+	to := cb.state
+	cb.mu.Unlock()
+	cb.notify(from, to)
+	return to
+}
+
+// advance moves an open breaker whose open duration elapsed to half-open and
+// returns the state before. cb.mu must be held.
+func (cb *circuitBreaker) advance() CircuitState {
+	from := cb.state
+	if cb.state == CircuitOpen && cb.now().Sub(cb.openedAt) >= cb.policy.OpenDuration {
+		cb.state = CircuitHalfOpen
+		cb.probes, cb.successes = 0, 0
+		cb.generation++
+	}
+	return from
+}
+
+// open opens the breaker. cb.mu must be held.
+func (cb *circuitBreaker) open() {
+	cb.state = CircuitOpen
+	cb.openedAt = cb.now()
+	cb.failures = 0
+	cb.generation++
+}
+
+func (cb *circuitBreaker) notify(from, to CircuitState) {
+	if from != to && cb.policy.OnStateChange != nil {
+		cb.policy.OnStateChange(from, to)
+	}
+}
+
+// CircuitState returns the state of the circuit breaker configured with
+// WithCircuitBreaker, or CircuitClosed if there is none.
+func (b *WrappedBackend) CircuitState() CircuitState {
+	return b.mw.breaker.current()
+}
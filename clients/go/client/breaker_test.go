@@ -0,0 +1,240 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// breakerClock is a manually advanced clock for circuit breakers.
+type breakerClock struct {
+	t time.Time
+}
+
+func (c *breakerClock) now() time.Time {
+	return c.t
+}
+
+// newBreakerBackend wraps rt with a circuit breaker using a manual clock, and
+// records the breaker's state transitions.
+func newBreakerBackend(t *testing.T, rt *rpcTransport, policy CircuitBreakerPolicy, opts ...Option) (*WrappedBackend, *breakerClock, *[]string) {
+	var transitions []string
+	policy.OnStateChange = func(from, to CircuitState) {
+		transitions = append(transitions, fmt.Sprintf("%s->%s", from, to))
+	}
+	b, err := WrapClient(dialTransport(t, rt), nil, append([]Option{WithCircuitBreaker(policy)}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	clock := &breakerClock{t: time.Unix(1_700_000_000, 0)}
+	b.mw.breaker.now = clock.now
+	return b, clock, &transitions
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	addr := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	rt := newRPCTransport()
+	b, clock, transitions := newBreakerBackend(t, rt, CircuitBreakerPolicy{
+		FailureThreshold: 3,
+		OpenDuration:     10 * time.Second,
+		HalfOpenProbes:   2,
+	})
+
+	rt.failNext(3, http.StatusServiceUnavailable)
+	for i := 0; i < 3; i++ {
+		if _, err := b.PendingNonceAt(ctx, addr); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("request %d: expected the gateway error, got %v", i, err)
+		}
+	}
+	if got := b.CircuitState(); got != CircuitOpen {
+		t.Fatalf("expected the breaker to open, got %s", got)
+	}
+
+	sent := len(rt.recorded("eth_getTransactionCount"))
+	if _, err := b.PendingNonceAt(ctx, addr); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if n := len(rt.recorded("eth_getTransactionCount")); n != sent {
+		t.Fatalf("open breaker let a request through")
+	}
+
+	clock.t = clock.t.Add(10 * time.Second)
+	if got := b.CircuitState(); got != CircuitHalfOpen {
+		t.Fatalf("expected the breaker to be half-open, got %s", got)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := b.PendingNonceAt(ctx, addr); err != nil {
+			t.Fatalf("probe %d failed: %v", i, err)
+		}
+	}
+	if got := b.CircuitState(); got != CircuitClosed {
+		t.Fatalf("expected the breaker to close after the probes, got %s", got)
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if fmt.Sprint(*transitions) != fmt.Sprint(want) {
+		t.Fatalf("unexpected transitions %v, want %v", *transitions, want)
+	}
+}
+
+func TestCircuitBreakerFailedProbe(t *testing.T) {
+	ctx := context.Background()
+	addr := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	rt := newRPCTransport()
+	b, clock, transitions := newBreakerBackend(t, rt, CircuitBreakerPolicy{FailureThreshold: 1, OpenDuration: time.Second})
+
+	rt.failNext(2, http.StatusBadGateway)
+	_, _ = b.PendingNonceAt(ctx, addr)
+	clock.t = clock.t.Add(time.Second)
+	if _, err := b.PendingNonceAt(ctx, addr); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the probe to reach the gateway and fail, got %v", err)
+	}
+	if got := b.CircuitState(); got != CircuitOpen {
+		t.Fatalf("expected a failed probe to reopen the breaker, got %s", got)
+	}
+	want := []string{"closed->open", "open->half-open", "half-open->open"}
+	if fmt.Sprint(*transitions) != fmt.Sprint(want) {
+		t.Fatalf("unexpected transitions %v, want %v", *transitions, want)
+	}
+}
+
+func TestCircuitBreakerProbeLimit(t *testing.T) {
+	cb := newCircuitBreaker(CircuitBreakerPolicy{FailureThreshold: 1, OpenDuration: time.Second, HalfOpenProbes: 1})
+	clock := &breakerClock{t: time.Unix(1_700_000_000, 0)}
+	cb.now = clock.now
+	ctx := context.Background()
+
+	generation, err := cb.allow()
+	if err != nil {
+		t.Fatalf("closed breaker rejected a request: %v", err)
+	}
+	cb.record(ctx, generation, io.ErrUnexpectedEOF)
+	clock.t = clock.t.Add(time.Second)
+	if generation, err = cb.allow(); err != nil {
+		t.Fatalf("half-open breaker rejected the probe: %v", err)
+	}
+	if _, err = cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("half-open breaker let a second probe through: %v", err)
+	}
+
+	// A probe abandoned by its caller frees its slot without closing the breaker.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	cb.record(canceled, generation, context.Canceled)
+	if got := cb.current(); got != CircuitHalfOpen {
+		t.Fatalf("expected the breaker to stay half-open, got %s", got)
+	}
+	if generation, err = cb.allow(); err != nil {
+		t.Fatalf("half-open breaker rejected a new probe: %v", err)
+	}
+	cb.record(ctx, generation, nil)
+	if got := cb.current(); got != CircuitClosed {
+		t.Fatalf("expected the breaker to close, got %s", got)
+	}
+}
+
+func TestCircuitBreakerStaleResults(t *testing.T) {
+	cb := newCircuitBreaker(CircuitBreakerPolicy{FailureThreshold: 1, OpenDuration: time.Second, HalfOpenProbes: 1})
+	clock := &breakerClock{t: time.Unix(1_700_000_000, 0)}
+	cb.now = clock.now
+	ctx := context.Background()
+
+	// A slow request is sent while closed, and another one opens the breaker.
+	slow, _ := cb.allow()
+	failing, _ := cb.allow()
+	cb.record(ctx, failing, io.ErrUnexpectedEOF)
+	clock.t = clock.t.Add(time.Second)
+	probe, err := cb.allow()
+	if err != nil {
+		t.Fatalf("half-open breaker rejected the probe: %v", err)
+	}
+
+	// The slow request succeeding doesn't pass for the probe, nor does it
+	// free the probe's slot.
+	cb.record(ctx, slow, nil)
+	if got := cb.current(); got != CircuitHalfOpen {
+		t.Fatalf("expected a stale success to leave the breaker half-open, got %s", got)
+	}
+	if _, err = cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("half-open breaker let a second probe through: %v", err)
+	}
+	cb.record(ctx, probe, io.ErrUnexpectedEOF)
+	if got := cb.current(); got != CircuitOpen {
+		t.Fatalf("expected the failed probe to reopen the breaker, got %s", got)
+	}
+
+	// Nor does a stale failure reopen a breaker that closed since.
+	clock.t = clock.t.Add(time.Second)
+	probe, _ = cb.allow()
+	cb.record(ctx, probe, nil)
+	cb.record(ctx, slow, io.ErrUnexpectedEOF)
+	if got := cb.current(); got != CircuitClosed {
+		t.Fatalf("expected a stale failure to leave the breaker closed, got %s", got)
+	}
+}
+
+func TestCircuitBreakerIgnoresGatewayErrors(t *testing.T) {
+	ctx := context.Background()
+	addr := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	rt := newRPCTransport()
+	rt.handle("eth_getTransactionCount", func([]json.RawMessage) (interface{}, error) {
+		return nil, errors.New("execution reverted")
+	})
+	b, _, _ := newBreakerBackend(t, rt, CircuitBreakerPolicy{FailureThreshold: 2})
+
+	for i := 0; i < 5; i++ {
+		if _, err := b.PendingNonceAt(ctx, addr); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected the gateway error, got %v", err)
+		}
+	}
+
+	// Neither do requests abandoned by the caller.
+	rt.block("eth_getTransactionCount")
+	for i := 0; i < 3; i++ {
+		canceled, cancel := context.WithTimeout(ctx, time.Millisecond)
+		_, _ = b.PendingNonceAt(canceled, addr)
+		cancel()
+	}
+	if got := b.CircuitState(); got != CircuitClosed {
+		t.Fatalf("expected the breaker to stay closed, got %s", got)
+	}
+
+	// Requests timing out on the client's own timeouts do count.
+	b.mw.timeouts = &Timeouts{Call: time.Millisecond}
+	for i := 0; i < 2; i++ {
+		_, _ = b.PendingNonceAt(ctx, addr)
+	}
+	if got := b.CircuitState(); got != CircuitOpen {
+		t.Fatalf("expected timeouts to open the breaker, got %s", got)
+	}
+}
+
+func TestCircuitBreakerRetries(t *testing.T) {
+	ctx := context.Background()
+	addr := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	rt := newRPCTransport()
+	b, _, _ := newBreakerBackend(t, rt, CircuitBreakerPolicy{FailureThreshold: 2},
+		WithRetry(RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}))
+
+	rt.failNext(5, http.StatusServiceUnavailable)
+	_, err := b.PendingNonceAt(ctx, addr)
+	var httpErr rpc.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the last gateway error, got %v", err)
+	}
+	if n := len(rt.recorded("eth_getTransactionCount")); n != 2 {
+		t.Fatalf("expected the breaker to stop retries after 2 attempts, got %d", n)
+	}
+	if _, err = b.PendingNonceAt(ctx, addr); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+}
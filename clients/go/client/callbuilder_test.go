@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// signedBy returns the account that signed pack as a query with the given fields.
+func signedBy(t *testing.T, pack *evm.SignedCallDataPack, domain sapphire.SignedCallDomain, from common.Address, to *common.Address, gasLimit uint64, gasPrice, value *big.Int, data []byte) common.Address {
+	digest, err := sapphire.SignedCallDigest(domain.SignableCall(0x5afd, from, to, gasLimit, gasPrice, value, data, pack.Leash))
+	if err != nil {
+		t.Fatalf("failed to hash query: %v", err)
+	}
+	sig := append([]byte(nil), pack.Signature...)
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(digest[:], sig)
+	if err != nil {
+		return common.Address{}
+	}
+	return crypto.PubkeyToAddress(*pub)
+}
+
+func TestNewSignedCall(t *testing.T) {
+	ctx := context.Background()
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	data := []byte{0xe2, 0x1f, 0x37, 0xce}
+	leash := evm.Leash{Nonce: 3, BlockNumber: 50, BlockHash: common.HexToHash("0x01").Bytes(), BlockRange: sapphire.DefaultBlockRange}
+	mock := newMockBackend()
+	mock.nonces[signer.Address()] = 7
+
+	t.Run("defaults", func(t *testing.T) {
+		pack, err := sapphire.NewSignedCall(ctx, signer, big.NewInt(0x5afd), &to, data, sapphire.WithLeash(leash))
+		if err != nil {
+			t.Fatalf("NewSignedCall failed: %v", err)
+		}
+		if signedBy(t, pack, sapphire.DefaultSignedCallDomain, signer.Address(), &to, sapphire.DefaultGasLimit, big.NewInt(sapphire.DefaultGasPrice), nil, data) != signer.Address() {
+			t.Fatalf("query not signed with the default caller, gas limit and gas price")
+		}
+		if pack.Leash.Nonce != leash.Nonce || pack.Leash.BlockNumber != leash.BlockNumber {
+			t.Fatalf("unexpected leash %+v", pack.Leash)
+		}
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		caller := common.HexToAddress("0x1111111111111111111111111111111111111111")
+		domain := sapphire.SignedCallDomain{Name: "test", Version: "2"}
+		pack, err := sapphire.NewSignedCall(ctx, signer, big.NewInt(0x5afd), &to, data,
+			sapphire.WithLeash(leash),
+			sapphire.WithGasLimit(1), sapphire.WithGasLimit(50_000),
+			sapphire.WithGasPrice(big.NewInt(5)),
+			sapphire.WithValue(big.NewInt(9)),
+			sapphire.WithCaller(caller),
+			sapphire.WithCallDomain(domain),
+		)
+		if err != nil {
+			t.Fatalf("NewSignedCall failed: %v", err)
+		}
+		// The signature is over the caller given, even if the key is not its.
+		if signedBy(t, pack, domain, caller, &to, 50_000, big.NewInt(5), big.NewInt(9), data) != signer.Address() {
+			t.Fatalf("options not applied, or not the last of them")
+		}
+	})
+
+	t.Run("leash precedence", func(t *testing.T) {
+		pack, err := sapphire.NewSignedCall(ctx, signer, big.NewInt(0x5afd), &to, data, sapphire.WithLeash(leash), WithAutoLeash(mock))
+		if err != nil {
+			t.Fatalf("NewSignedCall failed: %v", err)
+		}
+		if pack.Leash.Nonce != 7 || pack.Leash.BlockNumber != 99 || common.BytesToHash(pack.Leash.BlockHash) != mock.head.ParentHash {
+			t.Fatalf("expected a leash on the latest block, got %+v", pack.Leash)
+		}
+		pack, err = sapphire.NewSignedCall(ctx, signer, big.NewInt(0x5afd), &to, data, WithAutoLeash(mock), sapphire.WithLeash(leash))
+		if err != nil {
+			t.Fatalf("NewSignedCall failed: %v", err)
+		}
+		if pack.Leash.Nonce != leash.Nonce {
+			t.Fatalf("expected the explicit leash, got %+v", pack.Leash)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if _, err := sapphire.NewSignedCall(ctx, signer, big.NewInt(0x5afd), &to, data); !errors.Is(err, sapphire.ErrInvalidSignedCall) {
+			t.Fatalf("expected a missing leash to fail, got %v", err)
+		}
+		bare := rsvSigner{signer.SignRSV}
+		if _, err := sapphire.NewSignedCall(ctx, bare, big.NewInt(0x5afd), &to, data, sapphire.WithLeash(leash)); !errors.Is(err, sapphire.ErrNoSigner) {
+			t.Fatalf("expected a missing caller to fail, got %v", err)
+		}
+		if _, err := sapphire.NewSignedCall(ctx, bare, big.NewInt(0x5afd), &to, data, sapphire.WithLeash(leash), sapphire.WithCaller(signer.Address())); err != nil {
+			t.Fatalf("NewSignedCall failed with an explicit caller: %v", err)
+		}
+		if _, err := sapphire.NewSignedCall(ctx, signer, big.NewInt(0x5afd), &to, data, sapphire.WithLeash(leash), sapphire.WithGasLimit(0)); !errors.Is(err, sapphire.ErrInvalidSignedCall) {
+			t.Fatalf("expected a zero gas limit to fail, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// CallError is returned by wrapped clients for failed calls, estimates,
+// transactions and key fetches, and says which request failed. It unwraps to
+// the error the request failed with.
+type CallError struct {
+	// Op is the JSON-RPC method, e.g. "eth_call".
+	Op string
+	// Target is the called contract or the transaction's recipient, zero if
+	// there is none.
+	Target common.Address
+	// Method is the ABI method, if the request was made by an ABI helper such
+	// as PackAndCall.
+	Method string
+	// Block is the block a call was made at, nil for the latest one.
+	Block *big.Int
+	Err   error
+}
+
+// Error returns e.g. "eth_call getSecret to 0x595C…8883 at block 12: execution
+// reverted".
+func (e *CallError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Op)
+	if e.Method != "" {
+		b.WriteString(" " + e.Method)
+	}
+	if e.Target != (common.Address{}) {
+		hex := e.Target.Hex()
+		b.WriteString(" to " + hex[:6] + "…" + hex[len(hex)-4:])
+	}
+	if e.Block != nil {
+		b.WriteString(" at block " + e.Block.String())
+	}
+	b.WriteString(": " + e.Err.Error())
+	return b.String()
+}
+
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// callContext describes what a request is for.
+type callContext struct {
+	op     string
+	target common.Address
+	method string
+	block  *big.Int
+}
+
+// newCallContext returns the context of a request of op to to at block, with
+// the ABI method ctx carries.
+func newCallContext(ctx context.Context, op string, to *common.Address, block *big.Int) callContext {
+	call := callContext{op: op, method: abiMethodFromContext(ctx), block: block}
+	if to != nil {
+		call.target = *to
+	}
+	return call
+}
+
+// wrap wraps err in a CallError, unless it is nil or already one.
+func (c callContext) wrap(err error) error {
+	var callErr *CallError
+	if err == nil || errors.As(err, &callErr) {
+		return err
+	}
+	return &CallError{Op: c.op, Target: c.target, Method: c.method, Block: c.block, Err: err}
+}
+
+type abiMethodContextKey struct{}
+
+// contextWithABIMethod returns a copy of ctx carrying the ABI method the
+// requests made with it are for.
+func contextWithABIMethod(ctx context.Context, method string) context.Context {
+	return context.WithValue(ctx, abiMethodContextKey{}, method)
+}
+
+// abiMethodFromContext returns the ABI method carried by ctx, if any.
+func abiMethodFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	method, _ := ctx.Value(abiMethodContextKey{}).(string)
+	return method
+}
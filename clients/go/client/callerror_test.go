@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+func TestCallError(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(testContractABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	key, _ := crypto.GenerateKey()
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	rt := newRPCTransport()
+	for _, method := range []string{"eth_call", "eth_estimateGas", "eth_sendRawTransaction"} {
+		rt.handle(method, func([]json.RawMessage) (interface{}, error) {
+			return nil, &rpcError{Code: -32000, Message: "gateway unavailable"}
+		})
+	}
+	rec := &debugRecorder{}
+	b, err := WrapClient(dialTransport(t, rt), nil, WithDebugHook(rec.hook), WithKeyring(sapphire.NewKeyring(signer)))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	rt.handle(KeySourceRPC, func([]json.RawMessage) (interface{}, error) {
+		return nil, &rpcError{Code: -32000, Message: "key manager unavailable"}
+	})
+
+	tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{To: &to, Gas: 21_000, GasPrice: big.NewInt(sapphire.DefaultGasPrice)}), types.LatestSignerForChainID(big.NewInt(0x5afd)), key)
+	txOpts := b.Transactor(signer.Address())
+	txOpts.GasLimit = 100_000
+
+	for _, tc := range []struct {
+		name     string
+		do       func() error
+		expected CallError
+	}{
+		{
+			"call",
+			func() error {
+				_, err := b.CallContract(ctx, ethereum.CallMsg{To: &to, Data: TestData}, big.NewInt(12))
+				return err
+			},
+			CallError{Op: "eth_call", Target: to, Block: big.NewInt(12)},
+		},
+		{
+			"abi call",
+			func() error {
+				_, err := b.PackAndCall(ctx, to, parsed, "pair")
+				return err
+			},
+			CallError{Op: "eth_call", Target: to, Method: "pair"},
+		},
+		{
+			"estimate",
+			func() error {
+				_, err := b.EstimateGas(ctx, ethereum.CallMsg{To: &to, Data: TestData})
+				return err
+			},
+			CallError{Op: "eth_estimateGas", Target: to},
+		},
+		{
+			"send",
+			func() error {
+				return b.SendTransaction(ctx, tx)
+			},
+			CallError{Op: "eth_sendRawTransaction", Target: to},
+		},
+		{
+			"abi send",
+			func() error {
+				_, err := b.PackAndTransact(txOpts, to, parsed, "set", big.NewInt(1))
+				return err
+			},
+			CallError{Op: "eth_sendRawTransaction", Target: to, Method: "set"},
+		},
+		{
+			"key fetch",
+			func() error {
+				return b.RefreshCipher(ctx)
+			},
+			CallError{Op: KeySourceRPC},
+		},
+	} {
+		err := tc.do()
+		var callErr *CallError
+		if !errors.As(err, &callErr) {
+			t.Errorf("%s: expected a CallError, got %v", tc.name, err)
+			continue
+		}
+		if callErr.Op != tc.expected.Op || callErr.Target != tc.expected.Target || callErr.Method != tc.expected.Method || callErr.Block.String() != tc.expected.Block.String() {
+			t.Errorf("%s: expected %s %s %q at %v, got %s %s %q at %v", tc.name, tc.expected.Op, tc.expected.Target.Hex(), tc.expected.Method, tc.expected.Block, callErr.Op, callErr.Target.Hex(), callErr.Method, callErr.Block)
+		}
+		var rpcErr rpc.Error
+		if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != -32000 {
+			t.Errorf("%s: expected the CallError to wrap the gateway error, got %v", tc.name, err)
+		}
+	}
+
+	var found bool
+	for _, ev := range rec.recorded() {
+		if ev.Method == "eth_call" && ev.ABIMethod == "pair" {
+			found = ev.Target == to && ev.Err != nil
+		}
+	}
+	if !found {
+		t.Fatalf("expected the debug hook to receive the call context")
+	}
+}
+
+func TestCallErrorString(t *testing.T) {
+	err := &CallError{
+		Op:     "eth_call",
+		Target: common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883"),
+		Method: "getSecret",
+		Block:  big.NewInt(12),
+		Err:    sapphire.ErrCallFailed,
+	}
+	if expected := "eth_call getSecret to 0x595C…8883 at block 12: " + sapphire.ErrCallFailed.Error(); err.Error() != expected {
+		t.Fatalf("expected %q, got %q", expected, err.Error())
+	}
+	if !errors.Is(err, sapphire.ErrCallFailed) {
+		t.Fatalf("expected the CallError to unwrap")
+	}
+	if err := (&CallError{Op: KeySourceRPC, Err: sapphire.ErrKeyFetchFailed}).Error(); err != KeySourceRPC+": "+sapphire.ErrKeyFetchFailed.Error() {
+		t.Fatalf("unexpected key fetch error %q", err)
+	}
+}
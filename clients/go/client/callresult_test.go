@@ -0,0 +1,110 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	"github.com/oasisprotocol/deoxysii"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+func TestCallResultError(t *testing.T) {
+	pair := sapphire.Curve25519KeyPair{
+		PublicKey: x25519.PublicKey(common.Hex2Bytes("3046db3fa70ce605457dc47c48837ebd8bd0a26abfde5994d033e1ced68e2576")),
+		SecretKey: x25519.PrivateKey(common.Hex2Bytes("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")),
+	}
+	encrypted, err := sapphire.NewX25519DeoxysIICipher(&pair, &pair.PublicKey, 42)
+	if err != nil {
+		t.Fatalf("could not init deoxysii cipher: %v", err)
+	}
+	// encryptResult returns result as the runtime returns the results of
+	// encrypted calls.
+	encryptResult := func(result types.CallResult, tamper bool) []byte {
+		data, nonce := encrypted.Encrypt(cbor.Marshal(result))
+		if tamper {
+			data[0] ^= 1
+		}
+		return cbor.Marshal(types.CallResult{Unknown: cbor.Marshal(types.ResultEnvelopeX25519DeoxysII{
+			Nonce: [deoxysii.NonceSize]byte(nonce),
+			Data:  data,
+		})})
+	}
+	reverted := &types.FailedCallResult{Module: "evm", Code: evmRevertedCode, Message: "reverted: " + base64.StdEncoding.EncodeToString(revertData(t, "not the owner"))}
+	outOfGas := &types.FailedCallResult{Module: "core", Code: 12, Message: "out of gas"}
+	plainInfo := sapphire.CipherInfo{Format: types.CallFormatPlain}
+	encryptedInfo := sapphire.CipherInfo{Format: types.CallFormatEncryptedX25519DeoxysII, Epoch: 42}
+
+	for _, tc := range []struct {
+		name      string
+		cipher    sapphire.Cipher
+		response  []byte
+		variant   sapphire.CallResultVariant
+		inner     sapphire.CallResultVariant
+		info      sapphire.CipherInfo
+		module    *types.FailedCallResult
+		reverted  bool
+		malformed bool
+	}{
+		{"plain revert", sapphire.NewPlainCipher(), cbor.Marshal(types.CallResult{Failed: reverted}), sapphire.CallResultFailed, sapphire.CallResultUndecodable, plainInfo, reverted, true, false},
+		{"plain module error", sapphire.NewPlainCipher(), cbor.Marshal(types.CallResult{Failed: outOfGas}), sapphire.CallResultFailed, sapphire.CallResultUndecodable, plainInfo, outOfGas, false, false},
+		{"plain malformed", sapphire.NewPlainCipher(), []byte("not cbor"), sapphire.CallResultUndecodable, sapphire.CallResultUndecodable, plainInfo, nil, false, true},
+		{"outer module error", encrypted, cbor.Marshal(types.CallResult{Failed: outOfGas}), sapphire.CallResultFailed, sapphire.CallResultUndecodable, encryptedInfo, outOfGas, false, false},
+		{"inner revert", encrypted, encryptResult(types.CallResult{Failed: reverted}, false), sapphire.CallResultUnknown, sapphire.CallResultFailed, encryptedInfo, reverted, true, false},
+		{"inner module error", encrypted, encryptResult(types.CallResult{Failed: outOfGas}, false), sapphire.CallResultUnknown, sapphire.CallResultFailed, encryptedInfo, outOfGas, false, false},
+		{"tampered", encrypted, encryptResult(types.CallResult{Failed: reverted}, true), sapphire.CallResultUnknown, sapphire.CallResultUndecodable, encryptedInfo, nil, false, true},
+		{"empty", encrypted, cbor.Marshal(types.CallResult{}), sapphire.CallResultUndecodable, sapphire.CallResultUndecodable, encryptedInfo, nil, false, true},
+	} {
+		raw := common.CopyBytes(tc.response)
+		_, err := tc.cipher.DecryptEncoded(tc.response)
+		var resultErr *sapphire.CallResultError
+		if !errors.As(err, &resultErr) {
+			t.Errorf("%s: expected a CallResultError, got %v", tc.name, err)
+			continue
+		}
+		if !bytes.Equal(resultErr.RawEnvelope, raw) {
+			t.Errorf("%s: expected the raw envelope %x, got %x", tc.name, raw, resultErr.RawEnvelope)
+		}
+		if resultErr.Variant != tc.variant || resultErr.InnerVariant != tc.inner {
+			t.Errorf("%s: expected variants %q/%q, got %q/%q", tc.name, tc.variant, tc.inner, resultErr.Variant, resultErr.InnerVariant)
+		}
+		if resultErr.Cipher != tc.info {
+			t.Errorf("%s: expected cipher %+v, got %+v", tc.name, tc.info, resultErr.Cipher)
+		}
+		if errors.Is(err, sapphire.ErrMalformedEnvelope) != tc.malformed {
+			t.Errorf("%s: expected malformed %t, got %v", tc.name, tc.malformed, err)
+		}
+		switch {
+		case tc.module == nil && resultErr.ModuleError != nil:
+			t.Errorf("%s: unexpected module error %v", tc.name, resultErr.ModuleError)
+		case tc.module != nil && (resultErr.ModuleError == nil || resultErr.ModuleError.Module != tc.module.Module || resultErr.ModuleError.Code != tc.module.Code || resultErr.ModuleError.Message != tc.module.Message):
+			t.Errorf("%s: expected module error %+v, got %+v", tc.name, tc.module, resultErr.ModuleError)
+		}
+		var revert *sapphire.RevertError
+		if (resultErr.RevertError != nil) != tc.reverted || errors.As(err, &revert) != tc.reverted {
+			t.Errorf("%s: expected reverted %t, got %v", tc.name, tc.reverted, resultErr.RevertError)
+		} else if tc.reverted && resultErr.RevertError.Reason != "not the owner" {
+			t.Errorf("%s: expected the revert reason, got %q", tc.name, resultErr.RevertError.Reason)
+		}
+	}
+
+	// Calls return it alongside their context.
+	mock := newMockBackend()
+	mock.callResult = cbor.Marshal(types.CallResult{Failed: reverted})
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	_, err = newMockWrappedBackend(mock, nil).CallContract(context.Background(), ethereum.CallMsg{To: &to, Data: TestData}, nil)
+	var (
+		resultErr *sapphire.CallResultError
+		callErr   *CallError
+	)
+	if !errors.As(err, &resultErr) || !errors.As(err, &callErr) || !bytes.Equal(resultErr.RawEnvelope, mock.callResult) {
+		t.Fatalf("expected the call to fail with a CallResultError, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// Gateway capabilities, named after the JSON-RPC method that provides them.
+const (
+	// CapabilityCallDataPublicKey is required for encrypting calls and transactions.
+	CapabilityCallDataPublicKey = "oasis_callDataPublicKey"
+	// CapabilityDebugTrace is required by TraceCall.
+	CapabilityDebugTrace = "debug_traceCall"
+	// CapabilityGaslessSubmit is required by SubmitGasless. It is served by
+	// gateways that accept runtime transactions for relayers.
+	CapabilityGaslessSubmit = "oasis_sendRawRuntimeTransaction"
+)
+
+// ErrCapabilityUnsupported is returned when an operation requires a
+// capability the gateway does not have.
+type ErrCapabilityUnsupported struct {
+	// Capability is the missing capability, e.g. CapabilityCallDataPublicKey.
+	Capability string
+}
+
+func (e ErrCapabilityUnsupported) Error() string {
+	return fmt.Sprintf("gateway does not support %s", e.Capability)
+}
+
+// Capabilities describes what the gateway supports.
+type Capabilities struct {
+	// CallDataPublicKey is set when the gateway serves the runtime's calldata
+	// public key, i.e. when it is a Sapphire gateway, with either
+	// oasis_callDataPublicKey or the subcall precompile.
+	CallDataPublicKey bool
+	// DebugTrace is set when the gateway exposes debug_traceCall.
+	DebugTrace bool
+	// GaslessSubmit is set when the gateway accepts gasless transactions.
+	GaslessSubmit bool
+}
+
+// rpcMethodNotFound is the JSON-RPC error code for unknown methods.
+const rpcMethodNotFound = -32601
+
+func isMethodNotFound(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr) && rpcErr.ErrorCode() == rpcMethodNotFound
+}
+
+// capabilityCache records the capabilities a gateway was found to lack,
+// either by probing or from failed requests.
+type capabilityCache struct {
+	mu          sync.Mutex
+	probed      bool
+	unsupported map[string]bool
+}
+
+// require fails with ErrCapabilityUnsupported if the capability is known to be missing.
+func (c *capabilityCache) require(capability string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.unsupported[capability] {
+		return ErrCapabilityUnsupported{capability}
+	}
+	return nil
+}
+
+// observe records the outcome of a request that needed the capability and
+// converts method-not-found errors into ErrCapabilityUnsupported.
+func (c *capabilityCache) observe(capability string, err error) error {
+	var unsupported ErrCapabilityUnsupported
+	missing := errors.As(err, &unsupported) && unsupported.Capability == capability
+	if !missing && isMethodNotFound(err) {
+		missing = true
+		err = fmt.Errorf("%w: %v", ErrCapabilityUnsupported{capability}, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case missing:
+		if c.unsupported == nil {
+			c.unsupported = make(map[string]bool)
+		}
+		c.unsupported[capability] = true
+	case err == nil:
+		delete(c.unsupported, capability)
+	}
+	return err
+}
+
+func (c *capabilityCache) snapshot() (Capabilities, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Capabilities{
+		CallDataPublicKey: !c.unsupported[CapabilityCallDataPublicKey],
+		DebugTrace:        !c.unsupported[CapabilityDebugTrace],
+		GaslessSubmit:     !c.unsupported[CapabilityGaslessSubmit],
+	}, c.probed
+}
+
+// Capabilities returns the capabilities of the gateway, probing it on first use.
+func (b *WrappedBackend) Capabilities(ctx context.Context) (Capabilities, error) {
+	if caps, probed := b.caps.snapshot(); probed {
+		return caps, nil
+	}
+	return b.ProbeCapabilities(ctx)
+}
+
+// ProbeCapabilities probes the gateway for its capabilities, replacing those
+// previously recorded.
+//
+// Operations that require a capability the gateway was found to lack fail
+// with ErrCapabilityUnsupported without making a request.
+func (b *WrappedBackend) ProbeCapabilities(ctx context.Context) (Capabilities, error) {
+	if b.client == nil {
+		return Capabilities{}, fmt.Errorf("cannot probe capabilities: %w: not created from an ethclient.Client", sapphire.ErrUnsupportedBackend)
+	}
+	unsupported := make(map[string]bool)
+	for _, capability := range []string{CapabilityCallDataPublicKey, CapabilityDebugTrace, CapabilityGaslessSubmit} {
+		// Called without arguments, methods that exist fail with invalid
+		// params rather than method not found.
+		_, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (json.RawMessage, error) {
+			var res json.RawMessage
+			return res, b.client.Client().CallContext(ctx, &res, capability)
+		})
+		var rpcErr rpc.Error
+		switch {
+		case err == nil:
+		case isMethodNotFound(err) && capability == CapabilityCallDataPublicKey:
+			// The key may still be served by the subcall precompile.
+			_, err = invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (*sapphire.CallDataPublicKey, error) {
+				return fetchSubcallPublicKey(ctx, b.client, nil)
+			})
+			if errors.As(err, &rpcErr) || errors.Is(err, errInvalidSubcall) || errors.Is(err, sapphire.ErrCallFailed) {
+				unsupported[capability] = true
+			} else if err != nil {
+				return Capabilities{}, fmt.Errorf("failed to probe %s: %w", KeySourceSubcall, err)
+			}
+		case isMethodNotFound(err):
+			unsupported[capability] = true
+		case !errors.As(err, &rpcErr):
+			// The gateway could not be reached, so we learned nothing.
+			return Capabilities{}, fmt.Errorf("failed to probe %s: %w", capability, err)
+		}
+	}
+
+	b.caps.mu.Lock()
+	b.caps.probed = true
+	b.caps.unsupported = unsupported
+	b.caps.mu.Unlock()
+	caps, _ := b.caps.snapshot()
+	return caps, nil
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCapabilities(t *testing.T) {
+	ctx := context.Background()
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	t.Run("full gateway", func(t *testing.T) {
+		rt := newRPCTransport()
+		rt.handle("debug_traceCall", func([]json.RawMessage) (interface{}, error) {
+			return nil, errors.New("missing value for required argument 0")
+		})
+		b, err := WrapClient(dialTransport(t, rt), nil)
+		if err != nil {
+			t.Fatalf("failed to wrap client: %v", err)
+		}
+		caps, err := b.Capabilities(ctx)
+		if err != nil {
+			t.Fatalf("failed to probe capabilities: %v", err)
+		}
+		if !caps.CallDataPublicKey || !caps.DebugTrace {
+			t.Fatalf("unexpected capabilities %+v", caps)
+		}
+		if _, err = b.Capabilities(ctx); err != nil || len(rt.recorded("debug_traceCall")) != 1 {
+			t.Fatalf("capabilities should only be probed once")
+		}
+	})
+
+	t.Run("no debug namespace", func(t *testing.T) {
+		rt := newRPCTransport()
+		b, err := WrapClient(dialTransport(t, rt), nil)
+		if err != nil {
+			t.Fatalf("failed to wrap client: %v", err)
+		}
+		caps, err := b.ProbeCapabilities(ctx)
+		if err != nil {
+			t.Fatalf("failed to probe capabilities: %v", err)
+		}
+		if !caps.CallDataPublicKey || caps.DebugTrace {
+			t.Fatalf("unexpected capabilities %+v", caps)
+		}
+
+		_, err = b.TraceCall(ctx, ethereum.CallMsg{To: &to, Data: TestData}, nil, nil)
+		var unsupported ErrCapabilityUnsupported
+		if !errors.As(err, &unsupported) || unsupported.Capability != CapabilityDebugTrace || !errors.Is(err, ErrTracingUnsupported) {
+			t.Fatalf("expected ErrCapabilityUnsupported{%s}, got %v", CapabilityDebugTrace, err)
+		}
+		if got := len(rt.recorded("debug_traceCall")); got != 1 {
+			t.Fatalf("TraceCall should fail without a request, got %d requests", got)
+		}
+	})
+
+	t.Run("plain ethereum node", func(t *testing.T) {
+		rt := newRPCTransport()
+		rt.handle("oasis_callDataPublicKey", nil)
+		_, err := WrapClient(dialTransport(t, rt), nil)
+		if !errors.As(err, &ErrCapabilityUnsupported{}) || !errors.Is(err, ErrCapabilityUnsupported{CapabilityCallDataPublicKey}) {
+			t.Fatalf("expected ErrCapabilityUnsupported{%s}, got %v", CapabilityCallDataPublicKey, err)
+		}
+	})
+
+	t.Run("key endpoint removed", func(t *testing.T) {
+		rt := newRPCTransport()
+		b, err := WrapClient(dialTransport(t, rt), nil)
+		if err != nil {
+			t.Fatalf("failed to wrap client: %v", err)
+		}
+		rt.handle("oasis_callDataPublicKey", nil)
+		for i := 0; i < 2; i++ {
+			if err = b.RefreshCipher(ctx); !errors.Is(err, ErrCapabilityUnsupported{CapabilityCallDataPublicKey}) {
+				t.Fatalf("expected ErrCapabilityUnsupported{%s}, got %v", CapabilityCallDataPublicKey, err)
+			}
+		}
+		// WrapClient and the first refresh.
+		if got := len(rt.recorded("oasis_callDataPublicKey")); got != 2 {
+			t.Fatalf("the second refresh should fail without a request, got %d requests", got)
+		}
+		if caps, _ := b.Capabilities(ctx); caps.CallDataPublicKey {
+			t.Fatalf("expected the missing key endpoint to be detected")
+		}
+	})
+}
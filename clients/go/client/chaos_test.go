@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/chaostransport"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/mockgateway"
+)
+
+// The chaos tests run the resilience features against the mock gateway
+// behind a transport injecting faults. Random faults are seeded, so failures
+// reproduce.
+
+// chaosRetry retries quickly, and often enough to outlast the faults.
+var chaosRetry = RetryPolicy{MaxAttempts: 8, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+// dialChaos wraps a client connected to gw through a chaos transport seeded
+// with seed. Faults are only scheduled once the client is wrapped.
+func dialChaos(t *testing.T, gw *mockgateway.Gateway, seed int64, opts ...Option) (*WrappedBackend, *chaostransport.Transport) {
+	transport := &http.Transport{}
+	t.Cleanup(transport.CloseIdleConnections)
+	chaos := chaostransport.New(transport, seed)
+	c, err := rpc.DialOptions(context.Background(), gw.URL, rpc.WithHTTPClient(chaos.Client()))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(c.Close)
+	b, err := WrapClient(ethclient.NewClient(c), nil, opts...)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	return b, chaos
+}
+
+// sendBurst sends n transactions from the first account of keyring, one
+// after the other, resending each with send until it lands.
+func sendBurst(t *testing.T, b *WrappedBackend, keyring *sapphire.Keyring, n int, send func(*types.Transaction) error) {
+	ctx := context.Background()
+	from := keyring.Addresses()[0]
+	opts := b.Transactor(from)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	for i := 0; i < n; i++ {
+		nonce, err := b.PendingNonceAt(ctx, from)
+		if err != nil {
+			t.Fatalf("tx %d: failed to fetch nonce: %v", i, err)
+		}
+		tx, err := opts.Signer(from, types.NewTransaction(nonce, to, big.NewInt(1), 100_000, opts.GasPrice, []byte(fmt.Sprintf("tx %d", i))))
+		if err != nil {
+			t.Fatalf("tx %d: failed to sign: %v", i, err)
+		}
+		if err = send(tx); err != nil {
+			t.Fatalf("tx %d: %v", i, err)
+		}
+	}
+}
+
+// checkLandedOnce fails t unless the gateway accepted the n transactions of
+// sendBurst exactly once each, in order.
+func checkLandedOnce(t *testing.T, gw *mockgateway.Gateway, n int) {
+	sent := gw.Calls("eth_sendRawTransaction")
+	if len(sent) != n {
+		t.Fatalf("expected %d transactions to land, got %d", n, len(sent))
+	}
+	for i, call := range sent {
+		if string(call.Data) != fmt.Sprintf("tx %d", i) || call.Tx.Nonce() != uint64(i) {
+			t.Fatalf("transaction %d landed as %q with nonce %d", i, call.Data, call.Tx.Nonce())
+		}
+	}
+}
+
+// TestChaosSendBurst has the gateway refuse every third request during a
+// burst of transactions. Requests that provably did not reach the gateway are
+// retried, so every transaction lands exactly once.
+func TestChaosSendBurst(t *testing.T) {
+	gw := mockgateway.New(t)
+	keyring := newTestKeyring(t, 1)
+	b, chaos := dialChaos(t, gw, 1, WithKeyring(keyring), WithRetry(chaosRetry))
+	chaos.Every("", 3, chaostransport.Refuse)
+
+	sendBurst(t, b, keyring, 100, func(tx *types.Transaction) error {
+		return b.SendTransaction(context.Background(), tx)
+	})
+	checkLandedOnce(t, gw, 100)
+	if len(chaos.Injections()) < 60 {
+		t.Fatalf("expected the gateway to flap, got %d faults", len(chaos.Injections()))
+	}
+}
+
+// TestChaosLostSendResponses loses the response to every third transaction
+// after the gateway accepted it. Such sends are not retried, as they may have
+// landed; resending them is up to the caller, and the gateway recognizes them.
+func TestChaosLostSendResponses(t *testing.T) {
+	gw := mockgateway.New(t)
+	keyring := newTestKeyring(t, 1)
+	b, chaos := dialChaos(t, gw, 1, WithKeyring(keyring), WithRetry(chaosRetry))
+	chaos.Every("eth_sendRawTransaction", 3, chaostransport.Reset)
+
+	var lost int
+	sendBurst(t, b, keyring, 30, func(tx *types.Transaction) error {
+		err := b.SendTransaction(context.Background(), tx)
+		for err != nil && IsTemporary(err) {
+			if IsRetryable(err) {
+				return fmt.Errorf("expected a send that may have landed not to be retryable: %w", err)
+			}
+			lost++
+			err = b.SendTransaction(context.Background(), tx)
+		}
+		if err != nil && strings.Contains(err.Error(), "already known") {
+			return nil
+		}
+		return err
+	})
+	checkLandedOnce(t, gw, 30)
+	if lost == 0 {
+		t.Fatalf("expected responses to be lost")
+	}
+}
+
+// TestChaosFlakyReads makes calls and signed queries while the gateway
+// randomly delays requests, cuts responses off, fails or is unreachable.
+func TestChaosFlakyReads(t *testing.T) {
+	gw := mockgateway.New(t)
+	gw.OnCall(echo)
+	keyring := newTestKeyring(t, 1)
+	b, chaos := dialChaos(t, gw, 7, WithKeyring(keyring), WithRetry(chaosRetry))
+	chaos.Randomly("", 0.3,
+		chaostransport.Delay(2*time.Millisecond),
+		chaostransport.ResetMidBody,
+		chaostransport.Status(http.StatusServiceUnavailable),
+		chaostransport.Refuse,
+	)
+
+	for i := 0; i < 100; i++ {
+		var from common.Address
+		if i%2 == 1 {
+			from = keyring.Addresses()[0]
+		}
+		if err := stressCall(context.Background(), b, from, []byte(fmt.Sprintf("call %d", i))); err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+	}
+	if len(chaos.Injections()) < 30 {
+		t.Fatalf("expected faults, got %d", len(chaos.Injections()))
+	}
+}
+
+// TestChaosGarbage has the gateway answer with invalid JSON. The call fails,
+// as a gateway answering garbage can't be expected to do better when asked
+// again, and the client keeps working.
+func TestChaosGarbage(t *testing.T) {
+	gw := mockgateway.New(t)
+	gw.OnCall(echo)
+	b, chaos := dialChaos(t, gw, 1, WithRetry(chaosRetry))
+	chaos.Script("eth_call", chaostransport.Garbage)
+
+	if err := stressCall(context.Background(), b, common.Address{}, []byte("garbage")); err == nil || IsTemporary(err) {
+		t.Fatalf("expected the call to fail for good, got %v", err)
+	}
+	if n := len(gw.Requests("eth_call")); n != 1 {
+		t.Fatalf("expected a single attempt, got %d", n)
+	}
+	if err := stressCall(context.Background(), b, common.Address{}, []byte("after")); err != nil {
+		t.Fatalf("expected the client to keep working, got %v", err)
+	}
+}
+
+// TestChaosCircuitBreaker takes the gateway down until the circuit breaker
+// opens, and checks that it closes again once the gateway is back.
+func TestChaosCircuitBreaker(t *testing.T) {
+	gw := mockgateway.New(t)
+	gw.OnCall(echo)
+	b, chaos := dialChaos(t, gw, 1,
+		WithRetry(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+		WithCircuitBreaker(CircuitBreakerPolicy{FailureThreshold: 4, OpenDuration: 20 * time.Millisecond}),
+	)
+	chaos.Every("", 1, chaostransport.Refuse)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := stressCall(ctx, b, common.Address{}, []byte("down")); err == nil {
+			t.Fatalf("expected the call to fail while the gateway is down")
+		}
+	}
+	refused := len(chaos.Injections())
+	if err := stressCall(ctx, b, common.Address{}, []byte("open")); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the circuit breaker to be open, got %v", err)
+	}
+	if len(chaos.Injections()) != refused {
+		t.Fatalf("expected the open circuit breaker to hold requests back")
+	}
+
+	chaos.Clear()
+	time.Sleep(30 * time.Millisecond)
+	if err := stressCall(ctx, b, common.Address{}, []byte("up")); err != nil {
+		t.Fatalf("expected the circuit breaker to close, got %v", err)
+	}
+	if _, err := b.EstimateGas(ctx, ethereum.CallMsg{Data: []byte("up")}); err != nil {
+		t.Fatalf("expected the client to keep working, got %v", err)
+	}
+}
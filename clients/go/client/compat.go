@@ -0,0 +1,885 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+type NetworkParams struct {
+	Name           string
+	ChainID        big.Int
+	DefaultGateway string
+	RuntimeID      string
+	// Multicall3 is the address of the Multicall3 contract used by Multicall.
+	Multicall3 common.Address
+}
+
+// CanonicalMulticall3 is the address Multicall3 is deployed at on most chains.
+var CanonicalMulticall3 = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+var Networks = map[uint64]NetworkParams{
+	0x5aff: {
+		Name:           "testnet",
+		ChainID:        *big.NewInt(0x5aff),
+		DefaultGateway: "https://testnet.sapphire.oasis.io",
+		RuntimeID:      "0x000000000000000000000000000000000000000000000000a6d1e3ebf60dff6c",
+		Multicall3:     CanonicalMulticall3,
+	},
+	0x5afe: {
+		Name:           "mainnet",
+		ChainID:        *big.NewInt(0x5afe),
+		DefaultGateway: "https://sapphire.oasis.io",
+		RuntimeID:      "0x000000000000000000000000000000000000000000000000f80306c9858e7279",
+		Multicall3:     CanonicalMulticall3,
+	},
+	0x5afd: {
+		Name:           "localnet",
+		ChainID:        *big.NewInt(0x5afd),
+		DefaultGateway: "http://localhost:8545",
+		RuntimeID:      "0x8000000000000000000000000000000000000000000000000000000000000000",
+		Multicall3:     CanonicalMulticall3,
+	},
+}
+
+// PackTx prepares a regular Eth transaction for Sapphire. The transaction returned from this function is what must be signed.
+func PackTx(tx *types.Transaction, cipher sapphire.Cipher) (*types.Transaction, error) {
+	if !txNeedsPacking(tx) {
+		return tx, nil
+	}
+	return packTx(tx, cipher)
+}
+
+func packTx(tx *types.Transaction, cipher sapphire.Cipher) (*types.Transaction, error) {
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    tx.Nonce(),
+		GasPrice: tx.GasPrice(),
+		Gas:      tx.Gas(),
+		To:       tx.To(),
+		Value:    tx.Value(),
+		Data:     cipher.EncryptEncode(tx.Data()),
+	}), nil
+}
+
+// PackCall prepares `msg` for being sent to Sapphire. The call will be end-to-end encrypted, but the `from` address will be zero.
+func PackCall(msg ethereum.CallMsg, cipher sapphire.Cipher) (*ethereum.CallMsg, error) {
+	msg.Data = cipher.EncryptEncode(msg.Data)
+	return &msg, nil
+}
+
+type rsvSigner struct {
+	sign sapphire.SignerFn
+}
+
+func (s rsvSigner) SignRSV(digest [32]byte) ([]byte, error) {
+	sig, err := s.sign(digest)
+	switch {
+	case err != nil:
+		// Callers may inspect the signature before the error.
+		return make([]byte, 65), err
+	case len(sig) != 65:
+		return make([]byte, 65), fmt.Errorf("%w: invalid signature length %d", sapphire.ErrSignatureRejected, len(sig))
+	default:
+		return sig, nil
+	}
+}
+
+// PackSignedCall prepares `msg` in-place for being sent to Sapphire. The call will be end-to-end encrypted and a signature will be used to authenticate the `from` address.
+//
+// Calls the runtime would reject, e.g. with a negative value or more than
+// DefaultMaxSignedCallDataSize bytes of calldata, fail with
+// ErrInvalidSignedCall before they are signed.
+func PackSignedCall(msg ethereum.CallMsg, cipher sapphire.Cipher, sign sapphire.SignerFn, chainID big.Int, leash *evm.Leash) (*ethereum.CallMsg, error) {
+	return packSignedCall(msg, cipher, sign, chainID, leash)
+}
+
+// PackSignedCallUnchecked is PackSignedCall without validating msg, for
+// testing how the runtime handles malformed queries.
+func PackSignedCallUnchecked(msg ethereum.CallMsg, cipher sapphire.Cipher, sign sapphire.SignerFn, chainID big.Int, leash *evm.Leash) (*ethereum.CallMsg, error) {
+	return packSignedCall(msg, cipher, sign, chainID, leash, sapphire.WithoutValidation())
+}
+
+// packSignedCall is PackSignedCall signing the query with opts.
+func packSignedCall(msg ethereum.CallMsg, cipher sapphire.Cipher, sign sapphire.SignerFn, chainID big.Int, leash *evm.Leash, opts ...sapphire.CallOption) (*ethereum.CallMsg, error) {
+	if msg.Gas == 0 {
+		msg.Gas = sapphire.DefaultGasLimit // Must be non-zero for signed calls.
+	}
+	if msg.GasPrice == nil {
+		msg.GasPrice = big.NewInt(sapphire.DefaultGasPrice) // Must be non-zero for signed calls.
+	}
+	// msg.To is nil when deploying.
+	opts = append([]sapphire.CallOption{
+		sapphire.WithCaller(msg.From),
+		sapphire.WithGasLimit(msg.Gas),
+		sapphire.WithGasPrice(msg.GasPrice),
+		sapphire.WithValue(msg.Value),
+		sapphire.WithLeash(*leash),
+	}, opts...)
+	dataPack, err := sapphire.NewSignedCall(context.Background(), rsvSigner{sign}, &chainID, msg.To, msg.Data, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signed call data back: %w", err)
+	}
+
+	if dataPack.Data.Body != nil {
+		var bodyDecoded []byte
+		if err = cbor.Unmarshal(dataPack.Data.Body, &bodyDecoded); err != nil {
+			return nil, fmt.Errorf("failed to decode data body while packing signed call: %w", err)
+		}
+		// Ciphers leave calls without data, which have nothing to hide, as is.
+		if envelope := cipher.EncryptEnvelope(bodyDecoded); envelope != nil {
+			dataPack.Data = *envelope
+		}
+	}
+	msg.Data = sapphire.EncodeDataPack(dataPack)
+
+	return &msg, nil
+}
+
+// WrappedBackend implements bind.ContractBackend and bind.DeployBackend.
+//
+// A WrappedBackend is safe for concurrent use by multiple goroutines, like the
+// ethclient.Client it wraps. Each call, query or transaction uses a consistent
+// snapshot of the cipher, so refreshing the runtime key with RefreshCipher
+// never affects requests already in flight.
+type WrappedBackend struct {
+	backend       bind.ContractBackend
+	deployBackend bind.DeployBackend
+	client        *ethclient.Client
+	chainID       big.Int
+	sign          sapphire.SignerFn
+	keyring       *sapphire.Keyring
+	nonces        *nonceManager
+	noncePolicy   NoncePolicy
+	heads         *headTracker
+	limits        sapphire.DecodeLimits
+	plaintexts    *plaintextStore
+	mw            *middleware
+	caps          capabilityCache
+	plainEstimate bool
+	gasMargin     GasMargin
+	gasPadding    uint64
+	padded        *plaintextStore // Transactions sent with the padded gas limit.
+	feeOpts       *FeeOptions
+	fees          feeCache
+	multicall     *common.Address
+	domain        *sapphire.SignedCallDomain
+	maxCallData   int
+
+	debug          DebugHook
+	debugPlaintext bool
+
+	// rpcOpts, httpClient, userAgent and headers configure the connection
+	// made by Dial.
+	rpcOpts    []rpc.ClientOption
+	httpClient *http.Client
+	userAgent  *string
+	headers    HeaderProvider
+
+	// verifier cross-checks leash blocks, see WithEndpointVerifier.
+	verifier     VerifierBackend
+	verifierOpts VerifierOptions
+
+	// cache persists runtime keys and leashes, see WithPersistentCache.
+	cache *diskCache
+	// keyManagerKey signs runtime keys, see WithKeyManagerKey.
+	keyManagerKey *signature.PublicKey
+
+	// ownsClient is set when client was dialed by Dial and must be closed with the backend.
+	ownsClient bool
+	// runtime is set for backends of a runtime client, see WrapRuntimeClient,
+	// and conn when its connection was made by DialRuntime.
+	runtime *runtimeBackend
+	conn    io.Closer
+
+	mu        sync.RWMutex
+	cipher    sapphire.Cipher
+	keySource string
+}
+
+// NewCipher creates a default cipher with encryption support.
+//
+// If you use cipher over a longer period of time, you should create a new
+// cipher instance every epoch to refresh the ParaTime's ephemeral key!
+func NewCipher(c *ethclient.Client) (sapphire.Cipher, error) {
+	return NewCipherContext(context.Background(), c)
+}
+
+// NewCipherContext is like NewCipher but aborts when ctx is done.
+func NewCipherContext(ctx context.Context, c *ethclient.Client) (sapphire.Cipher, error) {
+	cipher, _, err := newCipherContext(ctx, c, nil)
+	return cipher, err
+}
+
+// keyFetch describes how the key of a cipher was fetched.
+type keyFetch struct {
+	// source is KeySourceRPC or KeySourceSubcall.
+	source string
+	// raw is the gateway's response.
+	raw json.RawMessage
+}
+
+// newCipherContext is like NewCipherContext but also describes the key
+// fetch, and checks the key's signature if km is set.
+func newCipherContext(ctx context.Context, c *ethclient.Client, km *keyManager) (sapphire.Cipher, keyFetch, error) {
+	runtimePublicKey, epoch, source, raw, err := getRuntimePublicKey(ctx, c, km)
+	fetch := keyFetch{source: source, raw: raw}
+	if err != nil {
+		return nil, keyFetch{source: source}, keyFetchError(err)
+	}
+	keypair, err := sapphire.NewCurve25519KeyPair()
+	if err != nil {
+		return nil, fetch, fmt.Errorf("failed to generate ephemeral keypair: %w", err)
+	}
+	cipher, err := sapphire.NewX25519DeoxysIICipher(keypair, runtimePublicKey, epoch)
+	if err != nil {
+		return nil, fetch, fmt.Errorf("failed to create default cipher: %w", err)
+	}
+	return cipher, fetch, nil
+}
+
+// WrapClient wraps an ethclient.Client so that it can talk to Sapphire.
+//
+// The sign function is used for all accounts unless a keyring is supplied via
+// WithKeyring, in which case it is only used for accounts missing from the
+// keyring and may be nil.
+func WrapClient(c *ethclient.Client, sign sapphire.SignerFn, opts ...Option) (*WrappedBackend, error) {
+	return WrapClientContext(context.Background(), c, sign, opts...)
+}
+
+// WrapClientContext is like WrapClient but aborts when ctx is done.
+func WrapClientContext(ctx context.Context, c *ethclient.Client, sign sapphire.SignerFn, opts ...Option) (*WrappedBackend, error) {
+	b := newWrappedBackend(c, c, big.Int{}, nil, sign, opts...)
+	b.client = c
+
+	// Only timeouts apply here, the rate limits are for the client's own requests.
+	chainCtx, cancel := b.mw.timeouts.withTimeout(ctx, rpcRead)
+	chainID, err := c.ChainID(chainCtx)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chain ID: %w", err)
+	}
+	b.chainID = *chainID
+	if err = b.verifyChainID(ctx); err != nil {
+		return nil, err
+	}
+
+	km, err := b.keyManager()
+	if err != nil {
+		return nil, err
+	}
+	if cipher, source, ok := b.cachedCipher(ctx, km); ok {
+		b.setCipherFrom(cipher, source)
+		return b, nil
+	}
+	keyCtx, cancel := b.mw.timeouts.withTimeout(ctx, rpcKeyFetch)
+	defer cancel()
+	cipher, fetch, err := newCipherContext(keyCtx, c, km)
+	b.debugRequest(callContext{op: fetch.source}, nil, nil, fetch.raw, err)
+	if err = b.caps.observe(CapabilityCallDataPublicKey, err); err != nil {
+		var unsupported ErrCapabilityUnsupported
+		if errors.As(err, &unsupported) {
+			err = fmt.Errorf("%w: %w", sapphire.ErrNotSapphireChain, err)
+		}
+		return nil, callContext{op: fetch.source}.wrap(err)
+	}
+	b.setCipherFrom(cipher, fetch.source)
+	b.cacheCipher(ctx, fetch)
+	return b, nil
+}
+
+// Dial connects to a Sapphire gateway and wraps the connection, see WrapClient.
+//
+// The connection is configured with WithHTTPClient, WithHeader,
+// WithBasicAuth, WithHeaderProvider or WithRPCClientOptions; all requests made by the wrapped client, including
+// the chain ID and runtime public key lookups, go through it. Requests
+// identify the client with DefaultUserAgent unless WithUserAgent says
+// otherwise. To reuse an
+// existing *rpc.Client instead, pass ethclient.NewClient(rpcClient) to
+// WrapClient.
+func Dial(rawurl string, sign sapphire.SignerFn, opts ...Option) (*WrappedBackend, error) {
+	return DialContext(context.Background(), rawurl, sign, opts...)
+}
+
+// DialContext is like Dial but aborts when ctx is done.
+func DialContext(ctx context.Context, rawurl string, sign sapphire.SignerFn, opts ...Option) (*WrappedBackend, error) {
+	var cfg WrappedBackend
+	cfg.mw = &middleware{redact: newRedactor()}
+	cfg.mw.redact.addURL(rawurl)
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	// The wrapped client shares the redactor, which learns the values of
+	// the header provider as requests are sent.
+	opts = append([]Option{withRedactor(cfg.mw.redact)}, opts...)
+
+	maxResponseSize := cfg.limits.MaxResponseSize
+	if maxResponseSize == 0 {
+		maxResponseSize = sapphire.DefaultDecodeLimits.MaxResponseSize
+	}
+	httpClient := limitedHTTPClient(cfg.httpClient, maxResponseSize)
+	rpcOpts := []rpc.ClientOption{
+		rpc.WithHTTPClient(httpClient),
+		rpc.WithWebsocketMessageSizeLimit(maxResponseSize),
+	}
+	if cfg.headers != nil {
+		httpClient.Transport = &headerTransport{base: httpClient.Transport, headers: cfg.headers, redact: cfg.mw.redact}
+		if isWebsocketURL(rawurl) {
+			// The handshake has no context of its own.
+			rpcOpts = append(rpcOpts, rpc.WithHTTPAuth(func(h http.Header) error {
+				return applyHeaders(context.Background(), h, cfg.headers, cfg.mw.redact)
+			}))
+		}
+	}
+	userAgent := DefaultUserAgent()
+	if cfg.userAgent != nil {
+		userAgent = *cfg.userAgent
+	}
+	if userAgent != "" {
+		// Added before the caller's options so that WithHeader can override them.
+		rpcOpts = append(rpcOpts, rpc.WithHeader("User-Agent", userAgent), rpc.WithHeader(clientHeader, userAgent))
+	}
+	rpcOpts = append(rpcOpts, cfg.rpcOpts...)
+	rc, err := rpc.DialOptions(ctx, rawurl, rpcOpts...)
+	if err != nil {
+		return nil, cfg.mw.redact.err(fmt.Errorf("failed to dial gateway: %w", err))
+	}
+	b, err := WrapClientContext(ctx, ethclient.NewClient(rc), sign, opts...)
+	if err != nil {
+		rc.Close()
+		return nil, cfg.mw.redact.err(err)
+	}
+	b.ownsClient = true
+	return b, nil
+}
+
+// Close stops head tracking and closes the connection if it was made by Dial
+// or DialRuntime. Clients passed to WrapClient or WrapRuntimeClient are left
+// open and remain owned by the caller.
+func (b *WrappedBackend) Close() {
+	if b.heads != nil {
+		b.heads.stop()
+	}
+	if b.ownsClient {
+		b.client.Close()
+	}
+	if b.conn != nil {
+		b.conn.Close()
+	}
+}
+
+func newWrappedBackend(backend bind.ContractBackend, deployBackend bind.DeployBackend, chainID big.Int, cipher sapphire.Cipher, sign sapphire.SignerFn, opts ...Option) *WrappedBackend {
+	b := &WrappedBackend{
+		backend:       backend,
+		deployBackend: deployBackend,
+		chainID:       chainID,
+		sign:          sign,
+		nonces:        newNonceManager(),
+		plaintexts:    newPlaintextStore(plaintextStoreSize),
+		mw:            &middleware{redact: newRedactor()},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.cipher = sapphire.CipherWithLimits(cipher, b.limits)
+	return b
+}
+
+// currentCipher returns the cipher requests should currently be encrypted with.
+func (b *WrappedBackend) currentCipher() sapphire.Cipher {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.cipher
+}
+
+// setCipher replaces the cipher used for subsequent requests.
+func (b *WrappedBackend) setCipher(cipher sapphire.Cipher) {
+	cipher = sapphire.CipherWithLimits(cipher, b.limits)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cipher = cipher
+}
+
+// setCipherFrom is setCipher for a cipher whose key was fetched from source.
+func (b *WrappedBackend) setCipherFrom(cipher sapphire.Cipher, source string) {
+	cipher = sapphire.CipherWithLimits(cipher, b.limits)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cipher = cipher
+	b.keySource = source
+}
+
+// RefreshCipher fetches the current runtime calldata public key and starts
+// encrypting subsequent requests to it with a fresh ephemeral keypair.
+//
+// The runtime rotates its ephemeral key every epoch, so long-lived clients
+// should call this periodically.
+func (b *WrappedBackend) RefreshCipher(ctx context.Context) error {
+	if b.runtime != nil {
+		return b.refreshRuntimeCipher(ctx)
+	}
+	if b.client == nil {
+		return fmt.Errorf("cannot refresh cipher: %w: not created from an ethclient.Client", sapphire.ErrUnsupportedBackend)
+	}
+	if err := b.caps.require(CapabilityCallDataPublicKey); err != nil {
+		return err
+	}
+	km, err := b.keyManager()
+	if err != nil {
+		return err
+	}
+	fetch := keyFetch{source: KeySourceRPC}
+	cipher, err := invoke(ctx, b.mw, rpcKeyFetch, func(ctx context.Context) (cipher sapphire.Cipher, err error) {
+		cipher, fetch, err = newCipherContext(ctx, b.client, km)
+		return cipher, err
+	})
+	b.debugRequest(callContext{op: fetch.source}, nil, nil, fetch.raw, err)
+	if err = b.caps.observe(CapabilityCallDataPublicKey, err); err != nil {
+		return callContext{op: fetch.source}.wrap(err)
+	}
+	b.setCipherFrom(cipher, fetch.source)
+	b.cacheCipher(ctx, fetch)
+	return nil
+}
+
+// signerFor returns the signer to be used for the given account: the one
+// carried by ctx, see ContextWithSigner, then the keyring's, then the SignerFn.
+func (b *WrappedBackend) signerFor(ctx context.Context, account common.Address) (sapphire.Signer, error) {
+	if s, ok := sapphire.SignerFromContext(ctx, account); ok {
+		return s, nil
+	}
+	if b.keyring != nil {
+		if s, ok := b.keyring.Signer(account); ok {
+			return s, nil
+		}
+	}
+	if b.sign != nil {
+		return rsvSigner{b.sign}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", sapphire.ErrNoSigner, account.Hex())
+}
+
+// Transactor returns a TransactOpts that can be used with Sapphire.
+//
+// Signing honors the returned options' Context, if set. bind doesn't pass the
+// options to their Signer, so a Context set on a copy of them, e.g. in an
+// abigen session, doesn't reach signing. It still bounds the other requests
+// bind makes for the transaction, including sending it. The gas price is
+// DefaultGasPrice unless WithFeeSuggestions was given. The gas limit is the
+// target of WithGasPadding, if given. An explicit GasLimit is used verbatim,
+// otherwise bindings estimate it with EstimateGas, which covers the encrypted
+// calldata and adds the margin set with WithGasMargin.
+//
+// With NoSend set, bindings return the encrypted and signed transaction
+// without sending it. It can be serialized with MarshalBinary and broadcast
+// later through any client, wrapped or not.
+func (b *WrappedBackend) Transactor(from common.Address) *bind.TransactOpts {
+	opts := &bind.TransactOpts{
+		From:     from,
+		GasPrice: big.NewInt(sapphire.DefaultGasPrice),
+		GasLimit: b.gasPadding,
+	}
+	if b.feeOpts != nil {
+		// Leave the fees to SuggestGasPrice and SuggestGasTipCap.
+		opts.GasPrice = nil
+	}
+	opts.Signer = func(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if addr != from {
+			return nil, bind.ErrNotAuthorized
+		}
+		ctx := opts.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		return b.signTx(ctx, from, tx)
+	}
+	return opts
+}
+
+// signTx packs the transaction for Sapphire and signs it on behalf of from.
+func (b *WrappedBackend) signTx(ctx context.Context, from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	txSigner, err := b.signerFor(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if tx, err = b.fillFees(ctx, tx); err != nil {
+		return nil, err
+	}
+	cipher := b.currentCipher()
+	packedTx, err := PackTx(tx, cipher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack tx: %w", err)
+	}
+	signer := types.LatestSignerForChainID(&b.chainID)
+	sig, err := sapphire.SignDigest(ctx, txSigner, *(*[32]byte)(signer.Hash(packedTx).Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	signedTx, err := packedTx.WithSignature(signer, sig)
+	if err != nil {
+		return nil, err
+	}
+	if packedTx != tx {
+		b.plaintexts.putSigned(signedTx, tx.Data(), cipher)
+	}
+	return signedTx, nil
+}
+
+// CodeAt implements ContractCaller and DeployBackend.
+//
+// Transient errors are retried with DefaultRetryPolicy unless WithRetry was
+// given, as bind.WaitDeployed gives up on the first error.
+func (b *WrappedBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return invoke(ctx, b.mw.withDefaultRetry(), rpcRead, func(ctx context.Context) ([]byte, error) {
+		return b.backend.CodeAt(ctx, contract, blockNumber)
+	})
+}
+
+// CallContract implements ContractCaller.
+func (b *WrappedBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	cc := newCallContext(ctx, "eth_call", call.To, blockNumber)
+	cipher := b.currentCipher()
+	packedCall, leash, err := b.packCall(ctx, cipher, call, blockNumber)
+	if err != nil {
+		return nil, cc.wrap(err)
+	}
+	res, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) ([]byte, error) {
+		return b.backend.CallContract(ctx, *packedCall, blockNumber)
+	})
+	b.debugRequest(cc, call.Data, packedCall.Data, hexutil.Bytes(res), err)
+	if err == nil {
+		res, err = cipher.DecryptEncoded(res)
+	}
+	b.dropCachedKey(ctx, cipher, err)
+	if err != nil && leash != nil {
+		err = signedQueryError(err)
+		if b.cache != nil && (errors.Is(err, sapphire.ErrLeashExpired) || errors.Is(err, sapphire.ErrLeashNonceMismatch)) {
+			b.cache.dropLeash(ctx, &b.chainID, call.From)
+		}
+	}
+	if err != nil {
+		return nil, cc.wrap(historicalStateError(blockNumber, err))
+	}
+	return res, nil
+}
+
+// packCall encrypts the call and, if it has a sender, turns it into a signed
+// query. The returned leash is nil for unsigned calls.
+func (b *WrappedBackend) packCall(ctx context.Context, cipher sapphire.Cipher, call ethereum.CallMsg, blockNumber *big.Int) (*ethereum.CallMsg, *evm.Leash, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if call.From == [common.AddressLength]byte{} {
+		packedCall, err := PackCall(call, cipher)
+		return packedCall, nil, err
+	}
+	callSigner, err := b.signerFor(ctx, call.From)
+	if err != nil {
+		return nil, nil, err
+	}
+	leash, err := b.makeLeash(ctx, call.From, blockNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+	sign := func(digest [32]byte) ([]byte, error) {
+		return sapphire.SignDigest(ctx, callSigner, digest)
+	}
+	packedCall, err := packSignedCall(call, cipher, sign, b.chainID, leash, b.signedCallOptions()...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to pack signed call: %w", err)
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return packedCall, leash, nil
+}
+
+// HeaderByNumber implements ContractTransactor.
+func (b *WrappedBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (*types.Header, error) {
+		return b.backend.HeaderByNumber(ctx, number)
+	})
+}
+
+// PendingCodeAt implements ContractTransactor. Transient errors are retried
+// as by CodeAt.
+func (b *WrappedBackend) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return invoke(ctx, b.mw.withDefaultRetry(), rpcRead, func(ctx context.Context) ([]byte, error) {
+		return b.backend.PendingCodeAt(ctx, account)
+	})
+}
+
+// PendingNonceAt implements ContractTransactor.
+//
+// The returned nonce accounts for transactions already sent through this
+// client that the gateway may not be reporting yet.
+func (b *WrappedBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	nonce, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (uint64, error) {
+		return b.backend.PendingNonceAt(ctx, account)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return b.nonces.pendingNonce(account, nonce), nil
+}
+
+// SuggestGasPrice implements ContractTransactor.
+//
+// With WithFeeSuggestions, the price is derived by SuggestFees.
+func (b *WrappedBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	if b.feeOpts != nil && !b.feeOpts.Legacy {
+		fees, err := b.suggestFees(ctx, *b.feeOpts)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).Set(fees.gasPrice), nil
+	}
+	return invoke(ctx, b.mw, rpcRead, b.backend.SuggestGasPrice)
+}
+
+// SuggestGasTipCap implements ContractTransactor.
+//
+// With WithFeeSuggestions, the tip is derived by SuggestFees.
+func (b *WrappedBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	if b.feeOpts != nil {
+		_, maxTip, err := b.SuggestFees(ctx, *b.feeOpts)
+		return maxTip, err
+	}
+	return invoke(ctx, b.mw, rpcRead, b.backend.SuggestGasTipCap)
+}
+
+// EstimateGas implements ContractTransactor.
+//
+// Calls with a sender are estimated as signed queries, so that the estimate
+// reflects the execution path taken for msg.sender, and padded to cover the
+// calldata of the encrypted transaction should it exceed that of the query.
+// See WithUnsignedEstimateFallback for senders the client cannot sign for.
+//
+// The estimate is made on the encrypted calldata and includes the margin set
+// with WithGasMargin.
+func (b *WrappedBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (gas uint64, err error) {
+	cc := newCallContext(ctx, "eth_estimateGas", call.To, nil)
+	packedCall, padding, err := b.packEstimate(ctx, b.currentCipher(), call)
+	if err != nil {
+		return 0, cc.wrap(err)
+	}
+	gas, err = invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (uint64, error) {
+		return b.backend.EstimateGas(ctx, *packedCall)
+	})
+	limit := b.gasMargin.apply(gas + padding)
+	b.debugGas(cc, call.Data, packedCall.Data, hexutil.Uint64(gas), limit, err)
+	if err != nil {
+		return 0, cc.wrap(err)
+	}
+	return limit, nil
+}
+
+// packEstimate packs a call for gas estimation and returns the gas to add to
+// the gateway's estimate.
+func (b *WrappedBackend) packEstimate(ctx context.Context, cipher sapphire.Cipher, call ethereum.CallMsg) (*ethereum.CallMsg, uint64, error) {
+	if b.plainEstimate && call.From != (common.Address{}) {
+		if _, err := b.signerFor(ctx, call.From); err != nil {
+			packedCall, err := PackCall(call, cipher)
+			return packedCall, 0, err
+		}
+	}
+	packedCall, leash, err := b.packCall(ctx, cipher, call, nil)
+	if err != nil || leash == nil {
+		return packedCall, 0, err
+	}
+	// The transaction will carry an encrypted envelope instead of the signed
+	// query, so make sure the estimate covers its calldata as well.
+	envelope := cipher.EncryptEncode(call.Data)
+	return packedCall, EnvelopeGasOverhead(packedCall.Data, envelope, call.To == nil), nil
+}
+
+// signedCallOptions returns how queries are signed.
+func (b *WrappedBackend) signedCallOptions() []sapphire.CallOption {
+	opts := []sapphire.CallOption{sapphire.WithMaxCallDataSize(b.maxCallData)}
+	if b.domain != nil {
+		opts = append(opts, sapphire.WithCallDomain(*b.domain))
+	}
+	return opts
+}
+
+// makeLeash creates a new leash for the given from address and blockNumber.
+// If blockNumber is nil, the latest block is taken.
+//
+// For historical blocks, the leash carries the account nonce at that block so
+// that it matches the state the call is executed against.
+func (b *WrappedBackend) makeLeash(ctx context.Context, from common.Address, blockNumber *big.Int) (*evm.Leash, error) {
+	// Cached leashes weren't necessarily checked by a verifier.
+	cacheLeash := blockNumber == nil && b.cache != nil && b.verifier == nil
+	if cacheLeash {
+		if leash, ok := b.cache.leash(ctx, &b.chainID, from); ok {
+			return leash, nil
+		}
+	}
+	leashBlockNumber := big.NewInt(0)
+	var header *types.Header
+	var err error
+	if blockNumber == nil {
+		header, err = b.latestLeashHeader(ctx)
+	} else {
+		header, err = b.HeaderByNumber(ctx, blockNumber)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch leash block header: %w", historicalStateError(blockNumber, err))
+	}
+	// We will build a leash on the pre-last block.
+	blockHash := header.ParentHash
+	leashBlockNumber.Sub(header.Number, big.NewInt(1))
+	if b.verifier != nil {
+		number, hash, err := b.verifyLeashBlock(ctx, leashBlockNumber.Uint64(), blockHash, blockNumber == nil)
+		if err != nil {
+			return nil, err
+		}
+		leashBlockNumber.SetUint64(number)
+		blockHash = hash
+	}
+	nonce, err := b.leashNonce(ctx, from, blockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch account nonce: %w", historicalStateError(blockNumber, err))
+	}
+	leash := &evm.Leash{
+		Nonce:       nonce,
+		BlockNumber: leashBlockNumber.Uint64(),
+		BlockHash:   blockHash[:],
+		BlockRange:  sapphire.DefaultBlockRange,
+	}
+	if cacheLeash {
+		b.cache.putLeash(ctx, &b.chainID, from, leash)
+	}
+	return leash, nil
+}
+
+// nonceReader is implemented by backends that can look up historical nonces,
+// such as ethclient.Client.
+type nonceReader interface {
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+}
+
+// leashNonce returns the nonce of the account a leash for blockNumber must carry.
+func (b *WrappedBackend) leashNonce(ctx context.Context, from common.Address, blockNumber *big.Int) (uint64, error) {
+	if blockNumber == nil {
+		return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (uint64, error) {
+			return b.backend.PendingNonceAt(ctx, from)
+		})
+	}
+	nr, ok := b.backend.(nonceReader)
+	if !ok {
+		return 0, fmt.Errorf("%w: cannot fetch nonces at historical blocks", sapphire.ErrUnsupportedBackend)
+	}
+	return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (uint64, error) {
+		return nr.NonceAt(ctx, from, blockNumber)
+	})
+}
+
+func txNeedsPacking(tx *types.Transaction) bool {
+	if tx == nil || len(tx.Data()) == 0 {
+		return false
+	}
+	var envelope sdkTypes.Call
+	return cbor.Unmarshal(tx.Data(), &envelope) != nil // If there is no error, the tx is already packed.
+}
+
+// SendTransaction implements ContractTransactor.
+//
+// Nonce errors resync the local nonce tracking of the sender as configured
+// with WithNoncePolicy.
+func (b *WrappedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	cc := newCallContext(ctx, "eth_sendRawTransaction", tx.To(), nil)
+	from, fromErr := types.Sender(types.LatestSignerForChainID(&b.chainID), tx)
+	if fromErr == nil {
+		if err := b.closeNonceGap(ctx, from, tx); err != nil {
+			return cc.wrap(err)
+		}
+	}
+	err := b.sendTx(ctx, cc, tx)
+	if err != nil && fromErr == nil && b.resyncNonce(ctx, from, tx, err) && b.noncePolicy.Gap != NonceGapFail {
+		if err = b.closeNonceGap(ctx, from, tx); err != nil {
+			return cc.wrap(err)
+		}
+		err = b.sendTx(ctx, cc, tx)
+	}
+	if err != nil {
+		return cc.wrap(err)
+	}
+	if fromErr == nil {
+		b.nonces.commit(from, tx.Nonce())
+		if b.cache != nil {
+			b.cache.sent(ctx, &b.chainID, from, tx.Nonce())
+		}
+	}
+	return nil
+}
+
+// sendTx submits a signed transaction to the gateway.
+func (b *WrappedBackend) sendTx(ctx context.Context, cc callContext, tx *types.Transaction) error {
+	_, err := invoke(ctx, b.mw, rpcSend, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, b.backend.SendTransaction(ctx, tx)
+	})
+	if b.debug != nil {
+		plaintext, _ := b.plaintexts.get(tx.Hash())
+		raw, _ := tx.MarshalBinary()
+		b.debugGas(cc, plaintext, raw, tx.Hash(), tx.Gas(), err)
+	}
+	if err == nil && b.gasPadding != 0 && tx.Gas() == b.gasPadding {
+		b.padded.put(tx.Hash(), nil)
+	}
+	if err != nil && b.cache != nil {
+		if entry, ok := b.plaintexts.signed(tx.Hash()); ok {
+			b.dropCachedKey(ctx, entry.cipher, err)
+		}
+	}
+	return err
+}
+
+// FilterLogs implements ContractFilterer.
+func (b *WrappedBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) ([]types.Log, error) {
+		return b.backend.FilterLogs(ctx, query)
+	})
+}
+
+// SubscribeFilterLogs implements ContractFilterer.
+func (b *WrappedBackend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return invoke(ctx, b.mw, rpcSubscribe, func(ctx context.Context) (ethereum.Subscription, error) {
+		return b.backend.SubscribeFilterLogs(ctx, query, ch)
+	})
+}
+
+// TransactionReceipt implements DeployBackend.
+//
+// Receipts of transactions padded with WithGasPadding are checked to have
+// used the padded amount of gas.
+func (b *WrappedBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	receipt, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (*types.Receipt, error) {
+		return b.deployBackend.TransactionReceipt(ctx, txHash)
+	})
+	if err == nil && b.padded != nil {
+		b.checkGasPadding(receipt)
+	}
+	return receipt, err
+}
@@ -0,0 +1,590 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+func TestPackSignedCall(t *testing.T) {
+	privateKey, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signFn := func(digest [32]byte) ([]byte, error) {
+		// Pass in a custom signing function to interact with the signer
+		return crypto.Sign(digest[:], privateKey)
+	}
+
+	messageFunction, _ := hexutil.Decode("0xe21f37ce")
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	from := common.HexToAddress("0xDce075E1C39b1ae0b75D554558b6451A226ffe00")
+	signature := "xM1D1tqLQM+lEZ8cNLgQq/KbCTB7Y3G2PVJ/yaR0ViAbH3eorfUZ6KRtRK65wNctZtRSWtCZ931uXkIbbH+Lnhw="
+	msg := ethereum.CallMsg{
+		From:       from,
+		To:         &to,
+		Gas:        0,
+		GasPrice:   nil,
+		GasFeeCap:  nil,
+		GasTipCap:  nil,
+		Value:      nil,
+		Data:       messageFunction,
+		AccessList: nil,
+	}
+
+	leash := evm.Leash{
+		Nonce:       0x12,
+		BlockNumber: 0x1234,
+		BlockHash:   common.HexToHash("2ec361fee28d09a3ad2c4d5f7f95d409ce2b68c39b5d647edf0ea651e069e4a8").Bytes(),
+		BlockRange:  15,
+	}
+
+	cipher := sapphire.NewPlainCipher()
+	packedCall, err := PackSignedCall(msg, cipher, signFn, *big.NewInt(0x5aff), &leash)
+	if err != nil {
+		t.Fatalf("err while packing signed call %v", err)
+	}
+	if packedCall.From != from {
+		t.Fatalf("err from mismatch: expected %s got %s", from, packedCall.From)
+	}
+	if packedCall.To.String() != to.String() {
+		t.Fatalf("err to mismatch: expected %s got %s", to, packedCall.To)
+	}
+	if packedCall.Gas == 0 {
+		t.Fatalf("err gas should not be zero")
+	}
+	if packedCall.GasPrice == nil || packedCall.GasPrice.Uint64() == 0 {
+		t.Fatalf("err gas price should not be zero")
+	}
+
+	var dataDecoded evm.SignedCallDataPack
+	if err = cbor.Unmarshal(packedCall.Data, &dataDecoded); err != nil {
+		t.Fatalf("err while decoding signed call %v", err)
+	}
+	var messageFunctionDecoded []byte
+	if err = cbor.Unmarshal(dataDecoded.Data.Body, &messageFunctionDecoded); err != nil {
+		t.Fatalf("err while decoding inner data body %v", err)
+	}
+	if string(messageFunctionDecoded) != string(messageFunction) {
+		t.Fatalf("err innerdata mismatch: expected %s got %s", messageFunction, messageFunctionDecoded)
+	}
+
+	signatureDecoded := base64.StdEncoding.EncodeToString(dataDecoded.Signature)
+	if signatureDecoded != signature {
+		t.Fatalf("err innerdata signature mismatch: expected %s got %s", signature, signatureDecoded)
+	}
+
+	leashOrig, _ := json.Marshal(leash)
+	leashDecoded, _ := json.Marshal(dataDecoded.Leash)
+	if err != nil {
+		t.Fatalf("err while encoding innerdata leash %v", err)
+	}
+	if string(leashOrig) != string(leashDecoded) {
+		t.Fatalf("err innerdata leash mismatch: expected %s got %s", leashOrig, leashDecoded)
+	}
+}
+
+func TestDial(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	addr1 := crypto.PubkeyToAddress(key.PublicKey)
+
+	key2, err := crypto.GenerateKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+	addr2 := crypto.PubkeyToAddress(key2.PublicKey)
+
+	client := localnet.Dial(t)
+	backend, err := WrapClient(client, func(digest [32]byte) ([]byte, error) {
+		// Pass in a custom signing function to interact with the signer
+		return crypto.Sign(digest[:], key)
+	})
+	if err != nil {
+		t.Fatalf("failed to wrap client %v", err)
+	}
+
+	nonce, err := client.PendingNonceAt(context.Background(), addr1)
+	if err != nil {
+		t.Fatalf("failed to get pending nonce %v", err)
+	}
+
+	gasPrice, err := client.SuggestGasPrice(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get gas price %v", err)
+	}
+
+	txOpts := backend.Transactor(addr1)
+
+	tx := types.NewTransaction(nonce, addr2, big.NewInt(1), uint64(100000), gasPrice, nil)
+	signedTx, err := txOpts.Signer(addr1, tx)
+	if err != nil {
+		t.Fatalf("failed to sign transaction %v", err)
+	}
+
+	err = client.SendTransaction(context.Background(), signedTx)
+	if err != nil {
+		t.Fatalf("failed to send transaction %v", err)
+	}
+
+	receipt, err := bind.WaitMined(context.Background(), client, signedTx)
+	if err != nil {
+		t.Fatalf("transaction failed! %v", err)
+	}
+
+	if receipt.Status != uint64(1) {
+		t.Fatalf("transaction failed! (status=%v)", receipt.Status)
+	}
+}
+
+func TestDialCustomTransport(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	rt := newRPCTransport()
+	rt.handle("eth_call", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Bytes(cbor.Marshal(sdkTypes.CallResult{Ok: TestData})), nil
+	})
+	rt.handle("eth_estimateGas", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Uint64(21_000), nil
+	})
+	rt.handle("eth_sendRawTransaction", func([]json.RawMessage) (interface{}, error) {
+		return common.Hash{}, nil
+	})
+
+	b, err := Dial("http://gateway.invalid", nil,
+		WithHTTPClient(&http.Client{Transport: rt}),
+		WithHeader("X-Api-Key", "secret"),
+		WithKeyring(sapphire.NewKeyring(signer)),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer b.Close()
+
+	if _, err = b.CallContract(ctx, ethereum.CallMsg{To: &to, Data: []byte{1, 2, 3}}, nil); err != nil {
+		t.Fatalf("CallContract failed: %v", err)
+	}
+	if _, err = b.CallContract(ctx, ethereum.CallMsg{From: signer.Address(), To: &to, Data: []byte{1, 2, 3}}, nil); err != nil {
+		t.Fatalf("signed CallContract failed: %v", err)
+	}
+	if _, err = b.EstimateGas(ctx, ethereum.CallMsg{To: &to, Data: []byte{1, 2, 3}}); err != nil {
+		t.Fatalf("EstimateGas failed: %v", err)
+	}
+	opts := b.Transactor(signer.Address())
+	tx := types.NewTransaction(0, to, big.NewInt(1), 21_000, opts.GasPrice, []byte{1, 2, 3})
+	signedTx, err := opts.Signer(signer.Address(), tx)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if err = b.SendTransaction(ctx, signedTx); err != nil {
+		t.Fatalf("SendTransaction failed: %v", err)
+	}
+
+	reqs := rt.recorded("")
+	for _, method := range []string{"eth_chainId", "oasis_callDataPublicKey", "eth_call", "eth_estimateGas", "eth_sendRawTransaction"} {
+		if len(rt.recorded(method)) == 0 {
+			t.Fatalf("expected %s to go through the injected transport", method)
+		}
+	}
+	for _, req := range reqs {
+		if got := req.Header.Get("X-Api-Key"); got != "secret" {
+			t.Fatalf("%s request is missing the custom header, got %q", req.Method, got)
+		}
+	}
+}
+
+func TestDialUserAgent(t *testing.T) {
+	rt := newRPCTransport()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := rt.RoundTrip(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name      string
+		opts      []Option
+		userAgent string
+		client    string
+	}{
+		{"default", nil, DefaultUserAgent(), DefaultUserAgent()},
+		{"override", []Option{WithUserAgent("my-app/1.0")}, "my-app/1.0", "my-app/1.0"},
+		{"header", []Option{WithHeader("User-Agent", "custom")}, "custom", DefaultUserAgent()},
+		{"removed", []Option{WithUserAgent("")}, "Go-http-client/1.1", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start := len(rt.recorded(""))
+			b, err := Dial(srv.URL, nil, tc.opts...)
+			if err != nil {
+				t.Fatalf("failed to dial: %v", err)
+			}
+			b.Close()
+
+			reqs := rt.recorded("")[start:]
+			if len(reqs) == 0 {
+				t.Fatalf("no requests reached the server")
+			}
+			for _, req := range reqs {
+				if got := req.Header.Get("User-Agent"); got != tc.userAgent {
+					t.Fatalf("%s: unexpected User-Agent %q, expected %q", req.Method, got, tc.userAgent)
+				}
+				if got := req.Header.Get("X-Sapphire-Client"); got != tc.client {
+					t.Fatalf("%s: unexpected X-Sapphire-Client %q, expected %q", req.Method, got, tc.client)
+				}
+			}
+		})
+	}
+	if !strings.HasPrefix(DefaultUserAgent(), "sapphire-paratime-go/") {
+		t.Fatalf("unexpected default User-Agent %q", DefaultUserAgent())
+	}
+}
+
+func TestEstimateGasAuthenticated(t *testing.T) {
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	stranger := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	mock := newMockBackend()
+	b := newMockWrappedBackend(mock, nil, WithKeyring(keyring), WithUnsignedEstimateFallback())
+
+	gas, err := b.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &to, Data: TestData})
+	if err != nil {
+		t.Fatalf("EstimateGas failed: %v", err)
+	}
+	query := mock.receivedCalls()[0]
+	var pack evm.SignedCallDataPack
+	if err = cbor.Unmarshal(query.Data, &pack); err != nil {
+		t.Fatalf("estimate for a known sender is not a signed query: %v", err)
+	}
+	if expected := mock.gas + EnvelopeGasOverhead(query.Data, sapphire.NewPlainCipher().EncryptEncode(TestData), false); gas != expected {
+		t.Fatalf("unexpected estimate %d, expected %d", gas, expected)
+	}
+
+	// Senders without a signer fall back to an unauthenticated estimate.
+	if gas, err = b.EstimateGas(ctx, ethereum.CallMsg{From: stranger, To: &to, Data: TestData}); err != nil {
+		t.Fatalf("EstimateGas failed for unknown sender: %v", err)
+	}
+	if gas != mock.gas {
+		t.Fatalf("unexpected estimate %d for unknown sender", gas)
+	}
+	if err = cbor.Unmarshal(mock.receivedCalls()[1].Data, &pack); err == nil {
+		t.Fatalf("estimate for an unknown sender was signed")
+	}
+
+	// Without the option, unknown senders are rejected as before.
+	strict := newMockWrappedBackend(newMockBackend(), nil, WithKeyring(keyring))
+	if _, err = strict.EstimateGas(ctx, ethereum.CallMsg{From: stranger, To: &to, Data: TestData}); !errors.Is(err, sapphire.ErrNoSigner) {
+		t.Fatalf("expected ErrNoSigner, got %v", err)
+	}
+}
+
+// bulkyEnvelopeCipher encrypts transactions to envelopes costing more gas
+// than the signed queries of the same calldata.
+type bulkyEnvelopeCipher struct {
+	sapphire.PlainCipher
+}
+
+func (c bulkyEnvelopeCipher) EncryptEncode(plaintext []byte) []byte {
+	return append(c.PlainCipher.EncryptEncode(plaintext), bytes.Repeat([]byte{0xff}, 1024)...)
+}
+
+func TestEstimateGasPadding(t *testing.T) {
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	cipher := bulkyEnvelopeCipher{sapphire.NewPlainCipher()}
+
+	// Signed estimates are padded to the encrypted transaction's calldata,
+	// with or without the fallback for unknown senders.
+	for _, opts := range [][]Option{nil, {WithUnsignedEstimateFallback()}} {
+		mock := newMockBackend()
+		b := newWrappedBackend(mock, mock, *big.NewInt(0x5afd), cipher, nil, append(opts, WithKeyring(keyring))...)
+		gas, err := b.EstimateGas(context.Background(), ethereum.CallMsg{From: from, To: &to, Data: TestData})
+		if err != nil {
+			t.Fatalf("EstimateGas failed: %v", err)
+		}
+		padding := EnvelopeGasOverhead(mock.receivedCalls()[0].Data, cipher.EncryptEncode(TestData), false)
+		if padding == 0 || gas != mock.gas+padding {
+			t.Fatalf("expected the estimate %d padded by %d, got %d", mock.gas, padding, gas)
+		}
+	}
+}
+
+// senderBranchCode deploys a contract that writes to storage when called by a
+// non-zero msg.sender and does nothing otherwise.
+var senderBranchCode = common.FromHex("600c80600b6000396000f3" + "3315600a5760013355005b00")
+
+func TestEstimateGasAuthenticatedLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	client := localnet.Dial(t)
+	b, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(sapphire.NewPrivateKeySigner(key))), WithUnsignedEstimateFallback())
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	ctx := context.Background()
+
+	opts := b.Transactor(from)
+	opts.Context = ctx
+	addr, tx, _, err := bind.DeployContract(opts, abi.ABI{}, senderBranchCode, b)
+	if err != nil {
+		t.Fatalf("failed to deploy contract: %v", err)
+	}
+	if _, err = bind.WaitDeployed(ctx, b, tx); err != nil {
+		t.Fatalf("failed to wait for deployment: %v", err)
+	}
+
+	naive, err := b.EstimateGas(ctx, ethereum.CallMsg{To: &addr, Data: TestData})
+	if err != nil {
+		t.Fatalf("failed to estimate gas without a sender: %v", err)
+	}
+	authenticated, err := b.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &addr, Data: TestData})
+	if err != nil {
+		t.Fatalf("failed to estimate gas with a sender: %v", err)
+	}
+	if authenticated < naive+params.SstoreSetGasEIP2200 {
+		t.Fatalf("authenticated estimate %d does not cover the storage write, naive estimate %d", authenticated, naive)
+	}
+}
+
+func TestTransactorNoSend(t *testing.T) {
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	mock := newMockBackend()
+	b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+	b.cipher = newSeededCipher(t, 1)
+
+	parsed, _ := abi.JSON(strings.NewReader(setterABI))
+	opts := b.Transactor(from)
+	opts.NoSend = true
+	tx, err := bind.NewBoundContract(to, parsed, b, b, b).Transact(opts, "set", big.NewInt(7))
+	if err != nil {
+		t.Fatalf("Transact failed: %v", err)
+	}
+	if len(mock.sentTransactions()) != 0 {
+		t.Fatalf("NoSend transaction was sent")
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to serialize transaction: %v", err)
+	}
+
+	// Broadcast the serialized transaction elsewhere, through a plain client.
+	rt := newRPCTransport()
+	var broadcast hexutil.Bytes
+	rt.handle("eth_sendRawTransaction", func(params []json.RawMessage) (interface{}, error) {
+		return nil, json.Unmarshal(params[0], &broadcast)
+	})
+	prepared := new(types.Transaction)
+	if err = prepared.UnmarshalBinary(raw); err != nil {
+		t.Fatalf("failed to deserialize transaction: %v", err)
+	}
+	if err = dialTransport(t, rt).SendTransaction(ctx, prepared); err != nil {
+		t.Fatalf("failed to broadcast: %v", err)
+	}
+
+	// The runtime recovers the sender and decrypts the calldata.
+	received := new(types.Transaction)
+	if err = received.UnmarshalBinary(broadcast); err != nil {
+		t.Fatalf("broadcast is not a transaction: %v", err)
+	}
+	if sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(0x5afd)), received); err != nil || sender != from {
+		t.Fatalf("transaction not signed by the sender: %v", err)
+	}
+	var envelope sdkTypes.Call
+	var body sdkTypes.CallEnvelopeX25519DeoxysII
+	if err = cbor.Unmarshal(received.Data(), &envelope); err != nil || cbor.Unmarshal(envelope.Body, &body) != nil {
+		t.Fatalf("calldata is not an encrypted envelope")
+	}
+	decrypted, err := newSeededCipher(t, 1).Decrypt(body.Nonce[:], body.Data)
+	if err != nil {
+		t.Fatalf("failed to decrypt calldata: %v", err)
+	}
+	var call sdkTypes.Call
+	var plaintext []byte
+	if err = cbor.Unmarshal(decrypted, &call); err != nil || cbor.Unmarshal(call.Body, &plaintext) != nil {
+		t.Fatalf("failed to decode decrypted calldata")
+	}
+	if want, _ := parsed.Pack("set", big.NewInt(7)); !bytes.Equal(plaintext, want) {
+		t.Fatalf("unexpected calldata %x", plaintext)
+	}
+}
+
+func TestTransactorNoSendLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	signer := sapphire.NewPrivateKeySigner(key)
+	client := localnet.Dial(t)
+	b, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(signer)))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	parsed, _ := abi.JSON(strings.NewReader(setterABI))
+	opts := b.Transactor(signer.Address())
+	opts.Context = ctx
+	addr, deployTx, contract, err := bind.DeployContract(opts, parsed, perSenderStorageCode, b)
+	if err != nil {
+		t.Fatalf("failed to deploy: %v", err)
+	}
+	if _, err = bind.WaitDeployed(ctx, b, deployTx); err != nil {
+		t.Fatalf("deployment failed: %v", err)
+	}
+	opts.NoSend = true
+	tx, err := contract.Transact(opts, "set", big.NewInt(7))
+	if err != nil {
+		t.Fatalf("Transact failed: %v", err)
+	}
+	raw, _ := tx.MarshalBinary()
+
+	// Broadcast from a fresh connection, as another process would.
+	other, err := ethclient.Dial(Networks[0x5afd].DefaultGateway)
+	if err != nil {
+		t.Fatalf("failed to dial localnet: %v", err)
+	}
+	prepared := new(types.Transaction)
+	if err = prepared.UnmarshalBinary(raw); err != nil {
+		t.Fatalf("failed to deserialize transaction: %v", err)
+	}
+	if err = other.SendTransaction(ctx, prepared); err != nil {
+		t.Fatalf("failed to broadcast: %v", err)
+	}
+	receipt, err := bind.WaitMined(ctx, other, prepared)
+	if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("transaction failed: %v", err)
+	}
+
+	plaintext, _ := parsed.Pack("set", big.NewInt(7))
+	stored, err := b.CallContract(ctx, ethereum.CallMsg{From: signer.Address(), To: &addr}, nil)
+	if err != nil {
+		t.Fatalf("failed to read back: %v", err)
+	}
+	if !bytes.Equal(stored, plaintext[:32]) {
+		t.Fatalf("unexpected stored word %x, expected %x", stored, plaintext[:32])
+	}
+}
+
+func TestWithSignedCallDomain(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	msg := ethereum.CallMsg{From: signer.Address(), To: &to, Data: []byte{0xe2, 0x1f, 0x37, 0xce}}
+	custom := sapphire.SignedCallDomain{Name: "example-runtime/evm: signed query", Version: "2.0.0"}
+
+	for _, domain := range []*sapphire.SignedCallDomain{nil, &custom} {
+		opts := []Option{WithKeyring(sapphire.NewKeyring(signer))}
+		expected := sapphire.DefaultSignedCallDomain
+		if domain != nil {
+			opts = append(opts, WithSignedCallDomain(*domain))
+			expected = *domain
+		}
+		b := newMockWrappedBackend(newMockBackend(), nil, opts...)
+		prepared, err := b.PrepareSignedQuery(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("failed to prepare signed query: %v", err)
+		}
+		var pack evm.SignedCallDataPack
+		if err = cbor.Unmarshal(prepared.Data(), &pack); err != nil {
+			t.Fatalf("failed to decode signed query: %v", err)
+		}
+		digest, err := sapphire.SignedCallDigest(expected.SignableCall(b.chainID.Uint64(), msg.From, msg.To, sapphire.DefaultGasLimit, big.NewInt(sapphire.DefaultGasPrice), nil, msg.Data, pack.Leash))
+		if err != nil {
+			t.Fatalf("failed to hash signed query: %v", err)
+		}
+		sig := append([]byte(nil), pack.Signature...)
+		sig[64] -= 27
+		pub, err := crypto.SigToPub(digest[:], sig)
+		if err != nil || crypto.PubkeyToAddress(*pub) != signer.Address() {
+			t.Fatalf("query not signed in domain %+v", expected)
+		}
+	}
+}
+
+func TestSignedCallValidation(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	leash := &evm.Leash{BlockHash: make([]byte, 32), BlockRange: sapphire.DefaultBlockRange}
+	tooLarge := new(big.Int).Lsh(big.NewInt(1), 256)
+
+	for _, tc := range []struct {
+		name  string
+		opts  []sapphire.CallOption
+		msg   ethereum.CallMsg
+		field string // Named in the error, empty if valid.
+	}{
+		{"valid", nil, ethereum.CallMsg{Value: big.NewInt(1), Data: make([]byte, sapphire.DefaultMaxSignedCallDataSize)}, ""},
+		{"max gas", nil, ethereum.CallMsg{Gas: ^uint64(0)}, ""},
+		{"negative value", nil, ethereum.CallMsg{Value: big.NewInt(-1)}, "value"},
+		{"value overflow", nil, ethereum.CallMsg{Value: tooLarge}, "value"},
+		{"negative gas price", nil, ethereum.CallMsg{GasPrice: big.NewInt(-1)}, "gasPrice"},
+		{"gas price overflow", nil, ethereum.CallMsg{GasPrice: tooLarge}, "gasPrice"},
+		{"data too large", nil, ethereum.CallMsg{Data: make([]byte, sapphire.DefaultMaxSignedCallDataSize+1)}, "data"},
+		{"custom data cap", []sapphire.CallOption{sapphire.WithMaxCallDataSize(4)}, ethereum.CallMsg{Data: make([]byte, 5)}, "data"},
+		{"unchecked", []sapphire.CallOption{sapphire.WithoutValidation()}, ethereum.CallMsg{Data: make([]byte, sapphire.DefaultMaxSignedCallDataSize+1)}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.msg.From, tc.msg.To = signer.Address(), &to
+			_, err := packSignedCall(tc.msg, newSeededCipher(t, 1), signer.SignRSV, *big.NewInt(0x5afd), leash, tc.opts...)
+			switch {
+			case tc.field == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.field != "" && (!errors.Is(err, sapphire.ErrInvalidSignedCall) || !strings.Contains(err.Error(), tc.field)):
+				t.Fatalf("expected ErrInvalidSignedCall naming %s, got %v", tc.field, err)
+			}
+		})
+	}
+
+	// PackSignedCall always sets a gas limit, so only the pack can lack one.
+	if _, err := sapphire.NewSignedCall(context.Background(), signer, big.NewInt(0x5afd), &to, nil, sapphire.WithGasLimit(0), sapphire.WithLeash(*leash)); !errors.Is(err, sapphire.ErrInvalidSignedCall) || !strings.Contains(err.Error(), "gasLimit") {
+		t.Fatalf("expected a zero gas limit to be rejected, got %v", err)
+	}
+
+	b := newMockWrappedBackend(newMockBackend(), nil, WithKeyring(sapphire.NewKeyring(signer)), WithMaxSignedCallDataSize(4))
+	if _, err := b.CallContract(context.Background(), ethereum.CallMsg{From: signer.Address(), To: &to, Data: make([]byte, 5)}, nil); !errors.Is(err, sapphire.ErrInvalidSignedCall) {
+		t.Fatalf("expected WithMaxSignedCallDataSize to be honored, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+func TestWrappedBackendConcurrentUse(t *testing.T) {
+	const (
+		workers      = 50
+		opsPerWorker = 20
+	)
+
+	mock := newMockBackend()
+	mock.callResult = cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(TestData)})
+	keyring := newTestKeyring(t, 5)
+	b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+	accounts := keyring.Addresses()
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Keep swapping the cipher while requests are in flight.
+	swapped := make(chan struct{})
+	go func() {
+		defer close(swapped)
+		for ctx.Err() == nil {
+			b.setCipher(sapphire.NewPlainCipher())
+		}
+	}()
+
+	var (
+		wg      sync.WaitGroup
+		sendsMu sync.Mutex
+	)
+	errCh := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			from := accounts[w%len(accounts)]
+			opts := b.Transactor(from)
+			for i := 0; i < opsPerWorker; i++ {
+				switch (w + i) % 3 {
+				case 0:
+					res, err := b.CallContract(ctx, ethereum.CallMsg{From: from, To: &to, Data: []byte{1, 2, 3}}, nil)
+					if err != nil {
+						errCh <- fmt.Errorf("CallContract: %w", err)
+						return
+					}
+					if !bytes.Equal(res, TestData) {
+						errCh <- fmt.Errorf("CallContract: unexpected result %x", res)
+						return
+					}
+				case 1:
+					if _, err := b.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &to, Data: []byte{1, 2, 3}}); err != nil {
+						errCh <- fmt.Errorf("EstimateGas: %w", err)
+						return
+					}
+				case 2:
+					// Fetching and committing a nonce is not atomic, so
+					// workers sharing an account take turns sending.
+					sendsMu.Lock()
+					nonce, err := b.PendingNonceAt(ctx, from)
+					if err == nil {
+						var signedTx *types.Transaction
+						tx := types.NewTransaction(nonce, to, big.NewInt(1), 21_000, opts.GasPrice, []byte{1, 2, 3})
+						if signedTx, err = opts.Signer(from, tx); err == nil {
+							err = b.SendTransaction(ctx, signedTx)
+						}
+					}
+					sendsMu.Unlock()
+					if err != nil {
+						errCh <- fmt.Errorf("SendTransaction: %w", err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	cancel()
+	<-swapped
+	close(errCh)
+	for err := range errCh {
+		t.Fatal(err)
+	}
+
+	signer := types.LatestSignerForChainID(big.NewInt(0x5afd))
+	seen := make(map[common.Address]map[uint64]bool)
+	for _, tx := range mock.sentTransactions() {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			t.Fatalf("failed to recover sender: %v", err)
+		}
+		if seen[from] == nil {
+			seen[from] = make(map[uint64]bool)
+		}
+		if seen[from][tx.Nonce()] {
+			t.Fatalf("account %s reused nonce %d", from, tx.Nonce())
+		}
+		seen[from][tx.Nonce()] = true
+	}
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// DefaultResendAfter is how long SendTransactionAndConfirm waits for a
+	// transaction to be mined before replacing it.
+	DefaultResendAfter = 30 * time.Second
+	// DefaultConfirmPollInterval is how often SendTransactionAndConfirm
+	// checks for a receipt.
+	DefaultConfirmPollInterval = time.Second
+	// DefaultFeeBumpPercent is how much SendTransactionAndConfirm raises fees
+	// by on each replacement, the minimum gateways accept.
+	DefaultFeeBumpPercent = 10
+	// DefaultMaxResends is the number of replacements SendTransactionAndConfirm
+	// sends before it only waits.
+	DefaultMaxResends = 3
+)
+
+// ConfirmOptions configures SendTransactionAndConfirm. The zero value uses
+// the defaults.
+type ConfirmOptions struct {
+	// ResendAfter is how long to wait for a transaction to be mined before
+	// replacing it with one paying higher fees.
+	ResendAfter time.Duration
+	// PollInterval is how often to check whether any of the sent
+	// transactions was mined.
+	PollInterval time.Duration
+	// BumpPercent is the percentage fees are raised by on each replacement.
+	BumpPercent uint64
+	// MaxFee caps the gas price, or fee cap for dynamic fee transactions, of
+	// replacements. Nil means no cap.
+	MaxFee *big.Int
+	// MaxResends is the maximum number of replacements to send.
+	MaxResends int
+	// OnResend, if set, is called after each replacement is sent.
+	OnResend func(replaced, replacement *types.Transaction)
+}
+
+func (o ConfirmOptions) withDefaults() ConfirmOptions {
+	if o.ResendAfter == 0 {
+		o.ResendAfter = DefaultResendAfter
+	}
+	if o.PollInterval == 0 {
+		o.PollInterval = DefaultConfirmPollInterval
+	}
+	if o.BumpPercent == 0 {
+		o.BumpPercent = DefaultFeeBumpPercent
+	}
+	if o.MaxResends == 0 {
+		o.MaxResends = DefaultMaxResends
+	}
+	return o
+}
+
+// UnconfirmedTxError is returned by SendTransactionAndConfirm when none of
+// the transactions it sent was mined.
+type UnconfirmedTxError struct {
+	// TxHash is the hash of the last transaction sent, which callers can keep
+	// watching. Any of the earlier ones may still be mined instead.
+	TxHash common.Hash
+	// Err is the reason for giving up, e.g. the context's error.
+	Err error
+}
+
+func (e *UnconfirmedTxError) Error() string {
+	return fmt.Sprintf("transaction %s not confirmed: %v", e.TxHash.Hex(), e.Err)
+}
+
+func (e *UnconfirmedTxError) Unwrap() error {
+	return e.Err
+}
+
+// SendTransactionAndConfirm sends a signed transaction and waits until it is
+// mined, returning its receipt.
+//
+// If the transaction is not mined within opts.ResendAfter, it is replaced by
+// a transaction with the same nonce and fees raised by opts.BumpPercent,
+// encrypted anew from the plaintext calldata if this client encrypted the
+// original. Replacements continue up to opts.MaxResends times or until the
+// fees reach opts.MaxFee, after which the transactions sent so far are
+// waited for until ctx is done.
+//
+// If ctx is done first, the returned error is an *UnconfirmedTxError holding
+// the hash of the last transaction sent.
+func (b *WrappedBackend) SendTransactionAndConfirm(ctx context.Context, tx *types.Transaction, opts ConfirmOptions) (*types.Receipt, error) {
+	opts = opts.withDefaults()
+	from, err := types.Sender(types.LatestSignerForChainID(&b.chainID), tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover transaction sender: %w", err)
+	}
+	if err = b.SendTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	sent := []common.Hash{tx.Hash()}
+	// latest is the last transaction accepted by the gateway, basis the one
+	// the next replacement's fees are raised from.
+	latest, basis := tx, tx
+	resends := 0
+	resendAt := time.Now().Add(opts.ResendAfter)
+	poll := time.NewTicker(opts.PollInterval)
+	defer poll.Stop()
+	for {
+		for _, txHash := range sent {
+			if receipt, err := b.TransactionReceipt(ctx, txHash); err == nil {
+				return receipt, nil
+			}
+		}
+
+		if resends < opts.MaxResends && !time.Now().Before(resendAt) {
+			replacement, err := b.replaceTx(ctx, from, basis, opts)
+			switch {
+			case err != nil:
+				return nil, &UnconfirmedTxError{TxHash: latest.Hash(), Err: err}
+			case replacement == nil:
+				// The fee cap is reached, wait for what was sent.
+				resends = opts.MaxResends
+			default:
+				resends++
+				basis = replacement
+				err = b.SendTransaction(ctx, replacement)
+				switch {
+				case err == nil:
+					sent = append(sent, replacement.Hash())
+					if opts.OnResend != nil {
+						opts.OnResend(latest, replacement)
+					}
+					latest = replacement
+					resendAt = time.Now().Add(opts.ResendAfter)
+				case isUnderpriced(err):
+					// Try again right away with higher fees.
+				case isNonceUsed(err):
+					// One of the transactions was mined or is known already.
+					resends = opts.MaxResends
+				default:
+					return nil, &UnconfirmedTxError{TxHash: latest.Hash(), Err: err}
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, &UnconfirmedTxError{TxHash: latest.Hash(), Err: ctx.Err()}
+		case <-poll.C:
+		}
+	}
+}
+
+// replaceTx returns tx re-signed with bumped fees, or nil if the fees are
+// already at opts.MaxFee.
+func (b *WrappedBackend) replaceTx(ctx context.Context, from common.Address, tx *types.Transaction, opts ConfirmOptions) (*types.Transaction, error) {
+	gasPrice, tipCap, feeCap := bumpFee(tx.GasPrice(), opts), bumpFee(tx.GasTipCap(), opts), bumpFee(tx.GasFeeCap(), opts)
+	if tx.Type() == types.DynamicFeeTxType {
+		if feeCap.Cmp(tx.GasFeeCap()) <= 0 {
+			return nil, nil
+		}
+		if tipCap.Cmp(feeCap) > 0 {
+			tipCap = feeCap
+		}
+	} else if gasPrice.Cmp(tx.GasPrice()) <= 0 {
+		return nil, nil
+	}
+
+	data := tx.Data()
+	if plaintext, ok := b.plaintexts.get(tx.Hash()); ok {
+		data = plaintext
+	}
+	unsigned, err := withData(tx, data, tx.Gas())
+	if err != nil {
+		return nil, err
+	}
+	if unsigned, err = withFees(unsigned, gasPrice, tipCap, feeCap); err != nil {
+		return nil, err
+	}
+	return b.signTx(ctx, from, unsigned)
+}
+
+// bumpFee raises fee by opts.BumpPercent, rounding up, without exceeding
+// opts.MaxFee.
+func bumpFee(fee *big.Int, opts ConfirmOptions) *big.Int {
+	bumped := new(big.Int).Mul(fee, new(big.Int).SetUint64(100+opts.BumpPercent))
+	bumped.Add(bumped, big.NewInt(99))
+	bumped.Div(bumped, big.NewInt(100))
+	if opts.MaxFee != nil && bumped.Cmp(opts.MaxFee) > 0 {
+		bumped.Set(opts.MaxFee)
+	}
+	if bumped.Cmp(fee) < 0 {
+		return new(big.Int).Set(fee)
+	}
+	return bumped
+}
+
+// isUnderpriced reports whether the gateway rejected a replacement for not
+// paying enough more than the transaction it replaces.
+func isUnderpriced(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "underpriced")
+}
+
+// isNonceUsed reports whether the gateway rejected a transaction because its
+// nonce was used by a mined or identical pending transaction.
+func isNonceUsed(err error) bool {
+	return isNonceTooLow(err) || strings.Contains(strings.ToLower(err.Error()), "already known")
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+func TestSendTransactionAndConfirm(t *testing.T) {
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	fastOpts := ConfirmOptions{ResendAfter: 20 * time.Millisecond, PollInterval: 5 * time.Millisecond}
+
+	setup := func(t *testing.T) (*WrappedBackend, *mockBackend, *types.Transaction) {
+		mock := newMockBackend()
+		b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+		tx, err := b.Transactor(from).Signer(from, types.NewTransaction(7, to, big.NewInt(0), 100_000, big.NewInt(sapphire.DefaultGasPrice), TestData))
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		return b, mock, tx
+	}
+
+	t.Run("mined", func(t *testing.T) {
+		b, mock, tx := setup(t)
+		mock.mine(tx, types.ReceiptStatusSuccessful, 21_000)
+		opts := fastOpts
+		opts.OnResend = func(_, _ *types.Transaction) {
+			t.Errorf("mined transaction was replaced")
+		}
+		receipt, err := b.SendTransactionAndConfirm(context.Background(), tx, opts)
+		if err != nil {
+			t.Fatalf("SendTransactionAndConfirm failed: %v", err)
+		}
+		if receipt.TxHash != tx.Hash() || len(mock.sentTransactions()) != 1 {
+			t.Fatalf("unexpected receipt for %s after %d sends", receipt.TxHash.Hex(), len(mock.sentTransactions()))
+		}
+	})
+
+	t.Run("replaced", func(t *testing.T) {
+		b, mock, tx := setup(t)
+		opts := fastOpts
+		var mu sync.Mutex
+		var replacements []*types.Transaction
+		opts.OnResend = func(replaced, replacement *types.Transaction) {
+			mu.Lock()
+			defer mu.Unlock()
+			replacements = append(replacements, replacement)
+			if len(replacements) == 2 {
+				mock.mine(replacement, types.ReceiptStatusSuccessful, 21_000)
+			}
+		}
+		receipt, err := b.SendTransactionAndConfirm(context.Background(), tx, opts)
+		if err != nil {
+			t.Fatalf("SendTransactionAndConfirm failed: %v", err)
+		}
+		if len(replacements) != 2 || receipt.TxHash != replacements[1].Hash() {
+			t.Fatalf("expected the second replacement to be mined, got %s", receipt.TxHash.Hex())
+		}
+		prev := tx
+		for _, replacement := range replacements {
+			if replacement.Nonce() != tx.Nonce() {
+				t.Fatalf("replacement uses nonce %d instead of %d", replacement.Nonce(), tx.Nonce())
+			}
+			minPrice := new(big.Int).Div(new(big.Int).Mul(prev.GasPrice(), big.NewInt(110)), big.NewInt(100))
+			if replacement.GasPrice().Cmp(minPrice) < 0 {
+				t.Fatalf("replacement gas price %v is not bumped enough from %v", replacement.GasPrice(), prev.GasPrice())
+			}
+			if plaintext, ok := b.plaintexts.get(replacement.Hash()); !ok || !bytes.Equal(plaintext, TestData) {
+				t.Fatalf("replacement does not carry the original plaintext")
+			}
+			prev = replacement
+		}
+	})
+
+	t.Run("underpriced", func(t *testing.T) {
+		b, mock, tx := setup(t)
+		var sends int
+		mock.hook = func(_ context.Context, method string) error {
+			if method != "eth_sendRawTransaction" {
+				return nil
+			}
+			if sends++; sends == 2 {
+				return fmt.Errorf("replacement transaction underpriced")
+			}
+			return nil
+		}
+		opts := fastOpts
+		opts.OnResend = func(_, replacement *types.Transaction) {
+			mock.mine(replacement, types.ReceiptStatusSuccessful, 21_000)
+		}
+		receipt, err := b.SendTransactionAndConfirm(context.Background(), tx, opts)
+		if err != nil {
+			t.Fatalf("SendTransactionAndConfirm failed: %v", err)
+		}
+		// The rejected replacement's fees are bumped again.
+		expected := bumpFee(bumpFee(tx.GasPrice(), opts.withDefaults()), opts.withDefaults())
+		if sent := mock.sentTransactions(); len(sent) != 2 || sent[1].GasPrice().Cmp(expected) != 0 || receipt.TxHash != sent[1].Hash() {
+			t.Fatalf("unexpected replacement after an underpriced resend")
+		}
+	})
+
+	t.Run("fee cap", func(t *testing.T) {
+		b, mock, tx := setup(t)
+		opts := fastOpts
+		opts.MaxFee = new(big.Int).Add(tx.GasPrice(), big.NewInt(1))
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		_, err := b.SendTransactionAndConfirm(ctx, tx, opts)
+		var unconfirmed *UnconfirmedTxError
+		if !errors.As(err, &unconfirmed) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected an unconfirmed transaction error, got %v", err)
+		}
+		sent := mock.sentTransactions()
+		if len(sent) != 2 || sent[1].GasPrice().Cmp(opts.MaxFee) != 0 || unconfirmed.TxHash != sent[1].Hash() {
+			t.Fatalf("replacements did not stop at the fee cap")
+		}
+	})
+
+	t.Run("max resends", func(t *testing.T) {
+		b, mock, tx := setup(t)
+		opts := fastOpts
+		opts.MaxResends = 2
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		_, err := b.SendTransactionAndConfirm(ctx, tx, opts)
+		var unconfirmed *UnconfirmedTxError
+		if !errors.As(err, &unconfirmed) {
+			t.Fatalf("expected an unconfirmed transaction error, got %v", err)
+		}
+		sent := mock.sentTransactions()
+		if len(sent) != 3 || unconfirmed.TxHash != sent[2].Hash() {
+			t.Fatalf("expected 3 sends ending with the returned hash, got %d", len(sent))
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+// TestSignedQueryConformanceLocalnet has the runtime's own verifier check
+// queries signed over SignableCall's digest, assembled without the signing
+// code the rest of the package uses, so that the two can't share a bug.
+func TestSignedQueryConformanceLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	owner := sapphire.NewPrivateKeySigner(key)
+	client := localnet.Dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	deployer, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(owner)))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	parsed, _ := abi.JSON(strings.NewReader(ownerGuardedABI))
+	opts := deployer.Transactor(owner.Address())
+	opts.Context = ctx
+	_, tx, _, err := DeployConfidential(opts, deployer, parsed, ownerGuardedCode)
+	if err != nil {
+		t.Fatalf("DeployConfidential failed: %v", err)
+	}
+	addr, err := deployer.WaitDeployed(ctx, tx)
+	if err != nil {
+		t.Fatalf("deployment failed: %v", err)
+	}
+	cipher, err := NewCipherContext(ctx, client)
+	if err != nil {
+		t.Fatalf("failed to fetch runtime public key: %v", err)
+	}
+
+	// query signs a call of secret() in domain and sends it with the plain client.
+	calldata := parsed.Methods["secret"].ID
+	query := func(domain sapphire.SignedCallDomain) ([]byte, error) {
+		head, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			t.Fatalf("failed to fetch head: %v", err)
+		}
+		nonce, err := client.PendingNonceAt(ctx, owner.Address())
+		if err != nil {
+			t.Fatalf("failed to fetch nonce: %v", err)
+		}
+		leash := evm.Leash{
+			Nonce:       nonce,
+			BlockNumber: head.Number.Uint64() - 1,
+			BlockHash:   head.ParentHash[:],
+			BlockRange:  sapphire.DefaultBlockRange,
+		}
+		gasPrice := big.NewInt(sapphire.DefaultGasPrice)
+		digest, err := sapphire.SignedCallDigest(domain.SignableCall(0x5afd, owner.Address(), &addr, sapphire.DefaultGasLimit, gasPrice, nil, calldata, leash))
+		if err != nil {
+			t.Fatalf("failed to hash query: %v", err)
+		}
+		sig, err := crypto.Sign(digest[:], key)
+		if err != nil {
+			t.Fatalf("failed to sign query: %v", err)
+		}
+		sig[64] += 27
+		pack := evm.SignedCallDataPack{Data: *cipher.EncryptEnvelope(calldata), Leash: leash, Signature: sig}
+		res, err := client.CallContract(ctx, ethereum.CallMsg{
+			From:     owner.Address(),
+			To:       &addr,
+			Gas:      sapphire.DefaultGasLimit,
+			GasPrice: gasPrice,
+			Data:     cbor.Marshal(pack),
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.DecryptEncoded(res)
+	}
+
+	res, err := query(sapphire.DefaultSignedCallDomain)
+	if err != nil {
+		t.Fatalf("runtime rejected the signed query: %v", err)
+	}
+	if new(big.Int).SetBytes(res).Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("query not authenticated as the owner: %x", res)
+	}
+	// A signature in another domain recovers to another account, which is
+	// not the owner.
+	if _, err = query(sapphire.SignedCallDomain{Name: sapphire.DefaultSignedCallDomain.Name, Version: "0.0.0"}); err == nil {
+		t.Fatalf("expected a query signed in another domain to fail")
+	}
+
+	// The package's own signing path agrees with the runtime as well.
+	caller := bind.NewBoundContract(addr, parsed, deployer, deployer, deployer)
+	var out []interface{}
+	if err = caller.Call(&bind.CallOpts{Context: ctx, From: owner.Address()}, &out, "secret"); err != nil {
+		t.Fatalf("signed call failed: %v", err)
+	}
+}
+
+// whoamiCode returns the caller when called, as the runtime authenticated it.
+// Run as a deployment, its constructor does the same.
+var (
+	whoamiCode        = common.FromHex("600980600b6000396000f3" + whoamiRuntimeCode)
+	whoamiRuntimeCode = "3360005260206000f3"
+)
+
+const whoamiABI = `[{"type":"function","name":"whoami","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]}]`
+
+// TestNewSignedCallConformanceLocalnet has the runtime recover the signer of
+// queries signed by NewSignedCall with varied parameters, as msg.sender of a
+// contract, so that hashing or encoding that diverges from the runtime's
+// fails even where the package's own recompute agrees with itself.
+func TestNewSignedCallConformanceLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	signer := sapphire.NewPrivateKeySigner(localnet.Accounts[0].Key)
+	client := localnet.Dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	deployer, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(signer)))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	parsed, _ := abi.JSON(strings.NewReader(whoamiABI))
+	opts := deployer.Transactor(signer.Address())
+	opts.Context = ctx
+	_, tx, _, err := DeployConfidential(opts, deployer, parsed, whoamiCode)
+	if err != nil {
+		t.Fatalf("DeployConfidential failed: %v", err)
+	}
+	addr, err := deployer.WaitDeployed(ctx, tx)
+	if err != nil {
+		t.Fatalf("deployment failed: %v", err)
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		t.Fatalf("failed to fetch chain ID: %v", err)
+	}
+	cipher, err := NewCipherContext(ctx, client)
+	if err != nil {
+		t.Fatalf("failed to fetch runtime public key: %v", err)
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to fetch head: %v", err)
+	}
+	// The runtime accepts leashes with nonces ahead of the account's and
+	// ranges reaching back past genesis.
+	maxLeash := evm.Leash{
+		Nonce:       math.MaxUint64,
+		BlockNumber: head.Number.Uint64(),
+		BlockHash:   head.Hash().Bytes(),
+		BlockRange:  math.MaxUint64,
+	}
+
+	calldata := parsed.Methods["whoami"].ID
+	creation := common.FromHex(whoamiRuntimeCode)
+	for _, tc := range []struct {
+		name     string
+		to       *common.Address
+		data     []byte
+		gasLimit uint64
+		gasPrice *big.Int
+		value    *big.Int
+		leash    *evm.Leash
+	}{
+		{name: "Call", to: &addr, data: calldata},
+		{name: "Creation", data: creation},
+		{name: "NoData", to: &addr},
+		{name: "ZeroValue", to: &addr, data: calldata, value: new(big.Int)},
+		{name: "Value", to: &addr, data: calldata, value: big.NewInt(1)},
+		{name: "GasParameters", to: &addr, data: calldata, gasLimit: 1_000_000, gasPrice: big.NewInt(sapphire.DefaultGasPrice + 1)},
+		{name: "MaxLeash", to: &addr, data: calldata, leash: &maxLeash},
+		{name: "MaxLeashCreation", data: creation, leash: &maxLeash},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := ethereum.CallMsg{
+				From:     signer.Address(),
+				To:       tc.to,
+				Gas:      sapphire.DefaultGasLimit,
+				GasPrice: big.NewInt(sapphire.DefaultGasPrice),
+				Value:    tc.value,
+			}
+			opts := []sapphire.CallOption{WithAutoLeash(client)}
+			if tc.gasLimit != 0 {
+				msg.Gas = tc.gasLimit
+				opts = append(opts, sapphire.WithGasLimit(tc.gasLimit))
+			}
+			if tc.gasPrice != nil {
+				msg.GasPrice = tc.gasPrice
+				opts = append(opts, sapphire.WithGasPrice(tc.gasPrice))
+			}
+			if tc.value != nil {
+				opts = append(opts, sapphire.WithValue(tc.value))
+			}
+			if tc.leash != nil {
+				opts = append(opts, sapphire.WithLeash(*tc.leash))
+			}
+			pack, err := sapphire.NewSignedCall(ctx, signer, chainID, tc.to, tc.data, opts...)
+			if err != nil {
+				t.Fatalf("NewSignedCall failed: %v", err)
+			}
+			if envelope := cipher.EncryptEnvelope(tc.data); envelope != nil {
+				pack.Data = *envelope
+			}
+			msg.Data = cbor.Marshal(pack)
+			res, err := client.CallContract(ctx, msg, nil)
+			if err != nil {
+				t.Fatalf("runtime rejected the signed query: %v", err)
+			}
+			if pack.Data.Format != sdkTypes.CallFormatPlain {
+				if res, err = cipher.DecryptEncoded(res); err != nil {
+					t.Fatalf("failed to decrypt result: %v", err)
+				}
+			}
+			if got := common.BytesToAddress(res); len(res) != 32 || got != signer.Address() {
+				t.Fatalf("runtime recovered %s instead of %s from %x", got, signer.Address(), res)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// verifyNoGoroutineLeaks fails the test if goroutines started during the test
+// are still running shortly after it finishes.
+func verifyNoGoroutineLeaks(t *testing.T) {
+	before := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			after := runtime.NumGoroutine()
+			if after <= before {
+				return
+			}
+			if time.Now().After(deadline) {
+				buf := make([]byte, 1<<16)
+				n := runtime.Stack(buf, true)
+				t.Fatalf("leaked %d goroutines:\n%s", after-before, buf[:n])
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+// requireCancelledWithin runs fn with a context that is cancelled after a
+// short delay and asserts that fn returns the context error promptly.
+func requireCancelledWithin(t *testing.T, bound time.Duration, fn func(ctx context.Context) error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() { done <- fn(ctx) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context error, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > bound {
+			t.Fatalf("returned after %s, expected within %s", elapsed, bound)
+		}
+	case <-time.After(bound + time.Second):
+		t.Fatalf("did not return after cancellation")
+	}
+}
+
+func blockUntilDone(ctx context.Context, _ string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type blockingContextSigner struct {
+	sapphire.SignerWithAddress
+}
+
+func (s blockingContextSigner) SignRSVContext(ctx context.Context, _ [32]byte) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestContextCancelKeyFetch(t *testing.T) {
+	verifyNoGoroutineLeaks(t)
+	rt := newRPCTransport()
+	rt.block("oasis_callDataPublicKey")
+	client := dialTransport(t, rt)
+
+	requireCancelledWithin(t, 200*time.Millisecond, func(ctx context.Context) error {
+		_, err := NewCipherContext(ctx, client)
+		return err
+	})
+	requireCancelledWithin(t, 200*time.Millisecond, func(ctx context.Context) error {
+		_, err := WrapClientContext(ctx, client, nil)
+		return err
+	})
+}
+
+func TestContextCancelPipelineStages(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	call := ethereum.CallMsg{From: signer.Address(), To: &to, Data: []byte{1, 2, 3}}
+	tx := types.NewTransaction(0, to, big.NewInt(0), 100_000, big.NewInt(sapphire.DefaultGasPrice), []byte{1, 2, 3})
+
+	for _, tc := range []struct {
+		name   string
+		setup  func(m *mockBackend) sapphire.SignerWithAddress
+		action func(ctx context.Context, b *WrappedBackend) error
+	}{
+		{
+			name: "leash",
+			setup: func(m *mockBackend) sapphire.SignerWithAddress {
+				m.hook = func(ctx context.Context, method string) error {
+					if method == "eth_getBlockByNumber" {
+						return blockUntilDone(ctx, method)
+					}
+					return nil
+				}
+				return signer
+			},
+			action: func(ctx context.Context, b *WrappedBackend) error {
+				_, err := b.CallContract(ctx, call, nil)
+				return err
+			},
+		},
+		{
+			name: "sign query",
+			setup: func(*mockBackend) sapphire.SignerWithAddress {
+				return blockingContextSigner{signer}
+			},
+			action: func(ctx context.Context, b *WrappedBackend) error {
+				_, err := b.CallContract(ctx, call, nil)
+				return err
+			},
+		},
+		{
+			name: "sign transaction",
+			setup: func(*mockBackend) sapphire.SignerWithAddress {
+				return blockingContextSigner{signer}
+			},
+			action: func(ctx context.Context, b *WrappedBackend) error {
+				opts := b.Transactor(signer.Address())
+				opts.Context = ctx
+				_, err := opts.Signer(signer.Address(), tx)
+				return err
+			},
+		},
+		{
+			name: "call",
+			setup: func(m *mockBackend) sapphire.SignerWithAddress {
+				m.hook = func(ctx context.Context, method string) error {
+					if method == "eth_call" {
+						return blockUntilDone(ctx, method)
+					}
+					return nil
+				}
+				return signer
+			},
+			action: func(ctx context.Context, b *WrappedBackend) error {
+				_, err := b.CallContract(ctx, call, nil)
+				return err
+			},
+		},
+		{
+			name: "estimate",
+			setup: func(m *mockBackend) sapphire.SignerWithAddress {
+				m.hook = func(ctx context.Context, method string) error {
+					if method == "eth_estimateGas" {
+						return blockUntilDone(ctx, method)
+					}
+					return nil
+				}
+				return signer
+			},
+			action: func(ctx context.Context, b *WrappedBackend) error {
+				_, err := b.EstimateGas(ctx, call)
+				return err
+			},
+		},
+		{
+			name: "send",
+			setup: func(m *mockBackend) sapphire.SignerWithAddress {
+				m.hook = func(ctx context.Context, method string) error {
+					if method == "eth_sendRawTransaction" {
+						return blockUntilDone(ctx, method)
+					}
+					return nil
+				}
+				return signer
+			},
+			action: func(ctx context.Context, b *WrappedBackend) error {
+				signedTx, err := b.PrepareTransaction(context.Background(), signer.Address(), tx)
+				if err != nil {
+					return err
+				}
+				return b.SendTransaction(ctx, signedTx)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			verifyNoGoroutineLeaks(t)
+			m := newMockBackend()
+			s := tc.setup(m)
+			b := newMockWrappedBackend(m, nil, WithKeyring(sapphire.NewKeyring(s)))
+			requireCancelledWithin(t, 200*time.Millisecond, func(ctx context.Context) error {
+				return tc.action(ctx, b)
+			})
+		})
+	}
+}
+
+func TestContextCancelBeforeEncryption(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	m := newMockBackend()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// A plain signer that cannot be interrupted; the context is cancelled
+	// while it signs, so nothing after signing must run.
+	b := newMockWrappedBackend(m, func(digest [32]byte) ([]byte, error) {
+		cancel()
+		return signer.SignRSV(digest)
+	})
+
+	_, err := b.CallContract(ctx, ethereum.CallMsg{From: signer.Address(), To: &to, Data: []byte{1}}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if calls := m.receivedCalls(); len(calls) != 0 {
+		t.Fatalf("cancelled call reached the gateway")
+	}
+}
+
+func TestContextCancelCopiedTransactOpts(t *testing.T) {
+	verifyNoGoroutineLeaks(t)
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	parsed, err := abi.JSON(strings.NewReader(setterABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+
+	m := newMockBackend()
+	m.hook = func(ctx context.Context, method string) error {
+		if method == "eth_sendRawTransaction" {
+			return blockUntilDone(ctx, method)
+		}
+		return nil
+	}
+	b := newMockWrappedBackend(m, nil, WithKeyring(sapphire.NewKeyring(signer)))
+	opts := b.Transactor(signer.Address())
+	contract := bind.NewBoundContract(to, parsed, b, b, b)
+
+	// The Context of a copy, as abigen sessions make, bounds sending.
+	requireCancelledWithin(t, 200*time.Millisecond, func(ctx context.Context) error {
+		copied := *opts
+		copied.Context = ctx
+		copied.GasLimit = 100_000
+		_, err := contract.Transact(&copied, "set", big.NewInt(7))
+		return err
+	})
+}
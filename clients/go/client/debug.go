@@ -0,0 +1,128 @@
+package client
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// DebugHook receives a DebugEvent for every call, estimate, transaction and
+// key fetch sent by a wrapped client. It is called synchronously once the
+// gateway answered, so it should return quickly.
+type DebugHook func(DebugEvent)
+
+// DebugEvent describes a request sent to the gateway and its response.
+//
+// Plaintext calldata is only identified by its length and hash unless
+// WithUnsafeDebugPlaintext was given, so events can be logged safely.
+type DebugEvent struct {
+	// Method is the JSON-RPC method, e.g. "eth_call".
+	Method string `json:"method"`
+	// Target is the called contract or the transaction's recipient, zero if
+	// there is none.
+	Target common.Address `json:"target"`
+	// ABIMethod is the ABI method, if the request was made by an ABI helper
+	// such as PackAndCall.
+	ABIMethod string `json:"abiMethod,omitempty"`
+	// Block is the block a call was made at, nil for the latest one.
+	Block *big.Int `json:"block,omitempty"`
+	// PlaintextLen is the length of the calldata before encryption.
+	PlaintextLen int `json:"plaintextLen"`
+	// PlaintextHash is the Keccak-256 hash of the calldata before
+	// encryption. It is zero if the plaintext is empty or unknown, e.g. for
+	// prepared queries and transactions encrypted elsewhere.
+	PlaintextHash common.Hash `json:"plaintextHash"`
+	// Plaintext is the calldata before encryption. It is only set with
+	// WithUnsafeDebugPlaintext.
+	Plaintext hexutil.Bytes `json:"plaintext,omitempty"`
+	// Envelope is the data as sent: the encrypted calldata or signed query of
+	// calls and estimates, or the raw signed transaction of sends.
+	Envelope hexutil.Bytes `json:"envelope,omitempty"`
+	// Leash summarizes the leash of signed queries, nil for other requests.
+	Leash *LeashSummary `json:"leash,omitempty"`
+	// Response is the JSON encoding of the gateway's result, before
+	// decryption. It is nil if the request failed.
+	Response json.RawMessage `json:"response,omitempty"`
+	// GasLimit is the gas limit the client chose: the estimate returned after
+	// envelope padding and WithGasMargin for estimates, the transaction's gas
+	// limit for sends. It is zero for other requests.
+	GasLimit uint64 `json:"gasLimit,omitempty"`
+	// Err is the error returned by the gateway, if any.
+	Err error `json:"-"`
+}
+
+// LeashSummary describes the leash a signed query is bound to.
+type LeashSummary struct {
+	Nonce       uint64      `json:"nonce"`
+	BlockNumber uint64      `json:"blockNumber"`
+	BlockHash   common.Hash `json:"blockHash"`
+	BlockRange  uint64      `json:"blockRange"`
+}
+
+// debugRequest reports a request to the debug hook, if one is set. call is
+// what the request is for, plaintext is nil if unknown, envelope is the data sent and response the result.
+func (b *WrappedBackend) debugRequest(call callContext, plaintext, envelope []byte, response interface{}, err error) {
+	if b.debug == nil {
+		return
+	}
+	b.debug(b.newDebugEvent(call, plaintext, envelope, response, err))
+}
+
+// debugGas is debugRequest for estimates and sends, which also report the
+// gas limit chosen.
+func (b *WrappedBackend) debugGas(call callContext, plaintext, envelope []byte, response interface{}, gasLimit uint64, err error) {
+	if b.debug == nil {
+		return
+	}
+	ev := b.newDebugEvent(call, plaintext, envelope, response, err)
+	if err == nil {
+		ev.GasLimit = gasLimit
+	}
+	b.debug(ev)
+}
+
+func (b *WrappedBackend) newDebugEvent(call callContext, plaintext, envelope []byte, response interface{}, err error) DebugEvent {
+	ev := DebugEvent{
+		Method:       call.op,
+		Target:       call.target,
+		ABIMethod:    call.method,
+		Block:        call.block,
+		PlaintextLen: len(plaintext),
+		Envelope:     common.CopyBytes(envelope),
+		Leash:        leashSummary(envelope),
+		Err:          b.mw.redact.err(err),
+	}
+	if len(plaintext) > 0 {
+		ev.PlaintextHash = crypto.Keccak256Hash(plaintext)
+		if b.debugPlaintext {
+			ev.Plaintext = common.CopyBytes(plaintext)
+		}
+	}
+	if err == nil && response != nil {
+		if raw, err := json.Marshal(response); err == nil {
+			ev.Response = raw
+		}
+	}
+	return ev
+}
+
+// leashSummary returns the leash of a signed query, or nil if data is not one.
+func leashSummary(data []byte) *LeashSummary {
+	if len(data) == 0 {
+		return nil
+	}
+	pack, err := (sapphire.DecodeLimits{}).DecodeSignedCall(data)
+	if err != nil {
+		return nil
+	}
+	return &LeashSummary{
+		Nonce:       pack.Leash.Nonce,
+		BlockNumber: pack.Leash.BlockNumber,
+		BlockHash:   common.BytesToHash(pack.Leash.BlockHash),
+		BlockRange:  pack.Leash.BlockRange,
+	}
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// debugSecret is calldata that must never show up in redacted debug events.
+var debugSecret = []byte("confidential calldata that must never be logged")
+
+// debugRecorder collects debug events.
+type debugRecorder struct {
+	mu     sync.Mutex
+	events []DebugEvent
+}
+
+func (r *debugRecorder) hook(ev DebugEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+func (r *debugRecorder) recorded() []DebugEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]DebugEvent(nil), r.events...)
+}
+
+// runDebugRequests sends a call, signed call, estimate, transaction, key
+// fetch and raw JSON-RPC call carrying debugSecret, and returns the events.
+func runDebugRequests(t *testing.T, opts ...Option) []DebugEvent {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	rt := newRPCTransport()
+	rt.handle("eth_call", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Bytes(cbor.Marshal(sdkTypes.CallResult{Ok: opaqueResult})), nil
+	})
+	rt.handle("eth_estimateGas", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Uint64(21_000), nil
+	})
+	rt.handle("eth_sendRawTransaction", func([]json.RawMessage) (interface{}, error) {
+		return common.Hash{}, nil
+	})
+
+	rec := &debugRecorder{}
+	opts = append([]Option{WithDebugHook(rec.hook), WithKeyring(sapphire.NewKeyring(signer))}, opts...)
+	b, err := WrapClient(dialTransport(t, rt), nil, opts...)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	if _, err = b.CallContract(ctx, ethereum.CallMsg{To: &to, Data: debugSecret}, nil); err != nil {
+		t.Fatalf("CallContract failed: %v", err)
+	}
+	if _, err = b.CallContract(ctx, ethereum.CallMsg{From: signer.Address(), To: &to, Data: debugSecret}, nil); err != nil {
+		t.Fatalf("signed CallContract failed: %v", err)
+	}
+	if _, err = b.EstimateGas(ctx, ethereum.CallMsg{From: signer.Address(), To: &to, Data: debugSecret}); err != nil {
+		t.Fatalf("EstimateGas failed: %v", err)
+	}
+	txOpts := b.Transactor(signer.Address())
+	tx, err := txOpts.Signer(signer.Address(), types.NewTransaction(0, to, big.NewInt(1), 100_000, txOpts.GasPrice, debugSecret))
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if err = b.SendTransaction(ctx, tx); err != nil {
+		t.Fatalf("SendTransaction failed: %v", err)
+	}
+	if err = b.RefreshCipher(ctx); err != nil {
+		t.Fatalf("RefreshCipher failed: %v", err)
+	}
+
+	c, err := WrapRPCClient(dialTransport(t, rt).Client(), nil, opts...)
+	if err != nil {
+		t.Fatalf("failed to wrap rpc client: %v", err)
+	}
+	var res hexutil.Bytes
+	call := map[string]interface{}{"to": to, "data": hexutil.Bytes(debugSecret)}
+	if err = c.CallContext(ctx, &res, "eth_call", call, "latest"); err != nil {
+		t.Fatalf("eth_call failed: %v", err)
+	}
+	return rec.recorded()
+}
+
+func TestDebugHookRedaction(t *testing.T) {
+	events := runDebugRequests(t)
+
+	methods := make(map[string]int)
+	secretHash := crypto.Keccak256Hash(debugSecret)
+	forbidden := [][]byte{
+		debugSecret,
+		[]byte(hex.EncodeToString(debugSecret)),
+		[]byte(base64.StdEncoding.EncodeToString(debugSecret)),
+	}
+	for _, ev := range events {
+		methods[ev.Method]++
+		raw, err := json.Marshal(ev)
+		if err != nil {
+			t.Fatalf("failed to encode event: %v", err)
+		}
+		dump := append(raw, fmt.Sprintf("%+v %x", ev, ev.Envelope)...)
+		for _, f := range forbidden {
+			if bytes.Contains(dump, f) {
+				t.Fatalf("%s event leaks the plaintext: %s", ev.Method, raw)
+			}
+		}
+		if ev.Plaintext != nil {
+			t.Fatalf("%s event carries the plaintext without the unsafe flag", ev.Method)
+		}
+		switch ev.Method {
+		case "oasis_callDataPublicKey":
+			if ev.PlaintextLen != 0 || len(ev.Response) == 0 {
+				t.Fatalf("unexpected key fetch event %s", raw)
+			}
+		default:
+			if ev.PlaintextLen != len(debugSecret) || ev.PlaintextHash != secretHash {
+				t.Fatalf("%s event does not identify the plaintext: %s", ev.Method, raw)
+			}
+			if len(ev.Envelope) == 0 || len(ev.Response) == 0 {
+				t.Fatalf("%s event is missing the envelope or response: %s", ev.Method, raw)
+			}
+		}
+	}
+
+	// The initial key fetch, its refresh, and that of the RPC client.
+	want := map[string]int{
+		"oasis_callDataPublicKey": 3,
+		"eth_call":                3,
+		"eth_estimateGas":         1,
+		"eth_sendRawTransaction":  1,
+	}
+	for method, n := range want {
+		if methods[method] != n {
+			t.Fatalf("expected %d %s events, got %d", n, method, methods[method])
+		}
+	}
+
+	var leashes int
+	for _, ev := range events {
+		if ev.Leash != nil {
+			leashes++
+			if ev.Leash.BlockNumber != 99 || ev.Leash.BlockRange != sapphire.DefaultBlockRange {
+				t.Fatalf("unexpected leash %+v", ev.Leash)
+			}
+		}
+	}
+	if leashes != 2 {
+		t.Fatalf("expected the signed call and estimate to carry leashes, got %d", leashes)
+	}
+}
+
+func TestDebugHookUnsafePlaintext(t *testing.T) {
+	for _, ev := range runDebugRequests(t, WithUnsafeDebugPlaintext()) {
+		if ev.Method == "oasis_callDataPublicKey" {
+			continue
+		}
+		if !bytes.Equal(ev.Plaintext, debugSecret) {
+			t.Fatalf("%s event is missing the plaintext", ev.Method)
+		}
+	}
+}
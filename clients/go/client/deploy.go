@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// DefaultDeployPollInterval is how often WaitDeployed checks for a receipt.
+	DefaultDeployPollInterval = time.Second
+	// maxDeployPollFailures is how many consecutive transient errors
+	// WaitDeployed tolerates while polling for a receipt.
+	maxDeployPollFailures = 10
+)
+
+// deployPollInterval is DefaultDeployPollInterval, shortened by tests.
+var deployPollInterval = DefaultDeployPollInterval
+
+// DeployFailedError is returned by WaitDeployed for deployments that were
+// mined but failed, e.g. because the constructor reverted.
+type DeployFailedError struct {
+	Receipt *types.Receipt
+	// Diagnosis tells why the deployment failed. It is nil if the failure
+	// could not be diagnosed, see DiagnoseErr.
+	Diagnosis   *TxDiagnosis
+	DiagnoseErr error
+}
+
+func (e *DeployFailedError) Error() string {
+	switch {
+	case e.Diagnosis == nil:
+		return fmt.Sprintf("deployment %s failed", e.Receipt.TxHash.Hex())
+	case e.Diagnosis.RevertReason != "":
+		return fmt.Sprintf("deployment %s failed: %s: %s", e.Receipt.TxHash.Hex(), e.Diagnosis.Kind, e.Diagnosis.RevertReason)
+	default:
+		return fmt.Sprintf("deployment %s failed: %s", e.Receipt.TxHash.Hex(), e.Diagnosis.Kind)
+	}
+}
+
+// WaitDeployed waits for the contract creation transaction tx to be mined and
+// returns the address of the deployed contract, like bind.WaitDeployed.
+//
+// Transient gateway errors while waiting are tolerated, a bounded number of
+// times in a row. If the deployment failed, the error is a *DeployFailedError
+// diagnosing why, see DiagnoseFailedTx. If it succeeded but left no code,
+// the error is bind.ErrNoCodeAfterDeploy.
+func (b *WrappedBackend) WaitDeployed(ctx context.Context, tx *types.Transaction) (common.Address, error) {
+	if tx.To() != nil {
+		return common.Address{}, fmt.Errorf("transaction %s is not a contract creation", tx.Hash().Hex())
+	}
+	receipt, err := b.waitReceipt(ctx, tx.Hash())
+	if err != nil {
+		return common.Address{}, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		d, err := b.DiagnoseFailedTx(ctx, tx.Hash())
+		return common.Address{}, &DeployFailedError{Receipt: receipt, Diagnosis: d, DiagnoseErr: err}
+	}
+	if receipt.ContractAddress == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("receipt of deployment %s has no contract address", tx.Hash().Hex())
+	}
+	code, err := b.CodeAt(ctx, receipt.ContractAddress, nil)
+	if err != nil {
+		return receipt.ContractAddress, fmt.Errorf("failed to fetch deployed code: %w", err)
+	}
+	if len(code) == 0 {
+		return receipt.ContractAddress, bind.ErrNoCodeAfterDeploy
+	}
+	return receipt.ContractAddress, nil
+}
+
+// waitReceipt polls for the receipt of txHash until ctx is done.
+func (b *WrappedBackend) waitReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	poll := time.NewTicker(deployPollInterval)
+	defer poll.Stop()
+	failures := 0
+	for {
+		receipt, err := b.TransactionReceipt(ctx, txHash)
+		switch {
+		case err == nil:
+			return receipt, nil
+		case errors.Is(err, ethereum.NotFound):
+			failures = 0
+		case IsRetryable(err) && ctx.Err() == nil:
+			if failures++; failures >= maxDeployPollFailures {
+				return nil, fmt.Errorf("failed to fetch receipt of %s: %w", txHash.Hex(), err)
+			}
+		default:
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, fmt.Errorf("failed to fetch receipt of %s: %w", txHash.Hex(), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-poll.C:
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+func fastDeployPolling(t *testing.T) {
+	deployPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { deployPollInterval = DefaultDeployPollInterval })
+}
+
+func TestWaitDeployed(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(ctorStorageABI))
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	ctx := context.Background()
+
+	deploy := func(t *testing.T, mock *mockBackend, status uint64) (*WrappedBackend, common.Address, *types.Transaction) {
+		b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+		opts := b.Transactor(from)
+		addr, tx, _, err := DeployConfidential(opts, b, parsed, ctorStorageCode, big.NewInt(7))
+		if err != nil {
+			t.Fatalf("DeployConfidential failed: %v", err)
+		}
+		mock.mine(tx, status, 50_000)
+		return b, addr, tx
+	}
+
+	t.Run("success", func(t *testing.T) {
+		b, want, tx := deploy(t, newMockBackend(), types.ReceiptStatusSuccessful)
+		addr, err := b.WaitDeployed(ctx, tx)
+		if err != nil || addr != want {
+			t.Fatalf("WaitDeployed returned %s, %v", addr.Hex(), err)
+		}
+
+		call := types.NewTransaction(1, want, big.NewInt(0), 21_000, big.NewInt(sapphire.DefaultGasPrice), nil)
+		if _, err = b.WaitDeployed(ctx, call); err == nil {
+			t.Fatalf("expected calls to be rejected")
+		}
+	})
+
+	t.Run("constructor revert", func(t *testing.T) {
+		mock := newMockBackend()
+		mock.callResult = cbor.Marshal(sdkTypes.CallResult{Failed: &sdkTypes.FailedCallResult{
+			Module:  "evm",
+			Code:    evmRevertedCode,
+			Message: "reverted: " + base64.StdEncoding.EncodeToString(revertData(t, "bad init")),
+		}})
+		b, _, tx := deploy(t, mock, types.ReceiptStatusFailed)
+		_, err := b.WaitDeployed(ctx, tx)
+		var failed *DeployFailedError
+		if !errors.As(err, &failed) || failed.Diagnosis == nil {
+			t.Fatalf("expected a diagnosed DeployFailedError, got %v", err)
+		}
+		if failed.Diagnosis.Kind != FailureRevert || failed.Diagnosis.RevertReason != "bad init" {
+			t.Fatalf("unexpected diagnosis %+v", failed.Diagnosis)
+		}
+		if !strings.Contains(err.Error(), "bad init") {
+			t.Fatalf("revert reason missing from %q", err)
+		}
+	})
+}
+
+func TestWaitDeployedFlakyGateway(t *testing.T) {
+	fastDeployPolling(t)
+	ctx := context.Background()
+	tx := types.NewContractCreation(0, big.NewInt(0), 100_000, big.NewInt(sapphire.DefaultGasPrice), ctorStorageCode)
+	contract := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	newGateway := func(t *testing.T, pending int) (*rpcTransport, *WrappedBackend) {
+		rt := newRPCTransport()
+		var mu sync.Mutex
+		rt.handle("eth_getTransactionReceipt", func([]json.RawMessage) (interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if pending > 0 {
+				pending--
+				return nil, nil
+			}
+			return &types.Receipt{
+				Status:          types.ReceiptStatusSuccessful,
+				TxHash:          tx.Hash(),
+				ContractAddress: contract,
+				BlockNumber:     big.NewInt(100),
+				Logs:            []*types.Log{},
+			}, nil
+		})
+		b, err := WrapClient(dialTransport(t, rt), nil)
+		if err != nil {
+			t.Fatalf("failed to wrap client: %v", err)
+		}
+		return rt, b
+	}
+
+	t.Run("recovers", func(t *testing.T) {
+		rt, b := newGateway(t, 2)
+		rt.failMethod("eth_getTransactionReceipt", 3, http.StatusServiceUnavailable)
+		addr, err := b.WaitDeployed(ctx, tx)
+		if err != nil || addr != contract {
+			t.Fatalf("WaitDeployed returned %s, %v", addr.Hex(), err)
+		}
+		rt.failMethod("eth_getCode", 2, http.StatusBadGateway)
+		if addr, err = b.WaitDeployed(ctx, tx); err != nil || addr != contract {
+			t.Fatalf("WaitDeployed with flaky code lookups returned %s, %v", addr.Hex(), err)
+		}
+		if n := len(rt.recorded("eth_getCode")); n != 4 {
+			t.Fatalf("expected the failed code lookups to be retried, got %d lookups", n)
+		}
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		rt, b := newGateway(t, 0)
+		rt.failMethod("eth_getTransactionReceipt", 1000, http.StatusServiceUnavailable)
+		var httpErr rpc.HTTPError
+		if _, err := b.WaitDeployed(ctx, tx); !errors.As(err, &httpErr) {
+			t.Fatalf("expected WaitDeployed to give up on a failing gateway, got %v", err)
+		}
+		if n := len(rt.recorded("eth_getTransactionReceipt")); n != maxDeployPollFailures {
+			t.Fatalf("expected %d receipt polls, got %d", maxDeployPollFailures, n)
+		}
+	})
+
+	t.Run("no code", func(t *testing.T) {
+		rt, b := newGateway(t, 0)
+		rt.handle("eth_getCode", func([]json.RawMessage) (interface{}, error) {
+			return hexutil.Bytes{}, nil
+		})
+		if _, err := b.WaitDeployed(ctx, tx); !errors.Is(err, bind.ErrNoCodeAfterDeploy) {
+			t.Fatalf("expected ErrNoCodeAfterDeploy, got %v", err)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		_, b := newGateway(t, 1_000_000)
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		if _, err := b.WaitDeployed(ctx, tx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the deadline to end the wait, got %v", err)
+		}
+	})
+}
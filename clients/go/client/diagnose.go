@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// FailureKind classifies why a transaction failed.
+type FailureKind int
+
+const (
+	// FailureNone means the transaction did not fail.
+	FailureNone FailureKind = iota
+	// FailureUnknown means the transaction failed but replaying it did not tell why.
+	FailureUnknown
+	// FailureRevert means the contract reverted.
+	FailureRevert
+	// FailureOutOfGas means the transaction ran out of gas.
+	FailureOutOfGas
+	// FailureModule means a runtime module rejected the transaction.
+	FailureModule
+)
+
+func (k FailureKind) String() string {
+	switch k {
+	case FailureNone:
+		return "none"
+	case FailureRevert:
+		return "revert"
+	case FailureOutOfGas:
+		return "out of gas"
+	case FailureModule:
+		return "module error"
+	default:
+		return "unknown"
+	}
+}
+
+// TxDiagnosis is the result of DiagnoseFailedTx.
+type TxDiagnosis struct {
+	Transaction *types.Transaction
+	Receipt     *types.Receipt
+	// Kind is why the transaction failed.
+	Kind FailureKind
+	// RevertReason is the reason string of a revert, if the contract gave one.
+	RevertReason string
+	// RevertData is the raw revert data of a revert.
+	RevertData []byte
+	// ModuleError is the error reported by the runtime, if any.
+	ModuleError *sapphire.CallFailedError
+	// ReplayError is the error the replayed call failed with.
+	ReplayError error
+	// UsedPlaintext is set if the replay used the transaction's plaintext
+	// calldata rather than its encrypted envelope.
+	UsedPlaintext bool
+}
+
+// DiagnoseFailedTx finds out why a mined transaction failed by replaying it
+// as a call against the state before its block.
+//
+// Transactions sent through this client are replayed from their plaintext
+// calldata, as a signed query if the client can sign for the sender.
+// Otherwise the encrypted envelope is replayed as is, which only works while
+// the runtime still accepts the envelope's key and whose result can usually
+// not be decrypted, so the diagnosis may be less precise.
+//
+// Transactions that succeeded are not replayed and yield FailureNone.
+func (b *WrappedBackend) DiagnoseFailedTx(ctx context.Context, txHash common.Hash) (*TxDiagnosis, error) {
+	reader, ok := b.backend.(ethereum.TransactionReader)
+	if !ok {
+		return nil, fmt.Errorf("%w: wrapped backend cannot look up transactions", sapphire.ErrUnsupportedBackend)
+	}
+	var pending bool
+	tx, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (tx *types.Transaction, err error) {
+		tx, pending, err = reader.TransactionByHash(ctx, txHash)
+		return tx, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transaction: %w", err)
+	}
+	if pending {
+		return nil, fmt.Errorf("transaction %s is still pending", txHash.Hex())
+	}
+	receipt, err := b.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch receipt: %w", err)
+	}
+	d := &TxDiagnosis{Transaction: tx, Receipt: receipt}
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		return d, nil
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(&b.chainID), tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover transaction sender: %w", err)
+	}
+	call := ethereum.CallMsg{
+		From:     from,
+		To:       tx.To(),
+		Gas:      tx.Gas(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+	}
+	var parent *big.Int
+	if receipt.BlockNumber != nil && receipt.BlockNumber.Sign() > 0 {
+		parent = new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1))
+	}
+	plaintext, ok := b.plaintexts.get(txHash)
+	if ok {
+		d.UsedPlaintext = true
+		call.Data = plaintext
+		if _, err = b.signerFor(ctx, from); err != nil {
+			call.From = common.Address{}
+		}
+		_, d.ReplayError = b.CallContract(ctx, call, parent)
+	} else {
+		// The envelope is already encrypted and signed calls can't carry it.
+		call.From = common.Address{}
+		call.Data = tx.Data()
+		var res []byte
+		res, d.ReplayError = invoke(ctx, b.mw, rpcRead, func(ctx context.Context) ([]byte, error) {
+			return b.backend.CallContract(ctx, call, parent)
+		})
+		b.debugRequest(newCallContext(ctx, "eth_call", call.To, parent), nil, call.Data, hexutil.Bytes(res), d.ReplayError)
+		if d.ReplayError == nil {
+			_, d.ReplayError = b.currentCipher().DecryptEncoded(res)
+		}
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	d.classify()
+	return d, nil
+}
+
+// classify determines the failure kind from the replay error and receipt.
+func (d *TxDiagnosis) classify() {
+	err := d.ReplayError
+	var failed *sapphire.CallFailedError
+	if errors.As(err, &failed) {
+		d.ModuleError = failed
+	}
+	data, reverted := sapphire.RevertDataFromError(err)
+	switch {
+	case reverted:
+		d.Kind = FailureRevert
+		d.setRevertData(data)
+	case failed != nil && strings.Contains(failed.Message, "out of gas"):
+		d.Kind = FailureOutOfGas
+	case failed != nil:
+		d.Kind = FailureModule
+	case err != nil && strings.Contains(err.Error(), "out of gas"):
+		d.Kind = FailureOutOfGas
+	case err != nil && strings.Contains(err.Error(), "revert"):
+		d.Kind = FailureRevert
+	case d.Receipt.GasUsed >= d.Transaction.Gas():
+		// Reverts refund unused gas, running out of gas uses all of it.
+		d.Kind = FailureOutOfGas
+	default:
+		d.Kind = FailureUnknown
+	}
+}
+
+func (d *TxDiagnosis) setRevertData(data []byte) {
+	d.RevertData = data
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		d.RevertReason = reason
+	}
+}
@@ -0,0 +1,209 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+// revertData encodes an Error(string) revert with the given reason.
+func revertData(t *testing.T, reason string) []byte {
+	stringType, _ := abi.NewType("string", "", nil)
+	encoded, err := abi.Arguments{{Type: stringType}}.Pack(reason)
+	if err != nil {
+		t.Fatalf("failed to encode revert reason: %v", err)
+	}
+	return append(common.FromHex("08c379a0"), encoded...)
+}
+
+func TestDiagnoseFailedTx(t *testing.T) {
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	send := func(b *WrappedBackend, mock *mockBackend, status, gasUsed uint64) *types.Transaction {
+		opts := b.Transactor(from)
+		nonce, _ := b.PendingNonceAt(ctx, from)
+		signedTx, err := opts.Signer(from, types.NewTransaction(nonce, to, big.NewInt(0), 50_000, opts.GasPrice, TestData))
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		if err = b.SendTransaction(ctx, signedTx); err != nil {
+			t.Fatalf("failed to send transaction: %v", err)
+		}
+		mock.mine(signedTx, status, gasUsed)
+		return signedTx
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mock := newMockBackend()
+		b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+		tx := send(b, mock, types.ReceiptStatusSuccessful, 30_000)
+		d, err := b.DiagnoseFailedTx(ctx, tx.Hash())
+		if err != nil {
+			t.Fatalf("DiagnoseFailedTx failed: %v", err)
+		}
+		if d.Kind != FailureNone || len(mock.receivedCalls()) != 0 {
+			t.Fatalf("successful transactions must not be diagnosed as failed, got %s", d.Kind)
+		}
+	})
+
+	t.Run("revert", func(t *testing.T) {
+		mock := newMockBackend()
+		mock.callResult = cbor.Marshal(sdkTypes.CallResult{Failed: &sdkTypes.FailedCallResult{
+			Module:  "evm",
+			Code:    evmRevertedCode,
+			Message: "reverted: " + base64.StdEncoding.EncodeToString(revertData(t, "not allowed")),
+		}})
+		b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+		tx := send(b, mock, types.ReceiptStatusFailed, 30_000)
+		d, err := b.DiagnoseFailedTx(ctx, tx.Hash())
+		if err != nil {
+			t.Fatalf("DiagnoseFailedTx failed: %v", err)
+		}
+		if d.Kind != FailureRevert || d.RevertReason != "not allowed" || !d.UsedPlaintext {
+			t.Fatalf("unexpected diagnosis %+v", d)
+		}
+
+		// The replay is a signed query of the plaintext against the parent block.
+		var pack evm.SignedCallDataPack
+		if err = cbor.Unmarshal(mock.receivedCalls()[0].Data, &pack); err != nil {
+			t.Fatalf("replay is not a signed query: %v", err)
+		}
+		var body []byte
+		if err = cbor.Unmarshal(pack.Data.Body, &body); err != nil || !bytes.Equal(body, TestData) {
+			t.Fatalf("replay did not use the plaintext calldata")
+		}
+		if pack.Leash.BlockNumber != d.Receipt.BlockNumber.Uint64()-2 {
+			t.Fatalf("replay leash is not built on the parent block")
+		}
+	})
+
+	t.Run("out of gas", func(t *testing.T) {
+		mock := newMockBackend()
+		mock.callResult = cbor.Marshal(sdkTypes.CallResult{Failed: &sdkTypes.FailedCallResult{
+			Module:  "evm",
+			Code:    2,
+			Message: "execution failed: out of gas",
+		}})
+		b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+		tx := send(b, mock, types.ReceiptStatusFailed, 50_000)
+		d, err := b.DiagnoseFailedTx(ctx, tx.Hash())
+		if err != nil {
+			t.Fatalf("DiagnoseFailedTx failed: %v", err)
+		}
+		if d.Kind != FailureOutOfGas || d.ModuleError == nil {
+			t.Fatalf("unexpected diagnosis %+v", d)
+		}
+	})
+
+	t.Run("module error", func(t *testing.T) {
+		mock := newMockBackend()
+		mock.callResult = cbor.Marshal(sdkTypes.CallResult{Failed: &sdkTypes.FailedCallResult{
+			Module: "core",
+			Code:   12,
+		}})
+		b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+		tx := send(b, mock, types.ReceiptStatusFailed, 30_000)
+		d, err := b.DiagnoseFailedTx(ctx, tx.Hash())
+		if err != nil {
+			t.Fatalf("DiagnoseFailedTx failed: %v", err)
+		}
+		if d.Kind != FailureModule || d.ModuleError.Module != "core" || d.ModuleError.Code != 12 {
+			t.Fatalf("unexpected diagnosis %+v", d)
+		}
+	})
+
+	t.Run("unknown plaintext", func(t *testing.T) {
+		mock := newMockBackend()
+		mock.callResult = cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal([]byte{})})
+		b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+		tx := send(b, mock, types.ReceiptStatusFailed, 30_000)
+
+		// A different client instance does not know the plaintext.
+		other := newMockWrappedBackend(mock, nil)
+		d, err := other.DiagnoseFailedTx(ctx, tx.Hash())
+		if err != nil {
+			t.Fatalf("DiagnoseFailedTx failed: %v", err)
+		}
+		if d.UsedPlaintext || d.Kind != FailureUnknown {
+			t.Fatalf("unexpected diagnosis %+v", d)
+		}
+		if !bytes.Equal(mock.receivedCalls()[0].Data, tx.Data()) {
+			t.Fatalf("replay did not use the encrypted envelope")
+		}
+	})
+}
+
+// revertingCode deploys a contract that always reverts without data.
+var revertingCode = common.FromHex("600580600b6000396000f3" + "60006000fd")
+
+func TestDiagnoseFailedTxLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	client := localnet.Dial(t)
+	b, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(sapphire.NewPrivateKeySigner(key))))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	send := func(to *common.Address, gas uint64, data []byte) *types.Receipt {
+		opts := b.Transactor(from)
+		nonce, err := b.PendingNonceAt(ctx, from)
+		if err != nil {
+			t.Fatalf("failed to fetch nonce: %v", err)
+		}
+		signedTx, err := opts.Signer(from, types.NewTx(&types.LegacyTx{Nonce: nonce, To: to, Gas: gas, GasPrice: opts.GasPrice, Data: data}))
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		if err = b.SendTransaction(ctx, signedTx); err != nil {
+			t.Fatalf("failed to send transaction: %v", err)
+		}
+		receipt, err := bind.WaitMined(ctx, b, signedTx)
+		if err != nil {
+			t.Fatalf("failed to wait for transaction: %v", err)
+		}
+		return receipt
+	}
+	diagnose := func(receipt *types.Receipt) *TxDiagnosis {
+		d, err := b.DiagnoseFailedTx(ctx, receipt.TxHash)
+		if err != nil {
+			t.Fatalf("DiagnoseFailedTx failed: %v", err)
+		}
+		return d
+	}
+
+	reverting := send(nil, 200_000, revertingCode).ContractAddress
+	storage := send(nil, 200_000, perSenderStorageCode).ContractAddress
+	value := common.BigToHash(big.NewInt(1))
+
+	if d := diagnose(send(&reverting, 100_000, TestData)); d.Kind != FailureRevert {
+		t.Fatalf("expected a revert, got %s: %v", d.Kind, d.ReplayError)
+	}
+	if d := diagnose(send(&storage, 30_000, value[:])); d.Kind != FailureOutOfGas {
+		t.Fatalf("expected out of gas, got %s: %v", d.Kind, d.ReplayError)
+	}
+	if d := diagnose(send(&storage, 100_000, value[:])); d.Kind != FailureNone {
+		t.Fatalf("successful transaction diagnosed as %s", d.Kind)
+	}
+}
@@ -0,0 +1,373 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+const (
+	// diskCacheVersion is the version of the cache file format. Files of
+	// other versions are discarded.
+	diskCacheVersion = 2
+	// cachedLeashTTL is how long a cached leash is reused, well within the
+	// DefaultBlockRange blocks of about six seconds the runtime accepts it
+	// for.
+	cachedLeashTTL = 30 * time.Second
+	// diskCacheLockTimeout bounds the wait for the lock of the cache file,
+	// after which the cache is skipped.
+	diskCacheLockTimeout = 2 * time.Second
+)
+
+// errDiskCacheLocked is returned when the lock of the cache file can't be
+// taken in time.
+var errDiskCacheLocked = errors.New("cache file is locked")
+
+// diskCache persists runtime keys and leashes in a file, see
+// WithPersistentCache. Every access locks a file next to it, so processes
+// sharing the file don't lose each other's updates, and writes replace the
+// file atomically. The lock is released by the operating system when its
+// process exits, so crashed processes don't leave the cache locked.
+//
+// The cache is best effort: entries that can't be read or written are
+// fetched from the gateway instead.
+type diskCache struct {
+	path string
+}
+
+// diskCacheFile is the content of the cache file. Checksum is the SHA-256
+// digest of Chains, which detects truncated and corrupted files.
+type diskCacheFile struct {
+	Version  int             `json:"version"`
+	Checksum hexutil.Bytes   `json:"checksum"`
+	Chains   json.RawMessage `json:"chains"`
+}
+
+// cachedChain are the entries of a chain.
+type cachedChain struct {
+	Key      *cachedKey                        `json:"key,omitempty"`
+	Accounts map[common.Address]*cachedAccount `json:"accounts,omitempty"`
+}
+
+// cachedKey is a runtime calldata public key, with the key manager's
+// checksum and signature to verify it again when it is loaded.
+type cachedKey struct {
+	PublicKey hexutil.Bytes `json:"publicKey"`
+	Checksum  hexutil.Bytes `json:"checksum"`
+	Signature hexutil.Bytes `json:"signature"`
+	Epoch     uint64        `json:"epoch"`
+	Source    string        `json:"source"`
+	Fetched   time.Time     `json:"fetched"`
+}
+
+// cachedAccount is the leash last built for an account. Nonce is the lowest
+// nonce a leash may carry, past the transactions sent from the account.
+type cachedAccount struct {
+	Nonce uint64       `json:"nonce"`
+	Leash *cachedLeash `json:"leash,omitempty"`
+}
+
+type cachedLeash struct {
+	Nonce       uint64      `json:"nonce"`
+	BlockNumber uint64      `json:"blockNumber"`
+	BlockHash   common.Hash `json:"blockHash"`
+	BlockRange  uint64      `json:"blockRange"`
+	Built       time.Time   `json:"built"`
+}
+
+// key returns the runtime key of chainID and where it was fetched from,
+// unless it is older than runtimeKeyTTL, by which time the epoch has likely
+// advanced. The key is not verified.
+func (c *diskCache) key(ctx context.Context, chainID *big.Int) (*sapphire.CallDataPublicKey, string, bool) {
+	var key *cachedKey
+	_ = c.update(ctx, func(chains map[string]*cachedChain) bool {
+		if chain := chains[chainID.String()]; chain != nil {
+			key = chain.Key
+		}
+		return false
+	})
+	if key == nil || time.Since(key.Fetched) >= runtimeKeyTTL {
+		return nil, "", false
+	}
+	return &sapphire.CallDataPublicKey{PublicKey: key.PublicKey, Checksum: key.Checksum, Signature: key.Signature, Epoch: key.Epoch}, key.Source, true
+}
+
+// putKey records the runtime key of chainID, unless another process already
+// recorded one of a later epoch.
+func (c *diskCache) putKey(ctx context.Context, chainID *big.Int, pubKey *sapphire.CallDataPublicKey, source string) {
+	_ = c.update(ctx, func(chains map[string]*cachedChain) bool {
+		chain := chainEntry(chains, chainID)
+		if chain.Key != nil && chain.Key.Epoch > pubKey.Epoch {
+			return false
+		}
+		chain.Key = &cachedKey{
+			PublicKey: common.CopyBytes(pubKey.PublicKey),
+			Checksum:  common.CopyBytes(pubKey.Checksum),
+			Signature: common.CopyBytes(pubKey.Signature),
+			Epoch:     pubKey.Epoch,
+			Source:    source,
+			Fetched:   time.Now(),
+		}
+		return true
+	})
+}
+
+// dropKey forgets the runtime key of chainID if it is of epoch or an earlier
+// one, e.g. after the runtime rejected a call encrypted to the key of epoch.
+func (c *diskCache) dropKey(ctx context.Context, chainID *big.Int, epoch uint64) {
+	_ = c.update(ctx, func(chains map[string]*cachedChain) bool {
+		chain := chains[chainID.String()]
+		if chain == nil || chain.Key == nil || chain.Key.Epoch > epoch {
+			return false
+		}
+		chain.Key = nil
+		return true
+	})
+}
+
+// leash returns the cached leash of account on chainID, unless it is too old
+// or a transaction was sent from the account since it was built.
+func (c *diskCache) leash(ctx context.Context, chainID *big.Int, account common.Address) (*evm.Leash, bool) {
+	var leash *evm.Leash
+	_ = c.update(ctx, func(chains map[string]*cachedChain) bool {
+		chain := chains[chainID.String()]
+		if chain == nil || chain.Accounts[account] == nil {
+			return false
+		}
+		entry := chain.Accounts[account]
+		l := entry.Leash
+		if l == nil || l.Nonce < entry.Nonce || time.Since(l.Built) >= cachedLeashTTL {
+			return false
+		}
+		leash = &evm.Leash{Nonce: l.Nonce, BlockNumber: l.BlockNumber, BlockHash: common.CopyBytes(l.BlockHash[:]), BlockRange: l.BlockRange}
+		return false
+	})
+	return leash, leash != nil
+}
+
+// putLeash records a leash built for account on chainID.
+func (c *diskCache) putLeash(ctx context.Context, chainID *big.Int, account common.Address, leash *evm.Leash) {
+	_ = c.update(ctx, func(chains map[string]*cachedChain) bool {
+		entry := accountEntry(chains, chainID, account)
+		if leash.Nonce < entry.Nonce {
+			return false
+		}
+		entry.Leash = &cachedLeash{
+			Nonce:       leash.Nonce,
+			BlockNumber: leash.BlockNumber,
+			BlockHash:   common.BytesToHash(leash.BlockHash),
+			BlockRange:  leash.BlockRange,
+			Built:       time.Now(),
+		}
+		return true
+	})
+}
+
+// dropLeash forgets the leash of account on chainID, e.g. after the runtime
+// rejected it.
+func (c *diskCache) dropLeash(ctx context.Context, chainID *big.Int, account common.Address) {
+	_ = c.update(ctx, func(chains map[string]*cachedChain) bool {
+		chain := chains[chainID.String()]
+		if chain == nil || chain.Accounts[account] == nil || chain.Accounts[account].Leash == nil {
+			return false
+		}
+		chain.Accounts[account].Leash = nil
+		return true
+	})
+}
+
+// sent records that a transaction with nonce was sent from account, which
+// invalidates its leashes of lower nonces, in this process and others.
+func (c *diskCache) sent(ctx context.Context, chainID *big.Int, account common.Address, nonce uint64) {
+	_ = c.update(ctx, func(chains map[string]*cachedChain) bool {
+		entry := accountEntry(chains, chainID, account)
+		if nonce < entry.Nonce {
+			return false
+		}
+		entry.Nonce = nonce + 1
+		if entry.Leash != nil && entry.Leash.Nonce < entry.Nonce {
+			entry.Leash = nil
+		}
+		return true
+	})
+}
+
+func chainEntry(chains map[string]*cachedChain, chainID *big.Int) *cachedChain {
+	chain := chains[chainID.String()]
+	if chain == nil {
+		chain = &cachedChain{}
+		chains[chainID.String()] = chain
+	}
+	if chain.Accounts == nil {
+		chain.Accounts = make(map[common.Address]*cachedAccount)
+	}
+	return chain
+}
+
+func accountEntry(chains map[string]*cachedChain, chainID *big.Int, account common.Address) *cachedAccount {
+	chain := chainEntry(chains, chainID)
+	entry := chain.Accounts[account]
+	if entry == nil {
+		entry = &cachedAccount{}
+		chain.Accounts[account] = entry
+	}
+	return entry
+}
+
+// update loads the cache under its lock and passes it to fn, writing it back
+// if fn reports a change. A missing, corrupted or outdated file loads empty
+// and is replaced by the next write.
+func (c *diskCache) update(ctx context.Context, fn func(chains map[string]*cachedChain) bool) error {
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	chains := c.load()
+	if !fn(chains) {
+		return nil
+	}
+	return c.store(chains)
+}
+
+func (c *diskCache) load() map[string]*cachedChain {
+	chains := make(map[string]*cachedChain)
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return chains
+	}
+	var file diskCacheFile
+	if json.Unmarshal(data, &file) != nil || file.Version != diskCacheVersion {
+		return chains
+	}
+	if sum := sha256.Sum256(file.Chains); !bytes.Equal(sum[:], file.Checksum) {
+		return chains
+	}
+	if json.Unmarshal(file.Chains, &chains) != nil {
+		return make(map[string]*cachedChain)
+	}
+	for id, chain := range chains {
+		if chain == nil {
+			delete(chains, id)
+		}
+	}
+	return chains
+}
+
+func (c *diskCache) store(chains map[string]*cachedChain) error {
+	encoded, err := json.Marshal(chains)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(encoded)
+	data, err := json.Marshal(diskCacheFile{Version: diskCacheVersion, Checksum: sum[:], Chains: encoded})
+	if err != nil {
+		return err
+	}
+
+	// Replace the file at once, so that it is never seen half written.
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// lock locks the lock file of the cache, waiting for other processes to
+// release it until ctx is done or diskCacheLockTimeout passed, and returns
+// the function releasing it.
+func (c *diskCache) lock(ctx context.Context) (func(), error) {
+	path := c.path + ".lock"
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, diskCacheLockTimeout)
+	defer cancel()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if locked {
+			return func() {
+				unlockFile(f)
+				f.Close()
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("%w: %s: %w", errDiskCacheLocked, path, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// cachedCipher returns a cipher for the cached runtime key, if any and if it
+// verifies like a fetched key, against km if it is set.
+func (b *WrappedBackend) cachedCipher(ctx context.Context, km *keyManager) (sapphire.Cipher, string, bool) {
+	if b.cache == nil {
+		return nil, "", false
+	}
+	pubKey, source, ok := b.cache.key(ctx, &b.chainID)
+	if !ok || verifyKey(pubKey, km) != nil {
+		return nil, "", false
+	}
+	keypair, err := sapphire.NewCurve25519KeyPair()
+	if err != nil {
+		return nil, "", false
+	}
+	cipher, err := sapphire.NewX25519DeoxysIICipher(keypair, (*x25519.PublicKey)(pubKey.PublicKey), pubKey.Epoch)
+	if err != nil {
+		return nil, "", false
+	}
+	return cipher, source, true
+}
+
+// cacheCipher records the runtime key of a fetch.
+func (b *WrappedBackend) cacheCipher(ctx context.Context, fetch keyFetch) {
+	var pubKey sapphire.CallDataPublicKey
+	if b.cache == nil || json.Unmarshal(fetch.raw, &pubKey) != nil {
+		return
+	}
+	b.cache.putKey(ctx, &b.chainID, &pubKey, fetch.source)
+}
+
+// dropCachedKey forgets the cached runtime key if the runtime rejected a call
+// encrypted with cipher for its call format, as it does once the key's epoch
+// has passed, so that other clients sharing the cache fetch a fresh one.
+func (b *WrappedBackend) dropCachedKey(ctx context.Context, cipher sapphire.Cipher, err error) {
+	c, ok := cipher.(*sapphire.X25519DeoxysIICipher)
+	if b.cache == nil || !ok || !callFormatRejected(err) {
+		return
+	}
+	b.cache.dropKey(ctx, &b.chainID, c.Epoch())
+}
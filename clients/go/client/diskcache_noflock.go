@@ -0,0 +1,18 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package client
+
+import (
+	"errors"
+	"os"
+)
+
+// errNoFileLock is returned where files can't be locked, which skips the
+// cache.
+var errNoFileLock = errors.New("file locking is not supported on this platform")
+
+func tryLockFile(*os.File) (bool, error) {
+	return false, errNoFileLock
+}
+
+func unlockFile(*os.File) {}
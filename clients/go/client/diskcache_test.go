@@ -0,0 +1,295 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/mockgateway"
+)
+
+// cachedClient dials gw with a persistent cache at path and sends a signed
+// query from the keyring's account, returning the client and the leash.
+func cachedClient(t *testing.T, gw *mockgateway.Gateway, path string, keyring *sapphire.Keyring) (*WrappedBackend, *evm.Leash) {
+	t.Helper()
+	b, err := Dial(gw.URL, nil, WithKeyring(keyring), WithPersistentCache(path))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(b.Close)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	if _, err = b.CallContract(context.Background(), ethereum.CallMsg{From: keyring.Addresses()[0], To: &to, Data: []byte{1}}, nil); err != nil {
+		t.Fatalf("CallContract failed: %v", err)
+	}
+	calls := gw.Calls("eth_call")
+	return b, calls[len(calls)-1].Leash
+}
+
+// rewriteCache applies fn to the cache file at path.
+func rewriteCache(t *testing.T, path string, fn func(chain *cachedChain)) {
+	t.Helper()
+	cache := &diskCache{path: path}
+	if err := cache.update(context.Background(), func(chains map[string]*cachedChain) bool {
+		fn(chains[big.NewInt(mockgateway.DefaultChainID).String()])
+		return true
+	}); err != nil {
+		t.Fatalf("failed to rewrite cache: %v", err)
+	}
+}
+
+func TestPersistentCache(t *testing.T) {
+	gw := mockgateway.New(t)
+	path := filepath.Join(t.TempDir(), "cache.json")
+	keyring := newTestKeyring(t, 1)
+
+	_, leash := cachedClient(t, gw, path, keyring)
+	keyFetches, headers := len(gw.Requests(KeySourceRPC)), len(gw.Requests("eth_getBlockByNumber"))
+
+	// A second process reuses the key and the leash.
+	b, cached := cachedClient(t, gw, path, keyring)
+	if n := len(gw.Requests(KeySourceRPC)); n != keyFetches {
+		t.Fatalf("expected the cached key to be used, got %d key fetches", n)
+	}
+	if n := len(gw.Requests("eth_getBlockByNumber")); n != headers {
+		t.Fatalf("expected the cached leash to be used, got %d header fetches", n)
+	}
+	if cached.Nonce != leash.Nonce || cached.BlockNumber != leash.BlockNumber || string(cached.BlockHash) != string(leash.BlockHash) {
+		t.Fatalf("expected the cached leash %+v, got %+v", leash, cached)
+	}
+	if b.KeySource() != KeySourceRPC {
+		t.Fatalf("unexpected key source %q", b.KeySource())
+	}
+}
+
+func TestPersistentCacheStale(t *testing.T) {
+	gw := mockgateway.New(t)
+	path := filepath.Join(t.TempDir(), "cache.json")
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	ctx := context.Background()
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	b, _ := cachedClient(t, gw, path, keyring)
+
+	t.Run("old key", func(t *testing.T) {
+		rewriteCache(t, path, func(chain *cachedChain) { chain.Key.Fetched = time.Now().Add(-runtimeKeyTTL) })
+		epoch := gw.RotateKey()
+		fetches := len(gw.Requests(KeySourceRPC))
+		cachedClient(t, gw, path, keyring)
+		if len(gw.Requests(KeySourceRPC)) != fetches+1 {
+			t.Fatalf("expected the old key to be fetched again")
+		}
+		if calls := gw.Calls("eth_call"); calls[len(calls)-1].Epoch != epoch {
+			t.Fatalf("expected a call for epoch %d, got %d", epoch, calls[len(calls)-1].Epoch)
+		}
+	})
+
+	t.Run("old leash", func(t *testing.T) {
+		rewriteCache(t, path, func(chain *cachedChain) { chain.Accounts[from].Leash.Built = time.Now().Add(-cachedLeashTTL) })
+		headers := len(gw.Requests("eth_getBlockByNumber"))
+		cachedClient(t, gw, path, keyring)
+		if len(gw.Requests("eth_getBlockByNumber")) != headers+1 {
+			t.Fatalf("expected a fresh leash to be built")
+		}
+	})
+
+	t.Run("nonce advanced", func(t *testing.T) {
+		txOpts := b.Transactor(from)
+		tx, err := txOpts.Signer(from, types.NewTransaction(0, to, big.NewInt(0), 100_000, txOpts.GasPrice, []byte{1}))
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		if err = b.SendTransaction(ctx, tx); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+		// Another process notices the transaction at load.
+		_, leash := cachedClient(t, gw, path, keyring)
+		if leash.Nonce != 1 {
+			t.Fatalf("expected a leash with the advanced nonce, got %d", leash.Nonce)
+		}
+	})
+
+	t.Run("rejected leash", func(t *testing.T) {
+		gw.Fail("eth_call", &mockgateway.Error{Code: -32000, Message: "leash expired"})
+		_, err := b.CallContract(ctx, ethereum.CallMsg{From: from, To: &to, Data: []byte{1}}, nil)
+		if !errors.Is(err, sapphire.ErrLeashExpired) {
+			t.Fatalf("expected the leash to be rejected, got %v", err)
+		}
+		headers := len(gw.Requests("eth_getBlockByNumber"))
+		if _, err = b.CallContract(ctx, ethereum.CallMsg{From: from, To: &to, Data: []byte{1}}, nil); err != nil {
+			t.Fatalf("CallContract failed: %v", err)
+		}
+		if len(gw.Requests("eth_getBlockByNumber")) != headers+1 {
+			t.Fatalf("expected the rejected leash to be dropped")
+		}
+	})
+}
+
+func TestPersistentCacheCorrupted(t *testing.T) {
+	gw := mockgateway.New(t)
+	keyring := newTestKeyring(t, 1)
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	cachedClient(t, gw, valid, keyring)
+	data, err := os.ReadFile(valid)
+	if err != nil {
+		t.Fatalf("failed to read cache: %v", err)
+	}
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-20] ^= 1
+
+	for name, content := range map[string][]byte{
+		"garbage":   []byte("not a cache"),
+		"truncated": data[:len(data)/2],
+		"tampered":  tampered,
+		"version":   []byte(`{"version":99,"checksum":"0x","chains":{}}`),
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name+".json")
+			if err := os.WriteFile(path, content, 0o600); err != nil {
+				t.Fatalf("failed to write cache: %v", err)
+			}
+			fetches := len(gw.Requests(KeySourceRPC))
+			cachedClient(t, gw, path, keyring)
+			if len(gw.Requests(KeySourceRPC)) != fetches+1 {
+				t.Fatalf("expected the key to be fetched")
+			}
+			// The file was replaced by a valid one.
+			if _, _, ok := (&diskCache{path: path}).key(context.Background(), big.NewInt(mockgateway.DefaultChainID)); !ok {
+				t.Fatalf("expected the cache to be rewritten")
+			}
+		})
+	}
+}
+
+func TestPersistentCacheKeyVerified(t *testing.T) {
+	gw := mockgateway.New(t)
+	path := filepath.Join(t.TempDir(), "cache.json")
+	keyring := newTestKeyring(t, 1)
+	opt := WithKeyManagerKey(gw.KeyManagerKey())
+	dial := func() *WrappedBackend {
+		b, err := Dial(gw.URL, nil, WithKeyring(keyring), WithPersistentCache(path), opt)
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		t.Cleanup(b.Close)
+		return b
+	}
+	dial()
+
+	// Keys written to the file by others are verified like fetched ones.
+	for name, poison := range map[string]func(key *cachedKey){
+		"replaced key": func(key *cachedKey) { key.PublicKey[0] ^= 1 },
+		"unsigned key": func(key *cachedKey) { key.Checksum, key.Signature = nil, nil },
+	} {
+		t.Run(name, func(t *testing.T) {
+			rewriteCache(t, path, func(chain *cachedChain) { poison(chain.Key) })
+			fetches := len(gw.Requests(KeySourceRPC))
+			dial()
+			if len(gw.Requests(KeySourceRPC)) != fetches+1 {
+				t.Fatalf("expected the poisoned key to be fetched again")
+			}
+		})
+	}
+
+	// The key refetched above replaced the poisoned one.
+	fetches := len(gw.Requests(KeySourceRPC))
+	dial()
+	if len(gw.Requests(KeySourceRPC)) != fetches {
+		t.Fatalf("expected the verified key to be reused")
+	}
+}
+
+func TestPersistentCacheEpochRejected(t *testing.T) {
+	gw := mockgateway.New(t)
+	path := filepath.Join(t.TempDir(), "cache.json")
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	b, _ := cachedClient(t, gw, path, keyring)
+	gw.Fail("eth_call", &mockgateway.Error{Code: -32000, Message: "invalid call format: unknown epoch"})
+	if _, err := b.CallContract(context.Background(), ethereum.CallMsg{From: from, To: &to, Data: []byte{1}}, nil); err == nil {
+		t.Fatalf("expected the call to be rejected")
+	}
+
+	// Other processes don't reuse the rejected key.
+	fetches := len(gw.Requests(KeySourceRPC))
+	cachedClient(t, gw, path, keyring)
+	if len(gw.Requests(KeySourceRPC)) != fetches+1 {
+		t.Fatalf("expected the rejected key to be fetched again")
+	}
+}
+
+func TestPersistentCacheLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	cache := &diskCache{path: path}
+	unlock, err := cache.lock(context.Background())
+	if err != nil {
+		t.Fatalf("failed to lock cache: %v", err)
+	}
+
+	// Another handle waits for the lock until its context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = (&diskCache{path: path}).update(ctx, func(map[string]*cachedChain) bool { return true })
+	if !errors.Is(err, errDiskCacheLocked) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the lock to be held, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= diskCacheLockTimeout {
+		t.Fatalf("expected the wait to end with the context, took %v", elapsed)
+	}
+
+	// The lock file stays behind once released, and doesn't hold up others.
+	unlock()
+	if _, err = os.Stat(path + ".lock"); err != nil {
+		t.Fatalf("expected the lock file to be kept, got %v", err)
+	}
+	if err = (&diskCache{path: path}).update(context.Background(), func(map[string]*cachedChain) bool { return true }); err != nil {
+		t.Fatalf("expected the released lock to be taken, got %v", err)
+	}
+}
+
+func TestPersistentCacheConcurrent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.json")
+	chainID := big.NewInt(mockgateway.DefaultChainID)
+	account := common.Address{1}
+
+	// Each goroutine stands for a process with its own handle on the file.
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(nonce uint64) {
+			defer wg.Done()
+			cache := &diskCache{path: path}
+			cache.putLeash(ctx, chainID, account, &evm.Leash{Nonce: nonce, BlockHash: make([]byte, 32)})
+			cache.sent(ctx, chainID, account, nonce)
+		}(uint64(i))
+	}
+	wg.Wait()
+
+	var entry *cachedAccount
+	if err := (&diskCache{path: path}).update(ctx, func(chains map[string]*cachedChain) bool {
+		entry = chains[chainID.String()].Accounts[account]
+		return false
+	}); err != nil {
+		t.Fatalf("failed to read cache: %v", err)
+	}
+	if entry == nil || entry.Nonce != 16 || entry.Leash != nil {
+		t.Fatalf("expected every transaction to be recorded, got %+v", entry)
+	}
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// RuntimeKeySource provides the runtime's calldata public key.
+type RuntimeKeySource interface {
+	// RuntimePublicKey returns the runtime's current calldata public key and its epoch.
+	RuntimePublicKey(ctx context.Context) (*x25519.PublicKey, uint64, error)
+}
+
+type clientKeySource struct {
+	c *ethclient.Client
+}
+
+// NewClientKeySource returns a RuntimeKeySource that fetches the key from a
+// gateway on every use.
+func NewClientKeySource(c *ethclient.Client) RuntimeKeySource {
+	return clientKeySource{c}
+}
+
+func (s clientKeySource) RuntimePublicKey(ctx context.Context) (*x25519.PublicKey, uint64, error) {
+	return GetRuntimePublicKeyContext(ctx, s.c)
+}
+
+// EncryptTxOption configures EncryptTx.
+type EncryptTxOption func(*encryptTxConfig)
+
+type encryptTxConfig struct {
+	adjustGas bool
+	margin    GasMargin
+}
+
+// WithEnvelopeGas makes EncryptTx raise the transaction's gas limit by the
+// additional intrinsic gas its encrypted calldata costs, see
+// EnvelopeGasOverhead. Use it when the gas limit was estimated for the
+// plaintext calldata.
+func WithEnvelopeGas() EncryptTxOption {
+	return func(cfg *encryptTxConfig) {
+		cfg.adjustGas = true
+	}
+}
+
+// WithEnvelopeGasMargin is WithEnvelopeGas that also adds margin to the gas
+// limit, after the envelope overhead.
+func WithEnvelopeGasMargin(margin GasMargin) EncryptTxOption {
+	return func(cfg *encryptTxConfig) {
+		cfg.adjustGas = true
+		cfg.margin = margin
+	}
+}
+
+// EncryptTx returns a copy of the unsigned transaction with its calldata
+// encrypted to the runtime's current key, for signing pipelines that handle
+// signing themselves. Nonce, fees and, unless WithEnvelopeGas is given, the
+// gas limit are left untouched, as is the transaction type.
+//
+// Transactions without calldata or whose calldata is already encrypted are
+// returned unchanged. Any signature on tx is dropped.
+func EncryptTx(ctx context.Context, keySource RuntimeKeySource, tx *types.Transaction, opts ...EncryptTxOption) (*types.Transaction, error) {
+	var cfg encryptTxConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if !txNeedsPacking(tx) {
+		return tx, nil
+	}
+	runtimePublicKey, epoch, err := keySource.RuntimePublicKey(ctx)
+	if err != nil {
+		return nil, keyFetchError(err)
+	}
+	keypair, err := sapphire.NewCurve25519KeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral keypair: %w", err)
+	}
+	cipher, err := sapphire.NewX25519DeoxysIICipher(keypair, runtimePublicKey, epoch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	data := cipher.EncryptEncode(tx.Data())
+	gas := tx.Gas()
+	if cfg.adjustGas {
+		gas = cfg.margin.apply(gas + EnvelopeGasOverhead(tx.Data(), data, tx.To() == nil))
+	}
+	return withData(tx, data, gas)
+}
+
+// withData returns an unsigned copy of tx with the given calldata and gas limit.
+func withData(tx *types.Transaction, data []byte, gas uint64) (*types.Transaction, error) {
+	switch tx.Type() {
+	case types.LegacyTxType:
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: tx.GasPrice(),
+			Gas:      gas,
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     data,
+		}), nil
+	case types.AccessListTxType:
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasPrice:   tx.GasPrice(),
+			Gas:        gas,
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       data,
+			AccessList: tx.AccessList(),
+		}), nil
+	case types.DynamicFeeTxType:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  tx.GasTipCap(),
+			GasFeeCap:  tx.GasFeeCap(),
+			Gas:        gas,
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       data,
+			AccessList: tx.AccessList(),
+		}), nil
+	default:
+		return nil, fmt.Errorf("%w %d", sapphire.ErrUnsupportedTransaction, tx.Type())
+	}
+}
+
+// EnvelopeGasOverhead returns how much more intrinsic gas a transaction costs
+// with the encrypted envelope as calldata than with the plaintext.
+func EnvelopeGasOverhead(plaintext, envelope []byte, creation bool) uint64 {
+	plainGas, envelopeGas := calldataGas(plaintext, creation), calldataGas(envelope, creation)
+	if envelopeGas <= plainGas {
+		return 0
+	}
+	return envelopeGas - plainGas
+}
+
+// calldataGas returns the intrinsic gas charged for calldata.
+func calldataGas(data []byte, creation bool) uint64 {
+	var gas uint64
+	for _, b := range data {
+		if b == 0 {
+			gas += params.TxDataZeroGas
+		} else {
+			gas += params.TxDataNonZeroGasEIP2028
+		}
+	}
+	if creation {
+		gas += params.InitCodeWordGas * ((uint64(len(data)) + 31) / 32)
+	}
+	return gas
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+type staticKeySource struct {
+	key   x25519.PublicKey
+	epoch uint64
+	err   error
+}
+
+func (s staticKeySource) RuntimePublicKey(context.Context) (*x25519.PublicKey, uint64, error) {
+	return &s.key, s.epoch, s.err
+}
+
+func TestEncryptTx(t *testing.T) {
+	keySource := staticKeySource{key: x25519.PublicKey(common.Hex2Bytes("3046db3fa70ce605457dc47c48837ebd8bd0a26abfde5994d033e1ced68e2576")), epoch: 42}
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	chainID := big.NewInt(0x5afd)
+	ctx := context.Background()
+
+	for name, tx := range map[string]*types.Transaction{
+		"legacy":     types.NewTx(&types.LegacyTx{Nonce: 3, GasPrice: big.NewInt(sapphire.DefaultGasPrice), Gas: 50_000, To: &to, Value: big.NewInt(1), Data: TestData}),
+		"accessList": types.NewTx(&types.AccessListTx{ChainID: chainID, Nonce: 3, GasPrice: big.NewInt(sapphire.DefaultGasPrice), Gas: 50_000, To: &to, Data: TestData, AccessList: types.AccessList{{Address: to}}}),
+		"dynamicFee": types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: 3, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(sapphire.DefaultGasPrice), Gas: 50_000, To: &to, Data: TestData}),
+		"create":     types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: 3, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(sapphire.DefaultGasPrice), Gas: 50_000, Data: TestData}),
+	} {
+		encrypted, err := EncryptTx(ctx, keySource, tx)
+		if err != nil {
+			t.Fatalf("%s: EncryptTx failed: %v", name, err)
+		}
+		if encrypted.Type() != tx.Type() || encrypted.Nonce() != tx.Nonce() || encrypted.Gas() != tx.Gas() ||
+			encrypted.GasFeeCap().Cmp(tx.GasFeeCap()) != 0 || encrypted.GasTipCap().Cmp(tx.GasTipCap()) != 0 ||
+			encrypted.Value().Cmp(tx.Value()) != 0 || encrypted.To() != tx.To() && *encrypted.To() != *tx.To() ||
+			len(encrypted.AccessList()) != len(tx.AccessList()) {
+			t.Fatalf("%s: EncryptTx changed fields other than calldata", name)
+		}
+		var envelope sdkTypes.Call
+		if err = cbor.Unmarshal(encrypted.Data(), &envelope); err != nil || envelope.Format != sdkTypes.CallFormatEncryptedX25519DeoxysII {
+			t.Fatalf("%s: calldata is not an encrypted envelope", name)
+		}
+
+		adjusted, err := EncryptTx(ctx, keySource, tx, WithEnvelopeGas())
+		if err != nil {
+			t.Fatalf("%s: EncryptTx failed: %v", name, err)
+		}
+		if want := tx.Gas() + EnvelopeGasOverhead(tx.Data(), adjusted.Data(), tx.To() == nil); adjusted.Gas() != want || want <= tx.Gas() {
+			t.Fatalf("%s: expected gas %d, got %d", name, want, adjusted.Gas())
+		}
+
+		again, err := EncryptTx(ctx, keySource, encrypted)
+		if err != nil || again != encrypted {
+			t.Fatalf("%s: encrypted transactions should be returned unchanged", name)
+		}
+	}
+
+	blobTx := types.NewTx(&types.BlobTx{Gas: 50_000, To: to, Data: TestData})
+	if _, err := EncryptTx(ctx, keySource, blobTx); !errors.Is(err, sapphire.ErrUnsupportedTransaction) {
+		t.Fatalf("expected blob transactions to be rejected")
+	}
+	failing := staticKeySource{err: errors.New("unavailable")}
+	if _, err := EncryptTx(ctx, failing, types.NewTx(&types.LegacyTx{To: &to, Data: TestData})); err == nil {
+		t.Fatalf("expected key source errors to be returned")
+	}
+}
+
+func TestEncryptTxLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	client := localnet.Dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	nonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		t.Fatalf("failed to fetch nonce: %v", err)
+	}
+	chainID := big.NewInt(0x5afd)
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(sapphire.DefaultGasPrice),
+		Gas:       100_000,
+		Data:      perSenderStorageCode,
+	})
+	encrypted, err := EncryptTx(ctx, NewClientKeySource(client), tx, WithEnvelopeGas())
+	if err != nil {
+		t.Fatalf("EncryptTx failed: %v", err)
+	}
+	// Sign externally, as a signing service would.
+	signedTx, err := types.SignTx(encrypted, types.LatestSignerForChainID(chainID), key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if err = client.SendTransaction(ctx, signedTx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	receipt, err := bind.WaitMined(ctx, client, signedTx)
+	if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("transaction failed: %v", err)
+	}
+}
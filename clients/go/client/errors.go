@@ -0,0 +1,115 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// keyFetchError wraps err in sapphire.ErrKeyFetchFailed unless it already is.
+func keyFetchError(err error) error {
+	if err == nil || errors.Is(err, sapphire.ErrKeyFetchFailed) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", sapphire.ErrKeyFetchFailed, err)
+}
+
+// Fragments of the errors the runtime rejects signed queries with, which it
+// only describes in their messages.
+var (
+	leashExpiredMessages = []string{
+		"leash expired",
+		"expired leash",
+		"block hash mismatch",
+		"unknown block",
+		"block too old",
+	}
+	leashNonceMessages = []string{
+		"leash nonce",
+		"nonce mismatch",
+		"nonce too high",
+		"nonce too low",
+	}
+	signatureRejectedMessages = []string{
+		"invalid signature",
+		"signature verification failed",
+		"signer mismatch",
+	}
+	// callFormatMessages are those of calls the runtime can't decrypt,
+	// e.g. because the epoch of their key has passed.
+	callFormatMessages = []string{
+		"invalid call format",
+		"unknown epoch",
+	}
+)
+
+// signedQueryError wraps err in sapphire.ErrLeashExpired, sapphire.ErrLeashNonceMismatch or
+// sapphire.ErrSignatureRejected if the runtime rejected a signed query with it.
+func signedQueryError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := strings.ToLower(err.Error())
+	for _, class := range []struct {
+		err       error
+		fragments []string
+	}{
+		{sapphire.ErrLeashExpired, leashExpiredMessages},
+		{sapphire.ErrLeashNonceMismatch, leashNonceMessages},
+		{sapphire.ErrSignatureRejected, signatureRejectedMessages},
+	} {
+		for _, fragment := range class.fragments {
+			if strings.Contains(msg, fragment) {
+				return fmt.Errorf("%w: %w", class.err, err)
+			}
+		}
+	}
+	return err
+}
+
+// callFormatRejected reports whether the runtime rejected a call for its call
+// format, i.e. with the core module's error 17 or one of callFormatMessages.
+func callFormatRejected(err error) bool {
+	if err == nil {
+		return false
+	}
+	if failed, ok := sapphire.DecodeModuleError(err); ok && failed.Module == "core" && failed.Code == 17 {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range callFormatMessages {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleError wraps the gateway error err with the module error in its data,
+// if there is one.
+func moduleError(err error) error {
+	var failed *sapphire.CallFailedError
+	if err == nil || errors.As(err, &failed) {
+		return err
+	}
+	if decoded, ok := sapphire.DecodeModuleError(err); ok {
+		return fmt.Errorf("%w: %w", err, decoded)
+	}
+	return err
+}
+
+// revertError wraps err in a sapphire.RevertError if the contract reverted.
+func revertError(err error) error {
+	var revert *sapphire.RevertError
+	if err == nil || errors.As(err, &revert) {
+		return err
+	}
+	if data, ok := sapphire.RevertDataFromError(err); ok {
+		reason, _ := abi.UnpackRevert(data)
+		return &sapphire.RevertError{Data: data, Reason: reason, Err: err}
+	}
+	return err
+}
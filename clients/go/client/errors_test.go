@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// classifiedFiles are the files whose errors must all wrap another error, so
+// that callers can tell them apart with errors.Is and errors.As. The core
+// sapphire package's call builder and cipher are checked here too.
+var classifiedFiles = []string{
+	"abi.go",
+	"../callbuilder.go",
+	"capabilities.go",
+	"../cipher.go",
+	"compat.go",
+	"encrypttx.go",
+	"gaslesssubmit.go",
+	"keysource.go",
+	"overrides.go",
+	"prepare.go",
+	"signedcall.go",
+	"status.go",
+	"trace.go",
+	"transactor.go",
+	"unwrap.go",
+}
+
+func TestErrorsClassified(t *testing.T) {
+	fset := token.NewFileSet()
+	for _, name := range classifiedFiles {
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", name, err)
+		}
+		for _, decl := range file.Decls {
+			// Package level errors.New calls declare the sentinels.
+			if _, ok := decl.(*ast.FuncDecl); !ok {
+				continue
+			}
+			ast.Inspect(decl, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				pkg, ok := sel.X.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				switch {
+				case pkg.Name == "errors" && sel.Sel.Name == "New":
+					t.Errorf("%s: unclassified errors.New", fset.Position(call.Pos()))
+				case pkg.Name == "fmt" && sel.Sel.Name == "Errorf":
+					format, ok := call.Args[0].(*ast.BasicLit)
+					if !ok {
+						return true
+					}
+					if s, _ := strconv.Unquote(format.Value); !strings.Contains(s, "%w") {
+						t.Errorf("%s: fmt.Errorf without %%w: %s", fset.Position(call.Pos()), format.Value)
+					}
+				}
+				return true
+			})
+		}
+	}
+}
+
+func TestSignedQueryErrors(t *testing.T) {
+	ctx := context.Background()
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	key, _ := crypto.GenerateKey()
+	signer := sapphire.NewPrivateKeySigner(key)
+	mock := newMockBackend()
+	b := newMockWrappedBackend(mock, nil)
+
+	for message, expected := range map[string]error{
+		"invalid signed simulate call query: leash expired":     sapphire.ErrLeashExpired,
+		"invalid signed simulate call query: nonce too high":    sapphire.ErrLeashNonceMismatch,
+		"invalid signed simulate call query: invalid signature": sapphire.ErrSignatureRejected,
+	} {
+		mock.callResult = cbor.Marshal(sdkTypes.CallResult{Failed: &sdkTypes.FailedCallResult{Module: "evm", Code: 1, Message: message}})
+		_, err := b.CallContract(sapphire.ContextWithSigner(ctx, signer), ethereum.CallMsg{From: signer.Address(), To: &to, Data: TestData}, nil)
+		var failed *sapphire.CallFailedError
+		if !errors.Is(err, expected) || !errors.As(err, &failed) || failed.Module != "evm" {
+			t.Errorf("%s: expected %v wrapping the CallFailedError, got %v", message, expected, err)
+		}
+
+		// Unsigned calls have no leash or signature to blame.
+		if _, err = b.CallContract(ctx, ethereum.CallMsg{To: &to, Data: TestData}, nil); errors.Is(err, expected) || !errors.Is(err, sapphire.ErrCallFailed) {
+			t.Errorf("%s: expected an unclassified ErrCallFailed for an unsigned call, got %v", message, err)
+		}
+	}
+
+	mock.callResult = []byte("not cbor")
+	if _, err := b.CallContract(ctx, ethereum.CallMsg{To: &to, Data: TestData}, nil); !errors.Is(err, sapphire.ErrMalformedEnvelope) {
+		t.Fatalf("expected ErrMalformedEnvelope, got %v", err)
+	}
+}
+
+func TestCallFormatRejected(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		rejected bool
+	}{
+		{&sapphire.CallFailedError{Module: "core", Code: 17, Message: "bad call"}, true},
+		{fmt.Errorf("call failed: %w", &sapphire.CallFailedError{Module: "core", Code: 17}), true},
+		{errors.New("mockgateway: call for unknown epoch 3"), true},
+		{&sapphire.CallFailedError{Module: "evm", Code: 17}, false},
+		{errors.New("leash expired"), false},
+		{nil, false},
+	} {
+		if rejected := callFormatRejected(tc.err); rejected != tc.rejected {
+			t.Errorf("%v: expected %v, got %v", tc.err, tc.rejected, rejected)
+		}
+	}
+}
+
+func TestWrapClientNotSapphire(t *testing.T) {
+	rt := newRPCTransport()
+	rt.handle("oasis_callDataPublicKey", nil)
+	_, err := WrapClient(dialTransport(t, rt), nil)
+	if !errors.Is(err, sapphire.ErrNotSapphireChain) || !errors.Is(err, sapphire.ErrKeyFetchFailed) || !errors.Is(err, ErrCapabilityUnsupported{CapabilityCallDataPublicKey}) {
+		t.Fatalf("expected ErrNotSapphireChain, got %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+const (
+	// DefaultFeeHistoryBlocks is the number of blocks sampled by SuggestFees.
+	DefaultFeeHistoryBlocks = 20
+	// DefaultTipPercentile is the percentile of tips paid in each sampled
+	// block that SuggestFees bases its tip on.
+	DefaultTipPercentile = 50
+	// DefaultBaseFeeMultiplier is how many times the next block's base fee
+	// the suggested maximum fee allows for.
+	DefaultBaseFeeMultiplier = 2
+
+	// feeCacheTTL is how long fee suggestions are reused for.
+	feeCacheTTL = 3 * time.Second
+)
+
+// FeeOptions configures how SuggestFees derives fees. The zero value uses
+// the defaults.
+type FeeOptions struct {
+	// BlockCount is the number of recent blocks to sample.
+	BlockCount uint64
+	// TipPercentile selects which tip paid in each block is considered, from
+	// 0 to 100. The suggested tip is the median of the per-block values.
+	TipPercentile float64
+	// BaseFeeMultiplier scales the next block's base fee in the suggested
+	// maximum fee, leaving headroom for base fee increases.
+	BaseFeeMultiplier float64
+	// Legacy skips eth_feeHistory and suggests the gateway's gas price as
+	// both the maximum fee and the tip.
+	Legacy bool
+}
+
+// withDefaults returns the options with unset fields set to their defaults.
+func (o FeeOptions) withDefaults() FeeOptions {
+	if o.BlockCount == 0 {
+		o.BlockCount = DefaultFeeHistoryBlocks
+	}
+	if o.TipPercentile == 0 {
+		o.TipPercentile = DefaultTipPercentile
+	}
+	if o.BaseFeeMultiplier == 0 {
+		o.BaseFeeMultiplier = DefaultBaseFeeMultiplier
+	}
+	return o
+}
+
+// feeSuggestion holds the fees suggested for a transaction.
+type feeSuggestion struct {
+	maxFee *big.Int
+	maxTip *big.Int
+	// gasPrice is the price for legacy transactions, the next block's base
+	// fee plus the tip.
+	gasPrice *big.Int
+}
+
+// feeHistoryReader is implemented by backends that support eth_feeHistory,
+// such as ethclient.Client.
+type feeHistoryReader interface {
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error)
+}
+
+// feeCache holds recent fee suggestions by the options they were made with.
+type feeCache struct {
+	mu      sync.Mutex
+	entries map[FeeOptions]feeCacheEntry
+}
+
+type feeCacheEntry struct {
+	fees    feeSuggestion
+	expires time.Time
+}
+
+func (c *feeCache) get(opts FeeOptions) (feeSuggestion, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[opts]
+	if !ok || time.Now().After(entry.expires) {
+		return feeSuggestion{}, false
+	}
+	return entry.fees, true
+}
+
+func (c *feeCache) put(opts FeeOptions, fees feeSuggestion) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[FeeOptions]feeCacheEntry)
+	}
+	c.entries[opts] = feeCacheEntry{fees: fees, expires: time.Now().Add(feeCacheTTL)}
+}
+
+// SuggestFees suggests the maximum fee and tip for a dynamic fee transaction
+// based on the fees paid in recent blocks, as reported by eth_feeHistory.
+//
+// The tip is the median over the sampled blocks of the opts.TipPercentile-th
+// tip paid in each block, and the maximum fee is the next block's base fee
+// times opts.BaseFeeMultiplier plus the tip. Suggestions are cached for a few
+// seconds.
+//
+// If opts.Legacy is set, or the gateway does not support eth_feeHistory or
+// report base fees, the gateway's gas price is returned as both values.
+func (b *WrappedBackend) SuggestFees(ctx context.Context, opts FeeOptions) (maxFee, maxTip *big.Int, err error) {
+	fees, err := b.suggestFees(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return new(big.Int).Set(fees.maxFee), new(big.Int).Set(fees.maxTip), nil
+}
+
+func (b *WrappedBackend) suggestFees(ctx context.Context, opts FeeOptions) (feeSuggestion, error) {
+	opts = opts.withDefaults()
+	if opts.TipPercentile < 0 || opts.TipPercentile > 100 {
+		return feeSuggestion{}, fmt.Errorf("invalid tip percentile %v", opts.TipPercentile)
+	}
+	if fees, ok := b.fees.get(opts); ok {
+		return fees, nil
+	}
+
+	var fees feeSuggestion
+	history, err := b.feeHistory(ctx, opts)
+	switch {
+	case err != nil:
+		return feeSuggestion{}, fmt.Errorf("failed to fetch fee history: %w", err)
+	case history == nil:
+		gasPrice, err := invoke(ctx, b.mw, rpcRead, b.backend.SuggestGasPrice)
+		if err != nil {
+			return feeSuggestion{}, fmt.Errorf("failed to fetch gas price: %w", err)
+		}
+		fees = feeSuggestion{maxFee: gasPrice, maxTip: gasPrice, gasPrice: gasPrice}
+	default:
+		fees = feesFromHistory(history, opts.BaseFeeMultiplier)
+	}
+	b.fees.put(opts, fees)
+	return fees, nil
+}
+
+// feeHistory fetches the fee history for opts, or returns nil if fees should
+// be suggested in legacy mode.
+func (b *WrappedBackend) feeHistory(ctx context.Context, opts FeeOptions) (*ethereum.FeeHistory, error) {
+	reader, ok := b.backend.(feeHistoryReader)
+	if opts.Legacy || !ok {
+		return nil, nil
+	}
+	history, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (*ethereum.FeeHistory, error) {
+		return reader.FeeHistory(ctx, opts.BlockCount, nil, []float64{opts.TipPercentile})
+	})
+	switch {
+	case isMethodNotFound(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case len(history.BaseFee) == 0 || history.BaseFee[len(history.BaseFee)-1].Sign() == 0:
+		return nil, nil
+	}
+	return history, nil
+}
+
+// feesFromHistory derives fees from a fee history sampled at a single
+// percentile.
+func feesFromHistory(history *ethereum.FeeHistory, multiplier float64) feeSuggestion {
+	tips := make([]*big.Int, 0, len(history.Reward))
+	for _, rewards := range history.Reward {
+		if len(rewards) > 0 && rewards[0] != nil {
+			tips = append(tips, rewards[0])
+		}
+	}
+	maxTip := new(big.Int)
+	if len(tips) > 0 {
+		sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+		maxTip.Set(tips[len(tips)/2])
+	}
+
+	// The last base fee is that of the block after the sampled ones.
+	baseFee := history.BaseFee[len(history.BaseFee)-1]
+	maxBaseFee, _ := new(big.Float).Mul(new(big.Float).SetInt(baseFee), big.NewFloat(multiplier)).Int(nil)
+	return feeSuggestion{
+		maxFee:   maxBaseFee.Add(maxBaseFee, maxTip),
+		maxTip:   maxTip,
+		gasPrice: new(big.Int).Add(baseFee, maxTip),
+	}
+}
+
+// hasFees reports whether any fee of tx is set.
+func hasFees(tx *types.Transaction) bool {
+	return tx.GasPrice().Sign() != 0 || tx.GasFeeCap().Sign() != 0 || tx.GasTipCap().Sign() != 0
+}
+
+// fillFees returns tx with suggested fees if fee suggestions are enabled and
+// the transaction has none.
+func (b *WrappedBackend) fillFees(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	if b.feeOpts == nil || hasFees(tx) {
+		return tx, nil
+	}
+	fees, err := b.suggestFees(ctx, *b.feeOpts)
+	if err != nil {
+		return nil, err
+	}
+	return withFees(tx, fees.gasPrice, fees.maxTip, fees.maxFee)
+}
+
+// withFees returns an unsigned copy of tx with the given fees. gasPrice
+// applies to legacy and access list transactions, tipCap and feeCap to
+// dynamic fee transactions.
+func withFees(tx *types.Transaction, gasPrice, tipCap, feeCap *big.Int) (*types.Transaction, error) {
+	switch tx.Type() {
+	case types.LegacyTxType:
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: gasPrice,
+			Gas:      tx.Gas(),
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     tx.Data(),
+		}), nil
+	case types.AccessListTxType:
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasPrice:   gasPrice,
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		}), nil
+	case types.DynamicFeeTxType:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  tipCap,
+			GasFeeCap:  feeCap,
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		}), nil
+	default:
+		return nil, fmt.Errorf("%w %d", sapphire.ErrUnsupportedTransaction, tx.Type())
+	}
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+func gwei(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(params.GWei))
+}
+
+// cannedFeeHistory serves an eth_feeHistory response with the given tips and
+// base fees, the last of which is that of the next block.
+func cannedFeeHistory(tips []int64, baseFees []int64) rpcHandler {
+	return func([]json.RawMessage) (interface{}, error) {
+		history := map[string]interface{}{
+			"oldestBlock":   hexutil.Uint64(100 - len(tips) + 1),
+			"baseFeePerGas": []*hexutil.Big{},
+			"gasUsedRatio":  []float64{},
+			"reward":        [][]*hexutil.Big{},
+		}
+		for _, tip := range tips {
+			history["reward"] = append(history["reward"].([][]*hexutil.Big), []*hexutil.Big{(*hexutil.Big)(gwei(tip))})
+			history["gasUsedRatio"] = append(history["gasUsedRatio"].([]float64), 0.5)
+		}
+		for _, baseFee := range baseFees {
+			history["baseFeePerGas"] = append(history["baseFeePerGas"].([]*hexutil.Big), (*hexutil.Big)(gwei(baseFee)))
+		}
+		return history, nil
+	}
+}
+
+func newFeeTestBackend(t *testing.T, rt *rpcTransport, opts ...Option) *WrappedBackend {
+	b, err := WrapClient(dialTransport(t, rt), nil, opts...)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	return b
+}
+
+func TestSuggestFees(t *testing.T) {
+	ctx := context.Background()
+	rt := newRPCTransport()
+	rt.handle("eth_feeHistory", cannedFeeHistory([]int64{1, 3, 2, 5}, []int64{100, 100, 100, 100, 110}))
+	b := newFeeTestBackend(t, rt)
+
+	maxFee, maxTip, err := b.SuggestFees(ctx, FeeOptions{})
+	if err != nil {
+		t.Fatalf("SuggestFees failed: %v", err)
+	}
+	if maxTip.Cmp(gwei(3)) != 0 || maxFee.Cmp(gwei(223)) != 0 {
+		t.Fatalf("unexpected fees: max fee %v, tip %v", maxFee, maxTip)
+	}
+	requests := rt.recorded("eth_feeHistory")
+	if len(requests) != 1 {
+		t.Fatalf("expected a single eth_feeHistory request, got %d", len(requests))
+	}
+	var blockCount hexutil.Uint64
+	var percentiles []float64
+	if err = json.Unmarshal(requests[0].Params[0], &blockCount); err != nil || blockCount != DefaultFeeHistoryBlocks {
+		t.Fatalf("unexpected block count %s", requests[0].Params[0])
+	}
+	if err = json.Unmarshal(requests[0].Params[2], &percentiles); err != nil || len(percentiles) != 1 || percentiles[0] != DefaultTipPercentile {
+		t.Fatalf("unexpected reward percentiles %s", requests[0].Params[2])
+	}
+
+	// Suggestions are cached briefly.
+	if _, _, err = b.SuggestFees(ctx, FeeOptions{}); err != nil {
+		t.Fatalf("SuggestFees failed: %v", err)
+	}
+	if n := len(rt.recorded("eth_feeHistory")); n != 1 {
+		t.Fatalf("cached suggestion was not reused, %d requests", n)
+	}
+	for opts, entry := range b.fees.entries {
+		entry.expires = time.Now().Add(-time.Second)
+		b.fees.entries[opts] = entry
+	}
+	if _, _, err = b.SuggestFees(ctx, FeeOptions{}); err != nil {
+		t.Fatalf("SuggestFees failed: %v", err)
+	}
+	if n := len(rt.recorded("eth_feeHistory")); n != 2 {
+		t.Fatalf("expired suggestion was reused, %d requests", n)
+	}
+
+	// Options are passed on and applied.
+	maxFee, _, err = b.SuggestFees(ctx, FeeOptions{BlockCount: 4, TipPercentile: 90, BaseFeeMultiplier: 1.5})
+	if err != nil {
+		t.Fatalf("SuggestFees failed: %v", err)
+	}
+	if maxFee.Cmp(gwei(168)) != 0 {
+		t.Fatalf("unexpected max fee %v", maxFee)
+	}
+	requests = rt.recorded("eth_feeHistory")
+	if err = json.Unmarshal(requests[2].Params[2], &percentiles); err != nil || percentiles[0] != 90 {
+		t.Fatalf("unexpected reward percentiles %s", requests[2].Params[2])
+	}
+
+	if _, _, err = b.SuggestFees(ctx, FeeOptions{TipPercentile: 101}); err == nil {
+		t.Fatalf("invalid percentile was accepted")
+	}
+}
+
+func TestSuggestFeesLegacy(t *testing.T) {
+	ctx := context.Background()
+	gasPrice := big.NewInt(sapphire.DefaultGasPrice)
+
+	for _, tc := range []struct {
+		name string
+		opts FeeOptions
+		h    rpcHandler
+	}{
+		{"forced", FeeOptions{Legacy: true}, cannedFeeHistory([]int64{1}, []int64{100, 100})},
+		{"unsupported", FeeOptions{}, nil},
+		{"no base fee", FeeOptions{}, cannedFeeHistory([]int64{0}, []int64{0, 0})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := newRPCTransport()
+			rt.handle("eth_feeHistory", tc.h)
+			b := newFeeTestBackend(t, rt)
+			maxFee, maxTip, err := b.SuggestFees(ctx, tc.opts)
+			if err != nil {
+				t.Fatalf("SuggestFees failed: %v", err)
+			}
+			if maxFee.Cmp(gasPrice) != 0 || maxTip.Cmp(gasPrice) != 0 {
+				t.Fatalf("expected the gas price, got max fee %v, tip %v", maxFee, maxTip)
+			}
+			if tc.opts.Legacy && len(rt.recorded("eth_feeHistory")) != 0 {
+				t.Fatalf("legacy mode queried the fee history")
+			}
+		})
+	}
+}
+
+func TestFeeSuggestionsFillTransactions(t *testing.T) {
+	ctx := context.Background()
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	rt := newRPCTransport()
+	rt.handle("eth_feeHistory", cannedFeeHistory([]int64{1, 3, 2, 5}, []int64{100, 100, 100, 100, 110}))
+	b := newFeeTestBackend(t, rt, WithKeyring(keyring), WithFeeSuggestions(FeeOptions{}))
+
+	opts := b.Transactor(from)
+	if opts.GasPrice != nil {
+		t.Fatalf("Transactor specified a gas price with fee suggestions enabled")
+	}
+	if tip, err := b.SuggestGasTipCap(ctx); err != nil || tip.Cmp(gwei(3)) != 0 {
+		t.Fatalf("unexpected tip %v: %v", tip, err)
+	}
+	if gasPrice, err := b.SuggestGasPrice(ctx); err != nil || gasPrice.Cmp(gwei(113)) != 0 {
+		t.Fatalf("unexpected gas price %v: %v", gasPrice, err)
+	}
+
+	signedTx, err := opts.Signer(from, types.NewTransaction(0, to, big.NewInt(0), 100_000, nil, TestData))
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if signedTx.GasPrice().Cmp(gwei(113)) != 0 {
+		t.Fatalf("unexpected gas price %v", signedTx.GasPrice())
+	}
+
+	signedTx, err = opts.Signer(from, types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(0x5afd), To: &to, Gas: 21_000}))
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if signedTx.GasFeeCap().Cmp(gwei(223)) != 0 || signedTx.GasTipCap().Cmp(gwei(3)) != 0 {
+		t.Fatalf("unexpected fees: max fee %v, tip %v", signedTx.GasFeeCap(), signedTx.GasTipCap())
+	}
+
+	// Fees set by the caller are left alone.
+	signedTx, err = opts.Signer(from, types.NewTransaction(1, to, big.NewInt(0), 100_000, big.NewInt(sapphire.DefaultGasPrice), TestData))
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if signedTx.GasPrice().Int64() != sapphire.DefaultGasPrice {
+		t.Fatalf("caller's gas price was replaced with %v", signedTx.GasPrice())
+	}
+}
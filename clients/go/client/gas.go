@@ -0,0 +1,61 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// GasMargin is headroom added to estimated gas limits, for transactions whose
+// gas use varies with the state they execute against. The zero value adds
+// none.
+type GasMargin struct {
+	// Percent of the estimate to add, e.g. 20 for 20%.
+	Percent uint64
+	// Absolute is the gas to add after the percentage.
+	Absolute uint64
+}
+
+// apply returns gas with the margin added, saturating instead of overflowing.
+func (m GasMargin) apply(gas uint64) uint64 {
+	total := new(big.Int).SetUint64(gas)
+	total.Mul(total, new(big.Int).SetUint64(m.Percent)).Div(total, big.NewInt(100))
+	total.Add(total, new(big.Int).SetUint64(gas))
+	total.Add(total, new(big.Int).SetUint64(m.Absolute))
+	if !total.IsUint64() {
+		return math.MaxUint64
+	}
+	return total.Uint64()
+}
+
+// GasPaddingError reports a transaction sent with the gas limit set with
+// WithGasPadding that did not use that much gas, so its gas use may reveal
+// what it executed.
+type GasPaddingError struct {
+	TxHash  common.Hash
+	Target  uint64
+	GasUsed uint64
+}
+
+func (e *GasPaddingError) Error() string {
+	return fmt.Sprintf("transaction %s used %d gas instead of the padded %d", e.TxHash.Hex(), e.GasUsed, e.Target)
+}
+
+// checkGasPadding reports receipt to the debug hook if it is of a padded
+// transaction that used other than the padded amount of gas.
+func (b *WrappedBackend) checkGasPadding(receipt *types.Receipt) {
+	if receipt == nil || receipt.GasUsed == b.gasPadding {
+		return
+	}
+	if _, ok := b.padded.get(receipt.TxHash); !ok {
+		return
+	}
+	b.debugRequest(callContext{op: "eth_getTransactionReceipt"}, nil, nil, nil, &GasPaddingError{
+		TxHash:  receipt.TxHash,
+		Target:  b.gasPadding,
+		GasUsed: receipt.GasUsed,
+	})
+}
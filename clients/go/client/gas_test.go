@@ -0,0 +1,284 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+func TestGasMargin(t *testing.T) {
+	for _, tc := range []struct {
+		margin GasMargin
+		gas    uint64
+		want   uint64
+	}{
+		{GasMargin{}, 50_000, 50_000},
+		{GasMargin{Percent: 20}, 50_000, 60_000},
+		{GasMargin{Absolute: 5_000}, 50_000, 55_000},
+		{GasMargin{Percent: 20, Absolute: 5_000}, 50_000, 65_000},
+		{GasMargin{Percent: 10}, 999, 1_098},
+		{GasMargin{Percent: 100}, math.MaxUint64 - 1, math.MaxUint64},
+		{GasMargin{Absolute: 2}, math.MaxUint64 - 1, math.MaxUint64},
+	} {
+		if got := tc.margin.apply(tc.gas); got != tc.want {
+			t.Errorf("%+v applied to %d: expected %d, got %d", tc.margin, tc.gas, tc.want, got)
+		}
+	}
+}
+
+// gasLimitCases covers explicit and estimated gas limits with and without
+// margins. The gateway estimates 50,000 gas.
+var gasLimitCases = []struct {
+	name     string
+	gasLimit uint64
+	margin   GasMargin
+	// want is the expected gas limit.
+	want uint64
+}{
+	{"estimated", 0, GasMargin{}, 50_000},
+	{"estimated with percent", 0, GasMargin{Percent: 20}, 60_000},
+	{"estimated with absolute", 0, GasMargin{Absolute: 5_000}, 55_000},
+	{"estimated with both", 0, GasMargin{Percent: 20, Absolute: 5_000}, 65_000},
+	{"explicit", 70_000, GasMargin{}, 70_000},
+	{"explicit with percent", 70_000, GasMargin{Percent: 20}, 70_000},
+	{"explicit with absolute", 70_000, GasMargin{Absolute: 5_000}, 70_000},
+	{"explicit with both", 70_000, GasMargin{Percent: 20, Absolute: 5_000}, 70_000},
+}
+
+func TestTransactorGasLimit(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(setterABI))
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+
+	for _, tc := range gasLimitCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var events []DebugEvent
+			mock := newMockBackend()
+			mock.gas = 50_000
+			b := newMockWrappedBackend(mock, nil, WithKeyring(keyring), WithGasMargin(tc.margin), WithDebugHook(func(ev DebugEvent) {
+				events = append(events, ev)
+			}))
+
+			opts := b.Transactor(from)
+			opts.GasLimit = tc.gasLimit
+			tx, err := bind.NewBoundContract(to, parsed, b, b, b).Transact(opts, "set", big.NewInt(7))
+			if err != nil {
+				t.Fatalf("Transact failed: %v", err)
+			}
+			if tx.Gas() != tc.want {
+				t.Fatalf("expected gas limit %d, got %d", tc.want, tx.Gas())
+			}
+
+			var estimated bool
+			for _, ev := range events {
+				switch ev.Method {
+				case "eth_estimateGas":
+					estimated = true
+					if ev.GasLimit != tc.want {
+						t.Fatalf("estimate event reports gas limit %d", ev.GasLimit)
+					}
+				case "eth_sendRawTransaction":
+					if ev.GasLimit != tx.Gas() {
+						t.Fatalf("send event reports gas limit %d", ev.GasLimit)
+					}
+				}
+			}
+			if estimated != (tc.gasLimit == 0) {
+				t.Fatalf("estimated: %v, with gas limit %d", estimated, tc.gasLimit)
+			}
+		})
+	}
+}
+
+func TestSapphireTransactorGasLimit(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(setterABI))
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	plaintext, _ := parsed.Pack("set", big.NewInt(7))
+
+	rt := newRPCTransport()
+	rt.handle("eth_estimateGas", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Uint64(50_000), nil
+	})
+	rt.handle("eth_sendRawTransaction", func([]json.RawMessage) (interface{}, error) {
+		return common.Hash{}, nil
+	})
+	client := dialTransport(t, rt)
+
+	for _, tc := range gasLimitCases {
+		// Bindings commonly set the gas limit on a copy of shared options.
+		for _, copied := range []bool{false, true} {
+			name := tc.name
+			if copied {
+				name += " on copy"
+			}
+			t.Run(name, func(t *testing.T) {
+				opts, backend, err := NewSapphireTransactor(context.Background(), client, sapphire.NewPrivateKeySigner(key), big.NewInt(0x5afd), WithEnvelopeGasMargin(tc.margin))
+				if err != nil {
+					t.Fatalf("NewSapphireTransactor failed: %v", err)
+				}
+				if copied {
+					o := *opts
+					opts = &o
+				}
+				if tc.gasLimit != 0 {
+					opts.GasLimit = tc.gasLimit
+				}
+				// Options are inspected before signing, e.g. for logging.
+				if opts.GasLimit != tc.gasLimit {
+					t.Fatalf("expected the options to carry gas limit %d, got %d", tc.gasLimit, opts.GasLimit)
+				}
+				estimates := len(rt.recorded("eth_estimateGas"))
+				tx, err := bind.NewBoundContract(to, parsed, backend, backend, backend).Transact(opts, "set", big.NewInt(7))
+				if err != nil {
+					t.Fatalf("Transact failed: %v", err)
+				}
+				if tx.Gas() != tc.want {
+					t.Fatalf("expected gas limit %d, got %d", tc.want, tx.Gas())
+				}
+				if estimated := len(rt.recorded("eth_estimateGas")) > estimates; estimated != (tc.gasLimit == 0) {
+					t.Fatalf("estimated: %v, with gas limit %d", estimated, tc.gasLimit)
+				}
+				for _, req := range rt.recorded("eth_estimateGas") {
+					if bytes.Contains(req.Params[0], []byte(hexutil.Encode(plaintext)[2:])) {
+						t.Fatalf("gas estimated on the plaintext calldata: %s", req.Params[0])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestGasPadding(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(setterABI))
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+
+	var events []DebugEvent
+	mock := newMockBackend()
+	b := newMockWrappedBackend(mock, nil, WithKeyring(keyring), WithGasPadding(100_000), WithDebugHook(func(ev DebugEvent) {
+		events = append(events, ev)
+	}))
+	contract := bind.NewBoundContract(to, parsed, b, b, b)
+	transact := func(gasLimit uint64, gasUsed uint64) *types.Transaction {
+		opts := b.Transactor(from)
+		if gasLimit != 0 {
+			opts.GasLimit = gasLimit
+		}
+		tx, err := contract.Transact(opts, "set", big.NewInt(7))
+		if err != nil {
+			t.Fatalf("Transact failed: %v", err)
+		}
+		mock.mine(tx, types.ReceiptStatusSuccessful, gasUsed)
+		if _, err = b.TransactionReceipt(context.Background(), tx.Hash()); err != nil {
+			t.Fatalf("TransactionReceipt failed: %v", err)
+		}
+		return tx
+	}
+
+	tx := transact(0, 100_000)
+	if tx.Gas() != 100_000 {
+		t.Fatalf("expected the padded gas limit, got %d", tx.Gas())
+	}
+	for _, ev := range events {
+		if ev.Method == "eth_estimateGas" || ev.Err != nil {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	}
+
+	events = nil
+	tx = transact(0, 90_000)
+	var paddingErr *GasPaddingError
+	last := events[len(events)-1]
+	if last.Method != "eth_getTransactionReceipt" || !errors.As(last.Err, &paddingErr) {
+		t.Fatalf("expected the gas padding mismatch to be reported, got %+v", last)
+	}
+	if paddingErr.TxHash != tx.Hash() || paddingErr.Target != 100_000 || paddingErr.GasUsed != 90_000 {
+		t.Fatalf("unexpected error %+v", paddingErr)
+	}
+
+	// Transactions with another gas limit are not padded.
+	events = nil
+	transact(50_000, 21_000)
+	for _, ev := range events {
+		if ev.Err != nil {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	}
+}
+
+// padGasCode deploys a contract that stores to a slot if the first word of
+// the calldata is non-zero, then pads its gas use to the second word with
+// the gas padding precompile.
+var padGasCode = common.FromHex("603c80600b6000396000f3" +
+	"60003515600c5760016000555b602035600052600060006020600073010000000000000000000000000000000000000a5afa15603757005b600080fd")
+
+func TestGasPaddingLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	signer := sapphire.NewPrivateKeySigner(key)
+	client := localnet.Dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var mismatches int
+	b, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(signer)), WithGasPadding(200_000), WithDebugHook(func(ev DebugEvent) {
+		if errors.As(ev.Err, new(*GasPaddingError)) {
+			mismatches++
+		}
+	}))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	opts := b.Transactor(signer.Address())
+	opts.Context = ctx
+	_, tx, _, err := DeployConfidential(opts, b, abi.ABI{}, padGasCode)
+	if err != nil {
+		t.Fatalf("DeployConfidential failed: %v", err)
+	}
+	addr, err := b.WaitDeployed(ctx, tx)
+	if err != nil {
+		t.Fatalf("deployment failed: %v", err)
+	}
+	mismatches = 0
+
+	contract := bind.NewBoundContract(addr, abi.ABI{}, b, b, b)
+	var gasUsed [2]uint64
+	for branch := range gasUsed {
+		data := append(common.LeftPadBytes([]byte{byte(branch)}, 32), common.LeftPadBytes(big.NewInt(100_000).Bytes(), 32)...)
+		tx, err := contract.RawTransact(opts, data)
+		if err != nil {
+			t.Fatalf("RawTransact failed: %v", err)
+		}
+		receipt, err := bind.WaitMined(ctx, b, tx)
+		if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+			t.Fatalf("transaction failed: %v", err)
+		}
+		gasUsed[branch] = receipt.GasUsed
+	}
+	// Only the encrypted calldata, whose zero bytes cost less, may differ.
+	if diff := int64(gasUsed[1]) - int64(gasUsed[0]); diff < -200 || diff > 200 {
+		t.Fatalf("gas use depends on the branch taken: %d and %d", gasUsed[0], gasUsed[1])
+	}
+	if mismatches != 2 {
+		t.Fatalf("expected both receipts to be reported as not padded to 200,000 gas, got %d", mismatches)
+	}
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/oasisprotocol/oasis-core/go/common"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/crypto/signature"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/crypto/signature/ed25519"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/crypto/signature/secp256k1"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/crypto/signature/sr25519"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+const (
+	gaslessMethodCall   = "evm.Call"
+	gaslessMethodCreate = "evm.Create"
+)
+
+// GaslessFee is the fee the fee payer of a gasless transaction pays.
+type GaslessFee struct {
+	// Amount is the fee in runtime base units, i.e. wei. Nil pays nothing.
+	Amount *big.Int
+	// Gas is the gas limit of the outer transaction, the inner transaction's
+	// if 0.
+	Gas uint64
+}
+
+// GaslessChainContext returns the context runtime transactions are signed
+// for: that of the runtime with ID runtimeID, as in NetworkParams, on the
+// consensus layer with the given chain context.
+func GaslessChainContext(runtimeID string, consensusChainContext string) (signature.Context, error) {
+	raw, err := hexutil.Decode(runtimeID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid runtime ID %q: %w", runtimeID, err)
+	}
+	var id common.Namespace
+	if err = id.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("invalid runtime ID %q: %w", runtimeID, err)
+	}
+	return &signature.RichContext{
+		RuntimeID:    id,
+		ChainContext: consensusChainContext,
+		Base:         sdkTypes.SignatureContextBase,
+	}, nil
+}
+
+var (
+	// ErrGaslessInner is returned for gasless transactions whose inner
+	// transaction, the one the user signed, is invalid.
+	ErrGaslessInner = errors.New("invalid inner transaction")
+	// ErrGaslessOuter is returned for gasless transactions whose outer
+	// transaction, the one the fee payer signs, can't be built or decoded.
+	ErrGaslessOuter = errors.New("invalid outer transaction")
+)
+
+// FeePayer pays the fees of gasless transactions. It signs their outer
+// transactions with a runtime key, of any scheme the runtime supports, and
+// hands out the nonces of its runtime account, which are distinct from those
+// of any Ethereum account.
+type FeePayer interface {
+	signature.Signer
+	// ChainContext returns the context outer transactions are signed for,
+	// see GaslessChainContext.
+	ChainContext() signature.Context
+	// NextNonce reserves the nonce of the next outer transaction.
+	NextNonce() uint64
+}
+
+// LocalFeePayer is a FeePayer that tracks its nonce in memory, starting at
+// the account's nonce when it was created.
+type LocalFeePayer struct {
+	signature.Signer
+
+	chainContext signature.Context
+	mu           sync.Mutex
+	nonce        uint64
+}
+
+// NewFeePayer returns a FeePayer signing with signer for chainContext, whose
+// runtime account's next nonce is nonce.
+func NewFeePayer(signer signature.Signer, chainContext signature.Context, nonce uint64) *LocalFeePayer {
+	return &LocalFeePayer{Signer: signer, chainContext: chainContext, nonce: nonce}
+}
+
+// ChainContext implements FeePayer.
+func (p *LocalFeePayer) ChainContext() signature.Context {
+	return p.chainContext
+}
+
+// NextNonce implements FeePayer.
+func (p *LocalFeePayer) NextNonce() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	nonce := p.nonce
+	p.nonce++
+	return nonce
+}
+
+// SetNonce resets the next nonce, e.g. after an outer transaction was not
+// submitted.
+func (p *LocalFeePayer) SetNonce(nonce uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nonce = nonce
+}
+
+// Address returns the address of the payer's runtime account, or the zero
+// address if its key scheme is not supported.
+func (p *LocalFeePayer) Address() sdkTypes.Address {
+	spec, err := gaslessAddressSpec(p.Public())
+	if err != nil {
+		return sdkTypes.Address{}
+	}
+	return sdkTypes.NewAddress(spec)
+}
+
+// GaslessCall is the call a gasless transaction makes, that of its inner
+// transaction.
+type GaslessCall struct {
+	// To is the called account, nil for contract creations.
+	To    *ethCommon.Address
+	Value *big.Int
+	Data  []byte
+}
+
+// WrapGasless wraps the signed Ethereum transaction inner in a runtime
+// transaction whose fee is paid by payer, see EncodeGasless, with the next
+// nonce of the payer. The nonce is only reserved once inner was found valid.
+//
+// Errors wrap ErrGaslessInner if inner is to blame, and ErrGaslessOuter if
+// the outer transaction could not be built.
+func WrapGasless(inner *types.Transaction, payer FeePayer, fee GaslessFee) ([]byte, error) {
+	if err := checkGaslessInner(inner); err != nil {
+		return nil, err
+	}
+	return encodeGaslessOuter(inner, payer, payer.NextNonce(), fee, payer.ChainContext())
+}
+
+// EncodeGasless makes the call of the signed Ethereum transaction inner with
+// a runtime transaction whose fee is paid by payer, and returns the encoded
+// sdkTypes.UnverifiedTransaction for the runtime to check and submit.
+//
+// The outer transaction calls evm.Call with the address, value and data of
+// inner, or evm.Create with its value and init code for contract creations,
+// the bodies the runtime decodes for these methods. The runtime only
+// authenticates the payer, so the call is made from the payer's account:
+// contracts see it as msg.sender, and the inner transaction's nonce, gas
+// price and signature are not used. Its signature only shows that the user
+// asked for the call, so relay calls to contracts that authenticate users
+// themselves, e.g. with a Permission. Calldata encrypted by
+// PrepareTransaction is passed on as is and decrypted by the runtime.
+//
+// The payer signs the outer transaction for chainContext, see
+// GaslessChainContext, with its runtime account nonce payerNonce. Use
+// WrapGasless to have a FeePayer track the nonce.
+//
+// Errors wrap ErrGaslessInner or ErrGaslessOuter as those of WrapGasless.
+func EncodeGasless(inner *types.Transaction, payer signature.Signer, payerNonce uint64, fee GaslessFee, chainContext signature.Context) ([]byte, error) {
+	if err := checkGaslessInner(inner); err != nil {
+		return nil, err
+	}
+	return encodeGaslessOuter(inner, payer, payerNonce, fee, chainContext)
+}
+
+// checkGaslessInner checks that the inner transaction is signed by its user.
+func checkGaslessInner(inner *types.Transaction) error {
+	if inner == nil {
+		return fmt.Errorf("%w: no transaction", ErrGaslessInner)
+	}
+	if !inner.Protected() {
+		return fmt.Errorf("%w: not replay protected", ErrGaslessInner)
+	}
+	if _, err := types.Sender(types.LatestSignerForChainID(inner.ChainId()), inner); err != nil {
+		return fmt.Errorf("%w: not signed: %v", ErrGaslessInner, err)
+	}
+	if inner.Value().BitLen() > 256 {
+		return fmt.Errorf("%w: value overflows 256 bits", ErrGaslessInner)
+	}
+	return nil
+}
+
+// encodeGaslessOuter builds and signs the outer transaction making the call
+// of inner.
+func encodeGaslessOuter(inner *types.Transaction, payer signature.Signer, payerNonce uint64, fee GaslessFee, chainContext signature.Context) ([]byte, error) {
+	spec, err := gaslessAddressSpec(payer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGaslessOuter, err)
+	}
+	amount := quantity.NewQuantity()
+	if fee.Amount != nil {
+		if err = amount.FromBigInt(fee.Amount); err != nil {
+			return nil, fmt.Errorf("%w: invalid fee amount: %v", ErrGaslessOuter, err)
+		}
+	}
+	gas := fee.Gas
+	if gas == 0 {
+		gas = inner.Gas()
+	}
+	// The runtime decodes values as 256-bit big-endian integers.
+	value := inner.Value().FillBytes(make([]byte, 32))
+	txFee := &sdkTypes.Fee{Amount: sdkTypes.NewBaseUnits(*amount, sdkTypes.NativeDenomination), Gas: gas}
+	var tx *sdkTypes.Transaction
+	if to := inner.To(); to != nil {
+		tx = sdkTypes.NewTransaction(txFee, gaslessMethodCall, evm.Call{Address: to.Bytes(), Value: value, Data: inner.Data()})
+	} else {
+		tx = sdkTypes.NewTransaction(txFee, gaslessMethodCreate, evm.Create{Value: value, InitCode: inner.Data()})
+	}
+	tx.AppendAuthSignature(spec, payerNonce)
+	ts := tx.PrepareForSigning()
+	if err = ts.AppendSign(chainContext, payer); err != nil {
+		return nil, fmt.Errorf("%w: failed to sign: %v", ErrGaslessOuter, err)
+	}
+	return cbor.Marshal(ts.UnverifiedTransaction()), nil
+}
+
+// DecodeGasless decodes a transaction encoded by EncodeGasless into the call
+// it makes and the outer transaction. It does not verify the payer's
+// signature. Errors wrap ErrGaslessOuter.
+func DecodeGasless(wrapped []byte) (*GaslessCall, *sdkTypes.Transaction, error) {
+	var ut sdkTypes.UnverifiedTransaction
+	if err := cbor.Unmarshal(wrapped, &ut); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrGaslessOuter, err)
+	}
+	var outer sdkTypes.Transaction
+	if err := cbor.Unmarshal(ut.Body, &outer); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrGaslessOuter, err)
+	}
+	if err := outer.ValidateBasic(); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrGaslessOuter, err)
+	}
+	if outer.Call.Method != gaslessMethodCall && outer.Call.Method != gaslessMethodCreate {
+		return nil, nil, fmt.Errorf("%w: calls %s", ErrGaslessOuter, outer.Call.Method)
+	}
+	var call GaslessCall
+	var value []byte
+	if outer.Call.Method == gaslessMethodCall {
+		var body evm.Call
+		if err := cbor.Unmarshal(outer.Call.Body, &body); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrGaslessOuter, err)
+		}
+		if len(body.Address) != ethCommon.AddressLength {
+			return nil, nil, fmt.Errorf("%w: invalid address length %d", ErrGaslessOuter, len(body.Address))
+		}
+		to := ethCommon.BytesToAddress(body.Address)
+		call.To, call.Data, value = &to, body.Data, body.Value
+	} else {
+		var body evm.Create
+		if err := cbor.Unmarshal(outer.Call.Body, &body); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrGaslessOuter, err)
+		}
+		call.Data, value = body.InitCode, body.Value
+	}
+	if len(value) > 32 {
+		return nil, nil, fmt.Errorf("%w: invalid value length %d", ErrGaslessOuter, len(value))
+	}
+	call.Value = new(big.Int).SetBytes(value)
+	return &call, &outer, nil
+}
+
+// gaslessAddressSpec returns how the runtime derives the address of a payer
+// with public key pk.
+func gaslessAddressSpec(pk signature.PublicKey) (sdkTypes.SignatureAddressSpec, error) {
+	switch pk := pk.(type) {
+	case ed25519.PublicKey:
+		return sdkTypes.NewSignatureAddressSpecEd25519(pk), nil
+	case secp256k1.PublicKey:
+		return sdkTypes.NewSignatureAddressSpecSecp256k1Eth(pk), nil
+	case sr25519.PublicKey:
+		return sdkTypes.NewSignatureAddressSpecSr25519(pk), nil
+	default:
+		return sdkTypes.SignatureAddressSpec{}, fmt.Errorf("unsupported fee payer key type %T", pk)
+	}
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/crypto/signature"
+	sdkTesting "github.com/oasisprotocol/oasis-sdk/client-sdk/go/testing"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// gaslessChainContext returns the signature context of runtimeID on a
+// consensus layer with an arbitrary chain context.
+func gaslessChainContext(t *testing.T, runtimeID string) signature.Context {
+	chainContext, err := GaslessChainContext(runtimeID, "074fcd4a15e8ce2bdd76de7d2914f2a77c7b2871852d8644b2b9f7bbec8a12c8")
+	if err != nil {
+		t.Fatalf("GaslessChainContext failed: %v", err)
+	}
+	return chainContext
+}
+
+// gaslessInnerTxs returns a signed legacy, EIP-1559 and contract creation
+// transaction.
+func gaslessInnerTxs(t *testing.T) map[string]*types.Transaction {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	chainID := big.NewInt(0x5afd)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	txs := map[string]types.TxData{
+		"legacy": &types.LegacyTx{Nonce: 3, GasPrice: big.NewInt(0), Gas: 100_000, To: &to, Value: big.NewInt(1), Data: []byte{1, 2, 3}},
+		"1559":   &types.DynamicFeeTx{ChainID: chainID, Nonce: 3, Gas: 100_000, GasFeeCap: big.NewInt(0), GasTipCap: big.NewInt(0), To: &to, Data: []byte{1, 2, 3}},
+		"create": &types.LegacyTx{Nonce: 3, GasPrice: big.NewInt(0), Gas: 100_000, Data: []byte{0x60, 0x80}},
+	}
+	signed := make(map[string]*types.Transaction, len(txs))
+	for name, data := range txs {
+		tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), data)
+		if err != nil {
+			t.Fatalf("failed to sign %s transaction: %v", name, err)
+		}
+		signed[name] = tx
+	}
+	return signed
+}
+
+func TestEncodeGasless(t *testing.T) {
+	chainContext := gaslessChainContext(t, Networks[0x5afd].RuntimeID)
+	fee := GaslessFee{Amount: big.NewInt(100_000 * sapphire.DefaultGasPrice)}
+	for name, inner := range gaslessInnerTxs(t) {
+		for _, payer := range []sdkTesting.TestKey{sdkTesting.Alice, sdkTesting.Dave, sdkTesting.Frank} {
+			wrapped, err := EncodeGasless(inner, payer.Signer, 7, fee, chainContext)
+			if err != nil {
+				t.Fatalf("%s: EncodeGasless failed: %v", name, err)
+			}
+			call, outer, err := DecodeGasless(wrapped)
+			if err != nil {
+				t.Fatalf("%s: DecodeGasless failed: %v", name, err)
+			}
+			if (call.To == nil) != (inner.To() == nil) || call.To != nil && *call.To != *inner.To() || call.Value.Cmp(inner.Value()) != 0 || string(call.Data) != string(inner.Data()) {
+				t.Fatalf("%s: unexpected call %+v", name, call)
+			}
+			// The body has the fields the runtime decodes for the method.
+			method, fields := sdkTypes.MethodName("evm.Call"), []string{"address", "value", "data"}
+			if name == "create" {
+				method, fields = "evm.Create", []string{"value", "init_code"}
+			}
+			var body map[string][]byte
+			if err = cbor.Unmarshal(outer.Call.Body, &body); err != nil || len(body) != len(fields) {
+				t.Fatalf("%s: unexpected body %x: %v", name, outer.Call.Body, err)
+			}
+			for _, field := range fields {
+				if _, ok := body[field]; !ok {
+					t.Fatalf("%s: body %x has no %s", name, outer.Call.Body, field)
+				}
+			}
+			if len(body["value"]) != 32 {
+				t.Fatalf("%s: expected a 256-bit value, got %x", name, body["value"])
+			}
+			if outer.Call.Method != method || outer.AuthInfo.Fee.Gas != inner.Gas() || outer.AuthInfo.Fee.Amount.Amount.ToBigInt().Cmp(fee.Amount) != 0 {
+				t.Fatalf("%s: unexpected outer transaction %+v", name, outer)
+			}
+			if len(outer.AuthInfo.SignerInfo) != 1 || outer.AuthInfo.SignerInfo[0].Nonce != 7 || !outer.AuthInfo.SignerInfo[0].AddressSpec.Signature.PublicKey().Equal(payer.Signer.Public()) {
+				t.Fatalf("%s: unexpected signer info %+v", name, outer.AuthInfo.SignerInfo)
+			}
+
+			// The runtime verifies the payer's signature the same way.
+			var ut sdkTypes.UnverifiedTransaction
+			if err = cbor.Unmarshal(wrapped, &ut); err != nil {
+				t.Fatalf("%s: malformed encoding: %v", name, err)
+			}
+			if _, err = ut.Verify(chainContext); err != nil {
+				t.Fatalf("%s: payer signature does not verify: %v", name, err)
+			}
+			if _, err = ut.Verify(gaslessChainContext(t, Networks[0x5afe].RuntimeID)); err == nil {
+				t.Fatalf("%s: payer signature verifies for another runtime", name)
+			}
+		}
+	}
+}
+
+func TestEncodeGaslessInvalid(t *testing.T) {
+	chainContext := gaslessChainContext(t, Networks[0x5afd].RuntimeID)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	unsigned := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(0x5afd), Gas: 100_000, To: &to})
+	if _, err := EncodeGasless(unsigned, sdkTesting.Alice.Signer, 0, GaslessFee{}, chainContext); !errors.Is(err, ErrGaslessInner) {
+		t.Fatalf("expected an unsigned inner transaction to be rejected")
+	}
+	key, _ := crypto.GenerateKey()
+	unprotected, _ := types.SignTx(types.NewTransaction(0, to, nil, 100_000, big.NewInt(0), nil), types.HomesteadSigner{}, key)
+	if _, err := EncodeGasless(unprotected, sdkTesting.Alice.Signer, 0, GaslessFee{}, chainContext); !errors.Is(err, ErrGaslessInner) {
+		t.Fatalf("expected an unprotected inner transaction to be rejected")
+	}
+	overflowing, _ := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(0x5afd)), &types.LegacyTx{Gas: 100_000, GasPrice: big.NewInt(0), To: &to, Value: new(big.Int).Lsh(big.NewInt(1), 256)})
+	if _, err := EncodeGasless(overflowing, sdkTesting.Alice.Signer, 0, GaslessFee{}, chainContext); !errors.Is(err, ErrGaslessInner) {
+		t.Fatalf("expected a value overflowing 256 bits to be rejected")
+	}
+	if _, err := EncodeGasless(gaslessInnerTxs(t)["legacy"], sdkTesting.Alice.Signer, 0, GaslessFee{Amount: big.NewInt(-1)}, chainContext); !errors.Is(err, ErrGaslessOuter) {
+		t.Fatalf("expected a negative fee to be rejected")
+	}
+	if _, _, err := DecodeGasless([]byte{0xff}); !errors.Is(err, ErrGaslessOuter) {
+		t.Fatalf("expected a malformed encoding to be rejected")
+	}
+	if _, err := GaslessChainContext("0x1234", ""); err == nil {
+		t.Fatalf("expected a malformed runtime ID to be rejected")
+	}
+}
+
+func TestWrapGasless(t *testing.T) {
+	chainContext := gaslessChainContext(t, Networks[0x5afd].RuntimeID)
+	payer := NewFeePayer(sdkTesting.Alice.Signer, chainContext, 5)
+	if !payer.Address().Equal(sdkTesting.Alice.Address) {
+		t.Fatalf("unexpected payer address %s", payer.Address())
+	}
+	inner := gaslessInnerTxs(t)["1559"]
+	wrapNonce := func() uint64 {
+		wrapped, err := WrapGasless(inner, payer, GaslessFee{})
+		if err != nil {
+			t.Fatalf("WrapGasless failed: %v", err)
+		}
+		_, outer, err := DecodeGasless(wrapped)
+		if err != nil {
+			t.Fatalf("DecodeGasless failed: %v", err)
+		}
+		return outer.AuthInfo.SignerInfo[0].Nonce
+	}
+	if n := wrapNonce(); n != 5 {
+		t.Fatalf("expected the first nonce to be 5, got %d", n)
+	}
+
+	// Invalid inner transactions don't use up a nonce.
+	if _, err := WrapGasless(types.NewTx(&types.LegacyTx{}), payer, GaslessFee{}); !errors.Is(err, ErrGaslessInner) {
+		t.Fatalf("expected an unsigned inner transaction to be rejected, got %v", err)
+	}
+	if n := wrapNonce(); n != 6 {
+		t.Fatalf("expected nonce 6, got %d", n)
+	}
+
+	// Concurrent wraps get distinct nonces.
+	var wg sync.WaitGroup
+	seen := make(chan uint64, 50)
+	for i := 0; i < cap(seen); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seen <- wrapNonce()
+		}()
+	}
+	wg.Wait()
+	close(seen)
+	distinct := make(map[uint64]bool)
+	for n := range seen {
+		distinct[n] = true
+	}
+	if len(distinct) != cap(seen) {
+		t.Fatalf("expected %d distinct nonces, got %d", cap(seen), len(distinct))
+	}
+
+	payer.SetNonce(1)
+	if n := wrapNonce(); n != 1 {
+		t.Fatalf("expected nonce 1 after SetNonce, got %d", n)
+	}
+}
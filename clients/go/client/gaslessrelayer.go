@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/crypto/signature"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+// DefaultGaslessDedupWindow is how long a GaslessRelayer remembers the
+// transactions it submitted.
+const DefaultGaslessDedupWindow = 10 * time.Minute
+
+// ErrGaslessDuplicate is returned by GaslessRelayer.Relay for transactions it
+// already submitted.
+var ErrGaslessDuplicate = errors.New("gasless transaction already submitted")
+
+// GaslessSpend describes a fee a GaslessRelayer paid for a user.
+type GaslessSpend struct {
+	// User is the sender of the inner transaction.
+	User                 common.Address
+	InnerHash, OuterHash common.Hash
+	// Nonce is the payer's runtime nonce the outer transaction used.
+	Nonce uint64
+	Fee   GaslessFee
+}
+
+// GaslessRelayerOptions configures a GaslessRelayer. The zero value uses the
+// defaults.
+type GaslessRelayerOptions struct {
+	// DedupWindow is how long a submitted transaction is remembered, and
+	// submitting it again fails with ErrGaslessDuplicate.
+	DedupWindow time.Duration
+	// Fee, if set, returns the fee to pay for an inner transaction sent by
+	// user. By default, the relayer pays nothing and the outer transaction's
+	// gas limit is the inner one's.
+	Fee func(user common.Address, inner *types.Transaction) (GaslessFee, error)
+	// OnSpend, if set, is called after each submission, e.g. to account the
+	// fees paid per user. Calls are serialized.
+	OnSpend func(GaslessSpend)
+}
+
+// withDefaults returns the options with unset fields set to their defaults.
+func (o GaslessRelayerOptions) withDefaults() GaslessRelayerOptions {
+	if o.DedupWindow == 0 {
+		o.DedupWindow = DefaultGaslessDedupWindow
+	}
+	return o
+}
+
+// GaslessRelayer pays for the gasless transactions of many users from one
+// runtime account, which must not be used to sign transactions elsewhere.
+//
+// Submissions are serialized, so that each outer transaction uses the next
+// nonce of the payer. The nonce is fetched with RuntimeNonce before the first
+// submission, and again after a submission failed, as the gateway may or may
+// not have accepted it.
+type GaslessRelayer struct {
+	backend      *WrappedBackend
+	payer        signature.Signer
+	address      sdkTypes.Address
+	chainContext signature.Context
+	opts         GaslessRelayerOptions
+	now          func() time.Time
+
+	mu     sync.Mutex
+	nonce  uint64
+	synced bool
+	// submitted maps the inner hashes of submitted transactions to when they
+	// were submitted and their outer hashes.
+	submitted map[common.Hash]gaslessSubmission
+}
+
+type gaslessSubmission struct {
+	at        time.Time
+	outerHash common.Hash
+}
+
+// NewGaslessRelayer returns a relayer submitting through backend the gasless
+// transactions paid for by payer, signed for chainContext, see
+// GaslessChainContext.
+func NewGaslessRelayer(backend *WrappedBackend, payer signature.Signer, chainContext signature.Context, opts GaslessRelayerOptions) (*GaslessRelayer, error) {
+	spec, err := gaslessAddressSpec(payer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &GaslessRelayer{
+		backend:      backend,
+		payer:        payer,
+		address:      sdkTypes.NewAddress(spec),
+		chainContext: chainContext,
+		opts:         opts.withDefaults(),
+		now:          time.Now,
+		submitted:    make(map[common.Hash]gaslessSubmission),
+	}, nil
+}
+
+// Address returns the address of the payer's runtime account.
+func (r *GaslessRelayer) Address() sdkTypes.Address {
+	return r.address
+}
+
+// Resync makes the relayer fetch the payer's nonce again before the next
+// submission, e.g. after the account was used elsewhere.
+func (r *GaslessRelayer) Resync() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.synced = false
+}
+
+// Relay makes the call of the signed Ethereum transaction inner, see
+// EncodeGasless, and submits it with SubmitGasless. Use WaitGasless with the
+// outer hash returned to wait for it. The inner hash identifies inner for
+// deduplication, and is not found on chain.
+//
+// Transactions submitted within the dedup window are not submitted again:
+// Relay returns their hashes with an error wrapping ErrGaslessDuplicate.
+// Errors wrap ErrGaslessInner if inner is to blame.
+func (r *GaslessRelayer) Relay(ctx context.Context, inner *types.Transaction) (innerHash, outerHash common.Hash, err error) {
+	if err = checkGaslessInner(inner); err != nil {
+		return common.Hash{}, common.Hash{}, err
+	}
+	user, _ := types.Sender(types.LatestSignerForChainID(inner.ChainId()), inner)
+	innerHash = inner.Hash()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for hash, s := range r.submitted {
+		if now.Sub(s.at) >= r.opts.DedupWindow {
+			delete(r.submitted, hash)
+		}
+	}
+	if s, ok := r.submitted[innerHash]; ok {
+		return innerHash, s.outerHash, fmt.Errorf("%w: %s", ErrGaslessDuplicate, innerHash.Hex())
+	}
+
+	fee := GaslessFee{}
+	if r.opts.Fee != nil {
+		if fee, err = r.opts.Fee(user, inner); err != nil {
+			return innerHash, common.Hash{}, fmt.Errorf("failed to determine fee: %w", err)
+		}
+	}
+	if !r.synced {
+		if r.nonce, err = r.backend.RuntimeNonce(ctx, r.address); err != nil {
+			return innerHash, common.Hash{}, err
+		}
+		r.synced = true
+	}
+	wrapped, err := EncodeGasless(inner, r.payer, r.nonce, fee, r.chainContext)
+	if err != nil {
+		return innerHash, common.Hash{}, err
+	}
+	if outerHash, err = r.backend.SubmitGasless(ctx, wrapped); err != nil {
+		r.synced = false
+		return innerHash, outerHash, err
+	}
+
+	r.submitted[innerHash] = gaslessSubmission{at: now, outerHash: outerHash}
+	if r.opts.OnSpend != nil {
+		r.opts.OnSpend(GaslessSpend{User: user, InnerHash: innerHash, OuterHash: outerHash, Nonce: r.nonce, Fee: fee})
+	}
+	r.nonce++
+	return innerHash, outerHash, nil
+}
@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTesting "github.com/oasisprotocol/oasis-sdk/client-sdk/go/testing"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+// gaslessGateway serves the payer's runtime nonce and accepts gasless
+// transactions, recording the payer nonce of each call submitted by its
+// data, which relayedTxs makes distinct.
+type gaslessGateway struct {
+	mu      sync.Mutex
+	nonce   uint64
+	queries int
+	nonces  map[string]uint64
+	// fail, if set, rejects the next submission without recording it.
+	fail bool
+}
+
+func newGaslessGateway(t *testing.T, rt *rpcTransport, nonce uint64) *gaslessGateway {
+	g := &gaslessGateway{nonce: nonce, nonces: make(map[string]uint64)}
+	rt.handle("eth_call", func([]json.RawMessage) (interface{}, error) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.queries++
+		bytesType, _ := abi.NewType("bytes", "", nil)
+		uint64Type, _ := abi.NewType("uint64", "", nil)
+		out, _ := abi.Arguments{{Type: uint64Type}, {Type: bytesType}}.Pack(uint64(0), cbor.Marshal(g.nonce))
+		return hexutil.Bytes(out), nil
+	})
+	rt.handle(CapabilityGaslessSubmit, func(params []json.RawMessage) (interface{}, error) {
+		var wrapped hexutil.Bytes
+		if err := json.Unmarshal(params[0], &wrapped); err != nil {
+			t.Errorf("unexpected submission %s: %v", params[0], err)
+		}
+		call, outer, err := DecodeGasless(wrapped)
+		if err != nil {
+			t.Errorf("failed to decode submission: %v", err)
+			return nil, err
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.fail {
+			g.fail = false
+			return nil, errors.New("invalid nonce")
+		}
+		if _, ok := g.nonces[string(call.Data)]; ok {
+			t.Errorf("call %x submitted twice", call.Data)
+		}
+		g.nonces[string(call.Data)] = outer.AuthInfo.SignerInfo[0].Nonce
+		g.nonce++
+		var ut sdkTypes.UnverifiedTransaction
+		_ = cbor.Unmarshal(wrapped, &ut)
+		return common.Hash(ut.Hash()), nil
+	})
+	return g
+}
+
+// relayedTxs returns n signed transactions of two users, with distinct data.
+func relayedTxs(t *testing.T, n int) []*types.Transaction {
+	keys := []string{
+		"c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750",
+		"ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80",
+	}
+	chainID := big.NewInt(0x5afd)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	txs := make([]*types.Transaction, n)
+	for i := range txs {
+		key, _ := crypto.HexToECDSA(keys[i%len(keys)])
+		tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), &types.LegacyTx{Nonce: uint64(i / len(keys)), GasPrice: big.NewInt(0), Gas: 100_000, To: &to, Data: []byte{byte(i)}})
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		txs[i] = tx
+	}
+	return txs
+}
+
+func TestGaslessRelayer(t *testing.T) {
+	ctx := context.Background()
+	rt := newRPCTransport()
+	gateway := newGaslessGateway(t, rt, 5)
+	b, err := WrapClient(dialTransport(t, rt), nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	spent := make(map[common.Address]uint64)
+	r, err := NewGaslessRelayer(b, sdkTesting.Alice.Signer, gaslessChainContext(t, Networks[0x5afd].RuntimeID), GaslessRelayerOptions{
+		DedupWindow: time.Minute,
+		Fee: func(_ common.Address, inner *types.Transaction) (GaslessFee, error) {
+			return GaslessFee{Amount: big.NewInt(int64(inner.Gas()))}, nil
+		},
+		OnSpend: func(s GaslessSpend) {
+			spent[s.User] += s.Fee.Amount.Uint64()
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewGaslessRelayer failed: %v", err)
+	}
+	if !r.Address().Equal(sdkTesting.Alice.Address) {
+		t.Fatalf("expected payer %s, got %s", sdkTesting.Alice.Address, r.Address())
+	}
+	now := time.Unix(1_700_000_000, 0)
+	r.now = func() time.Time { return now }
+
+	txs := relayedTxs(t, 3)
+	innerHash, outerHash, err := r.Relay(ctx, txs[0])
+	if err != nil || innerHash != txs[0].Hash() {
+		t.Fatalf("Relay returned %s: %v", innerHash.Hex(), err)
+	}
+	if _, duplicate, err := r.Relay(ctx, txs[0]); !errors.Is(err, ErrGaslessDuplicate) || duplicate != outerHash {
+		t.Fatalf("expected ErrGaslessDuplicate with outer hash %s, got %s: %v", outerHash.Hex(), duplicate.Hex(), err)
+	}
+	if _, _, err = r.Relay(ctx, txs[1]); err != nil {
+		t.Fatalf("Relay failed: %v", err)
+	}
+	if gateway.nonces[string(txs[0].Data())] != 5 || gateway.nonces[string(txs[1].Data())] != 6 || gateway.queries != 1 {
+		t.Fatalf("expected consecutive nonces from a single query, got %v after %d queries", gateway.nonces, gateway.queries)
+	}
+	for _, tx := range txs[:2] {
+		user, _ := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		if spent[user] != tx.Gas() {
+			t.Fatalf("expected %s to have spent %d, got %d", user.Hex(), tx.Gas(), spent[user])
+		}
+	}
+
+	// Failed submissions resync the nonce.
+	gateway.fail = true
+	if _, _, err = r.Relay(ctx, txs[2]); err == nil {
+		t.Fatalf("expected the submission to fail")
+	}
+	if _, _, err = r.Relay(ctx, txs[2]); err != nil || gateway.nonces[string(txs[2].Data())] != 7 || gateway.queries != 2 {
+		t.Fatalf("expected the nonce to be queried again, got %v after %d queries: %v", gateway.nonces, gateway.queries, err)
+	}
+
+	// Transactions are forgotten after the dedup window.
+	now = now.Add(time.Minute)
+	delete(gateway.nonces, string(txs[0].Data()))
+	if _, _, err = r.Relay(ctx, txs[0]); err != nil {
+		t.Fatalf("expected the transaction to be submitted again after the window, got %v", err)
+	}
+
+	if _, _, err = r.Relay(ctx, types.NewTx(&types.LegacyTx{})); !errors.Is(err, ErrGaslessInner) {
+		t.Fatalf("expected an unsigned transaction to be rejected, got %v", err)
+	}
+}
+
+func TestGaslessRelayerConcurrent(t *testing.T) {
+	ctx := context.Background()
+	rt := newRPCTransport()
+	gateway := newGaslessGateway(t, rt, 0)
+	b, err := WrapClient(dialTransport(t, rt), nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	var spends int
+	r, err := NewGaslessRelayer(b, sdkTesting.Dave.Signer, gaslessChainContext(t, Networks[0x5afd].RuntimeID), GaslessRelayerOptions{
+		OnSpend: func(GaslessSpend) { spends++ },
+	})
+	if err != nil {
+		t.Fatalf("NewGaslessRelayer failed: %v", err)
+	}
+
+	// Every transaction is relayed by several goroutines at once.
+	const copies = 4
+	txs := relayedTxs(t, 20)
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		submitted  = make(map[common.Hash]int)
+		duplicates int
+	)
+	for _, tx := range txs {
+		for i := 0; i < copies; i++ {
+			wg.Add(1)
+			go func(tx *types.Transaction) {
+				defer wg.Done()
+				_, _, err := r.Relay(ctx, tx)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					submitted[tx.Hash()]++
+				case errors.Is(err, ErrGaslessDuplicate):
+					duplicates++
+				default:
+					t.Errorf("Relay failed: %v", err)
+				}
+			}(tx)
+		}
+	}
+	wg.Wait()
+
+	if len(submitted) != len(txs) || duplicates != len(txs)*(copies-1) || spends != len(txs) {
+		t.Fatalf("expected %d submissions and %d duplicates, got %d, %d and %d spends", len(txs), len(txs)*(copies-1), len(submitted), duplicates, spends)
+	}
+	for hash, n := range submitted {
+		if n != 1 {
+			t.Fatalf("transaction %s relayed %d times", hash.Hex(), n)
+		}
+	}
+	used := make(map[uint64]bool)
+	for _, nonce := range gateway.nonces {
+		used[nonce] = true
+	}
+	for nonce := uint64(0); nonce < uint64(len(txs)); nonce++ {
+		if !used[nonce] {
+			t.Fatalf("expected nonces 0 to %d to be used once each, got %v", len(txs)-1, gateway.nonces)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/accounts"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// GaslessFailedError is returned by WaitGasless for gasless transactions
+// that were included but whose call failed.
+type GaslessFailedError struct {
+	Receipt *types.Receipt
+	// Diagnosis tells why the call failed. It is nil if the failure could
+	// not be diagnosed, see DiagnoseErr.
+	Diagnosis   *TxDiagnosis
+	DiagnoseErr error
+}
+
+func (e *GaslessFailedError) Error() string {
+	switch {
+	case e.Diagnosis == nil:
+		return fmt.Sprintf("gasless transaction %s failed", e.Receipt.TxHash.Hex())
+	case e.Diagnosis.RevertReason != "":
+		return fmt.Sprintf("gasless transaction %s failed: %s: %s", e.Receipt.TxHash.Hex(), e.Diagnosis.Kind, e.Diagnosis.RevertReason)
+	default:
+		return fmt.Sprintf("gasless transaction %s failed: %s", e.Receipt.TxHash.Hex(), e.Diagnosis.Kind)
+	}
+}
+
+// SubmitGasless submits a gasless transaction built by WrapGasless or
+// EncodeGasless with the gateway's CapabilityGaslessSubmit method, and
+// returns the hash of the outer transaction, the runtime transaction the
+// gateway finds the receipt by. The inner transaction is never included, so
+// its hash is not found on chain.
+//
+// Gateways without the method fail with ErrCapabilityUnsupported.
+func (b *WrappedBackend) SubmitGasless(ctx context.Context, wrapped []byte) (outerHash common.Hash, err error) {
+	if _, _, err = DecodeGasless(wrapped); err != nil {
+		return common.Hash{}, err
+	}
+	var ut sdkTypes.UnverifiedTransaction
+	if err = cbor.Unmarshal(wrapped, &ut); err != nil {
+		return common.Hash{}, fmt.Errorf("%w: %v", ErrGaslessOuter, err)
+	}
+	outerHash = common.Hash(ut.Hash())
+	if b.client == nil {
+		return outerHash, fmt.Errorf("cannot submit gasless transaction: %w: not created from an ethclient.Client", sapphire.ErrUnsupportedBackend)
+	}
+	if err = b.caps.require(CapabilityGaslessSubmit); err != nil {
+		return outerHash, err
+	}
+
+	reported, err := invoke(ctx, b.mw, rpcSend, func(ctx context.Context) (reported common.Hash, err error) {
+		err = b.client.Client().CallContext(ctx, &reported, CapabilityGaslessSubmit, hexutil.Bytes(wrapped))
+		return reported, err
+	})
+	b.debugRequest(callContext{op: CapabilityGaslessSubmit}, nil, wrapped, nil, err)
+	if err = b.caps.observe(CapabilityGaslessSubmit, err); err != nil {
+		return outerHash, err
+	}
+	if reported != outerHash {
+		return outerHash, fmt.Errorf("%w: gateway reported outer transaction hash %s, expected %s", ErrGaslessOuter, reported.Hex(), outerHash.Hex())
+	}
+	return outerHash, nil
+}
+
+// WaitGasless waits for the gasless transaction with outer hash outerHash,
+// as returned by SubmitGasless, to be included and returns its receipt.
+//
+// Transient gateway errors while waiting are tolerated as by WaitDeployed.
+// If the call failed, the error is a *GaslessFailedError diagnosing why, see
+// DiagnoseFailedTx.
+func (b *WrappedBackend) WaitGasless(ctx context.Context, outerHash common.Hash) (*types.Receipt, error) {
+	receipt, err := b.waitReceipt(ctx, outerHash)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		d, err := b.DiagnoseFailedTx(ctx, outerHash)
+		return receipt, &GaslessFailedError{Receipt: receipt, Diagnosis: d, DiagnoseErr: err}
+	}
+	return receipt, nil
+}
+
+// RuntimeNonce returns the nonce of the runtime account addr, e.g. that of a
+// FeePayer, with a query subcall.
+func (b *WrappedBackend) RuntimeNonce(ctx context.Context, addr sdkTypes.Address) (uint64, error) {
+	data, err := subcall(ctx, b.backend, "accounts.Nonce", &accounts.NonceQuery{Address: addr})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query nonce of %s: %w", addr, err)
+	}
+	var nonce uint64
+	if err = cbor.Unmarshal(data, &nonce); err != nil {
+		return 0, fmt.Errorf("failed to query nonce of %s: %w", addr, err)
+	}
+	return nonce, nil
+}
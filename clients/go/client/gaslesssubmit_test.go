@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/accounts"
+	sdkTesting "github.com/oasisprotocol/oasis-sdk/client-sdk/go/testing"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+func TestSubmitGasless(t *testing.T) {
+	ctx := context.Background()
+	inner := gaslessInnerTxs(t)["1559"]
+	payer := NewFeePayer(sdkTesting.Alice.Signer, gaslessChainContext(t, Networks[0x5afd].RuntimeID), 0)
+	wrapped, err := WrapGasless(inner, payer, GaslessFee{})
+	if err != nil {
+		t.Fatalf("WrapGasless failed: %v", err)
+	}
+	var ut sdkTypes.UnverifiedTransaction
+	_ = cbor.Unmarshal(wrapped, &ut)
+	expectedOuter := common.Hash(ut.Hash())
+
+	rt := newRPCTransport()
+	reported := expectedOuter
+	rt.handle(CapabilityGaslessSubmit, func(params []json.RawMessage) (interface{}, error) {
+		return reported, nil
+	})
+	b, err := WrapClient(dialTransport(t, rt), nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	outerHash, err := b.SubmitGasless(ctx, wrapped)
+	if err != nil || outerHash != expectedOuter {
+		t.Fatalf("SubmitGasless returned %s: %v", outerHash.Hex(), err)
+	}
+	var sent hexutil.Bytes
+	if reqs := rt.recorded(CapabilityGaslessSubmit); len(reqs) != 1 || json.Unmarshal(reqs[0].Params[0], &sent) != nil || string(sent) != string(wrapped) {
+		t.Fatalf("unexpected submissions %+v", reqs)
+	}
+
+	reported = common.Hash{1}
+	if _, err = b.SubmitGasless(ctx, wrapped); err == nil {
+		t.Fatalf("expected a mismatching outer hash to be reported")
+	}
+	if _, err = b.SubmitGasless(ctx, []byte{0xff}); !errors.Is(err, ErrGaslessOuter) {
+		t.Fatalf("expected a malformed transaction to be rejected, got %v", err)
+	}
+
+	rt.handle(CapabilityGaslessSubmit, nil)
+	for i := 0; i < 2; i++ {
+		if _, err = b.SubmitGasless(ctx, wrapped); !errors.Is(err, ErrCapabilityUnsupported{CapabilityGaslessSubmit}) {
+			t.Fatalf("expected ErrCapabilityUnsupported{%s}, got %v", CapabilityGaslessSubmit, err)
+		}
+	}
+	if n := len(rt.recorded(CapabilityGaslessSubmit)); n != 3 {
+		t.Fatalf("expected the missing method to only be tried once, got %d submissions", n)
+	}
+}
+
+func TestWaitGasless(t *testing.T) {
+	fastDeployPolling(t)
+	ctx := context.Background()
+	outerHash := common.HexToHash("0x0a11ce")
+	status := types.ReceiptStatusSuccessful
+	rt := newRPCTransport()
+	rt.handle("eth_getTransactionReceipt", func(params []json.RawMessage) (interface{}, error) {
+		var hash common.Hash
+		if err := json.Unmarshal(params[0], &hash); err != nil || hash != outerHash {
+			t.Errorf("expected a receipt request for %s, got %s", outerHash.Hex(), params[0])
+		}
+		return &types.Receipt{Status: status, TxHash: outerHash, BlockNumber: big.NewInt(100), Logs: []*types.Log{}}, nil
+	})
+	b, err := WrapClient(dialTransport(t, rt), nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	if receipt, err := b.WaitGasless(ctx, outerHash); err != nil || receipt.TxHash != outerHash {
+		t.Fatalf("WaitGasless failed: %v", err)
+	}
+
+	status = types.ReceiptStatusFailed
+	receipt, err := b.WaitGasless(ctx, outerHash)
+	var failed *GaslessFailedError
+	if !errors.As(err, &failed) || receipt == nil || failed.Receipt != receipt {
+		t.Fatalf("expected a GaslessFailedError, got %v", err)
+	}
+}
+
+func TestRuntimeNonce(t *testing.T) {
+	ctx := context.Background()
+	rt := newRPCTransport()
+	rt.handle("eth_call", func(params []json.RawMessage) (interface{}, error) {
+		var msg struct {
+			Input hexutil.Bytes `json:"input"`
+		}
+		_ = json.Unmarshal(params[0], &msg)
+		stringType, _ := abi.NewType("string", "", nil)
+		bytesType, _ := abi.NewType("bytes", "", nil)
+		uint64Type, _ := abi.NewType("uint64", "", nil)
+		values, err := abi.Arguments{{Type: stringType}, {Type: bytesType}}.Unpack(msg.Input)
+		var query accounts.NonceQuery
+		if err != nil || values[0].(string) != "accounts.Nonce" || cbor.Unmarshal(values[1].([]byte), &query) != nil || !query.Address.Equal(sdkTesting.Alice.Address) {
+			t.Fatalf("unexpected subcall %v: %v", values, err)
+		}
+		out, _ := abi.Arguments{{Type: uint64Type}, {Type: bytesType}}.Pack(uint64(0), cbor.Marshal(uint64(12)))
+		return hexutil.Bytes(out), nil
+	})
+	b, err := WrapClient(dialTransport(t, rt), nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	if nonce, err := b.RuntimeNonce(ctx, sdkTesting.Alice.Address); err != nil || nonce != 12 {
+		t.Fatalf("RuntimeNonce returned %d: %v", nonce, err)
+	}
+}
+
+// TestGaslessLocalnet has a relayer pay for a call an account without ROSE
+// signed.
+func TestGaslessLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	chainContext := localnet.ChainContext(t)
+	network := Networks[0x5afd]
+	client := localnet.Dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	user, _ := crypto.GenerateKey()
+	b, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(sapphire.NewPrivateKeySigner(user))))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	sigContext, err := GaslessChainContext(network.RuntimeID, chainContext)
+	if err != nil {
+		t.Fatalf("GaslessChainContext failed: %v", err)
+	}
+	nonce, err := b.RuntimeNonce(ctx, sdkTesting.Alice.Address)
+	if err != nil {
+		t.Fatalf("RuntimeNonce failed: %v", err)
+	}
+	payer := NewFeePayer(sdkTesting.Alice.Signer, sigContext, nonce)
+
+	from := crypto.PubkeyToAddress(user.PublicKey)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	prepared, err := b.PrepareTransaction(ctx, from, types.NewTx(&types.LegacyTx{Gas: 100_000, GasPrice: big.NewInt(0), To: &to, Data: []byte{1}}))
+	if err != nil {
+		t.Fatalf("PrepareTransaction failed: %v", err)
+	}
+	wrapped, err := WrapGasless(prepared, payer, GaslessFee{Amount: new(big.Int).Mul(big.NewInt(100_000), big.NewInt(sapphire.DefaultGasPrice))})
+	if err != nil {
+		t.Fatalf("WrapGasless failed: %v", err)
+	}
+	outerHash, err := b.SubmitGasless(ctx, wrapped)
+	if err != nil {
+		t.Fatalf("SubmitGasless failed: %v", err)
+	}
+	if _, err = b.WaitGasless(ctx, outerHash); err != nil {
+		t.Fatalf("WaitGasless failed: %v", err)
+	}
+	if balance, err := b.BalanceAt(ctx, from, nil); err != nil || balance.Sign() != 0 {
+		t.Fatalf("expected the user to have paid nothing, has %v: %v", balance, err)
+	}
+}
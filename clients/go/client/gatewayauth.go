@@ -0,0 +1,187 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HeaderProvider returns headers to send with a request to the gateway, see
+// WithHeaderProvider.
+type HeaderProvider func(ctx context.Context) (http.Header, error)
+
+const (
+	// redactedText replaces credentials in error messages.
+	redactedText = "[REDACTED]"
+	// minRedactedLen is the length below which values aren't redacted, as
+	// they can't be credentials and would mangle the messages.
+	minRedactedLen = 4
+	// maxRecentSecrets bounds the values of a header provider that are
+	// remembered for redaction. Tokens older than that have expired.
+	maxRecentSecrets = 32
+)
+
+// redactor replaces the credentials of a gateway connection in error
+// messages. The nil redactor redacts nothing.
+type redactor struct {
+	mu sync.Mutex
+	// static are the values of WithHeader and the URL, recent the most
+	// recent values of the header provider, oldest first.
+	static map[string]string
+	recent []string
+}
+
+func newRedactor() *redactor {
+	return &redactor{static: make(map[string]string)}
+}
+
+// add redacts secrets for good.
+func (r *redactor) add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range secrets {
+		if len(s) >= minRedactedLen {
+			r.static[s] = redactedText
+		}
+	}
+}
+
+// addRecent redacts secret until maxRecentSecrets newer ones were added.
+func (r *redactor) addRecent(secret string) {
+	if len(secret) < minRedactedLen {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.recent {
+		if s == secret {
+			return
+		}
+	}
+	if len(r.recent) == maxRecentSecrets {
+		r.recent = append(r.recent[:0], r.recent[1:]...)
+	}
+	r.recent = append(r.recent, secret)
+}
+
+// addURL redacts the user info, path and query of rawurl, which some
+// gateways take API keys in, leaving the scheme and host.
+func (r *redactor) addURL(rawurl string) {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return
+	}
+	if u.User == nil && strings.Trim(u.Path, "/") == "" && u.RawQuery == "" {
+		return
+	}
+	redacted := u.Scheme + "://" + u.Host + "/" + redactedText
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// net/http reports URLs with the password masked.
+	for _, s := range []string{rawurl, u.String(), u.Redacted()} {
+		r.static[s] = redacted
+	}
+	// Gateways may echo them in their responses.
+	parts := []string{u.Path, u.RawQuery}
+	if pass, ok := u.User.Password(); ok {
+		parts = append(parts, pass)
+	}
+	for _, values := range u.Query() {
+		parts = append(parts, values...)
+	}
+	for _, s := range parts {
+		if len(strings.Trim(s, "/")) >= minRedactedLen {
+			r.static[s] = redactedText
+		}
+	}
+}
+
+// string returns s with the secrets replaced, longest first so that secrets
+// containing others are replaced whole.
+func (r *redactor) string(s string) string {
+	if r == nil {
+		return s
+	}
+	type replacement struct{ secret, with string }
+	r.mu.Lock()
+	replacements := make([]replacement, 0, len(r.static)+len(r.recent))
+	for secret, with := range r.static {
+		replacements = append(replacements, replacement{secret, with})
+	}
+	for _, secret := range r.recent {
+		replacements = append(replacements, replacement{secret, redactedText})
+	}
+	r.mu.Unlock()
+	sort.Slice(replacements, func(i, j int) bool { return len(replacements[i].secret) > len(replacements[j].secret) })
+
+	for _, rep := range replacements {
+		s = strings.ReplaceAll(s, rep.secret, rep.with)
+	}
+	return s
+}
+
+// err returns err with a redacted message, unwrapping to err, or err itself
+// if it holds no secrets.
+func (r *redactor) err(err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+	msg := err.Error()
+	if redacted := r.string(msg); redacted != msg {
+		return &redactedError{msg: redacted, err: err}
+	}
+	return err
+}
+
+// redactedError is an error whose message had credentials removed.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
+
+// headerTransport adds the headers of a HeaderProvider to every request.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers HeaderProvider
+	redact  *redactor
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request.
+	req = req.Clone(req.Context())
+	if err := applyHeaders(req.Context(), req.Header, t.headers, t.redact); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// applyHeaders sets the headers of provider on h and redacts their values.
+func applyHeaders(ctx context.Context, h http.Header, provider HeaderProvider, redact *redactor) error {
+	headers, err := provider(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get gateway headers: %w", err)
+	}
+	for key, values := range headers {
+		h.Del(key)
+		for _, value := range values {
+			h.Add(key, value)
+			redact.addRecent(value)
+		}
+	}
+	return nil
+}
+
+func isWebsocketURL(rawurl string) bool {
+	return strings.HasPrefix(rawurl, "ws://") || strings.HasPrefix(rawurl, "wss://")
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/mockgateway"
+)
+
+type tokenContextKey struct{}
+
+// tokenProvider returns a provider of a new access token for every request,
+// and the number of tokens handed out.
+func tokenProvider(prefix string) (HeaderProvider, *atomic.Int64) {
+	var issued atomic.Int64
+	return func(ctx context.Context) (http.Header, error) {
+		n := issued.Add(1)
+		h := http.Header{}
+		h.Set("X-Access-Token", fmt.Sprintf("%s-%d", prefix, n))
+		if v, ok := ctx.Value(tokenContextKey{}).(string); ok {
+			h.Set("X-Request-Tag", v)
+		}
+		return h, nil
+	}, &issued
+}
+
+func TestGatewayAuthHeaders(t *testing.T) {
+	gw := mockgateway.New(t)
+	provider, issued := tokenProvider("token")
+	b, err := Dial(gw.URL, nil,
+		WithHeader("X-Api-Key", "static-api-key"),
+		WithBasicAuth("alice", "hunter22"),
+		WithHeaderProvider(provider),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer b.Close()
+	ctx := context.WithValue(context.Background(), tokenContextKey{}, "gas-price")
+	if _, err = b.SuggestGasPrice(ctx); err != nil {
+		t.Fatalf("SuggestGasPrice failed: %v", err)
+	}
+
+	reqs := gw.Requests("")
+	if int64(len(reqs)) != issued.Load() {
+		t.Fatalf("expected a token per request, got %d tokens for %d requests", issued.Load(), len(reqs))
+	}
+	tokens := make(map[string]bool)
+	for _, req := range reqs {
+		if got := req.Header.Get("X-Api-Key"); got != "static-api-key" {
+			t.Fatalf("%s: unexpected API key %q", req.Method, got)
+		}
+		user, pass, ok := (&http.Request{Header: req.Header}).BasicAuth()
+		if !ok || user != "alice" || pass != "hunter22" {
+			t.Fatalf("%s: unexpected basic auth %q:%q", req.Method, user, pass)
+		}
+		token := req.Header.Get("X-Access-Token")
+		if token == "" || tokens[token] {
+			t.Fatalf("%s: expected a fresh token, got %q", req.Method, token)
+		}
+		tokens[token] = true
+	}
+	// The provider is passed the request's context.
+	if got := reqs[len(reqs)-1].Header.Get("X-Request-Tag"); reqs[len(reqs)-1].Method != "eth_gasPrice" || got != "gas-price" {
+		t.Fatalf("expected the gas price request to carry its context, got %s with %q", reqs[len(reqs)-1].Method, got)
+	}
+
+	failing := func(context.Context) (http.Header, error) { return nil, errors.New("token service down") }
+	if _, err = Dial(gw.URL, nil, WithHeaderProvider(failing)); err == nil || !strings.Contains(err.Error(), "token service down") {
+		t.Fatalf("expected the provider error, got %v", err)
+	}
+}
+
+// rejectingGateway serves gw to requests whose API key is valid and rejects
+// the others with a 401 response echoing the credentials and path.
+func rejectingGateway(t *testing.T, gw *mockgateway.Gateway, valid *atomic.Bool) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if valid.Load() {
+			gw.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, fmt.Sprintf("invalid credentials %q %q %q for %s?%s",
+			r.Header.Get("X-Api-Key"), r.Header.Get("Authorization"), r.Header.Get("X-Access-Token"), r.URL.Path, r.URL.RawQuery),
+			http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func assertRedacted(t *testing.T, what, msg string, secrets ...string) {
+	t.Helper()
+	for _, secret := range secrets {
+		if strings.Contains(msg, secret) {
+			t.Fatalf("%s leaks %q: %s", what, secret, msg)
+		}
+	}
+	if !strings.Contains(msg, redactedText) {
+		t.Fatalf("%s is not redacted: %s", what, msg)
+	}
+}
+
+func TestGatewayAuthRedaction(t *testing.T) {
+	gw := mockgateway.New(t)
+	var valid atomic.Bool
+	srv := rejectingGateway(t, gw, &valid)
+	provider, _ := tokenProvider("short-lived")
+	secrets := []string{"static-api-key", "hunter22", "short-lived-", "url-api-key"}
+	opts := []Option{
+		WithHeader("X-Api-Key", "static-api-key"),
+		WithBasicAuth("alice", "hunter22"),
+		WithHeaderProvider(provider),
+	}
+
+	// Failing to dial.
+	_, err := Dial(srv.URL+"/v1/url-api-key?key=url-api-key", nil, opts...)
+	var httpErr rpc.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected an HTTP 401 error, got %v", err)
+	}
+	assertRedacted(t, "dial error", err.Error(), secrets...)
+
+	// Failing requests once the token is rejected.
+	valid.Store(true)
+	rec := &debugRecorder{}
+	b, err := Dial(srv.URL+"/v1/url-api-key", nil, append(opts, WithDebugHook(rec.hook))...)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer b.Close()
+	valid.Store(false)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	_, err = b.CallContract(context.Background(), ethereum.CallMsg{To: &to, Data: []byte{1}}, nil)
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expected an HTTP error, got %v", err)
+	}
+	assertRedacted(t, "call error", err.Error(), secrets...)
+	events := rec.recorded()
+	if ev := events[len(events)-1]; ev.Err == nil {
+		t.Fatalf("expected the debug event to report the error")
+	} else {
+		assertRedacted(t, "debug event", ev.Err.Error(), secrets...)
+	}
+}
+
+func TestGatewayAuthWebsocket(t *testing.T) {
+	var handshake http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handshake = r.Header.Clone()
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	provider, _ := tokenProvider("ws-token")
+	_, err := Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/url-api-key", nil,
+		WithHeader("X-Api-Key", "static-api-key"),
+		WithHeaderProvider(provider),
+	)
+	if err == nil {
+		t.Fatalf("expected the handshake to fail")
+	}
+	if handshake.Get("X-Api-Key") != "static-api-key" || handshake.Get("X-Access-Token") != "ws-token-1" {
+		t.Fatalf("unexpected handshake headers %v", handshake)
+	}
+	if strings.Contains(err.Error(), "url-api-key") {
+		t.Fatalf("dial error leaks the URL: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+// HistoricalStateError is returned when a call at a historical block fails
+// because the gateway no longer has the state of that block, e.g. because
+// it was pruned.
+type HistoricalStateError struct {
+	// BlockNumber is the block whose state was requested.
+	BlockNumber *big.Int
+	// Err is the error returned by the gateway.
+	Err error
+}
+
+func (e *HistoricalStateError) Error() string {
+	return fmt.Sprintf("state at block %s is not available: %v", e.BlockNumber, e.Err)
+}
+
+func (e *HistoricalStateError) Unwrap() error {
+	return e.Err
+}
+
+// prunedStateMessages are fragments of the errors gateways return for
+// requests outside of their pruning window.
+var prunedStateMessages = []string{
+	"missing trie node",
+	"header not found",
+	"historical state",
+	"state is not available",
+	"pruned",
+	"version not found",
+}
+
+// historicalStateError wraps err in a HistoricalStateError if it was caused
+// by the state at blockNumber being unavailable.
+func historicalStateError(blockNumber *big.Int, err error) error {
+	if err == nil || blockNumber == nil {
+		return err
+	}
+	if errors.Is(err, ethereum.NotFound) {
+		return &HistoricalStateError{BlockNumber: blockNumber, Err: err}
+	}
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range prunedStateMessages {
+		if strings.Contains(msg, fragment) {
+			return &HistoricalStateError{BlockNumber: blockNumber, Err: err}
+		}
+	}
+	return err
+}
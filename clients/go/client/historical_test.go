@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+func TestSignedCallHistoricalBlock(t *testing.T) {
+	mock := newMockBackend()
+	mock.callResult = cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(TestData)})
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	mock.nonces[from] = 7
+	mock.history = map[uint64]map[common.Address]uint64{50: {from: 3}}
+	b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	if _, err := b.CallContract(context.Background(), ethereum.CallMsg{From: from, To: &to, Data: TestData}, big.NewInt(50)); err != nil {
+		t.Fatalf("CallContract failed: %v", err)
+	}
+	var pack evm.SignedCallDataPack
+	if err := cbor.Unmarshal(mock.receivedCalls()[0].Data, &pack); err != nil {
+		t.Fatalf("failed to decode signed call: %v", err)
+	}
+	if pack.Leash.BlockNumber != 49 {
+		t.Fatalf("expected leash on block 49, got %d", pack.Leash.BlockNumber)
+	}
+	if pack.Leash.Nonce != 3 {
+		t.Fatalf("expected leash nonce at block 50 to be 3, got %d", pack.Leash.Nonce)
+	}
+}
+
+func TestSignedCallPrunedBlock(t *testing.T) {
+	mock := newMockBackend()
+	mock.hook = func(_ context.Context, method string) error {
+		if method == "eth_getBlockByNumber" {
+			return errors.New("missing trie node 1b2c3d (path ) state 0x1b2c3d is not available")
+		}
+		return nil
+	}
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	_, err := b.CallContract(context.Background(), ethereum.CallMsg{From: from, To: &to, Data: TestData}, big.NewInt(1))
+	var stateErr *HistoricalStateError
+	if !errors.As(err, &stateErr) {
+		t.Fatalf("expected HistoricalStateError, got %v", err)
+	}
+	if stateErr.BlockNumber.Uint64() != 1 {
+		t.Fatalf("unexpected block number %s", stateErr.BlockNumber)
+	}
+}
+
+// perSenderStorageCode stores the first calldata word in the storage slot of
+// the caller, and returns it when called without data.
+var perSenderStorageCode = common.FromHex("601680600b6000396000f3" + "3615600b576000353355005b335460005260206000f3")
+
+func TestSignedCallHistoricalBlockLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	client := localnet.Dial(t)
+	b, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(sapphire.NewPrivateKeySigner(key))))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	send := func(to *common.Address, data []byte) *types.Receipt {
+		opts := b.Transactor(from)
+		nonce, err := b.PendingNonceAt(ctx, from)
+		if err != nil {
+			t.Fatalf("failed to fetch nonce: %v", err)
+		}
+		tx := types.NewTx(&types.LegacyTx{Nonce: nonce, To: to, Gas: 100_000, GasPrice: opts.GasPrice, Data: data})
+		signedTx, err := opts.Signer(from, tx)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		if err = b.SendTransaction(ctx, signedTx); err != nil {
+			t.Fatalf("failed to send transaction: %v", err)
+		}
+		receipt, err := bind.WaitMined(ctx, b, signedTx)
+		if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+			t.Fatalf("transaction failed: %v", err)
+		}
+		return receipt
+	}
+
+	contract := send(nil, perSenderStorageCode).ContractAddress
+	oldValue := common.BigToHash(big.NewInt(1))
+	oldBlock := send(&contract, oldValue[:]).BlockNumber
+	newValue := common.BigToHash(big.NewInt(2))
+	send(&contract, newValue[:])
+	send(&from, nil)
+
+	call := ethereum.CallMsg{From: from, To: &contract}
+	res, err := b.CallContract(ctx, call, oldBlock)
+	if err != nil {
+		t.Fatalf("historical CallContract failed: %v", err)
+	}
+	if common.BytesToHash(res) != oldValue {
+		t.Fatalf("expected old value %x at block %s, got %x", oldValue, oldBlock, res)
+	}
+	res, err = b.CallContract(ctx, call, nil)
+	if err != nil {
+		t.Fatalf("CallContract failed: %v", err)
+	}
+	if common.BytesToHash(res) != newValue {
+		t.Fatalf("expected new value %x, got %x", newValue, res)
+	}
+}
@@ -0,0 +1,256 @@
+package client
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/oasisprotocol/curve25519-voi/primitives/ed25519"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+
+	oasisCommon "github.com/oasisprotocol/oasis-core/go/common"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/core"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// Sources of the runtime calldata public key, see WrappedBackend.KeySource.
+const (
+	// KeySourceRPC is the gateway's oasis_callDataPublicKey method.
+	KeySourceRPC = "oasis_callDataPublicKey"
+	// KeySourceSubcall is an eth_call to the subcall precompile with
+	// core.CallDataPublicKey, for gateways without oasis_callDataPublicKey.
+	KeySourceSubcall = "core.CallDataPublicKey"
+)
+
+// GetRuntimePublicKey fetches the runtime calldata public key from the default Sapphire gateway.
+func GetRuntimePublicKey(c *ethclient.Client) (*x25519.PublicKey, uint64, error) {
+	return GetRuntimePublicKeyContext(context.Background(), c)
+}
+
+// GetRuntimePublicKeyContext is like GetRuntimePublicKey but aborts when ctx is done.
+func GetRuntimePublicKeyContext(ctx context.Context, c *ethclient.Client) (*x25519.PublicKey, uint64, error) {
+	pk, epoch, _, _, err := getRuntimePublicKey(ctx, c, nil)
+	return pk, epoch, keyFetchError(err)
+}
+
+// getRuntimePublicKey is like GetRuntimePublicKeyContext but also returns the
+// source of the key and the raw response, and checks the key's signature if
+// km is set. Gateways without oasis_callDataPublicKey are asked with the
+// subcall precompile instead.
+func getRuntimePublicKey(ctx context.Context, c *ethclient.Client, km *keyManager) (*x25519.PublicKey, uint64, string, json.RawMessage, error) {
+	var raw json.RawMessage
+	if err := c.Client().CallContext(ctx, &raw, "oasis_callDataPublicKey"); err != nil {
+		if isMethodNotFound(err) {
+			return getSubcallPublicKey(ctx, c, km, err)
+		}
+		return nil, 0, KeySourceRPC, nil, fmt.Errorf("%w: invalid response: %w", sapphire.ErrKeyFetchFailed, err)
+	}
+
+	var pubKey sapphire.CallDataPublicKey
+	if err := json.Unmarshal(raw, &pubKey); err != nil {
+		return nil, 0, KeySourceRPC, raw, fmt.Errorf("%w: invalid response: %w", sapphire.ErrKeyFetchFailed, err)
+	}
+	if err := verifyKey(&pubKey, km); err != nil {
+		return nil, 0, KeySourceRPC, raw, err
+	}
+
+	return (*x25519.PublicKey)(pubKey.PublicKey), pubKey.Epoch, KeySourceRPC, raw, nil
+}
+
+var (
+	subcallPrecompileAddress = common.HexToAddress("0x0100000000000000000000000000000000000103")
+
+	errInvalidSubcall = errors.New("invalid subcall response")
+)
+
+type subcallKeySource struct {
+	c bind.ContractCaller
+}
+
+// NewSubcallKeySource returns a RuntimeKeySource that fetches the key with an
+// eth_call to the subcall precompile on every use, for gateways that don't
+// serve oasis_callDataPublicKey.
+func NewSubcallKeySource(c bind.ContractCaller) RuntimeKeySource {
+	return subcallKeySource{c}
+}
+
+func (s subcallKeySource) RuntimePublicKey(ctx context.Context) (*x25519.PublicKey, uint64, error) {
+	pubKey, err := fetchSubcallPublicKey(ctx, s.c, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return (*x25519.PublicKey)(pubKey.PublicKey), pubKey.Epoch, nil
+}
+
+// fetchSubcallPublicKey fetches and verifies the key with the subcall
+// precompile.
+func fetchSubcallPublicKey(ctx context.Context, c bind.ContractCaller, km *keyManager) (*sapphire.CallDataPublicKey, error) {
+	data, err := subcall(ctx, c, KeySourceSubcall, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res core.CallDataPublicKeyResponse
+	if err = cbor.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidSubcall, err)
+	}
+	return newCallDataPublicKey(&res, km)
+}
+
+// newCallDataPublicKey verifies the response of the core.CallDataPublicKey
+// query and returns its key.
+func newCallDataPublicKey(res *core.CallDataPublicKeyResponse, km *keyManager) (*sapphire.CallDataPublicKey, error) {
+	pubKey := &sapphire.CallDataPublicKey{
+		PublicKey: res.PublicKey.PublicKey[:],
+		Checksum:  res.PublicKey.Checksum,
+		Signature: res.PublicKey.Signature[:],
+		Epoch:     res.Epoch,
+	}
+	if err := verifyKey(pubKey, km); err != nil {
+		return nil, err
+	}
+	return pubKey, nil
+}
+
+// subcall makes an unencrypted eth_call to the subcall precompile and returns
+// the CBOR-encoded response of method. The precompiles package has the full
+// API, which this package can't import.
+func subcall(ctx context.Context, c bind.ContractCaller, method string, body interface{}) ([]byte, error) {
+	stringType, _ := abi.NewType("string", "", nil)
+	bytesType, _ := abi.NewType("bytes", "", nil)
+	uint64Type, _ := abi.NewType("uint64", "", nil)
+	input, err := abi.Arguments{{Type: stringType}, {Type: bytesType}}.Pack(method, cbor.Marshal(body))
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.CallContract(ctx, ethereum.CallMsg{To: &subcallPrecompileAddress, Data: input}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call subcall precompile: %w", err)
+	}
+	values, err := abi.Arguments{{Type: uint64Type}, {Type: bytesType}}.Unpack(out)
+	if err != nil {
+		// E.g. an empty account on chains other than Sapphire.
+		return nil, fmt.Errorf("%w: %v", errInvalidSubcall, err)
+	}
+	status, data := values[0].(uint64), values[1].([]byte)
+	if status != 0 {
+		return nil, &sapphire.CallFailedError{Module: string(data), Code: uint32(status)}
+	}
+	return data, nil
+}
+
+// getSubcallPublicKey is getRuntimePublicKey's fallback for gateways whose
+// oasis_callDataPublicKey failed with rpcErr, method not found. The
+// capability is only reported missing if the fallback isn't available
+// either.
+func getSubcallPublicKey(ctx context.Context, c bind.ContractCaller, km *keyManager, rpcErr error) (*x25519.PublicKey, uint64, string, json.RawMessage, error) {
+	pubKey, err := fetchSubcallPublicKey(ctx, c, km)
+	if err != nil {
+		var callErr rpc.Error
+		if errors.As(err, &callErr) || errors.Is(err, errInvalidSubcall) || errors.Is(err, sapphire.ErrCallFailed) {
+			return nil, 0, KeySourceSubcall, nil, fmt.Errorf("%w: %v, and %s fallback failed: %v", ErrCapabilityUnsupported{CapabilityCallDataPublicKey}, rpcErr, KeySourceSubcall, err)
+		}
+		return nil, 0, KeySourceSubcall, nil, fmt.Errorf("%w: with %s: %w", sapphire.ErrKeyFetchFailed, KeySourceSubcall, err)
+	}
+	raw, _ := json.Marshal(pubKey)
+	return (*x25519.PublicKey)(pubKey.PublicKey), pubKey.Epoch, KeySourceSubcall, raw, nil
+}
+
+// verifyKey checks k as the key manager signs it, whichever source it came
+// from, and its signature if km is set.
+func verifyKey(k *sapphire.CallDataPublicKey, km *keyManager) error {
+	if len(k.PublicKey) != x25519.PublicKeySize {
+		return fmt.Errorf("%w: invalid public key length", sapphire.ErrKeyFetchFailed)
+	}
+	if len(k.Checksum) == 0 || len(k.Signature) == 0 {
+		return fmt.Errorf("%w: %w", sapphire.ErrKeyFetchFailed, sapphire.ErrKeyNotSigned)
+	}
+	if n := len(k.Checksum); n != 32 {
+		return fmt.Errorf("%w: invalid key manager checksum length %d", sapphire.ErrKeyFetchFailed, n)
+	}
+	if n := len(k.Signature); n != signature.SignatureSize {
+		return fmt.Errorf("%w: invalid key manager signature length %d", sapphire.ErrKeyFetchFailed, n)
+	}
+	if km != nil && !km.verify(k) {
+		return fmt.Errorf("%w: %w", sapphire.ErrKeyFetchFailed, sapphire.ErrKeySignatureInvalid)
+	}
+	return nil
+}
+
+// Domain separation of the key manager's signatures of ephemeral public keys
+// and of the runtime's calldata key pair IDs, see oasis-core's
+// keymanager::crypto::SignedPublicKey and oasis-runtime-sdk's
+// callformat::get_key_pair_id.
+const (
+	keyManagerSignatureContext = "oasis-core/keymanager: pk signature"
+	callDataKeyPairIDContext   = "oasis-runtime-sdk/private: tx"
+)
+
+// keyManager is the key manager signing the runtime calldata public keys of
+// a runtime, see WithKeyManagerKey.
+type keyManager struct {
+	runtimeID  oasisCommon.Namespace
+	signingKey signature.PublicKey
+}
+
+// verify checks the key manager's signature of k.
+func (km *keyManager) verify(k *sapphire.CallDataPublicKey) bool {
+	return ed25519.Verify(km.signingKey[:], km.digest(k), k.Signature)
+}
+
+// digest returns what the key manager signs for k:
+// SHA-512/256(context || key || checksum || runtime ID || key pair ID || epoch).
+func (km *keyManager) digest(k *sapphire.CallDataPublicKey) []byte {
+	var epoch [8]byte
+	binary.BigEndian.PutUint64(epoch[:], k.Epoch)
+	keyPairID := sha512.Sum512_256(append([]byte(callDataKeyPairIDContext), epoch[:]...))
+
+	h := sha512.New512_256()
+	for _, part := range [][]byte{[]byte(keyManagerSignatureContext), k.PublicKey, k.Checksum, km.runtimeID[:], keyPairID[:]} {
+		_, _ = h.Write(part)
+	}
+	binary.LittleEndian.PutUint64(epoch[:], k.Epoch)
+	_, _ = h.Write(epoch[:])
+	return h.Sum(nil)
+}
+
+// keyManager returns the key manager set with WithKeyManagerKey, or nil if
+// none was.
+func (b *WrappedBackend) keyManager() (*keyManager, error) {
+	if b.keyManagerKey == nil {
+		return nil, nil
+	}
+	network, ok := Networks[b.chainID.Uint64()]
+	if !ok {
+		return nil, fmt.Errorf("%w: runtime ID of chain %s unknown, see Networks", sapphire.ErrKeyFetchFailed, &b.chainID)
+	}
+	km := &keyManager{signingKey: *b.keyManagerKey}
+	raw, err := hexutil.Decode(network.RuntimeID)
+	if err == nil {
+		err = km.runtimeID.UnmarshalBinary(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid runtime ID %q: %w", sapphire.ErrKeyFetchFailed, network.RuntimeID, err)
+	}
+	return km, nil
+}
+
+// KeySource returns where the runtime calldata public key in use was fetched
+// from: KeySourceRPC or KeySourceSubcall, or "" if the backend was not
+// created from an ethclient.Client.
+func (b *WrappedBackend) KeySource() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.keySource
+}
@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/oasisprotocol/curve25519-voi/primitives/ed25519"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/core"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/mockgateway"
+)
+
+var subcallKey = common.Hex2Bytes("3046db3fa70ce605457dc47c48837ebd8bd0a26abfde5994d033e1ced68e2576")
+
+// testKeyManagerKey signs the keys served in tests as localnet's key manager.
+var testKeyManagerKey = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+// testKeyManager returns the key manager of testKeyManagerKey.
+func testKeyManager(t *testing.T) *keyManager {
+	t.Helper()
+	b := &WrappedBackend{chainID: *big.NewInt(0x5afd), keyManagerKey: &signature.PublicKey{}}
+	copy(b.keyManagerKey[:], testKeyManagerKey.Public().(ed25519.PublicKey))
+	km, err := b.keyManager()
+	if err != nil {
+		t.Fatalf("failed to get key manager: %v", err)
+	}
+	return km
+}
+
+// signTestKey signs k with testKeyManagerKey, with a zero checksum unless k
+// has one.
+func signTestKey(k sapphire.CallDataPublicKey) sapphire.CallDataPublicKey {
+	if k.Checksum == nil {
+		k.Checksum = make([]byte, 32)
+	}
+	km := &keyManager{}
+	_ = km.runtimeID.UnmarshalBinary(hexutil.MustDecode(Networks[0x5afd].RuntimeID))
+	k.Signature = ed25519.Sign(testKeyManagerKey, km.digest(&k))
+	return k
+}
+
+// handleSubcallKey makes rt reject oasis_callDataPublicKey and serve the key
+// with the subcall precompile, answering with status and, if the status is
+// 0, the key, or else the module name.
+func handleSubcallKey(t *testing.T, rt *rpcTransport, status uint64) {
+	rt.handle("oasis_callDataPublicKey", nil)
+	rt.handle("eth_call", func(params []json.RawMessage) (interface{}, error) {
+		var msg struct {
+			To    common.Address `json:"to"`
+			Input hexutil.Bytes  `json:"input"`
+		}
+		if err := json.Unmarshal(params[0], &msg); err != nil || msg.To != subcallPrecompileAddress {
+			t.Fatalf("unexpected eth_call %s: %v", params[0], err)
+		}
+		stringType, _ := abi.NewType("string", "", nil)
+		bytesType, _ := abi.NewType("bytes", "", nil)
+		uint64Type, _ := abi.NewType("uint64", "", nil)
+		values, err := abi.Arguments{{Type: stringType}, {Type: bytesType}}.Unpack(msg.Input)
+		if err != nil || values[0].(string) != KeySourceSubcall {
+			t.Fatalf("unexpected subcall %v: %v", values, err)
+		}
+		signed := signTestKey(sapphire.CallDataPublicKey{PublicKey: subcallKey, Epoch: 43})
+		var res core.CallDataPublicKeyResponse
+		copy(res.PublicKey.PublicKey[:], signed.PublicKey)
+		copy(res.PublicKey.Signature[:], signed.Signature)
+		res.PublicKey.Checksum = signed.Checksum
+		res.Epoch = signed.Epoch
+		data := cbor.Marshal(res)
+		if status != 0 {
+			data = []byte("core")
+		}
+		out, _ := abi.Arguments{{Type: uint64Type}, {Type: bytesType}}.Pack(status, data)
+		return hexutil.Bytes(out), nil
+	})
+}
+
+func TestSubcallKeySourceFallback(t *testing.T) {
+	ctx := context.Background()
+
+	rt := newRPCTransport()
+	handleSubcallKey(t, rt, 0)
+	rec := &debugRecorder{}
+	b, err := WrapClient(dialTransport(t, rt), nil, WithDebugHook(rec.hook))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	if b.KeySource() != KeySourceSubcall {
+		t.Fatalf("expected key from %s, got %q", KeySourceSubcall, b.KeySource())
+	}
+	if len(rt.recorded("oasis_callDataPublicKey")) != 1 || len(rt.recorded("eth_call")) != 1 {
+		t.Fatalf("expected the RPC method to be tried before the subcall")
+	}
+	events := rec.recorded()
+	if len(events) != 1 || events[0].Method != KeySourceSubcall || events[0].Err != nil {
+		t.Fatalf("unexpected debug events %+v", events)
+	}
+	caps, err := b.ProbeCapabilities(ctx)
+	if err != nil || !caps.CallDataPublicKey {
+		t.Fatalf("expected the subcall to provide the key, got %+v: %v", caps, err)
+	}
+
+	// Gateways serving oasis_callDataPublicKey use it.
+	b, err = WrapClient(dialTransport(t, newRPCTransport()), nil)
+	if err != nil || b.KeySource() != KeySourceRPC {
+		t.Fatalf("expected key from %s, got %q: %v", KeySourceRPC, b.KeySource(), err)
+	}
+}
+
+func TestSubcallKeySourceUnsupported(t *testing.T) {
+	rt := newRPCTransport()
+	handleSubcallKey(t, rt, 1)
+	_, err := WrapClient(dialTransport(t, rt), nil)
+	if !errors.Is(err, ErrCapabilityUnsupported{CapabilityCallDataPublicKey}) {
+		t.Fatalf("expected ErrCapabilityUnsupported{%s}, got %v", CapabilityCallDataPublicKey, err)
+	}
+
+	// Chains without the precompile return no data.
+	rt = newRPCTransport()
+	rt.handle("oasis_callDataPublicKey", nil)
+	rt.handle("eth_call", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Bytes{}, nil
+	})
+	if _, err = WrapClient(dialTransport(t, rt), nil); !errors.Is(err, ErrCapabilityUnsupported{CapabilityCallDataPublicKey}) {
+		t.Fatalf("expected ErrCapabilityUnsupported{%s}, got %v", CapabilityCallDataPublicKey, err)
+	}
+}
+
+func TestNewSubcallKeySource(t *testing.T) {
+	ctx := context.Background()
+	rt := newRPCTransport()
+	handleSubcallKey(t, rt, 0)
+	keys := NewSubcallKeySource(dialTransport(t, rt))
+	pk, epoch, err := keys.RuntimePublicKey(ctx)
+	if err != nil || epoch != 43 || *pk != *(*x25519.PublicKey)(subcallKey) {
+		t.Fatalf("unexpected key %x at epoch %d: %v", pk, epoch, err)
+	}
+
+	rt = newRPCTransport()
+	handleSubcallKey(t, rt, 1)
+	keys = NewSubcallKeySource(dialTransport(t, rt))
+	var failed *sapphire.CallFailedError
+	if _, _, err = keys.RuntimePublicKey(ctx); !errors.As(err, &failed) || failed.Module != "core" {
+		t.Fatalf("expected the subcall to fail, got %v", err)
+	}
+}
+
+func TestCallDataPublicKeyVerify(t *testing.T) {
+	km := testKeyManager(t)
+	signed := signTestKey(sapphire.CallDataPublicKey{PublicKey: subcallKey, Epoch: 43})
+	tamper := func(fn func(k *sapphire.CallDataPublicKey)) sapphire.CallDataPublicKey {
+		k := signed
+		k.PublicKey = common.CopyBytes(signed.PublicKey)
+		k.Checksum = common.CopyBytes(signed.Checksum)
+		k.Signature = common.CopyBytes(signed.Signature)
+		fn(&k)
+		return k
+	}
+	other := &keyManager{runtimeID: km.runtimeID}
+	other.signingKey[0] = 1
+
+	for name, tc := range map[string]struct {
+		key sapphire.CallDataPublicKey
+		km  *keyManager
+		err error
+	}{
+		"signed":             {signed, km, nil},
+		"unchecked":          {signed, nil, nil},
+		"missing signature":  {tamper(func(k *sapphire.CallDataPublicKey) { k.Signature = nil }), nil, sapphire.ErrKeyNotSigned},
+		"missing checksum":   {tamper(func(k *sapphire.CallDataPublicKey) { k.Checksum = nil }), nil, sapphire.ErrKeyNotSigned},
+		"short key":          {tamper(func(k *sapphire.CallDataPublicKey) { k.PublicKey = k.PublicKey[:31] }), nil, sapphire.ErrKeyFetchFailed},
+		"short checksum":     {tamper(func(k *sapphire.CallDataPublicKey) { k.Checksum = k.Checksum[:31] }), nil, sapphire.ErrKeyFetchFailed},
+		"short signature":    {tamper(func(k *sapphire.CallDataPublicKey) { k.Signature = k.Signature[:63] }), nil, sapphire.ErrKeyFetchFailed},
+		"tampered key":       {tamper(func(k *sapphire.CallDataPublicKey) { k.PublicKey[0] ^= 1 }), km, sapphire.ErrKeySignatureInvalid},
+		"tampered checksum":  {tamper(func(k *sapphire.CallDataPublicKey) { k.Checksum[0] ^= 1 }), km, sapphire.ErrKeySignatureInvalid},
+		"tampered signature": {tamper(func(k *sapphire.CallDataPublicKey) { k.Signature[0] ^= 1 }), km, sapphire.ErrKeySignatureInvalid},
+		"other epoch":        {tamper(func(k *sapphire.CallDataPublicKey) { k.Epoch++ }), km, sapphire.ErrKeySignatureInvalid},
+		"other key manager":  {signed, other, sapphire.ErrKeySignatureInvalid},
+	} {
+		err := verifyKey(&tc.key, tc.km)
+		if tc.err == nil && err != nil || tc.err != nil && (!errors.Is(err, tc.err) || !errors.Is(err, sapphire.ErrKeyFetchFailed)) {
+			t.Errorf("%s: expected %v, got %v", name, tc.err, err)
+		}
+	}
+}
+
+func TestKeyManagerKey(t *testing.T) {
+	ctx := context.Background()
+	gw := mockgateway.New(t)
+	opt := WithKeyManagerKey(gw.KeyManagerKey())
+	var otherKey signature.PublicKey
+	copy(otherKey[:], testKeyManagerKey.Public().(ed25519.PublicKey))
+
+	if _, err := WrapClient(gw.Dial(t), nil, opt); err != nil {
+		t.Fatalf("expected the signed key to verify, got %v", err)
+	}
+	if _, err := WrapRuntimeClient(ctx, gw.RuntimeClient(), gw.ChainID(), nil, opt); err != nil {
+		t.Fatalf("expected the signed runtime key to verify, got %v", err)
+	}
+	if _, err := WrapClient(gw.Dial(t), nil, WithKeyManagerKey(otherKey)); !errors.Is(err, sapphire.ErrKeySignatureInvalid) {
+		t.Fatalf("expected ErrKeySignatureInvalid for another key manager, got %v", err)
+	}
+	if _, err := WrapRuntimeClient(ctx, gw.RuntimeClient(), gw.ChainID(), nil, WithKeyManagerKey(otherKey)); !errors.Is(err, sapphire.ErrKeySignatureInvalid) {
+		t.Fatalf("expected ErrKeySignatureInvalid for the runtime key of another key manager, got %v", err)
+	}
+
+	// Keys tampered with by the gateway.
+	serve := func(fn func(key map[string]interface{})) {
+		signed := signTestKey(sapphire.CallDataPublicKey{PublicKey: subcallKey, Epoch: 42})
+		gw.Handle("oasis_callDataPublicKey", func([]json.RawMessage) (interface{}, error) {
+			key := map[string]interface{}{"key": signed.PublicKey, "checksum": signed.Checksum, "signature": signed.Signature, "epoch": signed.Epoch}
+			fn(key)
+			return key, nil
+		})
+	}
+	for name, tc := range map[string]struct {
+		fn  func(key map[string]interface{})
+		err error
+	}{
+		"untampered":        {func(map[string]interface{}) {}, nil},
+		"missing signature": {func(key map[string]interface{}) { delete(key, "signature") }, sapphire.ErrKeyNotSigned},
+		"missing checksum":  {func(key map[string]interface{}) { delete(key, "checksum") }, sapphire.ErrKeyNotSigned},
+		"other key":         {func(key map[string]interface{}) { key["key"] = hexutil.Bytes(make([]byte, 32)) }, sapphire.ErrKeySignatureInvalid},
+		"other epoch":       {func(key map[string]interface{}) { key["epoch"] = 43 }, sapphire.ErrKeySignatureInvalid},
+	} {
+		serve(tc.fn)
+		_, err := WrapClient(gw.Dial(t), nil, WithKeyManagerKey(otherKey))
+		if tc.err == nil && err != nil || tc.err != nil && !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected %v, got %v", name, tc.err, err)
+		}
+	}
+
+	// Keys from the subcall precompile are checked as well.
+	rt := newRPCTransport()
+	handleSubcallKey(t, rt, 0)
+	if _, err := WrapClient(dialTransport(t, rt), nil, WithKeyManagerKey(otherKey)); err != nil {
+		t.Fatalf("expected the subcall key to verify, got %v", err)
+	}
+	if _, err := WrapClient(dialTransport(t, rt), nil, opt); !errors.Is(err, sapphire.ErrKeySignatureInvalid) {
+		t.Fatalf("expected ErrKeySignatureInvalid for the subcall key, got %v", err)
+	}
+
+	// The runtime of other chains is unknown.
+	other := mockgateway.New(t, mockgateway.WithChainID(1))
+	if _, err := WrapClient(other.Dial(t), nil, WithKeyManagerKey(other.KeyManagerKey())); !errors.Is(err, sapphire.ErrKeyFetchFailed) {
+		t.Fatalf("expected ErrKeyFetchFailed for an unknown chain, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// headSubscriber is implemented by backends that can subscribe to new
+// headers, such as ethclient.Client over WebSocket.
+type headSubscriber interface {
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+}
+
+// headTracker keeps the latest header in memory so that leashes for the
+// latest block can be built without fetching it.
+//
+// Headers are received via a newHeads subscription if the backend supports
+// it and polled otherwise. A header is only used while it is younger than
+// twice the polling interval, so a stalled subscription or gateway never
+// leaves leashes built on an old block.
+type headTracker struct {
+	interval time.Duration
+
+	startOnce sync.Once
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	mu      sync.RWMutex
+	head    *types.Header
+	updated time.Time
+}
+
+func newHeadTracker(interval time.Duration) *headTracker {
+	return &headTracker{
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// latest returns the tracked header, or nil if it is missing or stale.
+func (t *headTracker) latest() *types.Header {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.head == nil || time.Since(t.updated) > 2*t.interval {
+		return nil
+	}
+	return t.head
+}
+
+// update records a header as the latest one. Older headers only refresh the
+// tracked header if they are the same block.
+func (t *headTracker) update(head *types.Header) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.head != nil && head.Number.Cmp(t.head.Number) < 0 {
+		return
+	}
+	t.head = head
+	t.updated = time.Now()
+}
+
+// start starts tracking headers in the background, once.
+func (t *headTracker) start(b *WrappedBackend) {
+	t.startOnce.Do(func() {
+		var ctx context.Context
+		ctx, t.cancel = context.WithCancel(context.Background())
+		go b.trackHeads(ctx, t)
+	})
+}
+
+// stop stops tracking headers and waits for the tracker to exit.
+func (t *headTracker) stop() {
+	t.startOnce.Do(func() {
+		// Never started.
+		close(t.done)
+	})
+	if t.cancel != nil {
+		t.cancel()
+	}
+	<-t.done
+}
+
+// trackHeads follows new headers until ctx is done, polling whenever there
+// is no working subscription and resubscribing after every interval.
+func (b *WrappedBackend) trackHeads(ctx context.Context, t *headTracker) {
+	defer close(t.done)
+	for {
+		// Catch up on blocks missed while not subscribed.
+		b.pollHead(ctx, t)
+		b.followHeads(ctx, t)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(t.interval):
+		}
+	}
+}
+
+// pollHead fetches the latest header once.
+func (b *WrappedBackend) pollHead(ctx context.Context, t *headTracker) {
+	head, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (*types.Header, error) {
+		return b.backend.HeaderByNumber(ctx, nil)
+	})
+	if err == nil {
+		t.update(head)
+	}
+}
+
+// followHeads receives headers from a newHeads subscription until it fails,
+// stalls or ctx is done. It returns immediately if the backend does not
+// support subscriptions, e.g. over HTTP.
+func (b *WrappedBackend) followHeads(ctx context.Context, t *headTracker) {
+	s, ok := b.backend.(headSubscriber)
+	if !ok {
+		return
+	}
+	ch := make(chan *types.Header, 16)
+	sub, err := invoke(ctx, b.mw, rpcSubscribe, func(ctx context.Context) (ethereum.Subscription, error) {
+		return s.SubscribeNewHead(ctx, ch)
+	})
+	if err != nil {
+		return
+	}
+	defer sub.Unsubscribe()
+
+	stall := time.NewTicker(t.interval)
+	defer stall.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.Err():
+			return
+		case head := <-ch:
+			t.update(head)
+		case <-stall.C:
+			if t.latest() == nil {
+				return
+			}
+		}
+	}
+}
+
+// latestLeashHeader returns the header a leash for the latest block is built
+// on, from the head tracker if it is enabled and fresh.
+func (b *WrappedBackend) latestLeashHeader(ctx context.Context) (*types.Header, error) {
+	if b.heads == nil {
+		return b.HeaderByNumber(ctx, nil)
+	}
+	b.heads.start(b)
+	if head := b.heads.latest(); head != nil {
+		return head, nil
+	}
+	head, err := b.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	b.heads.update(head)
+	return head, nil
+}
@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// headSubscription is a newHeads subscription that can be killed by the test.
+type headSubscription struct {
+	errCh chan error
+	once  sync.Once
+}
+
+func (s *headSubscription) Unsubscribe() {
+	s.once.Do(func() { close(s.errCh) })
+}
+
+func (s *headSubscription) Err() <-chan error {
+	return s.errCh
+}
+
+// subscribingBackend is a mockBackend that supports newHeads subscriptions.
+type subscribingBackend struct {
+	*mockBackend
+
+	subMu sync.Mutex
+	ch    chan<- *types.Header
+	sub   *headSubscription
+	subs  int
+}
+
+func (s *subscribingBackend) SubscribeNewHead(_ context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	s.ch, s.sub = ch, &headSubscription{errCh: make(chan error, 1)}
+	s.subs++
+	return s.sub, nil
+}
+
+func (s *subscribingBackend) subscriptions() int {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	return s.subs
+}
+
+// setHead makes the backend report a new latest block, and announces it to
+// the subscriber if announce is set.
+func (s *subscribingBackend) setHead(number int64, announce bool) {
+	s.mu.Lock()
+	s.head = types.CopyHeader(s.head)
+	s.head.Number = big.NewInt(number)
+	s.head.ParentHash = common.BigToHash(big.NewInt(number - 1))
+	head := types.CopyHeader(s.head)
+	s.mu.Unlock()
+	if announce {
+		s.subMu.Lock()
+		defer s.subMu.Unlock()
+		s.ch <- head
+	}
+}
+
+// kill drops the current subscription as a broken connection would.
+func (s *subscribingBackend) kill() {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	s.sub.errCh <- errors.New("connection closed")
+}
+
+func TestHeadTracking(t *testing.T) {
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	leashBlock := func(b *WrappedBackend) uint64 {
+		q, err := b.PrepareSignedQuery(ctx, ethereum.CallMsg{From: from, To: &to, Data: TestData})
+		if err != nil {
+			t.Fatalf("PrepareSignedQuery failed: %v", err)
+		}
+		return q.Leash.BlockNumber
+	}
+	eventually := func(what string, cond func() bool) {
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	t.Run("subscription", func(t *testing.T) {
+		backend := &subscribingBackend{mockBackend: newMockBackend()}
+		b := newWrappedBackend(backend, backend, *big.NewInt(0x5afd), sapphire.NewPlainCipher(), nil, WithKeyring(keyring), WithHeadTracking(200*time.Millisecond))
+		defer b.Close()
+
+		if n := leashBlock(b); n != 99 {
+			t.Fatalf("unexpected leash block %d", n)
+		}
+		eventually("subscription", func() bool { return backend.subscriptions() == 1 })
+
+		// Announced heads are used without fetching the latest block.
+		var fetches atomic.Int32
+		backend.mu.Lock()
+		backend.hook = func(_ context.Context, method string) error {
+			if method == "eth_getBlockByNumber" {
+				fetches.Add(1)
+			}
+			return nil
+		}
+		backend.mu.Unlock()
+		backend.setHead(120, true)
+		eventually("announced head", func() bool { return leashBlock(b) == 119 })
+		if fetches := fetches.Load(); fetches != 0 {
+			t.Fatalf("leash fetched the latest block %d times while subscribed", fetches)
+		}
+
+		// Killing the subscription falls back to polling until it is restored.
+		backend.kill()
+		backend.setHead(130, false)
+		eventually("polled head", func() bool { return leashBlock(b) == 129 })
+		eventually("resubscription", func() bool { return backend.subscriptions() >= 2 })
+		backend.setHead(140, true)
+		eventually("head on restored subscription", func() bool { return leashBlock(b) == 139 })
+	})
+
+	t.Run("stalled subscription", func(t *testing.T) {
+		backend := &subscribingBackend{mockBackend: newMockBackend()}
+		b := newWrappedBackend(backend, backend, *big.NewInt(0x5afd), sapphire.NewPlainCipher(), nil, WithKeyring(keyring), WithHeadTracking(20*time.Millisecond))
+		defer b.Close()
+
+		leashBlock(b)
+		eventually("subscription", func() bool { return backend.subscriptions() == 1 })
+		// The subscription silently stops delivering heads.
+		backend.setHead(150, false)
+		eventually("fresh head despite stalled subscription", func() bool { return leashBlock(b) == 149 })
+	})
+
+	t.Run("polling", func(t *testing.T) {
+		backend := newMockBackend()
+		b := newMockWrappedBackend(backend, nil, WithKeyring(keyring), WithHeadTracking(20*time.Millisecond))
+		defer b.Close()
+
+		if n := leashBlock(b); n != 99 {
+			t.Fatalf("unexpected leash block %d", n)
+		}
+		backend.mu.Lock()
+		backend.head = types.CopyHeader(backend.head)
+		backend.head.Number = big.NewInt(160)
+		backend.mu.Unlock()
+		eventually("polled head", func() bool { return leashBlock(b) == 159 })
+	})
+
+	t.Run("close", func(t *testing.T) {
+		// Closing a backend that never tracked heads must not block.
+		b := newMockWrappedBackend(newMockBackend(), nil, WithHeadTracking(time.Second))
+		b.Close()
+	})
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// limitedHTTPClient returns a copy of c, or of a default client if c is nil,
+// whose response bodies fail with sapphire.ErrResponseTooLarge beyond maxSize bytes.
+func limitedHTTPClient(c *http.Client, maxSize int64) *http.Client {
+	var cp http.Client
+	if c != nil {
+		cp = *c
+	}
+	base := cp.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	cp.Transport = &limitedTransport{base: base, maxSize: maxSize}
+	return &cp
+}
+
+// limitedTransport limits the size of response bodies.
+type limitedTransport struct {
+	base    http.RoundTripper
+	maxSize int64
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ContentLength > t.maxSize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d byte response exceeds limit of %d bytes", sapphire.ErrResponseTooLarge, resp.ContentLength, t.maxSize)
+	}
+	resp.Body = &limitedBody{body: resp.Body, remaining: t.maxSize}
+	return resp, nil
+}
+
+// limitedBody fails reads once more than the allowed number of bytes was read.
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, sapphire.ErrResponseTooLarge
+	}
+	// Read one byte past the limit to tell a body of exactly the limit apart
+	// from a larger one.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), sapphire.ErrResponseTooLarge
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
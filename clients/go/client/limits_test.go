@@ -0,0 +1,203 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/oasisprotocol/deoxysii"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// nestedArrays encodes depth nested single-element CBOR arrays.
+func nestedArrays(depth int) []byte {
+	return append(bytes.Repeat([]byte{0x81}, depth), 0x00)
+}
+
+// hugeByteString is a CBOR byte string whose length prefix claims almost
+// 2^64 bytes but carries only a few.
+var hugeByteString = []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xf0, 'x', 'y', 'z'}
+
+// allocatedBytes returns how many bytes f allocates on the heap.
+func allocatedBytes(f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestDecodeLimits(t *testing.T) {
+	limits := sapphire.DecodeLimits{MaxEnvelopeSize: 1024, MaxNestingDepth: 8}
+	plain := sapphire.CipherWithLimits(sapphire.NewPlainCipher(), limits)
+	deoxys := sapphire.CipherWithLimits(newSeededCipher(t, 1), limits)
+
+	for _, tc := range []struct {
+		name     string
+		response []byte
+		tooLarge bool
+	}{
+		{"oversized response", cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(make([]byte, 2048))}), true},
+		{"oversized envelope", append(cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(make([]byte, 1000))}), make([]byte, 100)...), true},
+		{"deep nesting", nestedArrays(64), true},
+		{"deep nesting in result", cbor.Marshal(sdkTypes.CallResult{Ok: nestedArrays(64)}), true},
+		{"huge length prefix", hugeByteString, false},
+		{"huge length prefix in result", cbor.Marshal(sdkTypes.CallResult{Ok: hugeByteString}), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, c := range []sapphire.Cipher{plain, deoxys} {
+				var err error
+				allocated := allocatedBytes(func() {
+					_, err = c.DecryptCallResult(tc.response)
+				})
+				if tc.tooLarge && !errors.Is(err, sapphire.ErrResponseTooLarge) {
+					t.Fatalf("%T: expected ErrResponseTooLarge, got %v", c, err)
+				}
+				if allocated > 64<<10 {
+					t.Fatalf("%T: decoding a %d byte response allocated %d bytes", c, len(tc.response), allocated)
+				}
+			}
+		})
+	}
+
+	// Responses within the limits decode as before.
+	res, err := plain.DecryptCallResult(cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(TestData)}))
+	if err != nil || !bytes.Equal(res, TestData) {
+		t.Fatalf("response within limits failed to decode: %v", err)
+	}
+}
+
+func TestDecodeLimitsBackend(t *testing.T) {
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	// Limits configured on the backend apply to its cipher.
+	mock := newMockBackend()
+	mock.callResult = cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(make([]byte, 4096))})
+	b := newMockWrappedBackend(mock, nil, WithDecodeLimits(sapphire.DecodeLimits{MaxEnvelopeSize: 1024}))
+	if _, err := b.CallContract(ctx, ethereum.CallMsg{To: &to, Data: TestData}, nil); !errors.Is(err, sapphire.ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+	b.setCipher(sapphire.NewPlainCipher())
+	if _, err := b.CallContract(ctx, ethereum.CallMsg{To: &to, Data: TestData}, nil); !errors.Is(err, sapphire.ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge after refreshing the cipher, got %v", err)
+	}
+
+	// Connections made by Dial limit response bodies.
+	rt := newRPCTransport()
+	rt.handle("eth_getCode", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Bytes(make([]byte, 4096)), nil
+	})
+	b, err := Dial("http://gateway.invalid", nil,
+		WithHTTPClient(&http.Client{Transport: rt}),
+		WithDecodeLimits(sapphire.DecodeLimits{MaxResponseSize: 1024}),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer b.Close()
+	if _, err = b.CodeAt(ctx, to, nil); !errors.Is(err, sapphire.ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+}
+
+// fuzzLimits keep decoders under fuzzing from allocating more than the
+// fuzzer's inputs warrant.
+var fuzzLimits = sapphire.DecodeLimits{MaxEnvelopeSize: 4096, MaxNestingDepth: 8}
+
+// fuzzCiphers returns a plain and an encrypting cipher limited to fuzzLimits,
+// and a function sealing call results as the runtime does for the latter.
+func fuzzCiphers(f *testing.F) ([]sapphire.Cipher, func(result []byte) []byte) {
+	pair := sapphire.Curve25519KeyPair{}
+	deoxys, err := sapphire.NewX25519DeoxysIICipher(&pair, &pair.PublicKey, 1)
+	if err != nil {
+		f.Fatalf("could not init deoxysii cipher: %v", err)
+	}
+	seal := func(result []byte) []byte {
+		data, nonce := deoxys.Encrypt(result)
+		return cbor.Marshal(sdkTypes.CallResult{Unknown: cbor.Marshal(sdkTypes.ResultEnvelopeX25519DeoxysII{
+			Nonce: [deoxysii.NonceSize]byte(nonce),
+			Data:  data,
+		})})
+	}
+	return []sapphire.Cipher{sapphire.CipherWithLimits(sapphire.NewPlainCipher(), fuzzLimits), sapphire.CipherWithLimits(deoxys, fuzzLimits)}, seal
+}
+
+// checkDecryptedCallResult checks that c either failed to decrypt response
+// with a CallResultError or returned res, which decodes the same once encoded
+// again.
+func checkDecryptedCallResult(t *testing.T, c sapphire.Cipher, seal func([]byte) []byte, response, res []byte, err error) {
+	if err != nil {
+		var resultErr *sapphire.CallResultError
+		if !errors.As(err, &resultErr) || !bytes.Equal(resultErr.RawEnvelope, response) {
+			t.Fatalf("%T: expected a CallResultError with the response, got %v", c, err)
+		}
+		return
+	}
+	if len(res) > len(response) {
+		t.Fatalf("%T: decoded %d bytes from a %d byte response", c, len(res), len(response))
+	}
+	encoded := cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(res)})
+	if c.CallFormat() != sdkTypes.CallFormatPlain {
+		encoded = seal(encoded)
+	}
+	again, err := c.DecryptCallResult(encoded)
+	if err != nil || !bytes.Equal(again, res) {
+		t.Fatalf("%T: expected %x to decode again, got %x, %v", c, res, again, err)
+	}
+}
+
+func FuzzDecryptCallResult(f *testing.F) {
+	f.Add(cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(TestData)}))
+	f.Add(cbor.Marshal(sdkTypes.CallResult{Failed: &sdkTypes.FailedCallResult{Module: "evm", Code: 8}}))
+	f.Add(cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(sdkTypes.ResultEnvelopeX25519DeoxysII{Data: TestData})}))
+	f.Add(nestedArrays(300))
+	f.Add(cbor.Marshal(sdkTypes.CallResult{Ok: nestedArrays(300)}))
+	f.Add(hugeByteString)
+	f.Add(cbor.Marshal(sdkTypes.CallResult{Unknown: hugeByteString}))
+	f.Add([]byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	f.Add([]byte{0xbb, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00})
+	for _, data := range moduleErrorData(f) {
+		f.Add(data)
+	}
+
+	ciphers, seal := fuzzCiphers(f)
+	f.Add(seal(cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(TestData)})))
+	f.Fuzz(func(t *testing.T, response []byte) {
+		for _, c := range ciphers {
+			res, err := c.DecryptCallResult(response)
+			checkDecryptedCallResult(t, c, seal, response, res, err)
+		}
+	})
+}
+
+// FuzzDecryptInnerCallResult fuzzes the call results decrypted from the
+// envelopes of encrypted calls, which fuzzing the envelopes can't reach.
+func FuzzDecryptInnerCallResult(f *testing.F) {
+	f.Add(cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(TestData)}))
+	f.Add(cbor.Marshal(sdkTypes.CallResult{Unknown: cbor.Marshal(TestData)}))
+	f.Add(cbor.Marshal(sdkTypes.CallResult{Failed: &sdkTypes.FailedCallResult{Module: "evm", Code: 8, Message: "reverted: "}}))
+	f.Add(cbor.Marshal(sdkTypes.CallResult{Ok: nestedArrays(300)}))
+	f.Add(hugeByteString)
+	for _, data := range moduleErrorData(f) {
+		f.Add(data)
+	}
+
+	ciphers, seal := fuzzCiphers(f)
+	deoxys := ciphers[1]
+	f.Fuzz(func(t *testing.T, result []byte) {
+		response := seal(result)
+		res, err := deoxys.DecryptCallResult(response)
+		checkDecryptedCallResult(t, deoxys, seal, response, res, err)
+	})
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// ParsedLog is a log decoded by ParseLogs.
+type ParsedLog struct {
+	types.Log
+	// Event is the event the log was emitted as, nil if it is not in the ABI.
+	Event *abi.Event
+	// Fields holds the event's arguments by name, or "arg<i>" for unnamed
+	// ones. Indexed arguments of dynamic types, e.g. strings, arrays and
+	// tuples, are only known by the common.Hash of their value.
+	Fields map[string]interface{}
+	// Err is the error decoding or decrypting the log, in which case Fields
+	// holds the arguments decoded so far.
+	Err error
+}
+
+// LogDecryptor decrypts a bytes field of a log, e.g. with a key the
+// application shares with the contract.
+type LogDecryptor func(log types.Log, ciphertext []byte) ([]byte, error)
+
+// ParseLogsOption configures ParseLogs.
+type ParseLogsOption func(*parseLogsConfig)
+
+type parseLogsConfig struct {
+	decryptors map[[2]string]LogDecryptor
+}
+
+// WithFieldDecryptor makes ParseLogs replace the non-indexed bytes argument
+// field of event by its decryption with decrypt.
+func WithFieldDecryptor(event, field string, decrypt LogDecryptor) ParseLogsOption {
+	return func(cfg *parseLogsConfig) {
+		cfg.decryptors[[2]string{event, field}] = decrypt
+	}
+}
+
+// ParseLogs decodes logs emitted by a contract with the given ABI.
+//
+// Logs of events not in the ABI are returned with a nil Event rather than
+// failing, as confidential contracts may emit events they don't publish.
+// Anonymous events carry no signature topic, so a log is only decoded as one
+// if it matches no other event and exactly one anonymous event fits its
+// topics and data.
+//
+// An error is only returned for options that don't match the ABI; errors of
+// individual logs are reported in their Err.
+func ParseLogs(parsedABI abi.ABI, logs []types.Log, opts ...ParseLogsOption) ([]ParsedLog, error) {
+	cfg := parseLogsConfig{decryptors: make(map[[2]string]LogDecryptor)}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	for key := range cfg.decryptors {
+		if err := checkDecryptedField(parsedABI, key[0], key[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	var anonymous []*abi.Event
+	for _, name := range sortedEventNames(parsedABI) {
+		if ev := parsedABI.Events[name]; ev.Anonymous {
+			anonymous = append(anonymous, &ev)
+		}
+	}
+
+	parsed := make([]ParsedLog, len(logs))
+	for i, log := range logs {
+		parsed[i] = ParsedLog{Log: log}
+		ev := eventFor(parsedABI, anonymous, log)
+		if ev == nil {
+			continue
+		}
+		parsed[i].Event = ev
+		parsed[i].Fields, parsed[i].Err = decodeLog(ev, log)
+		if parsed[i].Err == nil {
+			parsed[i].Err = cfg.decrypt(ev, log, parsed[i].Fields)
+		}
+	}
+	return parsed, nil
+}
+
+// FilterParsedLogs fetches the logs matching query with FilterLogs and
+// decodes them with ParseLogs.
+func (b *WrappedBackend) FilterParsedLogs(ctx context.Context, query ethereum.FilterQuery, parsedABI abi.ABI, opts ...ParseLogsOption) ([]ParsedLog, error) {
+	logs, err := b.FilterLogs(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLogs(parsedABI, logs, opts...)
+}
+
+func sortedEventNames(parsedABI abi.ABI) []string {
+	names := make([]string, 0, len(parsedABI.Events))
+	for name := range parsedABI.Events {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func checkDecryptedField(parsedABI abi.ABI, event, field string) error {
+	ev, ok := parsedABI.Events[event]
+	if !ok {
+		return fmt.Errorf("%w: no event %s to decrypt", sapphire.ErrABI, event)
+	}
+	for i, arg := range ev.Inputs {
+		if argName(arg, i) != field {
+			continue
+		}
+		if arg.Indexed || arg.Type.T != abi.BytesTy {
+			return fmt.Errorf("%w: %s.%s is not a non-indexed bytes argument", sapphire.ErrABI, event, field)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: event %s has no argument %s", sapphire.ErrABI, event, field)
+}
+
+// eventFor returns the event log was emitted as, nil if unknown.
+func eventFor(parsedABI abi.ABI, anonymous []*abi.Event, log types.Log) *abi.Event {
+	if len(log.Topics) > 0 {
+		if ev, err := parsedABI.EventByID(log.Topics[0]); err == nil {
+			return ev
+		}
+	}
+	var match *abi.Event
+	for _, ev := range anonymous {
+		if _, err := decodeLog(ev, log); err != nil {
+			continue
+		}
+		if match != nil {
+			return nil // Ambiguous.
+		}
+		match = ev
+	}
+	return match
+}
+
+// decodeLog decodes the arguments of log, emitted as ev.
+func decodeLog(ev *abi.Event, log types.Log) (map[string]interface{}, error) {
+	topics := log.Topics
+	if !ev.Anonymous {
+		if len(topics) == 0 {
+			return nil, fmt.Errorf("log has no signature topic")
+		}
+		topics = topics[1:]
+	}
+	var indexed, nonIndexed []int
+	for i, arg := range ev.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, i)
+		} else {
+			nonIndexed = append(nonIndexed, i)
+		}
+	}
+	if len(topics) != len(indexed) {
+		return nil, fmt.Errorf("log has %d indexed topics, event %s has %d", len(topics), ev.Name, len(indexed))
+	}
+
+	fields := make(map[string]interface{}, len(ev.Inputs))
+	for j, i := range indexed {
+		arg := ev.Inputs[i]
+		switch arg.Type.T {
+		case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+			// Only the hash of dynamic values is logged.
+			fields[argName(arg, i)] = topics[j]
+		default:
+			single := map[string]interface{}{}
+			if err := abi.ParseTopicsIntoMap(single, abi.Arguments{arg}, []common.Hash{topics[j]}); err != nil {
+				return fields, fmt.Errorf("failed to decode %s.%s: %w", ev.Name, argName(arg, i), err)
+			}
+			fields[argName(arg, i)] = single[arg.Name]
+		}
+	}
+	if len(nonIndexed) == 0 && len(log.Data) > 0 {
+		return fields, fmt.Errorf("log has data, event %s has no non-indexed arguments", ev.Name)
+	}
+	values, err := ev.Inputs.NonIndexed().Unpack(log.Data)
+	if err != nil {
+		return fields, fmt.Errorf("failed to decode %s data: %w", ev.Name, err)
+	}
+	for j, i := range nonIndexed {
+		fields[argName(ev.Inputs[i], i)] = values[j]
+	}
+	return fields, nil
+}
+
+// decrypt applies the decryptors configured for ev to fields.
+func (cfg *parseLogsConfig) decrypt(ev *abi.Event, log types.Log, fields map[string]interface{}) error {
+	for i, arg := range ev.Inputs {
+		name := argName(arg, i)
+		decrypt, ok := cfg.decryptors[[2]string{ev.Name, name}]
+		if !ok {
+			continue
+		}
+		plaintext, err := decrypt(log, fields[name].([]byte))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s.%s: %w", ev.Name, name, err)
+		}
+		fields[name] = plaintext
+	}
+	return nil
+}
+
+func argName(arg abi.Argument, i int) string {
+	if arg.Name == "" {
+		return fmt.Sprintf("arg%d", i)
+	}
+	return arg.Name
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+const logsABI = `[
+	{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]},
+	{"type":"event","name":"Note","inputs":[{"name":"tag","type":"string","indexed":true},{"name":"payload","type":"bytes","indexed":false}]},
+	{"type":"event","name":"Grouped","inputs":[{"name":"pair","type":"tuple","indexed":true,"components":[{"name":"a","type":"uint256"},{"name":"b","type":"bool"}]},{"name":"ids","type":"uint8[]","indexed":true},{"name":"","type":"uint64","indexed":false}]},
+	{"type":"event","name":"Ping","anonymous":true,"inputs":[{"name":"id","type":"uint256","indexed":true},{"name":"data","type":"bytes","indexed":false}]}
+]`
+
+// xorDecryptor "decrypts" by XORing with key.
+func xorDecryptor(key byte) LogDecryptor {
+	return func(_ types.Log, ciphertext []byte) ([]byte, error) {
+		if len(ciphertext) == 0 {
+			return nil, errors.New("empty ciphertext")
+		}
+		out := make([]byte, len(ciphertext))
+		for i, c := range ciphertext {
+			out[i] = c ^ key
+		}
+		return out, nil
+	}
+}
+
+func xor(data []byte, key byte) []byte {
+	out, _ := xorDecryptor(key)(types.Log{}, data)
+	return out
+}
+
+func packData(t *testing.T, ev abi.Event, values ...interface{}) []byte {
+	data, err := ev.Inputs.NonIndexed().Pack(values...)
+	if err != nil {
+		t.Fatalf("failed to pack %s data: %v", ev.Name, err)
+	}
+	return data
+}
+
+func TestParseLogs(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(logsABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	from := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	transfer, note, grouped, ping := parsed.Events["Transfer"], parsed.Events["Note"], parsed.Events["Grouped"], parsed.Events["Ping"]
+	tagHash := crypto.Keccak256Hash([]byte("secret"))
+	pairHash := common.HexToHash("0x01")
+	idsHash := common.HexToHash("0x02")
+	unknown := types.Log{Topics: []common.Hash{crypto.Keccak256Hash([]byte("Private(uint256)"))}, Data: []byte{1, 2, 3}}
+
+	logs := []types.Log{
+		{Topics: []common.Hash{transfer.ID, common.BytesToHash(from[:]), common.BytesToHash(to[:])}, Data: packData(t, transfer, big.NewInt(7))},
+		{Topics: []common.Hash{note.ID, tagHash}, Data: packData(t, note, xor([]byte("hello"), 0x5a))},
+		{Topics: []common.Hash{grouped.ID, pairHash, idsHash}, Data: packData(t, grouped, uint64(9))},
+		{Topics: []common.Hash{common.BigToHash(big.NewInt(42))}, Data: packData(t, ping, []byte{0xaa})},
+		unknown,
+		{Topics: []common.Hash{transfer.ID, common.BytesToHash(from[:])}, Data: packData(t, transfer, big.NewInt(7))},
+	}
+	out, err := ParseLogs(parsed, logs, WithFieldDecryptor("Note", "payload", xorDecryptor(0x5a)))
+	if err != nil {
+		t.Fatalf("ParseLogs failed: %v", err)
+	}
+	if len(out) != len(logs) {
+		t.Fatalf("expected %d logs, got %d", len(logs), len(out))
+	}
+	for i, l := range out[:4] {
+		if l.Event == nil || l.Err != nil {
+			t.Fatalf("log %d not decoded: %v", i, l.Err)
+		}
+	}
+
+	t.Run("plain", func(t *testing.T) {
+		f := out[0].Fields
+		if out[0].Event.Name != "Transfer" || f["from"] != from || f["to"] != to || f["value"].(*big.Int).Int64() != 7 {
+			t.Fatalf("unexpected Transfer fields %v", f)
+		}
+	})
+	t.Run("indexed dynamic types", func(t *testing.T) {
+		if out[1].Fields["tag"] != tagHash {
+			t.Fatalf("indexed string not decoded as its hash: %v", out[1].Fields["tag"])
+		}
+		f := out[2].Fields
+		if f["pair"] != pairHash || f["ids"] != idsHash || f["arg2"] != uint64(9) {
+			t.Fatalf("unexpected Grouped fields %v", f)
+		}
+	})
+	t.Run("decryptor", func(t *testing.T) {
+		if !bytes.Equal(out[1].Fields["payload"].([]byte), []byte("hello")) {
+			t.Fatalf("payload not decrypted: %x", out[1].Fields["payload"])
+		}
+		// Anonymous events and other events are not decrypted.
+		if !bytes.Equal(out[3].Fields["data"].([]byte), []byte{0xaa}) {
+			t.Fatalf("undesignated field was changed: %x", out[3].Fields["data"])
+		}
+	})
+	t.Run("anonymous", func(t *testing.T) {
+		if out[3].Event.Name != "Ping" || out[3].Fields["id"].(*big.Int).Int64() != 42 {
+			t.Fatalf("unexpected anonymous event %v", out[3].Fields)
+		}
+	})
+	t.Run("unknown", func(t *testing.T) {
+		if out[4].Event != nil || out[4].Err != nil || !bytes.Equal(out[4].Data, unknown.Data) {
+			t.Fatalf("unknown event not left raw: %+v", out[4])
+		}
+	})
+	t.Run("malformed", func(t *testing.T) {
+		if out[5].Event == nil || out[5].Err == nil {
+			t.Fatalf("expected a decoding error for a Transfer missing a topic")
+		}
+	})
+}
+
+func TestParseLogsAnonymousAmbiguity(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(`[
+		{"type":"event","name":"A","anonymous":true,"inputs":[{"name":"x","type":"uint256","indexed":true}]},
+		{"type":"event","name":"B","anonymous":true,"inputs":[{"name":"y","type":"bytes32","indexed":true}]},
+		{"type":"event","name":"C","anonymous":true,"inputs":[{"name":"z","type":"uint256","indexed":false}]}
+	]`))
+	logs := []types.Log{
+		{Topics: []common.Hash{common.HexToHash("0x01")}},
+		{Data: common.BigToHash(big.NewInt(1)).Bytes()},
+		{Topics: []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")}},
+	}
+	out, err := ParseLogs(parsed, logs)
+	if err != nil {
+		t.Fatalf("ParseLogs failed: %v", err)
+	}
+	if out[0].Event != nil {
+		t.Fatalf("ambiguous anonymous log decoded as %s", out[0].Event.Name)
+	}
+	if out[1].Event == nil || out[1].Event.Name != "C" {
+		t.Fatalf("expected the log to be decoded as C")
+	}
+	if out[2].Event != nil {
+		t.Fatalf("log without a matching event decoded as %s", out[2].Event.Name)
+	}
+}
+
+func TestParseLogsDecryptorErrors(t *testing.T) {
+	parsed, _ := abi.JSON(strings.NewReader(logsABI))
+	note := parsed.Events["Note"]
+	for _, opt := range []ParseLogsOption{
+		WithFieldDecryptor("Missing", "payload", xorDecryptor(1)),
+		WithFieldDecryptor("Note", "missing", xorDecryptor(1)),
+		WithFieldDecryptor("Note", "tag", xorDecryptor(1)),
+		WithFieldDecryptor("Transfer", "value", xorDecryptor(1)),
+	} {
+		if _, err := ParseLogs(parsed, nil, opt); !errors.Is(err, sapphire.ErrABI) {
+			t.Fatalf("expected ErrABI for an invalid decryptor, got %v", err)
+		}
+	}
+
+	logs := []types.Log{{Topics: []common.Hash{note.ID, {}}, Data: packData(t, note, []byte{})}}
+	out, err := ParseLogs(parsed, logs, WithFieldDecryptor("Note", "payload", xorDecryptor(1)))
+	if err != nil {
+		t.Fatalf("ParseLogs failed: %v", err)
+	}
+	if out[0].Event == nil || out[0].Err == nil {
+		t.Fatalf("expected the decryption error on the log")
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+type rpcKind int
+
+const (
+	// rpcRead is a request that does not change chain state and is safe to repeat.
+	rpcRead rpcKind = iota
+	// rpcSend submits a transaction.
+	rpcSend
+	// rpcKeyFetch fetches the runtime's calldata public key.
+	rpcKeyFetch
+	// rpcSubscribe establishes a subscription.
+	rpcSubscribe
+)
+
+// middleware applies client-side policies to outbound gateway requests.
+type middleware struct {
+	readLimiter *rateLimiter
+	sendLimiter *rateLimiter
+	retry       *RetryPolicy
+	timeouts    *Timeouts
+	breaker     *circuitBreaker
+	// redact removes gateway credentials from the errors of requests.
+	redact *redactor
+}
+
+func (mw *middleware) limiter(kind rpcKind) *rateLimiter {
+	if kind == rpcSend {
+		return mw.sendLimiter
+	}
+	return mw.readLimiter
+}
+
+func (mw *middleware) shouldRetry(kind rpcKind, attempt int, err error) bool {
+	if mw.retry == nil {
+		return false
+	}
+	return attempt+1 < mw.retry.MaxAttempts && isRetryable(err, kind == rpcSend)
+}
+
+// withDefaultRetry returns mw retrying with DefaultRetryPolicy if no retry
+// policy is set, for requests callers can't retry themselves.
+func (mw *middleware) withDefaultRetry() *middleware {
+	if mw == nil || mw.retry != nil {
+		return mw
+	}
+	withRetry, policy := *mw, DefaultRetryPolicy
+	withRetry.retry = &policy
+	return &withRetry
+}
+
+// invoke performs fn, a single outbound request, subject to timeouts, rate
+// limiting, retries and the circuit breaker.
+//
+// When a retry is due, the backoff delay and the rate limiter wait overlap
+// rather than add up, so a throttled client does not back off twice. A retry
+// that could not complete before the deadline is not attempted, and the last
+// error is returned instead. The same applies when the circuit breaker opens
+// between attempts. Errors with a runtime module error in their data wrap
+// it, see DecodeModuleError, and reverts are reported as RevertError.
+func invoke[T any](ctx context.Context, mw *middleware, kind rpcKind, fn func(context.Context) (T, error)) (T, error) {
+	if mw == nil {
+		res, err := fn(ctx)
+		return res, revertError(moduleError(err))
+	}
+	res, err := invokeAttempts(ctx, mw, kind, fn)
+	return res, mw.redact.err(err)
+}
+
+// invokeAttempts is invoke without redaction.
+func invokeAttempts[T any](ctx context.Context, mw *middleware, kind rpcKind, fn func(context.Context) (T, error)) (T, error) {
+	parent := ctx
+	ctx, cancel := mw.timeouts.withTimeout(ctx, kind)
+	defer cancel()
+
+	var (
+		res     T
+		err     error
+		backoff time.Duration
+	)
+	for attempt := 0; ; attempt++ {
+		limiter := mw.limiter(kind)
+		wait := limiter.reserve()
+		if wait < backoff {
+			wait = backoff
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			limiter.release()
+			var zero T
+			return zero, err
+		}
+		generation, openErr := mw.breaker.allow()
+		if openErr != nil {
+			limiter.release()
+			if attempt == 0 {
+				err = openErr
+			}
+			return res, err
+		}
+
+		res, err = fn(ctx)
+		err = revertError(moduleError(err))
+		mw.breaker.record(parent, generation, err)
+		if err == nil || ctx.Err() != nil || !mw.shouldRetry(kind, attempt, err) {
+			return res, err
+		}
+		backoff = mw.retry.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return res, err
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"context"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+var TestData = []byte{1, 2, 3, 4, 5}
+
+// evmRevertedCode is the evm module's error code for reverted calls.
+const evmRevertedCode = 8
+
+// mockBackend is an in-memory bind.ContractBackend and bind.DeployBackend
+// used to exercise the wrapped client without a gateway.
+type mockBackend struct {
+	mu sync.Mutex
+
+	head   *types.Header
+	nonces map[common.Address]uint64
+	// history holds account nonces at past blocks, served by NonceAt.
+	history  map[uint64]map[common.Address]uint64
+	sent     []*types.Transaction
+	calls    []ethereum.CallMsg
+	receipts map[common.Hash]*types.Receipt
+
+	callResult []byte
+	callErr    error
+	gas        uint64
+	sendErr    error
+
+	// hook, if set, is invoked at the start of every request that reaches
+	// the gateway and can block or fail it.
+	hook func(ctx context.Context, method string) error
+}
+
+func newMockBackend() *mockBackend {
+	return &mockBackend{
+		head: &types.Header{
+			Number:     big.NewInt(100),
+			ParentHash: common.HexToHash("2ec361fee28d09a3ad2c4d5f7f95d409ce2b68c39b5d647edf0ea651e069e4a8"),
+		},
+		nonces:   make(map[common.Address]uint64),
+		receipts: make(map[common.Hash]*types.Receipt),
+		gas:      21_000,
+	}
+}
+
+func (m *mockBackend) enter(ctx context.Context, method string) error {
+	m.mu.Lock()
+	hook := m.hook
+	m.mu.Unlock()
+	if hook == nil {
+		return nil
+	}
+	return hook(ctx, method)
+}
+
+func (m *mockBackend) sentTransactions() []*types.Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*types.Transaction(nil), m.sent...)
+}
+
+func (m *mockBackend) receivedCalls() []ethereum.CallMsg {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ethereum.CallMsg(nil), m.calls...)
+}
+
+func (m *mockBackend) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return []byte{0x60, 0x80}, nil
+}
+
+func (m *mockBackend) CallContract(ctx context.Context, call ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	if err := m.enter(ctx, "eth_call"); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, call)
+	return m.callResult, m.callErr
+}
+
+func (m *mockBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if err := m.enter(ctx, "eth_getBlockByNumber"); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	header := types.CopyHeader(m.head)
+	if number != nil {
+		header.Number = new(big.Int).Set(number)
+	}
+	return header, nil
+}
+
+func (m *mockBackend) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return m.CodeAt(ctx, account, nil)
+}
+
+func (m *mockBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	if err := m.enter(ctx, "eth_getTransactionCount"); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.nonces[account], nil
+}
+
+func (m *mockBackend) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	if err := m.enter(ctx, "eth_getTransactionCount"); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if blockNumber != nil {
+		if nonces, ok := m.history[blockNumber.Uint64()]; ok {
+			return nonces[account], nil
+		}
+	}
+	return m.nonces[account], nil
+}
+
+func (m *mockBackend) SuggestGasPrice(context.Context) (*big.Int, error) {
+	return big.NewInt(sapphire.DefaultGasPrice), nil
+}
+
+func (m *mockBackend) SuggestGasTipCap(context.Context) (*big.Int, error) {
+	return big.NewInt(0), nil
+}
+
+func (m *mockBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	if err := m.enter(ctx, "eth_estimateGas"); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, call)
+	return m.gas, nil
+}
+
+func (m *mockBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := m.enter(ctx, "eth_sendRawTransaction"); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sendErr != nil {
+		return m.sendErr
+	}
+	m.sent = append(m.sent, tx)
+	return nil
+}
+
+func (m *mockBackend) FilterLogs(context.Context, ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) SubscribeFilterLogs(ctx context.Context, _ ethereum.FilterQuery, _ chan<- types.Log) (ethereum.Subscription, error) {
+	if err := m.enter(ctx, "eth_subscribe"); err != nil {
+		return nil, err
+	}
+	return nil, ethereum.NotFound
+}
+
+func (m *mockBackend) TransactionReceipt(_ context.Context, txHash common.Hash) (*types.Receipt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.receipts[txHash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func (m *mockBackend) TransactionByHash(_ context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tx := range m.sent {
+		if tx.Hash() == txHash {
+			_, ok := m.receipts[txHash]
+			return tx, !ok, nil
+		}
+	}
+	return nil, false, ethereum.NotFound
+}
+
+// mine records a receipt for a sent transaction.
+func (m *mockBackend) mine(tx *types.Transaction, status, gasUsed uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	receipt := &types.Receipt{
+		TxHash:      tx.Hash(),
+		Status:      status,
+		GasUsed:     gasUsed,
+		BlockNumber: new(big.Int).Set(m.head.Number),
+	}
+	if from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(0x5afd)), tx); err == nil && tx.To() == nil {
+		receipt.ContractAddress = crypto.CreateAddress(from, tx.Nonce())
+	}
+	m.receipts[tx.Hash()] = receipt
+}
+
+// newMockWrappedBackend wraps a mockBackend the same way WrapClient wraps an ethclient.Client.
+func newMockWrappedBackend(m *mockBackend, sign sapphire.SignerFn, opts ...Option) *WrappedBackend {
+	return newWrappedBackend(m, m, *big.NewInt(0x5afd), sapphire.NewPlainCipher(), sign, opts...)
+}
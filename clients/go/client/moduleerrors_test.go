@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// moduleErrorResponse is a gateway error response carrying a module error.
+type moduleErrorResponse struct {
+	Name    string   `json:"name"`
+	Method  string   `json:"method"`
+	Error   rpcError `json:"error"`
+	Module  string   `json:"module"`
+	Code    uint32   `json:"code"`
+	Message string   `json:"message"`
+}
+
+func loadModuleErrorResponses(t testing.TB) []moduleErrorResponse {
+	raw, err := os.ReadFile(filepath.Join("..", "testdata", "module_error_responses.json"))
+	if err != nil {
+		t.Fatalf("failed to read responses: %v", err)
+	}
+	var responses []moduleErrorResponse
+	if err = json.Unmarshal(raw, &responses); err != nil {
+		t.Fatalf("failed to decode responses: %v", err)
+	}
+	return responses
+}
+
+func TestDecodeModuleError(t *testing.T) {
+	ctx := context.Background()
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	key, _ := crypto.GenerateKey()
+	tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{To: &to, Gas: 21_000, GasPrice: big.NewInt(sapphire.DefaultGasPrice)}), types.LatestSignerForChainID(big.NewInt(0x5afd)), key)
+
+	for _, resp := range loadModuleErrorResponses(t) {
+		rt := newRPCTransport()
+		rt.handle(resp.Method, func([]json.RawMessage) (interface{}, error) {
+			return nil, &resp.Error
+		})
+		b, err := WrapClient(dialTransport(t, rt), nil)
+		if err != nil {
+			t.Fatalf("failed to wrap client: %v", err)
+		}
+		switch resp.Method {
+		case "eth_call":
+			_, err = b.CallContract(ctx, ethereum.CallMsg{To: &to, Data: TestData}, nil)
+		case "eth_estimateGas":
+			_, err = b.EstimateGas(ctx, ethereum.CallMsg{To: &to, Data: TestData})
+		case "eth_sendRawTransaction":
+			err = b.SendTransaction(ctx, tx)
+		default:
+			t.Fatalf("%s: unexpected method %s", resp.Name, resp.Method)
+		}
+		var failed *sapphire.ModuleError
+		if !errors.As(err, &failed) || failed.Module != resp.Module || failed.Code != resp.Code || failed.Message != resp.Message {
+			t.Errorf("%s: expected module %s code %d %q, got %v", resp.Name, resp.Module, resp.Code, resp.Message, err)
+			continue
+		}
+		if !errors.Is(err, sapphire.ErrCallFailed) || failed.Description() == "" {
+			t.Errorf("%s: expected a well-known ErrCallFailed, got %v", resp.Name, err)
+		}
+
+		if resp.Name == "reverted" {
+			d := &TxDiagnosis{ReplayError: err}
+			d.classify()
+			if d.Kind != FailureRevert || d.RevertReason != "not the owner" {
+				t.Errorf("expected the revert to be diagnosed, got %s %q", d.Kind, d.RevertReason)
+			}
+		}
+	}
+}
+
+func TestModuleErrorDescription(t *testing.T) {
+	for _, tc := range []struct {
+		err      *sapphire.ModuleError
+		expected string
+	}{
+		{&sapphire.ModuleError{Module: "core", Code: 12}, "call failed in module core with code 12: out of gas"},
+		{&sapphire.ModuleError{Module: "evm", Code: 8, Message: "reverted: "}, "reverted: "},
+		{&sapphire.ModuleError{Module: "rofl", Code: 99}, "call failed in module rofl with code 99"},
+	} {
+		if got := tc.err.Error(); got != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, got)
+		}
+	}
+	if _, ok := sapphire.DecodeModuleError(&rpcDataError{data: "0x08c379a0"}); ok {
+		t.Fatalf("expected revert data not to be decoded as a module error")
+	}
+}
+
+// rpcDataError is an rpc.DataError with the given data.
+type rpcDataError struct {
+	data interface{}
+}
+
+func (e *rpcDataError) Error() string          { return "execution reverted" }
+func (e *rpcDataError) ErrorData() interface{} { return e.data }
+
+// moduleErrorData returns the CBOR-encoded failed call results in the error
+// data of the module error responses.
+func moduleErrorData(t testing.TB) [][]byte {
+	var out [][]byte
+	for _, resp := range loadModuleErrorResponses(t) {
+		var s string
+		if json.Unmarshal(resp.Error.Data, &s) != nil {
+			continue
+		}
+		data, err := hexutil.Decode(s)
+		if err != nil {
+			data, err = base64.StdEncoding.DecodeString(s)
+		}
+		if err != nil {
+			t.Fatalf("%s: invalid error data %q", resp.Name, s)
+		}
+		out = append(out, data)
+	}
+	return out
+}
+
+// FuzzDecodeModuleError fuzzes the JSON error data of gateway errors.
+func FuzzDecodeModuleError(f *testing.F) {
+	for _, resp := range loadModuleErrorResponses(f) {
+		f.Add(string(resp.Error.Data))
+	}
+	for _, resp := range loadRevertErrorResponses(f) {
+		f.Add(string(resp.Error.Data))
+	}
+	f.Add(`"0x08c379a0"`)
+	f.Add(`{"module":"evm","code":4294967296}`)
+
+	f.Fuzz(func(t *testing.T, data string) {
+		var payload interface{}
+		if json.Unmarshal([]byte(data), &payload) != nil {
+			return
+		}
+		err := &rpcDataError{data: payload}
+		sapphire.RevertDataFromError(err)
+		failed, ok := sapphire.DecodeModuleError(err)
+		if !ok {
+			return
+		}
+		// Module errors decode the same from their CBOR encoding.
+		encoded := cbor.Marshal(sdkTypes.FailedCallResult{Module: failed.Module, Code: failed.Code, Message: failed.Message})
+		for _, s := range []string{hexutil.Encode(encoded), base64.StdEncoding.EncodeToString(encoded)} {
+			again, ok := sapphire.DecodeModuleError(&rpcDataError{data: s})
+			if !ok || *again != *failed {
+				t.Fatalf("expected %+v to decode again from %s, got %+v", failed, s, again)
+			}
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+const multicall3ABI = `[{"type":"function","name":"aggregate3","stateMutability":"payable","inputs":[{"name":"calls","type":"tuple[]","components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}]}],"outputs":[{"name":"returnData","type":"tuple[]","components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}]}]}]`
+
+var multicall3 = mustParseABI(multicall3ABI)
+
+func mustParseABI(s string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// multicall3Call and multicall3Result mirror Multicall3's Call3 and Result.
+type multicall3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicall3Result struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// MulticallCall is a call aggregated by Multicall.
+type MulticallCall struct {
+	Target common.Address
+	Data   []byte
+}
+
+// MulticallResult is the outcome of a call aggregated by Multicall.
+type MulticallResult struct {
+	// Data is the data returned by the call.
+	Data []byte
+	// Err is a *MulticallError if the call reverted.
+	Err error
+}
+
+// MulticallError is the error of a call aggregated by Multicall that
+// reverted. The other calls are not affected.
+type MulticallError struct {
+	// Index is the position of the call in the batch.
+	Index  int
+	Target common.Address
+	// RevertData is the data the call reverted with.
+	RevertData []byte
+}
+
+func (e *MulticallError) Error() string {
+	if reason, err := abi.UnpackRevert(e.RevertData); err == nil {
+		return fmt.Sprintf("multicall: call %d to %s reverted: %s", e.Index, e.Target.Hex(), reason)
+	}
+	return fmt.Sprintf("multicall: call %d to %s reverted", e.Index, e.Target.Hex())
+}
+
+// Multicall executes the calls in a single query through Multicall3's
+// aggregate3, returning a result for each call. A call that reverts does not
+// affect the others, its result carries a *MulticallError instead.
+//
+// The aggregate query is encrypted like any other call, which covers the
+// inner calls: the runtime decrypts the query before Multicall3 runs it, and
+// the inner calls never leave the confidential runtime. If from is set, the
+// query is signed on its behalf; the inner calls are still made by the
+// Multicall3 contract, so they see its address as msg.sender.
+//
+// The Multicall3 address is that of the network in Networks, unless set with
+// WithMulticallAddress.
+func (b *WrappedBackend) Multicall(ctx context.Context, from common.Address, calls []MulticallCall, blockNumber *big.Int) ([]MulticallResult, error) {
+	target, err := b.multicallAddress()
+	if err != nil {
+		return nil, err
+	}
+	inner := make([]multicall3Call, len(calls))
+	for i, call := range calls {
+		inner[i] = multicall3Call{Target: call.Target, AllowFailure: true, CallData: call.Data}
+	}
+	data, err := multicall3.Pack("aggregate3", inner)
+	if err != nil {
+		return nil, fmt.Errorf("%w: packing aggregate3: %v", sapphire.ErrABI, err)
+	}
+	res, err := b.CallContract(ctx, ethereum.CallMsg{From: from, To: &target, Data: data}, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	out, err := multicall3.Unpack("aggregate3", res)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unpacking aggregate3 result: %v", sapphire.ErrABI, err)
+	}
+	var results []multicall3Result
+	if err = multicall3.Methods["aggregate3"].Outputs.Copy(&results, out); err != nil {
+		return nil, fmt.Errorf("%w: unpacking aggregate3 result: %v", sapphire.ErrABI, err)
+	}
+	if len(results) != len(calls) {
+		return nil, fmt.Errorf("%w: aggregate3 returned %d results for %d calls", sapphire.ErrABI, len(results), len(calls))
+	}
+
+	mapped := make([]MulticallResult, len(results))
+	for i, r := range results {
+		if r.Success {
+			mapped[i].Data = r.ReturnData
+		} else {
+			mapped[i].Err = &MulticallError{Index: i, Target: calls[i].Target, RevertData: r.ReturnData}
+		}
+	}
+	return mapped, nil
+}
+
+// multicallAddress returns the Multicall3 address for the backend's chain.
+func (b *WrappedBackend) multicallAddress() (common.Address, error) {
+	if b.multicall != nil {
+		return *b.multicall, nil
+	}
+	if network, ok := Networks[b.chainID.Uint64()]; ok && network.Multicall3 != (common.Address{}) {
+		return network.Multicall3, nil
+	}
+	return common.Address{}, fmt.Errorf("no Multicall3 address known for chain %s, use WithMulticallAddress", b.chainID.String())
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+// revertWith encodes an Error(string) revert.
+func revertWith(t *testing.T, reason string) []byte {
+	stringType, _ := abi.NewType("string", "", nil)
+	encoded, err := abi.Arguments{{Type: stringType}}.Pack(reason)
+	if err != nil {
+		t.Fatalf("failed to encode revert: %v", err)
+	}
+	return append(crypto.Keccak256([]byte("Error(string)"))[:4], encoded...)
+}
+
+// mockAggregate3 makes mock answer calls with the given aggregate3 results.
+func mockAggregate3(t *testing.T, mock *mockBackend, results ...multicall3Result) {
+	encoded, err := multicall3.Methods["aggregate3"].Outputs.Pack(results)
+	if err != nil {
+		t.Fatalf("failed to encode results: %v", err)
+	}
+	mock.callResult = cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(encoded)})
+}
+
+func TestMulticall(t *testing.T) {
+	ctx := context.Background()
+	a := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	c := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	calls := []MulticallCall{{Target: a, Data: []byte{1, 2, 3}}, {Target: c, Data: []byte{4, 5, 6}}}
+
+	mock := newMockBackend()
+	mockAggregate3(t, mock, multicall3Result{true, []byte{0xaa}}, multicall3Result{false, revertWith(t, "nope")})
+	b := newMockWrappedBackend(mock, nil)
+
+	results, err := b.Multicall(ctx, common.Address{}, calls, nil)
+	if err != nil {
+		t.Fatalf("Multicall failed: %v", err)
+	}
+	if len(results) != 2 || !bytes.Equal(results[0].Data, []byte{0xaa}) || results[0].Err != nil {
+		t.Fatalf("unexpected first result %+v", results)
+	}
+	var callErr *MulticallError
+	if !errors.As(results[1].Err, &callErr) || callErr.Index != 1 || callErr.Target != c {
+		t.Fatalf("expected a MulticallError for the second call, got %v", results[1].Err)
+	}
+	if !strings.Contains(callErr.Error(), "nope") {
+		t.Fatalf("revert reason missing from %q", callErr.Error())
+	}
+
+	// The aggregate is encrypted as a whole and carries the inner calls.
+	sent := mock.receivedCalls()[0]
+	if sent.To == nil || *sent.To != CanonicalMulticall3 {
+		t.Fatalf("aggregate not sent to Multicall3")
+	}
+	var envelope sdkTypes.Call
+	var data []byte
+	if err = cbor.Unmarshal(sent.Data, &envelope); err != nil {
+		t.Fatalf("aggregate is not an envelope: %v", err)
+	}
+	if err = cbor.Unmarshal(envelope.Body, &data); err != nil {
+		t.Fatalf("failed to decode envelope body: %v", err)
+	}
+	args, err := multicall3.Methods["aggregate3"].Inputs.Unpack(data[4:])
+	if err != nil {
+		t.Fatalf("failed to decode aggregate3 input: %v", err)
+	}
+	var inner []multicall3Call
+	if err = multicall3.Methods["aggregate3"].Inputs.Copy(&inner, args); err != nil {
+		t.Fatalf("failed to decode aggregate3 input: %v", err)
+	}
+	for i, call := range inner {
+		if call.Target != calls[i].Target || !bytes.Equal(call.CallData, calls[i].Data) || !call.AllowFailure {
+			t.Fatalf("unexpected inner call %d: %+v", i, call)
+		}
+	}
+
+	mockAggregate3(t, mock, multicall3Result{true, nil})
+	if _, err = b.Multicall(ctx, common.Address{}, calls, nil); !errors.Is(err, sapphire.ErrABI) {
+		t.Fatalf("expected a result count mismatch to fail, got %v", err)
+	}
+}
+
+func TestMulticallEncryption(t *testing.T) {
+	ctx := context.Background()
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	a := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	secret := []byte("confidential inner calldata")
+	override := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	mock := newMockBackend()
+	mockAggregate3(t, mock, multicall3Result{true, nil})
+	b := newMockWrappedBackend(mock, nil, WithKeyring(keyring), WithMulticallAddress(override))
+	b.cipher = newSeededCipher(t, 1)
+	mock.callResult = opaqueResult
+
+	// The opaque result can't be decoded, only the query matters here.
+	_, _ = b.Multicall(ctx, from, []MulticallCall{{Target: a, Data: secret}}, nil)
+	sent := mock.receivedCalls()
+	query := sent[len(sent)-1]
+	if *query.To != override {
+		t.Fatalf("WithMulticallAddress was not honored")
+	}
+	if bytes.Contains(query.Data, secret) {
+		t.Fatalf("inner calldata sent in plain text")
+	}
+	var pack evm.SignedCallDataPack
+	if err := cbor.Unmarshal(query.Data, &pack); err != nil {
+		t.Fatalf("aggregate from a sender is not a signed query: %v", err)
+	}
+
+	unknown := newWrappedBackend(mock, mock, *big.NewInt(1), sapphire.NewPlainCipher(), nil)
+	if _, err := unknown.Multicall(ctx, common.Address{}, nil, nil); err == nil {
+		t.Fatalf("expected chains without a Multicall3 address to fail")
+	}
+}
+
+func TestMulticallLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	client := localnet.Dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if code, err := client.CodeAt(ctx, CanonicalMulticall3, nil); err != nil || len(code) == 0 {
+		t.Skip("Multicall3 is not deployed on localnet")
+	}
+	b, err := WrapClient(client, nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+
+	// Query Multicall3 itself: getChainId() and an unknown selector.
+	results, err := b.Multicall(ctx, common.Address{}, []MulticallCall{
+		{Target: CanonicalMulticall3, Data: common.FromHex("3408e470")},
+		{Target: CanonicalMulticall3, Data: common.FromHex("deadbeef")},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Multicall failed: %v", err)
+	}
+	if results[0].Err != nil || new(big.Int).SetBytes(results[0].Data).Uint64() != 0x5afd {
+		t.Fatalf("unexpected getChainId result %+v", results[0])
+	}
+	if results[1].Err == nil {
+		t.Fatalf("expected the unknown selector to revert")
+	}
+}
@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// nonceManager tracks the next nonce of each account that sent transactions
+// through the wrapped client, so that back-to-back sends from the same
+// account don't depend on the gateway's view of the pending pool.
+type nonceManager struct {
+	mu     sync.Mutex
+	nonces map[common.Address]uint64
+}
+
+func newNonceManager() *nonceManager {
+	return &nonceManager{
+		nonces: make(map[common.Address]uint64),
+	}
+}
+
+// pendingNonce returns the larger of the gateway's pending nonce and the
+// locally tracked next nonce for the account.
+func (m *nonceManager) pendingNonce(account common.Address, gatewayNonce uint64) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if local, ok := m.nonces[account]; ok && local > gatewayNonce {
+		return local
+	}
+	return gatewayNonce
+}
+
+// commit records that a transaction with the given nonce was accepted for the account.
+func (m *nonceManager) commit(account common.Address, nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if next := nonce + 1; next > m.nonces[account] {
+		m.nonces[account] = next
+	}
+}
+
+// set overrides the locally tracked next nonce for the account.
+func (m *nonceManager) set(account common.Address, next uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nonces[account] = next
+}
+
+// local returns the locally tracked next nonce for the account.
+func (m *nonceManager) local(account common.Address) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.nonces[account]
+}
+
+// NonceGapPolicy selects how SendTransaction handles transactions whose nonce
+// is ahead of the gateway's pending nonce.
+type NonceGapPolicy int
+
+const (
+	// NonceGapFail resyncs the local nonce tracking to the gateway when it
+	// rejects a nonce as too high, and returns the error.
+	NonceGapFail NonceGapPolicy = iota
+	// NonceGapHold holds the transaction until the gateway's pending nonce
+	// catches up with it. The pending nonce is checked before every send.
+	NonceGapHold
+	// NonceGapFill fills the gap with zero-value transfers from the sender to
+	// itself before sending the transaction. The pending nonce is checked
+	// before every send.
+	NonceGapFill
+)
+
+// NonceAction is a decision taken to bring the local nonce tracking of an
+// account back in line with the chain.
+type NonceAction int
+
+const (
+	// NonceFastForward advances the local nonce after a nonce too low error.
+	NonceFastForward NonceAction = iota
+	// NonceReset rewinds the local nonce to the gateway's after a nonce too
+	// high error.
+	NonceReset
+	// NonceHold holds a transaction until the gap before it closes.
+	NonceHold
+	// NonceFill fills the gap before a transaction with self-transfers.
+	NonceFill
+)
+
+func (a NonceAction) String() string {
+	switch a {
+	case NonceFastForward:
+		return "fast-forward"
+	case NonceReset:
+		return "reset"
+	case NonceHold:
+		return "hold"
+	case NonceFill:
+		return "fill"
+	default:
+		return "unknown"
+	}
+}
+
+// NonceResync describes a nonce resync decision.
+type NonceResync struct {
+	Account common.Address
+	Action  NonceAction
+	// Nonce is the nonce of the transaction being sent.
+	Nonce uint64
+	// Local is the locally tracked next nonce before the resync.
+	Local uint64
+	// Gateway is the gateway's pending nonce.
+	Gateway uint64
+}
+
+// NoncePolicy configures how the wrapped client recovers when its nonce
+// tracking diverges from the chain, e.g. after a gateway failover.
+type NoncePolicy struct {
+	// Gap selects how transactions ahead of the gateway's pending nonce are
+	// handled.
+	Gap NonceGapPolicy
+	// PollInterval is how often the gateway's pending nonce is checked while
+	// holding a transaction. Defaults to one second.
+	PollInterval time.Duration
+	// OnResync, if set, is called for every resync decision.
+	OnResync func(NonceResync)
+}
+
+// isNonceTooLow reports whether the gateway rejected a transaction because
+// its nonce was already used.
+func isNonceTooLow(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "nonce too low")
+}
+
+// isNonceTooHigh reports whether the gateway rejected a transaction because
+// its nonce leaves a gap after the account's pending nonce.
+func isNonceTooHigh(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "nonce too high") || strings.Contains(msg, "future nonce") || strings.Contains(msg, "nonce gap")
+}
+
+func (b *WrappedBackend) onResync(ev NonceResync) {
+	if b.noncePolicy.OnResync != nil {
+		b.noncePolicy.OnResync(ev)
+	}
+}
+
+// gatewayNonce returns the gateway's pending nonce for the account, ignoring
+// the local nonce tracking.
+func (b *WrappedBackend) gatewayNonce(ctx context.Context, account common.Address) (uint64, error) {
+	return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (uint64, error) {
+		return b.backend.PendingNonceAt(ctx, account)
+	})
+}
+
+// resyncNonce updates the local nonce tracking after the gateway rejected tx
+// with sendErr, and reports whether the error was a nonce too high error.
+func (b *WrappedBackend) resyncNonce(ctx context.Context, from common.Address, tx *types.Transaction, sendErr error) bool {
+	tooLow, tooHigh := isNonceTooLow(sendErr), isNonceTooHigh(sendErr)
+	if !tooLow && !tooHigh {
+		return false
+	}
+	gateway, err := b.gatewayNonce(ctx, from)
+	if err != nil {
+		return false
+	}
+	ev := NonceResync{Account: from, Nonce: tx.Nonce(), Local: b.nonces.local(from), Gateway: gateway}
+	if tooLow {
+		// The nonce is used even if the gateway does not know it yet.
+		next := gateway
+		if next <= tx.Nonce() {
+			next = tx.Nonce() + 1
+		}
+		if next > ev.Local {
+			b.nonces.set(from, next)
+		}
+		ev.Action = NonceFastForward
+		b.onResync(ev)
+		return false
+	}
+	b.nonces.set(from, gateway)
+	ev.Action = NonceReset
+	b.onResync(ev)
+	return true
+}
+
+// closeNonceGap holds or fills the gap between the account's pending nonce
+// and the nonce of tx, according to the nonce policy. The pending nonce is the
+// larger of the gateway's and the locally tracked one, so that transactions
+// this client sent but a lagging gateway doesn't report yet are not taken for
+// a gap.
+func (b *WrappedBackend) closeNonceGap(ctx context.Context, from common.Address, tx *types.Transaction) error {
+	if b.noncePolicy.Gap == NonceGapFail {
+		return nil
+	}
+	gateway, err := b.gatewayNonce(ctx, from)
+	if err != nil {
+		return err
+	}
+	local := b.nonces.local(from)
+	if max(gateway, local) >= tx.Nonce() {
+		return nil
+	}
+	ev := NonceResync{Account: from, Nonce: tx.Nonce(), Local: local, Gateway: gateway}
+	switch b.noncePolicy.Gap {
+	case NonceGapHold:
+		ev.Action = NonceHold
+		b.onResync(ev)
+		interval := b.noncePolicy.PollInterval
+		if interval == 0 {
+			interval = time.Second
+		}
+		poll := time.NewTicker(interval)
+		defer poll.Stop()
+		for max(gateway, b.nonces.local(from)) < tx.Nonce() {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("holding transaction with nonce %d for gap after %d: %w", tx.Nonce(), gateway, ctx.Err())
+			case <-poll.C:
+			}
+			if gateway, err = b.gatewayNonce(ctx, from); err != nil {
+				return err
+			}
+		}
+	case NonceGapFill:
+		ev.Action = NonceFill
+		b.onResync(ev)
+		for nonce := max(gateway, local); nonce < tx.Nonce(); nonce++ {
+			filler, err := b.signTx(ctx, from, types.NewTx(&types.LegacyTx{
+				Nonce:    nonce,
+				GasPrice: tx.GasFeeCap(),
+				Gas:      params.TxGas,
+				To:       &from,
+				Value:    new(big.Int),
+			}))
+			if err != nil {
+				return fmt.Errorf("failed to sign gap filler with nonce %d: %w", nonce, err)
+			}
+			if err = b.sendTx(ctx, newCallContext(ctx, "eth_sendRawTransaction", filler.To(), nil), filler); err != nil {
+				return fmt.Errorf("failed to send gap filler with nonce %d: %w", nonce, err)
+			}
+			b.nonces.commit(from, nonce)
+		}
+	}
+	return nil
+}
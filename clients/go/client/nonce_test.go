@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// divergentBackend is a mockBackend whose gateway reports pending nonces that
+// may differ from the chain's, and which enforces the chain's nonces.
+type divergentBackend struct {
+	*mockBackend
+
+	mu sync.Mutex
+	// chain holds the next nonce of each account as known to the chain.
+	chain map[common.Address]uint64
+	// reported, if set, overrides the pending nonce the gateway reports.
+	reported map[common.Address]uint64
+	// staleOnce makes the gateway report the overridden nonce only once.
+	staleOnce bool
+	// lagging makes the gateway keep reporting the overridden nonce after
+	// accepting transactions.
+	lagging  bool
+	accepted []*types.Transaction
+}
+
+func newDivergentBackend() *divergentBackend {
+	return &divergentBackend{
+		mockBackend: newMockBackend(),
+		chain:       make(map[common.Address]uint64),
+		reported:    make(map[common.Address]uint64),
+	}
+}
+
+func (d *divergentBackend) PendingNonceAt(_ context.Context, account common.Address) (uint64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n, ok := d.reported[account]; ok {
+		if d.staleOnce {
+			delete(d.reported, account)
+		}
+		return n, nil
+	}
+	return d.chain[account], nil
+}
+
+func (d *divergentBackend) SendTransaction(_ context.Context, tx *types.Transaction) error {
+	from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(0x5afd)), tx)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch next := d.chain[from]; {
+	case tx.Nonce() < next:
+		return fmt.Errorf("nonce too low: next nonce %d, tx nonce %d", next, tx.Nonce())
+	case tx.Nonce() > next:
+		return fmt.Errorf("nonce too high: next nonce %d, tx nonce %d", next, tx.Nonce())
+	}
+	d.chain[from]++
+	if !d.lagging {
+		delete(d.reported, from)
+	}
+	d.accepted = append(d.accepted, tx)
+	return nil
+}
+
+func (d *divergentBackend) setNonces(account common.Address, chain uint64, reported *uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.chain[account] = chain
+	if reported != nil {
+		d.reported[account] = *reported
+	} else {
+		delete(d.reported, account)
+	}
+}
+
+func TestNonceResync(t *testing.T) {
+	keyring := newTestKeyring(t, 1)
+	from := keyring.Addresses()[0]
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	setup := func(policy NoncePolicy) (*WrappedBackend, *divergentBackend, *[]NonceResync) {
+		backend := newDivergentBackend()
+		var mu sync.Mutex
+		var events []NonceResync
+		policy.OnResync = func(ev NonceResync) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, ev)
+		}
+		b := newWrappedBackend(backend, backend, *big.NewInt(0x5afd), sapphire.NewPlainCipher(), nil, WithKeyring(keyring), WithNoncePolicy(policy))
+		return b, backend, &events
+	}
+	send := func(b *WrappedBackend, nonce uint64) error {
+		tx, err := b.Transactor(from).Signer(from, types.NewTransaction(nonce, to, big.NewInt(0), 100_000, big.NewInt(sapphire.DefaultGasPrice), TestData))
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		return b.SendTransaction(ctx, tx)
+	}
+	pending := func(b *WrappedBackend) uint64 {
+		nonce, err := b.PendingNonceAt(ctx, from)
+		if err != nil {
+			t.Fatalf("PendingNonceAt failed: %v", err)
+		}
+		return nonce
+	}
+
+	t.Run("behind", func(t *testing.T) {
+		// Another process sent transactions the failed-over gateway does not
+		// report as pending yet.
+		b, backend, events := setup(NoncePolicy{})
+		stale := uint64(3)
+		backend.setNonces(from, 6, &stale)
+		if err := send(b, pending(b)); err == nil || !isNonceTooLow(err) {
+			t.Fatalf("expected nonce too low, got %v", err)
+		}
+		if len(*events) != 1 || (*events)[0].Action != NonceFastForward || (*events)[0].Nonce != 3 {
+			t.Fatalf("unexpected resync events %+v", *events)
+		}
+		if next := pending(b); next != 4 {
+			t.Fatalf("local nonce was not fast-forwarded past the used nonce, got %d", next)
+		}
+
+		// Once the gateway reports the chain's nonce, the tracking catches up.
+		backend.setNonces(from, 6, nil)
+		if err := send(b, pending(b)); err != nil {
+			t.Fatalf("send after resync failed: %v", err)
+		}
+	})
+
+	t.Run("ahead", func(t *testing.T) {
+		// The failed-over gateway lost transactions this client sent.
+		b, backend, events := setup(NoncePolicy{})
+		b.nonces.commit(from, 9)
+		backend.setNonces(from, 7, nil)
+		if err := send(b, pending(b)); err == nil || !isNonceTooHigh(err) {
+			t.Fatalf("expected nonce too high, got %v", err)
+		}
+		if len(*events) != 1 || (*events)[0].Action != NonceReset || (*events)[0].Local != 10 || (*events)[0].Gateway != 7 {
+			t.Fatalf("unexpected resync events %+v", *events)
+		}
+		if next := pending(b); next != 7 {
+			t.Fatalf("local nonce was not reset, got %d", next)
+		}
+		if err := send(b, pending(b)); err != nil {
+			t.Fatalf("send after resync failed: %v", err)
+		}
+	})
+
+	t.Run("hold", func(t *testing.T) {
+		b, backend, events := setup(NoncePolicy{Gap: NonceGapHold, PollInterval: 5 * time.Millisecond})
+		backend.setNonces(from, 7, nil)
+		done := make(chan error, 1)
+		go func() { done <- send(b, 10) }()
+
+		select {
+		case err := <-done:
+			t.Fatalf("transaction was not held: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		backend.setNonces(from, 10, nil)
+		if err := <-done; err != nil {
+			t.Fatalf("held transaction failed: %v", err)
+		}
+		if len(*events) != 1 || (*events)[0].Action != NonceHold || (*events)[0].Gateway != 7 {
+			t.Fatalf("unexpected resync events %+v", *events)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		tx, _ := b.Transactor(from).Signer(from, types.NewTransaction(20, to, big.NewInt(0), 100_000, big.NewInt(sapphire.DefaultGasPrice), TestData))
+		if err := b.SendTransaction(ctx, tx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected held transaction to time out, got %v", err)
+		}
+	})
+
+	t.Run("fill", func(t *testing.T) {
+		b, backend, events := setup(NoncePolicy{Gap: NonceGapFill})
+		backend.setNonces(from, 7, nil)
+		if err := send(b, 10); err != nil {
+			t.Fatalf("send with gap failed: %v", err)
+		}
+		if len(*events) != 1 || (*events)[0].Action != NonceFill {
+			t.Fatalf("unexpected resync events %+v", *events)
+		}
+		if len(backend.accepted) != 4 {
+			t.Fatalf("expected 3 fillers and the transaction, got %d transactions", len(backend.accepted))
+		}
+		for i, filler := range backend.accepted[:3] {
+			if filler.Nonce() != uint64(7+i) || *filler.To() != from || filler.Value().Sign() != 0 || len(filler.Data()) != 0 {
+				t.Fatalf("unexpected gap filler %d", i)
+			}
+		}
+		if next := pending(b); next != 11 {
+			t.Fatalf("unexpected next nonce %d", next)
+		}
+	})
+
+	t.Run("fill with lagging gateway", func(t *testing.T) {
+		// The gateway doesn't report the transactions of a burst as pending,
+		// which must not be taken for a gap.
+		b, backend, events := setup(NoncePolicy{Gap: NonceGapFill})
+		reported := uint64(7)
+		backend.lagging = true
+		backend.setNonces(from, 7, &reported)
+		for i := 0; i < 5; i++ {
+			if err := send(b, pending(b)); err != nil {
+				t.Fatalf("send %d failed: %v", i, err)
+			}
+		}
+		if len(*events) != 0 {
+			t.Fatalf("unexpected resync events %+v", *events)
+		}
+		for i, tx := range backend.accepted {
+			if tx.Nonce() != uint64(7+i) || *tx.To() != to {
+				t.Fatalf("transaction %d was replaced by a gap filler", i)
+			}
+		}
+
+		// A gap after the burst is filled from the local nonce on.
+		if err := send(b, 14); err != nil {
+			t.Fatalf("send with gap failed: %v", err)
+		}
+		if len(*events) != 1 || (*events)[0].Action != NonceFill || (*events)[0].Local != 12 {
+			t.Fatalf("unexpected resync events %+v", *events)
+		}
+		if len(backend.accepted) != 8 || backend.accepted[5].Nonce() != 12 || *backend.accepted[5].To() != from {
+			t.Fatalf("expected fillers from nonce 12, got %d transactions", len(backend.accepted))
+		}
+	})
+
+	t.Run("fill after reset", func(t *testing.T) {
+		// Gaps the policy did not catch before sending are filled after the
+		// gateway rejects the nonce.
+		b, backend, events := setup(NoncePolicy{Gap: NonceGapFill})
+		reported := uint64(8)
+		backend.staleOnce = true
+		backend.setNonces(from, 7, &reported)
+		if err := send(b, 8); err != nil {
+			t.Fatalf("send with hidden gap failed: %v", err)
+		}
+		if len(*events) != 2 || (*events)[0].Action != NonceReset || (*events)[1].Action != NonceFill {
+			t.Fatalf("unexpected resync events %+v", *events)
+		}
+		if len(backend.accepted) != 2 || backend.accepted[1].Nonce() != 8 {
+			t.Fatalf("gap before the transaction was not filled")
+		}
+	})
+}
@@ -0,0 +1,315 @@
+package client
+
+import (
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// Option configures a WrappedBackend.
+type Option func(*WrappedBackend)
+
+// WithKeyring makes the wrapped client sign with the keyring's signer that
+// matches the sender of each transaction or signed query.
+//
+// The SignerFn passed to WrapClient, if any, is used for accounts that are not
+// in the keyring.
+func WithKeyring(keyring *sapphire.Keyring) Option {
+	return func(b *WrappedBackend) {
+		b.keyring = keyring
+	}
+}
+
+// WithRateLimit limits the rate of requests sent to the gateway. Reads and
+// transaction submissions are limited separately; a zero RateLimit leaves the
+// corresponding class unlimited.
+//
+// Waiting for the limiter honors the request context: a request whose
+// deadline would expire while waiting fails immediately.
+func WithRateLimit(reads, sends sapphire.RateLimit) Option {
+	return func(b *WrappedBackend) {
+		b.mw.readLimiter = newRateLimiter(reads)
+		b.mw.sendLimiter = newRateLimiter(sends)
+	}
+}
+
+// WithRetry retries requests that failed with an error IsRetryable reports
+// as retryable according to the given policy.
+func WithRetry(policy RetryPolicy) Option {
+	return func(b *WrappedBackend) {
+		b.mw.retry = &policy
+	}
+}
+
+// WithCircuitBreaker fails requests with ErrCircuitOpen, without contacting the
+// gateway, once it looks unavailable, and lets probe requests through after
+// a while to detect its recovery. See CircuitBreakerPolicy.
+//
+// Retries count as separate requests, so a breaker opening during retries
+// ends them early with the last error.
+func WithCircuitBreaker(policy CircuitBreakerPolicy) Option {
+	return func(b *WrappedBackend) {
+		b.mw.breaker = newCircuitBreaker(policy)
+	}
+}
+
+// WithTimeouts applies the given timeouts to requests whose context has no
+// deadline.
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(b *WrappedBackend) {
+		b.mw.timeouts = &timeouts
+	}
+}
+
+// WithUnsignedEstimateFallback makes EstimateGas tolerate senders the client
+// cannot sign for. Calls from such senders are estimated as plain encrypted
+// calls, executed with a zero msg.sender, instead of failing with ErrNoSigner.
+func WithUnsignedEstimateFallback() Option {
+	return func(b *WrappedBackend) {
+		b.plainEstimate = true
+	}
+}
+
+// WithGasMargin adds margin to the gas estimates of EstimateGas, and so to
+// the gas limits bindings estimate for TransactOpts without a GasLimit.
+// Explicit gas limits are used verbatim.
+func WithGasMargin(margin GasMargin) Option {
+	return func(b *WrappedBackend) {
+		b.gasMargin = margin
+	}
+}
+
+// WithGasPadding makes Transactor set the gas limit of transactions to
+// target, for contracts that pad their gas use to it with Sapphire.padGas so
+// that it doesn't reveal what they executed. Receipts of transactions sent
+// with that limit whose gas used differs from target are reported to the
+// debug hook with a *GasPaddingError.
+//
+// The runtime charges gas outside of the EVM, e.g. for the calldata, which
+// padGas doesn't cover, so the contract needs to pad to less than target.
+func WithGasPadding(target uint64) Option {
+	return func(b *WrappedBackend) {
+		b.gasPadding = target
+		b.padded = newPlaintextStore(plaintextStoreSize)
+	}
+}
+
+// WithFeeSuggestions makes the wrapped client pick fees with SuggestFees and
+// the given options whenever the caller did not specify them: transactions
+// signed without any fees set are given suggested fees, and Transactor leaves
+// the fees to be suggested instead of using DefaultGasPrice.
+func WithFeeSuggestions(opts FeeOptions) Option {
+	return func(b *WrappedBackend) {
+		b.feeOpts = &opts
+	}
+}
+
+// WithEndpointVerifier makes the wrapped client check the block of every
+// leash it builds against verifier, an endpoint independent of the gateway,
+// so that a compromised gateway can't bind signed queries to a fabricated
+// block. Queries whose leash block hash the two disagree on fail with
+// ErrEndpointDisagreement instead of being signed.
+//
+// A verifier lagging behind the gateway by up to opts.MaxSkew blocks moves
+// leashes for the latest block to the parent of the verifier's latest block
+// instead, as leashes are built on the parent of the latest block.
+func WithEndpointVerifier(verifier VerifierBackend, opts VerifierOptions) Option {
+	return func(b *WrappedBackend) {
+		b.verifier = verifier
+		b.verifierOpts = opts
+	}
+}
+
+// WithKeyManagerKey makes the wrapped client check that the runtime calldata
+// public keys it fetches are signed by the key manager whose runtime signing
+// key is key, e.g. as published in the key manager's status on the consensus
+// layer, so that a compromised gateway can't make it encrypt to a key of its
+// own. Keys with invalid signatures fail with ErrKeySignatureInvalid. The
+// runtime is the one of the chain in Networks.
+//
+// Keys are required to carry a checksum and signature either way, and fail
+// with ErrKeyNotSigned otherwise.
+func WithKeyManagerKey(key signature.PublicKey) Option {
+	return func(b *WrappedBackend) {
+		b.keyManagerKey = &key
+	}
+}
+
+// WithPersistentCache makes the wrapped client keep the runtime calldata
+// public key and the leashes of signed queries in the file at path, for
+// short-lived processes such as command line tools to reuse instead of
+// fetching them on every start. Processes may share the file.
+//
+// Keys are reused for ten minutes after they were fetched, or until the
+// runtime rejects a call encrypted to them, as it does once their epoch has
+// passed. Cached keys are verified like fetched ones, against the key of
+// WithKeyManagerKey if given. Leashes are reused for 30 seconds and only
+// until a transaction is sent from their account by a client sharing the
+// file. Queries the runtime rejects for their leash drop it, so that
+// retrying them builds a fresh one. Leashes are not cached with
+// WithEndpointVerifier. Corrupted files are discarded, and the cache is
+// skipped if the file can't be accessed or locked within the context of the
+// request.
+//
+// The file holds no secrets but should not be writable by others, who could
+// otherwise make the client encrypt to their key unless WithKeyManagerKey is
+// given.
+func WithPersistentCache(path string) Option {
+	return func(b *WrappedBackend) {
+		b.cache = &diskCache{path: path}
+	}
+}
+
+// WithNoncePolicy configures how SendTransaction recovers when the local
+// nonce tracking diverges from the chain. By default, nonce too low and nonce
+// too high errors resync the tracking to the gateway and are returned.
+func WithNoncePolicy(policy NoncePolicy) Option {
+	return func(b *WrappedBackend) {
+		b.noncePolicy = policy
+	}
+}
+
+// WithHeadTracking makes the wrapped client keep the latest header in memory
+// and build leashes for signed queries on it, instead of fetching the latest
+// block for every query.
+//
+// Headers are followed with a newHeads subscription when the connection
+// supports it, e.g. over WebSocket, and polled every interval otherwise. A
+// dropped subscription is retried every interval, with polling in between.
+// Tracking starts with the first signed query and stops on Close.
+func WithHeadTracking(interval time.Duration) Option {
+	return func(b *WrappedBackend) {
+		b.heads = newHeadTracker(interval)
+	}
+}
+
+// WithRPCClientOptions configures the connection made by Dial, e.g. with
+// rpc.WithHTTPAuth or rpc.WithWebsocketDialer. It has no effect on clients
+// passed to WrapClient.
+func WithRPCClientOptions(options ...rpc.ClientOption) Option {
+	return func(b *WrappedBackend) {
+		b.rpcOpts = append(b.rpcOpts, options...)
+	}
+}
+
+// WithHTTPClient makes Dial send HTTP requests with the given client, which
+// allows custom TLS configuration, proxies and connection pooling. Response
+// size limits still apply, unlike with rpc.WithHTTPClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(b *WrappedBackend) {
+		b.httpClient = c
+	}
+}
+
+// WithUserAgent makes Dial identify the client to the gateway with ua in the
+// User-Agent and X-Sapphire-Client headers instead of DefaultUserAgent. An
+// empty ua sends neither header.
+func WithUserAgent(ua string) Option {
+	return func(b *WrappedBackend) {
+		b.userAgent = &ua
+	}
+}
+
+// WithDecodeLimits bounds the size and complexity of gateway responses the
+// wrapped client decodes. Responses exceeding them fail with
+// ErrResponseTooLarge. DefaultDecodeLimits apply otherwise.
+func WithDecodeLimits(limits sapphire.DecodeLimits) Option {
+	return func(b *WrappedBackend) {
+		b.limits = limits
+	}
+}
+
+// WithHeader makes Dial add an HTTP header to every request and to the
+// WebSocket handshake, e.g. for gateway authentication. The value is
+// redacted from errors, see WithHeaderProvider.
+func WithHeader(key, value string) Option {
+	return func(b *WrappedBackend) {
+		b.rpcOpts = append(b.rpcOpts, rpc.WithHeader(key, value))
+		b.mw.redact.add(value)
+	}
+}
+
+// WithBasicAuth makes Dial authenticate to the gateway with HTTP basic
+// authentication. The credentials are redacted from errors.
+func WithBasicAuth(user, pass string) Option {
+	credentials := base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+	header := WithHeader("Authorization", "Basic "+credentials)
+	return func(b *WrappedBackend) {
+		header(b)
+		b.mw.redact.add(credentials, pass)
+	}
+}
+
+// WithHeaderProvider makes Dial ask headers for every HTTP request, e.g.
+// short-lived access tokens. The headers replace those of WithHeader with the
+// same keys. HTTP requests pass their context to headers; WebSocket
+// handshakes, which happen when dialing and reconnecting, a background one.
+// A failing headers fails the request.
+//
+// The values of WithHeader, WithBasicAuth and the most recent ones of
+// WithHeaderProvider, as well as the path, query and user info of the URL,
+// are redacted from the errors the wrapped client returns and passes to the
+// debug hook. Errors that wrap the gateway's response, e.g. rpc.HTTPError,
+// still hold it unredacted.
+func WithHeaderProvider(headers HeaderProvider) Option {
+	return func(b *WrappedBackend) {
+		b.headers = headers
+	}
+}
+
+// withRedactor makes the wrapped client redact errors with r.
+func withRedactor(r *redactor) Option {
+	return func(b *WrappedBackend) {
+		b.mw.redact = r
+	}
+}
+
+// WithDebugHook makes the wrapped client report every call, estimate,
+// transaction and key fetch it sends to hook, for debugging. Plaintext
+// calldata is redacted unless WithUnsafeDebugPlaintext is also given.
+func WithDebugHook(hook DebugHook) Option {
+	return func(b *WrappedBackend) {
+		b.debug = hook
+	}
+}
+
+// WithUnsafeDebugPlaintext includes plaintext calldata in the events passed
+// to the debug hook. Never use it where the events may be logged or shared,
+// as it defeats the confidentiality of the calls.
+func WithUnsafeDebugPlaintext() Option {
+	return func(b *WrappedBackend) {
+		b.debugPlaintext = true
+	}
+}
+
+// WithMulticallAddress makes Multicall use the Multicall3 contract at addr
+// instead of the one listed in Networks for the chain.
+func WithMulticallAddress(addr common.Address) Option {
+	return func(b *WrappedBackend) {
+		b.multicall = &addr
+	}
+}
+
+// WithSignedCallDomain signs queries in the EIP-712 domain instead of
+// DefaultSignedCallDomain, e.g. for a runtime that changed the domain version
+// or a private deployment with a custom domain. Queries signed in a domain the
+// runtime doesn't verify in are rejected as having invalid signatures.
+func WithSignedCallDomain(domain sapphire.SignedCallDomain) Option {
+	return func(b *WrappedBackend) {
+		b.domain = &domain
+	}
+}
+
+// WithMaxSignedCallDataSize changes the maximum calldata size of signed
+// queries from DefaultMaxSignedCallDataSize to n bytes.
+func WithMaxSignedCallDataSize(n int) Option {
+	return func(b *WrappedBackend) {
+		b.maxCallData = n
+	}
+}
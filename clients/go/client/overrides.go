@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// OverrideAccount specifies the state of an account to be overridden for
+// the duration of a call.
+type OverrideAccount = gethclient.OverrideAccount
+
+// CallContractWithOverrides is like CallContract but executes the call with
+// the given accounts' state overridden.
+//
+// The overrides are sent alongside the encrypted, and if the call has a
+// sender signed, call data. They are not confidential.
+func (b *WrappedBackend) CallContractWithOverrides(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int, overrides map[common.Address]OverrideAccount) ([]byte, error) {
+	if b.client == nil {
+		return nil, fmt.Errorf("cannot override state: %w: not created from an ethclient.Client", sapphire.ErrUnsupportedBackend)
+	}
+	cc := newCallContext(ctx, "eth_call", call.To, blockNumber)
+	cipher := b.currentCipher()
+	packedCall, _, err := b.packCall(ctx, cipher, call, blockNumber)
+	if err != nil {
+		return nil, cc.wrap(err)
+	}
+	gc := gethclient.New(b.client.Client())
+	res, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) ([]byte, error) {
+		return gc.CallContract(ctx, *packedCall, blockNumber, &overrides)
+	})
+	b.debugRequest(cc, call.Data, packedCall.Data, hexutil.Bytes(res), err)
+	if err == nil {
+		res, err = cipher.DecryptEncoded(res)
+	}
+	return res, cc.wrap(err)
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+// sloadCode returns the word in storage slot 0.
+var sloadCode = common.FromHex("60005460005260206000f3")
+
+func TestCallContractWithOverridesSigned(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	rt := newRPCTransport()
+	rt.handle("eth_call", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Bytes(cbor.Marshal(sdkTypes.CallResult{Ok: opaqueResult})), nil
+	})
+	b, err := WrapClient(dialTransport(t, rt), nil, WithKeyring(sapphire.NewKeyring(signer)))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	slot, value := common.Hash{}, common.BigToHash(big.NewInt(42))
+	overrides := map[common.Address]OverrideAccount{
+		to: {StateDiff: map[common.Hash]common.Hash{slot: value}},
+	}
+	res, err := b.CallContractWithOverrides(context.Background(), ethereum.CallMsg{From: signer.Address(), To: &to, Data: TestData}, nil, overrides)
+	if err != nil {
+		t.Fatalf("CallContractWithOverrides failed: %v", err)
+	}
+	if !bytes.Equal(res, opaqueResult) {
+		t.Fatalf("unexpected result %x", res)
+	}
+
+	params := rt.recorded("eth_call")[0].Params
+	if len(params) != 3 {
+		t.Fatalf("expected call, block and overrides params, got %d params", len(params))
+	}
+	var args rpcCallArgs
+	if err = json.Unmarshal(params[0], &args); err != nil || args.Input == nil {
+		t.Fatalf("failed to decode call params: %v", err)
+	}
+	var pack evm.SignedCallDataPack
+	if err = cbor.Unmarshal(*args.Input, &pack); err != nil {
+		t.Fatalf("call with overrides did not take the signed query path: %v", err)
+	}
+	var sent map[common.Address]struct {
+		StateDiff map[common.Hash]common.Hash `json:"stateDiff"`
+	}
+	if err = json.Unmarshal(params[2], &sent); err != nil {
+		t.Fatalf("failed to decode overrides: %v", err)
+	}
+	if sent[to].StateDiff[slot] != value {
+		t.Fatalf("overrides were not passed to the gateway: %s", params[2])
+	}
+}
+
+func TestCallContractWithOverridesLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	client := localnet.Dial(t)
+	b, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(sapphire.NewPrivateKeySigner(key))))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	value := common.BigToHash(big.NewInt(42))
+	overrides := map[common.Address]OverrideAccount{
+		to: {
+			Code:      sloadCode,
+			StateDiff: map[common.Hash]common.Hash{{}: value},
+		},
+	}
+	call := ethereum.CallMsg{From: crypto.PubkeyToAddress(key.PublicKey), To: &to}
+	res, err := b.CallContractWithOverrides(context.Background(), call, nil, overrides)
+	if err != nil {
+		t.Fatalf("CallContractWithOverrides failed: %v", err)
+	}
+	if !bytes.Equal(res, value[:]) {
+		t.Fatalf("expected overridden slot value %x, got %x", value, res)
+	}
+}
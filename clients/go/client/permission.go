@@ -0,0 +1,262 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// ErrInvalidPermission is returned for permissions a relayer contract would
+// reject, e.g. because they expired or were not signed by their from
+// account.
+var ErrInvalidPermission = errors.New("invalid permission")
+
+// Permission authorizes a relayer contract to execute a call on behalf of
+// From. The contract pays for the transaction, checks the signature, nonce
+// and deadline, and then calls To.
+type Permission struct {
+	From  common.Address
+	To    common.Address
+	Value *big.Int
+	Data  []byte
+	// Nonce is From's nonce in the relayer contract, which it increments on
+	// every execution so that a permission can be used only once.
+	Nonce *big.Int
+	// Deadline is the Unix time in seconds after which the permission
+	// expires, 0 for never.
+	Deadline uint64
+}
+
+// PermissionField identifies a field of Permission.
+type PermissionField int
+
+const (
+	PermissionFrom PermissionField = iota
+	PermissionTo
+	PermissionValue
+	PermissionData
+	PermissionNonce
+	PermissionDeadline
+)
+
+// PermissionSchemaField is a field of the EIP-712 message a relayer contract
+// verifies, carrying a field of Permission.
+type PermissionSchemaField struct {
+	// Name and Type are the field's EIP-712 name and type.
+	Name, Type string
+	// Field is the Permission field carried.
+	Field PermissionField
+}
+
+// PermissionSchema describes the EIP-712 message of a relayer contract and
+// its execute function, as contracts differ in naming and field order.
+type PermissionSchema struct {
+	// Name and Version of the EIP-712 domain, which is bound to the chain
+	// and relayer contract.
+	Name, Version string
+	// PrimaryType is the name of the message type.
+	PrimaryType string
+	// Fields are the fields of the message, in the contract's order.
+	Fields []PermissionSchemaField
+	// Execute is the name of the contract's function executing a
+	// permission. It takes the message's fields in order as its arguments,
+	// followed by the bytes signature.
+	Execute string
+}
+
+// DefaultPermissionSchema is the schema of the relayer contract example in
+// the Sapphire documentation.
+var DefaultPermissionSchema = PermissionSchema{
+	Name:        "SapphireRelayer",
+	Version:     "1",
+	PrimaryType: "Permission",
+	Fields: []PermissionSchemaField{
+		{"from", "address", PermissionFrom},
+		{"to", "address", PermissionTo},
+		{"value", "uint256", PermissionValue},
+		{"data", "bytes", PermissionData},
+		{"nonce", "uint256", PermissionNonce},
+		{"deadline", "uint256", PermissionDeadline},
+	},
+	Execute: "execute",
+}
+
+// TypedData returns the EIP-712 typed data of p for the relayer contract at
+// relayer on chain chainID.
+func (s PermissionSchema) TypedData(chainID *big.Int, relayer common.Address, p *Permission) (apitypes.TypedData, error) {
+	if err := sapphire.ValidateChainID(chainID); err != nil {
+		return apitypes.TypedData{}, fmt.Errorf("%w: %v", ErrInvalidPermission, err)
+	}
+	fields := make([]apitypes.Type, len(s.Fields))
+	message := make(apitypes.TypedDataMessage, len(s.Fields))
+	for i, f := range s.Fields {
+		v, err := p.value(f.Field)
+		if err != nil {
+			return apitypes.TypedData{}, err
+		}
+		fields[i] = apitypes.Type{Name: f.Name, Type: f.Type}
+		switch v := v.(type) {
+		case common.Address:
+			message[f.Name] = v.Hex()
+		case *big.Int:
+			message[f.Name] = (*math.HexOrDecimal256)(v)
+		default:
+			message[f.Name] = v
+		}
+	}
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			s.PrimaryType: fields,
+		},
+		PrimaryType: s.PrimaryType,
+		Domain: apitypes.TypedDataDomain{
+			Name:              s.Name,
+			Version:           s.Version,
+			ChainId:           (*math.HexOrDecimal256)(new(big.Int).Set(chainID)),
+			VerifyingContract: relayer.Hex(),
+		},
+		Message: message,
+	}, nil
+}
+
+// Sign signs p with signer, which must be the key of p.From, for the relayer
+// contract at relayer on chain chainID. The signature's V is 27 or 28, as
+// Solidity's ecrecover expects.
+func (s PermissionSchema) Sign(ctx context.Context, signer sapphire.Signer, chainID *big.Int, relayer common.Address, p *Permission) ([]byte, error) {
+	typedData, err := s.TypedData(chainID, relayer, p)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := sapphire.SignedCallDigest(typedData)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := sapphire.SignDigest(ctx, signer, digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign permission: %w", err)
+	}
+	return sapphire.NormalizeV(signature)
+}
+
+// Verify checks p as the relayer contract at relayer would before executing
+// it at time now: that signature was made by p.From and p has not expired.
+// It can't check the nonce, which only the contract knows.
+func (s PermissionSchema) Verify(chainID *big.Int, relayer common.Address, p *Permission, signature []byte, now time.Time) error {
+	if p.Deadline != 0 && uint64(now.Unix()) > p.Deadline {
+		return fmt.Errorf("%w: expired at %s", ErrInvalidPermission, time.Unix(int64(p.Deadline), 0).UTC())
+	}
+	typedData, err := s.TypedData(chainID, relayer, p)
+	if err != nil {
+		return err
+	}
+	signer, err := sapphire.RecoverCaller(typedData, signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPermission, err)
+	}
+	if signer != p.From {
+		return fmt.Errorf("%w: signed by %s, not %s", ErrInvalidPermission, signer.Hex(), p.From.Hex())
+	}
+	return nil
+}
+
+// ExecuteData returns the calldata of a call to the relayer contract's
+// execute function with p and its signature.
+func (s PermissionSchema) ExecuteData(p *Permission, signature []byte) ([]byte, error) {
+	args := make(abi.Arguments, 0, len(s.Fields)+1)
+	values := make([]interface{}, 0, len(s.Fields)+1)
+	types := make([]string, 0, len(s.Fields)+1)
+	for _, f := range append(s.Fields, PermissionSchemaField{Name: "signature", Type: "bytes", Field: -1}) {
+		typ, err := abi.NewType(f.Type, "", nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %s: %v", ErrInvalidPermission, f.Name, err)
+		}
+		v := interface{}(signature)
+		if f.Field >= 0 {
+			if v, err = p.value(f.Field); err != nil {
+				return nil, err
+			}
+			if v, err = permissionArgument(f, typ, v); err != nil {
+				return nil, err
+			}
+		}
+		args = append(args, abi.Argument{Name: f.Name, Type: typ})
+		values = append(values, v)
+		types = append(types, f.Type)
+	}
+	packed, err := args.Pack(values...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPermission, err)
+	}
+	selector := crypto.Keccak256([]byte(s.Execute + "(" + strings.Join(types, ",") + ")"))[:4]
+	return append(selector, packed...), nil
+}
+
+// permissionArgument converts v, as returned by Permission.value, to the Go
+// type the ABI packs typ from. Integers narrower than 64 bits are native Go
+// integers rather than *big.Int.
+func permissionArgument(f PermissionSchemaField, typ abi.Type, v interface{}) (interface{}, error) {
+	n, ok := v.(*big.Int)
+	goType := typ.GetType()
+	if !ok || goType == reflect.TypeOf(n) {
+		return v, nil
+	}
+	arg := reflect.New(goType).Elem()
+	switch {
+	case typ.T == abi.UintTy && n.IsUint64() && !arg.OverflowUint(n.Uint64()):
+		arg.SetUint(n.Uint64())
+	case typ.T == abi.IntTy && n.IsInt64() && !arg.OverflowInt(n.Int64()):
+		arg.SetInt(n.Int64())
+	default:
+		return nil, fmt.Errorf("%w: field %s: %s does not fit in %s", ErrInvalidPermission, f.Name, n, f.Type)
+	}
+	return arg.Interface(), nil
+}
+
+// value returns field f of p as the ABI packs it.
+func (p *Permission) value(f PermissionField) (interface{}, error) {
+	uint256 := func(name string, v *big.Int) (interface{}, error) {
+		if v == nil {
+			return new(big.Int), nil
+		}
+		if v.Sign() < 0 || v.BitLen() > 256 {
+			return nil, fmt.Errorf("%w: %s %s does not fit in uint256", ErrInvalidPermission, name, v)
+		}
+		return v, nil
+	}
+	switch f {
+	case PermissionFrom:
+		return p.From, nil
+	case PermissionTo:
+		return p.To, nil
+	case PermissionValue:
+		return uint256("value", p.Value)
+	case PermissionData:
+		if p.Data == nil {
+			return []byte{}, nil
+		}
+		return p.Data, nil
+	case PermissionNonce:
+		return uint256("nonce", p.Nonce)
+	case PermissionDeadline:
+		return new(big.Int).SetUint64(p.Deadline), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown field %d", ErrInvalidPermission, f)
+	}
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+func testPermission(from common.Address) *Permission {
+	return &Permission{
+		From:     from,
+		To:       common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883"),
+		Value:    big.NewInt(1000),
+		Data:     []byte{0xe2, 0x1f, 0x37, 0xce},
+		Nonce:    big.NewInt(4),
+		Deadline: 1_700_000_000,
+	}
+}
+
+func TestPermissionSign(t *testing.T) {
+	ctx := context.Background()
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	chainID := big.NewInt(0x5afd)
+	relayer := common.HexToAddress("0x000000000000000000000000000000000000beef")
+	p := testPermission(signer.Address())
+	now := time.Unix(1_600_000_000, 0)
+
+	custom := PermissionSchema{
+		Name:        "Forwarder",
+		Version:     "2",
+		PrimaryType: "ForwardRequest",
+		Fields: []PermissionSchemaField{
+			{"nonce", "uint256", PermissionNonce},
+			{"from", "address", PermissionFrom},
+			{"to", "address", PermissionTo},
+			{"data", "bytes", PermissionData},
+			{"validUntil", "uint64", PermissionDeadline},
+		},
+		Execute: "forward",
+	}
+	for _, schema := range []PermissionSchema{DefaultPermissionSchema, custom} {
+		sig, err := schema.Sign(ctx, signer, chainID, relayer, p)
+		if err != nil {
+			t.Fatalf("%s: failed to sign: %v", schema.PrimaryType, err)
+		}
+		if v := sig[64]; v != 27 && v != 28 {
+			t.Fatalf("%s: expected V of 27 or 28, got %d", schema.PrimaryType, v)
+		}
+
+		// The signature verifies with go-ethereum's own EIP-712 hashing, as
+		// the contract's would.
+		typedData, err := schema.TypedData(chainID, relayer, p)
+		if err != nil {
+			t.Fatalf("%s: failed to build typed data: %v", schema.PrimaryType, err)
+		}
+		hash, _, err := apitypes.TypedDataAndHash(typedData)
+		if err != nil {
+			t.Fatalf("%s: failed to hash typed data: %v", schema.PrimaryType, err)
+		}
+		rsv := append([]byte(nil), sig...)
+		rsv[64] -= 27
+		pub, err := crypto.SigToPub(hash, rsv)
+		if err != nil || crypto.PubkeyToAddress(*pub) != signer.Address() {
+			t.Fatalf("%s: signature does not recover to the signer: %v", schema.PrimaryType, err)
+		}
+		if err = schema.Verify(chainID, relayer, p, sig, now); err != nil {
+			t.Fatalf("%s: failed to verify: %v", schema.PrimaryType, err)
+		}
+
+		if _, err = schema.ExecuteData(p, sig); err != nil {
+			t.Fatalf("%s: failed to pack execute call: %v", schema.PrimaryType, err)
+		}
+
+		// The domain binds the signature to the chain and relayer.
+		other := common.HexToAddress("0x000000000000000000000000000000000000dead")
+		if err = schema.Verify(chainID, other, p, sig, now); !errors.Is(err, ErrInvalidPermission) {
+			t.Fatalf("%s: expected another relayer to be rejected, got %v", schema.PrimaryType, err)
+		}
+		if err = schema.Verify(big.NewInt(0x5aff), relayer, p, sig, now); !errors.Is(err, ErrInvalidPermission) {
+			t.Fatalf("%s: expected another chain to be rejected, got %v", schema.PrimaryType, err)
+		}
+	}
+}
+
+func TestPermissionVerify(t *testing.T) {
+	ctx := context.Background()
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	chainID := big.NewInt(0x5afd)
+	relayer := common.HexToAddress("0x000000000000000000000000000000000000beef")
+	p := testPermission(signer.Address())
+	sig, err := DefaultPermissionSchema.Sign(ctx, signer, chainID, relayer, p)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	if err = DefaultPermissionSchema.Verify(chainID, relayer, p, sig, time.Unix(int64(p.Deadline)+1, 0)); !errors.Is(err, ErrInvalidPermission) {
+		t.Fatalf("expected an expired permission to be rejected, got %v", err)
+	}
+	noDeadline := *p
+	noDeadline.Deadline = 0
+	if err = DefaultPermissionSchema.Verify(chainID, relayer, &noDeadline, sig, time.Now()); !errors.Is(err, ErrInvalidPermission) {
+		t.Fatalf("expected a changed permission to be rejected, got %v", err)
+	}
+	if sig, err = DefaultPermissionSchema.Sign(ctx, signer, chainID, relayer, &noDeadline); err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if err = DefaultPermissionSchema.Verify(chainID, relayer, &noDeadline, sig, time.Unix(1<<40, 0)); err != nil {
+		t.Fatalf("expected a permission without deadline not to expire, got %v", err)
+	}
+
+	// Permissions signed by another key than From's are rejected.
+	other := *p
+	other.From = common.HexToAddress("0x000000000000000000000000000000000000dead")
+	if sig, err = DefaultPermissionSchema.Sign(ctx, signer, chainID, relayer, &other); err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if err = DefaultPermissionSchema.Verify(chainID, relayer, &other, sig, time.Unix(0, 0)); !errors.Is(err, ErrInvalidPermission) {
+		t.Fatalf("expected a permission signed by another key to be rejected, got %v", err)
+	}
+
+	invalid := *p
+	invalid.Nonce = big.NewInt(-1)
+	if _, err = DefaultPermissionSchema.Sign(ctx, signer, chainID, relayer, &invalid); !errors.Is(err, ErrInvalidPermission) {
+		t.Fatalf("expected a negative nonce to be rejected, got %v", err)
+	}
+}
+
+func TestPermissionExecuteData(t *testing.T) {
+	p := testPermission(common.HexToAddress("0x000000000000000000000000000000000000cafe"))
+	sig := bytes.Repeat([]byte{1}, 65)
+	data, err := DefaultPermissionSchema.ExecuteData(p, sig)
+	if err != nil {
+		t.Fatalf("failed to pack execute call: %v", err)
+	}
+	parsed, err := abi.JSON(bytes.NewReader([]byte(`[{"type":"function","name":"execute","inputs":[
+		{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"value","type":"uint256"},
+		{"name":"data","type":"bytes"},{"name":"nonce","type":"uint256"},{"name":"deadline","type":"uint256"},
+		{"name":"signature","type":"bytes"}]}]`)))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	expected, err := parsed.Pack("execute", p.From, p.To, p.Value, p.Data, p.Nonce, new(big.Int).SetUint64(p.Deadline), sig)
+	if err != nil {
+		t.Fatalf("failed to pack expected call: %v", err)
+	}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("expected calldata %x, got %x", expected, data)
+	}
+
+	narrow := DefaultPermissionSchema
+	narrow.Fields = []PermissionSchemaField{{"deadline", "uint8", PermissionDeadline}}
+	if _, err = narrow.ExecuteData(p, sig); !errors.Is(err, ErrInvalidPermission) {
+		t.Fatalf("expected a deadline overflowing its field to be rejected, got %v", err)
+	}
+
+	invalid := DefaultPermissionSchema
+	invalid.Fields = append([]PermissionSchemaField{{"from", "uint999", PermissionFrom}}, invalid.Fields[1:]...)
+	if _, err = invalid.ExecuteData(p, sig); !errors.Is(err, ErrInvalidPermission) {
+		t.Fatalf("expected an invalid field type to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// plaintextStoreSize is the number of transactions whose plaintext calldata
+// is remembered.
+const plaintextStoreSize = 1024
+
+// plaintextStore remembers the plaintext calldata of the most recent
+// transactions encrypted by the wrapped client, so that they can be replayed
+// or re-encrypted later, and the transactions and ciphers they were sent
+// with, for ExportArtifact.
+type plaintextStore struct {
+	mu      sync.Mutex
+	data    map[common.Hash]plaintextEntry
+	order   []common.Hash
+	next    int
+	maxSize int
+}
+
+type plaintextEntry struct {
+	plaintext []byte
+	tx        *types.Transaction
+	cipher    sapphire.Cipher
+}
+
+func newPlaintextStore(size int) *plaintextStore {
+	return &plaintextStore{
+		data:    make(map[common.Hash]plaintextEntry, size),
+		order:   make([]common.Hash, 0, size),
+		maxSize: size,
+	}
+}
+
+// put records the plaintext calldata of the transaction, evicting the oldest
+// entry if the store is full.
+func (s *plaintextStore) put(txHash common.Hash, plaintext []byte) {
+	s.putEntry(txHash, plaintextEntry{plaintext: common.CopyBytes(plaintext)})
+}
+
+// putSigned records the plaintext calldata of a transaction signed by the
+// wrapped client, and the cipher it was encrypted with.
+func (s *plaintextStore) putSigned(tx *types.Transaction, plaintext []byte, cipher sapphire.Cipher) {
+	s.putEntry(tx.Hash(), plaintextEntry{plaintext: common.CopyBytes(plaintext), tx: tx, cipher: cipher})
+}
+
+func (s *plaintextStore) putEntry(txHash common.Hash, entry plaintextEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[txHash]; ok {
+		return
+	}
+	if len(s.order) < s.maxSize {
+		s.order = append(s.order, txHash)
+	} else {
+		delete(s.data, s.order[s.next])
+		s.order[s.next] = txHash
+		s.next = (s.next + 1) % s.maxSize
+	}
+	s.data[txHash] = entry
+}
+
+// get returns the plaintext calldata of the transaction, if it is known.
+func (s *plaintextStore) get(txHash common.Hash) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.data[txHash]
+	return common.CopyBytes(entry.plaintext), ok
+}
+
+// signed returns the entry of a transaction recorded with putSigned.
+func (s *plaintextStore) signed(txHash common.Hash) (plaintextEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.data[txHash]
+	if !ok || entry.tx == nil {
+		return plaintextEntry{}, false
+	}
+	entry.plaintext = common.CopyBytes(entry.plaintext)
+	return entry, true
+}
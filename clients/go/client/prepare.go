@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// PreparedQuery is a signed and encrypted query that has not been sent yet.
+type PreparedQuery struct {
+	// Call is the call message as it will be sent to the gateway. Its Data
+	// field holds the encoded signed query.
+	Call ethereum.CallMsg
+	// Leash is the leash the query signature is bound to.
+	Leash evm.Leash
+
+	cipher sapphire.Cipher
+}
+
+// Data returns the encoded signed query.
+func (q *PreparedQuery) Data() []byte {
+	return q.Call.Data
+}
+
+// ValidUntil returns the last block number at which the runtime still accepts
+// the query's leash. Submitting the query afterwards will fail.
+func (q *PreparedQuery) ValidUntil() uint64 {
+	return q.Leash.BlockNumber + q.Leash.BlockRange
+}
+
+// PrepareTransaction runs the full confidential transaction pipeline on tx,
+// encrypting and signing it on behalf of from, but does not send it.
+//
+// The returned transaction can be sent later with SubmitPreparedTransaction
+// or any other client. Its calldata is encrypted to the runtime's ephemeral
+// key of the current epoch and must be submitted before that key expires.
+func (b *WrappedBackend) PrepareTransaction(ctx context.Context, from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	return b.signTx(ctx, from, tx)
+}
+
+// PrepareSignedQuery runs the full signed query pipeline on msg, building the
+// leash, signing and encrypting the call, but does not send it.
+//
+// msg.From must be an account the wrapped client can sign for.
+func (b *WrappedBackend) PrepareSignedQuery(ctx context.Context, msg ethereum.CallMsg) (*PreparedQuery, error) {
+	if msg.From == (common.Address{}) {
+		return nil, fmt.Errorf("%w: signed query requires a sender", sapphire.ErrNoSigner)
+	}
+	cipher := b.currentCipher()
+	packedCall, leash, err := b.packCall(ctx, cipher, msg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedQuery{
+		Call:   *packedCall,
+		Leash:  *leash,
+		cipher: cipher,
+	}, nil
+}
+
+// SubmitPreparedTransaction sends a transaction returned by PrepareTransaction.
+func (b *WrappedBackend) SubmitPreparedTransaction(ctx context.Context, tx *types.Transaction) error {
+	if txNeedsPacking(tx) {
+		return fmt.Errorf("%w: submitting transaction %s", sapphire.ErrUnencryptedCalldata, tx.Hash().Hex())
+	}
+	return b.SendTransaction(ctx, tx)
+}
+
+// SubmitPreparedQuery sends a query returned by PrepareSignedQuery and
+// decrypts its result with the cipher the query was prepared with.
+func (b *WrappedBackend) SubmitPreparedQuery(ctx context.Context, q *PreparedQuery) ([]byte, error) {
+	cc := newCallContext(ctx, "eth_call", q.Call.To, nil)
+	res, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) ([]byte, error) {
+		return b.backend.CallContract(ctx, q.Call, nil)
+	})
+	b.debugRequest(cc, nil, q.Call.Data, hexutil.Bytes(res), err)
+	if err == nil {
+		res, err = q.cipher.DecryptEncoded(res)
+	}
+	return res, cc.wrap(err)
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	mathRand "math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// newSeededCipher returns a deoxysii cipher whose nonces come from a seeded RNG.
+func newSeededCipher(t *testing.T, seed int64) *sapphire.X25519DeoxysIICipher {
+	pair := sapphire.Curve25519KeyPair{
+		PublicKey: x25519.PublicKey(common.Hex2Bytes("3046db3fa70ce605457dc47c48837ebd8bd0a26abfde5994d033e1ced68e2576")),
+		SecretKey: x25519.PrivateKey(common.Hex2Bytes("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")),
+	}
+	cipher, err := sapphire.NewX25519DeoxysIICipherWithRand(&pair, &pair.PublicKey, 42, mathRand.New(mathRand.NewSource(seed))) //nolint:gosec
+	if err != nil {
+		t.Fatalf("could not init deoxysii cipher: %v", err)
+	}
+	return cipher
+}
+
+func TestPrepareTransaction(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	from := signer.Address()
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	mock := newMockBackend()
+	b := newMockWrappedBackend(mock, nil, WithKeyring(sapphire.NewKeyring(signer)))
+	tx := types.NewTransaction(3, to, big.NewInt(1), 100_000, big.NewInt(sapphire.DefaultGasPrice), []byte{0xe2, 0x1f, 0x37, 0xce})
+
+	b.cipher = newSeededCipher(t, 1)
+	prepared, err := b.PrepareTransaction(ctx, from, tx)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction: %v", err)
+	}
+	if len(mock.sentTransactions()) != 0 {
+		t.Fatalf("prepare must not send anything")
+	}
+
+	b.cipher = newSeededCipher(t, 1)
+	sent, err := b.Transactor(from).Signer(from, tx)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+
+	preparedRaw, _ := prepared.MarshalBinary()
+	sentRaw, _ := sent.MarshalBinary()
+	if !bytes.Equal(preparedRaw, sentRaw) {
+		t.Fatalf("prepared tx differs from normally signed tx:\n%x\n%x", preparedRaw, sentRaw)
+	}
+
+	if err = b.SubmitPreparedTransaction(ctx, prepared); err != nil {
+		t.Fatalf("failed to submit prepared transaction: %v", err)
+	}
+	if got := mock.sentTransactions(); len(got) != 1 || got[0].Hash() != prepared.Hash() {
+		t.Fatalf("prepared transaction was not submitted")
+	}
+
+	if err = b.SubmitPreparedTransaction(ctx, tx); err == nil {
+		t.Fatalf("submitting an unencrypted transaction should fail")
+	}
+}
+
+func TestPrepareSignedQuery(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	msg := ethereum.CallMsg{From: signer.Address(), To: &to, Data: []byte{0xe2, 0x1f, 0x37, 0xce}}
+	ctx := context.Background()
+
+	mock := newMockBackend()
+	mock.callResult = cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(TestData)})
+	b := newMockWrappedBackend(mock, nil, WithKeyring(sapphire.NewKeyring(signer)))
+	b.cipher = sapphire.NewPlainCipher()
+
+	prepared, err := b.PrepareSignedQuery(ctx, msg)
+	if err != nil {
+		t.Fatalf("failed to prepare signed query: %v", err)
+	}
+	if len(mock.receivedCalls()) != 0 {
+		t.Fatalf("prepare must not call the gateway")
+	}
+	if prepared.ValidUntil() != 99+sapphire.DefaultBlockRange {
+		t.Fatalf("unexpected leash expiry: %d", prepared.ValidUntil())
+	}
+
+	if _, err = b.CallContract(ctx, msg, nil); err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	calls := mock.receivedCalls()
+	if len(calls) != 1 || !bytes.Equal(calls[0].Data, prepared.Data()) {
+		t.Fatalf("prepared query differs from the normally sent query")
+	}
+
+	res, err := b.SubmitPreparedQuery(ctx, prepared)
+	if err != nil {
+		t.Fatalf("failed to submit prepared query: %v", err)
+	}
+	if !bytes.Equal(res, TestData) {
+		t.Fatalf("unexpected query result: %x", res)
+	}
+
+	if _, err = b.PrepareSignedQuery(ctx, ethereum.CallMsg{To: &to}); err == nil {
+		t.Fatalf("preparing a query without sender should fail")
+	}
+}
+
+func TestPrepareSignedQueryEncrypted(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	msg := ethereum.CallMsg{From: signer.Address(), To: &to, Data: []byte{0xe2, 0x1f, 0x37, 0xce}}
+	ctx := context.Background()
+
+	mock := newMockBackend()
+	b := newMockWrappedBackend(mock, nil, WithKeyring(sapphire.NewKeyring(signer)))
+
+	b.cipher = newSeededCipher(t, 7)
+	prepared, err := b.PrepareSignedQuery(ctx, msg)
+	if err != nil {
+		t.Fatalf("failed to prepare signed query: %v", err)
+	}
+
+	b.cipher = newSeededCipher(t, 7)
+	if _, err = b.CallContract(ctx, msg, nil); err == nil {
+		t.Fatalf("expected decoding of the empty mock result to fail")
+	}
+	calls := mock.receivedCalls()
+	if len(calls) != 1 || !bytes.Equal(calls[0].Data, prepared.Data()) {
+		t.Fatalf("prepared encrypted query differs from the normally sent query")
+	}
+}
@@ -0,0 +1,360 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	mathRand "math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	"github.com/oasisprotocol/deoxysii"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/crypto/signature"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTesting "github.com/oasisprotocol/oasis-sdk/client-sdk/go/testing"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"pgregory.net/rapid"
+)
+
+// The property tests check round trips on generated inputs, which rapid
+// shrinks to a minimal one on failure and saves under testdata/rapid, from
+// where later runs replay it. Commit such files along with the fix, as
+// regression cases. Inputs known to be nasty run first in every test.
+
+// cborBoundarySizes are sizes around those at which CBOR byte strings switch
+// to a longer length prefix.
+var cborBoundarySizes = []int{0, 1, 22, 23, 24, 25, 254, 255, 256, 257, 65534, 65535, 65536, 65537}
+
+// uint64Edges are the uint64 values most likely to be mishandled.
+var uint64Edges = []uint64{0, 1, 23, 24, math.MaxUint32, math.MaxUint32 + 1, math.MaxInt64, math.MaxInt64 + 1, math.MaxUint64 - 1, math.MaxUint64}
+
+// maxPayloadSize is the largest payload encrypted.
+const maxPayloadSize = 4 << 20
+
+// payload is a byte string drawn as its size and a seed to fill it from, so
+// that large ones are cheap to generate and shrink.
+type payload struct {
+	Size int
+	Seed int64
+}
+
+func (p payload) bytes() []byte {
+	b := make([]byte, p.Size)
+	mathRand.New(mathRand.NewSource(p.Seed)).Read(b) //nolint:gosec
+	return b
+}
+
+// payloadGen draws payloads of up to maxSize bytes, mostly small ones.
+func payloadGen(maxSize int) *rapid.Generator[payload] {
+	return rapid.Custom(func(t *rapid.T) payload {
+		size := rapid.OneOf(
+			rapid.SampledFrom(cborBoundarySizes),
+			rapid.IntRange(0, 1024),
+			rapid.IntRange(0, 1024),
+			rapid.IntRange(0, maxSize),
+		).Filter(func(n int) bool { return n <= maxSize }).Draw(t, "size")
+		return payload{Size: size, Seed: rapid.Int64().Draw(t, "seed")}
+	})
+}
+
+// uint64Gen draws from the whole uint64 range, its edges included.
+var uint64Gen = rapid.OneOf(rapid.SampledFrom(uint64Edges), rapid.Uint64())
+
+// bytesN draws n bytes.
+func bytesN(n int) *rapid.Generator[[]byte] {
+	return rapid.SliceOfN(rapid.Byte(), n, n)
+}
+
+// uint256Gen draws nil or a uint256.
+var uint256Gen = rapid.OneOf(
+	rapid.Just[*big.Int](nil),
+	rapid.Map(bytesN(32), func(b []byte) *big.Int { return new(big.Int).SetBytes(b) }),
+	rapid.Map(uint64Gen, func(v uint64) *big.Int { return new(big.Int).SetUint64(v) }),
+)
+
+// secp256k1KeyGen draws valid secp256k1 private keys.
+var secp256k1KeyGen = rapid.Custom(func(t *rapid.T) []byte {
+	return bytesN(32).Filter(func(b []byte) bool {
+		_, err := crypto.ToECDSA(b)
+		return err == nil
+	}).Draw(t, "key")
+})
+
+// envelopeInput is an encrypted call and its result.
+type envelopeInput struct {
+	CallerKey, RuntimeKey []byte
+	Epoch                 uint64
+	Call, Result          payload
+}
+
+func checkEnvelopeRoundTrip(t rapid.TB, in envelopeInput) {
+	t.Helper()
+	callerPair := &sapphire.Curve25519KeyPair{SecretKey: x25519.PrivateKey(in.CallerKey)}
+	callerPair.PublicKey = *callerPair.SecretKey.Public()
+	runtimePair := &sapphire.Curve25519KeyPair{SecretKey: x25519.PrivateKey(in.RuntimeKey)}
+	runtimePair.PublicKey = *runtimePair.SecretKey.Public()
+	caller, err := sapphire.NewX25519DeoxysIICipher(callerPair, &runtimePair.PublicKey, in.Epoch)
+	if err != nil {
+		t.Fatalf("failed to create caller cipher: %v", err)
+	}
+
+	data := in.Call.bytes()
+	envelope := caller.EncryptEnvelope(data)
+	if envelope == nil {
+		if len(data) != 0 {
+			t.Fatalf("no envelope for %d bytes", len(data))
+		}
+	} else {
+		var call sdkTypes.Call
+		var body sdkTypes.CallEnvelopeX25519DeoxysII
+		if err = cbor.Unmarshal(caller.EncryptEncode(data), &call); err != nil || cbor.Unmarshal(call.Body, &body) != nil {
+			t.Fatalf("malformed envelope: %v", err)
+		}
+		if body.Epoch != in.Epoch || body.Pk != callerPair.PublicKey {
+			t.Fatalf("envelope for epoch %d and key %x, expected %d and %x", body.Epoch, body.Pk, in.Epoch, callerPair.PublicKey)
+		}
+		runtime, err := sapphire.NewX25519DeoxysIICipher(runtimePair, &body.Pk, body.Epoch)
+		if err != nil {
+			t.Fatalf("failed to create runtime cipher: %v", err)
+		}
+		plaintext, err := runtime.Decrypt(body.Nonce[:], body.Data)
+		if err != nil {
+			t.Fatalf("runtime failed to decrypt call: %v", err)
+		}
+		var decrypted []byte
+		if err = cbor.Unmarshal(plaintext, &call); err == nil {
+			err = cbor.Unmarshal(call.Body, &decrypted)
+		}
+		if err != nil || !bytes.Equal(decrypted, data) {
+			t.Fatalf("call of %d bytes decrypted to %d bytes: %v", len(data), len(decrypted), err)
+		}
+	}
+
+	// The runtime encrypts the result with the key it shares with the caller.
+	runtime, err := sapphire.NewX25519DeoxysIICipher(runtimePair, &callerPair.PublicKey, in.Epoch)
+	if err != nil {
+		t.Fatalf("failed to create runtime cipher: %v", err)
+	}
+	result := in.Result.bytes()
+	sealed, nonce := runtime.Encrypt(cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(result)}))
+	response := cbor.Marshal(sdkTypes.CallResult{Ok: cbor.Marshal(sdkTypes.ResultEnvelopeX25519DeoxysII{
+		Nonce: [deoxysii.NonceSize]byte(nonce),
+		Data:  sealed,
+	})})
+	decrypted, err := caller.DecryptCallResult(response)
+	if err != nil || !bytes.Equal(decrypted, result) {
+		t.Fatalf("result of %d bytes decrypted to %d bytes: %v", len(result), len(decrypted), err)
+	}
+}
+
+func TestPropertyEnvelopeRoundTrip(t *testing.T) {
+	for _, size := range append([]int{maxPayloadSize}, cborBoundarySizes...) {
+		checkEnvelopeRoundTrip(t, envelopeInput{
+			CallerKey:  bytes.Repeat([]byte{1}, 32),
+			RuntimeKey: bytes.Repeat([]byte{2}, 32),
+			Epoch:      math.MaxUint64,
+			Call:       payload{Size: size},
+			Result:     payload{Size: size},
+		})
+	}
+	rapid.Check(t, func(t *rapid.T) {
+		checkEnvelopeRoundTrip(t, envelopeInput{
+			CallerKey:  bytesN(32).Draw(t, "callerKey"),
+			RuntimeKey: bytesN(32).Draw(t, "runtimeKey"),
+			Epoch:      uint64Gen.Draw(t, "epoch"),
+			Call:       payloadGen(maxPayloadSize).Draw(t, "call"),
+			Result:     payloadGen(maxPayloadSize).Draw(t, "result"),
+		})
+	})
+}
+
+// signedCallInput is a signed query.
+type signedCallInput struct {
+	Key                 []byte
+	ChainID             uint64
+	To                  *common.Address
+	GasLimit            uint64
+	GasPrice, Value     *big.Int
+	Data                payload
+	Nonce, Block, Range uint64
+	BlockHash           []byte
+}
+
+func (in signedCallInput) leash() evm.Leash {
+	return evm.Leash{Nonce: in.Nonce, BlockNumber: in.Block, BlockHash: in.BlockHash, BlockRange: in.Range}
+}
+
+// checkSignedCallRoundTrip checks that the signature of a query recovers to
+// its signer and that the query decodes to itself.
+func checkSignedCallRoundTrip(t rapid.TB, in signedCallInput) {
+	t.Helper()
+	key, err := crypto.ToECDSA(in.Key)
+	if err != nil {
+		t.Fatalf("invalid key: %v", err)
+	}
+	signer := sapphire.NewPrivateKeySigner(key)
+	data := in.Data.bytes()
+	pack, err := sapphire.NewSignedCall(context.Background(), signer, new(big.Int).SetUint64(in.ChainID), in.To, data,
+		sapphire.WithLeash(in.leash()), sapphire.WithGasLimit(in.GasLimit), sapphire.WithGasPrice(in.GasPrice), sapphire.WithValue(in.Value))
+	if len(data) > sapphire.DefaultMaxSignedCallDataSize {
+		if !errors.Is(err, sapphire.ErrInvalidSignedCall) {
+			t.Fatalf("expected %d bytes of data to be refused, got %v", len(data), err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("NewSignedCall failed: %v", err)
+	}
+
+	digest, err := sapphire.SignedCallDigest(sapphire.SignableCall(in.ChainID, signer.Address(), in.To, in.GasLimit, in.GasPrice, in.Value, data, in.leash()))
+	if err != nil {
+		t.Fatalf("SignedCallDigest failed: %v", err)
+	}
+	sig := common.CopyBytes(pack.Signature)
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(digest[:], sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != signer.Address() {
+		t.Fatalf("signature does not recover to the signer: %v", err)
+	}
+
+	decoded, err := sapphire.DecodeDataPack(sapphire.EncodeDataPack(pack))
+	if err != nil || !reflect.DeepEqual(decoded, pack) || decoded.Leash.Nonce != in.Nonce || decoded.Leash.BlockRange != in.Range {
+		t.Fatalf("signed query does not decode to itself: %v", err)
+	}
+}
+
+func TestPropertySignedCallRoundTrip(t *testing.T) {
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	maxUint256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	for _, size := range append([]int{sapphire.DefaultMaxSignedCallDataSize, sapphire.DefaultMaxSignedCallDataSize + 1}, cborBoundarySizes...) {
+		checkSignedCallRoundTrip(t, signedCallInput{
+			Key:       common.Hex2Bytes("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750"),
+			ChainID:   math.MaxUint64,
+			To:        &to,
+			GasLimit:  math.MaxUint64,
+			GasPrice:  maxUint256,
+			Value:     maxUint256,
+			Data:      payload{Size: size},
+			Nonce:     math.MaxUint64,
+			Block:     math.MaxUint64,
+			Range:     math.MaxUint64,
+			BlockHash: bytes.Repeat([]byte{0xff}, 32),
+		})
+	}
+	rapid.Check(t, func(t *rapid.T) {
+		in := signedCallInput{
+			Key:       secp256k1KeyGen.Draw(t, "key"),
+			ChainID:   uint64Gen.Filter(func(v uint64) bool { return v > 0 }).Draw(t, "chainID"),
+			GasLimit:  uint64Gen.Filter(func(v uint64) bool { return v > 0 }).Draw(t, "gasLimit"),
+			GasPrice:  uint256Gen.Draw(t, "gasPrice"),
+			Value:     uint256Gen.Draw(t, "value"),
+			Data:      payloadGen(sapphire.DefaultMaxSignedCallDataSize+1).Draw(t, "data"),
+			Nonce:     uint64Gen.Draw(t, "nonce"),
+			Block:     uint64Gen.Draw(t, "block"),
+			Range:     uint64Gen.Draw(t, "range"),
+			BlockHash: bytesN(32).Draw(t, "blockHash"),
+		}
+		if rapid.Bool().Draw(t, "call") {
+			to := common.BytesToAddress(bytesN(20).Draw(t, "to"))
+			in.To = &to
+		}
+		checkSignedCallRoundTrip(t, in)
+	})
+}
+
+// gaslessInput is a gasless transaction.
+type gaslessInput struct {
+	Key        []byte
+	Dynamic    bool
+	To         *common.Address
+	Nonce, Gas uint64
+	Value      *big.Int
+	Data       payload
+	Payer      sdkTesting.TestKey
+	PayerNonce uint64
+	Fee        GaslessFee
+}
+
+// checkGaslessRoundTrip checks that a gasless transaction decodes to the
+// call of the inner transaction and the fee it was encoded with.
+func checkGaslessRoundTrip(t rapid.TB, chainContext signature.Context, in gaslessInput) {
+	t.Helper()
+	key, err := crypto.ToECDSA(in.Key)
+	if err != nil {
+		t.Fatalf("invalid key: %v", err)
+	}
+	chainID := big.NewInt(0x5afd)
+	var data types.TxData = &types.LegacyTx{Nonce: in.Nonce, GasPrice: new(big.Int), Gas: in.Gas, To: in.To, Value: in.Value, Data: in.Data.bytes()}
+	if in.Dynamic {
+		data = &types.DynamicFeeTx{ChainID: chainID, Nonce: in.Nonce, GasFeeCap: new(big.Int), GasTipCap: new(big.Int), Gas: in.Gas, To: in.To, Value: in.Value, Data: in.Data.bytes()}
+	}
+	inner, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), data)
+	if err != nil {
+		t.Fatalf("failed to sign inner transaction: %v", err)
+	}
+	wrapped, err := EncodeGasless(inner, in.Payer.Signer, in.PayerNonce, in.Fee, chainContext)
+	if err != nil {
+		t.Fatalf("EncodeGasless failed: %v", err)
+	}
+	call, outer, err := DecodeGasless(wrapped)
+	if err != nil {
+		t.Fatalf("DecodeGasless failed: %v", err)
+	}
+	if (call.To == nil) != (in.To == nil) || call.To != nil && *call.To != *in.To || call.Value.Cmp(inner.Value()) != 0 || string(call.Data) != string(inner.Data()) {
+		t.Fatalf("call decoded to %+v, expected that of %s", call, inner.Hash())
+	}
+	fee := in.Fee.Amount
+	if fee == nil {
+		fee = new(big.Int)
+	}
+	gas := in.Fee.Gas
+	if gas == 0 {
+		gas = in.Gas
+	}
+	if len(outer.AuthInfo.SignerInfo) != 1 || outer.AuthInfo.SignerInfo[0].Nonce != in.PayerNonce ||
+		outer.AuthInfo.Fee.Amount.Amount.ToBigInt().Cmp(fee) != 0 || outer.AuthInfo.Fee.Gas != gas {
+		t.Fatalf("outer transaction decoded to %+v", outer.AuthInfo)
+	}
+}
+
+func TestPropertyGaslessRoundTrip(t *testing.T) {
+	chainContext := gaslessChainContext(t, Networks[0x5afd].RuntimeID)
+	key := common.Hex2Bytes("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	for _, size := range cborBoundarySizes {
+		checkGaslessRoundTrip(t, chainContext, gaslessInput{
+			Key:        key,
+			Nonce:      math.MaxUint64 - 1,
+			Gas:        math.MaxUint64,
+			Data:       payload{Size: size},
+			Payer:      sdkTesting.Alice,
+			PayerNonce: math.MaxUint64,
+		})
+	}
+	rapid.Check(t, func(t *rapid.T) {
+		in := gaslessInput{
+			Key:     secp256k1KeyGen.Draw(t, "key"),
+			Dynamic: rapid.Bool().Draw(t, "dynamic"),
+			// A transaction's nonce can't be the largest uint64.
+			Nonce:      uint64Gen.Filter(func(v uint64) bool { return v < math.MaxUint64 }).Draw(t, "nonce"),
+			Gas:        uint64Gen.Filter(func(v uint64) bool { return v > 0 }).Draw(t, "gas"),
+			Value:      uint256Gen.Draw(t, "value"),
+			Data:       payloadGen(64<<10).Draw(t, "data"),
+			Payer:      rapid.SampledFrom([]sdkTesting.TestKey{sdkTesting.Alice, sdkTesting.Dave, sdkTesting.Frank}).Draw(t, "payer"),
+			PayerNonce: uint64Gen.Draw(t, "payerNonce"),
+			Fee:        GaslessFee{Amount: uint256Gen.Draw(t, "fee"), Gas: uint64Gen.Draw(t, "feeGas")},
+		}
+		if rapid.Bool().Draw(t, "call") {
+			to := common.BytesToAddress(bytesN(20).Draw(t, "to"))
+			in.To = &to
+		}
+		checkGaslessRoundTrip(t, chainContext, in)
+	})
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a minimal token bucket.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// newRateLimiter returns a limiter for the given limit, or nil if the limit
+// is unset.
+func newRateLimiter(limit sapphire.RateLimit) *rateLimiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := math.Max(float64(limit.Burst), 1)
+	return &rateLimiter{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		now:    time.Now,
+	}
+}
+
+// reserve takes a token and returns how long the caller must wait before
+// using it.
+func (l *rateLimiter) reserve() time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// release returns a reserved token that was not used.
+func (l *rateLimiter) release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = math.Min(l.burst, l.tokens+1)
+}
+
+// sleepContext waits for d, returning early with the context's error if it
+// is done first. If the context deadline would pass before d elapses the
+// wait is abandoned immediately.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return context.DeadlineExceeded
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+func TestRateLimitReads(t *testing.T) {
+	rt := newRPCTransport()
+	b, err := WrapClient(dialTransport(t, rt), nil, WithRateLimit(sapphire.RateLimit{Rate: 20, Burst: 1}, sapphire.RateLimit{}))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+
+	const n = 11
+	ctx := context.Background()
+	for i := 0; i < n; i++ {
+		if _, err = b.CodeAt(ctx, common.Address{}, nil); err != nil {
+			t.Fatalf("CodeAt failed: %v", err)
+		}
+	}
+
+	reqs := rt.recorded("eth_getCode")
+	if len(reqs) != n {
+		t.Fatalf("expected %d requests, got %d", n, len(reqs))
+	}
+	elapsed := reqs[n-1].Time.Sub(reqs[0].Time)
+	observed := float64(n-1) / elapsed.Seconds()
+	if observed > 22 {
+		t.Fatalf("observed request rate %.1f/s exceeds the 20/s limit", observed)
+	}
+}
+
+func TestRateLimitHonorsDeadline(t *testing.T) {
+	rt := newRPCTransport()
+	b, err := WrapClient(dialTransport(t, rt), nil, WithRateLimit(sapphire.RateLimit{Rate: 0.5, Burst: 1}, sapphire.RateLimit{}))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	if _, err = b.CodeAt(context.Background(), common.Address{}, nil); err != nil {
+		t.Fatalf("CodeAt failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = b.CodeAt(ctx, common.Address{}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Fatalf("limiter blocked for %s instead of failing fast", time.Since(start))
+	}
+	if got := len(rt.recorded("eth_getCode")); got != 1 {
+		t.Fatalf("throttled request should not reach the gateway, got %d requests", got)
+	}
+}
+
+func TestRateLimitAndRetryDoNotCompound(t *testing.T) {
+	rt := newRPCTransport()
+	b, err := WrapClient(dialTransport(t, rt), nil,
+		WithRateLimit(sapphire.RateLimit{Rate: 10, Burst: 1}, sapphire.RateLimit{}),
+		WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	// Let the bucket refill after the requests made by WrapClient.
+	time.Sleep(100 * time.Millisecond)
+
+	rt.failNext(2, http.StatusServiceUnavailable)
+	start := time.Now()
+	if _, err = b.CodeAt(context.Background(), common.Address{}, nil); err != nil {
+		t.Fatalf("CodeAt should succeed after retries: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	// Backoffs of 100ms and 200ms overlap with the 100ms limiter waits, for
+	// ~300ms total. Adding them up would take ~500ms.
+	if elapsed < 280*time.Millisecond || elapsed > 450*time.Millisecond {
+		t.Fatalf("unexpected total latency %s", elapsed)
+	}
+	if got := len(rt.recorded("eth_getCode")); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestRetrySkipsPermanentErrors(t *testing.T) {
+	rt := newRPCTransport()
+	b, err := WrapClient(dialTransport(t, rt), nil, WithRetry(RetryPolicy{MaxAttempts: 3}))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	rt.failNext(3, http.StatusBadRequest)
+	if _, err = b.CodeAt(context.Background(), common.Address{}, nil); err == nil {
+		t.Fatalf("expected CodeAt to fail")
+	}
+	if got := len(rt.recorded("eth_getCode")); got != 1 {
+		t.Fatalf("permanent errors must not be retried, got %d attempts", got)
+	}
+}
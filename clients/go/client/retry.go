@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// RetryPolicy configures retries of failed read requests to the gateway.
+//
+// Transactions are only resent if they provably did not reach the gateway,
+// see IsRetryable.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponentially growing delay between retries.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is a conservative policy suitable for public gateways.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 250 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+// backoff returns the delay before the given retry (zero-based).
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialBackoff
+	for i := 0; i < retry && (p.MaxBackoff == 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff != 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// permanentErrors are the errors retrying the same request can't fix, even
+// if they wrap a transport failure: requests the client or the runtime
+// rejected, and calls and transactions that failed on chain.
+var permanentErrors = []error{
+	sapphire.ErrABI,
+	sapphire.ErrCallFailed,
+	ErrGaslessDuplicate,
+	ErrGaslessInner,
+	ErrGaslessOuter,
+	ErrInvalidPermission,
+	sapphire.ErrInvalidSignedCall,
+	sapphire.ErrLeashExpired,
+	sapphire.ErrLeashNonceMismatch,
+	sapphire.ErrMalformedEnvelope,
+	sapphire.ErrNoSigner,
+	sapphire.ErrNotSapphireChain,
+	sapphire.ErrResponseTooLarge,
+	sapphire.ErrSignatureRejected,
+	ErrTracingUnsupported,
+	ErrUnauthenticated,
+	sapphire.ErrUnencryptedCalldata,
+	sapphire.ErrUnsupportedBackend,
+	sapphire.ErrUnsupportedTransaction,
+}
+
+// IsRetryable reports whether the request that failed with err can safely be
+// made again unchanged. It is what WithRetry retries by.
+//
+// Failures of the network or the gateway are retryable: connection errors,
+// HTTP 5xx and 429 responses, timeouts and ErrCircuitOpen. Errors the gateway
+// or the runtime answered with are not, e.g. reverts and other module errors,
+// rejected signatures and leashes and ErrNotSapphireChain, and neither are
+// the client's own validation errors or context.Canceled.
+//
+// Transactions, i.e. errors of SendTransaction, are only retryable if they
+// provably did not reach the gateway: the connection was refused, the gateway
+// rate limited the request, or the circuit breaker was open. Otherwise the
+// transaction may have been accepted, see IsTemporary.
+func IsRetryable(err error) bool {
+	var callErr *CallError
+	send := errors.As(err, &callErr) && callErr.Op == "eth_sendRawTransaction"
+	return isRetryable(err, send)
+}
+
+// IsTemporary reports whether err is a failure of the network or the gateway
+// that may clear up by itself, as described for IsRetryable. Unlike
+// IsRetryable, it does not consider whether a failed transaction may have
+// been accepted anyway.
+func IsTemporary(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	for _, permanent := range permanentErrors {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+	var (
+		revert      *sapphire.RevertError
+		unsupported ErrCapabilityUnsupported
+	)
+	if errors.As(err, &revert) || errors.As(err, &unsupported) {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// IsPermanent reports whether err is a failure that repeating the request
+// would run into again. It is the opposite of IsTemporary for non-nil errors.
+func IsPermanent(err error) bool {
+	return err != nil && !IsTemporary(err)
+}
+
+// isRetryable reports whether a request that failed with err can be made
+// again. send is whether the request submitted a transaction.
+func isRetryable(err error, send bool) bool {
+	if !IsTemporary(err) {
+		return false
+	}
+	return !send || notSent(err)
+}
+
+// notSent reports whether the request that failed with err provably did not
+// reach the gateway.
+func notSent(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests
+	}
+	var (
+		opErr  *net.OpError
+		dnsErr *net.DNSError
+	)
+	return errors.As(err, &dnsErr) || (errors.As(err, &opErr) && opErr.Op == "dial")
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+func TestIsRetryable(t *testing.T) {
+	read := func(err error) error { return &CallError{Op: "eth_call", Err: err} }
+	send := func(err error) error { return &CallError{Op: "eth_sendRawTransaction", Err: err} }
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	resetErr := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+	for _, tc := range []struct {
+		name      string
+		err       error
+		retryable bool
+		temporary bool
+	}{
+		{"nil", nil, false, false},
+		{"canceled", context.Canceled, false, false},
+		{"deadline", context.DeadlineExceeded, true, true},
+		{"eof", io.ErrUnexpectedEOF, true, true},
+		{"circuit open", ErrCircuitOpen, true, true},
+		{"key fetch over eof", fmt.Errorf("%w: %w", sapphire.ErrKeyFetchFailed, io.EOF), true, true},
+		{"bare key fetch", sapphire.ErrKeyFetchFailed, false, false},
+		{"not sapphire", fmt.Errorf("%w: %w", sapphire.ErrNotSapphireChain, ErrCapabilityUnsupported{Capability: CapabilityCallDataPublicKey}), false, false},
+		{"capability unsupported", ErrCapabilityUnsupported{Capability: CapabilityDebugTrace}, false, false},
+		{"module error", &sapphire.CallFailedError{Module: "core", Code: 12}, false, false},
+		{"revert", &sapphire.RevertError{Data: []byte{1, 2, 3, 4}}, false, false},
+		{"leash expired over eof", fmt.Errorf("%w: %w", sapphire.ErrLeashExpired, io.EOF), false, false},
+
+		{"read 503", read(rpc.HTTPError{StatusCode: http.StatusServiceUnavailable}), true, true},
+		{"read 429", read(rpc.HTTPError{StatusCode: http.StatusTooManyRequests}), true, true},
+		{"read 400", read(rpc.HTTPError{StatusCode: http.StatusBadRequest}), false, false},
+		{"read reset", read(resetErr), true, true},
+		{"read json-rpc error", read(&rpcError{Code: -32000, Message: "execution reverted"}), false, false},
+		{"send 503", send(rpc.HTTPError{StatusCode: http.StatusServiceUnavailable}), false, true},
+		{"send 429", send(rpc.HTTPError{StatusCode: http.StatusTooManyRequests}), true, true},
+		{"send refused", send(dialErr), true, true},
+		{"send reset", send(resetErr), false, true},
+		{"send timeout", send(context.DeadlineExceeded), false, true},
+		{"send circuit open", send(ErrCircuitOpen), true, true},
+		{"send nonce too low", send(&rpcError{Code: -32000, Message: "nonce too low"}), false, false},
+	} {
+		if got := IsRetryable(tc.err); got != tc.retryable {
+			t.Errorf("%s: expected IsRetryable %t, got %t", tc.name, tc.retryable, got)
+		}
+		if got := IsTemporary(tc.err); got != tc.temporary {
+			t.Errorf("%s: expected IsTemporary %t, got %t", tc.name, tc.temporary, got)
+		}
+		if got := IsPermanent(tc.err); got != (tc.err != nil && !tc.temporary) {
+			t.Errorf("%s: expected IsPermanent %t, got %t", tc.name, !tc.temporary, got)
+		}
+	}
+
+	// Every sentinel is permanent, also when it wraps a transport failure.
+	for _, sentinel := range append(permanentErrors, sapphire.ErrCallResultDecode) {
+		if IsRetryable(sentinel) || IsRetryable(fmt.Errorf("%w: %w", sentinel, io.EOF)) || !IsPermanent(sentinel) {
+			t.Errorf("expected %q to be permanent", sentinel)
+		}
+	}
+}
+
+func TestRetrySends(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{To: &to, Gas: 21_000, GasPrice: big.NewInt(sapphire.DefaultGasPrice)}), types.LatestSignerForChainID(big.NewInt(0x5afd)), key)
+
+	for _, tc := range []struct {
+		status   int
+		attempts int
+	}{
+		{http.StatusTooManyRequests, 2},
+		{http.StatusServiceUnavailable, 1},
+	} {
+		rt := newRPCTransport()
+		rt.handle("eth_sendRawTransaction", func([]json.RawMessage) (interface{}, error) {
+			return tx.Hash(), nil
+		})
+		b, err := WrapClient(dialTransport(t, rt), nil, WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+		if err != nil {
+			t.Fatalf("failed to wrap client: %v", err)
+		}
+		rt.failMethod("eth_sendRawTransaction", 1, tc.status)
+		err = b.SendTransaction(context.Background(), tx)
+		if got := len(rt.recorded("eth_sendRawTransaction")); got != tc.attempts {
+			t.Errorf("%d: expected %d attempts, got %d", tc.status, tc.attempts, got)
+		}
+		if (err == nil) != (tc.attempts > 1) || (err != nil && IsRetryable(err)) {
+			t.Errorf("%d: unexpected result %v", tc.status, err)
+		}
+	}
+}
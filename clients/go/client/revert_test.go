@@ -0,0 +1,90 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// revertErrorResponse is a gateway error response reporting a revert.
+type revertErrorResponse struct {
+	Name   string        `json:"name"`
+	Error  rpcError      `json:"error"`
+	Data   hexutil.Bytes `json:"data"`
+	Reason string        `json:"reason"`
+}
+
+func loadRevertErrorResponses(t testing.TB) []revertErrorResponse {
+	raw, err := os.ReadFile(filepath.Join("..", "testdata", "revert_error_responses.json"))
+	if err != nil {
+		t.Fatalf("failed to read responses: %v", err)
+	}
+	var responses []revertErrorResponse
+	if err = json.Unmarshal(raw, &responses); err != nil {
+		t.Fatalf("failed to decode responses: %v", err)
+	}
+	return responses
+}
+
+func TestRevertDataFromError(t *testing.T) {
+	ctx := context.Background()
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	for _, resp := range loadRevertErrorResponses(t) {
+		rt := newRPCTransport()
+		rt.handle("eth_call", func([]json.RawMessage) (interface{}, error) {
+			return nil, &resp.Error
+		})
+		b, err := WrapClient(dialTransport(t, rt), nil)
+		if err != nil {
+			t.Fatalf("failed to wrap client: %v", err)
+		}
+		_, err = b.CallContract(ctx, ethereum.CallMsg{To: &to, Data: TestData}, nil)
+		var revert *sapphire.RevertError
+		if !errors.As(err, &revert) || !bytes.Equal(revert.Data, resp.Data) || revert.Reason != resp.Reason {
+			t.Errorf("%s: expected a revert with data %x and reason %q, got %v", resp.Name, []byte(resp.Data), resp.Reason, err)
+			continue
+		}
+		if data, ok := sapphire.RevertDataFromError(err); !ok || !bytes.Equal(data, resp.Data) {
+			t.Errorf("%s: expected revert data %x, got %x", resp.Name, []byte(resp.Data), data)
+		}
+	}
+
+	// Enveloped calls fail in their call result.
+	data := revertData(t, "not the owner")
+	mock := newMockBackend()
+	mock.callResult = cbor.Marshal(sdkTypes.CallResult{Failed: &sdkTypes.FailedCallResult{Module: "evm", Code: evmRevertedCode, Message: "reverted: " + base64.StdEncoding.EncodeToString(data)}})
+	_, err := newMockWrappedBackend(mock, nil).CallContract(ctx, ethereum.CallMsg{To: &to, Data: TestData}, nil)
+	var revert *sapphire.RevertError
+	if !errors.As(err, &revert) || !bytes.Equal(revert.Data, data) || revert.Reason != "not the owner" {
+		t.Fatalf("expected a revert from the call result, got %v", err)
+	}
+	var failed *sapphire.CallFailedError
+	if !errors.As(err, &failed) || !errors.Is(revert, sapphire.ErrCallFailed) {
+		t.Fatalf("expected the revert to wrap the CallFailedError, got %v", err)
+	}
+
+	for _, err := range []error{
+		&sapphire.CallFailedError{Module: "evm", Code: 2, Message: "out of gas"},
+		fmt.Errorf("call failed: %w", &sapphire.CallFailedError{Module: "core", Code: 12, Message: "reverted: grQpAA=="}),
+		errors.New("connection refused"),
+		nil,
+	} {
+		if _, ok := sapphire.RevertDataFromError(err); ok {
+			t.Errorf("expected %v not to be a revert", err)
+		}
+	}
+}
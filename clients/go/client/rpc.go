@@ -0,0 +1,306 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// RPCHandler handles calls to a JSON-RPC method on behalf of an RPCClient.
+//
+// result and args are those passed to RPCClient.CallContext. Handlers
+// typically transform args, forward the request with c.Raw().CallContext and
+// transform the result.
+type RPCHandler func(ctx context.Context, c *RPCClient, result interface{}, args ...interface{}) error
+
+// RPCClient wraps an rpc.Client so that raw JSON-RPC calls can talk to Sapphire.
+//
+// Calls to eth_call, eth_estimateGas and eth_sendRawTransaction are
+// encrypted, and signed where a signer for the sender is available, the same
+// way WrappedBackend does it. All other methods are passed through unchanged
+// unless a handler is registered for them with Handle.
+type RPCClient struct {
+	raw     *rpc.Client
+	backend *WrappedBackend
+
+	mu       sync.RWMutex
+	handlers map[string]RPCHandler
+}
+
+// WrapRPCClient wraps an rpc.Client so that it can talk to Sapphire. The
+// sign function and options are the same as for WrapClient.
+func WrapRPCClient(c *rpc.Client, sign sapphire.SignerFn, opts ...Option) (*RPCClient, error) {
+	return WrapRPCClientContext(context.Background(), c, sign, opts...)
+}
+
+// WrapRPCClientContext is like WrapRPCClient but aborts when ctx is done.
+func WrapRPCClientContext(ctx context.Context, c *rpc.Client, sign sapphire.SignerFn, opts ...Option) (*RPCClient, error) {
+	b, err := WrapClientContext(ctx, ethclient.NewClient(c), sign, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &RPCClient{
+		raw:     c,
+		backend: b,
+		handlers: map[string]RPCHandler{
+			"eth_call":               handleCall,
+			"eth_estimateGas":        handleEstimateGas,
+			"eth_sendRawTransaction": handleSendRawTransaction,
+		},
+	}, nil
+}
+
+// Raw returns the wrapped rpc.Client. Requests made with it directly are not
+// encrypted.
+func (c *RPCClient) Raw() *rpc.Client {
+	return c.raw
+}
+
+// Backend returns the WrappedBackend used to encrypt and sign requests.
+func (c *RPCClient) Backend() *WrappedBackend {
+	return c.backend
+}
+
+// Handle registers the handler for method, replacing any existing handler.
+// A nil handler makes the method pass through unchanged.
+func (c *RPCClient) Handle(method string, h RPCHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if h == nil {
+		delete(c.handlers, method)
+		return
+	}
+	c.handlers[method] = h
+}
+
+// Call is like CallContext with a background context.
+func (c *RPCClient) Call(result interface{}, method string, args ...interface{}) error {
+	return c.CallContext(context.Background(), result, method, args...)
+}
+
+// CallContext performs a JSON-RPC call with the given arguments, see
+// rpc.Client.CallContext.
+func (c *RPCClient) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	c.mu.RLock()
+	h, ok := c.handlers[method]
+	c.mu.RUnlock()
+	if !ok {
+		return c.raw.CallContext(ctx, result, method, args...)
+	}
+	return h(ctx, c, result, args...)
+}
+
+// Close closes the wrapped rpc.Client.
+func (c *RPCClient) Close() {
+	c.raw.Close()
+}
+
+// rpcCallArgs is the JSON form of an eth_call or eth_estimateGas call object.
+type rpcCallArgs struct {
+	From     *common.Address `json:"from,omitempty"`
+	To       *common.Address `json:"to,omitempty"`
+	Gas      *hexutil.Uint64 `json:"gas,omitempty"`
+	GasPrice *hexutil.Big    `json:"gasPrice,omitempty"`
+	Value    *hexutil.Big    `json:"value,omitempty"`
+	Data     *hexutil.Bytes  `json:"data,omitempty"`
+	Input    *hexutil.Bytes  `json:"input,omitempty"`
+}
+
+// parseCallArg converts a raw call object argument into a CallMsg.
+func parseCallArg(arg interface{}) (ethereum.CallMsg, error) {
+	switch arg := arg.(type) {
+	case ethereum.CallMsg:
+		return arg, nil
+	case *ethereum.CallMsg:
+		return *arg, nil
+	}
+	raw, err := json.Marshal(arg)
+	if err != nil {
+		return ethereum.CallMsg{}, fmt.Errorf("invalid call object: %w", err)
+	}
+	var args rpcCallArgs
+	if err = json.Unmarshal(raw, &args); err != nil {
+		return ethereum.CallMsg{}, fmt.Errorf("invalid call object: %w", err)
+	}
+	msg := ethereum.CallMsg{To: args.To}
+	if args.From != nil {
+		msg.From = *args.From
+	}
+	if args.Gas != nil {
+		msg.Gas = uint64(*args.Gas)
+	}
+	if args.GasPrice != nil {
+		msg.GasPrice = args.GasPrice.ToInt()
+	}
+	if args.Value != nil {
+		msg.Value = args.Value.ToInt()
+	}
+	switch {
+	case args.Input != nil:
+		msg.Data = *args.Input
+	case args.Data != nil:
+		msg.Data = *args.Data
+	}
+	return msg, nil
+}
+
+// toCallArg converts a CallMsg into a call object, like ethclient does.
+func toCallArg(msg *ethereum.CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["input"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	return arg
+}
+
+// parseBlockArg returns the block number the leash of a call at the given
+// block parameter should be built on, or nil for the latest block.
+func parseBlockArg(args []interface{}) (*big.Int, error) {
+	if len(args) < 2 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid block parameter: %w", err)
+	}
+	var block rpc.BlockNumberOrHash
+	if err = json.Unmarshal(raw, &block); err != nil {
+		return nil, fmt.Errorf("invalid block parameter: %w", err)
+	}
+	if n, ok := block.Number(); ok && n >= 0 {
+		return big.NewInt(n.Int64()), nil
+	}
+	return nil, nil
+}
+
+// setResult stores v into the result pointer passed to CallContext.
+func setResult(result interface{}, v interface{}) error {
+	if result == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, result)
+}
+
+func handleCall(ctx context.Context, c *RPCClient, result interface{}, args ...interface{}) error {
+	if len(args) == 0 {
+		return fmt.Errorf("eth_call: missing call object")
+	}
+	msg, err := parseCallArg(args[0])
+	if err != nil {
+		return err
+	}
+	blockNumber, err := parseBlockArg(args)
+	if err != nil {
+		return err
+	}
+	b := c.backend
+	cipher := b.currentCipher()
+	packedCall, _, err := b.packCall(ctx, cipher, msg, blockNumber)
+	if err != nil {
+		return err
+	}
+	params := append([]interface{}{toCallArg(packedCall)}, args[1:]...)
+	res, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (hexutil.Bytes, error) {
+		var res hexutil.Bytes
+		err := c.raw.CallContext(ctx, &res, "eth_call", params...)
+		return res, err
+	})
+	b.debugRequest(newCallContext(ctx, "eth_call", msg.To, blockNumber), msg.Data, packedCall.Data, res, err)
+	if err != nil {
+		return err
+	}
+	decrypted, err := cipher.DecryptEncoded(res)
+	if err != nil {
+		return err
+	}
+	return setResult(result, hexutil.Bytes(decrypted))
+}
+
+func handleEstimateGas(ctx context.Context, c *RPCClient, result interface{}, args ...interface{}) error {
+	if len(args) == 0 {
+		return fmt.Errorf("eth_estimateGas: missing call object")
+	}
+	msg, err := parseCallArg(args[0])
+	if err != nil {
+		return err
+	}
+	b := c.backend
+	packedCall, padding, err := b.packEstimate(ctx, b.currentCipher(), msg)
+	if err != nil {
+		return err
+	}
+	params := append([]interface{}{toCallArg(packedCall)}, args[1:]...)
+	gas, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (hexutil.Uint64, error) {
+		var gas hexutil.Uint64
+		err := c.raw.CallContext(ctx, &gas, "eth_estimateGas", params...)
+		return gas, err
+	})
+	limit := b.gasMargin.apply(uint64(gas) + padding)
+	b.debugGas(newCallContext(ctx, "eth_estimateGas", msg.To, nil), msg.Data, packedCall.Data, gas, limit, err)
+	if err != nil {
+		return err
+	}
+	return setResult(result, hexutil.Uint64(limit))
+}
+
+// handleSendRawTransaction encrypts the calldata of transactions that were
+// signed with plaintext calldata, which requires re-signing them on behalf of
+// their sender. Transactions that are already encrypted are sent as is.
+func handleSendRawTransaction(ctx context.Context, c *RPCClient, result interface{}, args ...interface{}) error {
+	if len(args) == 0 {
+		return fmt.Errorf("eth_sendRawTransaction: missing transaction")
+	}
+	raw, err := json.Marshal(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid raw transaction: %w", err)
+	}
+	var encoded hexutil.Bytes
+	if err = json.Unmarshal(raw, &encoded); err != nil {
+		return fmt.Errorf("invalid raw transaction: %w", err)
+	}
+	tx := new(types.Transaction)
+	if err = tx.UnmarshalBinary(encoded); err != nil {
+		return fmt.Errorf("invalid raw transaction: %w", err)
+	}
+
+	b := c.backend
+	if txNeedsPacking(tx) {
+		from, err := types.Sender(types.LatestSignerForChainID(&b.chainID), tx)
+		if err != nil {
+			return fmt.Errorf("failed to recover transaction sender: %w", err)
+		}
+		if tx, err = b.signTx(ctx, from, tx); err != nil {
+			return err
+		}
+	}
+	if err = b.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	return setResult(result, tx.Hash())
+}
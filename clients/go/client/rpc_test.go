@@ -0,0 +1,201 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// opaqueResult is not a result envelope, so ciphers return it from a call result as is.
+var opaqueResult = cbor.Marshal("opaque")
+
+func newTestRPCClient(t *testing.T, rt *rpcTransport, opts ...Option) *RPCClient {
+	c, err := WrapRPCClient(dialTransport(t, rt).Client(), nil, opts...)
+	if err != nil {
+		t.Fatalf("failed to wrap rpc client: %v", err)
+	}
+	return c
+}
+
+// recordedCallInput decodes the calldata of the last recorded call to method.
+func recordedCallInput(t *testing.T, rt *rpcTransport, method string) []byte {
+	reqs := rt.recorded(method)
+	if len(reqs) == 0 {
+		t.Fatalf("no %s request reached the gateway", method)
+	}
+	var args rpcCallArgs
+	if err := json.Unmarshal(reqs[len(reqs)-1].Params[0], &args); err != nil {
+		t.Fatalf("failed to decode %s params: %v", method, err)
+	}
+	if args.Input == nil {
+		t.Fatalf("%s request has no calldata", method)
+	}
+	return *args.Input
+}
+
+func TestRPCClientCall(t *testing.T) {
+	rt := newRPCTransport()
+	rt.handle("eth_call", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Bytes(cbor.Marshal(sdkTypes.CallResult{Ok: opaqueResult})), nil
+	})
+	rt.handle("eth_estimateGas", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Uint64(21_000), nil
+	})
+	c := newTestRPCClient(t, rt)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	call := map[string]interface{}{"to": to, "data": hexutil.Bytes(TestData)}
+
+	var res hexutil.Bytes
+	if err := c.CallContext(context.Background(), &res, "eth_call", call, "latest"); err != nil {
+		t.Fatalf("eth_call failed: %v", err)
+	}
+	if !bytes.Equal(res, opaqueResult) {
+		t.Fatalf("unexpected eth_call result %x", []byte(res))
+	}
+	var envelope sdkTypes.Call
+	if err := cbor.Unmarshal(recordedCallInput(t, rt, "eth_call"), &envelope); err != nil {
+		t.Fatalf("eth_call calldata is not an envelope: %v", err)
+	}
+	if envelope.Format != sdkTypes.CallFormatEncryptedX25519DeoxysII {
+		t.Fatalf("eth_call calldata is not encrypted, format %d", envelope.Format)
+	}
+	if blockParam := string(rt.recorded("eth_call")[0].Params[1]); blockParam != `"latest"` {
+		t.Fatalf("block parameter was not passed through, got %s", blockParam)
+	}
+
+	var gas hexutil.Uint64
+	if err := c.CallContext(context.Background(), &gas, "eth_estimateGas", call); err != nil {
+		t.Fatalf("eth_estimateGas failed: %v", err)
+	}
+	if gas != 21_000 {
+		t.Fatalf("unexpected gas estimate %d", gas)
+	}
+	if err := cbor.Unmarshal(recordedCallInput(t, rt, "eth_estimateGas"), &envelope); err != nil || envelope.Format != sdkTypes.CallFormatEncryptedX25519DeoxysII {
+		t.Fatalf("eth_estimateGas calldata is not encrypted")
+	}
+}
+
+func TestRPCClientSignedCall(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	rt := newRPCTransport()
+	rt.handle("eth_call", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Bytes(cbor.Marshal(sdkTypes.CallResult{Ok: opaqueResult})), nil
+	})
+	c := newTestRPCClient(t, rt, WithKeyring(sapphire.NewKeyring(signer)))
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	var res hexutil.Bytes
+	call := map[string]interface{}{"from": signer.Address(), "to": to, "input": hexutil.Bytes(TestData)}
+	if err := c.CallContext(context.Background(), &res, "eth_call", call, "latest"); err != nil {
+		t.Fatalf("eth_call failed: %v", err)
+	}
+	var pack evm.SignedCallDataPack
+	if err := cbor.Unmarshal(recordedCallInput(t, rt, "eth_call"), &pack); err != nil {
+		t.Fatalf("eth_call calldata is not a signed call data pack: %v", err)
+	}
+	if pack.Data.Format != sdkTypes.CallFormatEncryptedX25519DeoxysII {
+		t.Fatalf("signed call data is not encrypted, format %d", pack.Data.Format)
+	}
+	if len(pack.Signature) != 65 {
+		t.Fatalf("signed call has no signature")
+	}
+}
+
+func TestRPCClientSendRawTransaction(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	rt := newRPCTransport()
+	rt.handle("eth_sendRawTransaction", func([]json.RawMessage) (interface{}, error) {
+		return common.Hash{}, nil
+	})
+	c := newTestRPCClient(t, rt, WithKeyring(sapphire.NewKeyring(signer)))
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	chainSigner := types.LatestSignerForChainID(big.NewInt(0x5afd))
+	tx := types.MustSignNewTx(key, chainSigner, &types.LegacyTx{
+		To:       &to,
+		Gas:      21_000,
+		GasPrice: big.NewInt(sapphire.DefaultGasPrice),
+		Data:     TestData,
+	})
+	encoded, _ := tx.MarshalBinary()
+	var hash common.Hash
+	if err := c.CallContext(context.Background(), &hash, "eth_sendRawTransaction", hexutil.Bytes(encoded)); err != nil {
+		t.Fatalf("eth_sendRawTransaction failed: %v", err)
+	}
+
+	reqs := rt.recorded("eth_sendRawTransaction")
+	var sentEncoded hexutil.Bytes
+	if err := json.Unmarshal(reqs[0].Params[0], &sentEncoded); err != nil {
+		t.Fatalf("failed to decode sent transaction: %v", err)
+	}
+	sent := new(types.Transaction)
+	if err := sent.UnmarshalBinary(sentEncoded); err != nil {
+		t.Fatalf("failed to decode sent transaction: %v", err)
+	}
+	if txNeedsPacking(sent) {
+		t.Fatalf("transaction reached the gateway with plaintext calldata")
+	}
+	if from, err := types.Sender(chainSigner, sent); err != nil || from != signer.Address() {
+		t.Fatalf("sent transaction is not signed by the original sender")
+	}
+	if hash != sent.Hash() {
+		t.Fatalf("expected hash %s, got %s", sent.Hash(), hash)
+	}
+}
+
+func TestRPCClientHandlers(t *testing.T) {
+	rt := newRPCTransport()
+	rt.handle("eth_blockNumber", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Uint64(100), nil
+	})
+	c := newTestRPCClient(t, rt)
+
+	var blockNumber hexutil.Uint64
+	if err := c.CallContext(context.Background(), &blockNumber, "eth_blockNumber"); err != nil {
+		t.Fatalf("pass-through call failed: %v", err)
+	}
+	if blockNumber != 100 {
+		t.Fatalf("unexpected block number %d", blockNumber)
+	}
+
+	c.Handle("eth_blockNumber", func(ctx context.Context, c *RPCClient, result interface{}, args ...interface{}) error {
+		var n hexutil.Uint64
+		if err := c.Raw().CallContext(ctx, &n, "eth_blockNumber", args...); err != nil {
+			return err
+		}
+		return setResult(result, n+1)
+	})
+	if err := c.CallContext(context.Background(), &blockNumber, "eth_blockNumber"); err != nil {
+		t.Fatalf("intercepted call failed: %v", err)
+	}
+	if blockNumber != 101 {
+		t.Fatalf("handler was not used, got block number %d", blockNumber)
+	}
+
+	c.Handle("eth_call", nil)
+	rt.handle("eth_call", func(params []json.RawMessage) (interface{}, error) {
+		return hexutil.Bytes{}, nil
+	})
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	var res hexutil.Bytes
+	if err := c.CallContext(context.Background(), &res, "eth_call", map[string]interface{}{"to": to, "input": hexutil.Bytes(TestData)}, rpc.LatestBlockNumber); err != nil {
+		t.Fatalf("eth_call failed: %v", err)
+	}
+	if input := recordedCallInput(t, rt, "eth_call"); !bytes.Equal(input, TestData) {
+		t.Fatalf("eth_call without a handler should pass through unchanged")
+	}
+}
@@ -0,0 +1,492 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	oasisCommon "github.com/oasisprotocol/oasis-core/go/common"
+	cmnErrors "github.com/oasisprotocol/oasis-core/go/common/errors"
+	cmnGrpc "github.com/oasisprotocol/oasis-core/go/common/grpc"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/client"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/accounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/core"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// KeySourceRuntimeQuery is the core.CallDataPublicKey query of a runtime
+// client, see WrapRuntimeClient and NewRuntimeKeySource.
+const KeySourceRuntimeQuery = "runtime core.CallDataPublicKey"
+
+// ethereumAuthModule is the authentication scheme of Ethereum transactions
+// wrapped in runtime transactions.
+const ethereumAuthModule = "evm.ethereum.v0"
+
+// sentTxStoreSize is the number of transactions sent through a runtime client
+// whose receipts can be looked up.
+const sentTxStoreSize = 1024
+
+// DialRuntime connects to the gRPC interface of an oasis-node, e.g.
+// "unix:/node/data/internal.sock", and wraps its client of the network's
+// runtime, see WrapRuntimeClient. The connection is not authenticated, which
+// suits the node's local socket; dial other nodes yourself and pass
+// client.New(conn, runtimeID) to WrapRuntimeClient. Close closes the
+// connection.
+func DialRuntime(ctx context.Context, target string, network NetworkParams, sign sapphire.SignerFn, opts ...Option) (*WrappedBackend, error) {
+	var runtimeID oasisCommon.Namespace
+	if err := runtimeID.UnmarshalHex(network.RuntimeID); err != nil {
+		return nil, fmt.Errorf("invalid runtime ID: %w", err)
+	}
+	conn, err := cmnGrpc.Dial(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial node: %w", err)
+	}
+	b, err := WrapRuntimeClient(ctx, client.New(conn, runtimeID), &network.ChainID, sign, opts...)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	b.conn = conn
+	return b, nil
+}
+
+// WrapRuntimeClient wraps the client of a Sapphire runtime served by an
+// oasis-node, so that transactions and queries are submitted to the node
+// directly instead of through a Web3 gateway. chainID is the runtime's chain
+// ID, see Networks. The runtime key is fetched with the core.CallDataPublicKey
+// query.
+//
+// Ethereum transactions are submitted wrapped in runtime transactions, as the
+// gateway does. SendTransaction waits until the transaction is in a block, so
+// its receipt is known when it returns; receipts of transactions sent through
+// other clients can't be looked up, and neither can logs be filtered. Those
+// requests fail with ErrUnsupportedBackend. Runtime module errors are
+// reported as a CallFailedError, or a RevertError for reverts, as they are by
+// the gateway. The sign function and options are as for WrapClient.
+func WrapRuntimeClient(ctx context.Context, rc client.RuntimeClient, chainID *big.Int, sign sapphire.SignerFn, opts ...Option) (*WrappedBackend, error) {
+	rb := newRuntimeBackend(rc, chainID)
+	b := newWrappedBackend(rb, rb, *chainID, nil, sign, opts...)
+	b.runtime = rb
+	km, err := b.keyManager()
+	if err != nil {
+		return nil, err
+	}
+	if km != nil {
+		rb.keys = runtimeKeySource{core: rb.core, km: km}
+	}
+	if err = b.refreshRuntimeCipher(ctx); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// refreshRuntimeCipher is RefreshCipher for backends of a runtime client.
+func (b *WrappedBackend) refreshRuntimeCipher(ctx context.Context) error {
+	cc := callContext{op: KeySourceRuntimeQuery}
+	cipher, err := invoke(ctx, b.mw, rpcKeyFetch, func(ctx context.Context) (sapphire.Cipher, error) {
+		return newKeySourceCipher(ctx, b.runtime.keys)
+	})
+	b.debugRequest(cc, nil, nil, nil, err)
+	if err != nil {
+		return cc.wrap(err)
+	}
+	b.setCipherFrom(cipher, KeySourceRuntimeQuery)
+	return nil
+}
+
+// newKeySourceCipher returns a cipher with a fresh ephemeral keypair for the
+// key of source.
+func newKeySourceCipher(ctx context.Context, source RuntimeKeySource) (sapphire.Cipher, error) {
+	runtimePublicKey, epoch, err := source.RuntimePublicKey(ctx)
+	if err != nil {
+		return nil, keyFetchError(err)
+	}
+	keypair, err := sapphire.NewCurve25519KeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral keypair: %w", err)
+	}
+	return sapphire.NewX25519DeoxysIICipher(keypair, runtimePublicKey, epoch)
+}
+
+type runtimeKeySource struct {
+	core core.V1
+	// km checks the key's signature if set.
+	km *keyManager
+}
+
+// NewRuntimeKeySource returns a RuntimeKeySource that fetches the key with
+// the core.CallDataPublicKey query of a runtime client, e.g. of an
+// oasis-node, on every use.
+func NewRuntimeKeySource(rc client.RuntimeClient) RuntimeKeySource {
+	return runtimeKeySource{core: core.NewV1(rc)}
+}
+
+func (s runtimeKeySource) RuntimePublicKey(ctx context.Context) (*x25519.PublicKey, uint64, error) {
+	res, err := s.core.CallDataPublicKey(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", sapphire.ErrKeyFetchFailed, runtimeError(err))
+	}
+	pubKey, err := newCallDataPublicKey(res, s.km)
+	if err != nil {
+		return nil, 0, err
+	}
+	return (*x25519.PublicKey)(pubKey.PublicKey), pubKey.Epoch, nil
+}
+
+// runtimeBackend implements bind.ContractBackend and bind.DeployBackend with
+// the queries and transactions of a runtime client, doing for each request
+// what the gateway does.
+type runtimeBackend struct {
+	rc       client.RuntimeClient
+	core     core.V1
+	accounts accounts.V1
+	evm      evm.V1
+	keys     RuntimeKeySource
+	signer   types.Signer
+	sent     *sentTxStore
+}
+
+func newRuntimeBackend(rc client.RuntimeClient, chainID *big.Int) *runtimeBackend {
+	return &runtimeBackend{
+		rc:       rc,
+		core:     core.NewV1(rc),
+		accounts: accounts.NewV1(rc),
+		evm:      evm.NewV1(rc),
+		keys:     NewRuntimeKeySource(rc),
+		signer:   types.LatestSignerForChainID(chainID),
+		sent:     newSentTxStore(sentTxStoreSize),
+	}
+}
+
+// runtimeError returns the module error a runtime client failed with, as
+// the gateway reports it.
+func runtimeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	module, code := cmnErrors.Code(err)
+	if module == cmnErrors.UnknownModule || code == cmnErrors.CodeNoError {
+		return err
+	}
+	return &sapphire.CallFailedError{Module: module, Code: code, Message: err.Error()}
+}
+
+// round returns the runtime round of blockNumber, which is the latest one
+// for nil and the special block numbers.
+func round(blockNumber *big.Int) uint64 {
+	if blockNumber == nil || blockNumber.Sign() < 0 {
+		return client.RoundLatest
+	}
+	return blockNumber.Uint64()
+}
+
+// u256 encodes v as the runtime does 256-bit integers.
+func u256(v *big.Int) ([]byte, error) {
+	out := make([]byte, 32)
+	if v == nil {
+		return out, nil
+	}
+	if v.Sign() < 0 || v.BitLen() > 256 {
+		return nil, fmt.Errorf("%s does not fit in uint256", v)
+	}
+	return v.FillBytes(out), nil
+}
+
+// CodeAt implements ContractCaller.
+func (rb *runtimeBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	code, err := rb.evm.Code(ctx, round(blockNumber), contract.Bytes())
+	return code, runtimeError(err)
+}
+
+// CallContract implements ContractCaller with the evm.SimulateCall query.
+func (rb *runtimeBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	gas := call.Gas
+	if gas == 0 {
+		gas = sapphire.DefaultGasLimit
+	}
+	gasPrice, err := u256(call.GasPrice)
+	if err != nil {
+		return nil, err
+	}
+	value, err := u256(call.Value)
+	if err != nil {
+		return nil, err
+	}
+	var to []byte
+	if call.To != nil {
+		to = call.To.Bytes()
+	}
+	res, err := rb.evm.SimulateCall(ctx, round(blockNumber), gasPrice, gas, call.From.Bytes(), to, value, call.Data)
+	return res, runtimeError(err)
+}
+
+// HeaderByNumber implements ContractTransactor. The header only has the
+// number, time and parent hash of the runtime block.
+func (rb *runtimeBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	blk, err := rb.rc.GetBlock(ctx, round(number))
+	if err != nil {
+		return nil, runtimeError(err)
+	}
+	return &types.Header{
+		ParentHash: common.Hash(blk.Header.PreviousHash),
+		Number:     new(big.Int).SetUint64(blk.Header.Round),
+		Time:       uint64(blk.Header.Timestamp),
+		Difficulty: new(big.Int),
+	}, nil
+}
+
+// PendingCodeAt implements ContractTransactor.
+func (rb *runtimeBackend) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return rb.CodeAt(ctx, account, nil)
+}
+
+// PendingNonceAt implements ContractTransactor.
+func (rb *runtimeBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return rb.NonceAt(ctx, account, nil)
+}
+
+// NonceAt returns the nonce of account at blockNumber, for leashes of signed
+// queries at historical blocks.
+func (rb *runtimeBackend) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	nonce, err := rb.accounts.Nonce(ctx, round(blockNumber), sdkTypes.NewAddressFromEth(account.Bytes()))
+	return nonce, runtimeError(err)
+}
+
+// SuggestGasPrice implements ContractTransactor with the runtime's minimum
+// gas price.
+func (rb *runtimeBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	prices, err := rb.core.MinGasPrice(ctx)
+	if err != nil {
+		return nil, runtimeError(err)
+	}
+	price, ok := prices[sdkTypes.NativeDenomination]
+	if !ok {
+		return nil, errors.New("runtime has no minimum gas price in its native denomination")
+	}
+	return price.ToBigInt(), nil
+}
+
+// SuggestGasTipCap implements ContractTransactor. The runtime doesn't
+// prioritize by tips.
+func (rb *runtimeBackend) SuggestGasTipCap(context.Context) (*big.Int, error) {
+	return new(big.Int), nil
+}
+
+// EstimateGas implements ContractTransactor with the core.EstimateGas query
+// for an evm.Call or evm.Create transaction from the caller.
+func (rb *runtimeBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	value, err := u256(call.Value)
+	if err != nil {
+		return 0, err
+	}
+	fee := &sdkTypes.Fee{Gas: call.Gas}
+	var tx *sdkTypes.Transaction
+	if call.To == nil {
+		tx = evm.NewCreateTx(fee, &evm.Create{Value: value, InitCode: call.Data})
+	} else {
+		tx = evm.NewCallTx(fee, &evm.Call{Address: call.To.Bytes(), Value: value, Data: call.Data})
+	}
+	caller := sdkTypes.CallerAddress{EthAddress: (*[common.AddressLength]byte)(&call.From)}
+	gas, err := rb.core.EstimateGasForCaller(ctx, client.RoundLatest, caller, tx, true)
+	return gas, runtimeError(err)
+}
+
+// SendTransaction implements ContractTransactor. It waits for the
+// transaction to be in a block, and fails with its CheckTx error if it was
+// rejected.
+func (rb *runtimeBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	from, err := types.Sender(rb.signer, tx)
+	if err != nil {
+		return fmt.Errorf("invalid transaction signature: %w", err)
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	meta, err := rb.rc.SubmitTxRawMeta(ctx, &sdkTypes.UnverifiedTransaction{
+		Body:       raw,
+		AuthProofs: []sdkTypes.AuthProof{{Module: ethereumAuthModule}},
+	})
+	if err != nil {
+		return runtimeError(err)
+	}
+	if checkErr := meta.CheckTxError; checkErr != nil {
+		return &sapphire.CallFailedError{Module: checkErr.Module, Code: checkErr.Code, Message: checkErr.Message}
+	}
+	rb.sent.put(tx.Hash(), &sentTx{tx: tx, from: from, meta: meta})
+	return nil
+}
+
+// FilterLogs implements ContractFilterer, which the runtime client doesn't
+// support.
+func (rb *runtimeBackend) FilterLogs(context.Context, ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, fmt.Errorf("%w: cannot filter logs of a runtime client", sapphire.ErrUnsupportedBackend)
+}
+
+// SubscribeFilterLogs implements ContractFilterer, which the runtime client
+// doesn't support.
+func (rb *runtimeBackend) SubscribeFilterLogs(context.Context, ethereum.FilterQuery, chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, fmt.Errorf("%w: cannot filter logs of a runtime client", sapphire.ErrUnsupportedBackend)
+}
+
+// TransactionReceipt implements DeployBackend for the transactions sent
+// through rb, from the results and events of their block.
+func (rb *runtimeBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	sent, ok := rb.sent.get(txHash)
+	if !ok {
+		return nil, fmt.Errorf("%w: receipt of %s not sent through this runtime client", sapphire.ErrUnsupportedBackend, txHash.Hex())
+	}
+	blk, err := rb.rc.GetBlock(ctx, sent.meta.Round)
+	if err != nil {
+		return nil, runtimeError(err)
+	}
+	txs, err := rb.rc.GetTransactionsWithResults(ctx, sent.meta.Round)
+	if err != nil {
+		return nil, runtimeError(err)
+	}
+	if int(sent.meta.BatchOrder) >= len(txs) {
+		return nil, fmt.Errorf("transaction %s missing from round %d", txHash.Hex(), sent.meta.Round)
+	}
+
+	receipt := &types.Receipt{
+		Type:              sent.tx.Type(),
+		Status:            types.ReceiptStatusSuccessful,
+		TxHash:            txHash,
+		EffectiveGasPrice: sent.tx.GasPrice(),
+		BlockHash:         common.Hash(blk.Header.EncodedHash()),
+		BlockNumber:       new(big.Int).SetUint64(sent.meta.Round),
+		TransactionIndex:  uint(sent.meta.BatchOrder),
+	}
+	if sent.meta.Result.Failed != nil {
+		receipt.Status = types.ReceiptStatusFailed
+	}
+	if sent.tx.To() == nil && receipt.Status == types.ReceiptStatusSuccessful {
+		receipt.ContractAddress = crypto.CreateAddress(sent.from, sent.tx.Nonce())
+	}
+	// Logs are indexed and gas used counted from the start of the block.
+	var logIndex uint
+	for i, twr := range txs[:sent.meta.BatchOrder+1] {
+		gasUsed, logs, err := decodeTxEvents(twr.Events)
+		if err != nil {
+			return nil, fmt.Errorf("malformed events of round %d: %w", sent.meta.Round, err)
+		}
+		receipt.CumulativeGasUsed += gasUsed
+		if i < int(sent.meta.BatchOrder) {
+			logIndex += uint(len(logs))
+			continue
+		}
+		receipt.GasUsed = gasUsed
+		for _, ev := range logs {
+			log := &types.Log{
+				Address:     common.BytesToAddress(ev.Address),
+				Data:        ev.Data,
+				BlockNumber: sent.meta.Round,
+				TxHash:      txHash,
+				TxIndex:     receipt.TransactionIndex,
+				BlockHash:   receipt.BlockHash,
+				Index:       logIndex,
+			}
+			for _, topic := range ev.Topics {
+				log.Topics = append(log.Topics, common.BytesToHash(topic))
+			}
+			receipt.Logs = append(receipt.Logs, log)
+			logIndex++
+		}
+	}
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	return receipt, nil
+}
+
+// decodeTxEvents returns the gas used and the EVM logs of a transaction from
+// its runtime events.
+func decodeTxEvents(events []*sdkTypes.Event) (uint64, []*evm.Event, error) {
+	var gasUsed uint64
+	var logs []*evm.Event
+	for _, ev := range events {
+		switch ev.Module {
+		case "core":
+			if ev.Code != core.GasUsedEventCode {
+				continue
+			}
+			decoded, err := core.DecodeEvent(ev)
+			if err != nil {
+				return 0, nil, err
+			}
+			for _, d := range decoded {
+				gasUsed += d.(*core.Event).GasUsed.Amount
+			}
+		case evm.ModuleName:
+			decoded, err := evm.DecodeEvent(ev)
+			if err != nil {
+				return 0, nil, err
+			}
+			for _, d := range decoded {
+				logs = append(logs, d.(*evm.Event))
+			}
+		}
+	}
+	return gasUsed, logs, nil
+}
+
+// sentTx is a transaction sent through a runtime client and where it was
+// included.
+type sentTx struct {
+	tx   *types.Transaction
+	from common.Address
+	meta *client.SubmitTxRawMeta
+}
+
+// sentTxStore remembers the most recent transactions sent through a runtime
+// client, like plaintextStore.
+type sentTxStore struct {
+	mu      sync.Mutex
+	txs     map[common.Hash]*sentTx
+	order   []common.Hash
+	next    int
+	maxSize int
+}
+
+func newSentTxStore(size int) *sentTxStore {
+	return &sentTxStore{
+		txs:     make(map[common.Hash]*sentTx, size),
+		order:   make([]common.Hash, 0, size),
+		maxSize: size,
+	}
+}
+
+// put records the transaction, evicting the oldest one if the store is full.
+func (s *sentTxStore) put(txHash common.Hash, tx *sentTx) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.txs[txHash]; ok {
+		return
+	}
+	if len(s.order) < s.maxSize {
+		s.order = append(s.order, txHash)
+	} else {
+		delete(s.txs, s.order[s.next])
+		s.order[s.next] = txHash
+		s.next = (s.next + 1) % s.maxSize
+	}
+	s.txs[txHash] = tx
+}
+
+// get returns the transaction, if it is known.
+func (s *sentTxStore) get(txHash common.Hash) (*sentTx, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, ok := s.txs[txHash]
+	return tx, ok
+}
@@ -0,0 +1,208 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/mockgateway"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+// wrapRuntime wraps the runtime client of gw with a single-account keyring.
+func wrapRuntime(t *testing.T, gw *mockgateway.Gateway, opts ...Option) (*WrappedBackend, common.Address) {
+	keyring := newTestKeyring(t, 1)
+	b, err := WrapRuntimeClient(context.Background(), gw.RuntimeClient(), gw.ChainID(), nil, append(opts, WithKeyring(keyring))...)
+	if err != nil {
+		t.Fatalf("failed to wrap runtime client: %v", err)
+	}
+	return b, keyring.Addresses()[0]
+}
+
+func TestRuntimeClientCall(t *testing.T) {
+	ctx := context.Background()
+	gw := mockgateway.New(t)
+	gw.OnCall(func(call mockgateway.Call) ([]byte, error) {
+		if bytes.Equal(call.Data, []byte("revert")) {
+			return nil, &mockgateway.Failure{Module: "evm", Code: 8, Message: "reverted: "}
+		}
+		return append([]byte("echo "), call.Data...), nil
+	})
+	b, from := wrapRuntime(t, gw)
+	if b.KeySource() != KeySourceRuntimeQuery || len(gw.Requests(mockgateway.MethodCallDataPublicKey)) != 1 {
+		t.Fatalf("expected the key from the runtime query, got %q", b.KeySource())
+	}
+
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	out, err := b.CallContract(ctx, ethereum.CallMsg{From: from, To: &to, Data: []byte("hi")}, nil)
+	if err != nil || string(out) != "echo hi" {
+		t.Fatalf("unexpected result %q: %v", out, err)
+	}
+	calls := gw.Calls(mockgateway.MethodSimulateCall)
+	if len(calls) != 1 || calls[0].Epoch != mockgateway.DefaultEpoch || calls[0].From != from || *calls[0].To != to || bytes.Contains(calls[0].Envelope, []byte("hi")) {
+		t.Fatalf("expected an encrypted simulated call, got %+v", calls)
+	}
+	if len(gw.Requests("eth_call")) != 0 {
+		t.Fatalf("expected no JSON-RPC requests")
+	}
+
+	_, err = b.CallContract(ctx, ethereum.CallMsg{From: from, To: &to, Data: []byte("revert")}, nil)
+	var revert *sapphire.RevertError
+	if !errors.As(err, &revert) {
+		t.Fatalf("expected a revert, got %v", err)
+	}
+
+	// Refreshing the cipher queries the rotated key.
+	epoch := gw.RotateKey()
+	if err = b.RefreshCipher(ctx); err != nil {
+		t.Fatalf("failed to refresh cipher: %v", err)
+	}
+	if _, err = b.CallContract(ctx, ethereum.CallMsg{From: from, To: &to, Data: []byte("hi")}, nil); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if calls = gw.Calls(mockgateway.MethodSimulateCall); calls[len(calls)-1].Epoch != epoch {
+		t.Fatalf("expected a call for epoch %d, got %d", epoch, calls[len(calls)-1].Epoch)
+	}
+
+	gw.Fail(mockgateway.MethodCallDataPublicKey, &mockgateway.Failure{Module: "core", Code: 1, Message: "no key"})
+	if err = b.RefreshCipher(ctx); !errors.Is(err, sapphire.ErrKeyFetchFailed) {
+		t.Fatalf("expected the key fetch to fail, got %v", err)
+	}
+}
+
+func TestRuntimeClientTransaction(t *testing.T) {
+	ctx := context.Background()
+	gw := mockgateway.New(t)
+	gw.SetLogs(&evm.Event{Address: make([]byte, 20), Topics: [][]byte{make([]byte, 32)}, Data: []byte("log")})
+	b, from := wrapRuntime(t, gw)
+	opts := b.Transactor(from)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	send := func(nonce uint64, data string) *types.Transaction {
+		tx, err := opts.Signer(from, types.NewTransaction(nonce, to, big.NewInt(0), 100_000, big.NewInt(mockgateway.DefaultGasPrice), []byte(data)))
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		if err = b.SendTransaction(ctx, tx); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+		return tx
+	}
+	tx := send(0, "first")
+	sent := gw.Calls(mockgateway.MethodSubmitTx)
+	if len(sent) != 1 || string(sent[0].Data) != "first" || sent[0].Epoch != mockgateway.DefaultEpoch || sent[0].From != from {
+		t.Fatalf("expected an encrypted transaction, got %+v", sent)
+	}
+	receipt, err := bind.WaitMined(ctx, b, tx)
+	if err != nil {
+		t.Fatalf("failed to wait for the receipt: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful || receipt.GasUsed != mockgateway.DefaultGas || len(receipt.Logs) != 1 || string(receipt.Logs[0].Data) != "log" || receipt.TxHash != tx.Hash() {
+		t.Fatalf("unexpected receipt %+v", receipt)
+	}
+
+	// Failed transactions are included, and their receipts count the gas of
+	// the transactions before them.
+	gw.OnCall(func(mockgateway.Call) ([]byte, error) {
+		return nil, &mockgateway.Failure{Module: "evm", Code: 8, Message: "reverted: "}
+	})
+	tx = send(1, "second")
+	if receipt, err = b.TransactionReceipt(ctx, tx.Hash()); err != nil {
+		t.Fatalf("failed to fetch the receipt: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusFailed || receipt.TransactionIndex != 1 || receipt.CumulativeGasUsed != 2*mockgateway.DefaultGas || len(receipt.Logs) != 0 {
+		t.Fatalf("unexpected receipt %+v", receipt)
+	}
+
+	// Transactions rejected by CheckTx fail with the module error.
+	tx, _ = opts.Signer(from, types.NewTransaction(0, to, big.NewInt(0), 100_000, big.NewInt(mockgateway.DefaultGasPrice), nil))
+	var failed *sapphire.CallFailedError
+	if err = b.SendTransaction(ctx, tx); !errors.As(err, &failed) || failed.Module != "core" || failed.Code != 4 {
+		t.Fatalf("expected an invalid nonce, got %v", err)
+	}
+
+	if _, err = b.TransactionReceipt(ctx, common.Hash{1}); !errors.Is(err, sapphire.ErrUnsupportedBackend) {
+		t.Fatalf("expected receipts of other transactions to be unsupported, got %v", err)
+	}
+	if _, err = b.FilterLogs(ctx, ethereum.FilterQuery{}); !errors.Is(err, sapphire.ErrUnsupportedBackend) {
+		t.Fatalf("expected log filters to be unsupported, got %v", err)
+	}
+}
+
+func TestRuntimeClientQueries(t *testing.T) {
+	ctx := context.Background()
+	gw := mockgateway.New(t)
+	b, from := wrapRuntime(t, gw)
+	gw.SetNonce(from, 7)
+	gw.SetGas(30_000)
+
+	if nonce, err := b.PendingNonceAt(ctx, from); err != nil || nonce != 7 {
+		t.Fatalf("expected nonce 7, got %d: %v", nonce, err)
+	}
+	if price, err := b.SuggestGasPrice(ctx); err != nil || price.Cmp(big.NewInt(mockgateway.DefaultGasPrice)) != 0 {
+		t.Fatalf("expected the minimum gas price, got %v: %v", price, err)
+	}
+	header, err := b.HeaderByNumber(ctx, nil)
+	if err != nil || header.Number == nil {
+		t.Fatalf("failed to fetch the header: %v", err)
+	}
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	gas, err := b.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &to, Data: []byte("estimate")})
+	if err != nil || gas < 30_000 {
+		t.Fatalf("unexpected estimate %d: %v", gas, err)
+	}
+	if calls := gw.Calls(mockgateway.MethodEstimateGas); len(calls) != 1 || string(calls[0].Data) != "estimate" || calls[0].From != from {
+		t.Fatalf("expected an encrypted estimate, got %+v", calls)
+	}
+}
+
+func TestRuntimeClientLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	if localnet.Node == "" {
+		t.Skip("SAPPHIRE_LOCALNET_NODE not set")
+	}
+	signer := sapphire.NewPrivateKeySigner(localnet.Accounts[0].Key)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	b, err := DialRuntime(ctx, localnet.Node, Networks[0x5afd], nil, WithKeyring(sapphire.NewKeyring(signer)))
+	if err != nil {
+		t.Fatalf("failed to dial node: %v", err)
+	}
+	defer b.Close()
+
+	parsed, _ := abi.JSON(strings.NewReader(setterABI))
+	opts := b.Transactor(signer.Address())
+	opts.Context = ctx
+	addr, deployTx, contract, err := bind.DeployContract(opts, parsed, perSenderStorageCode, b)
+	if err != nil {
+		t.Fatalf("failed to deploy: %v", err)
+	}
+	if _, err = bind.WaitDeployed(ctx, b, deployTx); err != nil {
+		t.Fatalf("deployment failed: %v", err)
+	}
+	tx, err := contract.Transact(opts, "set", big.NewInt(7))
+	if err != nil {
+		t.Fatalf("Transact failed: %v", err)
+	}
+	if receipt, err := bind.WaitMined(ctx, b, tx); err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("transaction failed: %v", err)
+	}
+
+	plaintext, _ := parsed.Pack("set", big.NewInt(7))
+	stored, err := b.CallContract(ctx, ethereum.CallMsg{From: signer.Address(), To: &addr}, nil)
+	if err != nil || !bytes.Equal(stored, plaintext) {
+		t.Fatalf("expected the stored calldata, got %x: %v", stored, err)
+	}
+}
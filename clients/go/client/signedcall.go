@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// WithAutoLeash binds a query signed by NewSignedCall to a leash on the
+// latest block and the caller's pending nonce, fetched with backend.
+func WithAutoLeash(backend bind.ContractBackend) sapphire.CallOption {
+	return sapphire.WithLeashFunc(func(ctx context.Context, caller common.Address) (*evm.Leash, error) {
+		return newWrappedBackend(backend, nil, big.Int{}, sapphire.NewPlainCipher(), nil).makeLeash(ctx, caller, nil)
+	})
+}
+
+// SignedCall performs a single authenticated confidential query of msg.To
+// with an unwrapped client and returns the decrypted result.
+//
+// It fetches the runtime's public key, builds a leash for msg.From, signs and
+// encrypts the call and decrypts the response. If msg.From is empty and the
+// signer is a SignerWithAddress, the signer's address is used. Errors name the
+// stage that failed.
+//
+// Use WrapClient instead when making more than a few queries, as SignedCall
+// fetches the runtime's public key and chain ID every time.
+func SignedCall(ctx context.Context, c *ethclient.Client, signer sapphire.Signer, msg ethereum.CallMsg) ([]byte, error) {
+	if msg.From == (common.Address{}) {
+		s, ok := signer.(sapphire.SignerWithAddress)
+		if !ok {
+			return nil, fmt.Errorf("signed call: %w: msg.From is not set", sapphire.ErrNoSigner)
+		}
+		msg.From = s.Address()
+	}
+	chainID, err := c.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("signed call: fetching chain ID: %w", err)
+	}
+	cipher, err := NewCipherContext(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("signed call: fetching runtime public key: %w", err)
+	}
+	b := newWrappedBackend(c, c, *chainID, cipher, nil)
+	leash, err := b.makeLeash(ctx, msg.From, nil)
+	if err != nil {
+		return nil, fmt.Errorf("signed call: building leash: %w", err)
+	}
+	sign := func(digest [32]byte) ([]byte, error) {
+		return sapphire.SignDigest(ctx, signer, digest)
+	}
+	packedCall, err := PackSignedCall(msg, cipher, sign, *chainID, leash)
+	if err != nil {
+		return nil, fmt.Errorf("signed call: signing call: %w", err)
+	}
+	res, err := c.CallContract(ctx, *packedCall, nil)
+	if err != nil {
+		return nil, fmt.Errorf("signed call: eth_call: %w", signedQueryError(err))
+	}
+	decrypted, err := cipher.DecryptEncoded(res)
+	if err != nil {
+		return nil, fmt.Errorf("signed call: decrypting result: %w", signedQueryError(err))
+	}
+	return decrypted, nil
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+func TestSignedCall(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	rt := newRPCTransport()
+	rt.handle("eth_call", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Bytes(cbor.Marshal(sdkTypes.CallResult{Ok: opaqueResult})), nil
+	})
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	res, err := SignedCall(context.Background(), dialTransport(t, rt), signer, ethereum.CallMsg{To: &to, Data: TestData})
+	if err != nil {
+		t.Fatalf("SignedCall failed: %v", err)
+	}
+	if !bytes.Equal(res, opaqueResult) {
+		t.Fatalf("unexpected result %x", res)
+	}
+	var pack evm.SignedCallDataPack
+	if err = cbor.Unmarshal(recordedCallInput(t, rt, "eth_call"), &pack); err != nil {
+		t.Fatalf("call is not a signed call data pack: %v", err)
+	}
+	if pack.Data.Format != sdkTypes.CallFormatEncryptedX25519DeoxysII {
+		t.Fatalf("call data is not encrypted")
+	}
+}
+
+func TestSignedCallStages(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	failing := func([]json.RawMessage) (interface{}, error) {
+		return nil, errors.New("unavailable")
+	}
+
+	for _, tc := range []struct {
+		method string
+		stage  string
+	}{
+		{"eth_chainId", "fetching chain ID"},
+		{"oasis_callDataPublicKey", "fetching runtime public key"},
+		{"eth_getBlockByNumber", "building leash"},
+		{"eth_call", "eth_call"},
+	} {
+		rt := newRPCTransport()
+		rt.handle(tc.method, failing)
+		_, err := SignedCall(context.Background(), dialTransport(t, rt), signer, ethereum.CallMsg{To: &to, Data: TestData})
+		if err == nil || !strings.Contains(err.Error(), "signed call: "+tc.stage+":") {
+			t.Fatalf("expected %s to fail at stage %q, got %v", tc.method, tc.stage, err)
+		}
+	}
+
+	rt := newRPCTransport()
+	fn := func([32]byte) ([]byte, error) { return nil, errors.New("signer offline") }
+	_, err := SignedCall(context.Background(), dialTransport(t, rt), sapphire.NewSignerWithAddress(signer.Address(), fn), ethereum.CallMsg{To: &to, Data: TestData})
+	if err == nil || !strings.Contains(err.Error(), "signed call: signing call:") {
+		t.Fatalf("expected signing stage to fail, got %v", err)
+	}
+}
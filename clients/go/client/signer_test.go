@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+func newTestKeyring(t *testing.T, n int) *sapphire.Keyring {
+	keyring := sapphire.NewKeyring()
+	for i := 0; i < n; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		keyring.Add(sapphire.NewPrivateKeySigner(key))
+	}
+	return keyring
+}
+
+func TestKeyring(t *testing.T) {
+	keyring := newTestKeyring(t, 3)
+	addrs := keyring.Addresses()
+	if len(addrs) != 3 {
+		t.Fatalf("expected 3 addresses, got %d", len(addrs))
+	}
+	for i := 1; i < len(addrs); i++ {
+		if addrs[i-1].Cmp(addrs[i]) >= 0 {
+			t.Fatalf("addresses not sorted: %v", addrs)
+		}
+	}
+
+	s, ok := keyring.Signer(addrs[1])
+	if !ok || s.Address() != addrs[1] {
+		t.Fatalf("signer lookup failed for %s", addrs[1])
+	}
+
+	keyring.Remove(addrs[1])
+	if _, ok = keyring.Signer(addrs[1]); ok {
+		t.Fatalf("signer should have been removed")
+	}
+}
+
+func TestWrappedBackendNoSigner(t *testing.T) {
+	b := newMockWrappedBackend(newMockBackend(), nil, WithKeyring(newTestKeyring(t, 1)))
+	stranger := common.HexToAddress("0xDce075E1C39b1ae0b75D554558b6451A226ffe00")
+
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	_, err := b.CallContract(context.Background(), ethereum.CallMsg{From: stranger, To: &to, Data: []byte{1}}, nil)
+	if !errors.Is(err, sapphire.ErrNoSigner) {
+		t.Fatalf("expected ErrNoSigner, got %v", err)
+	}
+
+	tx := types.NewTransaction(0, to, big.NewInt(0), 21_000, big.NewInt(sapphire.DefaultGasPrice), nil)
+	if _, err = b.Transactor(stranger).Signer(stranger, tx); !errors.Is(err, sapphire.ErrNoSigner) {
+		t.Fatalf("expected ErrNoSigner, got %v", err)
+	}
+}
+
+func TestWrappedBackendKeyringInterleavedSends(t *testing.T) {
+	const txsPerAccount = 20
+
+	mock := newMockBackend()
+	keyring := newTestKeyring(t, 10)
+	b := newMockWrappedBackend(mock, nil, WithKeyring(keyring))
+	ctx := context.Background()
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(keyring.Addresses()))
+	for _, from := range keyring.Addresses() {
+		wg.Add(1)
+		go func(from common.Address) {
+			defer wg.Done()
+			opts := b.Transactor(from)
+			for i := 0; i < txsPerAccount; i++ {
+				nonce, err := b.PendingNonceAt(ctx, from)
+				if err != nil {
+					errCh <- err
+					return
+				}
+				tx := types.NewTransaction(nonce, to, big.NewInt(1), 21_000, opts.GasPrice, []byte{1, 2, 3})
+				signedTx, err := opts.Signer(from, tx)
+				if err != nil {
+					errCh <- err
+					return
+				}
+				if err = b.SendTransaction(ctx, signedTx); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}(from)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	signer := types.LatestSignerForChainID(big.NewInt(0x5afd))
+	nonces := make(map[common.Address][]uint64)
+	for _, tx := range mock.sentTransactions() {
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			t.Fatalf("failed to recover sender: %v", err)
+		}
+		nonces[from] = append(nonces[from], tx.Nonce())
+	}
+	for _, from := range keyring.Addresses() {
+		got := nonces[from]
+		if len(got) != txsPerAccount {
+			t.Fatalf("expected %d txs from %s, got %d", txsPerAccount, from, len(got))
+		}
+		for i, nonce := range got {
+			if nonce != uint64(i) {
+				t.Fatalf("account %s: expected nonce %d, got %d", from, i, nonce)
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// StatusTimeout bounds Status when the caller's context has no deadline.
+const StatusTimeout = 5 * time.Second
+
+// Status reports what a backend is connected to, see WrappedBackend.Status.
+//
+// Each probe is reported separately: a field is only meaningful if the
+// error that goes with it is nil.
+type Status struct {
+	// ChainID is the chain ID the gateway reports now, which may differ from
+	// the one the backend was created for.
+	ChainID    *big.Int
+	ChainIDErr error
+
+	// ClientVersion is the gateway's web3_clientVersion.
+	ClientVersion    string
+	ClientVersionErr error
+
+	// KeyEpoch is the epoch of the runtime calldata public key the gateway
+	// serves now, fetched from KeySource.
+	KeyEpoch  uint64
+	KeySource string
+	KeyErr    error
+	// OasisNamespace is set when the gateway serves oasis_callDataPublicKey,
+	// i.e. the key didn't have to be fetched with the subcall precompile.
+	OasisNamespace bool
+
+	// LatestBlock is the number of the latest block and LatestBlockAge how
+	// long ago it was produced.
+	LatestBlock    uint64
+	LatestBlockAge time.Duration
+	LatestBlockErr error
+}
+
+// Err returns the errors of all probes that failed, or nil if they all
+// succeeded.
+func (s *Status) Err() error {
+	var errs []error
+	for _, p := range []struct {
+		name string
+		err  error
+	}{
+		{"chain ID", s.ChainIDErr},
+		{"client version", s.ClientVersionErr},
+		{"runtime key", s.KeyErr},
+		{"latest block", s.LatestBlockErr},
+	} {
+		if p.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.name, p.err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Status probes the gateway concurrently for its chain ID, version, runtime
+// key and latest block, e.g. for readiness checks. Probes that fail leave
+// their error in the Status rather than failing the whole report; use
+// Status.Err to check them all.
+//
+// The probes are bounded by StatusTimeout unless ctx has a deadline. The
+// backend's cipher is not replaced by the key fetched.
+func (b *WrappedBackend) Status(ctx context.Context) (*Status, error) {
+	if b.client == nil {
+		return nil, fmt.Errorf("cannot probe status: %w: not created from an ethclient.Client", sapphire.ErrUnsupportedBackend)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, StatusTimeout)
+		defer cancel()
+	}
+
+	s := &Status{}
+	var wg sync.WaitGroup
+	probe := func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+	probe(func() {
+		s.ChainID, s.ChainIDErr = invoke(ctx, b.mw, rpcRead, b.client.ChainID)
+	})
+	probe(func() {
+		s.ClientVersion, s.ClientVersionErr = invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (version string, err error) {
+			err = b.client.Client().CallContext(ctx, &version, "web3_clientVersion")
+			return version, err
+		})
+	})
+	probe(func() {
+		source := KeySourceRPC
+		s.KeyEpoch, s.KeyErr = invoke(ctx, b.mw, rpcKeyFetch, func(ctx context.Context) (epoch uint64, err error) {
+			km, err := b.keyManager()
+			if err != nil {
+				return 0, err
+			}
+			_, epoch, source, _, err = getRuntimePublicKey(ctx, b.client, km)
+			return epoch, err
+		})
+		s.KeySource = source
+		s.OasisNamespace = s.KeyErr == nil && source == KeySourceRPC
+	})
+	probe(func() {
+		header, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (*types.Header, error) {
+			return b.client.HeaderByNumber(ctx, nil)
+		})
+		if err != nil {
+			s.LatestBlockErr = err
+			return
+		}
+		s.LatestBlock = header.Number.Uint64()
+		s.LatestBlockAge = time.Since(time.Unix(int64(header.Time), 0))
+	})
+	wg.Wait()
+	return s, nil
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestStatus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("full gateway", func(t *testing.T) {
+		rt := newRPCTransport()
+		rt.handle("web3_clientVersion", func([]json.RawMessage) (interface{}, error) {
+			return "oasis-web3-gateway/5.1.0", nil
+		})
+		produced := time.Now().Add(-time.Minute)
+		rt.handle("eth_getBlockByNumber", func([]json.RawMessage) (interface{}, error) {
+			return &types.Header{Number: big.NewInt(100), Difficulty: big.NewInt(0), Time: uint64(produced.Unix())}, nil
+		})
+		b, err := WrapClient(dialTransport(t, rt), nil)
+		if err != nil {
+			t.Fatalf("failed to wrap client: %v", err)
+		}
+		s, err := b.Status(ctx)
+		if err != nil || s.Err() != nil {
+			t.Fatalf("Status failed: %v, %v", err, s.Err())
+		}
+		if s.ChainID.Uint64() != 0x5afd || s.ClientVersion != "oasis-web3-gateway/5.1.0" || s.KeyEpoch != 42 || !s.OasisNamespace || s.LatestBlock != 100 {
+			t.Fatalf("unexpected status %+v", s)
+		}
+		if s.LatestBlockAge < time.Minute-time.Second || s.LatestBlockAge > 2*time.Minute {
+			t.Fatalf("unexpected block age %v", s.LatestBlockAge)
+		}
+	})
+
+	t.Run("missing methods", func(t *testing.T) {
+		rt := newRPCTransport()
+		b, err := WrapClient(dialTransport(t, rt), nil)
+		if err != nil {
+			t.Fatalf("failed to wrap client: %v", err)
+		}
+		// Neither the oasis_ namespace nor the subcall precompile are there.
+		rt.handle("oasis_callDataPublicKey", nil)
+		s, err := b.Status(ctx)
+		if err != nil {
+			t.Fatalf("Status failed: %v", err)
+		}
+		if s.ChainIDErr != nil || s.LatestBlockErr != nil || s.LatestBlock != 100 {
+			t.Fatalf("expected the other probes to succeed, got %+v", s)
+		}
+		if !isMethodNotFound(s.ClientVersionErr) {
+			t.Fatalf("expected web3_clientVersion to be missing, got %v", s.ClientVersionErr)
+		}
+		if !errors.Is(s.KeyErr, ErrCapabilityUnsupported{CapabilityCallDataPublicKey}) || s.OasisNamespace {
+			t.Fatalf("expected the key to be unavailable, got %v", s.KeyErr)
+		}
+		if err = s.Err(); !errors.Is(err, s.KeyErr) || !errors.Is(err, s.ClientVersionErr) {
+			t.Fatalf("expected Err to report the failed probes, got %v", err)
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		rt := newRPCTransport()
+		b, err := WrapClient(dialTransport(t, rt), nil)
+		if err != nil {
+			t.Fatalf("failed to wrap client: %v", err)
+		}
+		rt.block("eth_getBlockByNumber")
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		s, err := b.Status(ctx)
+		if err != nil {
+			t.Fatalf("Status failed: %v", err)
+		}
+		if !errors.Is(s.LatestBlockErr, context.DeadlineExceeded) || s.ChainIDErr != nil || s.KeyErr != nil {
+			t.Fatalf("expected only the blocked probe to time out, got %+v", s)
+		}
+	})
+
+	if _, err := newMockWrappedBackend(newMockBackend(), nil).Status(ctx); err == nil {
+		t.Fatalf("expected Status to fail without an ethclient.Client")
+	}
+}
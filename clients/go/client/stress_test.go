@@ -0,0 +1,276 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/mockgateway"
+)
+
+// The stress tests exercise the shared state of a wrapped client from many
+// goroutines against the mock gateway. Run them with -race.
+
+// dialStress dials url with retries, a circuit breaker and head tracking, and
+// closes the backend when the test ends.
+func dialStress(t *testing.T, url string, keyring *sapphire.Keyring, breaker CircuitBreakerPolicy) *WrappedBackend {
+	b, err := Dial(url, nil,
+		WithKeyring(keyring),
+		WithRetry(RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}),
+		WithCircuitBreaker(breaker),
+		WithHeadTracking(5*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(b.Close)
+	return b
+}
+
+// echo answers calls with their data.
+func echo(call mockgateway.Call) ([]byte, error) {
+	return call.Data, nil
+}
+
+// stressCall makes an encrypted call, or a signed query if from is set, and
+// checks that the gateway echoed it.
+func stressCall(ctx context.Context, b *WrappedBackend, from common.Address, data []byte) error {
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	res, err := b.CallContract(ctx, ethereum.CallMsg{From: from, To: &to, Data: data}, nil)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(res, data) {
+		return fmt.Errorf("expected %x, got %x", data, res)
+	}
+	return nil
+}
+
+func TestStressConcurrentUse(t *testing.T) {
+	verifyNoGoroutineLeaks(t)
+	const (
+		workers      = 32
+		opsPerWorker = 25
+	)
+	gw := mockgateway.New(t)
+	gw.OnCall(echo)
+	keyring := newTestKeyring(t, 4)
+	b := dialStress(t, gw.URL, keyring, CircuitBreakerPolicy{FailureThreshold: 1000, OpenDuration: time.Millisecond})
+	accounts := keyring.Addresses()
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Roll the runtime key over and fail requests now and then while
+	// requests are in flight. At most one in eight requests fails, so a
+	// request gets through within its retries however they interleave.
+	var chaos sync.WaitGroup
+	chaos.Add(1)
+	go func() {
+		defer chaos.Done()
+		for ctx.Err() == nil {
+			gw.RotateKey()
+			gw.Fail("eth_call", mockgateway.StatusError(http.StatusServiceUnavailable), nil, nil, nil, nil, nil, nil, nil)
+			gw.Fail("eth_getTransactionCount", mockgateway.ErrDropConnection, nil, nil, nil, nil, nil, nil, nil)
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	var (
+		wg    sync.WaitGroup
+		sends = make([]sync.Mutex, len(accounts))
+	)
+	errCh := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			account := w % len(accounts)
+			from := accounts[account]
+			opts := b.Transactor(from)
+			for i := 0; i < opsPerWorker; i++ {
+				var err error
+				data := []byte(fmt.Sprintf("worker %d op %d", w, i))
+				switch (w + i) % 5 {
+				case 0:
+					err = stressCall(ctx, b, common.Address{}, data)
+				case 1:
+					err = stressCall(ctx, b, from, data)
+				case 2:
+					_, err = b.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &to, Data: data})
+				case 3:
+					err = b.RefreshCipher(ctx)
+				case 4:
+					// Fetching and committing a nonce is not atomic, so
+					// workers sharing an account take turns sending.
+					sends[account].Lock()
+					var nonce uint64
+					if nonce, err = b.PendingNonceAt(ctx, from); err == nil {
+						var tx *types.Transaction
+						tx, err = opts.Signer(from, types.NewTransaction(nonce, to, big.NewInt(1), 100_000, opts.GasPrice, data))
+						if err == nil {
+							err = b.SendTransaction(ctx, tx)
+						}
+					}
+					sends[account].Unlock()
+				}
+				if err != nil {
+					errCh <- fmt.Errorf("worker %d op %d: %w", w, i, err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	cancel()
+	chaos.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Error(err)
+	}
+
+	seen := make(map[common.Address]map[uint64]bool)
+	for _, call := range gw.Calls("eth_sendRawTransaction") {
+		if seen[call.From] == nil {
+			seen[call.From] = make(map[uint64]bool)
+		}
+		if seen[call.From][call.Tx.Nonce()] {
+			t.Fatalf("account %s reused nonce %d", call.From, call.Tx.Nonce())
+		}
+		seen[call.From][call.Tx.Nonce()] = true
+	}
+}
+
+// TestStressFailover switches the gateway behind a proxy while calls are in
+// flight, failing the calls the old gateway was serving.
+func TestStressFailover(t *testing.T) {
+	verifyNoGoroutineLeaks(t)
+	// The gateways serve the same runtime, and so the same key.
+	key, err := sapphire.NewCurve25519KeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate runtime key: %v", err)
+	}
+	primary := mockgateway.New(t, mockgateway.WithKeyPair(key.PublicKey, key.SecretKey))
+	secondary := mockgateway.New(t, mockgateway.WithKeyPair(key.PublicKey, key.SecretKey))
+	primary.OnCall(echo)
+	secondary.OnCall(echo)
+
+	var upstream atomic.Pointer[url.URL]
+	primaryURL, _ := url.Parse(primary.URL)
+	secondaryURL, _ := url.Parse(secondary.URL)
+	upstream.Store(primaryURL)
+	transport := &http.Transport{}
+	t.Cleanup(transport.CloseIdleConnections)
+	proxy := httptest.NewServer(&httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(upstream.Load())
+		},
+		Transport: transport,
+		ErrorLog:  log.New(io.Discard, "", 0),
+	})
+	t.Cleanup(proxy.Close)
+
+	keyring := newTestKeyring(t, 2)
+	b := dialStress(t, proxy.URL, keyring, CircuitBreakerPolicy{FailureThreshold: 1000, OpenDuration: time.Millisecond})
+	accounts := keyring.Addresses()
+	primary.Delay("eth_call", time.Millisecond)
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 16)
+	for w := 0; w < 16; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 40; i++ {
+				var from common.Address
+				if i%2 == 0 {
+					from = accounts[w%len(accounts)]
+				}
+				if err := stressCall(context.Background(), b, from, []byte(fmt.Sprintf("worker %d op %d", w, i))); err != nil {
+					errCh <- fmt.Errorf("worker %d op %d: %w", w, i, err)
+					return
+				}
+				if w == 0 && i == 10 {
+					primary.Fail("eth_call", mockgateway.ErrDropConnection, mockgateway.ErrDropConnection, mockgateway.ErrDropConnection)
+					upstream.Store(secondaryURL)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Error(err)
+	}
+	if len(primary.Calls("eth_call")) == 0 || len(secondary.Calls("eth_call")) == 0 {
+		t.Fatalf("expected both gateways to serve calls, got %d and %d", len(primary.Calls("eth_call")), len(secondary.Calls("eth_call")))
+	}
+}
+
+// TestStressCancellation cancels calls in flight from many goroutines.
+// Cancelled calls must fail with their context's error, without opening the
+// circuit breaker or breaking the client.
+func TestStressCancellation(t *testing.T) {
+	verifyNoGoroutineLeaks(t)
+	gw := mockgateway.New(t)
+	gw.OnCall(echo)
+	gw.Delay("eth_call", 5*time.Millisecond)
+	var opened atomic.Bool
+	b := dialStress(t, gw.URL, newTestKeyring(t, 1), CircuitBreakerPolicy{
+		FailureThreshold: 3,
+		OpenDuration:     time.Minute,
+		OnStateChange: func(_, to CircuitState) {
+			if to == CircuitOpen {
+				opened.Store(true)
+			}
+		},
+	})
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 200)
+	for w := 0; w < 200; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(w)))
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rng.Intn(10))*time.Millisecond)
+			defer cancel()
+			if w%2 == 0 {
+				go cancel()
+			}
+			err := stressCall(ctx, b, common.Address{}, []byte{byte(w)})
+			if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+				errCh <- fmt.Errorf("worker %d: expected a context error, got %w", w, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Error(err)
+	}
+	if opened.Load() {
+		t.Fatalf("expected cancelled calls not to open the circuit breaker")
+	}
+	if err := stressCall(context.Background(), b, common.Address{}, []byte("after")); err != nil {
+		t.Fatalf("expected the client to still work, got %v", err)
+	}
+}
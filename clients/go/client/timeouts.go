@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Timeouts bounds how long requests to the gateway may take when the
+// caller's context has no deadline of its own. A zero duration leaves the
+// corresponding requests bounded by the caller's context only.
+//
+// Retries happen within the same deadline, so a timeout bounds the total
+// time spent on a request including all of its attempts.
+type Timeouts struct {
+	// KeyFetch bounds fetching the runtime's calldata public key.
+	KeyFetch time.Duration
+	// Call bounds calls, gas estimates and other reads.
+	Call time.Duration
+	// Send bounds submitting a transaction.
+	Send time.Duration
+	// Subscribe bounds establishing a subscription. It does not limit the
+	// lifetime of the subscription itself.
+	Subscribe time.Duration
+}
+
+// DefaultTimeouts are reasonable timeouts for talking to a public gateway.
+var DefaultTimeouts = Timeouts{
+	KeyFetch:  10 * time.Second,
+	Call:      30 * time.Second,
+	Send:      60 * time.Second,
+	Subscribe: 30 * time.Second,
+}
+
+func (t *Timeouts) timeout(kind rpcKind) time.Duration {
+	switch kind {
+	case rpcKeyFetch:
+		return t.KeyFetch
+	case rpcSend:
+		return t.Send
+	case rpcSubscribe:
+		return t.Subscribe
+	default:
+		return t.Call
+	}
+}
+
+// withTimeout derives a context with the timeout for kind, unless ctx
+// already has a deadline.
+func (t *Timeouts) withTimeout(ctx context.Context, kind rpcKind) (context.Context, context.CancelFunc) {
+	if t == nil {
+		return ctx, func() {}
+	}
+	d := t.timeout(kind)
+	if _, ok := ctx.Deadline(); ok || d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
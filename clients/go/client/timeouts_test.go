@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+const (
+	slowRequest  = 500 * time.Millisecond
+	shortTimeout = 50 * time.Millisecond
+)
+
+// requireTimedOut checks that err is a deadline error returned well before
+// the slow request would have completed.
+func requireTimedOut(t *testing.T, what string, start time.Time, err error) {
+	t.Helper()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("%s: expected deadline exceeded, got %v", what, err)
+	}
+	if elapsed := time.Since(start); elapsed > slowRequest/2 {
+		t.Fatalf("%s: timed out after %s instead of %s", what, elapsed, shortTimeout)
+	}
+}
+
+func newSlowTransport() *rpcTransport {
+	rt := newRPCTransport()
+	rt.handle("eth_call", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Bytes(cbor.Marshal(sdkTypes.CallResult{Ok: opaqueResult})), nil
+	})
+	rt.handle("eth_sendRawTransaction", func([]json.RawMessage) (interface{}, error) {
+		return common.Hash{}, nil
+	})
+	return rt
+}
+
+func TestTimeoutsKeyFetch(t *testing.T) {
+	rt := newSlowTransport()
+	rt.delay("oasis_callDataPublicKey", slowRequest)
+
+	start := time.Now()
+	_, err := WrapClient(dialTransport(t, rt), nil, WithTimeouts(Timeouts{KeyFetch: shortTimeout}))
+	requireTimedOut(t, "key fetch", start, err)
+
+	// Other knobs don't apply to key fetches.
+	if _, err = WrapClient(dialTransport(t, rt), nil, WithTimeouts(Timeouts{Call: shortTimeout, Send: shortTimeout})); err != nil {
+		t.Fatalf("key fetch should not be bounded by other timeouts: %v", err)
+	}
+}
+
+func TestTimeoutsCall(t *testing.T) {
+	rt := newSlowTransport()
+	rt.delay("eth_call", slowRequest)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	call := ethereum.CallMsg{To: &to, Data: TestData}
+
+	b, err := WrapClient(dialTransport(t, rt), nil, WithTimeouts(Timeouts{Call: shortTimeout}))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	start := time.Now()
+	_, err = b.CallContract(context.Background(), call, nil)
+	requireTimedOut(t, "call", start, err)
+
+	// A deadline set by the caller takes precedence.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*slowRequest)
+	defer cancel()
+	if _, err = b.CallContract(ctx, call, nil); err != nil {
+		t.Fatalf("call with a caller deadline failed: %v", err)
+	}
+
+	b, err = WrapClient(dialTransport(t, rt), nil, WithTimeouts(Timeouts{KeyFetch: shortTimeout, Send: shortTimeout}))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	if _, err = b.CallContract(context.Background(), call, nil); err != nil {
+		t.Fatalf("call should not be bounded by other timeouts: %v", err)
+	}
+}
+
+func TestTimeoutsSend(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	rt := newSlowTransport()
+	rt.delay("eth_sendRawTransaction", slowRequest)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	send := func(timeouts Timeouts) error {
+		b, err := WrapClient(dialTransport(t, rt), nil, WithKeyring(sapphire.NewKeyring(signer)), WithTimeouts(timeouts))
+		if err != nil {
+			t.Fatalf("failed to wrap client: %v", err)
+		}
+		opts := b.Transactor(signer.Address())
+		signedTx, err := opts.Signer(signer.Address(), types.NewTransaction(0, to, big.NewInt(1), 21_000, opts.GasPrice, TestData))
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		return b.SendTransaction(context.Background(), signedTx)
+	}
+
+	start := time.Now()
+	requireTimedOut(t, "send", start, send(Timeouts{Send: shortTimeout}))
+	if err := send(Timeouts{KeyFetch: shortTimeout, Call: shortTimeout}); err != nil {
+		t.Fatalf("send should not be bounded by other timeouts: %v", err)
+	}
+}
+
+func TestTimeoutsSubscribe(t *testing.T) {
+	mock := newMockBackend()
+	mock.hook = func(ctx context.Context, method string) error {
+		if method == "eth_subscribe" {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(slowRequest):
+			}
+		}
+		return nil
+	}
+
+	b := newMockWrappedBackend(mock, nil, WithTimeouts(Timeouts{Subscribe: shortTimeout}))
+	start := time.Now()
+	_, err := b.SubscribeFilterLogs(context.Background(), ethereum.FilterQuery{}, make(chan types.Log))
+	requireTimedOut(t, "subscribe", start, err)
+
+	b = newMockWrappedBackend(mock, nil, WithTimeouts(Timeouts{Call: shortTimeout, Send: shortTimeout}))
+	if _, err = b.SubscribeFilterLogs(context.Background(), ethereum.FilterQuery{}, make(chan types.Log)); errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("subscribe should not be bounded by other timeouts")
+	}
+}
+
+func TestTimeoutsBoundRetries(t *testing.T) {
+	rt := newRPCTransport()
+	b, err := WrapClient(dialTransport(t, rt), nil,
+		WithTimeouts(Timeouts{Call: 150 * time.Millisecond}),
+		WithRetry(RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+
+	rt.failNext(5, http.StatusServiceUnavailable)
+	start := time.Now()
+	_, err = b.CodeAt(context.Background(), common.Address{}, nil)
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the last gateway error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Fatalf("retries ran past the call timeout: %s", elapsed)
+	}
+	// The retry after 100ms fits in the deadline, the one after another 200ms doesn't.
+	if got := len(rt.recorded("eth_getCode")); got != 2 {
+		t.Fatalf("expected 2 attempts, got %d", got)
+	}
+}
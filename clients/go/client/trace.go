@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// ErrTracingUnsupported is returned by TraceCall when the gateway does not
+// expose the debug namespace. Such errors are also ErrCapabilityUnsupported.
+var ErrTracingUnsupported = errors.New("gateway does not support debug_traceCall")
+
+// TraceConfig selects and configures the tracer used by TraceCall.
+type TraceConfig struct {
+	// Tracer is the name of the tracer, e.g. "callTracer". The default
+	// struct logger is used if empty.
+	Tracer string `json:"tracer,omitempty"`
+	// TracerConfig holds tracer-specific options.
+	TracerConfig json.RawMessage `json:"tracerConfig,omitempty"`
+	// Timeout overrides the gateway's default tracing timeout, e.g. "10s".
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// TraceCall runs debug_traceCall with the call encrypted, and signed if it has
+// a sender, exactly as CallContract would send it.
+//
+// If the trace reports the call's output, as the callTracer does, the output
+// is decrypted in place. Outputs that cannot be decrypted, e.g. those of
+// reverted calls, are left as returned by the gateway.
+func (b *WrappedBackend) TraceCall(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int, config *TraceConfig) (json.RawMessage, error) {
+	if b.client == nil {
+		return nil, fmt.Errorf("cannot trace call: %w: not created from an ethclient.Client", sapphire.ErrUnsupportedBackend)
+	}
+	if err := b.caps.require(CapabilityDebugTrace); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTracingUnsupported, err)
+	}
+	cc := newCallContext(ctx, "debug_traceCall", call.To, blockNumber)
+	cipher := b.currentCipher()
+	packedCall, _, err := b.packCall(ctx, cipher, call, blockNumber)
+	if err != nil {
+		return nil, cc.wrap(err)
+	}
+	trace, err := invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (json.RawMessage, error) {
+		var trace json.RawMessage
+		err := b.client.Client().CallContext(ctx, &trace, "debug_traceCall", toCallArg(packedCall), toBlockNumArg(blockNumber), config)
+		return trace, err
+	})
+	b.debugRequest(cc, call.Data, packedCall.Data, trace, err)
+	if err = b.caps.observe(CapabilityDebugTrace, err); err != nil {
+		if errors.As(err, new(ErrCapabilityUnsupported)) {
+			err = fmt.Errorf("%w: %w", ErrTracingUnsupported, err)
+		}
+		return nil, cc.wrap(err)
+	}
+	return decryptTraceOutput(cipher, trace), nil
+}
+
+// decryptTraceOutput replaces the top-level output of a trace with its
+// decryption, if it has one.
+func decryptTraceOutput(cipher sapphire.Cipher, trace json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(trace, &fields); err != nil {
+		return trace
+	}
+	rawOutput, ok := fields["output"]
+	if !ok {
+		return trace
+	}
+	var output hexutil.Bytes
+	if err := json.Unmarshal(rawOutput, &output); err != nil {
+		return trace
+	}
+	decrypted, err := cipher.DecryptEncoded(output)
+	if err != nil {
+		return trace
+	}
+	if fields["output"], err = json.Marshal(hexutil.Bytes(decrypted)); err != nil {
+		return trace
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return trace
+	}
+	return out
+}
+
+// toBlockNumArg converts a block number into a block parameter, like ethclient does.
+func toBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	if number.Sign() >= 0 {
+		return hexutil.EncodeBig(number)
+	}
+	return rpc.BlockNumber(number.Int64()).String()
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+type callTrace struct {
+	Type   string         `json:"type"`
+	Input  hexutil.Bytes  `json:"input"`
+	Output *hexutil.Bytes `json:"output"`
+}
+
+func TestTraceCall(t *testing.T) {
+	rt := newRPCTransport()
+	rt.handle("debug_traceCall", func(params []json.RawMessage) (interface{}, error) {
+		var args rpcCallArgs
+		if err := json.Unmarshal(params[0], &args); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":   "CALL",
+			"input":  args.Input,
+			"output": hexutil.Bytes(cbor.Marshal(sdkTypes.CallResult{Ok: opaqueResult})),
+		}, nil
+	})
+	b, err := WrapClient(dialTransport(t, rt), nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	raw, err := b.TraceCall(context.Background(), ethereum.CallMsg{To: &to, Data: TestData}, nil, &TraceConfig{Tracer: "callTracer"})
+	if err != nil {
+		t.Fatalf("TraceCall failed: %v", err)
+	}
+	var trace callTrace
+	if err = json.Unmarshal(raw, &trace); err != nil {
+		t.Fatalf("failed to decode trace: %v", err)
+	}
+	if bytes.Contains(trace.Input, TestData) {
+		t.Fatalf("traced call was not encrypted")
+	}
+	if trace.Output == nil || !bytes.Equal(*trace.Output, opaqueResult) {
+		t.Fatalf("trace output was not decrypted: %s", raw)
+	}
+
+	params := rt.recorded("debug_traceCall")[0].Params
+	if string(params[1]) != `"latest"` || !bytes.Contains(params[2], []byte("callTracer")) {
+		t.Fatalf("unexpected debug_traceCall params %s", params)
+	}
+}
+
+func TestTraceCallUnsupported(t *testing.T) {
+	b, err := WrapClient(dialTransport(t, newRPCTransport()), nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	_, err = b.TraceCall(context.Background(), ethereum.CallMsg{To: &to, Data: TestData}, nil, nil)
+	if !errors.Is(err, ErrTracingUnsupported) {
+		t.Fatalf("expected ErrTracingUnsupported, got %v", err)
+	}
+}
+
+func TestTraceCallLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	client := localnet.Dial(t)
+	b, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(sapphire.NewPrivateKeySigner(key))))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	call := ethereum.CallMsg{From: crypto.PubkeyToAddress(key.PublicKey), To: &to, Data: TestData}
+	raw, err := b.TraceCall(context.Background(), call, nil, &TraceConfig{Tracer: "callTracer"})
+	if errors.Is(err, ErrTracingUnsupported) {
+		t.Skip("localnet does not expose the debug namespace")
+	}
+	if err != nil {
+		t.Fatalf("TraceCall failed: %v", err)
+	}
+	var trace callTrace
+	if err = json.Unmarshal(raw, &trace); err != nil {
+		t.Fatalf("failed to decode trace: %v", err)
+	}
+	if trace.Type != "CALL" {
+		t.Fatalf("unexpected trace %s", raw)
+	}
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// runtimeKeyTTL is how long NewSapphireTransactor and WithPersistentCache
+// reuse a fetched runtime key, well within the epochs the runtime accepts it
+// for.
+const runtimeKeyTTL = 10 * time.Minute
+
+// NewSapphireTransactor returns TransactOpts for abigen bindings used with a
+// plain, unwrapped client, whose Signer encrypts each transaction's calldata
+// before signing it with signer, and the backend to bind the contracts to.
+//
+// The runtime's calldata public key is fetched from client and cached for a
+// few minutes. If chainID is nil, it is fetched from client.
+//
+// The backend is client, except that it estimates gas on encrypted calldata,
+// so that bind doesn't send the plaintext to the gateway when estimating the
+// limit of transactions without one. Further options for the estimates, e.g.
+// WithEnvelopeGasMargin, can be passed as estimateOpts. Contracts bound to
+// client itself must only be sent transactions with an explicit GasLimit.
+// The returned options leave GasLimit zero, and the Signer uses the limit of
+// the transactions it signs verbatim, whether set on the options or a copy.
+//
+// signer must be a SignerWithAddress, which determines the From address. ctx
+// only bounds the setup; transactions honor the Context of the returned
+// options when they are signed, which copies of the options can't change.
+func NewSapphireTransactor(ctx context.Context, client *ethclient.Client, signer sapphire.Signer, chainID *big.Int, estimateOpts ...EncryptTxOption) (*bind.TransactOpts, *TransactorBackend, error) {
+	withAddress, ok := signer.(sapphire.SignerWithAddress)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: signer does not implement SignerWithAddress", sapphire.ErrNoSigner)
+	}
+	if chainID == nil {
+		var err error
+		if chainID, err = client.ChainID(ctx); err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch chain ID: %w", err)
+		}
+	}
+	keySource := &cachedKeySource{source: NewClientKeySource(client), ttl: runtimeKeyTTL}
+	if _, _, err := keySource.RuntimePublicKey(ctx); err != nil {
+		return nil, nil, keyFetchError(err)
+	}
+
+	var estimateCfg encryptTxConfig
+	for _, opt := range estimateOpts {
+		opt(&estimateCfg)
+	}
+	backend := &TransactorBackend{Client: client, keySource: keySource, margin: estimateCfg.margin}
+
+	from := withAddress.Address()
+	txSigner := types.LatestSignerForChainID(chainID)
+	opts := &bind.TransactOpts{
+		From:     from,
+		GasPrice: big.NewInt(sapphire.DefaultGasPrice),
+	}
+	opts.Signer = func(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if addr != from {
+			return nil, bind.ErrNotAuthorized
+		}
+		ctx := opts.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		encrypted, err := EncryptTx(ctx, keySource, tx)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := sapphire.SignDigest(ctx, signer, *(*[32]byte)(txSigner.Hash(encrypted).Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		return encrypted.WithSignature(txSigner, sig)
+	}
+	return opts, backend, nil
+}
+
+// TransactorBackend is the backend returned by NewSapphireTransactor. It is
+// its client, except that it estimates gas on encrypted calldata.
+type TransactorBackend struct {
+	*ethclient.Client
+
+	keySource RuntimeKeySource
+	margin    GasMargin
+}
+
+// EstimateGas implements ContractTransactor. The estimate is made on the
+// call's calldata encrypted to the runtime's key, as NewSapphireTransactor's
+// Signer encrypts it, and includes the margin of WithEnvelopeGasMargin.
+func (b *TransactorBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	encrypted, err := EncryptTx(ctx, b.keySource, types.NewTx(&types.LegacyTx{To: call.To, Data: call.Data}))
+	if err != nil {
+		return 0, err
+	}
+	call.Data = encrypted.Data()
+	gas, err := b.Client.EstimateGas(ctx, call)
+	if err != nil {
+		return 0, err
+	}
+	return b.margin.apply(gas), nil
+}
+
+// cachedKeySource reuses the key returned by source for ttl.
+type cachedKeySource struct {
+	source RuntimeKeySource
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	key     *x25519.PublicKey
+	epoch   uint64
+	fetched time.Time
+}
+
+func (s *cachedKeySource) RuntimePublicKey(ctx context.Context) (*x25519.PublicKey, uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key != nil && time.Since(s.fetched) < s.ttl {
+		return s.key, s.epoch, nil
+	}
+	key, epoch, err := s.source.RuntimePublicKey(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.key, s.epoch, s.fetched = key, epoch, time.Now()
+	return key, epoch, nil
+}
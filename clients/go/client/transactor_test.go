@@ -0,0 +1,174 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+// setterABI is the ABI of a contract with a single setter, as abigen would
+// bind it.
+const setterABI = `[{"type":"function","name":"set","inputs":[{"name":"value","type":"uint256"}],"outputs":[],"stateMutability":"nonpayable"}]`
+
+func TestNewSapphireTransactor(t *testing.T) {
+	key, _ := crypto.HexToECDSA("c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750")
+	signer := sapphire.NewPrivateKeySigner(key)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	ctx := context.Background()
+
+	rt := newRPCTransport()
+	rt.handle("eth_estimateGas", func([]json.RawMessage) (interface{}, error) {
+		return hexutil.Uint64(50_000), nil
+	})
+	var sent []*types.Transaction
+	rt.handle("eth_sendRawTransaction", func(params []json.RawMessage) (interface{}, error) {
+		var raw hexutil.Bytes
+		if err := json.Unmarshal(params[0], &raw); err != nil {
+			return nil, err
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(raw); err != nil {
+			return nil, err
+		}
+		sent = append(sent, tx)
+		return tx.Hash(), nil
+	})
+	client := dialTransport(t, rt)
+
+	if _, _, err := NewSapphireTransactor(ctx, client, rsvSigner{func([32]byte) ([]byte, error) { return nil, nil }}, nil); err == nil {
+		t.Fatalf("expected signers without an address to be rejected")
+	}
+	opts, backend, err := NewSapphireTransactor(ctx, client, signer, nil)
+	if err != nil {
+		t.Fatalf("NewSapphireTransactor failed: %v", err)
+	}
+	if opts.From != signer.Address() {
+		t.Fatalf("unexpected sender %s", opts.From.Hex())
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(setterABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	contract := bind.NewBoundContract(to, parsed, backend, backend, backend)
+	for i := 0; i < 2; i++ {
+		if _, err = contract.Transact(opts, "set", big.NewInt(7)); err != nil {
+			t.Fatalf("Transact failed: %v", err)
+		}
+	}
+
+	for _, tx := range sent {
+		var envelope sdkTypes.Call
+		if err = cbor.Unmarshal(tx.Data(), &envelope); err != nil || envelope.Format != sdkTypes.CallFormatEncryptedX25519DeoxysII {
+			t.Fatalf("calldata is not an encrypted envelope: %x", tx.Data())
+		}
+		if tx.Gas() != 50_000 {
+			t.Fatalf("expected the estimated gas, got %d", tx.Gas())
+		}
+		from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(0x5afd)), tx)
+		if err != nil || from != signer.Address() {
+			t.Fatalf("transaction not signed by the signer: %v", err)
+		}
+	}
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(sent))
+	}
+	// The backend estimates the gas on calldata encrypted as it is sent.
+	estimates := rt.recorded("eth_estimateGas")
+	if len(estimates) != len(sent) {
+		t.Fatalf("expected an estimate per transaction, got %d", len(estimates))
+	}
+	for i, req := range estimates {
+		var call struct {
+			Input hexutil.Bytes `json:"input"`
+		}
+		if err = json.Unmarshal(req.Params[0], &call); err != nil {
+			t.Fatalf("failed to decode estimate: %v", err)
+		}
+		var envelope sdkTypes.Call
+		if err = cbor.Unmarshal(call.Input, &envelope); err != nil || envelope.Format != sdkTypes.CallFormatEncryptedX25519DeoxysII || len(call.Input) != len(sent[i].Data()) {
+			t.Fatalf("expected the estimate of the encrypted calldata, got %x", call.Input)
+		}
+	}
+	if n := len(rt.recorded("oasis_callDataPublicKey")); n != 1 {
+		t.Fatalf("expected the runtime key to be fetched once, got %d", n)
+	}
+
+	other := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	if _, err = opts.Signer(other, sent[0]); !errors.Is(err, bind.ErrNotAuthorized) {
+		t.Fatalf("expected ErrNotAuthorized, got %v", err)
+	}
+}
+
+func TestNewSapphireTransactorLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	signer := sapphire.NewPrivateKeySigner(key)
+	client := localnet.Dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	opts, backend, err := NewSapphireTransactor(ctx, client, signer, nil)
+	if err != nil {
+		t.Fatalf("NewSapphireTransactor failed: %v", err)
+	}
+	opts.Context = ctx
+	parsed, _ := abi.JSON(strings.NewReader(setterABI))
+
+	// Generated bindings deploy and transact through these same calls.
+	addr, deployTx, contract, err := bind.DeployContract(opts, parsed, perSenderStorageCode, backend)
+	if err != nil {
+		t.Fatalf("failed to deploy: %v", err)
+	}
+	if _, err = bind.WaitDeployed(ctx, client, deployTx); err != nil {
+		t.Fatalf("deployment failed: %v", err)
+	}
+	tx, err := contract.Transact(opts, "set", big.NewInt(7))
+	if err != nil {
+		t.Fatalf("Transact failed: %v", err)
+	}
+	receipt, err := bind.WaitMined(ctx, client, tx)
+	if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("transaction failed: %v", err)
+	}
+
+	plaintext, _ := parsed.Pack("set", big.NewInt(7))
+	mined, _, err := client.TransactionByHash(ctx, tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to fetch transaction: %v", err)
+	}
+	if bytes.Contains(mined.Data(), plaintext) {
+		t.Fatalf("calldata was sent in plain text")
+	}
+
+	b, err := WrapClient(client, nil, WithKeyring(sapphire.NewKeyring(signer)))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	stored, err := b.CallContract(ctx, ethereum.CallMsg{From: signer.Address(), To: &addr}, nil)
+	if err != nil {
+		t.Fatalf("failed to read back: %v", err)
+	}
+	if !bytes.Equal(stored, plaintext[:32]) {
+		t.Fatalf("unexpected stored word %x, expected %x", stored, plaintext[:32])
+	}
+}
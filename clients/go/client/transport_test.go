@@ -0,0 +1,253 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// rpcHandler answers a single JSON-RPC method call.
+type rpcHandler func(params []json.RawMessage) (interface{}, error)
+
+// rpcError is a JSON-RPC error object a handler can fail with, e.g. to give
+// the error data.
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcRequestRecord is a request observed by an rpcTransport.
+type rpcRequestRecord struct {
+	Method string
+	Params []json.RawMessage
+	Header http.Header
+	Time   time.Time
+}
+
+// rpcTransport is an http.RoundTripper that serves JSON-RPC requests from
+// in-memory handlers and records every request it sees.
+type rpcTransport struct {
+	mu       sync.Mutex
+	handlers map[string]rpcHandler
+	requests []rpcRequestRecord
+	// status, if non-zero, is returned as the HTTP status of the next
+	// statusCount requests instead of serving them.
+	status      int
+	statusCount int
+	// methodFailures holds how many requests for each method fail with
+	// methodStatus.
+	methodFailures map[string]int
+	methodStatus   int
+	// blocked methods hang until the request context is done.
+	blocked map[string]bool
+	// delays holds how long requests for each method take to be served.
+	delays map[string]time.Duration
+}
+
+func newRPCTransport() *rpcTransport {
+	head := &types.Header{
+		Number:     big.NewInt(100),
+		ParentHash: common.HexToHash("2ec361fee28d09a3ad2c4d5f7f95d409ce2b68c39b5d647edf0ea651e069e4a8"),
+		Difficulty: big.NewInt(0),
+	}
+	return &rpcTransport{
+		handlers: map[string]rpcHandler{
+			"eth_chainId": func([]json.RawMessage) (interface{}, error) {
+				return hexutil.Uint64(0x5afd), nil
+			},
+			"oasis_callDataPublicKey": func([]json.RawMessage) (interface{}, error) {
+				return signTestKey(sapphire.CallDataPublicKey{
+					PublicKey: common.Hex2Bytes("3046db3fa70ce605457dc47c48837ebd8bd0a26abfde5994d033e1ced68e2576"),
+					Epoch:     42,
+				}), nil
+			},
+			"eth_getCode": func([]json.RawMessage) (interface{}, error) {
+				return hexutil.Bytes{0x60, 0x80}, nil
+			},
+			"eth_getTransactionCount": func([]json.RawMessage) (interface{}, error) {
+				return hexutil.Uint64(0), nil
+			},
+			"eth_getBlockByNumber": func([]json.RawMessage) (interface{}, error) {
+				return head, nil
+			},
+			"eth_gasPrice": func([]json.RawMessage) (interface{}, error) {
+				return (*hexutil.Big)(big.NewInt(sapphire.DefaultGasPrice)), nil
+			},
+		},
+	}
+}
+
+// handle serves method with h. A nil handler makes the method unknown.
+func (rt *rpcTransport) handle(method string, h rpcHandler) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if h == nil {
+		delete(rt.handlers, method)
+		return
+	}
+	rt.handlers[method] = h
+}
+
+// block makes requests for method hang until their context is done.
+func (rt *rpcTransport) block(method string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.blocked == nil {
+		rt.blocked = make(map[string]bool)
+	}
+	rt.blocked[method] = true
+}
+
+// delay makes requests for method take d to be served, or until their
+// context is done.
+func (rt *rpcTransport) delay(method string, d time.Duration) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.delays == nil {
+		rt.delays = make(map[string]time.Duration)
+	}
+	rt.delays[method] = d
+}
+
+// failNext makes the next n requests fail with the given HTTP status.
+func (rt *rpcTransport) failNext(n, status int) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.status, rt.statusCount = status, n
+}
+
+// failMethod makes the next n requests for method fail with the given HTTP
+// status.
+func (rt *rpcTransport) failMethod(method string, n, status int) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.methodFailures == nil {
+		rt.methodFailures = make(map[string]int)
+	}
+	rt.methodFailures[method], rt.methodStatus = n, status
+}
+
+func (rt *rpcTransport) recorded(method string) []rpcRequestRecord {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	var out []rpcRequestRecord
+	for _, r := range rt.requests {
+		if method == "" || r.Method == method {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (rt *rpcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	var msg struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err = json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("rpcTransport: batch requests are not supported: %w", err)
+	}
+
+	rt.mu.Lock()
+	rt.requests = append(rt.requests, rpcRequestRecord{
+		Method: msg.Method,
+		Params: msg.Params,
+		Header: req.Header.Clone(),
+		Time:   time.Now(),
+	})
+	if rt.statusCount > 0 || rt.methodFailures[msg.Method] > 0 {
+		status := rt.status
+		if rt.statusCount > 0 {
+			rt.statusCount--
+		} else {
+			rt.methodFailures[msg.Method]--
+			status = rt.methodStatus
+		}
+		rt.mu.Unlock()
+		return &http.Response{
+			StatusCode: status,
+			Status:     http.StatusText(status),
+			Body:       io.NopCloser(bytes.NewReader(nil)),
+			Header:     make(http.Header),
+			Request:    req,
+		}, nil
+	}
+	h, ok := rt.handlers[msg.Method]
+	blocked := rt.blocked[msg.Method]
+	delay := rt.delays[msg.Method]
+	rt.mu.Unlock()
+
+	if blocked {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	if delay > 0 {
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID}
+	switch {
+	case !ok:
+		resp["error"] = map[string]interface{}{"code": -32601, "message": fmt.Sprintf("the method %s does not exist/is not available", msg.Method)}
+	default:
+		result, herr := h(msg.Params)
+		var rerr *rpcError
+		if errors.As(herr, &rerr) {
+			resp["error"] = rerr
+		} else if herr != nil {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": herr.Error()}
+		} else {
+			resp["result"] = result
+		}
+	}
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Body:       io.NopCloser(bytes.NewReader(out)),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Request:    req,
+	}, nil
+}
+
+// dialTransport returns an ethclient.Client whose requests are served by rt.
+func dialTransport(t *testing.T, rt http.RoundTripper) *ethclient.Client {
+	c, err := rpc.DialOptions(context.Background(), "http://gateway.invalid", rpc.WithHTTPClient(&http.Client{Transport: rt}))
+	if err != nil {
+		t.Fatalf("failed to dial mock transport: %v", err)
+	}
+	t.Cleanup(c.Close)
+	return ethclient.NewClient(c)
+}
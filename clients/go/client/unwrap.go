@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// Unwrap returns the ethclient.Client the backend was created from, or nil if
+// it wraps a different backend.
+//
+// Requests made with the returned client bypass the wrapper entirely. In
+// particular CallContract, EstimateGas, SendTransaction and the raw
+// eth_call, eth_estimateGas and eth_sendRawTransaction methods send their
+// calldata in plain text and calls are not signed, so only use it for
+// requests that carry no confidential data, such as fetching blocks, logs or
+// receipts.
+func (b *WrappedBackend) Unwrap() *ethclient.Client {
+	return b.client
+}
+
+// RPC returns the rpc.Client the backend was created from, or nil if it wraps
+// a different backend. See Unwrap for which requests are safe to make with it.
+func (b *WrappedBackend) RPC() *rpc.Client {
+	if b.client == nil {
+		return nil
+	}
+	return b.client.Client()
+}
+
+// chainReader returns the wrapped backend as an ethereum.ChainReader.
+func (b *WrappedBackend) chainReader() (ethereum.ChainReader, error) {
+	r, ok := b.backend.(ethereum.ChainReader)
+	if !ok {
+		return nil, fmt.Errorf("%w: wrapped backend does not implement ethereum.ChainReader", sapphire.ErrUnsupportedBackend)
+	}
+	return r, nil
+}
+
+// BlockByHash implements ChainReader.
+func (b *WrappedBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	r, err := b.chainReader()
+	if err != nil {
+		return nil, err
+	}
+	return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (*types.Block, error) {
+		return r.BlockByHash(ctx, hash)
+	})
+}
+
+// BlockByNumber implements ChainReader.
+func (b *WrappedBackend) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	r, err := b.chainReader()
+	if err != nil {
+		return nil, err
+	}
+	return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (*types.Block, error) {
+		return r.BlockByNumber(ctx, number)
+	})
+}
+
+// HeaderByHash implements ChainReader.
+func (b *WrappedBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	r, err := b.chainReader()
+	if err != nil {
+		return nil, err
+	}
+	return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (*types.Header, error) {
+		return r.HeaderByHash(ctx, hash)
+	})
+}
+
+// TransactionCount implements ChainReader.
+func (b *WrappedBackend) TransactionCount(ctx context.Context, blockHash common.Hash) (uint, error) {
+	r, err := b.chainReader()
+	if err != nil {
+		return 0, err
+	}
+	return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (uint, error) {
+		return r.TransactionCount(ctx, blockHash)
+	})
+}
+
+// TransactionInBlock implements ChainReader.
+func (b *WrappedBackend) TransactionInBlock(ctx context.Context, blockHash common.Hash, index uint) (*types.Transaction, error) {
+	r, err := b.chainReader()
+	if err != nil {
+		return nil, err
+	}
+	return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (*types.Transaction, error) {
+		return r.TransactionInBlock(ctx, blockHash, index)
+	})
+}
+
+// SubscribeNewHead implements ChainReader.
+func (b *WrappedBackend) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	r, err := b.chainReader()
+	if err != nil {
+		return nil, err
+	}
+	return invoke(ctx, b.mw, rpcSubscribe, func(ctx context.Context) (ethereum.Subscription, error) {
+		return r.SubscribeNewHead(ctx, ch)
+	})
+}
+
+// chainStateReader returns the wrapped backend as an ethereum.ChainStateReader.
+func (b *WrappedBackend) chainStateReader() (ethereum.ChainStateReader, error) {
+	r, ok := b.backend.(ethereum.ChainStateReader)
+	if !ok {
+		return nil, fmt.Errorf("%w: wrapped backend does not implement ethereum.ChainStateReader", sapphire.ErrUnsupportedBackend)
+	}
+	return r, nil
+}
+
+// BalanceAt implements ChainStateReader.
+func (b *WrappedBackend) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	r, err := b.chainStateReader()
+	if err != nil {
+		return nil, err
+	}
+	return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (*big.Int, error) {
+		return r.BalanceAt(ctx, account, blockNumber)
+	})
+}
+
+// StorageAt implements ChainStateReader.
+//
+// Contract storage on Sapphire is confidential, so the gateway does not
+// return the plaintext stored by contracts.
+func (b *WrappedBackend) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	r, err := b.chainStateReader()
+	if err != nil {
+		return nil, err
+	}
+	return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) ([]byte, error) {
+		return r.StorageAt(ctx, account, key, blockNumber)
+	})
+}
+
+// NonceAt implements ChainStateReader.
+func (b *WrappedBackend) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	r, err := b.chainStateReader()
+	if err != nil {
+		return 0, err
+	}
+	return invoke(ctx, b.mw, rpcRead, func(ctx context.Context) (uint64, error) {
+		return r.NonceAt(ctx, account, blockNumber)
+	})
+}
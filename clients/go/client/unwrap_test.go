@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	_ bind.ContractBackend       = (*WrappedBackend)(nil)
+	_ bind.DeployBackend         = (*WrappedBackend)(nil)
+	_ ethereum.ChainReader       = (*WrappedBackend)(nil)
+	_ ethereum.ChainStateReader  = (*WrappedBackend)(nil)
+	_ ethereum.TransactionSender = (*WrappedBackend)(nil)
+	_ ethereum.ContractCaller    = (*WrappedBackend)(nil)
+	_ ethereum.GasEstimator      = (*WrappedBackend)(nil)
+	_ ethereum.GasPricer         = (*WrappedBackend)(nil)
+	_ ethereum.LogFilterer       = (*WrappedBackend)(nil)
+)
+
+func TestUnwrap(t *testing.T) {
+	c := dialTransport(t, newRPCTransport())
+	b, err := WrapClient(c, nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	if b.Unwrap() != c {
+		t.Fatalf("Unwrap returned a different client")
+	}
+	if b.RPC() != c.Client() {
+		t.Fatalf("RPC returned a different client")
+	}
+	if _, err = b.HeaderByHash(context.Background(), common.Hash{}); err == nil {
+		t.Fatalf("expected HeaderByHash to reach the gateway and fail")
+	}
+
+	mocked := newMockWrappedBackend(newMockBackend(), nil)
+	if mocked.Unwrap() != nil || mocked.RPC() != nil {
+		t.Fatalf("expected no underlying client for a mock backend")
+	}
+	if _, err = mocked.BlockByHash(context.Background(), common.Hash{}); err == nil {
+		t.Fatalf("expected BlockByHash to fail on a backend that is not a ChainReader")
+	}
+}
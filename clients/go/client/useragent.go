@@ -0,0 +1,47 @@
+package client
+
+import (
+	"runtime/debug"
+)
+
+const (
+	// modulePath is the import path of this module, used to look up its version.
+	modulePath = "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	// clientHeader identifies the client library independently of User-Agent,
+	// which proxies and applications tend to overwrite.
+	clientHeader = "X-Sapphire-Client"
+)
+
+// ClientVersion returns the version of this module as recorded in the build
+// info of the running binary, or "devel" if it is unknown, e.g. in tests.
+func ClientVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	if info.Main.Path == modulePath {
+		return moduleVersion(info.Main)
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return moduleVersion(*dep.Replace)
+			}
+			return moduleVersion(*dep)
+		}
+	}
+	return "devel"
+}
+
+func moduleVersion(m debug.Module) string {
+	if m.Version == "" || m.Version == "(devel)" {
+		return "devel"
+	}
+	return m.Version
+}
+
+// DefaultUserAgent returns the User-Agent that Dial sends by default, of the
+// form sapphire-paratime-go/<version>.
+func DefaultUserAgent() string {
+	return "sapphire-paratime-go/" + ClientVersion()
+}
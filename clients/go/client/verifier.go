@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// DefaultVerifierSkew is how many blocks the verifier of WithEndpointVerifier
+// may lag behind the gateway unless VerifierOptions.MaxSkew says otherwise.
+const DefaultVerifierSkew = 3
+
+// VerifierBackend is an endpoint, independent of the gateway, that
+// WithEndpointVerifier checks the gateway against, e.g. an *ethclient.Client
+// of another provider.
+type VerifierBackend interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// VerifierOptions configure WithEndpointVerifier.
+type VerifierOptions struct {
+	// MaxSkew is how many blocks the verifier may lag behind the gateway,
+	// DefaultVerifierSkew if zero.
+	MaxSkew uint64
+	// ChainID also checks the chain ID when wrapping the client.
+	ChainID bool
+}
+
+func (o VerifierOptions) maxSkew() uint64 {
+	if o.MaxSkew == 0 {
+		return DefaultVerifierSkew
+	}
+	return o.MaxSkew
+}
+
+// verifierHeader fetches a header from the verifier. Only timeouts apply,
+// the rate limits and retries are for the gateway.
+func (b *WrappedBackend) verifierHeader(ctx context.Context, number *big.Int) (*types.Header, error) {
+	ctx, cancel := b.mw.timeouts.withTimeout(ctx, rpcRead)
+	defer cancel()
+	return b.verifier.HeaderByNumber(ctx, number)
+}
+
+// verifyLeashBlock checks the hash the gateway reported for the leash block
+// against the verifier and returns the block to build the leash on. Leashes
+// for the latest block move to the parent of the verifier's latest block if
+// it lags behind, after checking the gateway's hash of that one instead.
+//
+// Sapphire's block hashes are not the hashes of the headers, so like the
+// leash's, the verifier's hash of a block is the parent hash of its child.
+func (b *WrappedBackend) verifyLeashBlock(ctx context.Context, number uint64, hash common.Hash, latest bool) (uint64, common.Hash, error) {
+	child, err := b.verifierHeader(ctx, new(big.Int).SetUint64(number+1))
+	if errors.Is(err, ethereum.NotFound) && latest {
+		child, err = b.verifierHeader(ctx, nil)
+		switch {
+		case err != nil:
+		case child.Number.Uint64() > number:
+			// Caught up in the meantime.
+			child, err = b.verifierHeader(ctx, new(big.Int).SetUint64(number+1))
+		case child.Number.Sign() == 0 || number+1-child.Number.Uint64() > b.verifierOpts.maxSkew():
+			return 0, common.Hash{}, fmt.Errorf("%w: verifier is %d blocks behind the gateway, more than %d", sapphire.ErrEndpointDisagreement, number+1-child.Number.Uint64(), b.verifierOpts.maxSkew())
+		default:
+			header, err := b.HeaderByNumber(ctx, child.Number)
+			if err != nil {
+				return 0, common.Hash{}, fmt.Errorf("failed to fetch leash block header: %w", err)
+			}
+			number, hash = child.Number.Uint64()-1, header.ParentHash
+		}
+	}
+	if err != nil {
+		return 0, common.Hash{}, fmt.Errorf("failed to fetch leash block header from verifier: %w", err)
+	}
+	if child.ParentHash != hash {
+		return 0, common.Hash{}, fmt.Errorf("%w: block %d is %s at the gateway and %s at the verifier", sapphire.ErrEndpointDisagreement, number, hash.Hex(), child.ParentHash.Hex())
+	}
+	return number, hash, nil
+}
+
+// verifyChainID checks the chain ID against the verifier if
+// VerifierOptions.ChainID asks to.
+func (b *WrappedBackend) verifyChainID(ctx context.Context) error {
+	if b.verifier == nil || !b.verifierOpts.ChainID {
+		return nil
+	}
+	ctx, cancel := b.mw.timeouts.withTimeout(ctx, rpcRead)
+	defer cancel()
+	chainID, err := b.verifier.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch chain ID from verifier: %w", err)
+	}
+	if chainID.Cmp(&b.chainID) != 0 {
+		return fmt.Errorf("%w: chain ID is %s at the gateway and %s at the verifier", sapphire.ErrEndpointDisagreement, &b.chainID, chainID)
+	}
+	return nil
+}
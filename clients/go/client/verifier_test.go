@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/mockgateway"
+)
+
+// headerChain returns linked headers of blocks 1 to n. Like Sapphire's, the
+// block hashes they are linked by are not the hashes of the headers. Chains of
+// different forks have different hashes.
+func headerChain(n int, fork byte) []*types.Header {
+	headers := make([]*types.Header, n)
+	for i := range headers {
+		headers[i] = &types.Header{
+			Number:     big.NewInt(int64(i + 1)),
+			ParentHash: runtimeBlockHash(i, fork),
+			Difficulty: big.NewInt(0),
+		}
+	}
+	return headers
+}
+
+// runtimeBlockHash is the hash of block number in the chains of headerChain.
+func runtimeBlockHash(number int, fork byte) common.Hash {
+	if number == 0 {
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash([]byte("runtime block"), []byte{fork}, big.NewInt(int64(number)).Bytes())
+}
+
+func TestEndpointVerifier(t *testing.T) {
+	chain := headerChain(20, 0)
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+	for _, tc := range []struct {
+		name     string
+		verifier []*types.Header
+		opts     VerifierOptions
+		leash    uint64
+		err      error
+	}{
+		{"agree", chain, VerifierOptions{}, 19, nil},
+		{"verifier ahead", headerChain(25, 0), VerifierOptions{}, 19, nil},
+		{"verifier lagging", chain[:17], VerifierOptions{}, 16, nil},
+		{"verifier lagging too far", chain[:15], VerifierOptions{}, 0, sapphire.ErrEndpointDisagreement},
+		{"verifier lagging within skew", chain[:15], VerifierOptions{MaxSkew: 5}, 14, nil},
+		{"fork", headerChain(20, 1), VerifierOptions{}, 0, sapphire.ErrEndpointDisagreement},
+		{"fork while lagging", headerChain(18, 1), VerifierOptions{}, 0, sapphire.ErrEndpointDisagreement},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gw := mockgateway.New(t)
+			gw.SetChain(chain...)
+			verifier := mockgateway.New(t)
+			verifier.SetChain(tc.verifier...)
+
+			keyring := newTestKeyring(t, 1)
+			from := keyring.Addresses()[0]
+			b, err := WrapClient(gw.Dial(t), nil, WithKeyring(keyring), WithEndpointVerifier(verifier.Dial(t), tc.opts))
+			if err != nil {
+				t.Fatalf("failed to wrap client: %v", err)
+			}
+			_, err = b.CallContract(context.Background(), ethereum.CallMsg{From: from, To: &to, Data: []byte{1}}, nil)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected %v, got %v", tc.err, err)
+				}
+				if calls := gw.Calls("eth_call"); len(calls) != 0 {
+					t.Fatalf("expected no query to be sent, got %d", len(calls))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CallContract failed: %v", err)
+			}
+			leash := gw.Calls("eth_call")[0].Leash
+			if leash == nil || leash.BlockNumber != tc.leash || common.BytesToHash(leash.BlockHash) != runtimeBlockHash(int(tc.leash), 0) {
+				t.Fatalf("expected a leash on block %d, got %+v", tc.leash, leash)
+			}
+		})
+	}
+}
+
+func TestEndpointVerifierChainID(t *testing.T) {
+	gw := mockgateway.New(t)
+	verifier := mockgateway.New(t, mockgateway.WithChainID(1))
+
+	_, err := WrapClient(gw.Dial(t), nil, WithEndpointVerifier(verifier.Dial(t), VerifierOptions{ChainID: true}))
+	if !errors.Is(err, sapphire.ErrEndpointDisagreement) {
+		t.Fatalf("expected the chain IDs to disagree, got %v", err)
+	}
+	// Only checked when asked to.
+	if _, err = WrapClient(gw.Dial(t), nil, WithEndpointVerifier(verifier.Dial(t), VerifierOptions{})); err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// loadKey loads the private key given by spec, one of:
+//
+//	HEX                  a hex-encoded key, with or without 0x
+//	env:NAME, file:PATH  a hex-encoded key read from an environment variable or file
+//	keystore:PATH        a go-ethereum keystore file, unlocked with password
+//	mnemonic:SECRET      a BIP-39 mnemonic, itself given as by readSecret, with
+//	                     the key derived at hdPath
+func loadKey(spec, password, hdPath string) (*ecdsa.PrivateKey, error) {
+	switch kind, rest, _ := strings.Cut(spec, ":"); kind {
+	case "keystore":
+		encrypted, err := os.ReadFile(rest)
+		if err != nil {
+			return nil, fmt.Errorf("reading keystore: %w", err)
+		}
+		passphrase, err := readSecret(password)
+		if err != nil {
+			return nil, fmt.Errorf("reading keystore password: %w", err)
+		}
+		key, err := keystore.DecryptKey(encrypted, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("unlocking keystore: %w", err)
+		}
+		return key.PrivateKey, nil
+	case "mnemonic":
+		mnemonic, err := readSecret(rest)
+		if err != nil {
+			return nil, fmt.Errorf("reading mnemonic: %w", err)
+		}
+		path, err := accounts.ParseDerivationPath(hdPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HD path: %w", err)
+		}
+		return deriveKey(mnemonic, path)
+	default:
+		hexKey, err := readSecret(spec)
+		if err != nil {
+			return nil, fmt.Errorf("reading key: %w", err)
+		}
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(hexKey, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid key: %w", err)
+		}
+		return key, nil
+	}
+}
+
+// readSecret returns the value of the environment variable NAME for
+// env:NAME, the content of the file PATH for file:PATH, and spec itself
+// otherwise, without surrounding whitespace.
+func readSecret(spec string) (string, error) {
+	kind, rest, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "env":
+		value, ok := os.LookupEnv(rest)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", rest)
+		}
+		return strings.TrimSpace(value), nil
+	case "file":
+		value, err := os.ReadFile(rest)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(value)), nil
+	default:
+		return strings.TrimSpace(spec), nil
+	}
+}
+
+// deriveKey derives the secp256k1 key at path from the seed of mnemonic per
+// BIP-32, as wallets do for path m/44'/60'/0'/0/i.
+func deriveKey(mnemonic string, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %w", err)
+	}
+	n := crypto.S256().Params().N
+	key, chainCode, err := hmacSplit([]byte("Bitcoin seed"), seed, n)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range path {
+		var data []byte
+		if index >= 0x80000000 {
+			data = append([]byte{0}, math.PaddedBigBytes(key, 32)...)
+		} else {
+			priv, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+			if err != nil {
+				return nil, err
+			}
+			data = crypto.CompressPubkey(&priv.PublicKey)
+		}
+		tweak, nextChainCode, err := hmacSplit(chainCode, binary.BigEndian.AppendUint32(data, index), n)
+		if err != nil {
+			return nil, err
+		}
+		if key = tweak.Add(tweak, key).Mod(tweak, n); key.Sign() == 0 {
+			return nil, errors.New("invalid derived key")
+		}
+		chainCode = nextChainCode
+	}
+	return crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+}
+
+// hmacSplit returns the halves of HMAC-SHA512(key, data), the first as a
+// number that must be a valid secp256k1 key.
+func hmacSplit(key, data []byte, n *big.Int) (*big.Int, []byte, error) {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	left := new(big.Int).SetBytes(sum[:32])
+	if left.Sign() == 0 || left.Cmp(n) >= 0 {
+		return nil, nil, errors.New("invalid derived key")
+	}
+	return left, sum[32:], nil
+}
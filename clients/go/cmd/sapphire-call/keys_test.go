@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	testMnemonic = "test test test test test test test test test test test junk"
+	defaultPath  = "m/44'/60'/0'/0/0"
+)
+
+func TestLoadKey(t *testing.T) {
+	key, _ := crypto.HexToECDSA(testKey)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte("0x"+testKey+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	encrypted, err := keystore.EncryptKey(&keystore.Key{Address: address, PrivateKey: key}, "hunter2", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatalf("failed to encrypt key: %v", err)
+	}
+	keystoreFile := filepath.Join(dir, "keystore.json")
+	if err = os.WriteFile(keystoreFile, encrypted, 0o600); err != nil {
+		t.Fatalf("failed to write keystore: %v", err)
+	}
+	mnemonicFile := filepath.Join(dir, "mnemonic")
+	if err = os.WriteFile(mnemonicFile, []byte(testMnemonic+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write mnemonic: %v", err)
+	}
+	t.Setenv("SAPPHIRE_CALL_TEST_KEY", testKey)
+	t.Setenv("SAPPHIRE_CALL_TEST_PASSWORD", "hunter2")
+
+	for _, tc := range []struct {
+		spec, password, path string
+		address              common.Address
+	}{
+		{testKey, "", defaultPath, address},
+		{"0x" + testKey, "", defaultPath, address},
+		{"env:SAPPHIRE_CALL_TEST_KEY", "", defaultPath, address},
+		{"file:" + keyFile, "", defaultPath, address},
+		{"keystore:" + keystoreFile, "hunter2", defaultPath, address},
+		{"keystore:" + keystoreFile, "env:SAPPHIRE_CALL_TEST_PASSWORD", defaultPath, address},
+		// The first Hardhat and Foundry development accounts.
+		{"mnemonic:" + testMnemonic, "", defaultPath, common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")},
+		{"mnemonic:file:" + mnemonicFile, "", "m/44'/60'/0'/0/1", common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")},
+	} {
+		key, err := loadKey(tc.spec, tc.password, tc.path)
+		if err != nil {
+			t.Fatalf("%s: failed to load key: %v", tc.spec, err)
+		}
+		if got := crypto.PubkeyToAddress(key.PublicKey); got != tc.address {
+			t.Fatalf("%s: loaded the key of %s, expected %s", tc.spec, got, tc.address)
+		}
+	}
+
+	for _, tc := range []struct{ spec, password, path string }{
+		{"0x01", "", defaultPath},
+		{"env:SAPPHIRE_CALL_UNSET", "", defaultPath},
+		{"file:" + filepath.Join(dir, "missing"), "", defaultPath},
+		{"keystore:" + keystoreFile, "wrong", defaultPath},
+		{"mnemonic:test test test", "", defaultPath},
+		{"mnemonic:" + testMnemonic, "", "m/x"},
+	} {
+		if _, err := loadKey(tc.spec, tc.password, tc.path); err == nil {
+			t.Fatalf("%s: expected an error", tc.spec)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+// Command sapphire-call calls a contract function on Sapphire with a signed
+// and encrypted query, or sends it as an encrypted transaction, and prints the
+// decoded result or revert.
+//
+// Usage:
+//
+//	sapphire-call -rpc URL [-key KEY] -to ADDR -sig 'balanceOf(address)(uint256)' [-args ARG]... [-block N] [-tx] [-json] [ARG...]
+//
+// KEY is a hex-encoded private key, env:NAME or file:PATH holding one,
+// keystore:PATH with -password, or mnemonic:SECRET with -hd-path, where
+// SECRET is the mnemonic, env:NAME or file:PATH. Queries without a key are
+// encrypted but not signed.
+//
+// The signature's second parenthesized list, if any, gives the result types
+// to decode the result with; otherwise the raw result is printed. With -json,
+// the outcome is printed as a single JSON object:
+//
+//	{"from":"0x…","to":"0x…","raw":"0x…","result":["42"]}
+//	{"from":"0x…","to":"0x…","tx":"0x…"}
+//	{"from":"0x…","to":"0x…","error":"…","revert":{"reason":"no","data":"0x…"}}
+//
+// Integers are printed as decimal strings and bytes as hex. The exit status
+// is 1 if the call fails or reverts, and 2 for invalid arguments.
+//
+// The command only uses the exported API of the sapphire package, so it also
+// serves as an example of it.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/client"
+)
+
+// Exit statuses.
+const (
+	exitFailed = 1
+	exitUsage  = 2
+)
+
+// output is the outcome printed with -json.
+type output struct {
+	From   *common.Address `json:"from,omitempty"`
+	To     common.Address  `json:"to"`
+	Block  *uint64         `json:"block,omitempty"`
+	Raw    hexutil.Bytes   `json:"raw,omitempty"`
+	Result []interface{}   `json:"result,omitempty"`
+	Tx     *common.Hash    `json:"tx,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Revert *revert         `json:"revert,omitempty"`
+}
+
+type revert struct {
+	Reason string        `json:"reason,omitempty"`
+	Data   hexutil.Bytes `json:"data"`
+}
+
+// argList collects repeated -args flags.
+type argList []string
+
+func (a *argList) String() string     { return strings.Join(*a, ",") }
+func (a *argList) Set(s string) error { *a = append(*a, s); return nil }
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command with args and returns its exit status.
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("sapphire-call", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	rpcURL := fs.String("rpc", "http://localhost:8545", "gateway URL")
+	keySpec := fs.String("key", "", "key to sign with: HEX, env:NAME, file:PATH, keystore:PATH or mnemonic:SECRET")
+	password := fs.String("password", "", "keystore password: env:NAME, file:PATH or the password")
+	hdPath := fs.String("hd-path", "m/44'/60'/0'/0/0", "derivation path of mnemonic keys")
+	toHex := fs.String("to", "", "contract address")
+	sig := fs.String("sig", "", "function signature, e.g. 'balanceOf(address)(uint256)'")
+	var callArgs argList
+	fs.Var(&callArgs, "args", "function argument, repeated for each argument")
+	block := fs.String("block", "", "block number to query at, the latest if empty")
+	tx := fs.Bool("tx", false, "send a transaction instead of querying")
+	jsonOut := fs.Bool("json", false, "print the outcome as JSON")
+	timeout := fs.Duration("timeout", time.Minute, "time allowed for the command")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	callArgs = append(callArgs, fs.Args()...)
+
+	usageError := func(format string, a ...interface{}) int {
+		fmt.Fprintf(stderr, "sapphire-call: "+format+"\n", a...)
+		return exitUsage
+	}
+	if !common.IsHexAddress(*toHex) {
+		return usageError("invalid -to address %q", *toHex)
+	}
+	to := common.HexToAddress(*toHex)
+	method, err := parseSignature(*sig)
+	if err != nil {
+		return usageError("%v", err)
+	}
+	values, err := parseArgs(method.Inputs, callArgs)
+	if err != nil {
+		return usageError("%v", err)
+	}
+	var blockNumber *big.Int
+	if *block != "" {
+		n, err := strconv.ParseUint(*block, 0, 64)
+		if err != nil {
+			return usageError("invalid -block %q", *block)
+		}
+		blockNumber = new(big.Int).SetUint64(n)
+	}
+
+	var opts []client.Option
+	var from *common.Address
+	if *keySpec != "" {
+		key, err := loadKey(*keySpec, *password, *hdPath)
+		if err != nil {
+			return usageError("%v", err)
+		}
+		signer := sapphire.NewPrivateKeySigner(key)
+		addr := signer.Address()
+		from = &addr
+		opts = append(opts, client.WithKeyring(sapphire.NewKeyring(signer)))
+	} else if *tx {
+		return usageError("-tx needs -key")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	out := output{From: from, To: to}
+	if blockNumber != nil {
+		n := blockNumber.Uint64()
+		out.Block = &n
+	}
+	err = call(ctx, *rpcURL, opts, method, values, &out, blockNumber, *tx)
+	if err != nil {
+		out.Error = err.Error()
+		var revertErr *sapphire.RevertError
+		if errors.As(err, &revertErr) {
+			out.Revert = &revert{Reason: revertErr.Reason, Data: revertErr.Data}
+		}
+	}
+
+	if *jsonOut {
+		encoded, _ := json.Marshal(out)
+		fmt.Fprintln(stdout, string(encoded))
+	} else {
+		printText(stdout, stderr, &out)
+	}
+	if err != nil {
+		return exitFailed
+	}
+	return 0
+}
+
+// call makes the query or sends the transaction, filling in out.
+func call(ctx context.Context, rpcURL string, opts []client.Option, method *abi.Method, values []interface{}, out *output, blockNumber *big.Int, tx bool) error {
+	backend, err := client.DialContext(ctx, rpcURL, nil, opts...)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+	parsedABI := abi.ABI{Methods: map[string]abi.Method{method.Name: *method}}
+
+	if tx {
+		sent, err := backend.PackAndTransact(backend.Transactor(*out.From), out.To, parsedABI, method.Name, values...)
+		if err != nil {
+			return err
+		}
+		hash := sent.Hash()
+		out.Tx = &hash
+		return nil
+	}
+
+	data, err := parsedABI.Pack(method.Name, values...)
+	if err != nil {
+		return err
+	}
+	msg := ethereum.CallMsg{To: &out.To, Data: data}
+	if out.From != nil {
+		msg.From = *out.From
+	}
+	res, err := backend.CallContract(ctx, msg, blockNumber)
+	if err != nil {
+		return err
+	}
+	out.Raw = res
+	if len(method.Outputs) == 0 {
+		return nil
+	}
+	results, err := method.Outputs.Unpack(res)
+	if err != nil {
+		return fmt.Errorf("decoding result: %w", err)
+	}
+	out.Result = make([]interface{}, len(results))
+	for i, v := range results {
+		out.Result[i] = formatValue(v)
+	}
+	return nil
+}
+
+// printText prints out for humans: the results one per line, or the raw
+// result if it is not decoded, the transaction hash or the error.
+func printText(stdout, stderr io.Writer, out *output) {
+	switch {
+	case out.Error != "":
+		fmt.Fprintln(stderr, "sapphire-call:", out.Error)
+		switch {
+		case out.Revert == nil:
+		case out.Revert.Reason != "":
+			fmt.Fprintln(stderr, "revert reason:", out.Revert.Reason)
+		default:
+			fmt.Fprintln(stderr, "revert data:", out.Revert.Data)
+		}
+	case out.Tx != nil:
+		fmt.Fprintln(stdout, out.Tx.Hex())
+	case out.Result != nil:
+		for _, v := range out.Result {
+			if s, ok := v.(string); ok {
+				fmt.Fprintln(stdout, s)
+			} else {
+				encoded, _ := json.Marshal(v)
+				fmt.Fprintln(stdout, string(encoded))
+			}
+		}
+	default:
+		fmt.Fprintln(stdout, out.Raw)
+	}
+}
+
+// parseSignature parses a function signature like
+// 'transfer(address,uint256)(bool)', whose second list of types, the result
+// types, is optional. Tuple types are not supported.
+func parseSignature(sig string) (*abi.Method, error) {
+	name, rest, ok := strings.Cut(strings.TrimSpace(sig), "(")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("invalid -sig %q, expected e.g. 'balanceOf(address)(uint256)'", sig)
+	}
+	inputList, rest, ok := strings.Cut(rest, ")")
+	if !ok {
+		return nil, fmt.Errorf("invalid -sig %q: unbalanced parentheses", sig)
+	}
+	inputs, err := parseTypes(inputList)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -sig %q: %w", sig, err)
+	}
+	var outputs abi.Arguments
+	if rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), "returns")); rest != "" {
+		if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+			return nil, fmt.Errorf("invalid -sig %q: unexpected %q", sig, rest)
+		}
+		if outputs, err = parseTypes(rest[1 : len(rest)-1]); err != nil {
+			return nil, fmt.Errorf("invalid -sig %q: %w", sig, err)
+		}
+	}
+	method := abi.NewMethod(name, name, abi.Function, "view", false, false, inputs, outputs)
+	return &method, nil
+}
+
+// parseTypes parses a comma-separated list of ABI types.
+func parseTypes(list string) (abi.Arguments, error) {
+	var args abi.Arguments
+	if strings.TrimSpace(list) == "" {
+		return args, nil
+	}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if strings.ContainsAny(name, "() ") {
+			return nil, fmt.Errorf("unsupported type %q", name)
+		}
+		t, err := abi.NewType(name, "", nil)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, abi.Argument{Type: t})
+	}
+	return args, nil
+}
+
+// parseArgs converts the arguments to the Go values abi packs as inputs.
+func parseArgs(inputs abi.Arguments, args []string) ([]interface{}, error) {
+	if len(args) != len(inputs) {
+		return nil, fmt.Errorf("expected %d arguments, got %d", len(inputs), len(args))
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		v, err := parseArg(inputs[i].Type, arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// parseArg converts arg to the Go value abi packs as t. Integers are decimal
+// or 0x-prefixed hex, bytes are hex.
+func parseArg(t abi.Type, arg string) (interface{}, error) {
+	switch t.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(arg) {
+			return nil, fmt.Errorf("invalid address %q", arg)
+		}
+		return common.HexToAddress(arg), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(arg)
+	case abi.StringTy:
+		return arg, nil
+	case abi.BytesTy:
+		return hexutil.Decode(arg)
+	case abi.FixedBytesTy:
+		b, err := hexutil.Decode(arg)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != t.Size {
+			return nil, fmt.Errorf("expected %d bytes, got %d", t.Size, len(b))
+		}
+		v := reflect.New(t.GetType()).Elem()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v.Interface(), nil
+	case abi.IntTy, abi.UintTy:
+		n, ok := new(big.Int).SetString(arg, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", arg)
+		}
+		lo, hi := big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), uint(t.Size))
+		if t.T == abi.IntTy {
+			hi.Rsh(hi, 1)
+			lo.Neg(hi)
+		}
+		if n.Cmp(lo) < 0 || n.Cmp(hi) >= 0 {
+			return nil, fmt.Errorf("%s out of range for %s", arg, t)
+		}
+		if t.Size > 64 {
+			return n, nil
+		}
+		if t.T == abi.IntTy {
+			return reflect.ValueOf(n.Int64()).Convert(t.GetType()).Interface(), nil
+		}
+		return reflect.ValueOf(n.Uint64()).Convert(t.GetType()).Interface(), nil
+	default:
+		return nil, fmt.Errorf("unsupported argument type %s", t)
+	}
+}
+
+// formatValue returns v, a value abi decoded, as printed: integers as
+// decimal strings, addresses and bytes as hex, and other values as they
+// encode to JSON.
+func formatValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case string, bool:
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return hexutil.Encode(b)
+		}
+	}
+	return v
+}
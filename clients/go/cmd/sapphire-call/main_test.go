@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/mockgateway"
+)
+
+// asMainEnv makes the test binary run the command instead of the tests.
+const asMainEnv = "SAPPHIRE_CALL_AS_MAIN"
+
+const testKey = "c07b151fbc1e7a11dff926111188f8d872f62eba0396da97c0a24adb75161750"
+
+var (
+	contract = common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	owner    = common.HexToAddress("0xdce075e1c39b1ae0b75d554558b6451a226ffe00")
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv(asMainEnv) == "1" {
+		main()
+	}
+	os.Exit(m.Run())
+}
+
+// sapphireCall execs the command with args like a user does, with the test
+// key in PRIVKEY, and returns its output and exit status.
+func sapphireCall(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), asMainEnv+"=1", "PRIVKEY=0x"+testKey)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("failed to run the command: %v", err)
+	}
+	return stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()
+}
+
+// balanceOf answers balanceOf(owner) with 42 and reverts other calls with
+// Error("no").
+func balanceOf(t *testing.T) mockgateway.CallHandler {
+	method, err := parseSignature("balanceOf(address)(uint256)")
+	if err != nil {
+		t.Fatalf("failed to parse signature: %v", err)
+	}
+	input, _ := method.Inputs.Pack(owner)
+	output, _ := method.Outputs.Pack(big.NewInt(42))
+	reason, _ := abi.Arguments{{Type: mustType(t, "string")}}.Pack("no")
+	revertData := append(crypto.Keccak256([]byte("Error(string)"))[:4], reason...)
+	return func(call mockgateway.Call) ([]byte, error) {
+		if bytes.Equal(call.Data, append(method.ID, input...)) {
+			return output, nil
+		}
+		return nil, &mockgateway.Failure{Module: "evm", Code: 8, Message: "reverted: " + base64.StdEncoding.EncodeToString(revertData)}
+	}
+}
+
+func TestSignedQuery(t *testing.T) {
+	gw := mockgateway.New(t)
+	gw.OnCall(balanceOf(t))
+	key, _ := crypto.HexToECDSA(testKey)
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	stdout, stderr, code := sapphireCall(t, "--rpc", gw.URL, "--key", "env:PRIVKEY", "--to", contract.Hex(),
+		"--sig", "balanceOf(address)(uint256)", "--args", owner.Hex(), "--block", "99", "--json")
+	if code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	var out struct {
+		From   common.Address `json:"from"`
+		Block  uint64         `json:"block"`
+		Result []string       `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil || out.From != from || out.Block != 99 || len(out.Result) != 1 || out.Result[0] != "42" {
+		t.Fatalf("unexpected output %s: %v", stdout, err)
+	}
+
+	// The query was signed and encrypted, and made at the block.
+	calls := gw.Calls("eth_call")
+	if len(calls) != 1 || calls[0].Leash == nil || len(calls[0].Signature) != 65 || calls[0].From != from || bytes.Contains(calls[0].Envelope, owner.Bytes()) {
+		t.Fatalf("expected a signed encrypted query, got %+v", calls)
+	}
+	var block string
+	if err := json.Unmarshal(gw.Requests("eth_call")[0].Params[1], &block); err != nil || block != "0x63" {
+		t.Fatalf("expected the query at block 99, got %s: %v", gw.Requests("eth_call")[0].Params[1], err)
+	}
+
+	// Without -json, results are printed one per line, and without result
+	// types the raw result.
+	if stdout, stderr, code = sapphireCall(t, "-rpc", gw.URL, "-key", "env:PRIVKEY", "-to", contract.Hex(), "-sig", "balanceOf(address)(uint256)", owner.Hex()); code != 0 || stdout != "42\n" {
+		t.Fatalf("unexpected output %q, exit status %d: %s", stdout, code, stderr)
+	}
+	if stdout, _, _ = sapphireCall(t, "-rpc", gw.URL, "-key", "env:PRIVKEY", "-to", contract.Hex(), "-sig", "balanceOf(address)", owner.Hex()); stdout != "0x"+strings.Repeat("0", 62)+"2a\n" {
+		t.Fatalf("unexpected raw output %q", stdout)
+	}
+
+	// Without a key, the query is encrypted but not signed.
+	if _, stderr, code = sapphireCall(t, "-rpc", gw.URL, "-to", contract.Hex(), "-sig", "balanceOf(address)(uint256)", owner.Hex()); code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	if call := gw.Calls("eth_call")[3]; call.Leash != nil || call.Epoch != mockgateway.DefaultEpoch {
+		t.Fatalf("expected an unsigned encrypted call, got %+v", call)
+	}
+}
+
+func TestRevert(t *testing.T) {
+	gw := mockgateway.New(t)
+	gw.OnCall(balanceOf(t))
+
+	stdout, _, code := sapphireCall(t, "-rpc", gw.URL, "-key", "env:PRIVKEY", "-to", contract.Hex(), "-sig", "balanceOf(address)(uint256)", "-json", contract.Hex())
+	var out struct {
+		Error  string `json:"error"`
+		Revert struct {
+			Reason string `json:"reason"`
+		} `json:"revert"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil || code != exitFailed || out.Revert.Reason != "no" || !strings.Contains(out.Error, "reverted") {
+		t.Fatalf("expected the revert, got %s with exit status %d: %v", stdout, code, err)
+	}
+
+	if _, stderr, code := sapphireCall(t, "-rpc", gw.URL, "-key", "env:PRIVKEY", "-to", contract.Hex(), "-sig", "balanceOf(address)(uint256)", contract.Hex()); code != exitFailed || !strings.Contains(stderr, "revert reason: no") {
+		t.Fatalf("expected the revert, got %q with exit status %d", stderr, code)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	gw := mockgateway.New(t)
+	key, _ := crypto.HexToECDSA(testKey)
+
+	stdout, stderr, code := sapphireCall(t, "-rpc", gw.URL, "-key", "0x"+testKey, "-to", contract.Hex(),
+		"-sig", "transfer(address,uint256)(bool)", "-args", owner.Hex(), "-args", "0x10", "-tx", "-json")
+	if code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	var out struct {
+		Tx common.Hash `json:"tx"`
+	}
+	sent := gw.Calls("eth_sendRawTransaction")
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil || len(sent) != 1 || out.Tx != sent[0].Tx.Hash() {
+		t.Fatalf("unexpected output %s: %v", stdout, err)
+	}
+	method, _ := parseSignature("transfer(address,uint256)")
+	input, _ := method.Inputs.Pack(owner, big.NewInt(16))
+	if sent[0].From != crypto.PubkeyToAddress(key.PublicKey) || sent[0].Epoch != mockgateway.DefaultEpoch || !bytes.Equal(sent[0].Data, append(method.ID, input...)) {
+		t.Fatalf("expected an encrypted transfer, got %+v", sent[0])
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-to", "0x01", "-sig", "f()"},
+		{"-to", contract.Hex(), "-sig", "f"},
+		{"-to", contract.Hex(), "-sig", "f((uint256,bool))"},
+		{"-to", contract.Hex(), "-sig", "f(uint256)"},
+		{"-to", contract.Hex(), "-sig", "f(uint8)", "256"},
+		{"-to", contract.Hex(), "-sig", "f(int8)", "-129"},
+		{"-to", contract.Hex(), "-sig", "f(bytes4)", "0x010203"},
+		{"-to", contract.Hex(), "-sig", "f(uint256[])", "1"},
+		{"-to", contract.Hex(), "-sig", "f()", "-block", "latest"},
+		{"-to", contract.Hex(), "-sig", "f()", "-tx"},
+		{"-to", contract.Hex(), "-sig", "f()", "-key", "env:UNSET_SAPPHIRE_CALL_KEY"},
+		{"-unknown"},
+	} {
+		if _, stderr, code := sapphireCall(t, args...); code != exitUsage || stderr == "" {
+			t.Fatalf("%q: expected a usage error, got exit status %d: %s", args, code, stderr)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	method, err := parseSignature("f(address,bool,string,bytes,bytes2,uint8,int64,uint256,int256)")
+	if err != nil {
+		t.Fatalf("failed to parse signature: %v", err)
+	}
+	values, err := parseArgs(method.Inputs, []string{owner.Hex(), "true", "hi", "0x0102", "0x0304", "255", "-5", "0x100", "-1"})
+	if err != nil {
+		t.Fatalf("failed to parse arguments: %v", err)
+	}
+	// Arguments pack as the ABI types they are parsed as.
+	if _, err = method.Inputs.Pack(values...); err != nil {
+		t.Fatalf("failed to pack arguments %v: %v", values, err)
+	}
+	if values[5] != uint8(255) || values[6] != int64(-5) || values[7].(*big.Int).Int64() != 256 || values[4] != [2]byte{3, 4} {
+		t.Fatalf("unexpected arguments %v", values)
+	}
+
+	for _, v := range []struct {
+		in  interface{}
+		out interface{}
+	}{
+		{big.NewInt(-3), "-3"},
+		{uint8(7), "7"},
+		{owner, owner.Hex()},
+		{[]byte{1}, "0x01"},
+		{[2]byte{1, 2}, "0x0102"},
+		{true, true},
+	} {
+		if got := formatValue(v.in); got != v.out {
+			t.Fatalf("%v formatted as %v, expected %v", v.in, got, v.out)
+		}
+	}
+}
+
+func mustType(t *testing.T, name string) abi.Type {
+	typ, err := abi.NewType(name, "", nil)
+	if err != nil {
+		t.Fatalf("invalid type %s: %v", name, err)
+	}
+	return typ
+}
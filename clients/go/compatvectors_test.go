@@ -0,0 +1,164 @@
+package sapphire
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+// compatVectors are the cross-client vectors internal/genvectors generates.
+// They are decoded independently of the generator, as the other clients do.
+type compatVectors struct {
+	Version int `json:"version"`
+	Runtime struct {
+		PublicKey hexutil.Bytes `json:"publicKey"`
+		SecretKey hexutil.Bytes `json:"secretKey"`
+		Epoch     uint64        `json:"epoch"`
+	} `json:"runtime"`
+	Queries []struct {
+		Name      string          `json:"name"`
+		SignerKey hexutil.Bytes   `json:"signerKey"`
+		ChainID   uint64          `json:"chainId"`
+		From      common.Address  `json:"from"`
+		To        *common.Address `json:"to"`
+		GasLimit  uint64          `json:"gasLimit"`
+		GasPrice  *hexutil.Big    `json:"gasPrice"`
+		Value     *hexutil.Big    `json:"value"`
+		Data      hexutil.Bytes   `json:"data"`
+		Leash     struct {
+			Nonce       uint64      `json:"nonce"`
+			BlockNumber uint64      `json:"blockNumber"`
+			BlockHash   common.Hash `json:"blockHash"`
+			BlockRange  uint64      `json:"blockRange"`
+		} `json:"leash"`
+		Digest          common.Hash     `json:"digest"`
+		Signature       hexutil.Bytes   `json:"signature"`
+		CallerPublicKey hexutil.Bytes   `json:"callerPublicKey"`
+		CallerSecretKey hexutil.Bytes   `json:"callerSecretKey"`
+		CallNonce       hexutil.Bytes   `json:"callNonce"`
+		CallEnvelope    hexutil.Bytes   `json:"callEnvelope"`
+		Envelope        hexutil.Bytes   `json:"envelope"`
+		EnvelopeJSON    json.RawMessage `json:"envelopeJson"`
+		Result          hexutil.Bytes   `json:"result"`
+		ResultNonce     hexutil.Bytes   `json:"resultNonce"`
+		ResultEnvelope  hexutil.Bytes   `json:"resultEnvelope"`
+	} `json:"queries"`
+}
+
+// TestCompatVectors checks that the package signs, encrypts and decrypts
+// the cross-client vectors byte for byte.
+func TestCompatVectors(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "compat_vectors.json"))
+	if err != nil {
+		t.Fatalf("failed to read vectors: %v", err)
+	}
+	var vectors compatVectors
+	if err = json.Unmarshal(raw, &vectors); err != nil {
+		t.Fatalf("failed to decode vectors: %v", err)
+	}
+	if vectors.Version != 1 {
+		t.Fatalf("unsupported vectors version %d", vectors.Version)
+	}
+	runtime := &Curve25519KeyPair{
+		PublicKey: x25519.PublicKey(vectors.Runtime.PublicKey),
+		SecretKey: x25519.PrivateKey(vectors.Runtime.SecretKey),
+	}
+
+	for _, v := range vectors.Queries {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			key, err := crypto.ToECDSA(v.SignerKey)
+			if err != nil || crypto.PubkeyToAddress(key.PublicKey) != v.From {
+				t.Fatalf("signer key does not belong to %s", v.From)
+			}
+			leash := evm.Leash{
+				Nonce:       v.Leash.Nonce,
+				BlockNumber: v.Leash.BlockNumber,
+				BlockHash:   v.Leash.BlockHash[:],
+				BlockRange:  v.Leash.BlockRange,
+			}
+			digest, err := SignedCallDigest(SignableCall(v.ChainID, v.From, v.To, v.GasLimit, v.GasPrice.ToInt(), v.Value.ToInt(), v.Data, leash))
+			if err != nil || digest != v.Digest {
+				t.Fatalf("digest %x, expected %x: %v", digest, v.Digest, err)
+			}
+			pack, err := NewSignedCall(context.Background(), NewPrivateKeySigner(key), new(big.Int).SetUint64(v.ChainID), v.To, v.Data,
+				WithLeash(leash), WithGasLimit(v.GasLimit), WithGasPrice(v.GasPrice.ToInt()), WithValue(v.Value.ToInt()))
+			if err != nil {
+				t.Fatalf("NewSignedCall failed: %v", err)
+			}
+			if !bytes.Equal(pack.Signature, v.Signature) {
+				t.Fatalf("signature %x, expected %x", pack.Signature, v.Signature)
+			}
+
+			// Encrypting with the vector's nonce reproduces the envelope.
+			caller, err := NewX25519DeoxysIICipherWithRand(&Curve25519KeyPair{
+				PublicKey: x25519.PublicKey(v.CallerPublicKey),
+				SecretKey: x25519.PrivateKey(v.CallerSecretKey),
+			}, &runtime.PublicKey, vectors.Runtime.Epoch, bytes.NewReader(v.CallNonce))
+			if err != nil {
+				t.Fatalf("failed to create cipher: %v", err)
+			}
+			envelope := caller.EncryptEnvelope(v.Data)
+			if encoded := cbor.Marshal(envelope); !bytes.Equal(encoded, v.CallEnvelope) {
+				t.Fatalf("call envelope %x, expected %x", encoded, v.CallEnvelope)
+			}
+			pack.Data = *envelope
+			if encoded := cbor.Marshal(pack); !bytes.Equal(encoded, v.Envelope) {
+				t.Fatalf("signed query %x, expected %x", encoded, v.Envelope)
+			}
+			decoded, err := (DecodeLimits{}).DecodeSignedCall(v.Envelope)
+			if err != nil || !SignedCallsEqual(decoded, pack) {
+				t.Fatalf("signed query does not decode to itself: %v", err)
+			}
+			fromJSON, err := DecodeDataPackJSON(v.EnvelopeJSON)
+			if err != nil || !bytes.Equal(EncodeDataPack(fromJSON), v.Envelope) {
+				t.Fatalf("JSON signed query does not decode to the envelope: %v", err)
+			}
+			var expected bytes.Buffer
+			if err = json.Compact(&expected, v.EnvelopeJSON); err != nil {
+				t.Fatalf("malformed JSON signed query: %v", err)
+			}
+			if encoded, err := json.Marshal((*DataPackJSON)(pack)); err != nil || !bytes.Equal(encoded, expected.Bytes()) {
+				t.Fatalf("JSON signed query %s, expected %s: %v", encoded, expected.Bytes(), err)
+			}
+
+			// The runtime can decrypt the call, and the caller its result.
+			var body sdkTypes.CallEnvelopeX25519DeoxysII
+			if err = cbor.Unmarshal(envelope.Body, &body); err != nil {
+				t.Fatalf("malformed call envelope: %v", err)
+			}
+			opener, err := NewX25519DeoxysIICipher(runtime, &body.Pk, body.Epoch)
+			if err != nil {
+				t.Fatalf("failed to create cipher: %v", err)
+			}
+			plaintext, err := opener.Decrypt(body.Nonce[:], body.Data)
+			if err != nil {
+				t.Fatalf("runtime failed to decrypt call: %v", err)
+			}
+			var inner sdkTypes.Call
+			var data []byte
+			if err = cbor.Unmarshal(plaintext, &inner); err == nil {
+				err = cbor.Unmarshal(inner.Body, &data)
+			}
+			if err != nil || !bytes.Equal(data, v.Data) {
+				t.Fatalf("call decrypted to %x, expected %x: %v", data, v.Data, err)
+			}
+			result, err := caller.DecryptCallResult(v.ResultEnvelope)
+			if err != nil || !bytes.Equal(result, v.Result) {
+				t.Fatalf("result decrypted to %x, expected %x: %v", result, v.Result, err)
+			}
+		})
+	}
+}
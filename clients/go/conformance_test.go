@@ -0,0 +1,61 @@
+package sapphire
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// runtimeSDKVectors are signed query digests computed by the runtime's own
+// verifier, oasis-runtime-sdk-evm, rather than by this package or the
+// references in its tests.
+type runtimeSDKVectors struct {
+	// Generator is where the digests come from: the oasis-sdk commit, which
+	// should be the one runtime/Cargo.lock pins, and the program that called
+	// its signed call hashing.
+	Generator struct {
+		Commit  string `json:"commit"`
+		Program string `json:"program"`
+	} `json:"generator"`
+	Vectors []signedQueryVector `json:"vectors"`
+}
+
+// TestSignedQueryRuntimeSDKVectors checks SignableCall's digests against
+// those of testdata/runtime_sdk_signed_query_vectors.json. It is skipped
+// until the file is generated, which needs the oasis-sdk sources.
+func TestSignedQueryRuntimeSDKVectors(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "runtime_sdk_signed_query_vectors.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skip("no vectors generated by oasis-runtime-sdk-evm")
+	}
+	if err != nil {
+		t.Fatalf("failed to read vectors: %v", err)
+	}
+	var vectors runtimeSDKVectors
+	if err = json.Unmarshal(raw, &vectors); err != nil {
+		t.Fatalf("failed to decode vectors: %v", err)
+	}
+	if vectors.Generator.Commit == "" || vectors.Generator.Program == "" {
+		t.Fatalf("vectors don't say which oasis-sdk commit and program generated them")
+	}
+	if len(vectors.Vectors) == 0 {
+		t.Fatalf("no vectors")
+	}
+	for _, v := range vectors.Vectors {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			digest, err := SignedCallDigest(v.domain().SignableCall(v.ChainID, v.From, v.To, v.GasLimit, v.GasPrice.ToInt(), v.Value.ToInt(), v.Data, v.leash()))
+			if err != nil {
+				t.Fatalf("failed to hash query: %v", err)
+			}
+			if common.Hash(digest) != v.Digest {
+				t.Fatalf("expected digest %s as oasis-runtime-sdk-evm at %s computes it, got %x", v.Digest, vectors.Generator.Commit, digest)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package consensus
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+// EthAddress returns the Oasis address of the Ethereum account eth, which
+// the runtime uses for it in the consensus accounts and staking modules.
+func EthAddress(eth common.Address) types.Address {
+	return types.NewAddressFromEth(eth.Bytes())
+}
+
+// OasisAddressFromEth returns the bech32-encoded oasis1 address of the
+// Ethereum account eth. The derivation hashes eth, so the Ethereum address
+// behind an oasis1 address can't be recovered from it.
+func OasisAddressFromEth(eth common.Address) (string, error) {
+	text, err := EthAddress(eth).MarshalText()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode address: %w", err)
+	}
+	return string(text), nil
+}
+
+// ParseOasisAddress decodes the bech32-encoded oasis1 address s, checking
+// its checksum and length.
+func ParseOasisAddress(s string) ([21]byte, error) {
+	var addr types.Address
+	if err := addr.UnmarshalText([]byte(s)); err != nil {
+		return [21]byte{}, fmt.Errorf("%w: %v", precompiles.ErrInvalidInput, err)
+	}
+	raw, _ := addr.MarshalBinary()
+	return [21]byte(raw), nil
+}
+
+// ParseAddress parses s as an oasis1 address, or as a 0x-prefixed Ethereum
+// address it derives the Oasis address of, to pass either form to the
+// builders. Mixed-case Ethereum addresses must have a valid EIP-55 checksum.
+func ParseAddress(s string) (types.Address, error) {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		raw, err := ParseOasisAddress(s)
+		if err != nil {
+			return types.Address{}, err
+		}
+		var addr types.Address
+		_ = addr.UnmarshalBinary(raw[:])
+		return addr, nil
+	}
+	if !common.IsHexAddress(s) {
+		return types.Address{}, fmt.Errorf("%w: %q is not an Ethereum address", precompiles.ErrInvalidInput, s)
+	}
+	eth := common.HexToAddress(s)
+	if hex := s[2:]; hex != strings.ToLower(hex) && hex != strings.ToUpper(hex) && eth.Hex() != "0x"+hex {
+		return types.Address{}, fmt.Errorf("%w: %q has an invalid checksum", precompiles.ErrInvalidInput, s)
+	}
+	return EthAddress(eth), nil
+}
@@ -0,0 +1,66 @@
+package consensus
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+// Vectors of the oasis-sdk derivation tests, for the Dave and Erin test
+// accounts.
+var ethAddressVectors = []struct {
+	eth   string
+	oasis string
+}{
+	{"0xDce075E1C39b1ae0b75D554558b6451A226ffe00", "oasis1qrk58a6j2qn065m6p06jgjyt032f7qucy5wqeqpt"},
+	{"0x709EEbd979328A2B3605A160915DEB26E186abF8", "oasis1qqcd0qyda6gtwdrfcqawv3s8cr2kupzw9v967au6"},
+}
+
+func TestOasisAddressFromEth(t *testing.T) {
+	for _, v := range ethAddressVectors {
+		oasis, err := OasisAddressFromEth(common.HexToAddress(v.eth))
+		if err != nil || oasis != v.oasis {
+			t.Fatalf("address of %s: got %s, %v, expected %s", v.eth, oasis, err, v.oasis)
+		}
+		raw, err := ParseOasisAddress(v.oasis)
+		if err != nil {
+			t.Fatalf("ParseOasisAddress failed: %v", err)
+		}
+		if expected, _ := EthAddress(common.HexToAddress(v.eth)).MarshalBinary(); string(raw[:]) != string(expected) {
+			t.Fatalf("unexpected raw address %x", raw)
+		}
+		if raw[0] != 0 {
+			t.Fatalf("unexpected address version %d", raw[0])
+		}
+
+		for _, s := range []string{v.eth, v.oasis} {
+			addr, err := ParseAddress(s)
+			if err != nil || addr.String() != v.oasis {
+				t.Fatalf("ParseAddress(%s): got %s, %v, expected %s", s, addr, err, v.oasis)
+			}
+		}
+	}
+}
+
+func TestParseAddressInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"oasis1qrk58a6j2qn065m6p06jgjyt032f7qucy5wqeqpu", // Bad checksum.
+		"oasis1qrk58a6j2qn065m6p06jgjyt032f7qucy5wqeq",   // Truncated.
+		"cosmos1qrk58a6j2qn065m6p06jgjyt032f7qucyst2xf9", // Other prefix.
+		"0xDce075E1C39b1ae0b75D554558b6451A226ffe0",      // Short.
+		"0xdCe075E1C39b1ae0b75D554558b6451A226ffe00",     // Bad EIP-55 checksum.
+	} {
+		if _, err := ParseAddress(s); !errors.Is(err, precompiles.ErrInvalidInput) {
+			t.Fatalf("expected %q to be rejected, got %v", s, err)
+		}
+	}
+	for _, s := range []string{"0xdce075e1c39b1ae0b75d554558b6451a226ffe00", "0xDCE075E1C39B1AE0B75D554558B6451A226FFE00"} {
+		if _, err := ParseAddress(s); err != nil {
+			t.Fatalf("expected single-case %s to be accepted: %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package consensus
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+// Rounding is how ToConsensusUnits treats amounts that are not a whole
+// number of consensus base units.
+type Rounding uint8
+
+const (
+	// RoundExact rejects amounts that would need rounding.
+	RoundExact Rounding = iota
+	// RoundDown drops the remainder, which stays in the runtime.
+	RoundDown
+	// RoundUp rounds to the next consensus base unit.
+	RoundUp
+)
+
+var scalingFactor = big.NewInt(ScalingFactor)
+
+// ToConsensusUnits converts amount in runtime base units, 18 decimals like
+// wei, to consensus base units, 9 decimals, rounded with r.
+func ToConsensusUnits(amount *big.Int, r Rounding) (*big.Int, error) {
+	switch {
+	case amount == nil:
+		return nil, fmt.Errorf("%w: amount must not be nil", precompiles.ErrInvalidInput)
+	case amount.Sign() < 0:
+		return nil, fmt.Errorf("%w: amount must not be negative", precompiles.ErrInvalidInput)
+	}
+	q, rem := new(big.Int).QuoRem(amount, scalingFactor, new(big.Int))
+	if rem.Sign() == 0 {
+		return q, nil
+	}
+	switch r {
+	case RoundDown:
+		return q, nil
+	case RoundUp:
+		return q.Add(q, big.NewInt(1)), nil
+	case RoundExact:
+		return nil, fmt.Errorf("%w: amount %s leaves %s runtime base units below one consensus base unit", precompiles.ErrInvalidInput, amount, rem)
+	default:
+		return nil, fmt.Errorf("%w: unknown rounding %d", precompiles.ErrInvalidInput, r)
+	}
+}
+
+// FromConsensusUnits converts amount in consensus base units to runtime base
+// units.
+func FromConsensusUnits(amount *big.Int) *big.Int {
+	return new(big.Int).Mul(amount, scalingFactor)
+}
+
+// RoundAmount rounds amount in runtime base units to a whole number of
+// consensus base units with r, e.g. to withdraw a balance with dust in it.
+func RoundAmount(amount *big.Int, r Rounding) (*big.Int, error) {
+	units, err := ToConsensusUnits(amount, r)
+	if err != nil {
+		return nil, err
+	}
+	return FromConsensusUnits(units), nil
+}
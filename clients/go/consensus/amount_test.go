@@ -0,0 +1,71 @@
+package consensus
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+func TestToConsensusUnits(t *testing.T) {
+	for _, tc := range []struct {
+		amount          int64
+		down, up, exact int64
+	}{
+		{0, 0, 0, 0},
+		{ScalingFactor, 1, 1, 1},
+		{1, 0, 1, -1},                             // Dust only.
+		{ScalingFactor - 1, 0, 1, -1},             // Just below one unit.
+		{ScalingFactor + 1, 1, 2, -1},             // Just above one unit.
+		{5*ScalingFactor + 999_999_999, 5, 6, -1}, // Largest dust.
+		{1_000_000 * ScalingFactor, 1_000_000, 1_000_000, 1_000_000},
+	} {
+		amount := big.NewInt(tc.amount)
+		for _, r := range []struct {
+			rounding Rounding
+			expected int64
+		}{{RoundDown, tc.down}, {RoundUp, tc.up}, {RoundExact, tc.exact}} {
+			units, err := ToConsensusUnits(amount, r.rounding)
+			switch {
+			case r.expected < 0:
+				if !errors.Is(err, precompiles.ErrInvalidInput) {
+					t.Fatalf("expected %d to need rounding, got %v, %v", tc.amount, units, err)
+				}
+			case err != nil || units.Int64() != r.expected:
+				t.Fatalf("rounding %d with %d: got %v, %v, expected %d", tc.amount, r.rounding, units, err, r.expected)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		amount *big.Int
+		reason string
+	}{
+		{nil, "must not be nil"},
+		{big.NewInt(-1), "must not be negative"},
+	} {
+		if _, err := ToConsensusUnits(tc.amount, RoundDown); !errors.Is(err, precompiles.ErrInvalidInput) || !strings.Contains(err.Error(), tc.reason) {
+			t.Fatalf("expected %v to be rejected as %q, got %v", tc.amount, tc.reason, err)
+		}
+	}
+	if _, err := ToConsensusUnits(big.NewInt(1), Rounding(42)); !errors.Is(err, precompiles.ErrInvalidInput) {
+		t.Fatalf("expected an unknown rounding to be rejected, got %v", err)
+	}
+}
+
+func TestRoundAmount(t *testing.T) {
+	amount := new(big.Int).Add(rose, big.NewInt(1))
+	down, err := RoundAmount(amount, RoundDown)
+	if err != nil || down.Cmp(rose) != 0 {
+		t.Fatalf("unexpected round down %v: %v", down, err)
+	}
+	up, err := RoundAmount(amount, RoundUp)
+	if err != nil || up.Cmp(new(big.Int).Add(rose, big.NewInt(ScalingFactor))) != 0 {
+		t.Fatalf("unexpected round up %v: %v", up, err)
+	}
+	if FromConsensusUnits(big.NewInt(3)).Int64() != 3*ScalingFactor {
+		t.Fatalf("unexpected conversion")
+	}
+}
@@ -0,0 +1,241 @@
+// Package consensus builds subcalls to the consensus accounts module, which
+// moves and delegates the runtime balance of the caller to consensus layer
+// accounts:
+//
+//	tx, _ := consensus.DelegateTx(backend.Transactor(sender), backend, validator, amount, 1)
+//	receipt, _ := consensus.TakeReceipt(ctx, backend, sender, consensus.DelegateReceipt, 1)
+//
+// Delegations and undelegations are carried out by the consensus layer after
+// the transaction's block, so their outcome is not part of the transaction
+// receipt. Pass a non-zero receipt ID to have the module keep it for
+// TakeReceipt.
+//
+// Accounts are given by their Oasis address. ParseAddress accepts an oasis1
+// address or the Ethereum address of an account, e.g. from user input.
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+
+	beacon "github.com/oasisprotocol/oasis-core/go/beacon/api"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/consensusaccounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+const (
+	// ScalingFactor is the number of runtime base units, i.e. wei, per
+	// consensus base unit. Amounts moved to the consensus layer must be a
+	// multiple of it.
+	ScalingFactor = 1_000_000_000
+
+	methodDeposit     = "consensus.Deposit"
+	methodWithdraw    = "consensus.Withdraw"
+	methodDelegate    = "consensus.Delegate"
+	methodUndelegate  = "consensus.Undelegate"
+	methodTakeReceipt = "consensus.TakeReceipt"
+)
+
+// ReceiptKind is the kind of a receipt kept by the consensus accounts module.
+type ReceiptKind uint8
+
+const (
+	// DelegateReceipt is the receipt of a delegation.
+	DelegateReceipt ReceiptKind = 1
+	// UndelegateStartReceipt is the receipt of an undelegation whose
+	// debonding started.
+	UndelegateStartReceipt ReceiptKind = 2
+	// UndelegateDoneReceipt is the receipt of an undelegation whose
+	// debonding ended.
+	UndelegateDoneReceipt ReceiptKind = 3
+)
+
+func (k ReceiptKind) String() string {
+	switch k {
+	case DelegateReceipt:
+		return "delegate"
+	case UndelegateStartReceipt:
+		return "undelegate start"
+	case UndelegateDoneReceipt:
+		return "undelegate done"
+	default:
+		return fmt.Sprintf("ReceiptKind(%d)", uint8(k))
+	}
+}
+
+type delegateBody struct {
+	To      types.Address   `json:"to"`
+	Amount  types.BaseUnits `json:"amount"`
+	Receipt uint64          `json:"receipt,omitempty"`
+}
+
+type undelegateBody struct {
+	From    types.Address  `json:"from"`
+	Shares  types.Quantity `json:"shares"`
+	Receipt uint64         `json:"receipt,omitempty"`
+}
+
+type takeReceiptBody struct {
+	Kind ReceiptKind `json:"kind"`
+	ID   uint64      `json:"id"`
+}
+
+type receiptBody struct {
+	Shares  types.Quantity                    `json:"shares,omitempty"`
+	Epoch   beacon.EpochTime                  `json:"epoch,omitempty"`
+	Receipt uint64                            `json:"receipt,omitempty"`
+	Amount  types.Quantity                    `json:"amount,omitempty"`
+	Error   *consensusaccounts.ConsensusError `json:"error,omitempty"`
+}
+
+// Receipt is the outcome of a delegation or undelegation.
+type Receipt struct {
+	Kind ReceiptKind
+	// Shares are the shares a delegation received.
+	Shares *big.Int
+	// Epoch is the epoch the debonding of an undelegation ends at.
+	Epoch beacon.EpochTime
+	// DoneReceipt is the ID of the UndelegateDoneReceipt the module keeps
+	// once the debonding of an undelegation ended.
+	DoneReceipt uint64
+	// Amount is the amount an undelegation returned once its debonding
+	// ended.
+	Amount *big.Int
+	// Err is a *sapphire.CallFailedError if the consensus layer failed the
+	// delegation or undelegation.
+	Err error
+}
+
+// Delegate returns the calldata of a subcall delegating amount, in runtime
+// base units, of the caller's balance to to. The module keeps a receipt with
+// ID receipt, unless it is 0.
+func Delegate(to types.Address, amount *big.Int, receipt uint64) ([]byte, error) {
+	body, err := newDelegateBody(to, amount, receipt)
+	if err != nil {
+		return nil, err
+	}
+	return precompiles.SubcallData(methodDelegate, body)
+}
+
+// Undelegate returns the calldata of a subcall undelegating shares of the
+// caller's delegation to from. The module keeps a receipt with ID receipt,
+// unless it is 0.
+func Undelegate(from types.Address, shares *big.Int, receipt uint64) ([]byte, error) {
+	body, err := newUndelegateBody(from, shares, receipt)
+	if err != nil {
+		return nil, err
+	}
+	return precompiles.SubcallData(methodUndelegate, body)
+}
+
+// DelegateTx sends a transaction delegating amount of the sender's balance
+// to to, see Delegate. The amount is not attached as the transaction's value,
+// which must be unset. Pass the backend's Transactor as opts to have it
+// encrypted.
+func DelegateTx(opts *bind.TransactOpts, backend bind.ContractBackend, to types.Address, amount *big.Int, receipt uint64) (*ethtypes.Transaction, error) {
+	body, err := newDelegateBody(to, amount, receipt)
+	if err != nil {
+		return nil, err
+	}
+	return subcallTx(opts, backend, methodDelegate, body)
+}
+
+// UndelegateTx sends a transaction undelegating shares of the sender's
+// delegation to from, see Undelegate. Pass the backend's Transactor as opts
+// to have it encrypted.
+func UndelegateTx(opts *bind.TransactOpts, backend bind.ContractBackend, from types.Address, shares *big.Int, receipt uint64) (*ethtypes.Transaction, error) {
+	body, err := newUndelegateBody(from, shares, receipt)
+	if err != nil {
+		return nil, err
+	}
+	return subcallTx(opts, backend, methodUndelegate, body)
+}
+
+// TakeReceipt returns the receipt of kind with ID id the module keeps for
+// from. It is queried with eth_call, so the receipt is not removed, and must
+// be signed for the module to see from as the caller: pass a wrapped client
+// with a signer for from. The call fails until the consensus layer has
+// processed the delegation or undelegation.
+func TakeReceipt(ctx context.Context, caller bind.ContractCaller, from common.Address, kind ReceiptKind, id uint64) (*Receipt, error) {
+	if id == 0 {
+		return nil, fmt.Errorf("%w: receipt ID must not be 0", precompiles.ErrInvalidInput)
+	}
+	res, err := precompiles.SubcallFrom(ctx, caller, from, methodTakeReceipt, &takeReceiptBody{Kind: kind, ID: id})
+	if err != nil {
+		return nil, err
+	}
+	var body receiptBody
+	if err = res.Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to take %s receipt %d: %w", kind, id, err)
+	}
+	receipt := &Receipt{
+		Kind:        kind,
+		Shares:      body.Shares.ToBigInt(),
+		Epoch:       body.Epoch,
+		DoneReceipt: body.Receipt,
+		Amount:      body.Amount.ToBigInt(),
+	}
+	if body.Error != nil {
+		receipt.Err = &sapphire.CallFailedError{Module: body.Error.Module, Code: body.Error.Code}
+	}
+	return receipt, nil
+}
+
+func newDelegateBody(to types.Address, amount *big.Int, receipt uint64) (*delegateBody, error) {
+	units, err := runtimeAmount(amount)
+	if err != nil {
+		return nil, err
+	}
+	return &delegateBody{To: to, Amount: units, Receipt: receipt}, nil
+}
+
+func newUndelegateBody(from types.Address, shares *big.Int, receipt uint64) (*undelegateBody, error) {
+	if shares == nil || shares.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: shares must be positive", precompiles.ErrInvalidInput)
+	}
+	q, err := toQuantity(shares)
+	if err != nil {
+		return nil, err
+	}
+	return &undelegateBody{From: from, Shares: q, Receipt: receipt}, nil
+}
+
+// runtimeAmount returns amount in runtime base units as the module takes it,
+// rejecting amounts the consensus layer would truncate.
+func runtimeAmount(amount *big.Int) (types.BaseUnits, error) {
+	if amount == nil || amount.Sign() <= 0 {
+		return types.BaseUnits{}, fmt.Errorf("%w: amount must be positive", precompiles.ErrInvalidInput)
+	}
+	if _, err := ToConsensusUnits(amount, RoundExact); err != nil {
+		return types.BaseUnits{}, err
+	}
+	q, err := toQuantity(amount)
+	if err != nil {
+		return types.BaseUnits{}, err
+	}
+	return types.NewBaseUnits(q, types.NativeDenomination), nil
+}
+
+func toQuantity(v *big.Int) (quantity.Quantity, error) {
+	q := quantity.NewQuantity()
+	if err := q.FromBigInt(v); err != nil {
+		return quantity.Quantity{}, fmt.Errorf("%w: %v", precompiles.ErrInvalidInput, err)
+	}
+	return *q, nil
+}
+
+func subcallTx(opts *bind.TransactOpts, backend bind.ContractBackend, method string, body interface{}) (*ethtypes.Transaction, error) {
+	if opts.Value != nil && opts.Value.Sign() != 0 {
+		return nil, fmt.Errorf("%w: %s spends the sender's balance, the transaction must not carry value", precompiles.ErrInvalidInput, method)
+	}
+	return precompiles.SubcallTx(opts, backend, method, body)
+}
@@ -0,0 +1,246 @@
+package consensus
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/client"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+var rose = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+// decodeSubcall returns the method and body of subcall calldata.
+func decodeSubcall(t *testing.T, data []byte) (string, []byte) {
+	stringType, _ := abi.NewType("string", "", nil)
+	bytesType, _ := abi.NewType("bytes", "", nil)
+	values, err := abi.Arguments{{Type: stringType}, {Type: bytesType}}.Unpack(data)
+	if err != nil {
+		t.Fatalf("failed to decode subcall: %v", err)
+	}
+	return values[0].(string), values[1].([]byte)
+}
+
+func TestDelegate(t *testing.T) {
+	to := EthAddress(common.HexToAddress("0x1234"))
+	data, err := Delegate(to, rose, 7)
+	if err != nil {
+		t.Fatalf("Delegate failed: %v", err)
+	}
+	method, raw := decodeSubcall(t, data)
+	var body delegateBody
+	if err = cbor.Unmarshal(raw, &body); err != nil || method != "consensus.Delegate" {
+		t.Fatalf("unexpected subcall %s: %v", method, err)
+	}
+	if !body.To.Equal(to) || body.Amount.Amount.ToBigInt().Cmp(rose) != 0 || body.Receipt != 7 {
+		t.Fatalf("unexpected body %+v", body)
+	}
+
+	// Without a receipt, the field is left out.
+	data, _ = Delegate(to, rose, 0)
+	_, raw = decodeSubcall(t, data)
+	var fields map[string]interface{}
+	if err = cbor.Unmarshal(raw, &fields); err != nil || len(fields) != 2 {
+		t.Fatalf("unexpected body %v: %v", fields, err)
+	}
+
+	for _, amount := range []*big.Int{nil, big.NewInt(0), big.NewInt(-ScalingFactor), big.NewInt(ScalingFactor + 1)} {
+		if _, err = Delegate(to, amount, 0); !errors.Is(err, precompiles.ErrInvalidInput) {
+			t.Fatalf("expected amount %v to be rejected, got %v", amount, err)
+		}
+	}
+}
+
+func TestUndelegate(t *testing.T) {
+	from := EthAddress(common.HexToAddress("0x1234"))
+	data, err := Undelegate(from, big.NewInt(42), 8)
+	if err != nil {
+		t.Fatalf("Undelegate failed: %v", err)
+	}
+	method, raw := decodeSubcall(t, data)
+	var body undelegateBody
+	if err = cbor.Unmarshal(raw, &body); err != nil || method != "consensus.Undelegate" {
+		t.Fatalf("unexpected subcall %s: %v", method, err)
+	}
+	if !body.From.Equal(from) || body.Shares.ToBigInt().Int64() != 42 || body.Receipt != 8 {
+		t.Fatalf("unexpected body %+v", body)
+	}
+	if _, err = Undelegate(from, big.NewInt(0), 0); !errors.Is(err, precompiles.ErrInvalidInput) {
+		t.Fatalf("expected no shares to be rejected, got %v", err)
+	}
+}
+
+func TestDelegateTxValue(t *testing.T) {
+	opts := &bind.TransactOpts{Value: big.NewInt(1)}
+	to := EthAddress(common.HexToAddress("0x1234"))
+	if _, err := DelegateTx(opts, nil, to, rose, 0); !errors.Is(err, precompiles.ErrInvalidInput) {
+		t.Fatalf("expected a transaction with value to be rejected, got %v", err)
+	}
+}
+
+type fakeCaller struct {
+	respond func(msg ethereum.CallMsg) ([]byte, error)
+}
+
+func (c *fakeCaller) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (c *fakeCaller) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	return c.respond(msg)
+}
+
+func subcallOutput(t *testing.T, status uint64, response []byte) []byte {
+	uint64Type, _ := abi.NewType("uint64", "", nil)
+	bytesType, _ := abi.NewType("bytes", "", nil)
+	out, err := abi.Arguments{{Type: uint64Type}, {Type: bytesType}}.Pack(status, response)
+	if err != nil {
+		t.Fatalf("failed to pack output: %v", err)
+	}
+	return out
+}
+
+func TestTakeReceipt(t *testing.T) {
+	from := common.HexToAddress("0xabcd")
+	shares := quantity.NewFromUint64(1_000)
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		if msg.From != from || *msg.To != precompiles.SubcallAddress {
+			t.Fatalf("unexpected call %+v", msg)
+		}
+		method, raw := decodeSubcall(t, msg.Data)
+		var body takeReceiptBody
+		if err := cbor.Unmarshal(raw, &body); err != nil || method != "consensus.TakeReceipt" {
+			t.Fatalf("unexpected subcall %s: %v", method, err)
+		}
+		switch body.ID {
+		case 1:
+			return subcallOutput(t, 0, cbor.Marshal(map[string]interface{}{"shares": shares})), nil
+		case 2:
+			return subcallOutput(t, 0, cbor.Marshal(map[string]interface{}{"epoch": 12, "receipt": 3})), nil
+		case 3:
+			return subcallOutput(t, 0, cbor.Marshal(map[string]interface{}{"error": map[string]interface{}{"module": "staking", "code": 5}})), nil
+		default:
+			return subcallOutput(t, 1, []byte("consensus")), nil
+		}
+	}}
+	ctx := context.Background()
+	receipt, err := TakeReceipt(ctx, caller, from, DelegateReceipt, 1)
+	if err != nil || receipt.Shares.Int64() != 1_000 || receipt.Err != nil {
+		t.Fatalf("unexpected receipt %+v: %v", receipt, err)
+	}
+	receipt, err = TakeReceipt(ctx, caller, from, UndelegateStartReceipt, 2)
+	if err != nil || receipt.Epoch != 12 || receipt.DoneReceipt != 3 {
+		t.Fatalf("unexpected receipt %+v: %v", receipt, err)
+	}
+	receipt, err = TakeReceipt(ctx, caller, from, DelegateReceipt, 3)
+	var failed *sapphire.CallFailedError
+	if err != nil || !errors.As(receipt.Err, &failed) || failed.Module != "staking" || failed.Code != 5 {
+		t.Fatalf("expected a failed receipt, got %+v: %v", receipt, err)
+	}
+	if _, err = TakeReceipt(ctx, caller, from, DelegateReceipt, 4); !errors.Is(err, sapphire.ErrCallFailed) {
+		t.Fatalf("expected a missing receipt to fail, got %v", err)
+	}
+	if _, err = TakeReceipt(ctx, caller, from, DelegateReceipt, 0); !errors.Is(err, precompiles.ErrInvalidInput) {
+		t.Fatalf("expected receipt 0 to be rejected, got %v", err)
+	}
+}
+
+// waitReceipt polls TakeReceipt until the consensus layer processed the
+// message.
+func waitReceipt(ctx context.Context, t *testing.T, b *client.WrappedBackend, from common.Address, kind ReceiptKind, id uint64) *Receipt {
+	for {
+		receipt, err := TakeReceipt(ctx, b, from, kind, id)
+		if err == nil {
+			if receipt.Err != nil {
+				t.Fatalf("%s failed: %v", kind, receipt.Err)
+			}
+			return receipt
+		}
+		if !errors.Is(err, sapphire.ErrCallFailed) {
+			t.Fatalf("TakeReceipt failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("no %s receipt: %v", kind, err)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func TestDelegateLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	key := localnet.Accounts[0].Key
+	signer := sapphire.NewPrivateKeySigner(key)
+	eth := localnet.Dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	b, err := client.WrapClient(eth, nil, client.WithKeyring(sapphire.NewKeyring(signer)))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	opts := b.Transactor(signer.Address())
+	opts.Context = ctx
+	send := func(tx *ethtypes.Transaction, err error) {
+		if err != nil {
+			t.Fatalf("failed to send transaction: %v", err)
+		}
+		receipt, err := bind.WaitMined(ctx, b, tx)
+		if err != nil || receipt.Status != ethtypes.ReceiptStatusSuccessful {
+			t.Fatalf("transaction failed: %v", err)
+		}
+	}
+
+	// Receipts are kept per ID, so use fresh ones on every run.
+	id := uint64(time.Now().UnixNano())
+	to := EthAddress(signer.Address())
+	send(DelegateTx(opts, b, to, new(big.Int).Mul(big.NewInt(10), rose), id))
+	delegated := waitReceipt(ctx, t, b, signer.Address(), DelegateReceipt, id)
+	if delegated.Shares.Sign() <= 0 {
+		t.Fatalf("delegation received no shares")
+	}
+	delegations, err := Delegations(ctx, b, signer.Address())
+	if err != nil {
+		t.Fatalf("Delegations failed: %v", err)
+	}
+	var shares *big.Int
+	for _, d := range delegations {
+		if d.To.Equal(to) {
+			shares = d.Shares
+		}
+	}
+	// Earlier runs may have left shares behind.
+	if shares == nil || shares.Cmp(delegated.Shares) < 0 {
+		t.Fatalf("delegation not found in %+v", delegations)
+	}
+
+	send(UndelegateTx(opts, b, to, delegated.Shares, id+1))
+	undelegated := waitReceipt(ctx, t, b, signer.Address(), UndelegateStartReceipt, id+1)
+	if undelegated.Epoch == 0 || undelegated.DoneReceipt == 0 {
+		t.Fatalf("unexpected undelegation receipt %+v", undelegated)
+	}
+	undelegations, err := PendingUndelegations(ctx, b, signer.Address())
+	if err != nil {
+		t.Fatalf("PendingUndelegations failed: %v", err)
+	}
+	var pending bool
+	for _, u := range undelegations {
+		pending = pending || (u.From.Equal(to) && u.Epoch == undelegated.Epoch)
+	}
+	if !pending {
+		t.Fatalf("undelegation not found in %+v", undelegations)
+	}
+}
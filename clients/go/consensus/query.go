@@ -0,0 +1,80 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	beacon "github.com/oasisprotocol/oasis-core/go/beacon/api"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/consensusaccounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+const (
+	methodDelegations   = "consensus.Delegations"
+	methodUndelegations = "consensus.Undelegations"
+)
+
+// Delegation is a delegation of an account.
+type Delegation struct {
+	// To is the account delegated to.
+	To types.Address
+	// Shares are the shares of the delegation.
+	Shares *big.Int
+}
+
+// Undelegation is an undelegation whose debonding hasn't ended yet.
+type Undelegation struct {
+	// From is the account undelegated from.
+	From types.Address
+	// Shares are the shares being undelegated.
+	Shares *big.Int
+	// Epoch is the epoch the debonding ends at.
+	Epoch beacon.EpochTime
+}
+
+// Delegations returns the delegations of owner, made from the runtime, with a
+// query subcall. An account without delegations has none, not an error.
+func Delegations(ctx context.Context, caller bind.ContractCaller, owner common.Address) ([]Delegation, error) {
+	var infos []*consensusaccounts.ExtendedDelegationInfo
+	if err := query(ctx, caller, methodDelegations, &consensusaccounts.DelegationsQuery{From: EthAddress(owner)}, &infos); err != nil {
+		return nil, err
+	}
+	delegations := make([]Delegation, 0, len(infos))
+	for _, info := range infos {
+		delegations = append(delegations, Delegation{To: info.To, Shares: info.Shares.ToBigInt()})
+	}
+	return delegations, nil
+}
+
+// PendingUndelegations returns the undelegations of owner whose debonding
+// hasn't ended, with a query subcall.
+func PendingUndelegations(ctx context.Context, caller bind.ContractCaller, owner common.Address) ([]Undelegation, error) {
+	var infos []*consensusaccounts.UndelegationInfo
+	if err := query(ctx, caller, methodUndelegations, &consensusaccounts.UndelegationsQuery{To: EthAddress(owner)}, &infos); err != nil {
+		return nil, err
+	}
+	undelegations := make([]Undelegation, 0, len(infos))
+	for _, info := range infos {
+		undelegations = append(undelegations, Undelegation{From: info.From, Shares: info.Shares.ToBigInt(), Epoch: info.Epoch})
+	}
+	return undelegations, nil
+}
+
+// query makes a read-only subcall to method and decodes its response, CBOR
+// null for an empty list, into v.
+func query(ctx context.Context, caller bind.ContractCaller, method string, body, v interface{}) error {
+	res, err := precompiles.Subcall(ctx, caller, method, body)
+	if err != nil {
+		return err
+	}
+	if err = res.Decode(v); err != nil {
+		return fmt.Errorf("failed to query %s: %w", method, err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package consensus
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/consensusaccounts"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+func TestDelegations(t *testing.T) {
+	owner := common.HexToAddress("0x1234")
+	validator := EthAddress(common.HexToAddress("0x5678"))
+	// Enough delegations for the response to take many words.
+	infos := make([]*consensusaccounts.ExtendedDelegationInfo, 5_000)
+	for i := range infos {
+		infos[i] = &consensusaccounts.ExtendedDelegationInfo{To: validator, Shares: *quantity.NewFromUint64(uint64(i + 1))}
+	}
+	var response []byte
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		method, raw := decodeSubcall(t, msg.Data)
+		var body consensusaccounts.DelegationsQuery
+		if err := cbor.Unmarshal(raw, &body); err != nil || method != "consensus.Delegations" || !body.From.Equal(EthAddress(owner)) {
+			t.Fatalf("unexpected subcall %s %+v: %v", method, body, err)
+		}
+		return response, nil
+	}}
+	ctx := context.Background()
+
+	response = subcallOutput(t, 0, cbor.Marshal(infos))
+	delegations, err := Delegations(ctx, caller, owner)
+	if err != nil || len(delegations) != len(infos) {
+		t.Fatalf("Delegations failed: %d, %v", len(delegations), err)
+	}
+	if last := delegations[len(delegations)-1]; !last.To.Equal(validator) || last.Shares.Int64() != int64(len(infos)) {
+		t.Fatalf("unexpected delegation %+v", last)
+	}
+
+	// No delegations are encoded as an empty list or null.
+	for _, empty := range [][]byte{cbor.Marshal([]interface{}{}), cbor.Marshal(nil)} {
+		response = subcallOutput(t, 0, empty)
+		if delegations, err = Delegations(ctx, caller, owner); err != nil || len(delegations) != 0 {
+			t.Fatalf("expected no delegations, got %v: %v", delegations, err)
+		}
+	}
+
+	response = subcallOutput(t, 2, []byte("consensus_accounts"))
+	if _, err = Delegations(ctx, caller, owner); !errors.Is(err, sapphire.ErrCallFailed) {
+		t.Fatalf("expected a failed query to fail, got %v", err)
+	}
+	response = subcallOutput(t, 0, []byte{0xff})
+	if _, err = Delegations(ctx, caller, owner); err == nil {
+		t.Fatalf("expected a malformed response to fail")
+	}
+}
+
+func TestPendingUndelegations(t *testing.T) {
+	owner := common.HexToAddress("0x1234")
+	validator := EthAddress(common.HexToAddress("0x5678"))
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		method, raw := decodeSubcall(t, msg.Data)
+		var body consensusaccounts.UndelegationsQuery
+		if err := cbor.Unmarshal(raw, &body); err != nil || method != "consensus.Undelegations" || !body.To.Equal(EthAddress(owner)) {
+			t.Fatalf("unexpected subcall %s %+v: %v", method, body, err)
+		}
+		return subcallOutput(t, 0, cbor.Marshal([]*consensusaccounts.UndelegationInfo{
+			{From: validator, Epoch: 42, Shares: *quantity.NewFromUint64(7)},
+		})), nil
+	}}
+	undelegations, err := PendingUndelegations(context.Background(), caller, owner)
+	if err != nil || len(undelegations) != 1 {
+		t.Fatalf("PendingUndelegations failed: %v, %v", undelegations, err)
+	}
+	if u := undelegations[0]; !u.From.Equal(validator) || u.Epoch != 42 || u.Shares.Int64() != 7 {
+		t.Fatalf("unexpected undelegation %+v", u)
+	}
+}
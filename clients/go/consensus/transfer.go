@@ -0,0 +1,117 @@
+package consensus
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/consensusaccounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+// Deposit returns the calldata of a subcall depositing amount, in runtime
+// base units, from the caller's consensus account into the runtime account
+// to, or the caller's if to is nil. The consensus account must allow the
+// runtime to spend the amount.
+func Deposit(to *types.Address, amount *big.Int) ([]byte, error) {
+	units, err := runtimeAmount(amount)
+	if err != nil {
+		return nil, err
+	}
+	return precompiles.SubcallData(methodDeposit, &consensusaccounts.Deposit{To: to, Amount: units})
+}
+
+// Withdraw returns the calldata of a subcall withdrawing amount, in runtime
+// base units, of the caller's balance to the consensus account to, or the
+// caller's if to is nil. Use RoundAmount to withdraw a balance that isn't a
+// whole number of consensus base units.
+func Withdraw(to *types.Address, amount *big.Int) ([]byte, error) {
+	units, err := runtimeAmount(amount)
+	if err != nil {
+		return nil, err
+	}
+	return precompiles.SubcallData(methodWithdraw, &consensusaccounts.Withdraw{To: to, Amount: units})
+}
+
+// DepositTx sends a transaction depositing amount into to, see Deposit.
+func DepositTx(opts *bind.TransactOpts, backend bind.ContractBackend, to *types.Address, amount *big.Int) (*ethtypes.Transaction, error) {
+	units, err := runtimeAmount(amount)
+	if err != nil {
+		return nil, err
+	}
+	return subcallTx(opts, backend, methodDeposit, &consensusaccounts.Deposit{To: to, Amount: units})
+}
+
+// WithdrawTx sends a transaction withdrawing amount of the sender's balance
+// to to, see Withdraw. The amount is not attached as the transaction's
+// value, which must be unset.
+func WithdrawTx(opts *bind.TransactOpts, backend bind.ContractBackend, to *types.Address, amount *big.Int) (*ethtypes.Transaction, error) {
+	units, err := runtimeAmount(amount)
+	if err != nil {
+		return nil, err
+	}
+	return subcallTx(opts, backend, methodWithdraw, &consensusaccounts.Withdraw{To: to, Amount: units})
+}
+
+// Transfer is the outcome of a deposit or withdrawal, which the consensus
+// layer carries out after the transaction's block.
+type Transfer struct {
+	From types.Address
+	// Nonce is the runtime account nonce of the transaction that sent it.
+	Nonce uint64
+	To    types.Address
+	// Amount is in runtime base units.
+	Amount *big.Int
+	// Err is a *sapphire.CallFailedError if the consensus layer failed the
+	// transfer.
+	Err error
+}
+
+// FindDeposit returns the outcome of the deposit sent by from with nonce
+// among the runtime events of a block, e.g. fetched with an oasis-sdk client,
+// or nil if it isn't among them.
+func FindDeposit(events []*types.Event, from types.Address, nonce uint64) (*Transfer, error) {
+	return findTransfer(events, from, nonce, false)
+}
+
+// FindWithdraw returns the outcome of the withdrawal sent by from with nonce
+// among the runtime events of a block, or nil if it isn't among them.
+func FindWithdraw(events []*types.Event, from types.Address, nonce uint64) (*Transfer, error) {
+	return findTransfer(events, from, nonce, true)
+}
+
+func findTransfer(events []*types.Event, from types.Address, nonce uint64, withdraw bool) (*Transfer, error) {
+	for _, event := range events {
+		decoded, err := consensusaccounts.DecodeEvent(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode event: %w", err)
+		}
+		for _, d := range decoded {
+			var transfer *Transfer
+			var failed *consensusaccounts.ConsensusError
+			switch ev := d.(*consensusaccounts.Event); {
+			case withdraw && ev.Withdraw != nil:
+				transfer = &Transfer{From: ev.Withdraw.From, Nonce: ev.Withdraw.Nonce, To: ev.Withdraw.To, Amount: ev.Withdraw.Amount.Amount.ToBigInt()}
+				failed = ev.Withdraw.Error
+			case !withdraw && ev.Deposit != nil:
+				transfer = &Transfer{From: ev.Deposit.From, Nonce: ev.Deposit.Nonce, To: ev.Deposit.To, Amount: ev.Deposit.Amount.Amount.ToBigInt()}
+				failed = ev.Deposit.Error
+			default:
+				continue
+			}
+			if !transfer.From.Equal(from) || transfer.Nonce != nonce {
+				continue
+			}
+			if failed != nil {
+				transfer.Err = &sapphire.CallFailedError{Module: failed.Module, Code: failed.Code}
+			}
+			return transfer, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,105 @@
+package consensus
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/consensusaccounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+func TestWithdraw(t *testing.T) {
+	to := EthAddress(common.HexToAddress("0x1234"))
+	data, err := Withdraw(&to, rose)
+	if err != nil {
+		t.Fatalf("Withdraw failed: %v", err)
+	}
+	method, raw := decodeSubcall(t, data)
+	var body consensusaccounts.Withdraw
+	if err = cbor.Unmarshal(raw, &body); err != nil || method != "consensus.Withdraw" {
+		t.Fatalf("unexpected subcall %s: %v", method, err)
+	}
+	if body.To == nil || !body.To.Equal(to) || body.Amount.Amount.ToBigInt().Cmp(rose) != 0 || !body.Amount.Denomination.IsNative() {
+		t.Fatalf("unexpected body %+v", body)
+	}
+
+	// Dust would be truncated by the consensus layer, so it is rejected
+	// rather than lost.
+	dusty := new(big.Int).Add(rose, big.NewInt(1))
+	if _, err = Withdraw(&to, dusty); !errors.Is(err, precompiles.ErrInvalidInput) {
+		t.Fatalf("expected dust to be rejected, got %v", err)
+	}
+	if _, err = Withdraw(nil, big.NewInt(ScalingFactor-1)); !errors.Is(err, precompiles.ErrInvalidInput) {
+		t.Fatalf("expected an amount below one consensus base unit to be rejected, got %v", err)
+	}
+	rounded, _ := RoundAmount(dusty, RoundDown)
+	if _, err = Withdraw(nil, rounded); err != nil {
+		t.Fatalf("Withdraw of a rounded amount failed: %v", err)
+	}
+}
+
+func TestDeposit(t *testing.T) {
+	data, err := Deposit(nil, rose)
+	if err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	method, raw := decodeSubcall(t, data)
+	var body consensusaccounts.Deposit
+	if err = cbor.Unmarshal(raw, &body); err != nil || method != "consensus.Deposit" {
+		t.Fatalf("unexpected subcall %s: %v", method, err)
+	}
+	if body.To != nil || body.Amount.Amount.ToBigInt().Cmp(rose) != 0 {
+		t.Fatalf("unexpected body %+v", body)
+	}
+	if _, err = Deposit(nil, big.NewInt(0)); !errors.Is(err, precompiles.ErrInvalidInput) {
+		t.Fatalf("expected no amount to be rejected, got %v", err)
+	}
+}
+
+func TestFindTransfer(t *testing.T) {
+	from := EthAddress(common.HexToAddress("0x1234"))
+	to := EthAddress(common.HexToAddress("0x5678"))
+	amount := types.NewBaseUnits(*quantity.NewFromUint64(ScalingFactor), types.NativeDenomination)
+	events := []*types.Event{
+		{Module: "accounts", Code: 1, Value: cbor.Marshal([]interface{}{})},
+		{Module: consensusaccounts.ModuleName, Code: consensusaccounts.WithdrawEventCode, Value: cbor.Marshal([]*consensusaccounts.WithdrawEvent{
+			{From: from, Nonce: 1, To: to, Amount: amount},
+			{From: from, Nonce: 2, To: to, Amount: amount, Error: &consensusaccounts.ConsensusError{Module: "staking", Code: 3}},
+		})},
+		{Module: consensusaccounts.ModuleName, Code: consensusaccounts.DepositEventCode, Value: cbor.Marshal([]*consensusaccounts.DepositEvent{
+			{From: from, Nonce: 3, To: to, Amount: amount},
+		})},
+	}
+
+	transfer, err := FindWithdraw(events, from, 1)
+	if err != nil || transfer == nil || transfer.Err != nil || !transfer.To.Equal(to) || transfer.Amount.Int64() != ScalingFactor {
+		t.Fatalf("unexpected withdrawal %+v: %v", transfer, err)
+	}
+	transfer, err = FindWithdraw(events, from, 2)
+	var failed *sapphire.CallFailedError
+	if err != nil || transfer == nil || !errors.As(transfer.Err, &failed) || failed.Code != 3 {
+		t.Fatalf("expected a failed withdrawal, got %+v: %v", transfer, err)
+	}
+	if transfer, err = FindWithdraw(events, from, 3); err != nil || transfer != nil {
+		t.Fatalf("expected a deposit not to be found as a withdrawal, got %+v: %v", transfer, err)
+	}
+	if transfer, err = FindDeposit(events, from, 3); err != nil || transfer == nil || transfer.Nonce != 3 {
+		t.Fatalf("unexpected deposit %+v: %v", transfer, err)
+	}
+	if transfer, err = FindDeposit(events, to, 3); err != nil || transfer != nil {
+		t.Fatalf("expected no deposit from %s, got %+v: %v", to, transfer, err)
+	}
+
+	events = append(events, &types.Event{Module: consensusaccounts.ModuleName, Code: consensusaccounts.WithdrawEventCode, Value: []byte{0xff}})
+	if _, err = FindWithdraw(events, from, 4); err == nil {
+		t.Fatalf("expected a malformed event to fail")
+	}
+}
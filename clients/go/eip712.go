@@ -0,0 +1,386 @@
+package sapphire
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+)
+
+// SignedCallDomain is the EIP-712 domain signed queries are signed in.
+type SignedCallDomain struct {
+	Name    string
+	Version string
+}
+
+// DefaultSignedCallDomain is the domain the runtime verifies signed queries
+// in.
+var DefaultSignedCallDomain = SignedCallDomain{
+	Name:    "oasis-runtime-sdk/evm: signed query",
+	Version: "1.0.0",
+}
+
+// ErrInvalidSignedCall is returned for queries that can't be signed as they
+// are, e.g. because the runtime would reject them.
+var ErrInvalidSignedCall = errors.New("invalid signed call")
+
+// DefaultMaxSignedCallDataSize is the default maximum size in bytes of the
+// calldata of a signed query, see WithMaxSignedCallDataSize.
+const DefaultMaxSignedCallDataSize = 1 << 20
+
+// signedCallConfig configures how queries are signed.
+type signedCallConfig struct {
+	domain SignedCallDomain
+	// maxData is the maximum calldata size, DefaultMaxSignedCallDataSize if 0.
+	maxData int
+	// unchecked skips validating queries.
+	unchecked bool
+}
+
+// validate checks the fields of a query against what the runtime accepts.
+func (cfg signedCallConfig) validate(chainID *big.Int, gasLimit uint64, gasPrice, value *big.Int, data []byte) error {
+	if cfg.unchecked {
+		return nil
+	}
+	if err := ValidateChainID(chainID); err != nil {
+		return err
+	}
+	maxData := cfg.maxData
+	if maxData == 0 {
+		maxData = DefaultMaxSignedCallDataSize
+	}
+	if gasLimit == 0 {
+		return fmt.Errorf("%w: gasLimit is zero", ErrInvalidSignedCall)
+	}
+	for _, field := range []struct {
+		name string
+		v    *big.Int
+	}{{"gasPrice", gasPrice}, {"value", value}} {
+		if field.v == nil {
+			continue
+		}
+		if field.v.Sign() < 0 {
+			return fmt.Errorf("%w: %s %s is negative", ErrInvalidSignedCall, field.name, field.v)
+		}
+		if field.v.BitLen() > 256 {
+			return fmt.Errorf("%w: %s %s does not fit in uint256", ErrInvalidSignedCall, field.name, field.v)
+		}
+	}
+	if len(data) > maxData {
+		return fmt.Errorf("%w: data is %d bytes, at most %d are allowed", ErrInvalidSignedCall, len(data), maxData)
+	}
+	return nil
+}
+
+// ValidateChainID checks that chainID is a valid EIP-712 chainId, i.e. that
+// it is positive and fits in a uint256, and fails with ErrInvalidSignedCall
+// otherwise.
+func ValidateChainID(chainID *big.Int) error {
+	switch {
+	case chainID == nil:
+		return fmt.Errorf("%w: no chain ID", ErrInvalidSignedCall)
+	case chainID.Sign() <= 0:
+		return fmt.Errorf("%w: chain ID %s is not positive", ErrInvalidSignedCall, chainID)
+	case chainID.BitLen() > 256:
+		return fmt.Errorf("%w: chain ID %s does not fit in uint256", ErrInvalidSignedCall, chainID)
+	default:
+		return nil
+	}
+}
+
+// SignableCall returns the EIP-712 typed data a signed query from from to to
+// is signed as, for signing queries outside of this package, e.g. with an
+// offline signer. A nil to is a deployment.
+//
+// The typed data is exactly what the runtime verifies signed queries against;
+// it only changes if the runtime's format does. It is signed in
+// DefaultSignedCallDomain, see SignedCallDomain.SignableCall for others.
+func SignableCall(chainID uint64, from common.Address, to *common.Address, gasLimit uint64, gasPrice, value *big.Int, data []byte, leash evm.Leash) apitypes.TypedData {
+	return DefaultSignedCallDomain.SignableCall(chainID, from, to, gasLimit, gasPrice, value, data, leash)
+}
+
+// SignableCall is like the package-level SignableCall, but signs in domain d.
+func (d SignedCallDomain) SignableCall(chainID uint64, from common.Address, to *common.Address, gasLimit uint64, gasPrice, value *big.Int, data []byte, leash evm.Leash) apitypes.TypedData {
+	return d.signableCall(new(big.Int).SetUint64(chainID), from, to, gasLimit, gasPrice, value, data, leash)
+}
+
+// SignableCallBig is like SignableCall, but takes the chain ID as a *big.Int
+// like go-ethereum does, and fails with ErrInvalidSignedCall unless it is
+// positive and fits in a uint256.
+func SignableCallBig(chainID *big.Int, from common.Address, to *common.Address, gasLimit uint64, gasPrice, value *big.Int, data []byte, leash evm.Leash) (apitypes.TypedData, error) {
+	return DefaultSignedCallDomain.SignableCallBig(chainID, from, to, gasLimit, gasPrice, value, data, leash)
+}
+
+// SignableCallBig is like the package-level SignableCallBig, but signs in
+// domain d.
+func (d SignedCallDomain) SignableCallBig(chainID *big.Int, from common.Address, to *common.Address, gasLimit uint64, gasPrice, value *big.Int, data []byte, leash evm.Leash) (apitypes.TypedData, error) {
+	if err := ValidateChainID(chainID); err != nil {
+		return apitypes.TypedData{}, err
+	}
+	return d.signableCall(chainID, from, to, gasLimit, gasPrice, value, data, leash), nil
+}
+
+// signableCall builds the typed data here rather than taking it from
+// evm.NewSignedCallDataPack, which puts the addresses into the EIP-712 message
+// without their 0x prefix. Other clients and the runtime use 0x-prefixed
+// addresses, so this doesn't depend on how apitypes parses them.
+func (d SignedCallDomain) signableCall(chainID *big.Int, from common.Address, to *common.Address, gasLimit uint64, gasPrice, value *big.Int, data []byte, leash evm.Leash) apitypes.TypedData {
+	var callee common.Address
+	if to != nil {
+		callee = *to
+	}
+	if value == nil {
+		value = big.NewInt(0)
+	}
+	if gasPrice == nil {
+		gasPrice = big.NewInt(0)
+	}
+	valueU256 := math.HexOrDecimal256(*value)
+	gasPriceU256 := math.HexOrDecimal256(*gasPrice)
+
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": signedCallDomainType,
+			"Call": {
+				{Name: "from", Type: "address"},
+				{Name: "to", Type: "address"},
+				{Name: "gasLimit", Type: "uint64"},
+				{Name: "gasPrice", Type: "uint256"},
+				{Name: "value", Type: "uint256"},
+				{Name: "data", Type: "bytes"},
+				{Name: "leash", Type: "Leash"},
+			},
+			"Leash": {
+				{Name: "nonce", Type: "uint64"},
+				{Name: "blockNumber", Type: "uint64"},
+				{Name: "blockHash", Type: "bytes32"},
+				{Name: "blockRange", Type: "uint64"},
+			},
+		},
+		PrimaryType: "Call",
+		Domain: apitypes.TypedDataDomain{
+			Name:    d.Name,
+			Version: d.Version,
+			ChainId: (*math.HexOrDecimal256)(new(big.Int).Set(chainID)),
+		},
+		Message: apitypes.TypedDataMessage{
+			// Deployments are signed as calls to the zero address.
+			"from":     from.Hex(),
+			"to":       callee.Hex(),
+			"value":    &valueU256,
+			"gasLimit": hexUint64(gasLimit),
+			"gasPrice": &gasPriceU256,
+			"data":     data,
+			"leash": map[string]interface{}{
+				"nonce":       hexUint64(leash.Nonce),
+				"blockNumber": hexUint64(leash.BlockNumber),
+				"blockHash":   leash.BlockHash,
+				"blockRange":  hexUint64(leash.BlockRange),
+			},
+		},
+	}
+}
+
+// SignedCallDigest returns the EIP-712 digest of typedData, as returned by
+// SignableCall, that the 65-byte signature of a signed query is made over.
+func SignedCallDigest(typedData apitypes.TypedData) ([32]byte, error) {
+	domainSeparator, err := domainSeparators.get(typedData)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("failed to hash EIP712Domain: %w", err)
+	}
+	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("failed to hash typed data: %w", err)
+	}
+	return crypto.Keccak256Hash([]byte("\x19\x01"), domainSeparator, typedDataHash), nil
+}
+
+// signedCallDomainType is the EIP712Domain type of signed queries.
+var signedCallDomainType = []apitypes.Type{
+	{Name: "name", Type: "string"},
+	{Name: "version", Type: "string"},
+	{Name: "chainId", Type: "uint256"},
+}
+
+// maxDomainSeparators bounds the domain separators cached, as there are
+// only ever a few chains and domains in use.
+const maxDomainSeparators = 64
+
+// domainSeparators caches the domain separators of signed queries, which
+// are the same for every query on a chain.
+var domainSeparators = domainSeparatorCache{entries: make(map[domainKey][]byte)}
+
+type domainKey struct {
+	name, version, chainID string
+}
+
+type domainSeparatorCache struct {
+	mu      sync.RWMutex
+	entries map[domainKey][]byte
+}
+
+// get returns the domain separator of typedData, from the cache if its
+// domain is one of a signed query.
+func (c *domainSeparatorCache) get(typedData apitypes.TypedData) ([]byte, error) {
+	domain := typedData.Domain
+	if domain.ChainId == nil || domain.VerifyingContract != "" || domain.Salt != "" ||
+		!reflect.DeepEqual(typedData.Types["EIP712Domain"], signedCallDomainType) {
+		return typedData.HashStruct("EIP712Domain", domain.Map())
+	}
+	key := domainKey{domain.Name, domain.Version, (*big.Int)(domain.ChainId).String()}
+	c.mu.RLock()
+	separator, ok := c.entries[key]
+	c.mu.RUnlock()
+	if ok {
+		return separator, nil
+	}
+
+	separator, err := typedData.HashStruct("EIP712Domain", domain.Map())
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if len(c.entries) < maxDomainSeparators {
+		c.entries[key] = separator
+	}
+	c.mu.Unlock()
+	return separator, nil
+}
+
+// hexUint64 converts x without going through int64, which would turn values
+// above math.MaxInt64, e.g. ^uint64(0) as an unbounded block range, negative.
+func hexUint64(x uint64) *math.HexOrDecimal256 {
+	return (*math.HexOrDecimal256)(new(big.Int).SetUint64(x))
+}
+
+// TypedDataJSON encodes typedData, e.g. from SignableCall, as the
+// eth_signTypedData_v4 request wallets such as MetaMask sign: addresses and
+// bytes are 0x-prefixed hex strings and integers are decimal strings, so
+// that values beyond 2^53 survive JavaScript.
+func TypedDataJSON(typedData apitypes.TypedData) ([]byte, error) {
+	domain, err := walletStruct(typedData.Types, "EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode domain: %w", err)
+	}
+	message, err := walletStruct(typedData.Types, typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	return json.Marshal(struct {
+		Types       apitypes.Types         `json:"types"`
+		PrimaryType string                 `json:"primaryType"`
+		Domain      map[string]interface{} `json:"domain"`
+		Message     map[string]interface{} `json:"message"`
+	}{typedData.Types, typedData.PrimaryType, domain, message})
+}
+
+// walletStruct converts the fields of data, of struct type typ, with
+// walletValue. Fields missing from data are omitted.
+func walletStruct(types apitypes.Types, typ string, data map[string]interface{}) (map[string]interface{}, error) {
+	fields, ok := types[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+	out := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		v, ok := data[field.Name]
+		if !ok {
+			continue
+		}
+		converted, err := walletValue(types, field.Type, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", typ, field.Name, err)
+		}
+		out[field.Name] = converted
+	}
+	return out, nil
+}
+
+// walletValue converts v, of EIP-712 type typ, to its JSON representation.
+func walletValue(types apitypes.Types, typ string, v interface{}) (interface{}, error) {
+	if i := strings.LastIndex(typ, "["); i > 0 && strings.HasSuffix(typ, "]") {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return nil, fmt.Errorf("%T is not a %s", v, typ)
+		}
+		out := make([]interface{}, rv.Len())
+		for j := range out {
+			elem, err := walletValue(types, typ[:i], rv.Index(j).Interface())
+			if err != nil {
+				return nil, err
+			}
+			out[j] = elem
+		}
+		return out, nil
+	}
+	if _, ok := types[typ]; ok {
+		data, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%T is not a %s", v, typ)
+		}
+		return walletStruct(types, typ, data)
+	}
+
+	switch {
+	case typ == "address":
+		switch addr := v.(type) {
+		case common.Address:
+			return addr.Hex(), nil
+		case string:
+			if common.IsHexAddress(addr) {
+				return common.HexToAddress(addr).Hex(), nil
+			}
+		case []byte:
+			if len(addr) == common.AddressLength {
+				return common.BytesToAddress(addr).Hex(), nil
+			}
+		}
+	case typ == "string" || typ == "bool":
+		return v, nil
+	case strings.HasPrefix(typ, "bytes"):
+		switch b := v.(type) {
+		case []byte:
+			return hexutil.Encode(b), nil
+		case hexutil.Bytes:
+			return b.String(), nil
+		case string:
+			if _, err := hexutil.Decode(b); err == nil {
+				return b, nil
+			}
+		default:
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+				b := make([]byte, rv.Len())
+				reflect.Copy(reflect.ValueOf(b), rv)
+				return hexutil.Encode(b), nil
+			}
+		}
+	case strings.HasPrefix(typ, "uint") || strings.HasPrefix(typ, "int"):
+		switch n := v.(type) {
+		case *math.HexOrDecimal256:
+			return (*big.Int)(n).String(), nil
+		case *big.Int:
+			return n.String(), nil
+		case string:
+			if x, ok := math.ParseBig256(n); ok {
+				return x.String(), nil
+			}
+		default:
+			if rv := reflect.ValueOf(v); rv.CanInt() {
+				return big.NewInt(rv.Int()).String(), nil
+			} else if rv.CanUint() {
+				return new(big.Int).SetUint64(rv.Uint()).String(), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("cannot encode %T as %s", v, typ)
+}
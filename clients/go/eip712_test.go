@@ -0,0 +1,313 @@
+package sapphire
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+)
+
+// signedQueryVector is a signed query and its EIP-712 digest with
+// 0x-prefixed addresses, as ethers' TypedDataEncoder and the runtime compute
+// it.
+type signedQueryVector struct {
+	Name     string          `json:"name"`
+	ChainID  uint64          `json:"chainId"`
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	GasLimit uint64          `json:"gasLimit"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Data     hexutil.Bytes   `json:"data"`
+	Leash    struct {
+		Nonce       uint64      `json:"nonce"`
+		BlockNumber uint64      `json:"blockNumber"`
+		BlockHash   common.Hash `json:"blockHash"`
+		BlockRange  uint64      `json:"blockRange"`
+	} `json:"leash"`
+	// Domain is DefaultSignedCallDomain if not set.
+	Domain *SignedCallDomain `json:"domain,omitempty"`
+	Digest common.Hash       `json:"digest"`
+}
+
+func loadSignedQueryVectors(t testing.TB) []signedQueryVector {
+	raw, err := os.ReadFile(filepath.Join("testdata", "signed_query_vectors.json"))
+	if err != nil {
+		t.Fatalf("failed to read vectors: %v", err)
+	}
+	var vectors []signedQueryVector
+	if err = json.Unmarshal(raw, &vectors); err != nil {
+		t.Fatalf("failed to decode vectors: %v", err)
+	}
+	return vectors
+}
+
+func (v *signedQueryVector) domain() SignedCallDomain {
+	if v.Domain != nil {
+		return *v.Domain
+	}
+	return DefaultSignedCallDomain
+}
+
+func (v *signedQueryVector) leash() evm.Leash {
+	return evm.Leash{
+		Nonce:       v.Leash.Nonce,
+		BlockNumber: v.Leash.BlockNumber,
+		BlockHash:   v.Leash.BlockHash[:],
+		BlockRange:  v.Leash.BlockRange,
+	}
+}
+
+// referenceDigest encodes a signed query per EIP-712 by hand, independently
+// of apitypes.
+func referenceDigest(v *signedQueryVector) common.Hash {
+	word := func(x *big.Int) []byte { return common.BigToHash(x).Bytes() }
+	u64 := func(x uint64) []byte { return word(new(big.Int).SetUint64(x)) }
+	var to common.Address
+	if v.To != nil {
+		to = *v.To
+	}
+
+	leashType := "Leash(uint64 nonce,uint64 blockNumber,bytes32 blockHash,uint64 blockRange)"
+	leashHash := crypto.Keccak256(
+		crypto.Keccak256([]byte(leashType)),
+		u64(v.Leash.Nonce), u64(v.Leash.BlockNumber), v.Leash.BlockHash[:], u64(v.Leash.BlockRange),
+	)
+	callType := "Call(address from,address to,uint64 gasLimit,uint256 gasPrice,uint256 value,bytes data,Leash leash)" + leashType
+	callHash := crypto.Keccak256(
+		crypto.Keccak256([]byte(callType)),
+		common.BytesToHash(v.From[:]).Bytes(), common.BytesToHash(to[:]).Bytes(),
+		u64(v.GasLimit), word(v.GasPrice.ToInt()), word(v.Value.ToInt()),
+		crypto.Keccak256(v.Data), leashHash,
+	)
+	domainHash := crypto.Keccak256(
+		crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId)")),
+		crypto.Keccak256([]byte(v.domain().Name)), crypto.Keccak256([]byte(v.domain().Version)), u64(v.ChainID),
+	)
+	return crypto.Keccak256Hash([]byte("\x19\x01"), domainHash, callHash)
+}
+
+// TestSignedQueryVectors guards against signing a digest other clients and
+// the runtime don't compute, e.g. from addresses lacking their 0x prefix.
+func TestSignedQueryVectors(t *testing.T) {
+	key, _ := crypto.HexToECDSA("8160d68c4bf9425b1d3a14dc6d59a99d7d130428203042a8d419e68d626bd9f2")
+	for _, v := range loadSignedQueryVectors(t) {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			typedData := v.domain().SignableCall(v.ChainID, v.From, v.To, v.GasLimit, v.GasPrice.ToInt(), v.Value.ToInt(), v.Data, v.leash())
+			for _, field := range []string{"from", "to"} {
+				if addr, _ := typedData.Message[field].(string); !has0xPrefix(addr) {
+					t.Fatalf("%s address %q is not 0x-prefixed", field, addr)
+				}
+			}
+			digest, err := SignedCallDigest(typedData)
+			if err != nil {
+				t.Fatalf("failed to hash signed query: %v", err)
+			}
+			if digest != v.Digest {
+				t.Fatalf("digest %x, expected %x", digest, v.Digest)
+			}
+			if ref := referenceDigest(&v); ref != v.Digest {
+				t.Fatalf("vector digest %x does not match the EIP-712 encoding %x", v.Digest, ref)
+			}
+
+			pack, err := NewSignedCall(context.Background(), NewPrivateKeySigner(key), new(big.Int).SetUint64(v.ChainID), v.To, v.Data,
+				WithCallDomain(v.domain()),
+				WithCaller(v.From),
+				WithGasLimit(v.GasLimit),
+				WithGasPrice(v.GasPrice.ToInt()),
+				WithValue(v.Value.ToInt()),
+				WithLeash(v.leash()),
+			)
+			if err != nil {
+				t.Fatalf("failed to sign query: %v", err)
+			}
+			sig := append([]byte(nil), pack.Signature...)
+			sig[64] -= 27
+			pub, err := crypto.SigToPub(v.Digest[:], sig)
+			if err != nil || crypto.PubkeyToAddress(*pub) != crypto.PubkeyToAddress(key.PublicKey) {
+				t.Fatalf("query not signed over the expected digest")
+			}
+		})
+	}
+}
+
+func has0xPrefix(s string) bool {
+	return len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X')
+}
+
+func TestSignableCallTypes(t *testing.T) {
+	typedData := SignableCall(0x5afd, common.Address{}, nil, 0, nil, nil, nil, evm.Leash{})
+	const expected = "Call(address from,address to,uint64 gasLimit,uint256 gasPrice,uint256 value,bytes data,Leash leash)Leash(uint64 nonce,uint64 blockNumber,bytes32 blockHash,uint64 blockRange)"
+	if encoded := string(typedData.EncodeType("Call")); encoded != expected {
+		t.Fatalf("signed query type changed to %s", encoded)
+	}
+	if typedData.Message["to"] != (common.Address{}).Hex() {
+		t.Fatalf("deployment not signed as a call to the zero address: %v", typedData.Message["to"])
+	}
+}
+
+func TestTypedDataJSON(t *testing.T) {
+	for _, v := range loadSignedQueryVectors(t) {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			encoded, err := TypedDataJSON(v.domain().SignableCall(v.ChainID, v.From, v.To, v.GasLimit, v.GasPrice.ToInt(), v.Value.ToInt(), v.Data, v.leash()))
+			if err != nil {
+				t.Fatalf("TypedDataJSON failed: %v", err)
+			}
+
+			// Wallets only get strings, never numbers or byte arrays.
+			var request struct {
+				Domain  map[string]interface{} `json:"domain"`
+				Message struct {
+					From     string            `json:"from"`
+					GasLimit string            `json:"gasLimit"`
+					Data     string            `json:"data"`
+					Leash    map[string]string `json:"leash"`
+				} `json:"message"`
+			}
+			if err = json.Unmarshal(encoded, &request); err != nil {
+				t.Fatalf("unexpected JSON shape %s: %v", encoded, err)
+			}
+			if request.Domain["chainId"] != new(big.Int).SetUint64(v.ChainID).String() ||
+				request.Message.From != v.From.Hex() ||
+				request.Message.GasLimit != new(big.Int).SetUint64(v.GasLimit).String() ||
+				request.Message.Data != hexutil.Encode(v.Data) ||
+				request.Message.Leash["blockHash"] != v.Leash.BlockHash.Hex() {
+				t.Fatalf("unexpected encoding %s", encoded)
+			}
+
+			var parsed apitypes.TypedData
+			if err = json.Unmarshal(encoded, &parsed); err != nil {
+				t.Fatalf("go-ethereum can't parse %s: %v", encoded, err)
+			}
+			digest, err := SignedCallDigest(parsed)
+			if err != nil {
+				t.Fatalf("failed to hash parsed typed data: %v", err)
+			}
+			if digest != v.Digest {
+				t.Fatalf("digest %x after a JSON round trip, expected %x", digest, v.Digest)
+			}
+		})
+	}
+}
+
+// uncachedSignedCallDigest is SignedCallDigest without the domain separator
+// cache.
+func uncachedSignedCallDigest(t testing.TB, typedData apitypes.TypedData) [32]byte {
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		t.Fatalf("failed to hash domain: %v", err)
+	}
+	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		t.Fatalf("failed to hash message: %v", err)
+	}
+	return crypto.Keccak256Hash([]byte("\x19\x01"), domainSeparator, typedDataHash)
+}
+
+func TestDomainSeparatorCache(t *testing.T) {
+	for _, v := range loadSignedQueryVectors(t) {
+		typedData := v.domain().SignableCall(v.ChainID, v.From, v.To, v.GasLimit, v.GasPrice.ToInt(), v.Value.ToInt(), v.Data, v.leash())
+		// Once to fill the cache and once from it.
+		for i := 0; i < 2; i++ {
+			digest, err := SignedCallDigest(typedData)
+			if err != nil {
+				t.Fatalf("failed to hash signed query: %v", err)
+			}
+			if digest != uncachedSignedCallDigest(t, typedData) {
+				t.Fatalf("%s: cached digest differs", v.Name)
+			}
+		}
+	}
+
+	// Typed data with another domain type is never served from the cache.
+	typedData := SignableCall(0x5afd, common.Address{}, nil, 1, nil, nil, nil, evm.Leash{BlockHash: make([]byte, 32)})
+	if _, err := SignedCallDigest(typedData); err != nil {
+		t.Fatalf("failed to hash signed query: %v", err)
+	}
+	typedData.Domain.VerifyingContract = "0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883"
+	typedData.Types = apitypes.Types{
+		"EIP712Domain": append(append([]apitypes.Type(nil), signedCallDomainType...), apitypes.Type{Name: "verifyingContract", Type: "address"}),
+		"Call":         typedData.Types["Call"],
+		"Leash":        typedData.Types["Leash"],
+	}
+	digest, err := SignedCallDigest(typedData)
+	if err != nil {
+		t.Fatalf("failed to hash typed data: %v", err)
+	}
+	if digest != uncachedSignedCallDigest(t, typedData) {
+		t.Fatalf("digest of another domain type served from the cache")
+	}
+}
+
+func BenchmarkSignedCallDigest(b *testing.B) {
+	to := common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+	typedData := SignableCall(0x5afd, to, &to, DefaultGasLimit, big.NewInt(DefaultGasPrice), nil, make([]byte, 68), evm.Leash{BlockHash: make([]byte, 32)})
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := SignedCallDigest(typedData); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			uncachedSignedCallDigest(b, typedData)
+		}
+	})
+}
+
+func TestSignableCallBig(t *testing.T) {
+	from := common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	leash := evm.Leash{BlockHash: make([]byte, 32), BlockRange: DefaultBlockRange}
+	chainID, _ := new(big.Int).SetString("9223372036854775813", 10) // 2^63 + 5.
+
+	typedData, err := SignableCallBig(chainID, from, nil, DefaultGasLimit, nil, nil, nil, leash)
+	if err != nil {
+		t.Fatalf("SignableCallBig failed: %v", err)
+	}
+	separator, err := domainSeparators.get(typedData)
+	if err != nil {
+		t.Fatalf("failed to hash domain: %v", err)
+	}
+	// go-ethereum's own parsing of the domain, as a wallet would send it.
+	var parsed apitypes.TypedData
+	if err = json.Unmarshal([]byte(`{
+		"types": {"EIP712Domain": [{"name":"name","type":"string"},{"name":"version","type":"string"},{"name":"chainId","type":"uint256"}]},
+		"domain": {"name": "oasis-runtime-sdk/evm: signed query", "version": "1.0.0", "chainId": "9223372036854775813"}
+	}`), &parsed); err != nil {
+		t.Fatalf("failed to parse typed data: %v", err)
+	}
+	expected, err := parsed.HashStruct("EIP712Domain", parsed.Domain.Map())
+	if err != nil {
+		t.Fatalf("failed to hash domain: %v", err)
+	}
+	if !bytes.Equal(separator, expected) {
+		t.Fatalf("domain separator %x, go-ethereum computes %x", separator, expected)
+	}
+	if small := SignableCall(0x5afd, from, nil, DefaultGasLimit, nil, nil, nil, leash); (*big.Int)(small.Domain.ChainId).Uint64() != 0x5afd {
+		t.Fatalf("uint64 wrapper built chain ID %v", (*big.Int)(small.Domain.ChainId))
+	}
+
+	key, _ := crypto.GenerateKey()
+	signer := NewPrivateKeySigner(key)
+	for _, invalid := range []*big.Int{nil, big.NewInt(0), big.NewInt(-1), new(big.Int).Lsh(big.NewInt(1), 256)} {
+		if _, err := SignableCallBig(invalid, from, nil, DefaultGasLimit, nil, nil, nil, leash); !errors.Is(err, ErrInvalidSignedCall) {
+			t.Fatalf("expected chain ID %v to be rejected, got %v", invalid, err)
+		}
+		if _, err := NewSignedCall(context.Background(), signer, invalid, nil, nil, WithLeash(leash)); !errors.Is(err, ErrInvalidSignedCall) {
+			t.Fatalf("expected NewSignedCall to reject chain ID %v, got %v", invalid, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package sapphire
+
+import "errors"
+
+// Errors classifying failures, to be matched with errors.Is. The errors of
+// the features they belong to, e.g. ErrCallFailed, ErrNoSigner,
+// ErrInvalidSignedCall or ErrResponseTooLarge, complete the set, as do
+// ModuleError and RevertError and the client package's CallError,
+// ErrCapabilityUnsupported and HistoricalStateError, which are matched with
+// errors.As.
+var (
+	// ErrKeyFetchFailed is returned when the runtime calldata public key
+	// could not be fetched or verified.
+	ErrKeyFetchFailed = errors.New("failed to fetch runtime calldata public key")
+	// ErrKeyNotSigned is returned, with ErrKeyFetchFailed, for runtime
+	// calldata public keys without the key manager's checksum and signature.
+	ErrKeyNotSigned = errors.New("runtime calldata public key is not signed by the key manager")
+	// ErrKeySignatureInvalid is returned, with ErrKeyFetchFailed, for runtime
+	// calldata public keys whose signature doesn't verify against the key set
+	// with WithKeyManagerKey.
+	ErrKeySignatureInvalid = errors.New("invalid key manager signature of runtime calldata public key")
+	// ErrNotSapphireChain is returned when wrapping a client of a gateway
+	// that is not a Sapphire gateway.
+	ErrNotSapphireChain = errors.New("gateway is not a Sapphire gateway")
+	// ErrMalformedEnvelope is returned for call results that can't be
+	// decoded or decrypted.
+	ErrMalformedEnvelope = errors.New("malformed call envelope")
+	// ErrSignatureRejected is returned for malformed signatures and for
+	// signed queries whose signature the runtime rejected.
+	ErrSignatureRejected = errors.New("signature rejected")
+	// ErrLeashExpired is returned for signed queries the runtime rejected
+	// because their leash's block is too old or unknown.
+	ErrLeashExpired = errors.New("leash expired")
+	// ErrLeashNonceMismatch is returned for signed queries the runtime
+	// rejected because their leash's nonce is not the sender's.
+	ErrLeashNonceMismatch = errors.New("leash nonce mismatch")
+	// ErrUnsupportedBackend is returned by operations the wrapped backend
+	// can't do, e.g. ones needing an ethclient.Client.
+	ErrUnsupportedBackend = errors.New("unsupported backend")
+	// ErrUnencryptedCalldata is returned instead of sending calldata in the
+	// clear.
+	ErrUnencryptedCalldata = errors.New("refusing to send unencrypted calldata")
+	// ErrUnsupportedTransaction is returned for transaction types that can't
+	// be handled.
+	ErrUnsupportedTransaction = errors.New("unsupported transaction type")
+	// ErrEndpointDisagreement is returned when the verifier of
+	// WithEndpointVerifier disagrees with the gateway on a leash block or the
+	// chain ID.
+	ErrEndpointDisagreement = errors.New("gateway and verifier disagree")
+	// ErrABI is returned by the PackAnd* helpers when the method or its
+	// arguments or results don't match the contract ABI. Errors returned by
+	// the chain do not wrap it.
+	ErrABI = errors.New("abi error")
+)
+
+// dataError is rpc.DataError, the JSON-RPC errors gateways attach data to,
+// declared here so that decoding them doesn't need the rpc package.
+type dataError interface {
+	Error() string
+	ErrorData() interface{}
+}
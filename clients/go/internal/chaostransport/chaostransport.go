@@ -0,0 +1,334 @@
+// Package chaostransport injects faults into JSON-RPC over HTTP, for testing
+// how a client copes with an unreliable gateway:
+//
+//	chaos := chaostransport.New(http.DefaultTransport, 1)
+//	chaos.Every("", 3, chaostransport.Refuse)
+//	c, _ := rpc.DialOptions(ctx, url, rpc.WithHTTPClient(chaos.Client()))
+//
+// Faults are scheduled per JSON-RPC method, as a scripted sequence with
+// Script, periodically with Every or at random with Randomly. Random faults
+// are drawn from a math/rand source seeded by New, so a run that failed
+// reproduces with the same seed as long as the requests are made in the same
+// order.
+package chaostransport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type faultKind int
+
+const (
+	pass faultKind = iota
+	delay
+	refuse
+	status
+	reset
+	resetMidBody
+	garbage
+	shuffle
+)
+
+// Fault is a failure injected into a request. The zero Fault lets the
+// request through unharmed.
+type Fault struct {
+	kind   faultKind
+	delay  time.Duration
+	status int
+}
+
+var (
+	// Pass lets the request through unharmed.
+	Pass = Fault{}
+	// Refuse fails the request without sending it, as if the connection was
+	// refused.
+	Refuse = Fault{kind: refuse}
+	// Reset sends the request, then fails it as if the connection was reset
+	// before the response arrived. The gateway handles the request, but the
+	// client can't tell.
+	Reset = Fault{kind: reset}
+	// ResetMidBody sends the request and cuts its response off halfway with
+	// a connection reset.
+	ResetMidBody = Fault{kind: resetMidBody}
+	// Garbage sends the request and replaces its response with invalid JSON.
+	Garbage = Fault{kind: garbage}
+	// Shuffle sends the request and shuffles the responses of a batch.
+	// Responses to single requests are left as is.
+	Shuffle = Fault{kind: shuffle}
+)
+
+// Delay delays the request by d, or until it is cancelled, then sends it.
+func Delay(d time.Duration) Fault {
+	return Fault{kind: delay, delay: d}
+}
+
+// Status answers the request with the HTTP status code without sending it.
+func Status(code int) Fault {
+	return Fault{kind: status, status: code}
+}
+
+func (f Fault) String() string {
+	switch f.kind {
+	case pass:
+		return "pass"
+	case delay:
+		return "delay " + f.delay.String()
+	case refuse:
+		return "refuse"
+	case status:
+		return "status " + strconv.Itoa(f.status)
+	case reset:
+		return "reset"
+	case resetMidBody:
+		return "reset mid-body"
+	case garbage:
+		return "garbage"
+	case shuffle:
+		return "shuffle"
+	default:
+		return fmt.Sprintf("fault %d", f.kind)
+	}
+}
+
+// Injection is a fault injected into a request.
+type Injection struct {
+	// Methods are the JSON-RPC methods of the request, several for batches.
+	Methods []string
+	Fault   Fault
+}
+
+// rule schedules faults for the requests of a method.
+type rule struct {
+	method string
+	// next returns the fault of the next matching request, and whether the
+	// rule has one.
+	next func(rng *rand.Rand) (Fault, bool)
+}
+
+// Transport is an http.RoundTripper injecting faults into the requests it
+// sends with another one.
+type Transport struct {
+	next http.RoundTripper
+
+	mu         sync.Mutex
+	rng        *rand.Rand
+	rules      []*rule
+	injections []Injection
+}
+
+// New returns a transport sending requests with next, drawing random faults
+// from seed.
+func New(next http.RoundTripper, seed int64) *Transport {
+	return &Transport{
+		next: next,
+		rng:  rand.New(rand.NewSource(seed)), //nolint:gosec
+	}
+}
+
+// Client returns an HTTP client sending its requests with t.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// Script makes the next requests for method fail with faults in turn. An
+// empty method matches any request. Rules are consulted in the order they
+// were added, and the first with a fault for a request applies.
+func (t *Transport) Script(method string, faults ...Fault) {
+	t.add(method, func(*rand.Rand) (Fault, bool) {
+		if len(faults) == 0 {
+			return Pass, false
+		}
+		f := faults[0]
+		faults = faults[1:]
+		return f, true
+	})
+}
+
+// Every makes every n-th request for method, counting from the time of the
+// call, fail with fault.
+func (t *Transport) Every(method string, n int, fault Fault) {
+	var seen int
+	t.add(method, func(*rand.Rand) (Fault, bool) {
+		seen++
+		if seen%n != 0 {
+			return Pass, false
+		}
+		return fault, true
+	})
+}
+
+// Randomly makes requests for method fail with probability p, with one of
+// faults picked at random.
+func (t *Transport) Randomly(method string, p float64, faults ...Fault) {
+	t.add(method, func(rng *rand.Rand) (Fault, bool) {
+		if rng.Float64() >= p {
+			return Pass, false
+		}
+		return faults[rng.Intn(len(faults))], true
+	})
+}
+
+// Clear removes all rules, letting further requests through unharmed.
+func (t *Transport) Clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = nil
+}
+
+// Injections returns the faults injected so far, other than Pass.
+func (t *Transport) Injections() []Injection {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Injection(nil), t.injections...)
+}
+
+func (t *Transport) add(method string, next func(*rand.Rand) (Fault, bool)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = append(t.rules, &rule{method: method, next: next})
+}
+
+// fault returns the fault of a request for methods. Every rule matching the
+// request counts it, even once one has a fault for it.
+func (t *Transport) fault(methods []string) Fault {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fault, found := Pass, false
+	for _, r := range t.rules {
+		if !r.matches(methods) {
+			continue
+		}
+		if f, ok := r.next(t.rng); ok && !found {
+			fault, found = f, true
+		}
+	}
+	if fault != Pass {
+		t.injections = append(t.injections, Injection{Methods: methods, Fault: fault})
+	}
+	return fault
+}
+
+func (r *rule) matches(methods []string) bool {
+	if r.method == "" {
+		return true
+	}
+	for _, method := range methods {
+		if method == r.method {
+			return true
+		}
+	}
+	return false
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	fault := t.fault(methods(body))
+	switch fault.kind {
+	case delay:
+		timer := time.NewTimer(fault.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	case refuse:
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	case status:
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", fault.status, http.StatusText(fault.status)),
+			StatusCode: fault.status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || fault.kind == pass || fault.kind == delay {
+		return resp, err
+	}
+	raw, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	switch fault.kind {
+	case reset:
+		return nil, connReset()
+	case resetMidBody:
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(raw[:len(raw)/2]), errReader{connReset()}))
+		return resp, nil
+	case garbage:
+		raw = []byte(`{"jsonrpc":"2.0","id":<html>Bad Gateway</html>`)
+	case shuffle:
+		var batch []json.RawMessage
+		if json.Unmarshal(raw, &batch) == nil {
+			t.mu.Lock()
+			t.rng.Shuffle(len(batch), func(i, j int) { batch[i], batch[j] = batch[j], batch[i] })
+			t.mu.Unlock()
+			raw, _ = json.Marshal(batch)
+		}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+	resp.ContentLength = int64(len(raw))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(raw)))
+	return resp, nil
+}
+
+// methods returns the JSON-RPC methods of a request or batch.
+func methods(body []byte) []string {
+	var batch []struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(body, &batch); err == nil {
+		methods := make([]string, 0, len(batch))
+		for _, msg := range batch {
+			methods = append(methods, msg.Method)
+		}
+		return methods
+	}
+	var msg struct {
+		Method string `json:"method"`
+	}
+	_ = json.Unmarshal(body, &msg)
+	return []string{msg.Method}
+}
+
+// connReset returns the error of a read from a connection reset by the peer.
+func connReset() error {
+	return &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+}
+
+// errReader is an io.Reader failing with err.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
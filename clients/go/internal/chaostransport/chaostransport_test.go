@@ -0,0 +1,189 @@
+package chaostransport_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/chaostransport"
+)
+
+// echoServer answers every JSON-RPC request, batched or not, with its
+// method.
+func echoServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		type request struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		type response struct {
+			Version string          `json:"jsonrpc"`
+			ID      json.RawMessage `json:"id"`
+			Result  string          `json:"result"`
+		}
+		var raw json.RawMessage
+		_ = json.NewDecoder(r.Body).Decode(&raw)
+		var batch []request
+		if json.Unmarshal(raw, &batch) != nil {
+			var req request
+			_ = json.Unmarshal(raw, &req)
+			_ = json.NewEncoder(w).Encode(response{"2.0", req.ID, req.Method})
+			return
+		}
+		responses := make([]response, 0, len(batch))
+		for _, req := range batch {
+			responses = append(responses, response{"2.0", req.ID, req.Method})
+		}
+		_ = json.NewEncoder(w).Encode(responses)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dial(t *testing.T, chaos *chaostransport.Transport) *rpc.Client {
+	c, err := rpc.DialOptions(context.Background(), echoServer(t).URL, rpc.WithHTTPClient(chaos.Client()))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+func call(c *rpc.Client, method string) error {
+	var res string
+	if err := c.Call(&res, method); err != nil {
+		return err
+	}
+	if res != method {
+		return errors.New("unexpected result " + res)
+	}
+	return nil
+}
+
+func TestFaults(t *testing.T) {
+	chaos := chaostransport.New(http.DefaultTransport, 1)
+	c := dial(t, chaos)
+
+	chaos.Script("m", chaostransport.Refuse)
+	var opErr *net.OpError
+	if err := call(c, "m"); !errors.As(err, &opErr) || opErr.Op != "dial" || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("expected a refused connection, got %v", err)
+	}
+	chaos.Script("m", chaostransport.Reset)
+	if err := call(c, "m"); !errors.As(err, &opErr) || opErr.Op != "read" || !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("expected a reset connection, got %v", err)
+	}
+	chaos.Script("m", chaostransport.ResetMidBody)
+	if err := call(c, "m"); !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("expected the response to be cut off, got %v", err)
+	}
+	chaos.Script("m", chaostransport.Status(http.StatusServiceUnavailable))
+	var httpErr rpc.HTTPError
+	if err := call(c, "m"); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected a 503 response, got %v", err)
+	}
+	chaos.Script("m", chaostransport.Garbage)
+	var syntaxErr *json.SyntaxError
+	if err := call(c, "m"); !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected invalid JSON, got %v", err)
+	}
+	chaos.Script("m", chaostransport.Delay(50*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := c.CallContext(ctx, new(string), "m"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the delay to outlast the deadline, got %v", err)
+	}
+	if err := call(c, "m"); err != nil {
+		t.Fatalf("expected the script to be used up, got %v", err)
+	}
+	if n := len(chaos.Injections()); n != 6 {
+		t.Fatalf("expected 6 injected faults, got %d", n)
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	chaos := chaostransport.New(http.DefaultTransport, 1)
+	c := dial(t, chaos)
+	chaos.Every("", 1, chaostransport.Shuffle)
+
+	batch := make([]rpc.BatchElem, 8)
+	for i := range batch {
+		batch[i] = rpc.BatchElem{Method: string(rune('a' + i)), Result: new(string)}
+	}
+	if err := c.BatchCall(batch); err != nil {
+		t.Fatalf("batch failed: %v", err)
+	}
+	for _, elem := range batch {
+		if elem.Error != nil || *elem.Result.(*string) != elem.Method {
+			t.Fatalf("batch response of %s mismatched: %v, %v", elem.Method, *elem.Result.(*string), elem.Error)
+		}
+	}
+	if err := call(c, "single"); err != nil {
+		t.Fatalf("single request failed: %v", err)
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	// run makes 30 requests for a and b, and returns which failed.
+	run := func(seed int64, schedule func(*chaostransport.Transport)) []bool {
+		chaos := chaostransport.New(http.DefaultTransport, seed)
+		c := dial(t, chaos)
+		schedule(chaos)
+		var failed []bool
+		for i := 0; i < 30; i++ {
+			failed = append(failed, call(c, "a") != nil, call(c, "b") != nil)
+		}
+		return failed
+	}
+	count := func(failed []bool) (n int) {
+		for _, f := range failed {
+			if f {
+				n++
+			}
+		}
+		return n
+	}
+
+	every := run(1, func(chaos *chaostransport.Transport) {
+		chaos.Every("a", 3, chaostransport.Refuse)
+	})
+	for i, f := range every {
+		if f != (i%2 == 0 && (i/2+1)%3 == 0) {
+			t.Fatalf("request %d failed: %v", i, f)
+		}
+	}
+
+	// Rules apply in order, so the script takes precedence.
+	scripted := run(1, func(chaos *chaostransport.Transport) {
+		chaos.Script("", chaostransport.Refuse, chaostransport.Pass, chaostransport.Refuse)
+		chaos.Every("", 1, chaostransport.Pass)
+	})
+	if !reflect.DeepEqual(scripted[:4], []bool{true, false, true, false}) || count(scripted) != 2 {
+		t.Fatalf("unexpected failures %v", scripted)
+	}
+
+	random := func(seed int64) []bool {
+		return run(seed, func(chaos *chaostransport.Transport) {
+			chaos.Randomly("", 0.5, chaostransport.Refuse, chaostransport.Status(http.StatusBadGateway))
+		})
+	}
+	first := random(7)
+	if n := count(first); n < 15 || n > 45 {
+		t.Fatalf("expected about half the requests to fail, got %d of 60", n)
+	}
+	if !reflect.DeepEqual(first, random(7)) {
+		t.Fatalf("failures differ between runs with the same seed")
+	}
+	if reflect.DeepEqual(first, random(8)) {
+		t.Fatalf("failures do not depend on the seed")
+	}
+}
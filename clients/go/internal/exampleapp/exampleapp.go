@@ -0,0 +1,400 @@
+// Package exampleapp is a dapp backend using the client the way a real one
+// does: it dials the gateway, deploys a confidential contract through its
+// abigen bindings, writes to it with an encrypted transaction, reads it back
+// with a signed query, handles a revert, delegates to consensus with a
+// subcall and relays a gasless transaction.
+//
+// Its test runs it against a localnet as the smoke test of the whole client,
+// catching interactions between features their own tests miss, such as the
+// nonce tracking of the wrapped client and transactions it did not send
+// itself. It doubles as reference code for the features it uses.
+//
+// Run reports the outcome of each stage as a Result, which encodes to JSON:
+//
+//	{"stage":"deploy","status":"pass","detail":"vault at 0x…","durationMs":1234}
+package exampleapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/crypto/signature"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/client"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/consensus"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/exampleapp/vault"
+)
+
+// Status is the outcome of a stage.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+	// StatusSkip means the stage did not run, because it is not configured
+	// or a stage it builds on did not pass.
+	StatusSkip Status = "skip"
+)
+
+// Result is the outcome of a stage.
+type Result struct {
+	Stage  string `json:"stage"`
+	Status Status `json:"status"`
+	// Detail describes what the stage did, or why it failed or was skipped.
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Config configures Run.
+type Config struct {
+	// Gateway is the URL of the Web3 gateway.
+	Gateway string
+	// Key is the key of the funded account the app sends from.
+	Key *ecdsa.PrivateKey
+	// Payer pays for gasless transactions. The gasless stages are skipped if
+	// it or ChainContext is not set.
+	Payer signature.Signer
+	// ChainContext is the consensus chain context gasless transactions are
+	// signed for. Gateways don't serve it, so it must be configured.
+	ChainContext string
+}
+
+// Delegation is the amount the delegate stage delegates, 10 ROSE.
+var Delegation = new(big.Int).Mul(big.NewInt(10), new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
+
+// receiptPollInterval is how often the delegate stage polls for its receipt.
+const receiptPollInterval = time.Second
+
+// app is the state the stages share.
+type app struct {
+	cfg    Config
+	signer sapphire.SignerWithAddress
+	from   common.Address
+
+	client  *ethclient.Client
+	chainID *big.Int
+	backend *client.WrappedBackend
+	addr    common.Address
+	vault   *vault.SapphireVault
+}
+
+// stage is a step of the app, run if the stages it needs passed.
+type stage struct {
+	name  string
+	needs []string
+	run   func(ctx context.Context) (string, error)
+	// skip, if set, returns why the stage is not configured to run.
+	skip func() string
+}
+
+// Run runs the app's stages in order, calling report, if not nil, with the
+// result of each as soon as it is known, and returns all results.
+func Run(ctx context.Context, cfg Config, report func(Result)) []Result {
+	a := &app{cfg: cfg, signer: sapphire.NewPrivateKeySigner(cfg.Key)}
+	a.from = a.signer.Address()
+	defer func() {
+		if a.client != nil {
+			a.client.Close()
+		}
+	}()
+	noGasless := func() string {
+		if cfg.Payer == nil || cfg.ChainContext == "" {
+			return "no gasless payer or chain context configured"
+		}
+		return ""
+	}
+
+	stages := []stage{
+		{name: "dial", run: a.dial},
+		{name: "deploy", needs: []string{"dial"}, run: a.deploy},
+		{name: "write", needs: []string{"deploy"}, run: a.write},
+		{name: "read", needs: []string{"write"}, run: a.read},
+		{name: "revert", needs: []string{"write"}, run: a.revert},
+		{name: "delegate", needs: []string{"dial"}, run: a.delegate},
+		{name: "gasless", needs: []string{"deploy"}, run: a.gasless, skip: noGasless},
+		// Sending again after the gasless transaction, whose inner nonce
+		// was handed out but never used on chain, checks the nonce tracking.
+		{name: "write-after-gasless", needs: []string{"gasless"}, run: a.writeAfterGasless},
+	}
+	passed := make(map[string]bool)
+	var results []Result
+	for _, s := range stages {
+		res := Result{Stage: s.name, Status: StatusSkip}
+		for _, need := range s.needs {
+			if !passed[need] {
+				res.Detail = need + " did not pass"
+			}
+		}
+		if res.Detail == "" && s.skip != nil {
+			res.Detail = s.skip()
+		}
+		if res.Detail == "" {
+			start := time.Now()
+			detail, err := s.run(ctx)
+			res.DurationMs = time.Since(start).Milliseconds()
+			res.Status, res.Detail = StatusPass, detail
+			if err != nil {
+				res.Status, res.Detail = StatusFail, err.Error()
+			}
+		}
+		passed[s.name] = res.Status == StatusPass
+		if report != nil {
+			report(res)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// dial connects to the gateway and wraps the client, so that calls and
+// transactions are encrypted and queries from the app's account are signed.
+func (a *app) dial(ctx context.Context) (string, error) {
+	eth, err := ethclient.DialContext(ctx, a.cfg.Gateway)
+	if err != nil {
+		return "", err
+	}
+	a.client = eth
+	if a.backend, err = client.WrapClient(eth, nil, client.WithKeyring(sapphire.NewKeyring(a.signer))); err != nil {
+		return "", err
+	}
+	if a.chainID, err = eth.ChainID(ctx); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("chain %#x as %s", a.chainID, a.from), nil
+}
+
+// deploy deploys the vault with its abigen binding. Transactions signed by
+// the wrapped client's transactor are encrypted, deployments included.
+func (a *app) deploy(ctx context.Context) (string, error) {
+	opts := a.backend.Transactor(a.from)
+	opts.Context = ctx
+	_, tx, _, err := vault.DeployVault(opts, a.backend)
+	if err != nil {
+		return "", err
+	}
+	if a.addr, err = a.backend.WaitDeployed(ctx, tx); err != nil {
+		return "", err
+	}
+	if a.vault, err = vault.NewSapphireVault(a.addr, a.backend, a.signer); err != nil {
+		return "", err
+	}
+	return "vault at " + a.addr.Hex(), nil
+}
+
+// write stores a value with an encrypted transaction, and checks that the
+// value is not in the mined calldata.
+func (a *app) write(ctx context.Context) (string, error) {
+	value := big.NewInt(0x5ec2e7)
+	tx, err := a.vault.Set(ctx, value)
+	if err != nil {
+		return "", err
+	}
+	if err = a.waitMined(ctx, tx); err != nil {
+		return "", err
+	}
+	mined, _, err := a.client.TransactionByHash(ctx, tx.Hash())
+	if err != nil {
+		return "", err
+	}
+	if bytes.Contains(mined.Data(), common.LeftPadBytes(value.Bytes(), 32)) {
+		return "", errors.New("value was sent in plain text")
+	}
+	return "stored in " + tx.Hash().Hex(), nil
+}
+
+// read reads the value back with a signed query, and checks that the vault
+// refuses to answer an anonymous one.
+func (a *app) read(ctx context.Context) (string, error) {
+	value, err := a.vault.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	if value.Cmp(big.NewInt(0x5ec2e7)) != 0 {
+		return "", fmt.Errorf("read %v, expected the value written", value)
+	}
+	if _, err = a.vault.Vault.Get(&bind.CallOpts{Context: ctx}); err == nil {
+		return "", errors.New("anonymous query was answered")
+	}
+	return "read back as owner", nil
+}
+
+// revert stores zero, which the vault refuses. The revert reason is
+// reported by a preflight call, and by diagnosing the transaction once it
+// failed on chain regardless.
+func (a *app) revert(ctx context.Context) (string, error) {
+	parsed, err := vault.VaultMetaData.GetAbi()
+	if err != nil {
+		return "", err
+	}
+	input, err := parsed.Pack("set", new(big.Int))
+	if err != nil {
+		return "", err
+	}
+	_, err = a.backend.CallContract(ctx, ethereum.CallMsg{From: a.from, To: &a.addr, Data: input}, nil)
+	var revert *sapphire.RevertError
+	if !errors.As(err, &revert) || revert.Reason != "zero value" {
+		return "", fmt.Errorf("expected the preflight call to revert, got %v", err)
+	}
+
+	// A fixed gas limit skips estimation, which would fail the same way.
+	opts := a.backend.Transactor(a.from)
+	opts.Context = ctx
+	opts.GasLimit = 100_000
+	tx, err := a.vault.Vault.Set(opts, new(big.Int))
+	if err != nil {
+		return "", err
+	}
+	if err = a.waitMined(ctx, tx); err == nil {
+		return "", errors.New("transaction storing zero succeeded")
+	}
+	d, err := a.backend.DiagnoseFailedTx(ctx, tx.Hash())
+	if err != nil {
+		return "", err
+	}
+	if d.Kind != client.FailureRevert || d.RevertReason != "zero value" {
+		return "", fmt.Errorf("failed transaction diagnosed as %s %q", d.Kind, d.RevertReason)
+	}
+	return fmt.Sprintf("reverted with %q", d.RevertReason), nil
+}
+
+// delegate delegates to the app's own consensus account with a subcall, and
+// waits for the consensus layer to process it.
+func (a *app) delegate(ctx context.Context) (string, error) {
+	opts := a.backend.Transactor(a.from)
+	opts.Context = ctx
+	// Receipts are kept per ID, so use a fresh one on every run.
+	id := uint64(time.Now().UnixNano())
+	tx, err := consensus.DelegateTx(opts, a.backend, consensus.EthAddress(a.from), Delegation, id)
+	if err != nil {
+		return "", err
+	}
+	if err = a.waitMined(ctx, tx); err != nil {
+		return "", err
+	}
+	for {
+		receipt, err := consensus.TakeReceipt(ctx, a.backend, a.from, consensus.DelegateReceipt, id)
+		if err == nil {
+			if receipt.Err != nil {
+				return "", receipt.Err
+			}
+			return fmt.Sprintf("received %v shares", receipt.Shares), nil
+		}
+		// The receipt is missing until the consensus layer processed the
+		// delegation.
+		if !errors.Is(err, sapphire.ErrCallFailed) {
+			return "", err
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("no receipt: %w", err)
+		case <-time.After(receiptPollInterval):
+		}
+	}
+}
+
+// gasless stores a value with a transaction the payer pays for, relayed as
+// a backend relaying its users' transactions would, and checks that the app
+// paid nothing.
+func (a *app) gasless(ctx context.Context) (string, error) {
+	network, ok := client.Networks[a.chainID.Uint64()]
+	if !ok {
+		return "", fmt.Errorf("unknown chain %#x", a.chainID)
+	}
+	chainContext, err := client.GaslessChainContext(network.RuntimeID, a.cfg.ChainContext)
+	if err != nil {
+		return "", err
+	}
+	relayer, err := client.NewGaslessRelayer(a.backend, a.cfg.Payer, chainContext, client.GaslessRelayerOptions{
+		Fee: func(_ common.Address, inner *types.Transaction) (client.GaslessFee, error) {
+			return client.GaslessFee{Amount: new(big.Int).Mul(new(big.Int).SetUint64(inner.Gas()), big.NewInt(sapphire.DefaultGasPrice))}, nil
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	parsed, err := vault.VaultMetaData.GetAbi()
+	if err != nil {
+		return "", err
+	}
+	data, err := parsed.Pack("set", big.NewInt(0x9a51e55))
+	if err != nil {
+		return "", err
+	}
+	nonce, err := a.backend.PendingNonceAt(ctx, a.from)
+	if err != nil {
+		return "", err
+	}
+	balance, err := a.backend.BalanceAt(ctx, a.from, nil)
+	if err != nil {
+		return "", err
+	}
+	inner, err := a.backend.PrepareTransaction(ctx, a.from, types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       &a.addr,
+		Gas:      100_000,
+		GasPrice: new(big.Int),
+		Data:     data,
+	}))
+	if err != nil {
+		return "", err
+	}
+	innerHash, outerHash, err := relayer.Relay(ctx, inner)
+	if err != nil {
+		return "", err
+	}
+	if _, err = a.backend.WaitGasless(ctx, outerHash); err != nil {
+		return "", err
+	}
+	after, err := a.backend.BalanceAt(ctx, a.from, nil)
+	if err != nil {
+		return "", err
+	}
+	if after.Cmp(balance) != 0 {
+		return "", fmt.Errorf("balance changed from %v to %v", balance, after)
+	}
+	value, err := a.vault.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	if value.Cmp(big.NewInt(0x9a51e55)) != 0 {
+		return "", fmt.Errorf("read %v, expected the value relayed", value)
+	}
+	return fmt.Sprintf("relayed %s with nonce %d", innerHash.Hex(), nonce), nil
+}
+
+// writeAfterGasless sends an encrypted transaction again, which must reuse
+// the nonce of the gasless one, as the runtime only saw its call.
+func (a *app) writeAfterGasless(ctx context.Context) (string, error) {
+	tx, err := a.vault.Set(ctx, big.NewInt(0x5ec2e7))
+	if err != nil {
+		return "", err
+	}
+	if err = a.waitMined(ctx, tx); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sent with nonce %d", tx.Nonce()), nil
+}
+
+// waitMined waits for tx to be mined and fails if it did not succeed.
+func (a *app) waitMined(ctx context.Context, tx *types.Transaction) error {
+	receipt, err := bind.WaitMined(ctx, a.backend, tx)
+	if err != nil {
+		return err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("transaction %s failed", tx.Hash().Hex())
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package exampleapp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	sdkTesting "github.com/oasisprotocol/oasis-sdk/client-sdk/go/testing"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+// TestExampleAppLocalnet runs the app and logs the result of each stage as a
+// line of JSON. The gasless stages need the consensus chain context of the
+// localnet, and are skipped without SAPPHIRE_CONSENSUS_CHAIN_CONTEXT.
+func TestExampleAppLocalnet(t *testing.T) {
+	localnet := testenv.Start(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	// The app has an account of its own, so that it doesn't compete for
+	// nonces with the tests of other packages running meanwhile.
+	results := Run(ctx, Config{
+		Gateway:      localnet.Gateway,
+		Key:          localnet.Accounts[1].Key,
+		Payer:        sdkTesting.Alice.Signer,
+		ChainContext: os.Getenv("SAPPHIRE_CONSENSUS_CHAIN_CONTEXT"),
+	}, func(res Result) {
+		line, _ := json.Marshal(res)
+		t.Log(string(line))
+	})
+	for _, res := range results {
+		if res.Status == StatusFail {
+			t.Errorf("stage %s failed: %s", res.Stage, res.Detail)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// Package vault holds the bindings of the contract the example app deploys.
+//
+// Vault stores its deployer as owner. set(uint256) stores a value, reverting
+// with Error("zero value") for zero, and get() returns it to the owner and
+// reverts for anyone else, so that it must be called as a signed query. The
+// contract is hand-assembled rather than compiled, to keep solc out of the
+// build; its bytecode is in Vault.bin and dispatches on the calldata size.
+package vault
+
+//go:generate go run github.com/ethereum/go-ethereum/cmd/abigen@v1.14.3 --abi Vault.abi --bin Vault.bin --pkg vault --type Vault --out vault.go
+//go:generate go run github.com/oasisprotocol/sapphire-paratime/clients/go/bindgen/cmd/sapphire-bindgen -type Vault
@@ -0,0 +1,255 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package vault
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+	_ = abi.ConvertType
+)
+
+// VaultMetaData contains all meta data concerning the Vault contract.
+var VaultMetaData = &bind.MetaData{
+	ABI: "[{\"type\":\"constructor\",\"inputs\":[],\"stateMutability\":\"nonpayable\"},{\"type\":\"function\",\"name\":\"get\",\"inputs\":[],\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\"},{\"type\":\"function\",\"name\":\"set\",\"inputs\":[{\"name\":\"value\",\"type\":\"uint256\"}],\"outputs\":[],\"stateMutability\":\"nonpayable\"}]",
+	Bin: "0x33600055609d80600f6000396000f336602414602057336000541460145760006000fd5b60015460005260206000f35b600435806034576064603960003960646000fd5b6001550008c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000a7a65726f2076616c756500000000000000000000000000000000000000000000",
+}
+
+// VaultABI is the input ABI used to generate the binding from.
+// Deprecated: Use VaultMetaData.ABI instead.
+var VaultABI = VaultMetaData.ABI
+
+// VaultBin is the compiled bytecode used for deploying new contracts.
+// Deprecated: Use VaultMetaData.Bin instead.
+var VaultBin = VaultMetaData.Bin
+
+// DeployVault deploys a new Ethereum contract, binding an instance of Vault to it.
+func DeployVault(auth *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *types.Transaction, *Vault, error) {
+	parsed, err := VaultMetaData.GetAbi()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	if parsed == nil {
+		return common.Address{}, nil, nil, errors.New("GetABI returned nil")
+	}
+
+	address, tx, contract, err := bind.DeployContract(auth, *parsed, common.FromHex(VaultBin), backend)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &Vault{VaultCaller: VaultCaller{contract: contract}, VaultTransactor: VaultTransactor{contract: contract}, VaultFilterer: VaultFilterer{contract: contract}}, nil
+}
+
+// Vault is an auto generated Go binding around an Ethereum contract.
+type Vault struct {
+	VaultCaller     // Read-only binding to the contract
+	VaultTransactor // Write-only binding to the contract
+	VaultFilterer   // Log filterer for contract events
+}
+
+// VaultCaller is an auto generated read-only Go binding around an Ethereum contract.
+type VaultCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// VaultTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type VaultTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// VaultFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type VaultFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// VaultSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type VaultSession struct {
+	Contract     *Vault            // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// VaultCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type VaultCallerSession struct {
+	Contract *VaultCaller  // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts // Call options to use throughout this session
+}
+
+// VaultTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type VaultTransactorSession struct {
+	Contract     *VaultTransactor  // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// VaultRaw is an auto generated low-level Go binding around an Ethereum contract.
+type VaultRaw struct {
+	Contract *Vault // Generic contract binding to access the raw methods on
+}
+
+// VaultCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type VaultCallerRaw struct {
+	Contract *VaultCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// VaultTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type VaultTransactorRaw struct {
+	Contract *VaultTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewVault creates a new instance of Vault, bound to a specific deployed contract.
+func NewVault(address common.Address, backend bind.ContractBackend) (*Vault, error) {
+	contract, err := bindVault(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &Vault{VaultCaller: VaultCaller{contract: contract}, VaultTransactor: VaultTransactor{contract: contract}, VaultFilterer: VaultFilterer{contract: contract}}, nil
+}
+
+// NewVaultCaller creates a new read-only instance of Vault, bound to a specific deployed contract.
+func NewVaultCaller(address common.Address, caller bind.ContractCaller) (*VaultCaller, error) {
+	contract, err := bindVault(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &VaultCaller{contract: contract}, nil
+}
+
+// NewVaultTransactor creates a new write-only instance of Vault, bound to a specific deployed contract.
+func NewVaultTransactor(address common.Address, transactor bind.ContractTransactor) (*VaultTransactor, error) {
+	contract, err := bindVault(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &VaultTransactor{contract: contract}, nil
+}
+
+// NewVaultFilterer creates a new log filterer instance of Vault, bound to a specific deployed contract.
+func NewVaultFilterer(address common.Address, filterer bind.ContractFilterer) (*VaultFilterer, error) {
+	contract, err := bindVault(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &VaultFilterer{contract: contract}, nil
+}
+
+// bindVault binds a generic wrapper to an already deployed contract.
+func bindVault(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := VaultMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, *parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Vault *VaultRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _Vault.Contract.VaultCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Vault *VaultRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Vault.Contract.VaultTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Vault *VaultRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Vault.Contract.VaultTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Vault *VaultCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _Vault.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Vault *VaultTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Vault.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Vault *VaultTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Vault.Contract.contract.Transact(opts, method, params...)
+}
+
+// Get is a free data retrieval call binding the contract method 0x6d4ce63c.
+//
+// Solidity: function get() view returns(uint256)
+func (_Vault *VaultCaller) Get(opts *bind.CallOpts) (*big.Int, error) {
+	var out []interface{}
+	err := _Vault.contract.Call(opts, &out, "get")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// Get is a free data retrieval call binding the contract method 0x6d4ce63c.
+//
+// Solidity: function get() view returns(uint256)
+func (_Vault *VaultSession) Get() (*big.Int, error) {
+	return _Vault.Contract.Get(&_Vault.CallOpts)
+}
+
+// Get is a free data retrieval call binding the contract method 0x6d4ce63c.
+//
+// Solidity: function get() view returns(uint256)
+func (_Vault *VaultCallerSession) Get() (*big.Int, error) {
+	return _Vault.Contract.Get(&_Vault.CallOpts)
+}
+
+// Set is a paid mutator transaction binding the contract method 0x60fe47b1.
+//
+// Solidity: function set(uint256 value) returns()
+func (_Vault *VaultTransactor) Set(opts *bind.TransactOpts, value *big.Int) (*types.Transaction, error) {
+	return _Vault.contract.Transact(opts, "set", value)
+}
+
+// Set is a paid mutator transaction binding the contract method 0x60fe47b1.
+//
+// Solidity: function set(uint256 value) returns()
+func (_Vault *VaultSession) Set(value *big.Int) (*types.Transaction, error) {
+	return _Vault.Contract.Set(&_Vault.TransactOpts, value)
+}
+
+// Set is a paid mutator transaction binding the contract method 0x60fe47b1.
+//
+// Solidity: function set(uint256 value) returns()
+func (_Vault *VaultTransactorSession) Set(value *big.Int) (*types.Transaction, error) {
+	return _Vault.Contract.Set(&_Vault.TransactOpts, value)
+}
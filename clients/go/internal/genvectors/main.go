@@ -0,0 +1,294 @@
+// Command genvectors generates the compatibility vectors of
+// testdata/compat_vectors.json from a fixed seed, for the clients in other
+// languages to check themselves against:
+//
+//	go run ./internal/genvectors > testdata/compat_vectors.json
+//
+// For every signed query the vectors hold the signer's key, the call, its
+// leash, EIP-712 digest and signature, the caller's ephemeral keypair and
+// the encrypted envelope as sent to the gateway, also in the JSON encoding of
+// the TypeScript client, and the runtime's encrypted result with its
+// plaintext. Keys and nonces are drawn from math/rand seeded
+// with -seed, so the output only changes with the encoding.
+//
+// The schema is versioned by the version field, which is bumped whenever a
+// field changes meaning.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"math/rand"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// DefaultSeed is the seed of the committed vectors.
+const DefaultSeed = 1
+
+// schemaVersion is the version of the vectors' schema.
+const schemaVersion = 1
+
+// Vectors is the generated file.
+type Vectors struct {
+	Version int     `json:"version"`
+	Seed    int64   `json:"seed"`
+	Runtime Runtime `json:"runtime"`
+	Queries []Query `json:"queries"`
+}
+
+// Runtime is the runtime's calldata keypair.
+type Runtime struct {
+	PublicKey hexutil.Bytes `json:"publicKey"`
+	SecretKey hexutil.Bytes `json:"secretKey"`
+	Epoch     uint64        `json:"epoch"`
+}
+
+// Leash is the leash of a signed query.
+type Leash struct {
+	Nonce       uint64      `json:"nonce"`
+	BlockNumber uint64      `json:"blockNumber"`
+	BlockHash   common.Hash `json:"blockHash"`
+	BlockRange  uint64      `json:"blockRange"`
+}
+
+// Query is a signed, encrypted query and its encrypted result.
+type Query struct {
+	Name string `json:"name"`
+
+	// SignerKey is the secp256k1 key of From.
+	SignerKey hexutil.Bytes   `json:"signerKey"`
+	ChainID   uint64          `json:"chainId"`
+	From      common.Address  `json:"from"`
+	To        *common.Address `json:"to"`
+	GasLimit  uint64          `json:"gasLimit"`
+	GasPrice  *hexutil.Big    `json:"gasPrice"`
+	Value     *hexutil.Big    `json:"value"`
+	Data      hexutil.Bytes   `json:"data"`
+	Leash     Leash           `json:"leash"`
+	// Digest is the EIP-712 digest of the query in the default domain, and
+	// Signature the signer's over it, with V of 27 or 28.
+	Digest    common.Hash   `json:"digest"`
+	Signature hexutil.Bytes `json:"signature"`
+
+	// CallerPublicKey and CallerSecretKey are the caller's ephemeral X25519
+	// keypair.
+	CallerPublicKey hexutil.Bytes `json:"callerPublicKey"`
+	CallerSecretKey hexutil.Bytes `json:"callerSecretKey"`
+	// CallNonce is the Deoxys-II nonce Data is encrypted with into
+	// CallEnvelope, the CBOR-encoded call envelope. Envelope is the
+	// CBOR-encoded signed query wrapping it, the calldata of the eth_call.
+	CallNonce    hexutil.Bytes `json:"callNonce"`
+	CallEnvelope hexutil.Bytes `json:"callEnvelope"`
+	Envelope     hexutil.Bytes `json:"envelope"`
+	// EnvelopeJSON is the signed query of Envelope in the JSON encoding of
+	// sapphire.DataPackJSON.
+	EnvelopeJSON json.RawMessage `json:"envelopeJson"`
+
+	// Result is the plaintext the call returned, encrypted with ResultNonce
+	// into ResultEnvelope, the CBOR-encoded call result of the eth_call.
+	Result         hexutil.Bytes `json:"result"`
+	ResultNonce    hexutil.Bytes `json:"resultNonce"`
+	ResultEnvelope hexutil.Bytes `json:"resultEnvelope"`
+}
+
+// query describes a generated query. Unset fields are drawn at random.
+type query struct {
+	name       string
+	chainID    uint64
+	deployment bool
+	value      *big.Int
+	dataSize   int
+	resultSize int
+	leash      *evm.Leash
+}
+
+var queries = []query{
+	{name: "call", chainID: 0x5afd, dataSize: 36, resultSize: 32},
+	{name: "deployment", chainID: 0x5afe, deployment: true, dataSize: 256, resultSize: 64},
+	{name: "value", chainID: 0x5afd, value: big.NewInt(1e18), dataSize: 4, resultSize: 32},
+	{name: "zero value", chainID: 0x5aff, value: new(big.Int), dataSize: 68, resultSize: 96},
+	{name: "empty result", chainID: 0x5afd, dataSize: 4},
+	{name: "large data", chainID: 0x5afd, dataSize: 4096, resultSize: 1024},
+	{name: "max leash", chainID: 0x5afd, dataSize: 36, resultSize: 32, leash: &evm.Leash{
+		Nonce:       math.MaxUint64,
+		BlockNumber: math.MaxUint64,
+		BlockHash:   bytes.Repeat([]byte{0xff}, 32),
+		BlockRange:  math.MaxUint64,
+	}},
+}
+
+func main() {
+	seed := flag.Int64("seed", DefaultSeed, "seed of the keys and nonces")
+	flag.Parse()
+
+	out, err := Generate(*seed)
+	if err == nil {
+		_, err = os.Stdout.Write(out)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Generate returns the vectors for seed, encoded as the committed file is.
+func Generate(seed int64) ([]byte, error) {
+	rng := rand.New(rand.NewSource(seed)) //nolint:gosec
+
+	runtimePublic, runtimeSecret, err := x25519.GenerateKey(rng)
+	if err != nil {
+		return nil, err
+	}
+	vectors := Vectors{
+		Version: schemaVersion,
+		Seed:    seed,
+		Runtime: Runtime{
+			PublicKey: runtimePublic[:],
+			SecretKey: runtimeSecret[:],
+			Epoch:     rng.Uint64() % 1_000_000,
+		},
+	}
+	runtimeKeyPair := &sapphire.Curve25519KeyPair{PublicKey: *runtimePublic, SecretKey: *runtimeSecret}
+	for _, q := range queries {
+		v, err := generateQuery(rng, runtimeKeyPair, vectors.Runtime.Epoch, q)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", q.name, err)
+		}
+		vectors.Queries = append(vectors.Queries, *v)
+	}
+
+	out, err := json.MarshalIndent(vectors, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func generateQuery(rng *rand.Rand, runtime *sapphire.Curve25519KeyPair, epoch uint64, q query) (*Query, error) {
+	keyBytes := randomBytes(rng, 32)
+	key, err := crypto.ToECDSA(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	signer := sapphire.NewPrivateKeySigner(key)
+
+	var to *common.Address
+	if !q.deployment {
+		addr := common.BytesToAddress(randomBytes(rng, common.AddressLength))
+		to = &addr
+	}
+	value := q.value
+	if value == nil {
+		value = new(big.Int).SetBytes(randomBytes(rng, 8))
+	}
+	gasLimit := 21_000 + rng.Uint64()%30_000_000
+	gasPrice := new(big.Int).SetUint64(1 + rng.Uint64()%(1<<40))
+	data := randomBytes(rng, q.dataSize)
+	leash := q.leash
+	if leash == nil {
+		leash = &evm.Leash{
+			Nonce:       rng.Uint64() % 1_000_000,
+			BlockNumber: rng.Uint64() % 100_000_000,
+			BlockHash:   randomBytes(rng, 32),
+			BlockRange:  sapphire.DefaultBlockRange,
+		}
+	}
+	chainID := new(big.Int).SetUint64(q.chainID)
+
+	digest, err := sapphire.SignedCallDigest(sapphire.SignableCall(q.chainID, signer.Address(), to, gasLimit, gasPrice, value, data, *leash))
+	if err != nil {
+		return nil, err
+	}
+	pack, err := sapphire.NewSignedCall(context.Background(), signer, chainID, to, data,
+		sapphire.WithLeash(*leash),
+		sapphire.WithGasLimit(gasLimit),
+		sapphire.WithGasPrice(gasPrice),
+		sapphire.WithValue(value),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	callerPublic, callerSecret, err := x25519.GenerateKey(rng)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := sapphire.NewX25519DeoxysIICipherWithRand(&sapphire.Curve25519KeyPair{PublicKey: *callerPublic, SecretKey: *callerSecret}, &runtime.PublicKey, epoch, rng)
+	if err != nil {
+		return nil, err
+	}
+	envelope := caller.EncryptEnvelope(data)
+	var encrypted types.CallEnvelopeX25519DeoxysII
+	if err = cbor.Unmarshal(envelope.Body, &encrypted); err != nil {
+		return nil, err
+	}
+	pack.Data = *envelope
+	packJSON, err := json.Marshal((*sapphire.DataPackJSON)(pack))
+	if err != nil {
+		return nil, err
+	}
+
+	// The runtime seals the result with the same shared key.
+	result := randomBytes(rng, q.resultSize)
+	sealer, err := sapphire.NewX25519DeoxysIICipherWithRand(runtime, callerPublic, epoch, rng)
+	if err != nil {
+		return nil, err
+	}
+	sealed, resultNonce := sealer.Encrypt(cbor.Marshal(types.CallResult{Ok: cbor.Marshal(result)}))
+	resultEnvelope := types.ResultEnvelopeX25519DeoxysII{Data: sealed}
+	copy(resultEnvelope.Nonce[:], resultNonce)
+
+	return &Query{
+		Name:      q.name,
+		SignerKey: keyBytes,
+		ChainID:   q.chainID,
+		From:      signer.Address(),
+		To:        to,
+		GasLimit:  gasLimit,
+		GasPrice:  (*hexutil.Big)(gasPrice),
+		Value:     (*hexutil.Big)(value),
+		Data:      data,
+		Leash: Leash{
+			Nonce:       leash.Nonce,
+			BlockNumber: leash.BlockNumber,
+			BlockHash:   common.BytesToHash(leash.BlockHash),
+			BlockRange:  leash.BlockRange,
+		},
+		Digest:          digest,
+		Signature:       pack.Signature,
+		CallerPublicKey: callerPublic[:],
+		CallerSecretKey: callerSecret[:],
+		CallNonce:       encrypted.Nonce[:],
+		CallEnvelope:    cbor.Marshal(envelope),
+		Envelope:        cbor.Marshal(pack),
+		EnvelopeJSON:    packJSON,
+		Result:          result,
+		ResultNonce:     resultNonce,
+		ResultEnvelope:  cbor.Marshal(types.CallResult{Unknown: cbor.Marshal(resultEnvelope)}),
+	}, nil
+}
+
+// randomBytes returns n bytes drawn from rng.
+func randomBytes(rng io.Reader, n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rng, b); err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestVectorsUpToDate fails on any change to the committed vectors, which
+// the other clients are tested against. Changes must be deliberate: if the
+// encoding was meant to change, regenerate them with
+//
+//	go run ./internal/genvectors > testdata/compat_vectors.json
+func TestVectorsUpToDate(t *testing.T) {
+	committed, err := os.ReadFile(filepath.Join("..", "..", "testdata", "compat_vectors.json"))
+	if err != nil {
+		t.Fatalf("failed to read vectors: %v", err)
+	}
+	generated, err := Generate(DefaultSeed)
+	if err != nil {
+		t.Fatalf("failed to generate vectors: %v", err)
+	}
+	if bytes.Equal(generated, committed) {
+		return
+	}
+	committedLines, generatedLines := bytes.Split(committed, []byte("\n")), bytes.Split(generated, []byte("\n"))
+	for i := range generatedLines {
+		if i >= len(committedLines) || !bytes.Equal(generatedLines[i], committedLines[i]) {
+			var was []byte
+			if i < len(committedLines) {
+				was = committedLines[i]
+			}
+			t.Fatalf("generated vectors differ from testdata/compat_vectors.json at line %d:\n  committed: %s\n  generated: %s\nThis breaks compatibility with the other clients. If intended, regenerate the vectors and bump the schema version if fields changed meaning.", i+1, was, generatedLines[i])
+		}
+	}
+	t.Fatalf("generated vectors are a prefix of testdata/compat_vectors.json, %d bytes shorter", len(committed)-len(generated))
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	a, err := Generate(7)
+	if err != nil {
+		t.Fatalf("failed to generate vectors: %v", err)
+	}
+	b, _ := Generate(7)
+	c, _ := Generate(8)
+	if !bytes.Equal(a, b) {
+		t.Fatalf("vectors differ between runs with the same seed")
+	}
+	if bytes.Equal(a, c) {
+		t.Fatalf("vectors do not depend on the seed")
+	}
+}
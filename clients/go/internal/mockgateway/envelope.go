@@ -0,0 +1,144 @@
+package mockgateway
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/oasisprotocol/deoxysii"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles/local"
+)
+
+// Call is a call or transaction received by the gateway, decrypted.
+type Call struct {
+	// Method is eth_call, eth_estimateGas or eth_sendRawTransaction, or the
+	// runtime method of calls made through RuntimeClient.
+	Method string
+	// From is the sender: the from field of queries, the signer of
+	// transactions.
+	From common.Address
+	// To is the callee, nil for deployments.
+	To *common.Address
+	// Enveloped is false for calldata sent as is, without a call envelope.
+	Enveloped bool
+	// Format is the call format of the envelope.
+	Format types.CallFormat
+	// Epoch is the runtime key epoch encrypted calls are for.
+	Epoch uint64
+	// PublicKey is the caller's ephemeral key of encrypted calls.
+	PublicKey []byte
+	// Leash and Signature are set for signed queries. The signature is not
+	// verified.
+	Leash     *evm.Leash
+	Signature []byte
+	// Data is the plaintext calldata.
+	Data []byte
+	// Envelope is the calldata as received.
+	Envelope []byte
+	// Tx is the transaction for eth_sendRawTransaction and MethodSubmitTx.
+	Tx *ethtypes.Transaction
+}
+
+// sealer encodes call results the way the runtime does for the call they
+// answer.
+type sealer struct {
+	enveloped bool
+	aead      cipher.AEAD
+}
+
+// open decodes calldata, decrypting it with the runtime key if encrypted.
+func (g *Gateway) open(data []byte) (Call, *sealer, error) {
+	call := Call{Envelope: common.CopyBytes(data), Data: common.CopyBytes(data)}
+	if len(data) == 0 {
+		return call, &sealer{}, nil
+	}
+
+	var envelope types.Call
+	var pack evm.SignedCallDataPack
+	switch {
+	case cbor.Unmarshal(data, &pack) == nil && len(pack.Signature) > 0:
+		leash := pack.Leash
+		call.Leash, call.Signature = &leash, pack.Signature
+		envelope = pack.Data
+	case cbor.Unmarshal(data, &envelope) == nil && envelope.Body != nil:
+	default:
+		// Calldata that is not an envelope is sent as is.
+		return call, &sealer{}, nil
+	}
+	call.Enveloped, call.Format = true, envelope.Format
+
+	switch envelope.Format {
+	case types.CallFormatPlain:
+		if err := cbor.Unmarshal(envelope.Body, &call.Data); err != nil {
+			return call, nil, fmt.Errorf("mockgateway: malformed plain call body: %w", err)
+		}
+		return call, &sealer{enveloped: true}, nil
+	case types.CallFormatEncryptedX25519DeoxysII:
+	default:
+		return call, nil, fmt.Errorf("mockgateway: unsupported call format %d", envelope.Format)
+	}
+
+	var encrypted types.CallEnvelopeX25519DeoxysII
+	if err := cbor.Unmarshal(envelope.Body, &encrypted); err != nil {
+		return call, nil, fmt.Errorf("mockgateway: malformed encrypted call body: %w", err)
+	}
+	call.Epoch, call.PublicKey = encrypted.Epoch, common.CopyBytes(encrypted.Pk[:])
+	secret, ok := g.oldKeys[encrypted.Epoch]
+	if encrypted.Epoch == g.epoch {
+		secret, ok = g.secretKey, true
+	}
+	if !ok {
+		return call, nil, fmt.Errorf("mockgateway: call for unknown epoch %d", encrypted.Epoch)
+	}
+	key := local.X25519Derive(encrypted.Pk, secret)
+	aead, err := deoxysii.New(key[:])
+	if err != nil {
+		return call, nil, err
+	}
+	plaintext, err := aead.Open(nil, encrypted.Nonce[:], encrypted.Data, nil)
+	if err != nil {
+		return call, nil, errors.New("mockgateway: failed to decrypt call")
+	}
+	var inner types.Call
+	if err = cbor.Unmarshal(plaintext, &inner); err != nil {
+		return call, nil, fmt.Errorf("mockgateway: malformed decrypted call: %w", err)
+	}
+	if err = cbor.Unmarshal(inner.Body, &call.Data); err != nil {
+		return call, nil, fmt.Errorf("mockgateway: malformed decrypted call body: %w", err)
+	}
+	return call, &sealer{enveloped: true, aead: aead}, nil
+}
+
+// seal encodes the result of a call that returned out or failed. Failures
+// of calls without an envelope are not sealed but JSON-RPC errors.
+func (s *sealer) seal(out []byte, failure *Failure) []byte {
+	if !s.enveloped {
+		return out
+	}
+	result := types.CallResult{Ok: cbor.Marshal(out)}
+	if failure != nil {
+		result = types.CallResult{Failed: &types.FailedCallResult{
+			Module:  failure.Module,
+			Code:    failure.Code,
+			Message: failure.Message,
+		}}
+	}
+	if s.aead == nil {
+		return cbor.Marshal(result)
+	}
+	var nonce [deoxysii.NonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand is unavailable: %v", err))
+	}
+	return cbor.Marshal(types.CallResult{Unknown: cbor.Marshal(types.ResultEnvelopeX25519DeoxysII{
+		Nonce: nonce,
+		Data:  s.aead.Seal(nil, nonce[:], cbor.Marshal(result), nil),
+	})})
+}
@@ -0,0 +1,75 @@
+package mockgateway
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+
+	"github.com/oasisprotocol/curve25519-voi/primitives/ed25519"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	oasisCommon "github.com/oasisprotocol/oasis-core/go/common"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+// DefaultRuntimeID is the runtime ID the key manager signs keys for unless
+// WithRuntimeID is given, the one of sapphire-localnet.
+const DefaultRuntimeID = "0x8000000000000000000000000000000000000000000000000000000000000000"
+
+// WithRuntimeID sets the runtime ID the key manager signs keys for.
+func WithRuntimeID(id oasisCommon.Namespace) Option {
+	return func(g *Gateway) {
+		g.runtimeID = id
+	}
+}
+
+// keyManager signs runtime keys as the runtime's key manager does.
+type keyManager struct {
+	key      ed25519.PrivateKey
+	checksum []byte
+}
+
+func newKeyManager() (*keyManager, error) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	checksum := make([]byte, 32)
+	if _, err = rand.Read(checksum); err != nil {
+		return nil, err
+	}
+	return &keyManager{key: key, checksum: checksum}, nil
+}
+
+// sign returns the signed key of the given epoch, made over
+// SHA-512/256(context || key || checksum || runtime ID || key pair ID || epoch)
+// as oasis-core's keymanager::crypto::SignedPublicKey is.
+func (km *keyManager) sign(runtimeID oasisCommon.Namespace, key x25519.PublicKey, epoch uint64) types.SignedPublicKey {
+	var epochBytes [8]byte
+	binary.BigEndian.PutUint64(epochBytes[:], epoch)
+	keyPairID := sha512.Sum512_256(append([]byte("oasis-runtime-sdk/private: tx"), epochBytes[:]...))
+
+	h := sha512.New512_256()
+	for _, part := range [][]byte{[]byte("oasis-core/keymanager: pk signature"), key[:], km.checksum, runtimeID[:], keyPairID[:]} {
+		_, _ = h.Write(part)
+	}
+	binary.LittleEndian.PutUint64(epochBytes[:], epoch)
+	_, _ = h.Write(epochBytes[:])
+
+	signed := types.SignedPublicKey{PublicKey: key, Checksum: append([]byte(nil), km.checksum...)}
+	copy(signed.Signature[:], ed25519.Sign(km.key, h.Sum(nil)))
+	return signed
+}
+
+// KeyManagerKey returns the signing key of the key manager signing the
+// runtime keys.
+func (g *Gateway) KeyManagerKey() signature.PublicKey {
+	var pk signature.PublicKey
+	copy(pk[:], g.keyManager.key.Public().(ed25519.PublicKey))
+	return pk
+}
+
+// signedKey returns the current runtime key, signed. g.mu must be held.
+func (g *Gateway) signedKey() types.SignedPublicKey {
+	return g.keyManager.sign(g.runtimeID, g.publicKey, g.epoch)
+}
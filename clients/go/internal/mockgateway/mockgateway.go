@@ -0,0 +1,546 @@
+// Package mockgateway is an in-process Sapphire Web3 gateway for unit tests.
+//
+// It serves the JSON-RPC methods the client uses with a mock runtime key,
+// decrypts the calls and transactions it receives and records their
+// plaintexts, so that tests can check what the client sent:
+//
+//	gw := mockgateway.New(t)
+//	backend, _ := client.WrapClient(gw.Dial(t), sign)
+//	...
+//	calls := gw.Calls("eth_call")
+//
+// Failures are scripted per method with Fail, e.g. to test retries.
+// RuntimeClient serves the same mock runtime as an oasis-node does over gRPC.
+package mockgateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	oasisCommon "github.com/oasisprotocol/oasis-core/go/common"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/client"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+)
+
+const (
+	// DefaultChainID is the chain ID served unless WithChainID is given, the
+	// one of sapphire-localnet.
+	DefaultChainID = 0x5afd
+	// DefaultEpoch is the epoch of the runtime key unless WithEpoch is given.
+	DefaultEpoch = 42
+	// DefaultGas is what eth_estimateGas returns unless set with SetGas.
+	DefaultGas = 21_000
+	// DefaultGasPrice is what eth_gasPrice returns.
+	DefaultGasPrice = 100_000_000_000
+)
+
+// ErrDropConnection makes a scripted request close the connection without
+// answering.
+var ErrDropConnection = errors.New("drop connection")
+
+// Error is a JSON-RPC error object a scripted request fails with.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// StatusError makes a scripted request fail with its HTTP status.
+type StatusError int
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("HTTP %d", int(e))
+}
+
+// Failure is a failed runtime call. Returned by a CallHandler, it is sent
+// back as the failed call result, encrypted if the call was.
+type Failure struct {
+	Module  string
+	Code    uint32
+	Message string
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("call failed in module %s with code %d: %s", f.Module, f.Code, f.Message)
+}
+
+// Handler answers a JSON-RPC method call.
+type Handler func(params []json.RawMessage) (interface{}, error)
+
+// CallHandler computes the output of an eth_call or eth_estimateGas from its
+// decrypted call.
+type CallHandler func(call Call) ([]byte, error)
+
+// Request is a JSON-RPC request received by the gateway.
+type Request struct {
+	Method string
+	Params []json.RawMessage
+	Header http.Header
+	// Round is the round of runtime client requests.
+	Round uint64
+	Time  time.Time
+}
+
+// Option configures a Gateway.
+type Option func(*Gateway)
+
+// WithKeyPair sets the runtime keypair, random by default.
+func WithKeyPair(public x25519.PublicKey, secret x25519.PrivateKey) Option {
+	return func(g *Gateway) {
+		g.publicKey, g.secretKey = public, secret
+	}
+}
+
+// WithEpoch sets the epoch of the runtime key.
+func WithEpoch(epoch uint64) Option {
+	return func(g *Gateway) {
+		g.epoch = epoch
+	}
+}
+
+// WithChainID sets the chain ID.
+func WithChainID(chainID uint64) Option {
+	return func(g *Gateway) {
+		g.chainID = new(big.Int).SetUint64(chainID)
+	}
+}
+
+// Gateway is an in-process Sapphire Web3 gateway.
+type Gateway struct {
+	// URL is the gateway's JSON-RPC endpoint.
+	URL string
+
+	server  *httptest.Server
+	chainID *big.Int
+	// runtimeID and keyManager sign the runtime keys.
+	runtimeID  oasisCommon.Namespace
+	keyManager *keyManager
+
+	mu        sync.Mutex
+	publicKey x25519.PublicKey
+	secretKey x25519.PrivateKey
+	epoch     uint64
+	// oldKeys are the secret keys of past epochs, which calls may still be
+	// encrypted to.
+	oldKeys map[uint64]x25519.PrivateKey
+	delays  map[string]time.Duration
+	head    *types.Header
+	// chain are the headers served by number, if set with SetChain.
+	chain  map[uint64]*types.Header
+	nonces map[common.Address]uint64
+	// sent are the hashes of the transactions accepted.
+	sent map[common.Hash]bool
+	// included are the transactions submitted to the runtime client by
+	// round, and logs the logs they emit.
+	included map[uint64][]*client.TransactionWithResults
+	logs     []*evm.Event
+	gas      uint64
+	onCall   CallHandler
+	handlers map[string]Handler
+	failures map[string][]error
+	requests []Request
+	calls    []Call
+}
+
+// New starts a gateway that is stopped when the test ends.
+func New(t testing.TB, opts ...Option) *Gateway {
+	t.Helper()
+	g := &Gateway{
+		epoch:   DefaultEpoch,
+		chainID: big.NewInt(DefaultChainID),
+		head: &types.Header{
+			Number:     big.NewInt(100),
+			ParentHash: common.HexToHash("2ec361fee28d09a3ad2c4d5f7f95d409ce2b68c39b5d647edf0ea651e069e4a8"),
+			Difficulty: big.NewInt(0),
+		},
+		nonces:   make(map[common.Address]uint64),
+		sent:     make(map[common.Hash]bool),
+		included: make(map[uint64][]*client.TransactionWithResults),
+		gas:      DefaultGas,
+		oldKeys:  make(map[uint64]x25519.PrivateKey),
+		delays:   make(map[string]time.Duration),
+		handlers: make(map[string]Handler),
+		failures: make(map[string][]error),
+	}
+	public, secret, err := x25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate runtime key: %v", err)
+	}
+	g.publicKey, g.secretKey = *public, *secret
+	if g.keyManager, err = newKeyManager(); err != nil {
+		t.Fatalf("failed to generate key manager key: %v", err)
+	}
+	_ = g.runtimeID.UnmarshalBinary(hexutil.MustDecode(DefaultRuntimeID))
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.server = httptest.NewServer(g)
+	g.URL = g.server.URL
+	t.Cleanup(g.server.Close)
+	return g
+}
+
+// Dial connects to the gateway. The connection is closed when the test ends.
+func (g *Gateway) Dial(t testing.TB) *ethclient.Client {
+	t.Helper()
+	client, err := ethclient.Dial(g.URL)
+	if err != nil {
+		t.Fatalf("failed to dial mock gateway: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// PublicKey returns the runtime public key.
+func (g *Gateway) PublicKey() x25519.PublicKey {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.publicKey
+}
+
+// RotateKey replaces the runtime key with a random one of the next epoch, as
+// the runtime does every epoch, and returns the epoch. Calls encrypted to
+// past keys are still accepted.
+func (g *Gateway) RotateKey() uint64 {
+	public, secret, err := x25519.GenerateKey(nil)
+	if err != nil {
+		panic(fmt.Sprintf("mockgateway: failed to generate runtime key: %v", err))
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.oldKeys[g.epoch] = g.secretKey
+	g.publicKey, g.secretKey = *public, *secret
+	g.epoch++
+	return g.epoch
+}
+
+// ChainID returns the chain ID.
+func (g *Gateway) ChainID() *big.Int {
+	return new(big.Int).Set(g.chainID)
+}
+
+// Fail makes the next requests for method fail with errs in turn. A nil error
+// serves the request as usual. Requests fail with an *Error or StatusError as
+// given, close the connection for ErrDropConnection, and fail with a
+// JSON-RPC error with the message of other errors.
+func (g *Gateway) Fail(method string, errs ...error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures[method] = append(g.failures[method], errs...)
+}
+
+// Delay makes requests for method take d to be served, or until the client
+// gives up.
+func (g *Gateway) Delay(method string, d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.delays[method] = d
+}
+
+// Handle serves method with h instead of the built-in handler. A nil handler
+// restores it.
+func (g *Gateway) Handle(method string, h Handler) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if h == nil {
+		delete(g.handlers, method)
+		return
+	}
+	g.handlers[method] = h
+}
+
+// OnCall computes the output of eth_call with h. By default calls return no
+// output. h is called with the gateway locked and must not call its methods.
+func (g *Gateway) OnCall(h CallHandler) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.onCall = h
+}
+
+// SetGas sets what eth_estimateGas returns.
+func (g *Gateway) SetGas(gas uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gas = gas
+}
+
+// SetNonce sets the nonce of account.
+func (g *Gateway) SetNonce(account common.Address, nonce uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.nonces[account] = nonce
+}
+
+// SetHead sets the latest block.
+func (g *Gateway) SetHead(head *types.Header) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.head = head
+}
+
+// SetChain makes the gateway serve headers by number, the last one as the
+// head. Blocks missing from headers are not found.
+func (g *Gateway) SetChain(headers ...*types.Header) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.chain = make(map[uint64]*types.Header, len(headers))
+	for _, h := range headers {
+		g.chain[h.Number.Uint64()] = h
+	}
+	g.head = headers[len(headers)-1]
+}
+
+// Requests returns the requests received for method, or all requests if
+// method is empty.
+func (g *Gateway) Requests(method string) []Request {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var out []Request
+	for _, r := range g.requests {
+		if method == "" || r.Method == method {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Calls returns the decrypted calls received with method, eth_call,
+// eth_estimateGas or eth_sendRawTransaction, or all of them if method is
+// empty.
+func (g *Gateway) Calls(method string) []Call {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var out []Call
+	for _, c := range g.calls {
+		if method == "" || c.Method == method {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var msg struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &msg)
+	}
+	if err != nil {
+		http.Error(w, "mockgateway: batch requests are not supported", http.StatusBadRequest)
+		return
+	}
+
+	g.mu.Lock()
+	g.requests = append(g.requests, Request{
+		Method: msg.Method,
+		Params: msg.Params,
+		Header: r.Header.Clone(),
+		Time:   time.Now(),
+	})
+	var failure error
+	if queue := g.failures[msg.Method]; len(queue) > 0 {
+		failure, g.failures[msg.Method] = queue[0], queue[1:]
+	}
+	h, ok := g.handlers[msg.Method]
+	delay := g.delays[msg.Method]
+	g.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+
+	var status StatusError
+	switch {
+	case errors.Is(failure, ErrDropConnection):
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	case errors.As(failure, &status):
+		w.WriteHeader(int(status))
+		return
+	}
+
+	var result interface{}
+	switch {
+	case failure != nil:
+		err = failure
+	case ok:
+		result, err = h(msg.Params)
+	default:
+		result, err = g.serve(msg.Method, msg.Params)
+	}
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID}
+	var rpcErr *Error
+	switch {
+	case errors.As(err, &rpcErr):
+		resp["error"] = rpcErr
+	case err != nil:
+		resp["error"] = &Error{Code: -32000, Message: err.Error()}
+	default:
+		resp["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// serve answers the methods the gateway implements.
+func (g *Gateway) serve(method string, params []json.RawMessage) (interface{}, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch method {
+	case "eth_chainId":
+		return (*hexutil.Big)(g.chainID), nil
+	case "oasis_callDataPublicKey":
+		signed := g.signedKey()
+		return map[string]interface{}{
+			"key":       hexutil.Bytes(signed.PublicKey[:]),
+			"checksum":  hexutil.Bytes(signed.Checksum),
+			"signature": hexutil.Bytes(signed.Signature[:]),
+			"epoch":     g.epoch,
+		}, nil
+	case "eth_blockNumber":
+		return (*hexutil.Big)(g.head.Number), nil
+	case "eth_getBlockByNumber":
+		var number string
+		if err := param(params, 0, &number); err != nil {
+			return nil, err
+		}
+		n, err := hexutil.DecodeUint64(number)
+		if g.chain == nil || err != nil {
+			// Tags such as latest.
+			return g.head, nil
+		}
+		if h, ok := g.chain[n]; ok {
+			return h, nil
+		}
+		return nil, nil
+	case "eth_getTransactionCount":
+		var account common.Address
+		if err := param(params, 0, &account); err != nil {
+			return nil, err
+		}
+		return hexutil.Uint64(g.nonces[account]), nil
+	case "eth_gasPrice":
+		return (*hexutil.Big)(big.NewInt(DefaultGasPrice)), nil
+	case "eth_getCode":
+		return hexutil.Bytes{0x60, 0x80}, nil
+	case "eth_call", "eth_estimateGas":
+		return g.call(method, params)
+	case "eth_sendRawTransaction":
+		return g.sendRawTransaction(params)
+	}
+	return nil, &Error{Code: -32601, Message: fmt.Sprintf("the method %s does not exist/is not available", method)}
+}
+
+// call decrypts and records a call, and returns the encrypted output of
+// onCall for eth_call and the gas for eth_estimateGas.
+func (g *Gateway) call(method string, params []json.RawMessage) (interface{}, error) {
+	var args struct {
+		From  common.Address  `json:"from"`
+		To    *common.Address `json:"to"`
+		Input hexutil.Bytes   `json:"input"`
+		Data  hexutil.Bytes   `json:"data"`
+	}
+	if err := param(params, 0, &args); err != nil {
+		return nil, err
+	}
+	if args.Input == nil {
+		args.Input = args.Data
+	}
+	call, sealer, err := g.open(args.Input)
+	if err != nil {
+		return nil, &Error{Code: -32000, Message: err.Error()}
+	}
+	call.Method, call.From, call.To = method, args.From, args.To
+	g.calls = append(g.calls, call)
+
+	if method == "eth_estimateGas" {
+		return hexutil.Uint64(g.gas), nil
+	}
+	var out []byte
+	if g.onCall != nil {
+		out, err = g.onCall(call)
+	}
+	var failure *Failure
+	if err != nil && !errors.As(err, &failure) {
+		return nil, err
+	}
+	if failure != nil && !sealer.enveloped {
+		return nil, &Error{Code: -32000, Message: failure.Message}
+	}
+	return hexutil.Bytes(sealer.seal(out, failure)), nil
+}
+
+// sendRawTransaction decrypts and records a transaction and bumps the
+// sender's nonce. Transactions sent again are rejected as already known.
+func (g *Gateway) sendRawTransaction(params []json.RawMessage) (interface{}, error) {
+	var raw hexutil.Bytes
+	if err := param(params, 0, &raw); err != nil {
+		return nil, err
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, &Error{Code: -32000, Message: fmt.Sprintf("invalid transaction: %v", err)}
+	}
+	from, err := types.LatestSignerForChainID(g.chainID).Sender(tx)
+	if err != nil {
+		return nil, &Error{Code: -32000, Message: fmt.Sprintf("invalid signature: %v", err)}
+	}
+	switch next := g.nonces[from]; {
+	case g.sent[tx.Hash()]:
+		return nil, &Error{Code: -32000, Message: "already known"}
+	case tx.Nonce() < next:
+		return nil, &Error{Code: -32000, Message: fmt.Sprintf("nonce too low: next nonce %d, tx nonce %d", next, tx.Nonce())}
+	case tx.Nonce() > next:
+		return nil, &Error{Code: -32000, Message: fmt.Sprintf("nonce too high: next nonce %d, tx nonce %d", next, tx.Nonce())}
+	}
+	call, _, err := g.open(tx.Data())
+	if err != nil {
+		return nil, &Error{Code: -32000, Message: err.Error()}
+	}
+	call.Method, call.From, call.To, call.Tx = "eth_sendRawTransaction", from, tx.To(), tx
+	g.calls = append(g.calls, call)
+	g.sent[tx.Hash()] = true
+	g.nonces[from]++
+	return tx.Hash(), nil
+}
+
+// param decodes the i-th parameter into v.
+func param(params []json.RawMessage, i int, v interface{}) error {
+	if i >= len(params) {
+		return &Error{Code: -32602, Message: fmt.Sprintf("missing value for required argument %d", i)}
+	}
+	if err := json.Unmarshal(params[i], v); err != nil {
+		return &Error{Code: -32602, Message: fmt.Sprintf("invalid argument %d: %v", i, err)}
+	}
+	return nil
+}
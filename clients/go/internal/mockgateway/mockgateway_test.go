@@ -0,0 +1,117 @@
+package mockgateway_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/client"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/mockgateway"
+)
+
+var to = common.HexToAddress("0x595cce2312b7dfb068eb7dbb8c2b0b593b5c8883")
+
+func TestGateway(t *testing.T) {
+	gw := mockgateway.New(t)
+	key, _ := crypto.GenerateKey()
+	signer := sapphire.NewPrivateKeySigner(key)
+	backend, err := client.WrapClient(gw.Dial(t), nil, client.WithKeyring(sapphire.NewKeyring(signer)))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	ctx := context.Background()
+	gw.OnCall(func(call mockgateway.Call) ([]byte, error) {
+		if bytes.Equal(call.Data, []byte("revert")) {
+			return nil, &mockgateway.Failure{Module: "evm", Code: 8, Message: "reverted: "}
+		}
+		return append([]byte("echo "), call.Data...), nil
+	})
+
+	// Encrypted calls are decrypted and their results encrypted.
+	out, err := backend.CallContract(ctx, ethereum.CallMsg{To: &to, Data: []byte("hello")}, nil)
+	if err != nil || string(out) != "echo hello" {
+		t.Fatalf("expected the echoed call, got %q, %v", out, err)
+	}
+	call := gw.Calls("eth_call")[0]
+	if call.Format != sdkTypes.CallFormatEncryptedX25519DeoxysII || call.Epoch != mockgateway.DefaultEpoch || call.Leash != nil || bytes.Contains(call.Envelope, []byte("hello")) {
+		t.Fatalf("expected an unsigned encrypted call, got %+v", call)
+	}
+
+	// Signed queries carry their leash.
+	out, err = backend.CallContract(ctx, ethereum.CallMsg{From: signer.Address(), To: &to, Data: []byte("signed")}, nil)
+	if err != nil || string(out) != "echo signed" {
+		t.Fatalf("expected the echoed signed call, got %q, %v", out, err)
+	}
+	call = gw.Calls("eth_call")[1]
+	if call.Leash == nil || call.Leash.BlockNumber != 99 || len(call.Signature) != 65 || call.From != signer.Address() {
+		t.Fatalf("expected a signed query, got %+v", call)
+	}
+
+	// Failures are sealed like the results.
+	_, err = backend.CallContract(ctx, ethereum.CallMsg{To: &to, Data: []byte("revert")}, nil)
+	var failed *sapphire.CallFailedError
+	if !errors.As(err, &failed) || failed.Module != "evm" || failed.Code != 8 {
+		t.Fatalf("expected the call to fail in the evm module, got %v", err)
+	}
+
+	// Transactions are decrypted and bump the nonce.
+	tx := types.NewTx(&types.LegacyTx{To: &to, Gas: 100_000, GasPrice: big.NewInt(mockgateway.DefaultGasPrice), Data: []byte("transact")})
+	if tx, err = backend.Transactor(signer.Address()).Signer(signer.Address(), tx); err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if err = backend.SendTransaction(ctx, tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	sent := gw.Calls("eth_sendRawTransaction")
+	if len(sent) != 1 || string(sent[0].Data) != "transact" || sent[0].From != signer.Address() || sent[0].Tx.Hash() != tx.Hash() {
+		t.Fatalf("expected the decrypted transaction, got %+v", sent)
+	}
+	if nonce, _ := backend.Unwrap().PendingNonceAt(ctx, signer.Address()); nonce != 1 {
+		t.Fatalf("expected the nonce to be bumped, got %d", nonce)
+	}
+}
+
+func TestGatewayFailures(t *testing.T) {
+	gw := mockgateway.New(t)
+	backend, err := client.WrapClient(gw.Dial(t), nil, client.WithRetry(client.RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	ctx := context.Background()
+	msg := ethereum.CallMsg{To: &to, Data: []byte("hello")}
+
+	// Scripted failures are used up in turn, then requests are served.
+	gw.Fail("eth_call", mockgateway.StatusError(503), mockgateway.ErrDropConnection, nil, &mockgateway.Error{Code: 3, Message: "execution reverted"})
+	if _, err = backend.CallContract(ctx, msg, nil); err != nil {
+		t.Fatalf("expected the call to be retried, got %v", err)
+	}
+	if n := len(gw.Requests("eth_call")); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+	if _, err = backend.CallContract(ctx, msg, nil); err == nil || !strings.Contains(err.Error(), "execution reverted") {
+		t.Fatalf("expected the scripted JSON-RPC error, got %v", err)
+	}
+	if _, err = backend.CallContract(ctx, msg, nil); err != nil {
+		t.Fatalf("expected the script to be used up, got %v", err)
+	}
+
+	// Handlers replace the built-in ones.
+	gw.Handle("oasis_callDataPublicKey", func([]json.RawMessage) (interface{}, error) {
+		return nil, &mockgateway.Error{Code: -32601, Message: "the method oasis_callDataPublicKey does not exist/is not available"}
+	})
+	if err = backend.RefreshCipher(ctx); !errors.Is(err, sapphire.ErrKeyFetchFailed) {
+		t.Fatalf("expected the key fetch to fail, got %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+package mockgateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	cmnErrors "github.com/oasisprotocol/oasis-core/go/common/errors"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-core/go/roothash/api/block"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/client"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/accounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/core"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+// Methods of the runtime client, under which its requests and calls are
+// recorded and failures are scripted.
+const (
+	MethodSimulateCall      = "evm.SimulateCall"
+	MethodEstimateGas       = "core.EstimateGas"
+	MethodCallDataPublicKey = "core.CallDataPublicKey"
+	MethodSubmitTx          = "SubmitTx"
+)
+
+// ethereumAuthModule is the authentication scheme of Ethereum transactions
+// wrapped in runtime transactions.
+const ethereumAuthModule = "evm.ethereum.v0"
+
+// runtimeClient serves a gateway's mock runtime the way an oasis-node
+// serves the runtime over gRPC.
+type runtimeClient struct {
+	// The methods not overridden are not implemented and panic.
+	client.RuntimeClient
+
+	g *Gateway
+}
+
+// RuntimeClient returns a client of the gateway's runtime as an oasis-node
+// serves it over gRPC, for clients that bypass the gateway. Queries and
+// transactions are recorded as requests and calls with the runtime method,
+// e.g. MethodSimulateCall, and failures scripted with Fail under it; a
+// *Failure fails the request with the module error, as the node does.
+//
+// Submitted transactions are executed with the OnCall handler and included
+// in the head block, failing if it fails, and emit the logs set with SetLogs
+// if they succeed. The client's other methods panic.
+func (g *Gateway) RuntimeClient() client.RuntimeClient {
+	return &runtimeClient{g: g}
+}
+
+// SetLogs sets the EVM logs transactions submitted to the runtime client emit.
+func (g *Gateway) SetLogs(logs ...*evm.Event) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.logs = logs
+}
+
+// request records a request for method at round and returns its scripted
+// failure, if any. The gateway must be locked.
+func (g *Gateway) request(method string, round uint64) error {
+	g.requests = append(g.requests, Request{Method: method, Round: round, Time: time.Now()})
+	var failure error
+	if queue := g.failures[method]; len(queue) > 0 {
+		failure, g.failures[method] = queue[0], queue[1:]
+	}
+	return moduleError(failure)
+}
+
+// moduleError returns the error a node reports a *Failure with.
+func moduleError(err error) error {
+	var failure *Failure
+	if errors.As(err, &failure) {
+		return cmnErrors.FromCode(failure.Module, failure.Code, failure.Message)
+	}
+	return err
+}
+
+// Query implements client.RuntimeClient.
+func (rc *runtimeClient) Query(_ context.Context, round uint64, method types.MethodName, args, rsp interface{}) error {
+	g := rc.g
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.request(string(method), round); err != nil {
+		return err
+	}
+	var res interface{}
+	switch method {
+	case MethodCallDataPublicKey:
+		res = core.CallDataPublicKeyResponse{PublicKey: g.signedKey(), Epoch: g.epoch}
+	case "core.MinGasPrice":
+		res = map[types.Denomination]types.Quantity{types.NativeDenomination: *quantity.NewFromUint64(DefaultGasPrice)}
+	case "accounts.Nonce":
+		var q accounts.NonceQuery
+		if err := recode(args, &q); err != nil {
+			return err
+		}
+		var nonce uint64
+		for account, n := range g.nonces {
+			if types.NewAddressFromEth(account.Bytes()) == q.Address {
+				nonce = n
+			}
+		}
+		res = nonce
+	case "evm.Code":
+		res = []byte{0x60, 0x80}
+	case MethodSimulateCall:
+		var q evm.SimulateCallQuery
+		if err := recode(args, &q); err != nil {
+			return err
+		}
+		out, err := g.simulateCall(q)
+		if err != nil {
+			return err
+		}
+		res = out
+	case MethodEstimateGas:
+		var q core.EstimateGasQuery
+		if err := recode(args, &q); err != nil {
+			return err
+		}
+		if err := g.estimateGas(q); err != nil {
+			return err
+		}
+		res = g.gas
+	default:
+		return cmnErrors.FromCode("core", 3, fmt.Sprintf("invalid method: %s", method))
+	}
+	return recode(res, rsp)
+}
+
+// simulateCall decrypts and records a call, and returns the encrypted output
+// of onCall.
+func (g *Gateway) simulateCall(q evm.SimulateCallQuery) ([]byte, error) {
+	call, sealer, err := g.open(q.Data)
+	if err != nil {
+		return nil, cmnErrors.FromCode("core", 17, err.Error())
+	}
+	call.Method, call.From = MethodSimulateCall, common.BytesToAddress(q.Caller)
+	if q.Address != nil {
+		to := common.BytesToAddress(q.Address)
+		call.To = &to
+	}
+	g.calls = append(g.calls, call)
+
+	var out []byte
+	if g.onCall != nil {
+		out, err = g.onCall(call)
+	}
+	var failure *Failure
+	if err != nil && !errors.As(err, &failure) {
+		return nil, err
+	}
+	if failure != nil && !sealer.enveloped {
+		return nil, moduleError(failure)
+	}
+	return sealer.seal(out, failure), nil
+}
+
+// estimateGas decrypts and records the call of an estimate.
+func (g *Gateway) estimateGas(q core.EstimateGasQuery) error {
+	if q.Tx == nil || q.Tx.Call.Method != "evm.Call" && q.Tx.Call.Method != "evm.Create" {
+		return cmnErrors.FromCode("core", 3, "invalid method")
+	}
+	var body evm.Call
+	if q.Tx.Call.Method == "evm.Call" {
+		if err := cbor.Unmarshal(q.Tx.Call.Body, &body); err != nil {
+			return cmnErrors.FromCode("core", 1, err.Error())
+		}
+	} else {
+		var create evm.Create
+		if err := cbor.Unmarshal(q.Tx.Call.Body, &create); err != nil {
+			return cmnErrors.FromCode("core", 1, err.Error())
+		}
+		body.Data = create.InitCode
+	}
+	call, _, err := g.open(body.Data)
+	if err != nil {
+		return cmnErrors.FromCode("core", 17, err.Error())
+	}
+	call.Method = MethodEstimateGas
+	if q.Caller != nil && q.Caller.EthAddress != nil {
+		call.From = *q.Caller.EthAddress
+	}
+	if body.Address != nil {
+		to := common.BytesToAddress(body.Address)
+		call.To = &to
+	}
+	g.calls = append(g.calls, call)
+	return nil
+}
+
+// GetBlock implements client.RuntimeClient. Every round is the head block.
+func (rc *runtimeClient) GetBlock(_ context.Context, round uint64) (*block.Block, error) {
+	g := rc.g
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.request("GetBlock", round); err != nil {
+		return nil, err
+	}
+	return g.headBlock(), nil
+}
+
+// headBlock returns the runtime block of the head. The gateway must be
+// locked.
+func (g *Gateway) headBlock() *block.Block {
+	var blk block.Block
+	blk.Header.Round = g.head.Number.Uint64()
+	blk.Header.Timestamp = block.Timestamp(g.head.Time)
+	blk.Header.PreviousHash = hash.Hash(g.head.ParentHash)
+	return &blk
+}
+
+// SubmitTxRawMeta implements client.RuntimeClient. Transactions are checked
+// and executed at once, in the head block.
+func (rc *runtimeClient) SubmitTxRawMeta(_ context.Context, utx *types.UnverifiedTransaction) (*client.SubmitTxRawMeta, error) {
+	g := rc.g
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.request(MethodSubmitTx, client.RoundLatest); err != nil {
+		return nil, err
+	}
+	checkFailed := func(module string, code uint32, message string) (*client.SubmitTxRawMeta, error) {
+		return &client.SubmitTxRawMeta{TransactionMeta: client.TransactionMeta{
+			CheckTxError: &client.CheckTxError{Module: module, Code: code, Message: message},
+		}}, nil
+	}
+	tx := new(ethtypes.Transaction)
+	if len(utx.AuthProofs) != 1 || utx.AuthProofs[0].Module != ethereumAuthModule || tx.UnmarshalBinary(utx.Body) != nil {
+		return checkFailed("core", 1, "malformed transaction")
+	}
+	from, err := ethtypes.LatestSignerForChainID(g.chainID).Sender(tx)
+	if err != nil {
+		return checkFailed("core", 1, fmt.Sprintf("invalid signature: %v", err))
+	}
+	if tx.Nonce() != g.nonces[from] {
+		return checkFailed("core", 4, fmt.Sprintf("invalid nonce: expected %d, got %d", g.nonces[from], tx.Nonce()))
+	}
+	call, sealer, err := g.open(tx.Data())
+	if err != nil {
+		return checkFailed("core", 17, err.Error())
+	}
+	call.Method, call.From, call.To, call.Tx = MethodSubmitTx, from, tx.To(), tx
+	g.calls = append(g.calls, call)
+	g.sent[tx.Hash()] = true
+	g.nonces[from]++
+
+	var out []byte
+	if g.onCall != nil {
+		out, err = g.onCall(call)
+	}
+	var failure *Failure
+	if err != nil && !errors.As(err, &failure) {
+		failure = &Failure{Module: "evm", Code: 2, Message: err.Error()}
+	}
+	// Failures are reported in the clear, results encrypted like those of
+	// queries.
+	var result types.CallResult
+	switch {
+	case failure != nil:
+		result.Failed = &types.FailedCallResult{Module: failure.Module, Code: failure.Code, Message: failure.Message}
+	case sealer.enveloped:
+		_ = cbor.Unmarshal(sealer.seal(out, nil), &result)
+	default:
+		result.Ok = cbor.Marshal(out)
+	}
+
+	events := []*types.Event{{
+		Module: "core",
+		Code:   core.GasUsedEventCode,
+		Value:  cbor.Marshal([]*core.GasUsedEvent{{Amount: g.gas}}),
+	}}
+	if failure == nil && len(g.logs) > 0 {
+		events = append(events, &types.Event{Module: evm.ModuleName, Code: 1, Value: cbor.Marshal(g.logs)})
+	}
+	round := g.head.Number.Uint64()
+	g.included[round] = append(g.included[round], &client.TransactionWithResults{Tx: *utx, Result: result, Events: events})
+	return &client.SubmitTxRawMeta{
+		TransactionMeta: client.TransactionMeta{Round: round, BatchOrder: uint32(len(g.included[round]) - 1)},
+		Result:          result,
+	}, nil
+}
+
+// GetTransactionsWithResults implements client.RuntimeClient.
+func (rc *runtimeClient) GetTransactionsWithResults(_ context.Context, round uint64) ([]*client.TransactionWithResults, error) {
+	g := rc.g
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.request("GetTransactionsWithResults", round); err != nil {
+		return nil, err
+	}
+	return g.included[round], nil
+}
+
+// recode converts v to out through CBOR, as the node's gRPC interface does.
+func recode(v, out interface{}) error {
+	if out == nil {
+		return nil
+	}
+	return cbor.Unmarshal(cbor.Marshal(v), out)
+}
@@ -0,0 +1,206 @@
+package testenv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultDockerHost is the Docker daemon's socket if DOCKER_HOST is unset.
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+var (
+	errNotFound = errors.New("not found")
+	errConflict = errors.New("already exists")
+)
+
+// dockerClient is a minimal client of the Docker Engine API.
+type dockerClient struct {
+	http *http.Client
+	base string
+}
+
+// newDockerClient returns a client of the daemon at host, a unix:// or
+// tcp:// address.
+func newDockerClient(host string) (*dockerClient, error) {
+	if host == "" {
+		host = defaultDockerHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOCKER_HOST: %w", err)
+	}
+	switch u.Scheme {
+	case "unix":
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		return &dockerClient{
+			http: &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", u.Path)
+				},
+			}},
+			base: "http://docker",
+		}, nil
+	case "tcp", "http":
+		return &dockerClient{http: http.DefaultClient, base: "http://" + u.Host}, nil
+	}
+	return nil, fmt.Errorf("unsupported DOCKER_HOST %q", host)
+}
+
+// do sends a request and decodes the JSON response into out, if not nil.
+func (d *dockerClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, d.base+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("docker: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("docker: %s %s: %w", method, path, errNotFound)
+	case resp.StatusCode == http.StatusConflict:
+		return fmt.Errorf("docker: %s %s: %w", method, path, errConflict)
+	case resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified:
+		var msg struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&msg)
+		return fmt.Errorf("docker: %s %s: %s: %s", method, path, resp.Status, msg.Message)
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// containerConfig is the part of the container create request the harness
+// sets.
+type containerConfig struct {
+	Image        string                 `json:"Image"`
+	Env          []string               `json:"Env"`
+	Labels       map[string]string      `json:"Labels"`
+	ExposedPorts map[string]struct{}    `json:"ExposedPorts"`
+	Healthcheck  *healthConfig          `json:"Healthcheck,omitempty"`
+	HostConfig   map[string]interface{} `json:"HostConfig"`
+}
+
+type healthConfig struct {
+	Test     []string      `json:"Test"`
+	Interval time.Duration `json:"Interval"`
+}
+
+// containerSpec returns the sapphire-localnet container exposing its gateway
+// on hostPort.
+func containerSpec(image, hostPort string) containerConfig {
+	return containerConfig{
+		Image:        image,
+		Env:          []string{"OASIS_DOCKER_START_EXPLORER=no"},
+		Labels:       map[string]string{containerLabel: "1"},
+		ExposedPorts: map[string]struct{}{"8545/tcp": {}},
+		Healthcheck: &healthConfig{
+			Test:     []string{"CMD-SHELL", "test -f /CONTAINER_READY"},
+			Interval: pollInterval,
+		},
+		HostConfig: map[string]interface{}{
+			"PortBindings": map[string]interface{}{
+				"8545/tcp": []map[string]string{{"HostPort": hostPort}},
+			},
+		},
+	}
+}
+
+// containerState is the part of the container inspect response the harness
+// reads.
+type containerState struct {
+	State struct {
+		Status  string `json:"Status"`
+		Running bool   `json:"Running"`
+	} `json:"State"`
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+// pull pulls image unless it is present.
+func (d *dockerClient) pull(ctx context.Context, image string) error {
+	err := d.do(ctx, http.MethodGet, "/images/"+image+"/json", nil, nil)
+	if !errors.Is(err, errNotFound) {
+		return err
+	}
+	name, tag := image, "latest"
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		name, tag = image[:i], image[i+1:]
+	}
+	query := url.Values{"fromImage": {name}, "tag": {tag}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.base+"/images/create?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("docker: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker: pulling %s: %s", image, resp.Status)
+	}
+	// The progress is streamed as JSON messages, failures included.
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err = dec.Decode(&msg); errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("docker: pulling %s: %w", image, err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("docker: pulling %s: %s", image, msg.Error)
+		}
+	}
+}
+
+func (d *dockerClient) create(ctx context.Context, name string, spec containerConfig) error {
+	return d.do(ctx, http.MethodPost, "/containers/create?name="+url.QueryEscape(name), spec, nil)
+}
+
+func (d *dockerClient) start(ctx context.Context, name string) error {
+	return d.do(ctx, http.MethodPost, "/containers/"+name+"/start", nil, nil)
+}
+
+func (d *dockerClient) inspect(ctx context.Context, name string) (*containerState, error) {
+	var state containerState
+	if err := d.do(ctx, http.MethodGet, "/containers/"+name+"/json", nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (d *dockerClient) remove(ctx context.Context, name string) error {
+	return d.do(ctx, http.MethodDelete, "/containers/"+name+"?force=true&v=true", nil, nil)
+}
@@ -0,0 +1,79 @@
+package testenv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// leases track the test binaries using the container, so that only the last
+// one to finish removes it. Each holds a file named after its process ID.
+type leases struct {
+	dir  string
+	pid  int
+	held bool
+}
+
+func defaultLeaseDir() string {
+	return filepath.Join(os.TempDir(), containerName+".leases")
+}
+
+func (l *leases) path(pid int) string {
+	return filepath.Join(l.dir, strconv.Itoa(pid))
+}
+
+// acquire takes the lease of process l.pid.
+func (l *leases) acquire() error {
+	if l.held {
+		return nil
+	}
+	if err := os.MkdirAll(l.dir, 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(l.path(l.pid), nil, 0o600); err != nil {
+		return err
+	}
+	l.held = true
+	return nil
+}
+
+// release drops the lease of process l.pid and reports whether it was
+// the last one. Leases of processes that are gone are dropped too.
+func (l *leases) release() (bool, error) {
+	if !l.held {
+		return false, nil
+	}
+	if err := os.Remove(l.path(l.pid)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	l.held = false
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return false, err
+	}
+	last := true
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if alive(pid) {
+			last = false
+		} else {
+			_ = os.Remove(l.path(pid))
+		}
+	}
+	return last, nil
+}
+
+// alive reports whether the process pid may still be running.
+func alive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, syscall.ESRCH)
+}
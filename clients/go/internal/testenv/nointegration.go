@@ -0,0 +1,6 @@
+//go:build !integration
+
+package testenv
+
+// integration is whether the tests were built with the integration tag.
+const integration = false
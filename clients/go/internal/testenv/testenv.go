@@ -0,0 +1,369 @@
+// Package testenv runs integration tests against a sapphire-localnet:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(testenv.Run(m))
+//	}
+//
+//	func TestSomethingLocalnet(t *testing.T) {
+//		localnet := testenv.Start(t)
+//		client := localnet.Dial(t)
+//		...
+//	}
+//
+// The tests only run when built with the integration tag, or when
+// SAPPHIRE_LOCALNET is set, and are skipped otherwise:
+//
+//	go test -tags=integration ./...
+//
+// If no gateway answers at SAPPHIRE_LOCALNET_GATEWAY, http://localhost:8545
+// by default, the SAPPHIRE_LOCALNET_IMAGE container is started with the
+// Docker API at DOCKER_HOST and removed once the last test binary using it
+// is done, unless SAPPHIRE_LOCALNET_KEEP is set.
+//
+// Tests of the node's gRPC interface, which the container does not expose,
+// need SAPPHIRE_LOCALNET_NODE, e.g. unix:/path/to/internal.sock. So do tests
+// needing the consensus chain context, which the gateway doesn't serve,
+// unless it is given with SAPPHIRE_CONSENSUS_CHAIN_CONTEXT.
+//
+// The sapphire and client packages' own tests use testenv, so it must import
+// neither, which would be an import cycle.
+package testenv
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	cmnGrpc "github.com/oasisprotocol/oasis-core/go/common/grpc"
+	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// DefaultGateway is the gateway of a sapphire-localnet started with its
+	// default ports.
+	DefaultGateway = "http://localhost:8545"
+	// DefaultImage is the sapphire-localnet image started if there is no
+	// gateway.
+	DefaultImage = "ghcr.io/oasisprotocol/sapphire-localnet:latest"
+
+	// containerName is the name of the container started by the harness,
+	// shared by all test binaries.
+	containerName = "sapphire-localnet-go-test"
+	// containerLabel marks containers started by the harness, the only ones
+	// it removes.
+	containerLabel = "org.oasisprotocol.sapphire-paratime.testenv"
+	// startTimeout bounds how long the localnet may take to become ready.
+	startTimeout = 5 * time.Minute
+	// pollInterval is how often readiness is checked.
+	pollInterval = time.Second
+)
+
+// fundedKeys are the keys of the accounts sapphire-localnet funds, derived
+// from the "test test test test test test test test test test test junk"
+// mnemonic.
+var fundedKeys = []string{
+	"ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80",
+	"59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d",
+	"5de4111afa1a4b94908f83103eb1f1706367c2e68ca870fc3fb9a804cdab365a",
+	"7c852118294e51e653712a81e05800f419141751be58f605c371e15141b007a6",
+	"47e179ec197488593b187f80a00eb0da91f1b9d0b13f8733639f19c30a34926a",
+}
+
+// Account is a pre-funded localnet account. It implements
+// sapphire.SignerWithAddress.
+type Account struct {
+	Key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+func newAccount(hexKey string) Account {
+	key, err := crypto.HexToECDSA(hexKey)
+	if err != nil {
+		panic(fmt.Sprintf("testenv: invalid funded key: %v", err))
+	}
+	return Account{Key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// Address returns the account's address.
+func (a Account) Address() common.Address {
+	return a.address
+}
+
+// SignRSV signs digest with the account's key.
+func (a Account) SignRSV(digest [32]byte) ([]byte, error) {
+	return crypto.Sign(digest[:], a.Key)
+}
+
+// Localnet is a running sapphire-localnet.
+type Localnet struct {
+	// Gateway is the URL of the localnet's Web3 gateway.
+	Gateway string
+	// Node is the gRPC target of the localnet's oasis-node, empty unless
+	// SAPPHIRE_LOCALNET_NODE is set.
+	Node string
+	// ChainID is the localnet's chain ID.
+	ChainID *big.Int
+	// Accounts are the pre-funded accounts. Tests sending transactions from
+	// the same account must not run in parallel.
+	Accounts []Account
+
+	// chainContext is the consensus chain context given in the environment.
+	chainContext string
+}
+
+// Dial connects to the localnet's gateway. The connection is closed when the
+// test ends.
+func (l *Localnet) Dial(t testing.TB) *ethclient.Client {
+	t.Helper()
+	client, err := ethclient.Dial(l.Gateway)
+	if err != nil {
+		t.Fatalf("failed to dial localnet: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// ChainContext returns the consensus chain context of the localnet, the one
+// given with SAPPHIRE_CONSENSUS_CHAIN_CONTEXT or else the one reported by the
+// node. t fails if there is neither.
+func (l *Localnet) ChainContext(t testing.TB) string {
+	t.Helper()
+	if l.chainContext != "" {
+		return l.chainContext
+	}
+	if l.Node == "" {
+		t.Fatalf("consensus chain context unknown: set SAPPHIRE_LOCALNET_NODE or SAPPHIRE_CONSENSUS_CHAIN_CONTEXT")
+	}
+	conn, err := cmnGrpc.Dial(l.Node, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial node: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	chainContext, err := consensus.NewConsensusClient(conn).GetChainContext(ctx)
+	if err != nil {
+		t.Fatalf("failed to fetch consensus chain context: %v", err)
+	}
+	return chainContext
+}
+
+// Enabled reports whether integration tests run, i.e. whether the tests were
+// built with the integration tag or SAPPHIRE_LOCALNET is set.
+func Enabled() bool {
+	return integration || os.Getenv("SAPPHIRE_LOCALNET") != ""
+}
+
+var (
+	mu       sync.Mutex
+	env      *environment
+	localnet *Localnet
+	startErr error
+)
+
+// Start returns the localnet, starting it on first use, and skips t if
+// integration tests are not enabled. If the localnet failed to start, t
+// fails without trying again.
+func Start(t testing.TB) *Localnet {
+	t.Helper()
+	if !Enabled() {
+		t.Skip("integration tests not enabled, run with -tags=integration or set SAPPHIRE_LOCALNET")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if env == nil {
+		env = newEnvironment(configFromEnv())
+		ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+		defer cancel()
+		localnet, startErr = env.start(ctx)
+	}
+	if startErr != nil {
+		t.Fatalf("failed to start localnet: %v", startErr)
+	}
+	return localnet
+}
+
+// Run runs the tests and then stops the localnet if they started it and no
+// other test binary still uses it. It returns the exit code of m.Run.
+func Run(m *testing.M) int {
+	code := m.Run()
+	mu.Lock()
+	defer mu.Unlock()
+	if env != nil {
+		if err := env.stop(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "testenv: failed to stop localnet: %v\n", err)
+		}
+	}
+	return code
+}
+
+// config configures the harness.
+type config struct {
+	gateway      string
+	node         string
+	chainContext string
+	image        string
+	dockerHost   string
+	keep         bool
+	leaseDir     string
+}
+
+func configFromEnv() config {
+	cfg := config{
+		gateway:      os.Getenv("SAPPHIRE_LOCALNET_GATEWAY"),
+		node:         os.Getenv("SAPPHIRE_LOCALNET_NODE"),
+		chainContext: os.Getenv("SAPPHIRE_CONSENSUS_CHAIN_CONTEXT"),
+		image:        os.Getenv("SAPPHIRE_LOCALNET_IMAGE"),
+		dockerHost:   os.Getenv("DOCKER_HOST"),
+		keep:         os.Getenv("SAPPHIRE_LOCALNET_KEEP") != "",
+		leaseDir:     defaultLeaseDir(),
+	}
+	if cfg.gateway == "" {
+		cfg.gateway = DefaultGateway
+	}
+	if cfg.image == "" {
+		cfg.image = DefaultImage
+	}
+	return cfg
+}
+
+// environment starts and stops the localnet.
+type environment struct {
+	cfg    config
+	leases *leases
+}
+
+func newEnvironment(cfg config) *environment {
+	return &environment{cfg: cfg, leases: &leases{dir: cfg.leaseDir, pid: os.Getpid()}}
+}
+
+// start attaches to the gateway, or starts the container if there is none,
+// and waits for the localnet to be ready.
+func (e *environment) start(ctx context.Context) (*Localnet, error) {
+	// Lease the container before looking for it, so that a test binary
+	// finishing meanwhile does not remove it.
+	if err := e.leases.acquire(); err != nil {
+		return nil, err
+	}
+	l := &Localnet{Gateway: e.cfg.gateway, Node: e.cfg.node, chainContext: e.cfg.chainContext}
+	for _, key := range fundedKeys {
+		l.Accounts = append(l.Accounts, newAccount(key))
+	}
+
+	var docker *dockerClient
+	if err := e.probe(ctx, l); err != nil {
+		var dockerErr error
+		if docker, dockerErr = newDockerClient(e.cfg.dockerHost); dockerErr == nil {
+			dockerErr = e.startContainer(ctx, docker)
+		}
+		if dockerErr != nil {
+			return nil, fmt.Errorf("no gateway at %s (%v) and failed to start one: %w", e.cfg.gateway, err, dockerErr)
+		}
+	}
+	for {
+		if docker != nil {
+			state, err := docker.inspect(ctx, containerName)
+			if err != nil {
+				return nil, err
+			}
+			if !state.State.Running {
+				return nil, fmt.Errorf("container %s is %s", containerName, state.State.Status)
+			}
+		}
+		err := e.probe(ctx, l)
+		if err == nil {
+			return l, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("localnet not ready: %w", err)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// probe checks that the gateway serves Sapphire requests and the first
+// account has been funded, and sets l.ChainID.
+func (e *environment) probe(ctx context.Context, l *Localnet) error {
+	c, err := rpc.DialContext(ctx, e.cfg.gateway)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	client := ethclient.NewClient(c)
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+	var key interface{}
+	if err = c.CallContext(ctx, &key, "oasis_callDataPublicKey"); err != nil {
+		return fmt.Errorf("runtime key not available: %w", err)
+	}
+	balance, err := client.BalanceAt(ctx, l.Accounts[0].Address(), nil)
+	if err != nil {
+		return err
+	}
+	if balance.Sign() == 0 {
+		return errors.New("accounts not funded yet")
+	}
+	l.ChainID = chainID
+	return nil
+}
+
+// startContainer creates the container, unless another test binary did, and
+// starts it.
+func (e *environment) startContainer(ctx context.Context, docker *dockerClient) error {
+	u, err := url.Parse(e.cfg.gateway)
+	if err != nil {
+		return fmt.Errorf("invalid gateway: %w", err)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "8545"
+	}
+	if err = docker.pull(ctx, e.cfg.image); err != nil {
+		return err
+	}
+	if err = docker.create(ctx, containerName, containerSpec(e.cfg.image, port)); err != nil && !errors.Is(err, errConflict) {
+		return err
+	}
+	return docker.start(ctx, containerName)
+}
+
+// stop releases the lease and removes the container if it was started by the
+// harness and no other test binary holds a lease.
+func (e *environment) stop(ctx context.Context) error {
+	last, err := e.leases.release()
+	if err != nil || !last || e.cfg.keep {
+		return err
+	}
+	docker, err := newDockerClient(e.cfg.dockerHost)
+	if err != nil {
+		// Without Docker, the harness can't have started the container.
+		return nil
+	}
+	state, err := docker.inspect(ctx, containerName)
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := state.Config.Labels[containerLabel]; !ok {
+		return nil
+	}
+	return docker.remove(ctx, containerName)
+}
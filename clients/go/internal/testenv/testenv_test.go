@@ -0,0 +1,142 @@
+package testenv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDocker serves the Docker API calls of the harness and a gateway that
+// only answers once the container started.
+type fakeDocker struct {
+	mu       sync.Mutex
+	created  *containerConfig
+	started  bool
+	removed  bool
+	requests []string
+}
+
+func (d *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = append(d.requests, r.Method+" "+r.URL.Path)
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/images/"):
+		_, _ = w.Write([]byte("{}"))
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/create":
+		if d.created != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		d.created = new(containerConfig)
+		_ = json.NewDecoder(r.Body).Decode(d.created)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"Id":"1"}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/"+containerName+"/start":
+		d.started = true
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Path == "/containers/"+containerName+"/json":
+		if d.created == nil || d.removed {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var state containerState
+		state.State.Running, state.State.Status = d.started, "running"
+		state.Config.Labels = d.created.Labels
+		_ = json.NewEncoder(w).Encode(state)
+	case r.Method == http.MethodDelete && r.URL.Path == "/containers/"+containerName:
+		d.removed = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (d *fakeDocker) gateway(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	up := d.started && !d.removed
+	d.mu.Unlock()
+	if !up {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var req struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	results := map[string]interface{}{
+		"eth_chainId":             "0x5afd",
+		"oasis_callDataPublicKey": map[string]interface{}{"epoch": 1},
+		"eth_getBalance":          "0x1",
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": results[req.Method]})
+}
+
+func TestEnvironment(t *testing.T) {
+	docker := &fakeDocker{}
+	dockerServer := httptest.NewServer(docker)
+	defer dockerServer.Close()
+	gateway := httptest.NewServer(http.HandlerFunc(docker.gateway))
+	defer gateway.Close()
+
+	cfg := config{
+		gateway:      gateway.URL,
+		chainContext: "074fcd4a15e8ce2bdd76de7d2914f2a77c7b2871852d8644b2b9f7bbec8a12c8",
+		image:        DefaultImage,
+		dockerHost:   "tcp://" + strings.TrimPrefix(dockerServer.URL, "http://"),
+		leaseDir:     t.TempDir(),
+	}
+	ctx := context.Background()
+
+	first := newEnvironment(cfg)
+	l, err := first.start(ctx)
+	if err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	if l.ChainID.Uint64() != 0x5afd || len(l.Accounts) != len(fundedKeys) || l.Accounts[0].Address().Hex() != "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266" {
+		t.Fatalf("unexpected localnet %+v", l)
+	}
+	if chainContext := l.ChainContext(t); chainContext != cfg.chainContext {
+		t.Fatalf("expected the configured chain context, got %q", chainContext)
+	}
+	if docker.created == nil || docker.created.Image != DefaultImage || docker.created.Labels[containerLabel] == "" {
+		t.Fatalf("expected the container to be created, got %+v", docker.created)
+	}
+
+	// A second test binary attaches to the running container.
+	second := newEnvironment(cfg)
+	second.leases.pid = os.Getppid()
+	if _, err = second.start(ctx); err != nil {
+		t.Fatalf("failed to attach: %v", err)
+	}
+	if err = first.stop(ctx); err != nil || docker.removed {
+		t.Fatalf("expected the container to outlive the first binary, got %v", err)
+	}
+	if err = second.stop(ctx); err != nil || !docker.removed {
+		t.Fatalf("expected the last binary to remove the container, got %v", err)
+	}
+
+	// Containers the harness did not start are left alone.
+	docker.removed, docker.created.Labels = false, nil
+	third := newEnvironment(cfg)
+	if _, err = third.start(ctx); err != nil {
+		t.Fatalf("failed to attach: %v", err)
+	}
+	if err = third.stop(ctx); err != nil || docker.removed {
+		t.Fatalf("expected a foreign container not to be removed, got %v", err)
+	}
+}
+
+func TestAccountSigns(t *testing.T) {
+	a := newAccount(fundedKeys[0])
+	if _, err := a.SignRSV([32]byte{1}); err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+}
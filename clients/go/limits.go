@@ -0,0 +1,108 @@
+package sapphire
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+)
+
+// ErrResponseTooLarge is returned when a gateway response exceeds the
+// configured DecodeLimits.
+var ErrResponseTooLarge = errors.New("gateway response too large")
+
+// DecodeLimits bounds the resources spent on decoding gateway responses, so
+// that a hostile or buggy gateway cannot exhaust the client's memory.
+//
+// Zero fields take their value from DefaultDecodeLimits.
+type DecodeLimits struct {
+	// MaxResponseSize is the maximum size in bytes of a JSON-RPC response
+	// body or WebSocket message. It is only enforced on connections made
+	// by Dial.
+	MaxResponseSize int64
+	// MaxEnvelopeSize is the maximum size in bytes of a CBOR-encoded call
+	// result and of the envelopes and plaintexts nested in it.
+	MaxEnvelopeSize int
+	// MaxNestingDepth is the maximum nesting depth of CBOR arrays and maps in
+	// call results, from 4 to 256.
+	MaxNestingDepth int
+}
+
+// DefaultDecodeLimits are generous limits that no legitimate response comes
+// close to.
+var DefaultDecodeLimits = DecodeLimits{
+	MaxResponseSize: 64 << 20,
+	MaxEnvelopeSize: 32 << 20,
+	MaxNestingDepth: 16,
+}
+
+// withDefaults returns the limits with unset fields set to their defaults.
+func (l DecodeLimits) withDefaults() DecodeLimits {
+	if l.MaxResponseSize == 0 {
+		l.MaxResponseSize = DefaultDecodeLimits.MaxResponseSize
+	}
+	if l.MaxEnvelopeSize == 0 {
+		l.MaxEnvelopeSize = DefaultDecodeLimits.MaxEnvelopeSize
+	}
+	if l.MaxNestingDepth == 0 {
+		l.MaxNestingDepth = DefaultDecodeLimits.MaxNestingDepth
+	}
+	return l
+}
+
+// unmarshal decodes a CBOR value from the gateway after checking it against
+// the limits.
+func (l DecodeLimits) unmarshal(data []byte, v interface{}) error {
+	l = l.withDefaults()
+	if len(data) > l.MaxEnvelopeSize {
+		return fmt.Errorf("%w: %d byte envelope exceeds limit of %d bytes", ErrResponseTooLarge, len(data), l.MaxEnvelopeSize)
+	}
+	if l.MaxNestingDepth < minNestingDepth || l.MaxNestingDepth > maxNestingDepth {
+		return fmt.Errorf("invalid decode limits: nesting depth %d not in [%d, %d]", l.MaxNestingDepth, minNestingDepth, maxNestingDepth)
+	}
+	if _, err := scanCBOR(data, l.MaxNestingDepth); err != nil {
+		if errors.Is(err, errCBORDepth) {
+			return fmt.Errorf("%w: %v", ErrResponseTooLarge, err)
+		}
+		return err
+	}
+	return cbor.Unmarshal(data, v)
+}
+
+// checkSize checks the size of a decoded plaintext against the limits.
+func (l DecodeLimits) checkSize(data []byte) error {
+	if l = l.withDefaults(); len(data) > l.MaxEnvelopeSize {
+		return fmt.Errorf("%w: %d byte plaintext exceeds limit of %d bytes", ErrResponseTooLarge, len(data), l.MaxEnvelopeSize)
+	}
+	return nil
+}
+
+// CipherWithLimits returns a copy of c that decodes and decrypts within
+// limits, if it is one of the ciphers of this package, and c otherwise.
+func CipherWithLimits(c Cipher, limits DecodeLimits) Cipher {
+	switch c := c.(type) {
+	case PlainCipher:
+		c.limits = limits
+		return c
+	case *X25519DeoxysIICipher:
+		cp := *c
+		cp.limits = limits
+		return &cp
+	case X25519DeoxysIICipher:
+		c.limits = limits
+		return c
+	default:
+		return c
+	}
+}
+
+// RateLimit describes a token bucket limit on outbound requests.
+//
+// The zero value means no limit.
+type RateLimit struct {
+	// Rate is the sustained number of requests per second.
+	Rate float64
+	// Burst is the number of requests that may be sent back to back. Values
+	// below one are treated as one.
+	Burst int
+}
@@ -0,0 +1,51 @@
+package sapphire
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+)
+
+func TestScanCBOR(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data []byte
+		n    int
+		err  error
+	}{
+		{"integer", []byte{0x18, 0x2a}, 2, nil},
+		{"item followed by more", []byte{0x01, 0x02}, 1, nil},
+		{"map", cbor.Marshal(map[string]uint64{"a": 1, "b": 1 << 40}), 15, nil},
+		{"nested within depth", nestedArrays(8), 9, nil},
+		{"nested too deep", nestedArrays(9), 0, errCBORDepth},
+		{"huge byte string", hugeByteString, 0, errCBORMalformed},
+		{"huge array", []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}, 0, errCBORMalformed},
+		{"huge map", []byte{0xbb, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 0, errCBORMalformed},
+		{"truncated", []byte{0x82, 0x01}, 0, errCBORMalformed},
+		{"truncated header", []byte{0x19, 0x01}, 0, errCBORMalformed},
+		{"indefinite length", []byte{0x9f, 0x01, 0xff}, 0, errCBORMalformed},
+		{"reserved", []byte{0x1c}, 0, errCBORMalformed},
+		{"tag", []byte{0xc1, 0x01}, 0, errCBORMalformed},
+		{"empty", nil, 0, errCBORMalformed},
+	} {
+		n, err := scanCBOR(tc.data, 8)
+		if !errors.Is(err, tc.err) || n != tc.n {
+			t.Fatalf("%s: expected %d, %v, got %d, %v", tc.name, tc.n, tc.err, n, err)
+		}
+	}
+}
+
+// nestedArrays encodes depth nested single-element CBOR arrays.
+func nestedArrays(depth int) []byte {
+	return append(bytes.Repeat([]byte{0x81}, depth), 0x00)
+}
+
+// hugeByteString is a CBOR byte string whose length prefix claims almost
+// 2^64 bytes but carries only a few.
+var hugeByteString = []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xf0, 'x', 'y', 'z'}
+
+// fuzzLimits keep decoders under fuzzing from allocating more than the
+// fuzzer's inputs warrant.
+var fuzzLimits = DecodeLimits{MaxEnvelopeSize: 4096, MaxNestingDepth: 8}
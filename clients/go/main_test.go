@@ -0,0 +1,12 @@
+package sapphire
+
+import (
+	"os"
+	"testing"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testenv.Run(m))
+}
@@ -0,0 +1,116 @@
+package sapphire
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+// ModuleError is an error a runtime module failed a call or transaction
+// with, whether it came in a call result or in a gateway error's data.
+type ModuleError = CallFailedError
+
+type moduleCode struct {
+	module string
+	code   uint32
+}
+
+// moduleErrorDescriptions describes the well-known errors of the runtime
+// modules.
+var moduleErrorDescriptions = map[moduleCode]string{
+	{"core", 1}:  "malformed transaction",
+	{"core", 2}:  "invalid transaction",
+	{"core", 3}:  "invalid method",
+	{"core", 4}:  "invalid nonce",
+	{"core", 5}:  "insufficient balance to pay fees",
+	{"core", 6}:  "out of message slots",
+	{"core", 8}:  "message handler not invoked",
+	{"core", 9}:  "missing message handler",
+	{"core", 10}: "invalid argument",
+	{"core", 11}: "gas overflow",
+	{"core", 12}: "out of gas",
+	{"core", 15}: "too many authentication slots",
+	{"core", 16}: "multisig too many signers",
+	{"core", 17}: "invalid call format",
+	{"core", 18}: "not authenticated",
+	{"core", 19}: "gas price too low",
+
+	{"evm", 1}:               "invalid argument",
+	{"evm", 2}:               "EVM error",
+	{"evm", 3}:               "invalid signer type",
+	{"evm", 4}:               "fee overflow",
+	{"evm", 5}:               "gas limit too low",
+	{"evm", 6}:               "insufficient balance",
+	{"evm", 7}:               "forbidden",
+	{"evm", evmRevertedCode}: "execution reverted",
+
+	{"accounts", 1}: "invalid argument",
+	{"accounts", 2}: "insufficient balance",
+	{"accounts", 3}: "forbidden",
+	{"accounts", 4}: "not found",
+}
+
+// ModuleErrorDescription describes the well-known error code of module, or
+// returns an empty string if it is not known.
+func ModuleErrorDescription(module string, code uint32) string {
+	return moduleErrorDescriptions[moduleCode{module, code}]
+}
+
+// Description describes the error's code, see ModuleErrorDescription.
+func (e *CallFailedError) Description() string {
+	return ModuleErrorDescription(e.Module, e.Code)
+}
+
+// DecodeModuleError finds the runtime module error in err: a CallFailedError
+// or a gateway error whose data is a CBOR-encoded failed call result, either
+// hex or base64 encoded, or a JSON object with its module, code and message.
+func DecodeModuleError(err error) (*ModuleError, bool) {
+	var failed *CallFailedError
+	if errors.As(err, &failed) {
+		return failed, true
+	}
+	var dataErr dataError
+	if !errors.As(err, &dataErr) {
+		return nil, false
+	}
+	switch data := dataErr.ErrorData().(type) {
+	case map[string]interface{}:
+		module, _ := data["module"].(string)
+		code, ok := data["code"].(float64)
+		if module == "" || !ok || code < 0 || code != float64(uint32(code)) {
+			return nil, false
+		}
+		message, _ := data["message"].(string)
+		return &ModuleError{Module: module, Code: uint32(code), Message: message}, true
+	case string:
+		return decodeFailedCallResult(data)
+	}
+	return nil, false
+}
+
+// decodeFailedCallResult decodes a hex or base64 encoded failed call result,
+// or a call result with one.
+func decodeFailedCallResult(s string) (*ModuleError, bool) {
+	var raw []byte
+	var err error
+	if strings.HasPrefix(s, "0x") {
+		raw, err = hexutil.Decode(s)
+	} else {
+		raw, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil || len(raw) == 0 {
+		return nil, false
+	}
+	var failed types.FailedCallResult
+	if err = (DecodeLimits{}).unmarshal(raw, &failed); err == nil && failed.Module != "" {
+		return newCallFailedError(&failed), true
+	}
+	var result types.CallResult
+	if err = (DecodeLimits{}).unmarshal(raw, &result); err == nil && result.Failed != nil && result.Failed.Module != "" {
+		return newCallFailedError(result.Failed), true
+	}
+	return nil, false
+}
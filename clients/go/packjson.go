@@ -0,0 +1,303 @@
+package sapphire
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/oasisprotocol/deoxysii"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+	sdkTypes "github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+// The JSON encoding of signed queries is the one of the TypeScript client's
+// SignedCallDataPack, so that packs built by a web frontend can be posted to
+// a Go backend and back:
+//
+//	{
+//	  "data": {"format": 1, "body": {"pk": "0x…", "nonce": "0x…", "epoch": 3, "data": "0x…"}},
+//	  "leash": {"nonce": 1, "block_number": 2, "block_hash": "0x…", "block_range": 15},
+//	  "signature": "0x…"
+//	}
+//
+// Byte fields are 0x-prefixed hex. Field names are those of the CBOR
+// encoding. The format is omitted for plain calls, whose body is the hex of
+// the calldata. Integers are JSON numbers, or decimal strings beyond 2^53 - 1,
+// the largest integer JavaScript numbers hold exactly; either is accepted.
+
+// maxSafeInteger is JavaScript's Number.MAX_SAFE_INTEGER.
+const maxSafeInteger = 1<<53 - 1
+
+// DataPackJSON is a signed query encoded as JSON like the TypeScript client
+// does, e.g.:
+//
+//	encoded, err := json.Marshal((*sapphire.DataPackJSON)(pack))
+//
+// Decoding ignores unknown fields, like encoding/json; use
+// DecodeDataPackJSON for packs from untrusted sources.
+type DataPackJSON evm.SignedCallDataPack
+
+// CallJSON is the data of a signed query encoded as JSON like the TypeScript
+// client does. Encrypted bodies are not decrypted.
+type CallJSON sdkTypes.Call
+
+// LeashJSON is the leash of a signed query encoded as JSON like the
+// TypeScript client does.
+type LeashJSON evm.Leash
+
+type dataPackJSON struct {
+	Data      *callJSON      `json:"data"`
+	Leash     *leashJSON     `json:"leash"`
+	Signature *hexutil.Bytes `json:"signature"`
+}
+
+type callJSON struct {
+	Format sdkTypes.CallFormat `json:"format,omitempty"`
+	Body   json.RawMessage     `json:"body"`
+}
+
+type encryptedBodyJSON struct {
+	Pk    *hexutil.Bytes `json:"pk"`
+	Nonce *hexutil.Bytes `json:"nonce"`
+	Epoch jsUint64       `json:"epoch,omitempty"`
+	Data  *hexutil.Bytes `json:"data"`
+}
+
+type leashJSON struct {
+	Nonce       *jsUint64      `json:"nonce"`
+	BlockNumber *jsUint64      `json:"block_number"`
+	BlockHash   *hexutil.Bytes `json:"block_hash"`
+	BlockRange  *jsUint64      `json:"block_range"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p DataPackJSON) MarshalJSON() ([]byte, error) {
+	data, err := CallJSON(p.Data).toJSON()
+	if err != nil {
+		return nil, err
+	}
+	signature := hexutil.Bytes(p.Signature)
+	return json.Marshal(dataPackJSON{
+		Data:      data,
+		Leash:     LeashJSON(p.Leash).toJSON(),
+		Signature: &signature,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Like those of CallJSON and
+// LeashJSON, it leaves p as is for null.
+func (p *DataPackJSON) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	pack, err := decodeDataPackJSON(data, false)
+	if err != nil {
+		return err
+	}
+	*p = DataPackJSON(*pack)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c CallJSON) MarshalJSON() ([]byte, error) {
+	call, err := c.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(call)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *CallJSON) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var call callJSON
+	if err := unmarshalJSON(data, &call, false); err != nil {
+		return err
+	}
+	decoded, err := call.fromJSON(false)
+	if err != nil {
+		return err
+	}
+	*c = CallJSON(*decoded)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (l LeashJSON) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.toJSON())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *LeashJSON) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var leash leashJSON
+	if err := unmarshalJSON(data, &leash, false); err != nil {
+		return err
+	}
+	decoded, err := leash.fromJSON()
+	if err != nil {
+		return err
+	}
+	*l = LeashJSON(*decoded)
+	return nil
+}
+
+// DecodeDataPackJSON parses a signed query from its DataPackJSON encoding
+// strictly: unknown fields are rejected, and so is anything DecodeDataPack
+// would reject in the CBOR encoding, with ErrMalformedEnvelope.
+func DecodeDataPackJSON(data []byte) (*evm.SignedCallDataPack, error) {
+	pack, err := decodeDataPackJSON(data, true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedEnvelope, err)
+	}
+	return DecodeDataPack(EncodeDataPack(pack))
+}
+
+// decodeDataPackJSON decodes a DataPackJSON, rejecting unknown fields if
+// strict.
+func decodeDataPackJSON(data []byte, strict bool) (*evm.SignedCallDataPack, error) {
+	var p dataPackJSON
+	if err := unmarshalJSON(data, &p, strict); err != nil {
+		return nil, err
+	}
+	if p.Data == nil || p.Leash == nil || p.Signature == nil {
+		return nil, errors.New("signed query: missing data, leash or signature")
+	}
+	call, err := p.Data.fromJSON(strict)
+	if err != nil {
+		return nil, err
+	}
+	leash, err := p.Leash.fromJSON()
+	if err != nil {
+		return nil, err
+	}
+	return &evm.SignedCallDataPack{Data: *call, Leash: *leash, Signature: *p.Signature}, nil
+}
+
+// unmarshalJSON decodes a single JSON value into v, rejecting unknown fields
+// if strict.
+func unmarshalJSON(data []byte, v interface{}, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("trailing data after JSON value")
+	}
+	return nil
+}
+
+func (c CallJSON) toJSON() (*callJSON, error) {
+	if c.Method != "" || c.ReadOnly {
+		return nil, errors.New("call data: method and flags can't be encoded")
+	}
+	var body interface{}
+	switch c.Format {
+	case sdkTypes.CallFormatPlain:
+		var data []byte
+		if err := cbor.Unmarshal(c.Body, &data); err != nil {
+			return nil, fmt.Errorf("call data: malformed plain body: %w", err)
+		}
+		body = hexutil.Bytes(data)
+	case sdkTypes.CallFormatEncryptedX25519DeoxysII:
+		var envelope sdkTypes.CallEnvelopeX25519DeoxysII
+		if err := cbor.Unmarshal(c.Body, &envelope); err != nil {
+			return nil, fmt.Errorf("call data: malformed encrypted body: %w", err)
+		}
+		pk, nonce, data := hexutil.Bytes(envelope.Pk[:]), hexutil.Bytes(envelope.Nonce[:]), hexutil.Bytes(envelope.Data)
+		body = encryptedBodyJSON{Pk: &pk, Nonce: &nonce, Epoch: jsUint64(envelope.Epoch), Data: &data}
+	default:
+		return nil, fmt.Errorf("call data: unsupported format %d", c.Format)
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &callJSON{Format: c.Format, Body: encoded}, nil
+}
+
+func (c *callJSON) fromJSON(strict bool) (*sdkTypes.Call, error) {
+	if c.Body == nil || bytes.Equal(c.Body, []byte("null")) {
+		return nil, errors.New("call data: missing body")
+	}
+	switch c.Format {
+	case sdkTypes.CallFormatPlain:
+		var data hexutil.Bytes
+		if err := json.Unmarshal(c.Body, &data); err != nil {
+			return nil, fmt.Errorf("call data: malformed plain body: %w", err)
+		}
+		return &sdkTypes.Call{Body: cbor.Marshal([]byte(data))}, nil
+	case sdkTypes.CallFormatEncryptedX25519DeoxysII:
+		var body encryptedBodyJSON
+		if err := unmarshalJSON(c.Body, &body, strict); err != nil {
+			return nil, fmt.Errorf("call data: malformed encrypted body: %w", err)
+		}
+		if body.Pk == nil || body.Nonce == nil || body.Data == nil {
+			return nil, errors.New("call data: missing pk, nonce or data")
+		}
+		var envelope sdkTypes.CallEnvelopeX25519DeoxysII
+		if len(*body.Pk) != len(envelope.Pk) || len(*body.Nonce) != deoxysii.NonceSize {
+			return nil, fmt.Errorf("call data: invalid pk or nonce length %d, %d", len(*body.Pk), len(*body.Nonce))
+		}
+		copy(envelope.Pk[:], *body.Pk)
+		copy(envelope.Nonce[:], *body.Nonce)
+		envelope.Epoch, envelope.Data = uint64(body.Epoch), *body.Data
+		return &sdkTypes.Call{Format: c.Format, Body: cbor.Marshal(envelope)}, nil
+	default:
+		return nil, fmt.Errorf("call data: unsupported format %d", c.Format)
+	}
+}
+
+func (l LeashJSON) toJSON() *leashJSON {
+	nonce, blockNumber, blockRange := jsUint64(l.Nonce), jsUint64(l.BlockNumber), jsUint64(l.BlockRange)
+	blockHash := hexutil.Bytes(l.BlockHash)
+	return &leashJSON{Nonce: &nonce, BlockNumber: &blockNumber, BlockHash: &blockHash, BlockRange: &blockRange}
+}
+
+func (l *leashJSON) fromJSON() (*evm.Leash, error) {
+	if l.Nonce == nil || l.BlockNumber == nil || l.BlockHash == nil || l.BlockRange == nil {
+		return nil, errors.New("leash: missing nonce, block_number, block_hash or block_range")
+	}
+	return &evm.Leash{
+		Nonce:       uint64(*l.Nonce),
+		BlockNumber: uint64(*l.BlockNumber),
+		BlockHash:   *l.BlockHash,
+		BlockRange:  uint64(*l.BlockRange),
+	}, nil
+}
+
+// jsUint64 is an integer encoded as a JSON number while JavaScript numbers
+// hold it exactly, and as a decimal string beyond.
+type jsUint64 uint64
+
+func (n jsUint64) MarshalJSON() ([]byte, error) {
+	s := strconv.FormatUint(uint64(n), 10)
+	if n > maxSafeInteger {
+		s = strconv.Quote(s)
+	}
+	return []byte(s), nil
+}
+
+func (n *jsUint64) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s", data)
+	}
+	*n = jsUint64(v)
+	return nil
+}
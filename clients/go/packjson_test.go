@@ -0,0 +1,183 @@
+package sapphire
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/evm"
+)
+
+// testDataPacks returns a plain and an encrypted signed query.
+func testDataPacks(t testing.TB) []*evm.SignedCallDataPack {
+	leash := evm.Leash{Nonce: 3, BlockNumber: 50, BlockHash: common.HexToHash("0x01").Bytes(), BlockRange: DefaultBlockRange}
+	plain, err := SignedCallWithSignature([]byte{1, 2, 3}, leash, append(bytes.Repeat([]byte{1}, 64), 27))
+	if err != nil {
+		t.Fatalf("failed to assemble pack: %v", err)
+	}
+	pair := Curve25519KeyPair{}
+	cipher, err := NewX25519DeoxysIICipher(&pair, &pair.PublicKey, 7)
+	if err != nil {
+		t.Fatalf("could not init deoxysii cipher: %v", err)
+	}
+	encrypted := *plain
+	encrypted.Data = *cipher.EncryptEnvelope([]byte{1, 2, 3})
+	return []*evm.SignedCallDataPack{plain, &encrypted}
+}
+
+func TestDataPackJSON(t *testing.T) {
+	packs := testDataPacks(t)
+
+	encoded, err := json.Marshal((*DataPackJSON)(packs[0]))
+	if err != nil {
+		t.Fatalf("failed to encode pack: %v", err)
+	}
+	expected := `{"data":{"body":"0x010203"},"leash":{"nonce":3,"block_number":50,"block_hash":"0x0000000000000000000000000000000000000000000000000000000000000001","block_range":15},"signature":"0x` + strings.Repeat("01", 64) + `1b"}`
+	if string(encoded) != expected {
+		t.Fatalf("pack encoded as %s, expected %s", encoded, expected)
+	}
+
+	for _, pack := range packs {
+		encoded, err := json.Marshal((*DataPackJSON)(pack))
+		if err != nil {
+			t.Fatalf("failed to encode pack: %v", err)
+		}
+		var decoded DataPackJSON
+		if err = json.Unmarshal(encoded, &decoded); err != nil || !reflect.DeepEqual((*evm.SignedCallDataPack)(&decoded), pack) {
+			t.Fatalf("pack %s decoded to %+v: %v", encoded, decoded, err)
+		}
+		strict, err := DecodeDataPackJSON(encoded)
+		if err != nil || !reflect.DeepEqual(strict, pack) {
+			t.Fatalf("pack %s strictly decoded to %+v: %v", encoded, strict, err)
+		}
+	}
+
+	// The encrypted body is an object, with the epoch as a number.
+	encoded, _ = json.Marshal((*DataPackJSON)(packs[1]))
+	var raw struct {
+		Data struct {
+			Format int                        `json:"format"`
+			Body   map[string]json.RawMessage `json:"body"`
+		} `json:"data"`
+	}
+	if err = json.Unmarshal(encoded, &raw); err != nil || raw.Data.Format != 1 || string(raw.Data.Body["epoch"]) != "7" || len(raw.Data.Body["pk"]) != 68 || len(raw.Data.Body["nonce"]) != 34 {
+		t.Fatalf("unexpected encrypted pack %s: %v", encoded, err)
+	}
+}
+
+func TestDataPackJSONLargeIntegers(t *testing.T) {
+	leash := LeashJSON{Nonce: maxSafeInteger, BlockNumber: maxSafeInteger + 1, BlockHash: []byte{1}, BlockRange: math.MaxUint64}
+	encoded, err := json.Marshal(leash)
+	if err != nil {
+		t.Fatalf("failed to encode leash: %v", err)
+	}
+	// Integers beyond what JavaScript numbers hold exactly are strings.
+	expected := `{"nonce":9007199254740991,"block_number":"9007199254740992","block_hash":"0x01","block_range":"18446744073709551615"}`
+	if string(encoded) != expected {
+		t.Fatalf("leash encoded as %s, expected %s", encoded, expected)
+	}
+	var decoded LeashJSON
+	if err = json.Unmarshal(encoded, &decoded); err != nil || !reflect.DeepEqual(decoded, leash) {
+		t.Fatalf("leash decoded to %+v: %v", decoded, err)
+	}
+
+	// Either form is accepted.
+	if err = json.Unmarshal([]byte(`{"nonce":"3","block_number":50,"block_hash":"0x01","block_range":"15"}`), &decoded); err != nil || decoded.Nonce != 3 || decoded.BlockRange != 15 {
+		t.Fatalf("leash with numeric strings decoded to %+v: %v", decoded, err)
+	}
+	for _, n := range []string{`-1`, `1.5`, `"0x1"`, `"18446744073709551616"`, `null`, `true`} {
+		if err = json.Unmarshal([]byte(`{"nonce":`+n+`,"block_number":50,"block_hash":"0x01","block_range":15}`), &decoded); err == nil {
+			t.Fatalf("expected nonce %s to be rejected", n)
+		}
+	}
+}
+
+func TestDecodeDataPackJSONStrict(t *testing.T) {
+	pack := testDataPacks(t)[1]
+	encoded, _ := json.Marshal((*DataPackJSON)(pack))
+
+	// edited returns the encoding of pack with the JSON object at path
+	// changed by edit.
+	edited := func(edit func(obj map[string]interface{}), path ...string) []byte {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(encoded, &obj); err != nil {
+			t.Fatalf("failed to decode pack: %v", err)
+		}
+		target := obj
+		for _, key := range path {
+			target = target[key].(map[string]interface{})
+		}
+		edit(target)
+		out, err := json.Marshal(obj)
+		if err != nil {
+			t.Fatalf("failed to encode pack: %v", err)
+		}
+		return out
+	}
+	unknown := func(obj map[string]interface{}) { obj["extra"] = 1 }
+
+	for _, tc := range []struct {
+		name    string
+		data    []byte
+		lenient bool
+	}{
+		{"unknown field", edited(unknown), true},
+		{"unknown data field", edited(unknown, "data"), true},
+		{"unknown body field", edited(unknown, "data", "body"), true},
+		{"unknown leash field", edited(unknown, "leash"), true},
+		{"trailing data", append(append([]byte(nil), encoded...), " {}"...), false},
+		{"missing signature", edited(func(obj map[string]interface{}) { delete(obj, "signature") }), false},
+		{"missing leash", edited(func(obj map[string]interface{}) { delete(obj, "leash") }), false},
+		{"missing body", edited(func(obj map[string]interface{}) { obj["body"] = nil }, "data"), false},
+		{"missing block hash", edited(func(obj map[string]interface{}) { delete(obj, "block_hash") }, "leash"), false},
+		{"missing nonce", edited(func(obj map[string]interface{}) { delete(obj, "nonce") }, "data", "body"), false},
+		{"short nonce", edited(func(obj map[string]interface{}) { obj["nonce"] = "0x01" }, "data", "body"), false},
+		{"unknown format", edited(func(obj map[string]interface{}) { obj["format"] = 9 }, "data"), false},
+		{"plain body object", edited(func(obj map[string]interface{}) { obj["format"] = 0 }, "data"), false},
+		{"base64 signature", edited(func(obj map[string]interface{}) { obj["signature"] = "AQID" }), false},
+		{"short signature", edited(func(obj map[string]interface{}) { obj["signature"] = "0x01" }), true},
+		{"short block hash", edited(func(obj map[string]interface{}) { obj["block_hash"] = "0x01" }, "leash"), true},
+	} {
+		if _, err := DecodeDataPackJSON(tc.data); !errors.Is(err, ErrMalformedEnvelope) {
+			t.Fatalf("%s: expected ErrMalformedEnvelope, got %v", tc.name, err)
+		}
+		var decoded DataPackJSON
+		if err := json.Unmarshal(tc.data, &decoded); (err == nil) != tc.lenient {
+			t.Fatalf("%s: expected decoding to succeed: %t, got %v", tc.name, tc.lenient, err)
+		}
+	}
+}
+
+// FuzzDecodeDataPackJSON fuzzes the strict decoding of JSON signed queries:
+// whatever decodes encodes to JSON that decodes the same.
+func FuzzDecodeDataPackJSON(f *testing.F) {
+	for _, pack := range testDataPacks(f) {
+		encoded, err := json.Marshal((*DataPackJSON)(pack))
+		if err != nil {
+			f.Fatalf("failed to encode pack: %v", err)
+		}
+		f.Add(encoded)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pack, err := DecodeDataPackJSON(data)
+		if err != nil {
+			if !errors.Is(err, ErrMalformedEnvelope) {
+				t.Fatalf("expected ErrMalformedEnvelope, got %v", err)
+			}
+			return
+		}
+		encoded, err := json.Marshal((*DataPackJSON)(pack))
+		if err != nil {
+			t.Fatalf("failed to encode %+v: %v", pack, err)
+		}
+		if again, err := DecodeDataPackJSON(encoded); err != nil || !reflect.DeepEqual(again, pack) {
+			t.Fatalf("expected %s to decode to %+v, got %+v, %v", encoded, pack, again, err)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package precompiles
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Precompile identifies one of Sapphire's precompiled contracts.
+type Precompile uint8
+
+const (
+	RandomBytesPrecompile Precompile = iota + 1
+	X25519DerivePrecompile
+	DeoxysiiSealPrecompile
+	DeoxysiiOpenPrecompile
+	GenerateSigningKeyPairPrecompile
+	SignPrecompile
+	VerifyPrecompile
+	Curve25519PublicKeyPrecompile
+	GasUsedPrecompile
+	PadGasPrecompile
+	SHA512_256Precompile
+	SHA512Precompile
+	SubcallPrecompile
+	SHA384Precompile
+)
+
+var precompileNames = map[Precompile]string{
+	RandomBytesPrecompile:            "RandomBytes",
+	X25519DerivePrecompile:           "X25519Derive",
+	DeoxysiiSealPrecompile:           "DeoxysiiSeal",
+	DeoxysiiOpenPrecompile:           "DeoxysiiOpen",
+	GenerateSigningKeyPairPrecompile: "GenerateSigningKeyPair",
+	SignPrecompile:                   "Sign",
+	VerifyPrecompile:                 "Verify",
+	Curve25519PublicKeyPrecompile:    "Curve25519PublicKey",
+	GasUsedPrecompile:                "GasUsed",
+	PadGasPrecompile:                 "PadGas",
+	SHA512_256Precompile:             "SHA512_256",
+	SHA512Precompile:                 "SHA512",
+	SubcallPrecompile:                "Subcall",
+	SHA384Precompile:                 "SHA384",
+}
+
+func (p Precompile) String() string {
+	if name, ok := precompileNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Precompile(%d)", uint8(p))
+}
+
+// Addresses are the addresses of the precompiles.
+var Addresses = map[Precompile]common.Address{
+	RandomBytesPrecompile:            common.HexToAddress("0x0100000000000000000000000000000000000001"),
+	X25519DerivePrecompile:           common.HexToAddress("0x0100000000000000000000000000000000000002"),
+	DeoxysiiSealPrecompile:           common.HexToAddress("0x0100000000000000000000000000000000000003"),
+	DeoxysiiOpenPrecompile:           common.HexToAddress("0x0100000000000000000000000000000000000004"),
+	GenerateSigningKeyPairPrecompile: common.HexToAddress("0x0100000000000000000000000000000000000005"),
+	SignPrecompile:                   common.HexToAddress("0x0100000000000000000000000000000000000006"),
+	VerifyPrecompile:                 common.HexToAddress("0x0100000000000000000000000000000000000007"),
+	Curve25519PublicKeyPrecompile:    common.HexToAddress("0x0100000000000000000000000000000000000008"),
+	GasUsedPrecompile:                common.HexToAddress("0x0100000000000000000000000000000000000009"),
+	PadGasPrecompile:                 common.HexToAddress("0x010000000000000000000000000000000000000a"),
+	SHA512_256Precompile:             common.HexToAddress("0x0100000000000000000000000000000000000101"),
+	SHA512Precompile:                 common.HexToAddress("0x0100000000000000000000000000000000000102"),
+	SubcallPrecompile:                common.HexToAddress("0x0100000000000000000000000000000000000103"),
+	SHA384Precompile:                 common.HexToAddress("0x0100000000000000000000000000000000000104"),
+}
+
+// GasCost is the documented gas cost of a precompile.
+type GasCost struct {
+	// Base is the cost of a call with no input.
+	Base uint64
+	// Word is the cost per 32-byte word of input, or of output for
+	// RandomBytes.
+	Word uint64
+}
+
+// Gas returns the gas cost of a call with n words' worth of bytes.
+func (c GasCost) Gas(n int) uint64 {
+	return c.Base + c.Word*words(uint64(n))
+}
+
+// GasCosts are the gas costs of the precompiles with a fixed base and word
+// cost. The costs of the signing precompiles depend on the method, see
+// GenerateSigningKeyPairGas, SignGas and VerifyGas, and RandomBytes also
+// charges for its personalization string, see RandomBytesGas.
+var GasCosts = map[Precompile]GasCost{
+	RandomBytesPrecompile:  {Base: RandomBytesBaseGas, Word: RandomBytesWordGas},
+	X25519DerivePrecompile: {Base: X25519DeriveGas},
+	DeoxysiiSealPrecompile: {Base: DeoxysiiBaseGas, Word: DeoxysiiWordGas},
+	DeoxysiiOpenPrecompile: {Base: DeoxysiiBaseGas, Word: DeoxysiiWordGas},
+	SHA512_256Precompile:   {Base: 115, Word: 13},
+	SHA512Precompile:       {Base: 115, Word: 13},
+	SHA384Precompile:       {Base: 115, Word: 13},
+}
+
+var (
+	// RandomBytesAddress is the address of the RandomBytes precompile.
+	RandomBytesAddress = Addresses[RandomBytesPrecompile]
+	// X25519DeriveAddress is the address of the X25519 key derivation
+	// precompile.
+	X25519DeriveAddress = Addresses[X25519DerivePrecompile]
+	// DeoxysiiSealAddress is the address of the Deoxys-II encryption
+	// precompile.
+	DeoxysiiSealAddress = Addresses[DeoxysiiSealPrecompile]
+	// DeoxysiiOpenAddress is the address of the Deoxys-II decryption
+	// precompile.
+	DeoxysiiOpenAddress = Addresses[DeoxysiiOpenPrecompile]
+	// GenerateSigningKeyPairAddress is the address of the signing key pair
+	// generation precompile.
+	GenerateSigningKeyPairAddress = Addresses[GenerateSigningKeyPairPrecompile]
+	// SignAddress is the address of the signing precompile.
+	SignAddress = Addresses[SignPrecompile]
+	// VerifyAddress is the address of the signature verification precompile.
+	VerifyAddress = Addresses[VerifyPrecompile]
+	// Curve25519PublicKeyAddress is the address of the Curve25519 public key
+	// precompile.
+	Curve25519PublicKeyAddress = Addresses[Curve25519PublicKeyPrecompile]
+	// GasUsedAddress is the address of the precompile returning the gas used
+	// so far.
+	GasUsedAddress = Addresses[GasUsedPrecompile]
+	// PadGasAddress is the address of the gas padding precompile.
+	PadGasAddress = Addresses[PadGasPrecompile]
+	// SHA512_256Address is the address of the SHA-512/256 precompile.
+	SHA512_256Address = Addresses[SHA512_256Precompile]
+	// SHA512Address is the address of the SHA-512 precompile.
+	SHA512Address = Addresses[SHA512Precompile]
+	// SubcallAddress is the address of the subcall precompile, which calls
+	// methods of the runtime's modules.
+	SubcallAddress = Addresses[SubcallPrecompile]
+	// SHA384Address is the address of the SHA-384 precompile.
+	SHA384Address = Addresses[SHA384Precompile]
+)
@@ -0,0 +1,64 @@
+package precompiles
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles/local"
+)
+
+const (
+	// DeoxysiiBaseGas is the minimum gas cost of DeoxysiiSeal and
+	// DeoxysiiOpen.
+	DeoxysiiBaseGas = 50_000
+	// DeoxysiiWordGas is the gas cost of DeoxysiiSeal and DeoxysiiOpen per
+	// 32-byte word of input.
+	DeoxysiiWordGas = 100
+)
+
+var deoxysiiArgs = abi.Arguments{{Type: bytes32Type}, {Type: bytes32Type}, {Type: bytesType}, {Type: bytesType}}
+
+// DeoxysiiSeal encrypts and authenticates plaintext and ad with the Deoxys-II
+// encryption precompile, like Sapphire.encrypt. The ciphertext has the tag
+// appended.
+//
+// key must be 32 bytes. Only the first deoxysii.NonceSize bytes of nonce are
+// used, as by the precompile, so it may be up to 32 bytes long.
+func DeoxysiiSeal(ctx context.Context, caller bind.ContractCaller, key, nonce, plaintext, ad []byte) ([]byte, error) {
+	return callDeoxysii(ctx, caller, DeoxysiiSealPrecompile, key, nonce, plaintext, ad)
+}
+
+// DeoxysiiOpen decrypts and authenticates ciphertext and ad with the
+// Deoxys-II decryption precompile, like Sapphire.decrypt. The call fails if
+// the tag is incorrect.
+func DeoxysiiOpen(ctx context.Context, caller bind.ContractCaller, key, nonce, ciphertext, ad []byte) ([]byte, error) {
+	return callDeoxysii(ctx, caller, DeoxysiiOpenPrecompile, key, nonce, ciphertext, ad)
+}
+
+// DeoxysiiSealLocal encrypts like DeoxysiiSeal, without calling the
+// precompile. It is local.DeoxysiiSeal.
+func DeoxysiiSealLocal(key, nonce, plaintext, ad []byte) ([]byte, error) {
+	return local.DeoxysiiSeal(key, nonce, plaintext, ad)
+}
+
+// DeoxysiiOpenLocal decrypts like DeoxysiiOpen, without calling the
+// precompile. It is local.DeoxysiiOpen.
+func DeoxysiiOpenLocal(key, nonce, ciphertext, ad []byte) ([]byte, error) {
+	return local.DeoxysiiOpen(key, nonce, ciphertext, ad)
+}
+
+func callDeoxysii(ctx context.Context, caller bind.ContractCaller, p Precompile, key, nonce, text, ad []byte) ([]byte, error) {
+	if err := local.CheckDeoxysiiArgs(key, nonce); err != nil {
+		return nil, err
+	}
+	var k, n [32]byte
+	copy(k[:], key)
+	copy(n[:], nonce)
+	input, err := deoxysiiArgs.Pack(k, n, text, ad)
+	if err != nil {
+		return nil, err
+	}
+	return call(ctx, caller, p, input)
+}
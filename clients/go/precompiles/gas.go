@@ -0,0 +1,40 @@
+package precompiles
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+var uint128Type, _ = abi.NewType("uint128", "", nil)
+
+// GasUsed returns the gas used by a call to the gas used precompile, like
+// Sapphire.gasUsed does within a transaction.
+func GasUsed(ctx context.Context, caller bind.ContractCaller) (uint64, error) {
+	out, err := call(ctx, caller, GasUsedPrecompile, nil)
+	if err != nil {
+		return 0, err
+	}
+	values, err := abi.Arguments{{Type: uint64Type}}.Unpack(out)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode precompile output: %w", err)
+	}
+	return values[0].(uint64), nil
+}
+
+// PadGasData returns the input with which contracts pad their gas use to
+// toAmount with the gas padding precompile, like Sapphire.padGas.
+func PadGasData(toAmount uint64) []byte {
+	input, _ := abi.Arguments{{Type: uint128Type}}.Pack(new(big.Int).SetUint64(toAmount))
+	return input
+}
+
+// PadGas calls the gas padding precompile, which fails if more than toAmount
+// gas was used already.
+func PadGas(ctx context.Context, caller bind.ContractCaller, toAmount uint64) error {
+	_, err := call(ctx, caller, PadGasPrecompile, PadGasData(toAmount))
+	return err
+}
@@ -0,0 +1,69 @@
+package precompiles
+
+import (
+	"context"
+	"crypto/sha512"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles/local"
+)
+
+// Sum512_256 hashes data with the SHA-512/256 precompile, like
+// Sapphire's sha512_256. Unlike SHA-512, SHA-512/256 is not vulnerable to
+// length extension.
+func Sum512_256(ctx context.Context, caller bind.ContractCaller, data []byte) ([32]byte, error) {
+	out, err := hash(ctx, caller, SHA512_256Precompile, data, sha512.Size256)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return [32]byte(out), nil
+}
+
+// Sum512 hashes data with the SHA-512 precompile, like Sapphire's sha512.
+func Sum512(ctx context.Context, caller bind.ContractCaller, data []byte) ([64]byte, error) {
+	out, err := hash(ctx, caller, SHA512Precompile, data, sha512.Size)
+	if err != nil {
+		return [64]byte{}, err
+	}
+	return [64]byte(out), nil
+}
+
+// Sum384 hashes data with the SHA-384 precompile, like Sapphire's sha384.
+func Sum384(ctx context.Context, caller bind.ContractCaller, data []byte) ([48]byte, error) {
+	out, err := hash(ctx, caller, SHA384Precompile, data, sha512.Size384)
+	if err != nil {
+		return [48]byte{}, err
+	}
+	return [48]byte(out), nil
+}
+
+// Sum512_256Local computes the same digest as Sum512_256, without calling
+// the precompile.
+func Sum512_256Local(data []byte) [32]byte {
+	return local.Sum512_256(data)
+}
+
+// Sum512Local computes the same digest as Sum512, without calling the
+// precompile.
+func Sum512Local(data []byte) [64]byte {
+	return local.Sum512(data)
+}
+
+// Sum384Local computes the same digest as Sum384, without calling the
+// precompile.
+func Sum384Local(data []byte) [48]byte {
+	return local.Sum384(data)
+}
+
+func hash(ctx context.Context, caller bind.ContractCaller, p Precompile, data []byte, size int) ([]byte, error) {
+	out, err := call(ctx, caller, p, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != size {
+		return nil, fmt.Errorf("precompile returned a %d-byte digest, not %d", len(out), size)
+	}
+	return out, nil
+}
@@ -0,0 +1,113 @@
+package precompiles
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles/local"
+)
+
+// SigningMethod is a signature scheme of the signing precompiles, the
+// Sapphire.SigningAlg enum.
+type SigningMethod = local.SigningMethod
+
+// The signing methods, see the local package.
+const (
+	Ed25519Oasis                = local.Ed25519Oasis
+	Ed25519Pure                 = local.Ed25519Pure
+	Ed25519PrehashedSha512      = local.Ed25519PrehashedSha512
+	Secp256k1Oasis              = local.Secp256k1Oasis
+	Secp256k1PrehashedKeccak256 = local.Secp256k1PrehashedKeccak256
+	Secp256k1PrehashedSha256    = local.Secp256k1PrehashedSha256
+	Sr25519                     = local.Sr25519
+	Secp256r1PrehashedSha256    = local.Secp256r1PrehashedSha256
+	Secp384r1PrehashedSha384    = local.Secp384r1PrehashedSha384
+)
+
+// GenerateSigningKeyPairGas returns the gas cost of GenerateSigningKeyPair
+// for method.
+func GenerateSigningKeyPairGas(method SigningMethod) uint64 {
+	switch method {
+	case Ed25519Oasis, Ed25519Pure, Ed25519PrehashedSha512:
+		return 1_000
+	case Secp256k1Oasis, Secp256k1PrehashedKeccak256, Secp256k1PrehashedSha256:
+		return 1_500
+	case Secp256r1PrehashedSha256:
+		return 4_000
+	case Secp384r1PrehashedSha384:
+		return 18_000
+	default:
+		return 0
+	}
+}
+
+// SigningKeyPair is a key pair of the signing precompiles.
+type SigningKeyPair struct {
+	Method SigningMethod
+	// PublicKey is 32 bytes for Ed25519 methods and compressed, i.e. 33 or
+	// 49 bytes, for ECDSA methods.
+	PublicKey []byte
+	// SecretKey is the seed the key pair was generated from.
+	SecretKey []byte
+}
+
+var keyPairOutput = abi.Arguments{{Type: bytesType}, {Type: bytesType}}
+
+// Curve25519PublicKey computes the public key of the Curve25519 secret key
+// with the Curve25519 public key precompile.
+func Curve25519PublicKey(ctx context.Context, caller bind.ContractCaller, secretKey [32]byte) ([32]byte, error) {
+	out, err := call(ctx, caller, Curve25519PublicKeyPrecompile, secretKey[:])
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if len(out) != 32 {
+		return [32]byte{}, fmt.Errorf("precompile returned a %d-byte public key", len(out))
+	}
+	return [32]byte(out), nil
+}
+
+// Curve25519PublicKeyLocal computes the same public key as
+// Curve25519PublicKey, without calling the precompile. It is
+// local.Curve25519PublicKey.
+func Curve25519PublicKeyLocal(secretKey [32]byte) [32]byte {
+	return local.Curve25519PublicKey(secretKey)
+}
+
+// GenerateSigningKeyPair generates the key pair of method from seed with the
+// key pair generation precompile, like Sapphire.generateSigningKeyPair. The
+// methods of a curve generate the same key pairs.
+//
+// seed must be method.SeedSize() bytes.
+func GenerateSigningKeyPair(ctx context.Context, caller bind.ContractCaller, method SigningMethod, seed []byte) (*SigningKeyPair, error) {
+	if err := method.CheckSeed(seed); err != nil {
+		return nil, err
+	}
+	input, err := abi.Arguments{{Type: uint256Type}, {Type: bytesType}}.Pack(new(big.Int).SetUint64(uint64(method)), seed)
+	if err != nil {
+		return nil, err
+	}
+	out, err := call(ctx, caller, GenerateSigningKeyPairPrecompile, input)
+	if err != nil {
+		return nil, err
+	}
+	values, err := keyPairOutput.Unpack(out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode precompile output: %w", err)
+	}
+	return &SigningKeyPair{Method: method, PublicKey: values[0].([]byte), SecretKey: values[1].([]byte)}, nil
+}
+
+// GenerateSigningKeyPairLocal generates the same key pair as
+// GenerateSigningKeyPair, without calling the precompile, with
+// local.GenerateSigningKeyPair.
+func GenerateSigningKeyPairLocal(method SigningMethod, seed []byte) (*SigningKeyPair, error) {
+	pk, err := local.GenerateSigningKeyPair(method, seed)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKeyPair{Method: method, PublicKey: pk, SecretKey: append([]byte(nil), seed...)}, nil
+}
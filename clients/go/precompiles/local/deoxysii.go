@@ -0,0 +1,48 @@
+package local
+
+import (
+	"fmt"
+
+	"github.com/oasisprotocol/deoxysii"
+)
+
+// DeoxysiiSeal encrypts and authenticates plaintext and ad, like the
+// Deoxys-II encryption precompile. The ciphertext has the tag appended.
+//
+// key must be 32 bytes. Only the first deoxysii.NonceSize bytes of nonce are
+// used, as by the precompile, so it may be up to 32 bytes long.
+func DeoxysiiSeal(key, nonce, plaintext, ad []byte) ([]byte, error) {
+	if err := CheckDeoxysiiArgs(key, nonce); err != nil {
+		return nil, err
+	}
+	aead, err := deoxysii.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, nonce[:deoxysii.NonceSize], plaintext, ad), nil
+}
+
+// DeoxysiiOpen decrypts and authenticates ciphertext and ad, like the
+// Deoxys-II decryption precompile.
+func DeoxysiiOpen(key, nonce, ciphertext, ad []byte) ([]byte, error) {
+	if err := CheckDeoxysiiArgs(key, nonce); err != nil {
+		return nil, err
+	}
+	aead, err := deoxysii.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, nonce[:deoxysii.NonceSize], ciphertext, ad)
+}
+
+// CheckDeoxysiiArgs returns an error if the Deoxys-II precompiles would
+// reject key or ignore part of nonce that could matter.
+func CheckDeoxysiiArgs(key, nonce []byte) error {
+	if len(key) != deoxysii.KeySize {
+		return fmt.Errorf("%w: key is %d bytes, not %d", ErrInvalidInput, len(key), deoxysii.KeySize)
+	}
+	if len(nonce) < deoxysii.NonceSize || len(nonce) > 32 {
+		return fmt.Errorf("%w: nonce is %d bytes, not %d to 32", ErrInvalidInput, len(nonce), deoxysii.NonceSize)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package local
+
+import "crypto/sha512"
+
+// Sum512_256 computes the digest of data like the SHA-512/256 precompile.
+func Sum512_256(data []byte) [32]byte {
+	return sha512.Sum512_256(data)
+}
+
+// Sum512 computes the digest of data like the SHA-512 precompile.
+func Sum512(data []byte) [64]byte {
+	return sha512.Sum512(data)
+}
+
+// Sum384 computes the digest of data like the SHA-384 precompile.
+func Sum384(data []byte) [48]byte {
+	return sha512.Sum384(data)
+}
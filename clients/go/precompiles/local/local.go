@@ -0,0 +1,12 @@
+// Package local computes what Sapphire's deterministic precompiles do,
+// without calling them, e.g. to simulate contracts in tests or to check values
+// they computed.
+//
+// The precompiles package calls the precompiles themselves. Its Local helpers
+// use this package.
+package local
+
+import "errors"
+
+// ErrInvalidInput is returned for arguments the precompile would reject.
+var ErrInvalidInput = errors.New("invalid precompile input")
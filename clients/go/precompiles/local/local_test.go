@@ -0,0 +1,184 @@
+package local_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/client"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles/local"
+)
+
+func TestKnownAnswers(t *testing.T) {
+	// RFC 7748, section 6.1.
+	skA := [32]byte(common.FromHex("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"))
+	skB := [32]byte(common.FromHex("5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb"))
+	pkA, pkB := local.Curve25519PublicKey(skA), local.Curve25519PublicKey(skB)
+	if pkA != [32]byte(common.FromHex("8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a")) {
+		t.Fatalf("unexpected Curve25519 public key %x", pkA)
+	}
+	if local.X25519Derive(pkB, skA) != local.X25519Derive(pkA, skB) {
+		t.Fatalf("derived keys differ between the peers")
+	}
+
+	key := local.X25519Derive(pkB, skA)
+	sealed, err := local.DeoxysiiSeal(key[:], make([]byte, 32), []byte("plaintext"), []byte("ad"))
+	if err != nil {
+		t.Fatalf("DeoxysiiSeal failed: %v", err)
+	}
+	if opened, err := local.DeoxysiiOpen(key[:], make([]byte, 15), sealed, []byte("ad")); err != nil || string(opened) != "plaintext" {
+		t.Fatalf("DeoxysiiOpen failed: %q, %v", opened, err)
+	}
+	if _, err = local.DeoxysiiSeal(key[:16], make([]byte, 15), nil, nil); !errors.Is(err, local.ErrInvalidInput) {
+		t.Fatalf("expected a short key to be rejected, got %v", err)
+	}
+
+	// RFC 8032, section 7.1, test 1.
+	pk, err := local.GenerateSigningKeyPair(local.Ed25519Pure, common.FromHex("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"))
+	if err != nil || !bytes.Equal(pk, common.FromHex("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")) {
+		t.Fatalf("unexpected Ed25519 public key %x (%v)", pk, err)
+	}
+	sig := common.FromHex("e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b")
+	if ok, err := local.Verify(local.Ed25519Pure, pk, nil, nil, sig); !ok || err != nil {
+		t.Fatalf("expected the RFC 8032 signature to verify: %v", err)
+	}
+
+	// FIPS 180-4 examples.
+	if d := local.Sum512_256([]byte("abc")); d != [32]byte(common.FromHex("53048e2681941ef99b2e29b76b4c7dabe4c2d0c634fc6d46e0e2f13107e7af23")) {
+		t.Fatalf("unexpected SHA-512/256 digest %x", d)
+	}
+	if d := local.Sum384([]byte("abc")); d != [48]byte(common.FromHex("cb00753f45a35e8bb5a03d699ac65007272c32ab0eded1631a8b605a43ff5bed8086072ba1e7cc2358baeca134c825a7")) {
+		t.Fatalf("unexpected SHA-384 digest %x", d)
+	}
+	if d := local.Sum512([]byte("abc")); d != [64]byte(common.FromHex("ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f")) {
+		t.Fatalf("unexpected SHA-512 digest %x", d)
+	}
+}
+
+// localnet returns a wrapped client for the localnet, skipping the test
+// unless integration tests are enabled.
+func localnet(t *testing.T) (context.Context, *client.WrappedBackend) {
+	eth := testenv.Start(t).Dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+	b, err := client.WrapClient(eth, nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	return ctx, b
+}
+
+// TestParityLocalnet checks every function of the package against the
+// precompile it mirrors.
+func TestParityLocalnet(t *testing.T) {
+	ctx, b := localnet(t)
+	var skA, skB [32]byte
+	skA[0], skB[0] = 1, 2
+	pkB := local.Curve25519PublicKey(skB)
+
+	t.Run("Curve25519PublicKey", func(t *testing.T) {
+		pk, err := precompiles.Curve25519PublicKey(ctx, b, skB)
+		if err != nil || pk != pkB {
+			t.Fatalf("precompile computed %x, locally %x (%v)", pk, pkB, err)
+		}
+	})
+
+	t.Run("X25519Derive", func(t *testing.T) {
+		key, err := precompiles.X25519Derive(ctx, b, pkB, skA)
+		if err != nil || key != local.X25519Derive(pkB, skA) {
+			t.Fatalf("precompile derived %x, locally %x (%v)", key, local.X25519Derive(pkB, skA), err)
+		}
+	})
+
+	t.Run("Deoxysii", func(t *testing.T) {
+		key := bytes.Repeat([]byte{1}, 32)
+		nonce := bytes.Repeat([]byte{2}, 32)
+		plaintext := bytes.Repeat([]byte("plaintext"), 10)
+		sealed, err := precompiles.DeoxysiiSeal(ctx, b, key, nonce, plaintext, []byte("ad"))
+		if err != nil {
+			t.Fatalf("DeoxysiiSeal failed: %v", err)
+		}
+		if want, _ := local.DeoxysiiSeal(key, nonce, plaintext, []byte("ad")); !bytes.Equal(sealed, want) {
+			t.Fatalf("precompile sealed %x, locally %x", sealed, want)
+		}
+		opened, err := precompiles.DeoxysiiOpen(ctx, b, key, nonce, sealed, []byte("ad"))
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Fatalf("DeoxysiiOpen failed: %x, %v", opened, err)
+		}
+		if _, err = precompiles.DeoxysiiOpen(ctx, b, key, nonce, sealed, nil); err == nil {
+			t.Fatalf("expected the wrong additional data to fail")
+		}
+	})
+
+	for method := local.Ed25519Oasis; method <= local.Secp384r1PrehashedSha384; method++ {
+		if method == local.Sr25519 {
+			continue
+		}
+		t.Run(method.String(), func(t *testing.T) {
+			seed := bytes.Repeat([]byte{byte(method) + 1}, method.SeedSize())
+			kp, err := precompiles.GenerateSigningKeyPair(ctx, b, method, seed)
+			if err != nil {
+				t.Fatalf("GenerateSigningKeyPair failed: %v", err)
+			}
+			pk, err := local.GenerateSigningKeyPair(method, seed)
+			if err != nil || !bytes.Equal(kp.PublicKey, pk) || !bytes.Equal(kp.SecretKey, seed) {
+				t.Fatalf("precompile generated %x, locally %x (%v)", kp.PublicKey, pk, err)
+			}
+
+			contextOrHash, message := []byte("test context"), []byte("signed message")
+			if method == local.Ed25519Pure {
+				contextOrHash = nil
+			} else if size := method.DigestSize(); size != 0 {
+				contextOrHash, message = bytes.Repeat([]byte{0xab}, size), nil
+			}
+			sig, err := precompiles.Sign(ctx, b, method, kp.SecretKey, contextOrHash, message)
+			if err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+			if ok, err := local.Verify(method, pk, contextOrHash, message, sig); !ok || err != nil {
+				t.Fatalf("signature made on-chain doesn't verify locally: %v", err)
+			}
+			if ok, err := precompiles.Verify(ctx, b, method, pk, contextOrHash, message, sig); !ok || err != nil {
+				t.Fatalf("signature doesn't verify on-chain: %v", err)
+			}
+		})
+	}
+}
+
+// FuzzParityLocalnet compares the hash and Deoxys-II functions with their
+// precompiles for random inputs.
+func FuzzParityLocalnet(f *testing.F) {
+	f.Add([]byte{}, []byte{})
+	f.Add([]byte("abc"), []byte("ad"))
+	f.Add(bytes.Repeat([]byte{0xff}, 129), []byte{0})
+	f.Fuzz(func(t *testing.T, data, ad []byte) {
+		ctx, b := localnet(t)
+		d256, err := precompiles.Sum512_256(ctx, b, data)
+		if err != nil || d256 != local.Sum512_256(data) {
+			t.Fatalf("SHA-512/256 precompile returned %x, locally %x (%v)", d256, local.Sum512_256(data), err)
+		}
+		d512, err := precompiles.Sum512(ctx, b, data)
+		if err != nil || d512 != local.Sum512(data) {
+			t.Fatalf("SHA-512 precompile returned %x, locally %x (%v)", d512, local.Sum512(data), err)
+		}
+		d384, err := precompiles.Sum384(ctx, b, data)
+		if err != nil || d384 != local.Sum384(data) {
+			t.Fatalf("SHA-384 precompile returned %x, locally %x (%v)", d384, local.Sum384(data), err)
+		}
+
+		key, nonce := d256[:], d512[:32]
+		sealed, err := precompiles.DeoxysiiSeal(ctx, b, key, nonce, data, ad)
+		if err != nil {
+			t.Fatalf("DeoxysiiSeal failed: %v", err)
+		}
+		if want, _ := local.DeoxysiiSeal(key, nonce, data, ad); !bytes.Equal(sealed, want) {
+			t.Fatalf("precompile sealed %x, locally %x", sealed, want)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package local
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha512"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// SigningMethod is a signature scheme of the signing precompiles, the
+// Sapphire.SigningAlg enum.
+type SigningMethod uint8
+
+const (
+	// Ed25519Oasis signs messages hashed with SHA-512/256 and a context, as
+	// Oasis consensus and ParaTime transactions are.
+	Ed25519Oasis SigningMethod = iota
+	// Ed25519Pure signs messages.
+	Ed25519Pure
+	// Ed25519PrehashedSha512 signs SHA-512 digests.
+	Ed25519PrehashedSha512
+	// Secp256k1Oasis signs messages hashed with SHA-512/256 and a context, as
+	// Oasis consensus and ParaTime transactions are.
+	Secp256k1Oasis
+	// Secp256k1PrehashedKeccak256 signs Keccak-256 digests, as Ethereum
+	// transactions are.
+	Secp256k1PrehashedKeccak256
+	// Secp256k1PrehashedSha256 signs SHA-256 digests.
+	Secp256k1PrehashedSha256
+	// Sr25519 signs messages. The precompiles don't support it.
+	Sr25519
+	// Secp256r1PrehashedSha256 signs SHA-256 digests.
+	Secp256r1PrehashedSha256
+	// Secp384r1PrehashedSha384 signs SHA-384 digests.
+	Secp384r1PrehashedSha384
+)
+
+var signingMethodNames = []string{
+	"Ed25519Oasis",
+	"Ed25519Pure",
+	"Ed25519PrehashedSha512",
+	"Secp256k1Oasis",
+	"Secp256k1PrehashedKeccak256",
+	"Secp256k1PrehashedSha256",
+	"Sr25519",
+	"Secp256r1PrehashedSha256",
+	"Secp384r1PrehashedSha384",
+}
+
+func (m SigningMethod) String() string {
+	if int(m) < len(signingMethodNames) {
+		return signingMethodNames[m]
+	}
+	return fmt.Sprintf("SigningMethod(%d)", uint8(m))
+}
+
+// SeedSize returns the size of the seeds, and secret keys, of m's key pairs,
+// 0 if the precompiles don't support m.
+func (m SigningMethod) SeedSize() int {
+	switch m {
+	case Ed25519Oasis, Ed25519Pure, Ed25519PrehashedSha512,
+		Secp256k1Oasis, Secp256k1PrehashedKeccak256, Secp256k1PrehashedSha256,
+		Secp256r1PrehashedSha256:
+		return 32
+	case Secp384r1PrehashedSha384:
+		return 48
+	default:
+		return 0
+	}
+}
+
+// DigestSize returns the size of the digests m signs, 0 if m signs messages.
+func (m SigningMethod) DigestSize() int {
+	switch m {
+	case Ed25519PrehashedSha512:
+		return 64
+	case Secp256k1PrehashedKeccak256, Secp256k1PrehashedSha256, Secp256r1PrehashedSha256:
+		return 32
+	case Secp384r1PrehashedSha384:
+		return 48
+	default:
+		return 0
+	}
+}
+
+// CheckSeed returns an error if the precompiles can't generate a key pair of
+// m from seed.
+func (m SigningMethod) CheckSeed(seed []byte) error {
+	size := m.SeedSize()
+	if size == 0 {
+		return fmt.Errorf("%w: unsupported signing method %s", ErrInvalidInput, m)
+	}
+	if len(seed) != size {
+		return fmt.Errorf("%w: %s seed is %d bytes, not %d", ErrInvalidInput, m, len(seed), size)
+	}
+	return nil
+}
+
+// CheckArgs returns an error if the precompiles can't sign message with
+// contextOrHash with m.
+//
+// Ed25519Oasis and Secp256k1Oasis sign message in the domain given by the
+// context passed as contextOrHash, Ed25519Pure signs message without a context
+// and the prehashed methods sign the digest passed as contextOrHash, with an
+// empty message.
+func (m SigningMethod) CheckArgs(contextOrHash, message []byte) error {
+	if m.SeedSize() == 0 {
+		return fmt.Errorf("%w: unsupported signing method %s", ErrInvalidInput, m)
+	}
+	if size := m.DigestSize(); size != 0 {
+		if len(contextOrHash) != size || len(message) != 0 {
+			return fmt.Errorf("%w: %s signs a %d-byte digest passed as context, with an empty message", ErrInvalidInput, m, size)
+		}
+	}
+	if m == Ed25519Pure && len(contextOrHash) != 0 {
+		return fmt.Errorf("%w: %s takes no context", ErrInvalidInput, m)
+	}
+	return nil
+}
+
+// GenerateSigningKeyPair returns the public key of the key pair of method
+// generated from seed, like the key pair generation precompile. The secret key
+// is seed. Ed25519 public keys are 32 bytes, ECDSA ones compressed.
+func GenerateSigningKeyPair(method SigningMethod, seed []byte) ([]byte, error) {
+	if err := method.CheckSeed(seed); err != nil {
+		return nil, err
+	}
+	switch method {
+	case Ed25519Oasis, Ed25519Pure, Ed25519PrehashedSha512:
+		return ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey), nil
+	case Secp256k1Oasis, Secp256k1PrehashedKeccak256, Secp256k1PrehashedSha256:
+		sk, err := ethcrypto.ToECDSA(seed)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+		return ethcrypto.CompressPubkey(&sk.PublicKey), nil
+	case Secp256r1PrehashedSha256:
+		return compressedNISTKey(ecdh.P256(), elliptic.P256(), seed)
+	default:
+		return compressedNISTKey(ecdh.P384(), elliptic.P384(), seed)
+	}
+}
+
+func compressedNISTKey(curve ecdh.Curve, params elliptic.Curve, seed []byte) ([]byte, error) {
+	sk, err := curve.NewPrivateKey(seed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	x, y := elliptic.Unmarshal(params, sk.PublicKey().Bytes())
+	return elliptic.MarshalCompressed(params, x, y), nil
+}
+
+// Verify checks signature of message by publicKey, like the signature
+// verification precompile, so that signatures made on-chain can be checked
+// off-chain. Secp256k1 and NIST curve signatures are ASN.1 DER encoded.
+//
+// An error is returned for arguments CheckArgs rejects and malformed public
+// keys.
+func Verify(method SigningMethod, publicKey, contextOrHash, message, signature []byte) (bool, error) {
+	if err := method.CheckArgs(contextOrHash, message); err != nil {
+		return false, err
+	}
+	switch method {
+	case Ed25519Oasis, Ed25519Pure, Ed25519PrehashedSha512:
+		if len(publicKey) != ed25519.PublicKeySize {
+			return false, fmt.Errorf("%w: %s public key is %d bytes, not %d", ErrInvalidInput, method, len(publicKey), ed25519.PublicKeySize)
+		}
+		switch method {
+		case Ed25519Oasis:
+			digest := oasisDigest(contextOrHash, message)
+			return ed25519.Verify(publicKey, digest[:], signature), nil
+		case Ed25519Pure:
+			return ed25519.Verify(publicKey, message, signature), nil
+		default:
+			return ed25519.VerifyWithOptions(publicKey, contextOrHash, signature, &ed25519.Options{Hash: crypto.SHA512}) == nil, nil
+		}
+	case Secp256k1Oasis, Secp256k1PrehashedKeccak256, Secp256k1PrehashedSha256:
+		pk, err := ethcrypto.DecompressPubkey(publicKey)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+		digest := contextOrHash
+		if method == Secp256k1Oasis {
+			d := oasisDigest(contextOrHash, message)
+			digest = d[:]
+		}
+		r, s, ok := parseDERSignature(signature)
+		if !ok || r.BitLen() > 256 || s.BitLen() > 256 {
+			return false, nil
+		}
+		rs := make([]byte, 64)
+		r.FillBytes(rs[:32])
+		s.FillBytes(rs[32:])
+		return ethcrypto.VerifySignature(ethcrypto.CompressPubkey(pk), digest, rs), nil
+	default:
+		curve := elliptic.P256()
+		if method == Secp384r1PrehashedSha384 {
+			curve = elliptic.P384()
+		}
+		x, y := elliptic.UnmarshalCompressed(curve, publicKey)
+		if x == nil {
+			return false, fmt.Errorf("%w: invalid %s public key", ErrInvalidInput, method)
+		}
+		return ecdsa.VerifyASN1(&ecdsa.PublicKey{Curve: curve, X: x, Y: y}, contextOrHash, signature), nil
+	}
+}
+
+// oasisDigest is the digest Oasis signature schemes sign message with in the
+// domain given by context.
+func oasisDigest(context, message []byte) [32]byte {
+	return sha512.Sum512_256(append(append([]byte(nil), context...), message...))
+}
+
+func parseDERSignature(signature []byte) (r, s *big.Int, ok bool) {
+	var sig struct{ R, S *big.Int }
+	rest, err := asn1.Unmarshal(signature, &sig)
+	if err != nil || len(rest) != 0 || sig.R.Sign() <= 0 || sig.S.Sign() <= 0 {
+		return nil, nil, false
+	}
+	return sig.R, sig.S, true
+}
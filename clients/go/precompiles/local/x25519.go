@@ -0,0 +1,23 @@
+package local
+
+import (
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	mrae "github.com/oasisprotocol/oasis-core/go/common/crypto/mrae/deoxysii"
+)
+
+// X25519Derive derives the symmetric key of publicKey and privateKey, like
+// the key derivation precompile. It is the key derivation Deoxys-II envelopes
+// are encrypted with.
+func X25519Derive(publicKey, privateKey [32]byte) [32]byte {
+	var key [32]byte
+	pk, sk := x25519.PublicKey(publicKey), x25519.PrivateKey(privateKey)
+	mrae.Box.DeriveSymmetricKey(key[:], &pk, &sk)
+	return key
+}
+
+// Curve25519PublicKey computes the public key of the Curve25519 secret key,
+// like the Curve25519 public key precompile.
+func Curve25519PublicKey(secretKey [32]byte) [32]byte {
+	pk, _ := x25519.X25519(secretKey[:], x25519.Basepoint)
+	return [32]byte(pk)
+}
@@ -0,0 +1,48 @@
+// Package precompiles calls Sapphire's precompiled contracts with eth_call,
+// e.g. to check values contracts compute with them in tests.
+//
+// Pass a client.WrappedBackend as the bind.ContractCaller to have the calls
+// encrypted like any other query.
+package precompiles
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles/local"
+)
+
+// ErrInvalidInput is returned for arguments a precompile would reject or
+// silently change.
+var ErrInvalidInput = local.ErrInvalidInput
+
+var (
+	uint256Type, _ = abi.NewType("uint256", "", nil)
+	bytesType, _   = abi.NewType("bytes", "", nil)
+	bytes32Type, _ = abi.NewType("bytes32", "", nil)
+)
+
+// call calls p with input at the latest block.
+func call(ctx context.Context, caller bind.ContractCaller, p Precompile, input []byte) ([]byte, error) {
+	return callFrom(ctx, caller, common.Address{}, p, input)
+}
+
+// callFrom calls p with input at the latest block, from from.
+func callFrom(ctx context.Context, caller bind.ContractCaller, from common.Address, p Precompile, input []byte) ([]byte, error) {
+	addr := Addresses[p]
+	out, err := caller.CallContract(ctx, ethereum.CallMsg{From: from, To: &addr, Data: input}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s precompile %s: %w", p, addr.Hex(), err)
+	}
+	return out, nil
+}
+
+// words returns the number of 32-byte words n bytes take up.
+func words(n uint64) uint64 {
+	return (n + 31) / 32
+}
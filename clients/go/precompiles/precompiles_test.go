@@ -0,0 +1,558 @@
+package precompiles
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/oasisprotocol/curve25519-voi/primitives/x25519"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/client"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/internal/testenv"
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles/local"
+)
+
+// fakeCaller answers calls with respond and records them.
+type fakeCaller struct {
+	respond func(msg ethereum.CallMsg) ([]byte, error)
+	calls   []ethereum.CallMsg
+}
+
+func (c *fakeCaller) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (c *fakeCaller) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	c.calls = append(c.calls, msg)
+	return c.respond(msg)
+}
+
+// localnet returns a wrapped client for the localnet, skipping the test
+// unless integration tests are enabled.
+func localnet(t *testing.T) (context.Context, *client.WrappedBackend) {
+	eth := testenv.Start(t).Dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+	b, err := client.WrapClient(eth, nil)
+	if err != nil {
+		t.Fatalf("failed to wrap client: %v", err)
+	}
+	return ctx, b
+}
+
+func TestRandomBytes(t *testing.T) {
+	ctx := context.Background()
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		return make([]byte, 64), nil
+	}}
+	out, err := RandomBytes(ctx, caller, 64, []byte("pers"))
+	if err != nil || len(out) != 64 {
+		t.Fatalf("RandomBytes failed: %v", err)
+	}
+	msg := caller.calls[0]
+	if *msg.To != RandomBytesAddress {
+		t.Fatalf("called %s instead of the precompile", msg.To.Hex())
+	}
+	// abi.encode(uint256(64), bytes("pers")).
+	expected := common.FromHex("0000000000000000000000000000000000000000000000000000000000000040" +
+		"0000000000000000000000000000000000000000000000000000000000000040" +
+		"0000000000000000000000000000000000000000000000000000000000000004" +
+		"7065727300000000000000000000000000000000000000000000000000000000")
+	if !bytes.Equal(msg.Data, expected) {
+		t.Fatalf("unexpected input %x", msg.Data)
+	}
+
+	if _, err = RandomBytes(ctx, caller, MaxRandomBytes+1, nil); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected too many bytes to be rejected, got %v", err)
+	}
+	if _, err = RandomBytes(ctx, caller, 32, nil); err == nil {
+		t.Fatalf("expected a short output to fail")
+	}
+	if gas := RandomBytesGas(64, 33); gas != 10_000+2*240+2*60 {
+		t.Fatalf("unexpected gas cost %d", gas)
+	}
+}
+
+func TestRandomBytesLocalnet(t *testing.T) {
+	ctx, b := localnet(t)
+	a, err := RandomBytes(ctx, b, 32, []byte("test"))
+	if err != nil {
+		t.Fatalf("RandomBytes failed: %v", err)
+	}
+	c, err := RandomBytes(ctx, b, MaxRandomBytes, nil)
+	if err != nil {
+		t.Fatalf("RandomBytes failed: %v", err)
+	}
+	if len(a) != 32 || len(c) != MaxRandomBytes || bytes.Equal(a, c[:32]) {
+		t.Fatalf("unexpected random bytes %x, %x", a, c[:32])
+	}
+}
+
+// testKeyPair returns a fixed Curve25519 key pair derived from seed.
+func testKeyPair(t *testing.T, seed byte) (public, private [32]byte) {
+	private[0] = seed
+	pk, err := x25519.X25519(private[:], x25519.Basepoint)
+	if err != nil {
+		t.Fatalf("failed to derive public key: %v", err)
+	}
+	return [32]byte(pk), private
+}
+
+func TestX25519Derive(t *testing.T) {
+	pkA, skA := testKeyPair(t, 1)
+	pkB, skB := testKeyPair(t, 2)
+	if X25519DeriveLocal(pkB, skA) != X25519DeriveLocal(pkA, skB) {
+		t.Fatalf("derived keys differ between the peers")
+	}
+
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		key := X25519DeriveLocal([32]byte(msg.Data[:32]), [32]byte(msg.Data[32:]))
+		return key[:], nil
+	}}
+	key, err := X25519Derive(context.Background(), caller, pkB, skA)
+	if err != nil {
+		t.Fatalf("X25519Derive failed: %v", err)
+	}
+	if *caller.calls[0].To != X25519DeriveAddress || len(caller.calls[0].Data) != 64 {
+		t.Fatalf("unexpected call %+v", caller.calls[0])
+	}
+	if key != X25519DeriveLocal(pkB, skA) {
+		t.Fatalf("unexpected key %x", key)
+	}
+}
+
+func TestDeoxysii(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	nonce := bytes.Repeat([]byte{2}, 15)
+	ciphertext, err := DeoxysiiSealLocal(key, nonce, []byte("plaintext"), []byte("ad"))
+	if err != nil {
+		t.Fatalf("DeoxysiiSealLocal failed: %v", err)
+	}
+	plaintext, err := DeoxysiiOpenLocal(key, append(nonce, 0xff), ciphertext, []byte("ad"))
+	if err != nil || string(plaintext) != "plaintext" {
+		t.Fatalf("DeoxysiiOpenLocal failed: %q, %v", plaintext, err)
+	}
+	if _, err = DeoxysiiOpenLocal(key, nonce, ciphertext, nil); err == nil {
+		t.Fatalf("expected the wrong additional data to fail")
+	}
+
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		args, err := deoxysiiArgs.Unpack(msg.Data)
+		if err != nil {
+			return nil, err
+		}
+		k, n := args[0].([32]byte), args[1].([32]byte)
+		return DeoxysiiSealLocal(k[:], n[:], args[2].([]byte), args[3].([]byte))
+	}}
+	out, err := DeoxysiiSeal(context.Background(), caller, key, nonce, []byte("plaintext"), []byte("ad"))
+	if err != nil {
+		t.Fatalf("DeoxysiiSeal failed: %v", err)
+	}
+	if *caller.calls[0].To != DeoxysiiSealAddress || !bytes.Equal(out, ciphertext) {
+		t.Fatalf("unexpected call %+v returning %x", caller.calls[0], out)
+	}
+
+	for _, tc := range []struct{ key, nonce []byte }{
+		{key[:31], nonce},
+		{key, nonce[:14]},
+		{key, make([]byte, 33)},
+	} {
+		if _, err = DeoxysiiSeal(context.Background(), caller, tc.key, tc.nonce, nil, nil); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("expected %d byte key and %d byte nonce to be rejected, got %v", len(tc.key), len(tc.nonce), err)
+		}
+		if _, err = DeoxysiiOpenLocal(tc.key, tc.nonce, ciphertext, nil); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("expected %d byte key and %d byte nonce to be rejected, got %v", len(tc.key), len(tc.nonce), err)
+		}
+	}
+	if len(caller.calls) != 1 {
+		t.Fatalf("invalid input reached the precompile")
+	}
+}
+
+func TestCurve25519PublicKey(t *testing.T) {
+	// RFC 7748, section 6.1.
+	sk := [32]byte(common.FromHex("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"))
+	expected := [32]byte(common.FromHex("8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a"))
+	if pk := Curve25519PublicKeyLocal(sk); pk != expected {
+		t.Fatalf("unexpected public key %x", pk)
+	}
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		pk := Curve25519PublicKeyLocal([32]byte(msg.Data))
+		return pk[:], nil
+	}}
+	pk, err := Curve25519PublicKey(context.Background(), caller, sk)
+	if err != nil || pk != expected {
+		t.Fatalf("Curve25519PublicKey failed: %x, %v", pk, err)
+	}
+	if *caller.calls[0].To != Curve25519PublicKeyAddress || !bytes.Equal(caller.calls[0].Data, sk[:]) {
+		t.Fatalf("unexpected call %+v", caller.calls[0])
+	}
+}
+
+func TestGenerateSigningKeyPair(t *testing.T) {
+	// RFC 8032, section 7.1, test 1.
+	seed := common.FromHex("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	kp, err := GenerateSigningKeyPairLocal(Ed25519Pure, seed)
+	if err != nil {
+		t.Fatalf("GenerateSigningKeyPairLocal failed: %v", err)
+	}
+	if !bytes.Equal(kp.PublicKey, common.FromHex("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")) {
+		t.Fatalf("unexpected public key %x", kp.PublicKey)
+	}
+	for _, method := range []SigningMethod{Secp256k1Oasis, Secp256r1PrehashedSha256, Secp384r1PrehashedSha384} {
+		kp, err := GenerateSigningKeyPairLocal(method, bytes.Repeat([]byte{1}, method.SeedSize()))
+		if err != nil {
+			t.Fatalf("GenerateSigningKeyPairLocal(%s) failed: %v", method, err)
+		}
+		if len(kp.PublicKey) != method.SeedSize()+1 || (kp.PublicKey[0] != 2 && kp.PublicKey[0] != 3) {
+			t.Fatalf("%s public key %x is not compressed", method, kp.PublicKey)
+		}
+	}
+
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		kp, err := GenerateSigningKeyPairLocal(SigningMethod(msg.Data[31]), msg.Data[96:])
+		if err != nil {
+			return nil, err
+		}
+		return keyPairOutput.Pack(kp.PublicKey, kp.SecretKey)
+	}}
+	got, err := GenerateSigningKeyPair(context.Background(), caller, Ed25519Pure, seed)
+	if err != nil {
+		t.Fatalf("GenerateSigningKeyPair failed: %v", err)
+	}
+	if !bytes.Equal(got.PublicKey, kp.PublicKey) || !bytes.Equal(got.SecretKey, seed) || got.Method != Ed25519Pure {
+		t.Fatalf("unexpected key pair %+v", got)
+	}
+	if msg := caller.calls[0]; *msg.To != GenerateSigningKeyPairAddress || msg.Data[31] != byte(Ed25519Pure) {
+		t.Fatalf("unexpected call %+v", msg)
+	}
+
+	for _, tc := range []struct {
+		method SigningMethod
+		seed   []byte
+	}{
+		{Ed25519Oasis, seed[:31]},
+		{Secp384r1PrehashedSha384, seed},
+		{Sr25519, seed},
+		{SigningMethod(9), seed},
+	} {
+		if _, err = GenerateSigningKeyPair(context.Background(), caller, tc.method, tc.seed); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("expected a %d byte %s seed to be rejected, got %v", len(tc.seed), tc.method, err)
+		}
+	}
+	if _, err = GenerateSigningKeyPairLocal(Secp256k1Oasis, make([]byte, 32)); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected a zero secp256k1 seed to be rejected, got %v", err)
+	}
+	if len(caller.calls) != 1 {
+		t.Fatalf("invalid input reached the precompile")
+	}
+}
+
+func TestVerifyLocal(t *testing.T) {
+	message := []byte("signed message")
+
+	seed := bytes.Repeat([]byte{1}, 32)
+	kp, err := GenerateSigningKeyPairLocal(Ed25519Pure, seed)
+	if err != nil {
+		t.Fatalf("GenerateSigningKeyPairLocal failed: %v", err)
+	}
+	sig := ed25519.Sign(ed25519.NewKeyFromSeed(seed), message)
+	if ok, err := VerifyLocal(Ed25519Pure, kp.PublicKey, nil, message, sig); !ok || err != nil {
+		t.Fatalf("expected the ed25519 signature to verify: %v", err)
+	}
+	if ok, _ := VerifyLocal(Ed25519Pure, kp.PublicKey, nil, []byte("other message"), sig); ok {
+		t.Fatalf("expected the ed25519 signature of another message to fail")
+	}
+
+	kp, err = GenerateSigningKeyPairLocal(Secp256k1PrehashedKeccak256, seed)
+	if err != nil {
+		t.Fatalf("GenerateSigningKeyPairLocal failed: %v", err)
+	}
+	sk, _ := crypto.ToECDSA(seed)
+	digest := crypto.Keccak256(message)
+	rsv, _ := crypto.Sign(digest, sk)
+	der, _ := asn1.Marshal(struct{ R, S *big.Int }{new(big.Int).SetBytes(rsv[:32]), new(big.Int).SetBytes(rsv[32:64])})
+	if ok, err := VerifyLocal(Secp256k1PrehashedKeccak256, kp.PublicKey, digest, nil, der); !ok || err != nil {
+		t.Fatalf("expected the secp256k1 signature to verify: %v", err)
+	}
+	if ok, _ := VerifyLocal(Secp256k1PrehashedKeccak256, kp.PublicKey, crypto.Keccak256(digest), nil, der); ok {
+		t.Fatalf("expected the secp256k1 signature of another digest to fail")
+	}
+	if ok, _ := VerifyLocal(Secp256k1PrehashedKeccak256, kp.PublicKey, digest, nil, rsv); ok {
+		t.Fatalf("expected a signature that is not DER encoded to fail")
+	}
+
+	for _, tc := range []struct {
+		method           SigningMethod
+		context, message []byte
+	}{
+		{Sr25519, nil, message},
+		{SigningMethod(9), nil, message},
+		{Ed25519Pure, []byte("context"), message},
+		{Secp256k1PrehashedKeccak256, digest, message},
+		{Secp256k1PrehashedKeccak256, digest[:31], nil},
+	} {
+		if _, err = VerifyLocal(tc.method, kp.PublicKey, tc.context, tc.message, der); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("expected %s with a %d-byte context and %d-byte message to be rejected, got %v", tc.method, len(tc.context), len(tc.message), err)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		if *msg.To == SignAddress {
+			return []byte("signature"), nil
+		}
+		return abi.Arguments{{Type: boolType}}.Pack(true)
+	}}
+	ctx := context.Background()
+	sig, err := Sign(ctx, caller, Ed25519Oasis, make([]byte, 32), []byte("context"), []byte("message"))
+	if err != nil || string(sig) != "signature" {
+		t.Fatalf("Sign failed: %q, %v", sig, err)
+	}
+	ok, err := Verify(ctx, caller, Ed25519Oasis, make([]byte, 32), []byte("context"), []byte("message"), sig)
+	if err != nil || !ok {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if caller.calls[0].Data[31] != byte(Ed25519Oasis) || *caller.calls[1].To != VerifyAddress {
+		t.Fatalf("unexpected calls %+v", caller.calls)
+	}
+
+	if _, err = Sign(ctx, caller, Sr25519, make([]byte, 32), nil, []byte("message")); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected an unsupported method to be rejected, got %v", err)
+	}
+	if _, err = Sign(ctx, caller, Ed25519Pure, make([]byte, 31), nil, []byte("message")); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected a short secret key to be rejected, got %v", err)
+	}
+	if _, err = Verify(ctx, caller, SigningMethod(42), nil, nil, nil, nil); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("expected an unknown method to be rejected, got %v", err)
+	}
+	if len(caller.calls) != 2 {
+		t.Fatalf("invalid input reached the precompile")
+	}
+
+	if gas := SignGas(Ed25519Oasis, 7, 33); gas != 1_500+3*SigningWordGas {
+		t.Fatalf("unexpected gas cost %d", gas)
+	}
+	if gas := VerifyGas(Secp256k1PrehashedKeccak256, 32, 0); gas != 3_000 {
+		t.Fatalf("unexpected gas cost %d", gas)
+	}
+}
+
+func TestHashes(t *testing.T) {
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		switch *msg.To {
+		case SHA512_256Address:
+			d := Sum512_256Local(msg.Data)
+			return d[:], nil
+		case SHA512Address:
+			d := Sum512Local(msg.Data)
+			return d[:], nil
+		default:
+			return make([]byte, 32), nil
+		}
+	}}
+	ctx := context.Background()
+	// FIPS 180-4 examples.
+	d256, err := Sum512_256(ctx, caller, []byte("abc"))
+	if err != nil || d256 != [32]byte(common.FromHex("53048e2681941ef99b2e29b76b4c7dabe4c2d0c634fc6d46e0e2f13107e7af23")) {
+		t.Fatalf("Sum512_256 failed: %x, %v", d256, err)
+	}
+	d512, err := Sum512(ctx, caller, []byte("abc"))
+	if err != nil || d512 != Sum512Local([]byte("abc")) {
+		t.Fatalf("Sum512 failed: %x, %v", d512, err)
+	}
+	if d384 := Sum384Local([]byte("abc")); d384 != [48]byte(common.FromHex("cb00753f45a35e8bb5a03d699ac65007272c32ab0eded1631a8b605a43ff5bed8086072ba1e7cc2358baeca134c825a7")) {
+		t.Fatalf("unexpected SHA-384 digest %x", d384)
+	}
+	if _, err = Sum384(ctx, caller, []byte("abc")); err == nil {
+		t.Fatalf("expected a short digest to fail")
+	}
+	if gas := GasCosts[SHA384Precompile].Gas(33); gas != 115+2*13 {
+		t.Fatalf("unexpected gas cost %d", gas)
+	}
+}
+
+func TestSubcall(t *testing.T) {
+	input, err := SubcallData("core.CurrentEpoch", nil)
+	if err != nil {
+		t.Fatalf("SubcallData failed: %v", err)
+	}
+	args, err := subcallInput.Unpack(input)
+	if err != nil || args[0].(string) != "core.CurrentEpoch" || !bytes.Equal(args[1].([]byte), []byte{0xf6}) {
+		t.Fatalf("unexpected subcall input %v (%v)", args, err)
+	}
+	for _, method := range []string{"", "evm.Call"} {
+		if _, err = SubcallData(method, nil); !errors.Is(err, ErrInvalidInput) {
+			t.Fatalf("expected method %q to be rejected, got %v", method, err)
+		}
+	}
+
+	status, response := uint64(0), cbor.Marshal(uint64(42))
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		return subcallOutput.Pack(status, []byte(response))
+	}}
+	ctx := context.Background()
+	res, err := Subcall(ctx, caller, "core.CurrentEpoch", nil)
+	if err != nil || res.Err() != nil || *caller.calls[0].To != SubcallAddress {
+		t.Fatalf("Subcall failed: %v, %v", err, res.Err())
+	}
+	var epoch uint64
+	if err = res.Decode(&epoch); err != nil || epoch != 42 {
+		t.Fatalf("failed to decode response %d: %v", epoch, err)
+	}
+
+	status, response = 3, []byte("consensus")
+	if res, err = Subcall(ctx, caller, "consensus.Delegate", map[string]interface{}{"to": []byte{}}); err != nil {
+		t.Fatalf("Subcall failed: %v", err)
+	}
+	var failed *sapphire.CallFailedError
+	if err = res.Decode(&epoch); !errors.As(err, &failed) || !errors.Is(err, sapphire.ErrCallFailed) || failed.Module != "consensus" || failed.Code != 3 {
+		t.Fatalf("expected a failed call, got %v", err)
+	}
+}
+
+func TestSubcallLocalnet(t *testing.T) {
+	ctx, b := localnet(t)
+	res, err := Subcall(ctx, b, "core.CurrentEpoch", nil)
+	if err != nil {
+		t.Fatalf("Subcall failed: %v", err)
+	}
+	var epoch uint64
+	if err = res.Decode(&epoch); err != nil {
+		t.Fatalf("core.CurrentEpoch failed: %v", err)
+	}
+	if res, err = Subcall(ctx, b, "core.NoSuchMethod", nil); err != nil {
+		t.Fatalf("Subcall failed: %v", err)
+	}
+	if !errors.Is(res.Err(), sapphire.ErrCallFailed) {
+		t.Fatalf("expected an unknown method to fail, got %v", res.Err())
+	}
+}
+
+func TestGasPrecompiles(t *testing.T) {
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		if *msg.To == GasUsedAddress {
+			return abi.Arguments{{Type: uint64Type}}.Pack(uint64(1234))
+		}
+		return nil, nil
+	}}
+	ctx := context.Background()
+	if gas, err := GasUsed(ctx, caller); err != nil || gas != 1234 {
+		t.Fatalf("GasUsed failed: %d, %v", gas, err)
+	}
+	if err := PadGas(ctx, caller, 100_000); err != nil {
+		t.Fatalf("PadGas failed: %v", err)
+	}
+	if msg := caller.calls[1]; *msg.To != PadGasAddress || !bytes.Equal(msg.Data, common.LeftPadBytes(big.NewInt(100_000).Bytes(), 32)) {
+		t.Fatalf("unexpected call %+v", msg)
+	}
+}
+
+func TestGasPrecompilesLocalnet(t *testing.T) {
+	ctx, b := localnet(t)
+	used, err := GasUsed(ctx, b)
+	if err != nil || used == 0 {
+		t.Fatalf("GasUsed failed: %d, %v", used, err)
+	}
+	if err = PadGas(ctx, b, 1_000_000); err != nil {
+		t.Fatalf("PadGas failed: %v", err)
+	}
+	if err = PadGas(ctx, b, 0); err == nil {
+		t.Fatalf("expected padding to less than the gas used to fail")
+	}
+}
+
+func TestAddresses(t *testing.T) {
+	seen := make(map[common.Address]Precompile)
+	for p := RandomBytesPrecompile; p <= SHA384Precompile; p++ {
+		addr, ok := Addresses[p]
+		if !ok || p.String() == fmt.Sprintf("Precompile(%d)", p) {
+			t.Fatalf("precompile %d has no address or name", p)
+		}
+		if other, ok := seen[addr]; ok {
+			t.Fatalf("%s and %s share address %s", p, other, addr.Hex())
+		}
+		seen[addr] = p
+	}
+	if len(Addresses) != len(seen) {
+		t.Fatalf("Addresses has %d entries, expected %d", len(Addresses), len(seen))
+	}
+	for p := range GasCosts {
+		if _, ok := Addresses[p]; !ok {
+			t.Fatalf("gas cost of unknown precompile %s", p)
+		}
+	}
+	if Precompile(0).String() != "Precompile(0)" {
+		t.Fatalf("unexpected name %q", Precompile(0))
+	}
+}
+
+// TestAddressesLocalnet calls every precompile with trivially valid input,
+// to which an empty account would answer with empty output.
+func TestAddressesLocalnet(t *testing.T) {
+	ctx, b := localnet(t)
+	key, nonce, seed := make([]byte, 32), make([]byte, 32), make([]byte, 32)
+	ciphertext, err := DeoxysiiSealLocal(key, nonce, nil, nil)
+	if err != nil {
+		t.Fatalf("DeoxysiiSealLocal failed: %v", err)
+	}
+	publicKey, err := local.GenerateSigningKeyPair(Ed25519Pure, seed)
+	if err != nil {
+		t.Fatalf("GenerateSigningKeyPair failed: %v", err)
+	}
+	method := new(big.Int).SetUint64(uint64(Ed25519Pure))
+	pack := func(args abi.Arguments, values ...interface{}) []byte {
+		input, err := args.Pack(values...)
+		if err != nil {
+			t.Fatalf("failed to pack input: %v", err)
+		}
+		return input
+	}
+	subcallInput, err := SubcallData("core.CurrentEpoch", nil)
+	if err != nil {
+		t.Fatalf("SubcallData failed: %v", err)
+	}
+	inputs := map[Precompile][]byte{
+		RandomBytesPrecompile:            pack(abi.Arguments{{Type: uint256Type}, {Type: bytesType}}, big.NewInt(32), []byte{}),
+		X25519DerivePrecompile:           make([]byte, 64),
+		DeoxysiiSealPrecompile:           pack(deoxysiiArgs, [32]byte{}, [32]byte{}, []byte{}, []byte{}),
+		DeoxysiiOpenPrecompile:           pack(deoxysiiArgs, [32]byte{}, [32]byte{}, ciphertext, []byte{}),
+		GenerateSigningKeyPairPrecompile: pack(abi.Arguments{{Type: uint256Type}, {Type: bytesType}}, method, seed),
+		SignPrecompile:                   pack(abi.Arguments{{Type: uint256Type}, {Type: bytesType}, {Type: bytesType}, {Type: bytesType}}, method, seed, []byte{}, []byte{}),
+		VerifyPrecompile:                 pack(abi.Arguments{{Type: uint256Type}, {Type: bytesType}, {Type: bytesType}, {Type: bytesType}, {Type: bytesType}}, method, publicKey, []byte{}, []byte{}, make([]byte, 64)),
+		Curve25519PublicKeyPrecompile:    key,
+		GasUsedPrecompile:                nil,
+		SHA512_256Precompile:             nil,
+		SHA512Precompile:                 nil,
+		SubcallPrecompile:                subcallInput,
+		SHA384Precompile:                 nil,
+	}
+	for p, input := range inputs {
+		out, err := call(ctx, b, p, input)
+		if err != nil {
+			t.Fatalf("%s failed: %v", p, err)
+		}
+		if len(out) == 0 {
+			t.Fatalf("%s at %s returned no output", p, Addresses[p].Hex())
+		}
+	}
+	// PadGas returns nothing, but unlike an empty account rejects input it
+	// can't decode.
+	if _, err = call(ctx, b, PadGasPrecompile, []byte{1}); err == nil {
+		t.Fatalf("expected PadGas at %s to reject malformed input", PadGasAddress.Hex())
+	}
+}
@@ -0,0 +1,55 @@
+package precompiles
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+const (
+	// MaxRandomBytes is the most bytes RandomBytes returns per call. The
+	// precompile returns this many for larger requests.
+	MaxRandomBytes = 1024
+
+	// RandomBytesBaseGas is the minimum gas cost of RandomBytes.
+	RandomBytesBaseGas = 10_000
+	// RandomBytesWordGas is the gas cost of RandomBytes per 32-byte word of
+	// output.
+	RandomBytesWordGas = 240
+	// RandomBytesPersWordGas is the gas cost of RandomBytes per 32-byte word
+	// of the personalization string.
+	RandomBytesPersWordGas = 60
+)
+
+// RandomBytesGas returns the gas cost of a RandomBytes call for numBytes
+// bytes with a persLen-byte personalization string.
+func RandomBytesGas(numBytes uint, persLen int) uint64 {
+	return RandomBytesBaseGas + RandomBytesWordGas*words(uint64(numBytes)) + RandomBytesPersWordGas*words(uint64(persLen))
+}
+
+// RandomBytes returns numBytes pseudo-random bytes from the RandomBytes
+// precompile, like Sapphire.randomBytes, with the optional personalization
+// string pers for domain separation.
+//
+// Contracts calling the precompile get different bytes than eth_call does: its
+// output depends on the transaction or query it is called in.
+func RandomBytes(ctx context.Context, caller bind.ContractCaller, numBytes uint, pers []byte) ([]byte, error) {
+	if numBytes > MaxRandomBytes {
+		return nil, fmt.Errorf("%w: %d random bytes requested, at most %d are returned", ErrInvalidInput, numBytes, MaxRandomBytes)
+	}
+	input, err := abi.Arguments{{Type: uint256Type}, {Type: bytesType}}.Pack(new(big.Int).SetUint64(uint64(numBytes)), pers)
+	if err != nil {
+		return nil, err
+	}
+	out, err := call(ctx, caller, RandomBytesPrecompile, input)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != int(numBytes) {
+		return nil, fmt.Errorf("precompile returned %d random bytes, %d requested", len(out), numBytes)
+	}
+	return out, nil
+}
@@ -0,0 +1,111 @@
+package precompiles
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles/local"
+)
+
+// SigningWordGas is the gas cost of Sign and Verify per 32-byte word of
+// context and message, for methods that hash the message themselves.
+const SigningWordGas = 8
+
+var boolType, _ = abi.NewType("bool", "", nil)
+
+// SignGas returns the gas cost of Sign for method and the lengths of its
+// context and message.
+func SignGas(method SigningMethod, contextLen, messageLen int) uint64 {
+	var base uint64
+	switch method {
+	case Ed25519Oasis, Ed25519Pure, Ed25519PrehashedSha512:
+		base = 1_500
+	case Secp256k1Oasis, Secp256k1PrehashedKeccak256, Secp256k1PrehashedSha256:
+		base = 3_000
+	case Secp256r1PrehashedSha256:
+		base = 9_000
+	case Secp384r1PrehashedSha384:
+		base = 43_200
+	}
+	return base + signingWordsGas(method, contextLen, messageLen)
+}
+
+// VerifyGas returns the gas cost of Verify for method and the lengths of its
+// context and message.
+func VerifyGas(method SigningMethod, contextLen, messageLen int) uint64 {
+	var base uint64
+	switch method {
+	case Ed25519Oasis, Ed25519Pure, Ed25519PrehashedSha512:
+		base = 2_000
+	case Secp256k1Oasis, Secp256k1PrehashedKeccak256, Secp256k1PrehashedSha256:
+		base = 3_000
+	case Secp256r1PrehashedSha256:
+		base = 7_900
+	case Secp384r1PrehashedSha384:
+		base = 37_920
+	}
+	return base + signingWordsGas(method, contextLen, messageLen)
+}
+
+func signingWordsGas(method SigningMethod, contextLen, messageLen int) uint64 {
+	if method.DigestSize() != 0 {
+		return 0
+	}
+	return SigningWordGas * (words(uint64(contextLen)) + words(uint64(messageLen)))
+}
+
+// Sign signs message with secretKey with the signing precompile, like
+// Sapphire.sign.
+//
+// Ed25519Oasis and Secp256k1Oasis sign message in the domain given by the
+// context passed as contextOrHash, Ed25519Pure signs message without a context
+// and the prehashed methods sign the digest passed as context, with an
+// empty message.
+// Secp256k1 and NIST curve signatures are ASN.1 DER encoded.
+func Sign(ctx context.Context, caller bind.ContractCaller, method SigningMethod, secretKey, contextOrHash, message []byte) ([]byte, error) {
+	if err := method.CheckArgs(contextOrHash, message); err != nil {
+		return nil, err
+	}
+	if len(secretKey) != method.SeedSize() {
+		return nil, fmt.Errorf("%w: %s secret key is %d bytes, not %d", ErrInvalidInput, method, len(secretKey), method.SeedSize())
+	}
+	input, err := abi.Arguments{{Type: uint256Type}, {Type: bytesType}, {Type: bytesType}, {Type: bytesType}}.
+		Pack(new(big.Int).SetUint64(uint64(method)), secretKey, contextOrHash, message)
+	if err != nil {
+		return nil, err
+	}
+	return call(ctx, caller, SignPrecompile, input)
+}
+
+// Verify checks signature of message by publicKey with the signature
+// verification precompile, like Sapphire.verify. contextOrHash and message
+// are as for Sign.
+func Verify(ctx context.Context, caller bind.ContractCaller, method SigningMethod, publicKey, contextOrHash, message, signature []byte) (bool, error) {
+	if err := method.CheckArgs(contextOrHash, message); err != nil {
+		return false, err
+	}
+	input, err := abi.Arguments{{Type: uint256Type}, {Type: bytesType}, {Type: bytesType}, {Type: bytesType}, {Type: bytesType}}.
+		Pack(new(big.Int).SetUint64(uint64(method)), publicKey, contextOrHash, message, signature)
+	if err != nil {
+		return false, err
+	}
+	out, err := call(ctx, caller, VerifyPrecompile, input)
+	if err != nil {
+		return false, err
+	}
+	values, err := abi.Arguments{{Type: boolType}}.Unpack(out)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode precompile output: %w", err)
+	}
+	return values[0].(bool), nil
+}
+
+// VerifyLocal checks a signature like Verify, without calling the precompile,
+// with local.Verify.
+func VerifyLocal(method SigningMethod, publicKey, contextOrHash, message, signature []byte) (bool, error) {
+	return local.Verify(method, publicKey, contextOrHash, message, signature)
+}
@@ -0,0 +1,104 @@
+package precompiles
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+var (
+	uint64Type, _ = abi.NewType("uint64", "", nil)
+	stringType, _ = abi.NewType("string", "", nil)
+
+	subcallInput  = abi.Arguments{{Type: stringType}, {Type: bytesType}}
+	subcallOutput = abi.Arguments{{Type: uint64Type}, {Type: bytesType}}
+)
+
+// SubcallResult is the result of a subcall.
+type SubcallResult struct {
+	// Status is 0 if the call succeeded, the module's error code otherwise.
+	Status uint64
+	// Response is the CBOR-encoded result of a successful call, or the name
+	// of the module that failed it.
+	Response cbor.RawMessage
+}
+
+// Err returns a *sapphire.CallFailedError if the call failed.
+func (r SubcallResult) Err() error {
+	if r.Status == 0 {
+		return nil
+	}
+	return &sapphire.CallFailedError{Module: string(r.Response), Code: uint32(r.Status)}
+}
+
+// Decode decodes the response of a successful call into v.
+func (r SubcallResult) Decode(v interface{}) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if err := cbor.Unmarshal(r.Response, v); err != nil {
+		return fmt.Errorf("failed to decode subcall response: %w", err)
+	}
+	return nil
+}
+
+// SubcallData returns the calldata of a subcall to method with body, which is
+// CBOR-encoded canonically. A nil body is encoded as CBOR null, as methods
+// taking no arguments expect.
+func SubcallData(method string, body interface{}) ([]byte, error) {
+	if method == "" || strings.HasPrefix(method, "evm.") {
+		return nil, fmt.Errorf("%w: method %q cannot be subcalled", ErrInvalidInput, method)
+	}
+	return subcallInput.Pack(method, cbor.Marshal(body))
+}
+
+// Subcall calls method with body through the subcall precompile with
+// eth_call, e.g. to query a module. It only fails if the precompile does; a
+// call the module fails is reported by the result's Err.
+func Subcall(ctx context.Context, caller bind.ContractCaller, method string, body interface{}) (SubcallResult, error) {
+	return SubcallFrom(ctx, caller, common.Address{}, method, body)
+}
+
+// SubcallFrom is like Subcall with the query sent from from, e.g. to read
+// state the module keeps per caller. The precompile only sees from as the
+// caller if the query is signed, i.e. caller is a wrapped client with a
+// signer for from.
+func SubcallFrom(ctx context.Context, caller bind.ContractCaller, from common.Address, method string, body interface{}) (SubcallResult, error) {
+	input, err := SubcallData(method, body)
+	if err != nil {
+		return SubcallResult{}, err
+	}
+	out, err := callFrom(ctx, caller, from, SubcallPrecompile, input)
+	if err != nil {
+		return SubcallResult{}, err
+	}
+	values, err := subcallOutput.Unpack(out)
+	if err != nil {
+		return SubcallResult{}, fmt.Errorf("failed to decode precompile output: %w", err)
+	}
+	return SubcallResult{Status: values[0].(uint64), Response: values[1].([]byte)}, nil
+}
+
+// SubcallTx sends a transaction calling method with body through the subcall
+// precompile, e.g. to move funds of the sender. Pass the backend's
+// Transactor as opts to have it encrypted.
+//
+// The result of the call is not part of the receipt and a call the module
+// fails doesn't fail the transaction, so check it with Subcall first where it
+// matters.
+func SubcallTx(opts *bind.TransactOpts, backend bind.ContractBackend, method string, body interface{}) (*types.Transaction, error) {
+	input, err := SubcallData(method, body)
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(SubcallAddress, abi.ABI{}, backend, backend, backend).RawTransact(opts, input)
+}
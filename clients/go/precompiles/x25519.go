@@ -0,0 +1,33 @@
+package precompiles
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles/local"
+)
+
+// X25519DeriveGas is the gas cost of X25519Derive.
+const X25519DeriveGas = 100_000
+
+// X25519Derive derives the symmetric key of publicKey and privateKey with the
+// key derivation precompile, like Sapphire.deriveSymmetricKey.
+func X25519Derive(ctx context.Context, caller bind.ContractCaller, publicKey, privateKey [32]byte) ([32]byte, error) {
+	// abi.encode(bytes32, bytes32) is the keys concatenated.
+	out, err := call(ctx, caller, X25519DerivePrecompile, append(publicKey[:], privateKey[:]...))
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if len(out) != 32 {
+		return [32]byte{}, fmt.Errorf("precompile returned a %d-byte key", len(out))
+	}
+	return [32]byte(out), nil
+}
+
+// X25519DeriveLocal derives the same key as X25519Derive, without calling the
+// precompile. It is local.X25519Derive.
+func X25519DeriveLocal(publicKey, privateKey [32]byte) [32]byte {
+	return local.X25519Derive(publicKey, privateKey)
+}
@@ -0,0 +1,137 @@
+package sapphire
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// RevertError is returned for calls and transactions the contract reverted,
+// whichever way the gateway reported the revert.
+type RevertError struct {
+	// Data is the revert data, e.g. an Error(string) or a custom error.
+	Data []byte
+	// Reason is the reason string of Error(string) reverts.
+	Reason string
+	// Err is the error the revert was reported with.
+	Err error
+}
+
+func newRevertError(data []byte, err error) *RevertError {
+	reason, _ := abi.UnpackRevert(data)
+	return &RevertError{Data: data, Reason: reason, Err: err}
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason == "" {
+		return "execution reverted"
+	}
+	return "execution reverted: " + e.Reason
+}
+
+func (e *RevertError) Unwrap() error {
+	return e.Err
+}
+
+// evmRevertedCode is the evm module's error code for reverted calls.
+const evmRevertedCode = 8
+
+// As makes evm module reverts match *RevertError.
+func (e *CallFailedError) As(target interface{}) bool {
+	revert, ok := target.(**RevertError)
+	if !ok || e.Module != "evm" || e.Code != evmRevertedCode {
+		return false
+	}
+	*revert = newRevertError(decodeEVMRevert(e.Message), e)
+	return true
+}
+
+// decodeEVMRevert extracts the revert data from an evm module revert message.
+func decodeEVMRevert(message string) []byte {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(message, "reverted: "))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// Prefixes of the messages gateways report reverts with, followed by the
+// base64 encoded revert data or the reason string.
+var revertMessagePrefixes = []string{
+	"execution reverted",
+	"reverted",
+}
+
+// RevertDataFromError returns the data of a call that failed with err
+// because the contract reverted, and whether it reverted at all. It
+// understands the formats of the Sapphire gateway: an evm module failure,
+// also when CBOR-encoded in the error data, hex revert data in the error
+// data, and base64 revert data or the reason string in the error message,
+// for which the data of an Error(string) revert is returned.
+func RevertDataFromError(err error) ([]byte, bool) {
+	if err == nil {
+		return nil, false
+	}
+	var revert *RevertError
+	if errors.As(err, &revert) {
+		return revert.Data, true
+	}
+	if failed, ok := DecodeModuleError(err); ok {
+		if failed.Module != "evm" || failed.Code != evmRevertedCode {
+			return nil, false
+		}
+		return decodeEVMRevert(failed.Message), true
+	}
+	var dataErr dataError
+	if errors.As(err, &dataErr) && strings.Contains(strings.ToLower(err.Error()), "revert") {
+		if s, ok := dataErr.ErrorData().(string); ok {
+			if data, err := hexutil.Decode(s); err == nil {
+				return data, true
+			}
+		}
+	}
+	return revertDataFromMessage(err.Error())
+}
+
+// revertDataFromMessage finds the revert in a gateway error message.
+func revertDataFromMessage(message string) ([]byte, bool) {
+	for _, prefix := range revertMessagePrefixes {
+		i := strings.Index(strings.ToLower(message), prefix)
+		if i < 0 {
+			continue
+		}
+		rest := message[i+len(prefix):]
+		if rest == "" {
+			return nil, true
+		}
+		if !strings.HasPrefix(rest, ": ") {
+			continue
+		}
+		rest = rest[2:]
+		if rest == "" {
+			return nil, true
+		}
+		if data, err := base64.StdEncoding.DecodeString(rest); err == nil && isRevertData(data) {
+			return data, true
+		}
+		return encodeRevertReason(rest), true
+	}
+	return nil, false
+}
+
+// isRevertData reports whether data is shaped like ABI encoded revert data,
+// a selector followed by 32-byte words, rather than a reason string that
+// happens to be valid base64.
+func isRevertData(data []byte) bool {
+	return len(data) >= 4 && (len(data)-4)%32 == 0
+}
+
+// encodeRevertReason returns the data of an Error(string) revert.
+func encodeRevertReason(reason string) []byte {
+	stringType, _ := abi.NewType("string", "", nil)
+	encoded, _ := abi.Arguments{{Type: stringType}}.Pack(reason)
+	return append([]byte{0x08, 0xc3, 0x79, 0xa0}, encoded...)
+}
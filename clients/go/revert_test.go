@@ -0,0 +1,46 @@
+package sapphire
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzRevertDataFromMessage fuzzes gateway error messages reporting reverts.
+func FuzzRevertDataFromMessage(f *testing.F) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "revert_error_responses.json"))
+	if err != nil {
+		f.Fatalf("failed to read responses: %v", err)
+	}
+	var responses []struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err = json.Unmarshal(raw, &responses); err != nil {
+		f.Fatalf("failed to decode responses: %v", err)
+	}
+	for _, resp := range responses {
+		f.Add(resp.Error.Message)
+	}
+	f.Add("execution reverted")
+	f.Add("reverted: ")
+
+	f.Fuzz(func(t *testing.T, message string) {
+		data, ok := revertDataFromMessage(message)
+		if !ok || data == nil {
+			return
+		}
+		if !isRevertData(data) {
+			t.Fatalf("expected revert data, got %x", data)
+		}
+		// Revert data decodes the same from the message of the evm module.
+		again, ok := revertDataFromMessage("reverted: " + base64.StdEncoding.EncodeToString(data))
+		if !ok || !bytes.Equal(again, data) {
+			t.Fatalf("expected %x to decode again, got %x", data, again)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+// Package rofl checks the origin of transactions for contracts that only
+// accept them from instances of a ROFL app, as Subcall.roflEnsureAuthorizedOrigin
+// does on chain:
+//
+//	app, _ := rofl.ParseAppID("rofl1...")
+//	ok, _ := rofl.IsAuthorizedOrigin(ctx, backend, app, sender)
+//
+// A relayer can use it to find out whether a transaction would pass the check
+// before submitting it.
+package rofl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/oasisprotocol/oasis-core/go/common/encoding/bech32"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+const (
+	// AppIDSize is the size of a raw app ID: a version byte followed by a
+	// 20-byte hash.
+	AppIDSize = 21
+	// AppIDHRP is the human readable part of bech32-encoded app IDs.
+	AppIDHRP = "rofl"
+
+	methodIsAuthorizedOrigin = "rofl.IsAuthorizedOrigin"
+)
+
+// ErrInvalidAppID is the error returned for malformed app IDs.
+var ErrInvalidAppID = errors.New("invalid ROFL app ID")
+
+// AppID identifies a ROFL app.
+type AppID [AppIDSize]byte
+
+// ParseAppID decodes the bech32-encoded app ID s, e.g. rofl1qq....
+func ParseAppID(s string) (AppID, error) {
+	var id AppID
+	if err := id.UnmarshalText([]byte(s)); err != nil {
+		return AppID{}, err
+	}
+	return id, nil
+}
+
+// String returns the bech32 encoding of id.
+func (id AppID) String() string {
+	s, err := bech32.Encode(AppIDHRP, id[:])
+	if err != nil {
+		// Encoding 21 bytes with a valid HRP can't fail.
+		panic(err)
+	}
+	return s
+}
+
+// Validate checks that id has a known version.
+func (id AppID) Validate() error {
+	if id[0] != 0 {
+		return fmt.Errorf("%w: unknown version %d", ErrInvalidAppID, id[0])
+	}
+	return nil
+}
+
+// MarshalText encodes id in bech32.
+func (id AppID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText decodes a bech32-encoded app ID, checking its checksum,
+// human readable part, length and version.
+func (id *AppID) UnmarshalText(text []byte) error {
+	hrp, data, err := bech32.Decode(string(text))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAppID, err)
+	}
+	if hrp != AppIDHRP {
+		return fmt.Errorf("%w: human readable part %q, expected %q", ErrInvalidAppID, hrp, AppIDHRP)
+	}
+	return id.UnmarshalBinary(data)
+}
+
+// MarshalBinary returns the raw app ID, which is also how it is CBOR-encoded
+// in calls to the rofl module.
+func (id AppID) MarshalBinary() ([]byte, error) {
+	return append([]byte{}, id[:]...), nil
+}
+
+// UnmarshalBinary decodes a raw app ID, checking its length and version.
+func (id *AppID) UnmarshalBinary(data []byte) error {
+	if len(data) != AppIDSize {
+		return fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidAppID, len(data), AppIDSize)
+	}
+	parsed := AppID(data)
+	if err := parsed.Validate(); err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// IsAuthorizedOriginData returns the calldata of a subcall checking that the
+// transaction is signed by an endorsed key of an instance of app, the check
+// of Subcall.roflEnsureAuthorizedOrigin. The subcall returns a CBOR bool.
+func IsAuthorizedOriginData(app AppID) ([]byte, error) {
+	if err := app.Validate(); err != nil {
+		return nil, err
+	}
+	return precompiles.SubcallData(methodIsAuthorizedOrigin, app)
+}
+
+// IsAuthorizedOrigin returns whether a transaction from origin would pass
+// the authorized origin check of app. The check is simulated with a query
+// from origin, which must be signed for the runtime to see origin as the
+// sender: pass a wrapped client with a signer for origin.
+func IsAuthorizedOrigin(ctx context.Context, caller bind.ContractCaller, app AppID, origin common.Address) (bool, error) {
+	if err := app.Validate(); err != nil {
+		return false, err
+	}
+	res, err := precompiles.SubcallFrom(ctx, caller, origin, methodIsAuthorizedOrigin, app)
+	if err != nil {
+		return false, err
+	}
+	var authorized bool
+	if err = res.Decode(&authorized); err != nil {
+		return false, fmt.Errorf("failed to check origin of app %s: %w", app, err)
+	}
+	return authorized, nil
+}
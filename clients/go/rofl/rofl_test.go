@@ -0,0 +1,137 @@
+package rofl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/encoding/bech32"
+	"pgregory.net/rapid"
+
+	"github.com/oasisprotocol/sapphire-paratime/clients/go/precompiles"
+)
+
+const testApp = "rofl1qqn9xndja7e2pnxhttktmecvwzz0yqwxsquqyxdf"
+
+func TestParseAppID(t *testing.T) {
+	id, err := ParseAppID(testApp)
+	if err != nil {
+		t.Fatalf("ParseAppID failed: %v", err)
+	}
+	if id.String() != testApp {
+		t.Fatalf("unexpected encoding %s", id)
+	}
+	if raw, _ := id.MarshalBinary(); !bytes.Equal(raw, common.FromHex("0026534db2efb2a0ccd75aecbde70c7084f201c680")) {
+		t.Fatalf("unexpected raw app ID %x", raw)
+	}
+	var decoded AppID
+	if err = decoded.UnmarshalBinary(id[:]); err != nil || decoded != id {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	badVersion := id
+	badVersion[0] = 1
+	wrongVersion, _ := bech32.Encode(AppIDHRP, badVersion[:])
+	short, _ := bech32.Encode(AppIDHRP, id[:20])
+	wrongHRP, _ := bech32.Encode("oasis", id[:])
+	for _, s := range []string{
+		"",
+		"rofl1",
+		testApp[:len(testApp)-1] + "g",    // Bad checksum.
+		testApp[:10] + "b" + testApp[11:], // Invalid character.
+		"ROFL1" + testApp[5:10] + "q" + testApp[11:],      // Mixed case.
+		"rofl1qqn9xndja7e2pnxhttktmecvwzz0yqwxsquqyxdfqq", // Too long.
+		short,
+		wrongHRP,
+		"oasis1qrk58a6j2qn065m6p06jgjyt032f7qucy5wqeqpt",
+		wrongVersion,
+	} {
+		if _, err := ParseAppID(s); !errors.Is(err, ErrInvalidAppID) {
+			t.Fatalf("expected %q to be rejected, got %v", s, err)
+		}
+	}
+	if err = decoded.UnmarshalBinary(id[:20]); !errors.Is(err, ErrInvalidAppID) {
+		t.Fatalf("expected a short raw app ID to be rejected, got %v", err)
+	}
+}
+
+type fakeCaller struct {
+	respond func(msg ethereum.CallMsg) ([]byte, error)
+}
+
+func (c *fakeCaller) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (c *fakeCaller) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	return c.respond(msg)
+}
+
+func TestIsAuthorizedOrigin(t *testing.T) {
+	app, _ := ParseAppID(testApp)
+	data, err := IsAuthorizedOriginData(app)
+	if err != nil {
+		t.Fatalf("IsAuthorizedOriginData failed: %v", err)
+	}
+	stringType, _ := abi.NewType("string", "", nil)
+	bytesType, _ := abi.NewType("bytes", "", nil)
+	uint64Type, _ := abi.NewType("uint64", "", nil)
+	values, err := abi.Arguments{{Type: stringType}, {Type: bytesType}}.Unpack(data)
+	if err != nil || values[0] != "rofl.IsAuthorizedOrigin" {
+		t.Fatalf("unexpected subcall %v: %v", values, err)
+	}
+	// The app ID is a CBOR byte string.
+	if body := values[1].([]byte); !bytes.Equal(body, append([]byte{0x55}, app[:]...)) {
+		t.Fatalf("unexpected body %x", body)
+	}
+
+	origin := common.HexToAddress("0x1234")
+	caller := &fakeCaller{respond: func(msg ethereum.CallMsg) ([]byte, error) {
+		if msg.From != origin || *msg.To != precompiles.SubcallAddress || !bytes.Equal(msg.Data, data) {
+			t.Fatalf("unexpected call %+v", msg)
+		}
+		return abi.Arguments{{Type: uint64Type}, {Type: bytesType}}.Pack(uint64(0), cbor.Marshal(true))
+	}}
+	ok, err := IsAuthorizedOrigin(context.Background(), caller, app, origin)
+	if err != nil || !ok {
+		t.Fatalf("IsAuthorizedOrigin failed: %t, %v", ok, err)
+	}
+
+	var invalid AppID
+	invalid[0] = 2
+	if _, err = IsAuthorizedOriginData(invalid); !errors.Is(err, ErrInvalidAppID) {
+		t.Fatalf("expected an invalid app ID to be rejected, got %v", err)
+	}
+	if _, err = IsAuthorizedOrigin(context.Background(), caller, invalid, origin); !errors.Is(err, ErrInvalidAppID) {
+		t.Fatalf("expected an invalid app ID to be rejected, got %v", err)
+	}
+}
+
+func TestPropertyAppIDRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		var id AppID
+		id[0] = rapid.OneOf(rapid.Just[byte](0), rapid.Byte()).Draw(t, "version")
+		copy(id[1:], rapid.SliceOfN(rapid.Byte(), AppIDSize-1, AppIDSize-1).Draw(t, "id"))
+		parsed, err := ParseAppID(id.String())
+		if id.Validate() != nil {
+			if !errors.Is(err, ErrInvalidAppID) {
+				t.Fatalf("expected version %d to be refused, got %v", id[0], err)
+			}
+			return
+		}
+		if err != nil || parsed != id {
+			t.Fatalf("%s parsed to %x: %v", id, parsed, err)
+		}
+		var decoded AppID
+		if err = cbor.Unmarshal(cbor.Marshal(id), &decoded); err != nil || decoded != id {
+			t.Fatalf("%x decoded to %x: %v", id, decoded, err)
+		}
+	})
+}
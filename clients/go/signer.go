@@ -0,0 +1,175 @@
+package sapphire
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrNoSigner is returned when an operation requires a signature from an
+// account the wrapped client has no signer for.
+var ErrNoSigner = errors.New("no signer available for account")
+
+// SignerFn is a function that produces secp256k1 signatures in RSV format.
+type SignerFn = func(digest [32]byte) ([]byte, error)
+
+// Signer produces secp256k1 signatures in RSV format.
+type Signer interface {
+	// SignRSV returns a 65-byte secp256k1 signature as (R || S || V) over the provided digest.
+	SignRSV(digest [32]byte) ([]byte, error)
+}
+
+// SignerWithAddress is a Signer that also knows the address of its key.
+type SignerWithAddress interface {
+	Signer
+	// Address returns the Ethereum address corresponding to the signing key.
+	Address() common.Address
+}
+
+// ContextSigner is a Signer that can abandon signing when the context is
+// done, e.g. one backed by a remote signing service.
+type ContextSigner interface {
+	Signer
+	// SignRSVContext is like SignRSV but returns early with ctx.Err() when ctx is done.
+	SignRSVContext(ctx context.Context, digest [32]byte) ([]byte, error)
+}
+
+// SignDigest signs the digest with s, passing ctx on to signers that support
+// it.
+//
+// Signers that are not ContextSigners cannot be interrupted, so ctx is only
+// checked before and after they sign.
+func SignDigest(ctx context.Context, s Signer, digest [32]byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if cs, ok := s.(ContextSigner); ok {
+		return cs.SignRSVContext(ctx, digest)
+	}
+	sig, err := s.SignRSV(digest)
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+type signerContextKey struct{}
+
+// ContextWithSigner returns a copy of ctx carrying signer. Wrapped clients
+// sign queries and transactions from the signer's address made with the
+// returned context with it, in preference to their keyring and SignerFn.
+func ContextWithSigner(ctx context.Context, signer SignerWithAddress) context.Context {
+	return context.WithValue(ctx, signerContextKey{}, signer)
+}
+
+// SignerFromContext returns the signer carried by ctx, see ContextWithSigner,
+// if it is for account.
+func SignerFromContext(ctx context.Context, account common.Address) (Signer, bool) {
+	s, ok := ctx.Value(signerContextKey{}).(SignerWithAddress)
+	if !ok || s.Address() != account {
+		return nil, false
+	}
+	return s, true
+}
+
+type addressedSigner struct {
+	address common.Address
+	sign    SignerFn
+}
+
+// NewSignerWithAddress binds a SignerFn to the address of the key it signs with.
+func NewSignerWithAddress(address common.Address, sign SignerFn) SignerWithAddress {
+	return addressedSigner{address, sign}
+}
+
+func (s addressedSigner) SignRSV(digest [32]byte) ([]byte, error) {
+	return s.sign(digest)
+}
+
+func (s addressedSigner) Address() common.Address {
+	return s.address
+}
+
+type privateKeySigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewPrivateKeySigner creates a signer backed by an in-memory secp256k1 private key.
+func NewPrivateKeySigner(key *ecdsa.PrivateKey) SignerWithAddress {
+	return privateKeySigner{
+		key:     key,
+		address: crypto.PubkeyToAddress(key.PublicKey),
+	}
+}
+
+func (s privateKeySigner) SignRSV(digest [32]byte) ([]byte, error) {
+	return crypto.Sign(digest[:], s.key)
+}
+
+func (s privateKeySigner) Address() common.Address {
+	return s.address
+}
+
+// Keyring is a set of signers indexed by their address.
+//
+// A Keyring is safe for concurrent use and may be shared between wrapped clients.
+type Keyring struct {
+	mu      sync.RWMutex
+	signers map[common.Address]SignerWithAddress
+}
+
+// NewKeyring creates a keyring holding the given signers.
+func NewKeyring(signers ...SignerWithAddress) *Keyring {
+	k := &Keyring{
+		signers: make(map[common.Address]SignerWithAddress, len(signers)),
+	}
+	for _, s := range signers {
+		k.signers[s.Address()] = s
+	}
+	return k
+}
+
+// Add adds a signer to the keyring, replacing any signer for the same address.
+func (k *Keyring) Add(s SignerWithAddress) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.signers[s.Address()] = s
+}
+
+// Remove removes the signer for the given address from the keyring.
+func (k *Keyring) Remove(address common.Address) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.signers, address)
+}
+
+// Signer returns the signer for the given address, if any.
+func (k *Keyring) Signer(address common.Address) (SignerWithAddress, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	s, ok := k.signers[address]
+	return s, ok
+}
+
+// Addresses returns the addresses of all signers in the keyring in ascending order.
+func (k *Keyring) Addresses() []common.Address {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	addrs := make([]common.Address, 0, len(k.signers))
+	for addr := range k.signers {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].Cmp(addrs[j]) < 0
+	})
+	return addrs
+}
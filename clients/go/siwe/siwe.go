@@ -0,0 +1,422 @@
+// Package siwe builds, signs and verifies EIP-4361 Sign-In with Ethereum
+// messages, with which users log in to contracts deriving from SiweAuth:
+//
+//	msg := &siwe.Message{Domain: "example.com", Address: addr, URI: "https://example.com", ChainID: 0x5afd, Nonce: nonce, IssuedAt: time.Now()}
+//	login, _ := siwe.Sign(ctx, signer, msg)
+//	data, _ := login.CallData()
+//
+// The contract returns an encrypted bearer token from the login call, which
+// authenticates later queries as from the user.
+package siwe
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+const (
+	// Version is the version of EIP-4361 messages, the only one there is.
+	Version = "1"
+	// MinNonceLength is the shortest nonce the specification and SiweAuth
+	// accept.
+	MinNonceLength = 8
+
+	preamble = " wants you to sign in with your Ethereum account:"
+)
+
+var (
+	// ErrInvalidMessage is returned for messages that are not valid EIP-4361
+	// messages.
+	ErrInvalidMessage = errors.New("invalid SIWE message")
+	// ErrInvalidSignature is returned for signatures that were not made by
+	// the message's address.
+	ErrInvalidSignature = errors.New("invalid SIWE signature")
+	// ErrDomainMismatch is returned for messages for another domain.
+	ErrDomainMismatch = errors.New("SIWE domain mismatch")
+	// ErrChainIDMismatch is returned for messages for another chain.
+	ErrChainIDMismatch = errors.New("SIWE chain ID mismatch")
+	// ErrNonceMismatch is returned for messages with another nonce than the
+	// one the server handed out.
+	ErrNonceMismatch = errors.New("SIWE nonce mismatch")
+	// ErrNotYetValid is returned for messages whose Not Before time has not
+	// passed yet.
+	ErrNotYetValid = errors.New("SIWE message not yet valid")
+	// ErrExpired is returned for messages whose Expiration Time has passed.
+	ErrExpired = errors.New("SIWE message expired")
+)
+
+// Message is an EIP-4361 message. Optional fields are left out of the
+// message when empty.
+type Message struct {
+	// Scheme is the optional URI scheme of the domain, e.g. https. SiweAuth
+	// contracts compare the scheme and domain with the domain they were
+	// deployed for.
+	Scheme string
+	// Domain is the RFC 3986 authority, e.g. example.com:8080, of the site
+	// requesting the sign-in.
+	Domain  string
+	Address common.Address
+	// Statement is an optional statement for the user to accept. It must
+	// not contain newlines.
+	Statement string
+	URI       string
+	// Version is the message version, Version if empty.
+	Version string
+	ChainID uint64
+	// Nonce is a random alphanumeric string of at least MinNonceLength
+	// characters, see NewNonce.
+	Nonce     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	NotBefore time.Time
+	RequestID string
+	Resources []string
+}
+
+// NewNonce returns a random nonce for a message.
+func NewNonce() (string, error) {
+	const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	raw := make([]byte, 17)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	for i, b := range raw {
+		raw[i] = alphabet[int(b)%len(alphabet)]
+	}
+	return string(raw), nil
+}
+
+// Validate checks that m is a valid EIP-4361 message, as SiweAuth would
+// parse it.
+func (m *Message) Validate() error {
+	switch {
+	case m.Domain == "" || strings.ContainsAny(m.Domain, " \n/"):
+		return fmt.Errorf("%w: invalid domain %q", ErrInvalidMessage, m.Domain)
+	case strings.ContainsAny(m.Scheme, " \n:/"):
+		return fmt.Errorf("%w: invalid scheme %q", ErrInvalidMessage, m.Scheme)
+	case strings.Contains(m.Statement, "\n"):
+		return fmt.Errorf("%w: statement contains a newline", ErrInvalidMessage)
+	case m.URI == "" || strings.ContainsAny(m.URI, " \n"):
+		return fmt.Errorf("%w: invalid URI %q", ErrInvalidMessage, m.URI)
+	case m.Version != "" && m.Version != Version:
+		return fmt.Errorf("%w: unsupported version %q", ErrInvalidMessage, m.Version)
+	case m.ChainID == 0:
+		return fmt.Errorf("%w: no chain ID", ErrInvalidMessage)
+	case m.IssuedAt.IsZero():
+		return fmt.Errorf("%w: no issued at time", ErrInvalidMessage)
+	case strings.Contains(m.RequestID, "\n"):
+		return fmt.Errorf("%w: request ID contains a newline", ErrInvalidMessage)
+	}
+	if len(m.Nonce) < MinNonceLength {
+		return fmt.Errorf("%w: nonce shorter than %d characters", ErrInvalidMessage, MinNonceLength)
+	}
+	for _, c := range m.Nonce {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return fmt.Errorf("%w: nonce %q is not alphanumeric", ErrInvalidMessage, m.Nonce)
+		}
+	}
+	for _, r := range m.Resources {
+		if r == "" || strings.ContainsAny(r, " \n") {
+			return fmt.Errorf("%w: invalid resource %q", ErrInvalidMessage, r)
+		}
+	}
+	return nil
+}
+
+// String returns the text of m, which the user signs. Times are in UTC, as
+// SiweAuth ignores time zones.
+func (m *Message) String() string {
+	var b strings.Builder
+	if m.Scheme != "" {
+		b.WriteString(m.Scheme + "://")
+	}
+	b.WriteString(m.Domain + preamble + "\n")
+	b.WriteString(m.Address.Hex() + "\n\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\n")
+	version := m.Version
+	if version == "" {
+		version = Version
+	}
+	fmt.Fprintf(&b, "URI: %s\nVersion: %s\nChain ID: %d\nNonce: %s\nIssued At: %s", m.URI, version, m.ChainID, m.Nonce, formatTime(m.IssuedAt))
+	if !m.ExpiresAt.IsZero() {
+		b.WriteString("\nExpiration Time: " + formatTime(m.ExpiresAt))
+	}
+	if !m.NotBefore.IsZero() {
+		b.WriteString("\nNot Before: " + formatTime(m.NotBefore))
+	}
+	if m.RequestID != "" {
+		b.WriteString("\nRequest ID: " + m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\nResources:")
+		for _, r := range m.Resources {
+			b.WriteString("\n- " + r)
+		}
+	}
+	return b.String()
+}
+
+// formatTime formats t as RFC 3339 in UTC, with milliseconds if it has any
+// as JavaScript's toISOString.
+func formatTime(t time.Time) string {
+	switch t = t.UTC(); {
+	case t.Nanosecond() == 0:
+		return t.Format(time.RFC3339)
+	case t.Nanosecond()%int(time.Millisecond) == 0:
+		return t.Format("2006-01-02T15:04:05.000Z07:00")
+	default:
+		return t.Format(time.RFC3339Nano)
+	}
+}
+
+// Parse parses the text of an EIP-4361 message.
+func Parse(text string) (*Message, error) {
+	lines := strings.Split(text, "\n")
+	next := func() (string, bool) {
+		if len(lines) == 0 {
+			return "", false
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, true
+	}
+	field := func(name string, optional bool) (string, error) {
+		if len(lines) > 0 && strings.HasPrefix(lines[0], name+": ") {
+			line, _ := next()
+			return strings.TrimPrefix(line, name+": "), nil
+		}
+		if optional {
+			return "", nil
+		}
+		return "", fmt.Errorf("%w: no %s", ErrInvalidMessage, name)
+	}
+	parseTime := func(name string, optional bool) (time.Time, error) {
+		v, err := field(name, optional)
+		if err != nil || v == "" {
+			return time.Time{}, err
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: invalid %s %q", ErrInvalidMessage, name, v)
+		}
+		return t, nil
+	}
+
+	m := &Message{}
+	header, _ := next()
+	authority, ok := strings.CutSuffix(header, preamble)
+	if !ok {
+		return nil, fmt.Errorf("%w: no preamble", ErrInvalidMessage)
+	}
+	if scheme, domain, ok := strings.Cut(authority, "://"); ok {
+		m.Scheme, m.Domain = scheme, domain
+	} else {
+		m.Domain = authority
+	}
+	address, _ := next()
+	if !common.IsHexAddress(address) || common.HexToAddress(address).Hex() != address {
+		return nil, fmt.Errorf("%w: address %q is not EIP-55 checksummed", ErrInvalidMessage, address)
+	}
+	m.Address = common.HexToAddress(address)
+	if line, ok := next(); !ok || line != "" {
+		return nil, fmt.Errorf("%w: no empty line after the address", ErrInvalidMessage)
+	}
+	if len(lines) > 0 && lines[0] != "" {
+		m.Statement, _ = next()
+	}
+	if line, ok := next(); !ok || line != "" {
+		return nil, fmt.Errorf("%w: no empty line after the statement", ErrInvalidMessage)
+	}
+
+	var err error
+	if m.URI, err = field("URI", false); err != nil {
+		return nil, err
+	}
+	if m.Version, err = field("Version", false); err != nil {
+		return nil, err
+	}
+	chainID, err := field("Chain ID", false)
+	if err != nil {
+		return nil, err
+	}
+	if m.ChainID, err = strconv.ParseUint(chainID, 10, 64); err != nil {
+		return nil, fmt.Errorf("%w: invalid chain ID %q", ErrInvalidMessage, chainID)
+	}
+	if m.Nonce, err = field("Nonce", false); err != nil {
+		return nil, err
+	}
+	if m.IssuedAt, err = parseTime("Issued At", false); err != nil {
+		return nil, err
+	}
+	if m.ExpiresAt, err = parseTime("Expiration Time", true); err != nil {
+		return nil, err
+	}
+	if m.NotBefore, err = parseTime("Not Before", true); err != nil {
+		return nil, err
+	}
+	if m.RequestID, err = field("Request ID", true); err != nil {
+		return nil, err
+	}
+	if len(lines) > 0 && lines[0] == "Resources:" {
+		next()
+		for len(lines) > 0 && strings.HasPrefix(lines[0], "- ") {
+			line, _ := next()
+			m.Resources = append(m.Resources, strings.TrimPrefix(line, "- "))
+		}
+	}
+	if len(lines) > 0 {
+		return nil, fmt.Errorf("%w: unexpected line %q", ErrInvalidMessage, lines[0])
+	}
+	if err = m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SignatureRSV is a signature as SiweAuth's login function takes it.
+type SignatureRSV struct {
+	R [32]byte
+	S [32]byte
+	V *big.Int
+}
+
+// Login is a signed message, the arguments of SiweAuth's login function.
+type Login struct {
+	Message   string
+	Signature SignatureRSV
+}
+
+// Sign signs the text of m with signer, which must be the key of m.Address,
+// as personal_sign does.
+func Sign(ctx context.Context, signer sapphire.Signer, m *Message) (*Login, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	text := m.String()
+	digest := common.BytesToHash(accounts.TextHash([]byte(text)))
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var (
+		signature []byte
+		err       error
+	)
+	if cs, ok := signer.(sapphire.ContextSigner); ok {
+		signature, err = cs.SignRSVContext(ctx, digest)
+	} else {
+		signature, err = signer.SignRSV(digest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign SIWE message: %w", err)
+	}
+	if signature, err = sapphire.NormalizeV(signature); err != nil {
+		return nil, fmt.Errorf("failed to sign SIWE message: %w", err)
+	}
+	l := &Login{Message: text, Signature: SignatureRSV{V: big.NewInt(int64(signature[64]))}}
+	copy(l.Signature.R[:], signature[:32])
+	copy(l.Signature.S[:], signature[32:64])
+	return l, nil
+}
+
+// Bytes returns the signature as (R || S || V), with V 27 or 28.
+func (s SignatureRSV) Bytes() []byte {
+	signature := make([]byte, 0, 65)
+	signature = append(signature, s.R[:]...)
+	signature = append(signature, s.S[:]...)
+	return append(signature, byte(s.V.Uint64()))
+}
+
+// loginArguments are the arguments of login(string,(bytes32,bytes32,uint256)).
+var loginArguments = func() abi.Arguments {
+	stringType, _ := abi.NewType("string", "", nil)
+	sigType, _ := abi.NewType("tuple", "", []abi.ArgumentMarshaling{
+		{Name: "r", Type: "bytes32"},
+		{Name: "s", Type: "bytes32"},
+		{Name: "v", Type: "uint256"},
+	})
+	return abi.Arguments{{Name: "siweMsg", Type: stringType}, {Name: "sig", Type: sigType}}
+}()
+
+var loginSelector = crypto.Keccak256([]byte("login(string,(bytes32,bytes32,uint256))"))[:4]
+
+// CallData returns the calldata of a call to SiweAuth's login function with
+// l, which returns the bearer token.
+func (l *Login) CallData() ([]byte, error) {
+	packed, err := loginArguments.Pack(l.Message, l.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack login call: %w", err)
+	}
+	return append(append([]byte(nil), loginSelector...), packed...), nil
+}
+
+// VerifyOptions are what Verify expects of a message, beyond a valid
+// signature. Zero fields are not checked, except Now.
+type VerifyOptions struct {
+	// Scheme and Domain are those of the server, compared as SiweAuth
+	// compares them with the domain it was deployed for.
+	Scheme, Domain string
+	ChainID        uint64
+	// Nonce is the nonce the server handed out for the message.
+	Nonce string
+	// Now is the time the message is checked at, the current time if zero.
+	Now time.Time
+}
+
+// Verify parses the text of a message signed by its address and checks it
+// against opts, as SiweAuth does on login. Messages without an expiration
+// time don't expire.
+func Verify(text string, signature []byte, opts VerifyOptions) (*Message, error) {
+	m, err := Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	signature, err = sapphire.NormalizeV(signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	signature[64] -= 27
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(text)), signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != m.Address {
+		return nil, fmt.Errorf("%w: signed by %s, not %s", ErrInvalidSignature, signer.Hex(), m.Address.Hex())
+	}
+
+	if opts.Domain != "" && (m.Domain != opts.Domain || m.Scheme != opts.Scheme) {
+		return nil, fmt.Errorf("%w: %q", ErrDomainMismatch, strings.SplitN(text, preamble, 2)[0])
+	}
+	if opts.ChainID != 0 && m.ChainID != opts.ChainID {
+		return nil, fmt.Errorf("%w: %d", ErrChainIDMismatch, m.ChainID)
+	}
+	if opts.Nonce != "" && m.Nonce != opts.Nonce {
+		return nil, fmt.Errorf("%w: %q", ErrNonceMismatch, m.Nonce)
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if !m.NotBefore.IsZero() && now.Before(m.NotBefore) {
+		return nil, fmt.Errorf("%w: not before %s", ErrNotYetValid, formatTime(m.NotBefore))
+	}
+	if !m.ExpiresAt.IsZero() && !now.Before(m.ExpiresAt) {
+		return nil, fmt.Errorf("%w: at %s", ErrExpired, formatTime(m.ExpiresAt))
+	}
+	return m, nil
+}
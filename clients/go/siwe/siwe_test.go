@@ -0,0 +1,296 @@
+package siwe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// specMessages are example messages from EIP-4361.
+var specMessages = map[string]struct {
+	text    string
+	message Message
+}{
+	"example": {
+		text: `example.com wants you to sign in with your Ethereum account:
+0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2
+
+I accept the ExampleOrg Terms of Service: https://example.com/tos
+
+URI: https://example.com/login
+Version: 1
+Chain ID: 1
+Nonce: 32891756
+Issued At: 2021-09-30T16:25:24Z
+Resources:
+- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/
+- https://example.com/my-web2-claim.json`,
+		message: Message{
+			Domain:    "example.com",
+			Address:   common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+			Statement: "I accept the ExampleOrg Terms of Service: https://example.com/tos",
+			URI:       "https://example.com/login",
+			Version:   "1",
+			ChainID:   1,
+			Nonce:     "32891756",
+			IssuedAt:  time.Date(2021, 9, 30, 16, 25, 24, 0, time.UTC),
+			Resources: []string{
+				"ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/",
+				"https://example.com/my-web2-claim.json",
+			},
+		},
+	},
+	"scheme": {
+		text: `https://example.com wants you to sign in with your Ethereum account:
+0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2
+
+I accept the ExampleOrg Terms of Service: https://example.com/tos
+
+URI: https://example.com/login
+Version: 1
+Chain ID: 1
+Nonce: 32891756
+Issued At: 2021-09-30T16:25:24Z
+Resources:
+- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/
+- https://example.com/my-web2-claim.json`,
+		message: Message{
+			Scheme:    "https",
+			Domain:    "example.com",
+			Address:   common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+			Statement: "I accept the ExampleOrg Terms of Service: https://example.com/tos",
+			URI:       "https://example.com/login",
+			Version:   "1",
+			ChainID:   1,
+			Nonce:     "32891756",
+			IssuedAt:  time.Date(2021, 9, 30, 16, 25, 24, 0, time.UTC),
+			Resources: []string{
+				"ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/",
+				"https://example.com/my-web2-claim.json",
+			},
+		},
+	},
+	"all optional fields": {
+		text: `service.org:8080 wants you to sign in with your Ethereum account:
+0xe5A12547fe4E872D192E3eCecb76F2Ce1aeA4946
+
+I accept the ServiceOrg Terms of Service: https://service.org/tos
+
+URI: https://service.org/login
+Version: 1
+Chain ID: 1
+Nonce: 12341234
+Issued At: 2022-03-17T12:45:13.610Z
+Expiration Time: 2023-03-17T12:45:13.610Z
+Not Before: 2022-03-17T12:45:13.610Z
+Request ID: some_id
+Resources:
+- https://service.org/login`,
+		message: Message{
+			Domain:    "service.org:8080",
+			Address:   common.HexToAddress("0xe5A12547fe4E872D192E3eCecb76F2Ce1aeA4946"),
+			Statement: "I accept the ServiceOrg Terms of Service: https://service.org/tos",
+			URI:       "https://service.org/login",
+			Version:   "1",
+			ChainID:   1,
+			Nonce:     "12341234",
+			IssuedAt:  time.Date(2022, 3, 17, 12, 45, 13, 610_000_000, time.UTC),
+			ExpiresAt: time.Date(2023, 3, 17, 12, 45, 13, 610_000_000, time.UTC),
+			NotBefore: time.Date(2022, 3, 17, 12, 45, 13, 610_000_000, time.UTC),
+			RequestID: "some_id",
+			Resources: []string{"https://service.org/login"},
+		},
+	},
+	"no statement": {
+		text: `service.org wants you to sign in with your Ethereum account:
+0xe5A12547fe4E872D192E3eCecb76F2Ce1aeA4946
+
+
+URI: https://service.org/login
+Version: 1
+Chain ID: 1
+Nonce: 12341234
+Issued At: 2022-03-17T12:45:13.610Z`,
+		message: Message{
+			Domain:   "service.org",
+			Address:  common.HexToAddress("0xe5A12547fe4E872D192E3eCecb76F2Ce1aeA4946"),
+			URI:      "https://service.org/login",
+			Version:  "1",
+			ChainID:  1,
+			Nonce:    "12341234",
+			IssuedAt: time.Date(2022, 3, 17, 12, 45, 13, 610_000_000, time.UTC),
+		},
+	},
+}
+
+func TestParse(t *testing.T) {
+	for name, tc := range specMessages {
+		m, err := Parse(tc.text)
+		if err != nil {
+			t.Fatalf("%s: failed to parse: %v", name, err)
+		}
+		if m.String() != tc.text {
+			t.Fatalf("%s: expected the message to format as parsed, got\n%s", name, m.String())
+		}
+		if expected := tc.message.String(); expected != tc.text {
+			t.Fatalf("%s: expected the message to format as\n%s\ngot\n%s", name, tc.text, expected)
+		}
+		if m.Domain != tc.message.Domain || m.Scheme != tc.message.Scheme || m.Address != tc.message.Address || !m.IssuedAt.Equal(tc.message.IssuedAt) || len(m.Resources) != len(tc.message.Resources) {
+			t.Fatalf("%s: expected %+v, got %+v", name, tc.message, m)
+		}
+	}
+
+	valid := specMessages["example"].text
+	for name, text := range map[string]string{
+		"no preamble":         strings.Replace(valid, "wants you", "asks you", 1),
+		"unchecksummed":       strings.Replace(valid, "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", 1),
+		"short nonce":         strings.Replace(valid, "Nonce: 32891756", "Nonce: 1234", 1),
+		"invalid chain ID":    strings.Replace(valid, "Chain ID: 1", "Chain ID: one", 1),
+		"invalid time":        strings.Replace(valid, "2021-09-30T16:25:24Z", "2021-09-30 16:25:24", 1),
+		"missing URI":         strings.Replace(valid, "URI: https://example.com/login\n", "", 1),
+		"unsupported version": strings.Replace(valid, "Version: 1", "Version: 2", 1),
+		"trailing line":       valid + "\nfoo",
+	} {
+		if _, err := Parse(text); !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("%s: expected ErrInvalidMessage, got %v", name, err)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	ctx := context.Background()
+	key, _ := crypto.HexToECDSA("ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+	signer := sapphire.NewPrivateKeySigner(key)
+	nonce, err := NewNonce()
+	if err != nil || len(nonce) < MinNonceLength {
+		t.Fatalf("NewNonce returned %q: %v", nonce, err)
+	}
+	issued := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := &Message{
+		Scheme:    "http",
+		Domain:    "localhost",
+		Address:   signer.Address(),
+		Statement: "I accept the ExampleOrg Terms of Service: http://localhost/tos",
+		URI:       "http://localhost:5173",
+		ChainID:   0x5afd,
+		Nonce:     nonce,
+		IssuedAt:  issued,
+		ExpiresAt: issued.Add(time.Hour),
+	}
+	login, err := Sign(ctx, signer, m)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if v := login.Signature.V.Uint64(); v != 27 && v != 28 {
+		t.Fatalf("expected V of 27 or 28, got %d", v)
+	}
+
+	// The signature is a personal_sign signature of the message.
+	sig := login.Signature.Bytes()
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(login.Message)), sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != signer.Address() {
+		t.Fatalf("signature does not recover to the signer: %v", err)
+	}
+
+	opts := VerifyOptions{Scheme: "http", Domain: "localhost", ChainID: 0x5afd, Nonce: nonce, Now: issued}
+	if _, err = Verify(login.Message, login.Signature.Bytes(), opts); err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	for _, tc := range []struct {
+		name     string
+		modify   func(*VerifyOptions)
+		expected error
+	}{
+		{"domain", func(o *VerifyOptions) { o.Domain = "localhost2" }, ErrDomainMismatch},
+		{"scheme", func(o *VerifyOptions) { o.Scheme = "https" }, ErrDomainMismatch},
+		{"chain ID", func(o *VerifyOptions) { o.ChainID = 0x5aff }, ErrChainIDMismatch},
+		{"nonce", func(o *VerifyOptions) { o.Nonce = "12345678" }, ErrNonceMismatch},
+		{"expired", func(o *VerifyOptions) { o.Now = issued.Add(time.Hour) }, ErrExpired},
+	} {
+		o := opts
+		tc.modify(&o)
+		if _, err = Verify(login.Message, login.Signature.Bytes(), o); !errors.Is(err, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, err)
+		}
+	}
+
+	m.NotBefore = issued.Add(time.Minute)
+	if login, err = Sign(ctx, signer, m); err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if _, err = Verify(login.Message, login.Signature.Bytes(), opts); !errors.Is(err, ErrNotYetValid) {
+		t.Fatalf("expected ErrNotYetValid, got %v", err)
+	}
+
+	// Messages signed for another address are rejected.
+	m.Address = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	if login, err = Sign(ctx, signer, m); err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if _, err = Verify(login.Message, login.Signature.Bytes(), VerifyOptions{Now: issued}); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+
+	m.Nonce = "short"
+	if _, err = Sign(ctx, signer, m); !errors.Is(err, ErrInvalidMessage) {
+		t.Fatalf("expected an invalid message not to be signed, got %v", err)
+	}
+}
+
+func TestLoginCallData(t *testing.T) {
+	login := &Login{
+		Message:   specMessages["example"].text,
+		Signature: SignatureRSV{R: [32]byte{1}, S: [32]byte{2}, V: big.NewInt(28)},
+	}
+	data, err := login.CallData()
+	if err != nil {
+		t.Fatalf("failed to pack login call: %v", err)
+	}
+	parsed, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"login","stateMutability":"view","inputs":[
+		{"name":"siweMsg","type":"string"},
+		{"name":"sig","type":"tuple","components":[{"name":"r","type":"bytes32"},{"name":"s","type":"bytes32"},{"name":"v","type":"uint256"}]}],
+		"outputs":[{"name":"","type":"bytes"}]}]`))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	expected, err := parsed.Pack("login", login.Message, login.Signature)
+	if err != nil {
+		t.Fatalf("failed to pack expected call: %v", err)
+	}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("expected calldata %x, got %x", expected, data)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, tc := range specMessages {
+		f.Add(tc.text)
+	}
+
+	f.Fuzz(func(t *testing.T, text string) {
+		m, err := Parse(text)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidMessage) {
+				t.Fatalf("expected ErrInvalidMessage, got %v", err)
+			}
+			return
+		}
+		// Messages parse the same once formatted again.
+		again, err := Parse(m.String())
+		if err != nil || again.String() != m.String() {
+			t.Fatalf("expected %q to parse again, got %v", m.String(), err)
+		}
+	})
+}
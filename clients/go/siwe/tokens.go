@@ -0,0 +1,267 @@
+package siwe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+const (
+	// DefaultValidity is how long SiweAuth tokens of messages without an
+	// expiration time are valid for.
+	DefaultValidity = 24 * time.Hour
+	// DefaultRefreshWindow is how long before they expire AuthTokenCache
+	// replaces tokens.
+	DefaultRefreshWindow = 5 * time.Minute
+	// DefaultTokenArgument is the name of the argument SiweAuth methods take
+	// the token in.
+	DefaultTokenArgument = sapphire.SiweTokenArgument
+)
+
+// Token is a bearer token SiweAuth's login function returned.
+type Token struct {
+	Bearer    []byte
+	ExpiresAt time.Time
+}
+
+// SignIn logs in to the SiweAuth contract with m, signed by signer, and
+// returns the token the contract issued. Pass a wrapped client as caller, so
+// that neither the message nor the token are sent in the clear.
+func SignIn(ctx context.Context, caller bind.ContractCaller, contract common.Address, signer sapphire.Signer, m *Message) (*Token, error) {
+	login, err := Sign(ctx, signer, m)
+	if err != nil {
+		return nil, err
+	}
+	data, err := login.CallData()
+	if err != nil {
+		return nil, err
+	}
+	res, err := caller.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in: %w", err)
+	}
+	out, err := loginOutputs.Unpack(res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in: %w", err)
+	}
+	expiresAt := m.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = m.IssuedAt.Add(DefaultValidity)
+	}
+	return &Token{Bearer: out[0].([]byte), ExpiresAt: expiresAt}, nil
+}
+
+var loginOutputs = func() abi.Arguments {
+	bytesType, _ := abi.NewType("bytes", "", nil)
+	return abi.Arguments{{Type: bytesType}}
+}()
+
+// TokenKey identifies the token of a user for a contract.
+type TokenKey struct {
+	Contract, User common.Address
+}
+
+// TokenStore persists the tokens of an AuthTokenCache. It must be safe for
+// concurrent use.
+type TokenStore interface {
+	// Get returns the token stored for key, or nil if there is none.
+	Get(ctx context.Context, key TokenKey) (*Token, error)
+	// Put stores token for key.
+	Put(ctx context.Context, key TokenKey, token *Token) error
+	// Delete removes the token stored for key, if any.
+	Delete(ctx context.Context, key TokenKey) error
+}
+
+// MemoryTokenStore is a TokenStore keeping tokens in memory.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[TokenKey]Token
+}
+
+// NewMemoryTokenStore returns an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[TokenKey]Token)}
+}
+
+// Get implements TokenStore.
+func (s *MemoryTokenStore) Get(_ context.Context, key TokenKey) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[key]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// Put implements TokenStore.
+func (s *MemoryTokenStore) Put(_ context.Context, key TokenKey, token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[key] = *token
+	return nil
+}
+
+// Delete implements TokenStore.
+func (s *MemoryTokenStore) Delete(_ context.Context, key TokenKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, key)
+	return nil
+}
+
+// LoginFunc obtains a new token for key, usually with SignIn.
+type LoginFunc func(ctx context.Context, key TokenKey) (*Token, error)
+
+// AuthTokenCacheOptions configures an AuthTokenCache. The zero value uses
+// the defaults.
+type AuthTokenCacheOptions struct {
+	// Store persists the tokens, a new MemoryTokenStore by default.
+	Store TokenStore
+	// RefreshWindow is how long before they expire tokens are replaced.
+	RefreshWindow time.Duration
+	// TokenArgument is the name of the argument PackAndCall passes the token
+	// in.
+	TokenArgument string
+}
+
+// withDefaults returns the options with unset fields set to their defaults.
+func (o AuthTokenCacheOptions) withDefaults() AuthTokenCacheOptions {
+	if o.Store == nil {
+		o.Store = NewMemoryTokenStore()
+	}
+	if o.RefreshWindow == 0 {
+		o.RefreshWindow = DefaultRefreshWindow
+	}
+	if o.TokenArgument == "" {
+		o.TokenArgument = DefaultTokenArgument
+	}
+	return o
+}
+
+// AuthTokenCache keeps the SiweAuth tokens of users for contracts, and logs
+// them in again when their tokens are about to expire. Concurrent requests
+// for the same token share a single login.
+type AuthTokenCache struct {
+	login LoginFunc
+	opts  AuthTokenCacheOptions
+	now   func() time.Time
+
+	mu sync.Mutex
+	// logins serializes the logins of each key.
+	logins map[TokenKey]*sync.Mutex
+}
+
+// NewAuthTokenCache returns a cache obtaining tokens with login.
+func NewAuthTokenCache(login LoginFunc, opts AuthTokenCacheOptions) *AuthTokenCache {
+	return &AuthTokenCache{
+		login:  login,
+		opts:   opts.withDefaults(),
+		now:    time.Now,
+		logins: make(map[TokenKey]*sync.Mutex),
+	}
+}
+
+// Token returns the token of user for contract, logging in if there is none
+// that is valid for longer than the refresh window.
+func (c *AuthTokenCache) Token(ctx context.Context, contract, user common.Address) (*Token, error) {
+	key := TokenKey{Contract: contract, User: user}
+	if token, err := c.valid(ctx, key); token != nil || err != nil {
+		return token, err
+	}
+
+	c.mu.Lock()
+	lock, ok := c.logins[key]
+	if !ok {
+		lock = new(sync.Mutex)
+		c.logins[key] = lock
+	}
+	c.mu.Unlock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Another caller may have logged in while this one waited.
+	if token, err := c.valid(ctx, key); token != nil || err != nil {
+		return token, err
+	}
+	token, err := c.login(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.opts.Store.Put(ctx, key, token); err != nil {
+		return nil, fmt.Errorf("failed to store token: %w", err)
+	}
+	return token, nil
+}
+
+// valid returns the stored token for key if it is not within the refresh
+// window of expiring.
+func (c *AuthTokenCache) valid(ctx context.Context, key TokenKey) (*Token, error) {
+	token, err := c.opts.Store.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token: %w", err)
+	}
+	if token == nil || !c.now().Add(c.opts.RefreshWindow).Before(token.ExpiresAt) {
+		return nil, nil
+	}
+	return token, nil
+}
+
+// Bearer returns the bearer token of user for contract, see Token. It makes
+// the cache a sapphire.TokenSource:
+//
+//	out, err := backend.PackAndAuthenticatedCall(ctx, contract, client.WithAuto(signer, client.WithTokenSource(cache)), parsedABI, method, args...)
+func (c *AuthTokenCache) Bearer(ctx context.Context, contract, user common.Address) ([]byte, error) {
+	token, err := c.Token(ctx, contract, user)
+	if err != nil {
+		return nil, err
+	}
+	return token.Bearer, nil
+}
+
+// Invalidate removes the token of user for contract, e.g. after the
+// contract revoked it, so that the next request logs in again.
+func (c *AuthTokenCache) Invalidate(ctx context.Context, contract, user common.Address) error {
+	return c.opts.Store.Delete(ctx, TokenKey{Contract: contract, User: user})
+}
+
+// PackAndCaller makes calls with PackAndCall, e.g. a client.WrappedBackend.
+type PackAndCaller interface {
+	PackAndCall(ctx context.Context, contract common.Address, parsedABI abi.ABI, method string, args ...interface{}) ([]interface{}, error)
+}
+
+// PackAndCall calls method with b.PackAndCall as user. If method takes the
+// token argument, the token of user for contract is passed in it, and args
+// are the other arguments.
+func (c *AuthTokenCache) PackAndCall(ctx context.Context, b PackAndCaller, contract, user common.Address, parsedABI abi.ABI, method string, args ...interface{}) ([]interface{}, error) {
+	m, ok := parsedABI.Methods[method]
+	if !ok {
+		return b.PackAndCall(ctx, contract, parsedABI, method, args...)
+	}
+	for i, input := range m.Inputs {
+		if input.Name != c.opts.TokenArgument {
+			continue
+		}
+		if i > len(args) {
+			return nil, fmt.Errorf("%w: %s takes %d arguments besides %s, got %d", sapphire.ErrABI, method, len(m.Inputs)-1, input.Name, len(args))
+		}
+		token, err := c.Token(ctx, contract, user)
+		if err != nil {
+			return nil, err
+		}
+		injected := make([]interface{}, 0, len(args)+1)
+		injected = append(injected, args[:i]...)
+		injected = append(injected, token.Bearer)
+		injected = append(injected, args[i:]...)
+		return b.PackAndCall(ctx, contract, parsedABI, method, injected...)
+	}
+	return b.PackAndCall(ctx, contract, parsedABI, method, args...)
+}
@@ -0,0 +1,224 @@
+package siwe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// loginContract is a SiweAuth contract issuing numbered tokens.
+type loginContract struct {
+	t      *testing.T
+	mu     sync.Mutex
+	logins int
+	delay  time.Duration
+}
+
+func (c *loginContract) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (c *loginContract) CallContract(_ context.Context, call ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	if !strings.HasPrefix(string(call.Data), string(loginSelector)) {
+		c.t.Errorf("unexpected call %x", call.Data)
+	}
+	args, err := loginArguments.Unpack(call.Data[4:])
+	if err != nil {
+		c.t.Errorf("failed to unpack login: %v", err)
+		return nil, err
+	}
+	sig := args[1].(struct {
+		R [32]byte `json:"r"`
+		S [32]byte `json:"s"`
+		V *big.Int `json:"v"`
+	})
+	m, err := Parse(args[0].(string))
+	if err != nil {
+		c.t.Errorf("invalid login: %v", err)
+		return nil, err
+	}
+	if _, err = Verify(args[0].(string), SignatureRSV{R: sig.R, S: sig.S, V: sig.V}.Bytes(), VerifyOptions{Domain: "localhost", Now: m.IssuedAt}); err != nil {
+		c.t.Errorf("invalid login: %v", err)
+	}
+	time.Sleep(c.delay)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logins++
+	return loginOutputs.Pack([]byte(fmt.Sprintf("token-%d", c.logins)))
+}
+
+func (c *loginContract) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.logins
+}
+
+// testUsers returns the signers of n new accounts.
+func testUsers(t *testing.T, n int) *sapphire.Keyring {
+	users := sapphire.NewKeyring()
+	for i := 0; i < n; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		users.Add(sapphire.NewPrivateKeySigner(key))
+	}
+	return users
+}
+
+// loginFunc logs users in to c with messages issued at now, valid for
+// validity.
+func loginFunc(c *loginContract, users *sapphire.Keyring, now *time.Time, validity time.Duration) LoginFunc {
+	return func(ctx context.Context, key TokenKey) (*Token, error) {
+		signer, ok := users.Signer(key.User)
+		if !ok {
+			return nil, sapphire.ErrNoSigner
+		}
+		return SignIn(ctx, c, key.Contract, signer, &Message{
+			Domain:    "localhost",
+			Address:   key.User,
+			URI:       "http://localhost",
+			ChainID:   0x5afd,
+			Nonce:     "12345678",
+			IssuedAt:  *now,
+			ExpiresAt: now.Add(validity),
+		})
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	ctx := context.Background()
+	c := &loginContract{t: t}
+	key, _ := crypto.HexToECDSA("ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+	signer := sapphire.NewPrivateKeySigner(key)
+	issued := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	token, err := SignIn(ctx, c, common.Address{1}, signer, &Message{Domain: "localhost", Address: signer.Address(), URI: "http://localhost", ChainID: 0x5afd, Nonce: "12345678", IssuedAt: issued})
+	if err != nil || string(token.Bearer) != "token-1" || !token.ExpiresAt.Equal(issued.Add(DefaultValidity)) {
+		t.Fatalf("SignIn returned %+v: %v", token, err)
+	}
+}
+
+func TestAuthTokenCache(t *testing.T) {
+	ctx := context.Background()
+	c := &loginContract{t: t}
+	now := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	users := testUsers(t, 1)
+	user := users.Addresses()[0]
+	contract := common.Address{1}
+	cache := NewAuthTokenCache(loginFunc(c, users, &now, time.Hour), AuthTokenCacheOptions{RefreshWindow: 10 * time.Minute})
+	cache.now = func() time.Time { return now }
+
+	token, err := cache.Token(ctx, contract, user)
+	if err != nil || string(token.Bearer) != "token-1" {
+		t.Fatalf("Token returned %+v: %v", token, err)
+	}
+	now = now.Add(49 * time.Minute)
+	if token, err = cache.Token(ctx, contract, user); err != nil || string(token.Bearer) != "token-1" {
+		t.Fatalf("expected the token to be reused, got %+v: %v", token, err)
+	}
+
+	// Tokens are replaced within the refresh window of expiring.
+	now = now.Add(time.Minute)
+	if token, err = cache.Token(ctx, contract, user); err != nil || string(token.Bearer) != "token-2" {
+		t.Fatalf("expected the token to be refreshed, got %+v: %v", token, err)
+	}
+	if token, err = cache.Token(ctx, common.Address{2}, user); err != nil || string(token.Bearer) != "token-3" {
+		t.Fatalf("expected a token per contract, got %+v: %v", token, err)
+	}
+	var _ sapphire.TokenSource = cache
+	if bearer, err := cache.Bearer(ctx, contract, user); err != nil || string(bearer) != "token-2" {
+		t.Fatalf("expected the cached token, got %q: %v", bearer, err)
+	}
+	if err = cache.Invalidate(ctx, contract, user); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	if token, err = cache.Token(ctx, contract, user); err != nil || string(token.Bearer) != "token-4" {
+		t.Fatalf("expected an invalidated token to be replaced, got %+v: %v", token, err)
+	}
+}
+
+func TestAuthTokenCacheConcurrent(t *testing.T) {
+	ctx := context.Background()
+	c := &loginContract{t: t, delay: 10 * time.Millisecond}
+	now := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	keyring := testUsers(t, 3)
+	cache := NewAuthTokenCache(loginFunc(c, keyring, &now, time.Hour), AuthTokenCacheOptions{})
+	cache.now = func() time.Time { return now }
+
+	users := keyring.Addresses()
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(user common.Address) {
+			defer wg.Done()
+			if _, err := cache.Token(ctx, common.Address{9}, user); err != nil {
+				t.Errorf("Token failed: %v", err)
+			}
+		}(users[i%len(users)])
+	}
+	wg.Wait()
+	if n := c.count(); n != len(users) {
+		t.Fatalf("expected a single login per user, got %d", n)
+	}
+}
+
+// failingStore fails to load tokens.
+type failingStore struct{ *MemoryTokenStore }
+
+func (failingStore) Get(context.Context, TokenKey) (*Token, error) {
+	return nil, errors.New("store unavailable")
+}
+
+type fakePackAndCaller struct {
+	args []interface{}
+}
+
+func (f *fakePackAndCaller) PackAndCall(_ context.Context, _ common.Address, parsedABI abi.ABI, method string, args ...interface{}) ([]interface{}, error) {
+	if _, err := parsedABI.Pack(method, args...); err != nil {
+		return nil, err
+	}
+	f.args = args
+	return nil, nil
+}
+
+func TestAuthTokenCachePackAndCall(t *testing.T) {
+	ctx := context.Background()
+	c := &loginContract{t: t}
+	now := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	users := testUsers(t, 1)
+	user := users.Addresses()[0]
+	cache := NewAuthTokenCache(loginFunc(c, users, &now, time.Hour), AuthTokenCacheOptions{})
+	parsed, err := abi.JSON(strings.NewReader(`[
+		{"type":"function","name":"getSecret","stateMutability":"view","inputs":[{"name":"id","type":"uint256"},{"name":"bearer","type":"bytes"}],"outputs":[]},
+		{"type":"function","name":"open","stateMutability":"view","inputs":[{"name":"id","type":"uint256"}],"outputs":[]}]`))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	b := &fakePackAndCaller{}
+	if _, err = cache.PackAndCall(ctx, b, common.Address{1}, user, parsed, "getSecret", big.NewInt(7)); err != nil {
+		t.Fatalf("PackAndCall failed: %v", err)
+	}
+	if len(b.args) != 2 || string(b.args[1].([]byte)) != "token-1" {
+		t.Fatalf("expected the token to be injected, got %v", b.args)
+	}
+	if _, err = cache.PackAndCall(ctx, b, common.Address{1}, user, parsed, "open", big.NewInt(7)); err != nil || len(b.args) != 1 || c.count() != 1 {
+		t.Fatalf("expected methods without token to be called as is, got %v: %v", b.args, err)
+	}
+
+	failing := NewAuthTokenCache(loginFunc(c, users, &now, time.Hour), AuthTokenCacheOptions{Store: failingStore{NewMemoryTokenStore()}})
+	if _, err = failing.PackAndCall(ctx, b, common.Address{1}, user, parsed, "getSecret", big.NewInt(7)); err == nil {
+		t.Fatalf("expected store errors to be returned")
+	}
+}
@@ -0,0 +1,326 @@
+package siwe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+const (
+	// DefaultVerifyMethod is the name of the view method, taking a token and
+	// returning the address of its user, that TokenVerifier calls. SiweAuth's
+	// authMsgSender is internal, so contracts must expose it under this
+	// name or set TokenVerifierOptions.Method.
+	DefaultVerifyMethod = "authMsgSender"
+	// DefaultVerifiedMaxAge is how long TokenVerifier memoizes verdicts by
+	// default, bounding how long revoked tokens are still accepted.
+	DefaultVerifiedMaxAge = time.Minute
+
+	// minTokenLength is the length of the shortest token SiweAuth issues:
+	// a Deoxys-II tag and the ABI encoding of a Bearer with an empty domain.
+	minTokenLength = 16 + 5*32
+	// sweepThreshold is how many memoized verdicts a TokenVerifier keeps
+	// before it first drops the expired ones.
+	sweepThreshold = 1024
+)
+
+var (
+	// ErrInvalidToken is returned for tokens the contract did not issue, or
+	// that were tampered with.
+	ErrInvalidToken = errors.New("invalid SiweAuth token")
+	// ErrRevokedToken is returned for tokens the contract revoked.
+	ErrRevokedToken = errors.New("SiweAuth token revoked")
+	// ErrRateLimited is returned when verifying a token would exceed the
+	// on-chain verification rate limit.
+	ErrRateLimited = errors.New("SiweAuth token verification rate limited")
+)
+
+// Selectors of the errors SiweAuth's authMsgSender reverts with.
+var (
+	domainMismatchSelector = crypto.Keccak256([]byte("DomainMismatch()"))[:4]
+	expiredSelector        = crypto.Keccak256([]byte("Expired()"))[:4]
+	revokedBearerSelector  = crypto.Keccak256([]byte("RevokedBearer()"))[:4]
+)
+
+var (
+	bytesArguments = func() abi.Arguments {
+		bytesType, _ := abi.NewType("bytes", "", nil)
+		return abi.Arguments{{Type: bytesType}}
+	}()
+	addressArguments = func() abi.Arguments {
+		addressType, _ := abi.NewType("address", "", nil)
+		return abi.Arguments{{Type: addressType}}
+	}()
+	stringArguments = func() abi.Arguments {
+		stringType, _ := abi.NewType("string", "", nil)
+		return abi.Arguments{{Type: stringType}}
+	}()
+	domainSelector = crypto.Keccak256([]byte("domain()"))[:4]
+)
+
+// TokenVerifierOptions configures a TokenVerifier.
+type TokenVerifierOptions struct {
+	// Domain is the [scheme "://"] domain tokens must be bound to. Tokens of
+	// contracts deployed for another domain are rejected.
+	Domain string
+	// Method is the name of the contract's method verifying tokens,
+	// DefaultVerifyMethod if empty.
+	Method string
+	// MaxAge is how long verdicts are memoized for, DefaultVerifiedMaxAge
+	// if zero.
+	MaxAge time.Duration
+	// RateLimit limits the on-chain verifications. The zero value means no
+	// limit.
+	RateLimit sapphire.RateLimit
+}
+
+// withDefaults returns the options with unset fields set to their defaults.
+func (o TokenVerifierOptions) withDefaults() TokenVerifierOptions {
+	if o.Method == "" {
+		o.Method = DefaultVerifyMethod
+	}
+	if o.MaxAge == 0 {
+		o.MaxAge = DefaultVerifiedMaxAge
+	}
+	return o
+}
+
+// TokenVerifierStats are the counters of a TokenVerifier.
+type TokenVerifierStats struct {
+	// Hits is the number of verifications answered from memoized verdicts.
+	Hits uint64
+	// Misses is the number of verifications that needed the contract.
+	Misses uint64
+	// Calls is the number of verification calls made to contracts.
+	Calls uint64
+	// Rejected is the number of tokens found invalid, expired or revoked.
+	Rejected uint64
+	// RateLimited is the number of verifications refused by the rate limit.
+	RateLimited uint64
+	// Entries is the number of memoized verdicts.
+	Entries int
+}
+
+// verdict is the memoized result of verifying a token.
+type verdict struct {
+	user  common.Address
+	err   error
+	until time.Time
+}
+
+type verdictKey struct {
+	contract common.Address
+	token    common.Hash
+}
+
+// TokenVerifier verifies SiweAuth tokens, e.g. ones a backend received from
+// browsers, and memoizes the verdicts so that not every request needs the
+// chain.
+//
+// Tokens are encrypted with a key only the contract knows, so they are
+// verified by calling the contract, after cheaper checks of their length,
+// claimed expiry and the domain of the contract. Pass a wrapped client as
+// caller, so that tokens are not sent in the clear.
+type TokenVerifier struct {
+	caller bind.ContractCaller
+	opts   TokenVerifierOptions
+	now    func() time.Time
+
+	mu       sync.Mutex
+	verdicts map[verdictKey]verdict
+	domains  map[common.Address]string
+	sweepAt  int
+	stats    TokenVerifierStats
+	// tokens and last make up the token bucket of the rate limit.
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenVerifier returns a verifier calling contracts with caller.
+func NewTokenVerifier(caller bind.ContractCaller, opts TokenVerifierOptions) *TokenVerifier {
+	return &TokenVerifier{
+		caller:   caller,
+		opts:     opts.withDefaults(),
+		now:      time.Now,
+		verdicts: make(map[verdictKey]verdict),
+		domains:  make(map[common.Address]string),
+		sweepAt:  sweepThreshold,
+		tokens:   math.Max(float64(opts.RateLimit.Burst), 1),
+	}
+}
+
+// Verify returns the user token authenticates for contract. A non-zero
+// token.ExpiresAt, e.g. from the SIWE message the token was issued for, is
+// checked before calling the contract and bounds how long the verdict is
+// memoized.
+//
+// Errors matching ErrInvalidToken, ErrExpired, ErrDomainMismatch or
+// ErrRevokedToken reject the token, ErrRateLimited and others mean it could
+// not be verified.
+func (v *TokenVerifier) Verify(ctx context.Context, contract common.Address, token *Token) (common.Address, error) {
+	now := v.now()
+	switch {
+	case len(token.Bearer) < minTokenLength:
+		return v.reject(fmt.Errorf("%w: %d bytes long", ErrInvalidToken, len(token.Bearer)))
+	case !token.ExpiresAt.IsZero() && !now.Before(token.ExpiresAt):
+		return v.reject(fmt.Errorf("%w: token expired at %s", ErrExpired, token.ExpiresAt.Format(time.RFC3339)))
+	}
+	if err := v.checkDomain(ctx, contract); err != nil {
+		return common.Address{}, err
+	}
+
+	key := verdictKey{contract: contract, token: crypto.Keccak256Hash(token.Bearer)}
+	v.mu.Lock()
+	if d, ok := v.verdicts[key]; ok && now.Before(d.until) {
+		v.stats.Hits++
+		v.mu.Unlock()
+		return d.user, d.err
+	}
+	v.stats.Misses++
+	if !v.allow(now) {
+		v.stats.RateLimited++
+		v.mu.Unlock()
+		return common.Address{}, ErrRateLimited
+	}
+	v.stats.Calls++
+	v.mu.Unlock()
+
+	user, err := v.call(ctx, contract, token.Bearer)
+	if err != nil && !isRejection(err) {
+		return common.Address{}, err
+	}
+	until := now.Add(v.opts.MaxAge)
+	if !token.ExpiresAt.IsZero() && token.ExpiresAt.Before(until) {
+		until = token.ExpiresAt
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err != nil {
+		v.stats.Rejected++
+	}
+	v.verdicts[key] = verdict{user: user, err: err, until: until}
+	if len(v.verdicts) >= v.sweepAt {
+		v.sweep(now)
+	}
+	return user, err
+}
+
+// Stats returns the verifier's counters.
+func (v *TokenVerifier) Stats() TokenVerifierStats {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	stats := v.stats
+	stats.Entries = len(v.verdicts)
+	return stats
+}
+
+// reject counts a token rejected without calling the contract.
+func (v *TokenVerifier) reject(err error) (common.Address, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.stats.Rejected++
+	return common.Address{}, err
+}
+
+// checkDomain checks that contract is bound to the verifier's domain. The
+// domain of a SiweAuth contract can't change, so it is fetched only once.
+func (v *TokenVerifier) checkDomain(ctx context.Context, contract common.Address) error {
+	v.mu.Lock()
+	domain, ok := v.domains[contract]
+	v.mu.Unlock()
+	if !ok {
+		res, err := v.caller.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: domainSelector}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to get domain: %w", err)
+		}
+		out, err := stringArguments.Unpack(res)
+		if err != nil {
+			return fmt.Errorf("failed to get domain: %w", err)
+		}
+		domain = out[0].(string)
+		v.mu.Lock()
+		v.domains[contract] = domain
+		v.mu.Unlock()
+	}
+	if domain != v.opts.Domain {
+		_, err := v.reject(fmt.Errorf("%w: contract is bound to %q, expected %q", ErrDomainMismatch, domain, v.opts.Domain))
+		return err
+	}
+	return nil
+}
+
+// call verifies bearer with the contract.
+func (v *TokenVerifier) call(ctx context.Context, contract common.Address, bearer []byte) (common.Address, error) {
+	packed, err := bytesArguments.Pack(bearer)
+	if err != nil {
+		return common.Address{}, err
+	}
+	selector := crypto.Keccak256([]byte(v.opts.Method + "(bytes)"))[:4]
+	data := append(append([]byte(nil), selector...), packed...)
+	res, err := v.caller.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if revert, ok := sapphire.RevertDataFromError(err); ok {
+		switch {
+		case bytes.HasPrefix(revert, expiredSelector):
+			return common.Address{}, fmt.Errorf("%w: token expired", ErrExpired)
+		case bytes.HasPrefix(revert, domainMismatchSelector):
+			return common.Address{}, fmt.Errorf("%w: token is bound to another domain", ErrDomainMismatch)
+		case bytes.HasPrefix(revert, revokedBearerSelector):
+			return common.Address{}, ErrRevokedToken
+		}
+		return common.Address{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to verify token: %w", err)
+	}
+	out, err := addressArguments.Unpack(res)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to verify token: %w", err)
+	}
+	return out[0].(common.Address), nil
+}
+
+// isRejection reports whether err is a verdict on the token rather than a
+// failure to verify it.
+func isRejection(err error) bool {
+	return errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrExpired) || errors.Is(err, ErrDomainMismatch) || errors.Is(err, ErrRevokedToken)
+}
+
+// allow takes a token from the rate limit's bucket, if there is one. The
+// caller must hold v.mu.
+func (v *TokenVerifier) allow(now time.Time) bool {
+	limit := v.opts.RateLimit
+	if limit.Rate <= 0 {
+		return true
+	}
+	if !v.last.IsZero() {
+		v.tokens = math.Min(math.Max(float64(limit.Burst), 1), v.tokens+now.Sub(v.last).Seconds()*limit.Rate)
+	}
+	v.last = now
+	if v.tokens < 1 {
+		return false
+	}
+	v.tokens--
+	return true
+}
+
+// sweep drops expired verdicts. The caller must hold v.mu.
+func (v *TokenVerifier) sweep(now time.Time) {
+	for key, d := range v.verdicts {
+		if !now.Before(d.until) {
+			delete(v.verdicts, key)
+		}
+	}
+	v.sweepAt = max(2*len(v.verdicts), sweepThreshold)
+}
@@ -0,0 +1,162 @@
+package siwe
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	sapphire "github.com/oasisprotocol/sapphire-paratime/clients/go"
+)
+
+// verifyContract is a SiweAuth contract exposing authMsgSender.
+type verifyContract struct {
+	t      *testing.T
+	domain string
+	// users are the users of the valid tokens.
+	users map[string]common.Address
+	// reverts are the errors authMsgSender reverts with for other tokens.
+	reverts map[string][]byte
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *verifyContract) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (c *verifyContract) CallContract(_ context.Context, call ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	if bytes.Equal(call.Data, domainSelector) {
+		return stringArguments.Pack(c.domain)
+	}
+	if !bytes.HasPrefix(call.Data, verifySelector) {
+		c.t.Errorf("unexpected call %x", call.Data)
+	}
+	args, err := bytesArguments.Unpack(call.Data[4:])
+	if err != nil {
+		c.t.Errorf("failed to unpack authMsgSender: %v", err)
+		return nil, err
+	}
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	bearer := string(args[0].([]byte))
+	if user, ok := c.users[bearer]; ok {
+		return addressArguments.Pack(user)
+	}
+	// Tampered tokens fail to decrypt, which reverts without data.
+	return nil, &sapphire.CallFailedError{Module: "evm", Code: 8, Message: "reverted: " + base64.StdEncoding.EncodeToString(c.reverts[bearer])}
+}
+
+func (c *verifyContract) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+// verifySelector is the selector of the method the tests verify tokens with.
+var verifySelector = crypto.Keccak256([]byte("testAuthMsgSender(bytes)"))[:4]
+
+// testToken returns a token of SiweAuth's length made of b.
+func testToken(b byte) []byte {
+	return bytes.Repeat([]byte{b}, minTokenLength+32)
+}
+
+func TestTokenVerifier(t *testing.T) {
+	ctx := context.Background()
+	user := common.Address{0xaa}
+	contract := common.Address{1}
+	c := &verifyContract{
+		t:      t,
+		domain: "https://localhost",
+		users:  map[string]common.Address{string(testToken(1)): user},
+		reverts: map[string][]byte{
+			string(testToken(2)): expiredSelector,
+			string(testToken(3)): revokedBearerSelector,
+		},
+	}
+	now := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	v := NewTokenVerifier(c, TokenVerifierOptions{Domain: "https://localhost", Method: "testAuthMsgSender"})
+	v.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if got, err := v.Verify(ctx, contract, &Token{Bearer: testToken(1)}); err != nil || got != user {
+			t.Fatalf("Verify returned %s: %v", got.Hex(), err)
+		}
+	}
+	if stats := v.Stats(); c.count() != 1 || stats.Hits != 1 || stats.Misses != 1 || stats.Calls != 1 || stats.Entries != 1 {
+		t.Fatalf("expected the verdict to be memoized, got %d calls, %+v", c.count(), stats)
+	}
+	now = now.Add(DefaultVerifiedMaxAge)
+	if _, err := v.Verify(ctx, contract, &Token{Bearer: testToken(1)}); err != nil || c.count() != 2 {
+		t.Fatalf("expected the token to be verified again after MaxAge, got %d calls: %v", c.count(), err)
+	}
+
+	for name, tc := range map[string]struct {
+		token *Token
+		err   error
+		calls int
+	}{
+		"expired":         {&Token{Bearer: testToken(2)}, ErrExpired, 1},
+		"claimed expired": {&Token{Bearer: testToken(1), ExpiresAt: now}, ErrExpired, 0},
+		"revoked":         {&Token{Bearer: testToken(3)}, ErrRevokedToken, 1},
+		"tampered":        {&Token{Bearer: append(testToken(1)[1:], 0)}, ErrInvalidToken, 1},
+		"truncated":       {&Token{Bearer: testToken(1)[:minTokenLength-1]}, ErrInvalidToken, 0},
+	} {
+		before := c.count()
+		for i := 0; i < 2; i++ {
+			if _, err := v.Verify(ctx, contract, tc.token); !errors.Is(err, tc.err) {
+				t.Errorf("%s: expected %v, got %v", name, tc.err, err)
+			}
+		}
+		if calls := c.count() - before; calls != tc.calls {
+			t.Errorf("%s: expected %d calls, got %d", name, tc.calls, calls)
+		}
+	}
+
+	other := NewTokenVerifier(c, TokenVerifierOptions{Domain: "https://example.com", Method: "testAuthMsgSender"})
+	before := c.count()
+	if _, err := other.Verify(ctx, contract, &Token{Bearer: testToken(1)}); !errors.Is(err, ErrDomainMismatch) || c.count() != before {
+		t.Fatalf("expected the contract's domain to be rejected without verifying the token, got %v", err)
+	}
+}
+
+func TestTokenVerifierRateLimit(t *testing.T) {
+	ctx := context.Background()
+	c := &verifyContract{t: t, domain: "localhost", users: map[string]common.Address{}}
+	for i := byte(1); i <= 3; i++ {
+		c.users[string(testToken(i))] = common.Address{i}
+	}
+	now := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	v := NewTokenVerifier(c, TokenVerifierOptions{Domain: "localhost", Method: "testAuthMsgSender", RateLimit: sapphire.RateLimit{Rate: 1, Burst: 2}})
+	v.now = func() time.Time { return now }
+
+	for i := byte(1); i <= 2; i++ {
+		if _, err := v.Verify(ctx, common.Address{1}, &Token{Bearer: testToken(i)}); err != nil {
+			t.Fatalf("Verify failed: %v", err)
+		}
+	}
+	if _, err := v.Verify(ctx, common.Address{1}, &Token{Bearer: testToken(3)}); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	// Memoized verdicts are not rate limited.
+	if _, err := v.Verify(ctx, common.Address{1}, &Token{Bearer: testToken(1)}); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	now = now.Add(time.Second)
+	if _, err := v.Verify(ctx, common.Address{1}, &Token{Bearer: testToken(3)}); err != nil {
+		t.Fatalf("expected the limit to refill, got %v", err)
+	}
+	if stats := v.Stats(); stats.RateLimited != 1 || stats.Calls != 3 || c.count() != 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
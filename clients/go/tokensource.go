@@ -0,0 +1,17 @@
+package sapphire
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SiweTokenArgument is the name of the argument in which methods of SiweAuth
+// contracts take the bearer token.
+const SiweTokenArgument = "bearer"
+
+// TokenSource returns the SIWE bearer token of user for a SiweAuth contract,
+// e.g. a siwe.AuthTokenCache.
+type TokenSource interface {
+	Bearer(ctx context.Context, contract, user common.Address) ([]byte, error)
+}